@@ -0,0 +1,216 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package boot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/partition"
+	"github.com/snapcore/snapd/release"
+)
+
+const (
+	// DefaultMaxBootAttempts is the number of times a new kernel or
+	// core snap is tried before falling back to the previous one.
+	DefaultMaxBootAttempts = 1
+
+	// bootloader variable counting the failed attempts to boot the
+	// snaps in snap_try_{core,kernel}
+	bootAttemptsVar = "snap_boot_attempts"
+
+	// maximum number of entries kept in the boot history
+	maxHistoryEntries = 50
+)
+
+// Results of a boot attempt recorded in the boot history.
+const (
+	BootSuccess  = "success"
+	BootRetry    = "retry"
+	BootRollback = "rollback"
+)
+
+// HistoryEntry records the outcome of an attempt to boot a new kernel
+// or core snap.
+type HistoryEntry struct {
+	Time time.Time `json:"time"`
+	// Result is one of BootSuccess, BootRetry or BootRollback.
+	Result string `json:"result"`
+	// Kind is either "kernel" or "core".
+	Kind string `json:"kind"`
+	// Snap is the snap blob that was tried, e.g. "pc-kernel_2.snap".
+	Snap string `json:"snap"`
+	// Fallback is the snap blob that got booted instead, if any.
+	Fallback string `json:"fallback,omitempty"`
+	Attempts int    `json:"attempts"`
+}
+
+var timeNow = time.Now
+
+// History returns the recorded boot attempts, oldest first.
+func History() ([]HistoryEntry, error) {
+	data, err := ioutil.ReadFile(dirs.SnapBootHistoryFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var history []HistoryEntry
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("cannot read boot history: %v", err)
+	}
+	return history, nil
+}
+
+func appendHistory(entries []HistoryEntry) error {
+	history, err := History()
+	if err != nil {
+		return err
+	}
+	history = append(history, entries...)
+	if len(history) > maxHistoryEntries {
+		history = history[len(history)-maxHistoryEntries:]
+	}
+
+	data, err := json.Marshal(history)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dirs.SnapBootHistoryFile), 0755); err != nil {
+		return err
+	}
+	// the history is public, regular users can read it via the API
+	return osutil.AtomicWriteFile(dirs.SnapBootHistoryFile, data, 0644, 0)
+}
+
+// AttemptOutcome describes what UpdateBootAttempts found out about the
+// current boot.
+type AttemptOutcome struct {
+	// Retry is set when the boot of the new snaps failed and another
+	// attempt was scheduled, the system needs to be restarted.
+	Retry bool
+	// RolledBack lists the snaps whose boot failed too many times
+	// and that were given up on.
+	RolledBack []HistoryEntry
+}
+
+// UpdateBootAttempts inspects the bootloader environment after a boot
+// and accounts for the attempt to boot new kernel or core snaps. A
+// failed attempt is retried until maxAttempts is reached, at which
+// point the rollback done by the bootloader is made final. Outcomes are
+// recorded in the boot history. It must be called before
+// partition.MarkBootSuccessful.
+func UpdateBootAttempts(maxAttempts int) (*AttemptOutcome, error) {
+	outcome := &AttemptOutcome{}
+	if release.OnClassic {
+		return outcome, nil
+	}
+	if maxAttempts < 1 {
+		maxAttempts = DefaultMaxBootAttempts
+	}
+
+	bootloader, err := partition.FindBootloader()
+	if err != nil {
+		return nil, fmt.Errorf("cannot update boot attempts: %s", err)
+	}
+
+	m, err := bootloader.GetBootVars("snap_mode", "snap_core", "snap_try_core", "snap_kernel", "snap_try_kernel", bootAttemptsVar)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []HistoryEntry
+	for _, kind := range []string{"kernel", "core"} {
+		try := m["snap_try_"+kind]
+		good := m["snap_"+kind]
+		if try == "" || try == good {
+			continue
+		}
+		entries = append(entries, HistoryEntry{
+			Kind:     kind,
+			Snap:     try,
+			Fallback: good,
+		})
+	}
+	if len(entries) == 0 {
+		return outcome, nil
+	}
+
+	attempts, _ := strconv.Atoi(m[bootAttemptsVar])
+	attempts++
+
+	var result string
+	newVars := map[string]string{}
+	switch m["snap_mode"] {
+	case "trying":
+		// the new snaps booted, they get marked as good by
+		// MarkBootSuccessful
+		result = BootSuccess
+		newVars[bootAttemptsVar] = ""
+	case "":
+		// the bootloader fell back to the previous snaps
+		if attempts < maxAttempts {
+			result = BootRetry
+			newVars["snap_mode"] = "try"
+			newVars[bootAttemptsVar] = strconv.Itoa(attempts)
+			outcome.Retry = true
+		} else {
+			result = BootRollback
+			for _, e := range entries {
+				newVars["snap_try_"+e.Kind] = ""
+			}
+			newVars[bootAttemptsVar] = ""
+		}
+	default:
+		// not attempted yet
+		return outcome, nil
+	}
+
+	now := timeNow()
+	for i := range entries {
+		entries[i].Time = now
+		entries[i].Result = result
+		entries[i].Attempts = attempts
+		if result == BootSuccess {
+			entries[i].Fallback = ""
+		}
+	}
+	if result == BootRollback {
+		outcome.RolledBack = entries
+	}
+
+	if err := bootloader.SetBootVars(newVars); err != nil {
+		return nil, err
+	}
+	if err := appendHistory(entries); err != nil {
+		return nil, fmt.Errorf("cannot record boot history: %v", err)
+	}
+
+	return outcome, nil
+}
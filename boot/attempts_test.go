@@ -0,0 +1,232 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package boot_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/boot/boottest"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/partition"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type attemptsSuite struct {
+	testutil.BaseTest
+	bootloader *boottest.MockBootloader
+	now        time.Time
+}
+
+var _ = Suite(&attemptsSuite{})
+
+func (s *attemptsSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+	s.AddCleanup(release.MockOnClassic(false))
+
+	s.bootloader = boottest.NewMockBootloader("mock", c.MkDir())
+	partition.ForceBootloader(s.bootloader)
+	s.AddCleanup(func() { partition.ForceBootloader(nil) })
+
+	s.now = time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	s.AddCleanup(boot.MockTimeNow(func() time.Time { return s.now }))
+}
+
+func (s *attemptsSuite) TestUpdateBootAttemptsOnClassic(c *C) {
+	restore := release.MockOnClassic(true)
+	defer restore()
+	s.bootloader.GetErr = os.ErrInvalid
+
+	outcome, err := boot.UpdateBootAttempts(1)
+	c.Assert(err, IsNil)
+	c.Check(outcome, DeepEquals, &boot.AttemptOutcome{})
+}
+
+func (s *attemptsSuite) TestUpdateBootAttemptsNothingTried(c *C) {
+	s.bootloader.BootVars = map[string]string{
+		"snap_mode":   "",
+		"snap_core":   "core_1.snap",
+		"snap_kernel": "pc-kernel_1.snap",
+	}
+
+	outcome, err := boot.UpdateBootAttempts(1)
+	c.Assert(err, IsNil)
+	c.Check(outcome, DeepEquals, &boot.AttemptOutcome{})
+
+	history, err := boot.History()
+	c.Assert(err, IsNil)
+	c.Check(history, HasLen, 0)
+}
+
+func (s *attemptsSuite) TestUpdateBootAttemptsNotAttemptedYet(c *C) {
+	s.bootloader.BootVars = map[string]string{
+		"snap_mode":       "try",
+		"snap_kernel":     "pc-kernel_1.snap",
+		"snap_try_kernel": "pc-kernel_2.snap",
+	}
+
+	outcome, err := boot.UpdateBootAttempts(1)
+	c.Assert(err, IsNil)
+	c.Check(outcome, DeepEquals, &boot.AttemptOutcome{})
+	c.Check(s.bootloader.BootVars["snap_mode"], Equals, "try")
+}
+
+func (s *attemptsSuite) TestUpdateBootAttemptsSuccess(c *C) {
+	s.bootloader.BootVars = map[string]string{
+		"snap_mode":          "trying",
+		"snap_kernel":        "pc-kernel_1.snap",
+		"snap_try_kernel":    "pc-kernel_2.snap",
+		"snap_boot_attempts": "1",
+	}
+
+	outcome, err := boot.UpdateBootAttempts(3)
+	c.Assert(err, IsNil)
+	c.Check(outcome, DeepEquals, &boot.AttemptOutcome{})
+	c.Check(s.bootloader.BootVars["snap_boot_attempts"], Equals, "")
+	// promoting the snaps is left to MarkBootSuccessful
+	c.Check(s.bootloader.BootVars["snap_try_kernel"], Equals, "pc-kernel_2.snap")
+
+	history, err := boot.History()
+	c.Assert(err, IsNil)
+	c.Check(history, DeepEquals, []boot.HistoryEntry{{
+		Time:     s.now,
+		Result:   boot.BootSuccess,
+		Kind:     "kernel",
+		Snap:     "pc-kernel_2.snap",
+		Attempts: 2,
+	}})
+}
+
+func (s *attemptsSuite) TestUpdateBootAttemptsRetry(c *C) {
+	s.bootloader.BootVars = map[string]string{
+		"snap_mode":     "",
+		"snap_core":     "core_1.snap",
+		"snap_try_core": "core_2.snap",
+	}
+
+	outcome, err := boot.UpdateBootAttempts(2)
+	c.Assert(err, IsNil)
+	c.Check(outcome, DeepEquals, &boot.AttemptOutcome{Retry: true})
+	c.Check(s.bootloader.BootVars, DeepEquals, map[string]string{
+		"snap_mode":          "try",
+		"snap_core":          "core_1.snap",
+		"snap_try_core":      "core_2.snap",
+		"snap_boot_attempts": "1",
+	})
+
+	// the second attempt fails too
+	s.now = s.now.Add(time.Minute)
+	s.bootloader.BootVars["snap_mode"] = ""
+	outcome, err = boot.UpdateBootAttempts(2)
+	c.Assert(err, IsNil)
+	rollback := boot.HistoryEntry{
+		Time:     s.now,
+		Result:   boot.BootRollback,
+		Kind:     "core",
+		Snap:     "core_2.snap",
+		Fallback: "core_1.snap",
+		Attempts: 2,
+	}
+	c.Check(outcome, DeepEquals, &boot.AttemptOutcome{
+		RolledBack: []boot.HistoryEntry{rollback},
+	})
+	c.Check(s.bootloader.BootVars, DeepEquals, map[string]string{
+		"snap_mode":          "",
+		"snap_core":          "core_1.snap",
+		"snap_try_core":      "",
+		"snap_boot_attempts": "",
+	})
+
+	history, err := boot.History()
+	c.Assert(err, IsNil)
+	c.Check(history, DeepEquals, []boot.HistoryEntry{{
+		Time:     s.now.Add(-time.Minute),
+		Result:   boot.BootRetry,
+		Kind:     "core",
+		Snap:     "core_2.snap",
+		Fallback: "core_1.snap",
+		Attempts: 1,
+	}, rollback})
+}
+
+func (s *attemptsSuite) TestUpdateBootAttemptsRollbackKernelAndCore(c *C) {
+	s.bootloader.BootVars = map[string]string{
+		"snap_mode":       "",
+		"snap_core":       "core_1.snap",
+		"snap_try_core":   "core_2.snap",
+		"snap_kernel":     "pc-kernel_1.snap",
+		"snap_try_kernel": "pc-kernel_2.snap",
+	}
+
+	// invalid values use the default
+	outcome, err := boot.UpdateBootAttempts(0)
+	c.Assert(err, IsNil)
+	c.Assert(outcome.Retry, Equals, false)
+	c.Assert(outcome.RolledBack, HasLen, 2)
+	c.Check(outcome.RolledBack[0].Kind, Equals, "kernel")
+	c.Check(outcome.RolledBack[0].Snap, Equals, "pc-kernel_2.snap")
+	c.Check(outcome.RolledBack[1].Kind, Equals, "core")
+	c.Check(outcome.RolledBack[1].Snap, Equals, "core_2.snap")
+}
+
+func (s *attemptsSuite) TestUpdateBootAttemptsGetError(c *C) {
+	s.bootloader.GetErr = os.ErrPermission
+
+	_, err := boot.UpdateBootAttempts(1)
+	c.Assert(err, Equals, os.ErrPermission)
+}
+
+func (s *attemptsSuite) TestHistoryIsCapped(c *C) {
+	for i := 0; i < 60; i++ {
+		s.bootloader.BootVars = map[string]string{
+			"snap_mode":     "trying",
+			"snap_core":     "core_1.snap",
+			"snap_try_core": "core_2.snap",
+		}
+		_, err := boot.UpdateBootAttempts(1)
+		c.Assert(err, IsNil)
+	}
+
+	history, err := boot.History()
+	c.Assert(err, IsNil)
+	c.Check(history, HasLen, 50)
+
+	st, err := os.Stat(dirs.SnapBootHistoryFile)
+	c.Assert(err, IsNil)
+	c.Check(st.Mode().Perm(), Equals, os.FileMode(0644))
+}
+
+func (s *attemptsSuite) TestHistoryInvalid(c *C) {
+	err := os.MkdirAll(filepath.Dir(dirs.SnapBootHistoryFile), 0755)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(dirs.SnapBootHistoryFile, []byte("garbage"), 0600)
+	c.Assert(err, IsNil)
+
+	_, err = boot.History()
+	c.Assert(err, ErrorMatches, "cannot read boot history: .*")
+}
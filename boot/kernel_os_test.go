@@ -20,6 +20,8 @@
 package boot_test
 
 import (
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -226,6 +228,53 @@ func (s *kernelOSSuite) TestSetNextBootForKernelForTheSameKernel(c *C) {
 	})
 }
 
+func (s *kernelOSSuite) TestSetNextBootForKernelWithSystemdBoot(c *C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	// use a fake ESP instead of the mock bootloader
+	partition.ForceBootloader(nil)
+	esp := filepath.Join(dirs.GlobalRootDir, "/boot/efi")
+	c.Assert(os.MkdirAll(filepath.Join(esp, "loader"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(esp, "loader/loader.conf"), nil, 0644), IsNil)
+	bootloader, err := partition.FindBootloader()
+	c.Assert(err, IsNil)
+	c.Assert(bootloader.Name(), Equals, "systemd-boot")
+	err = bootloader.SetBootVars(map[string]string{
+		"snap_core":   "core_1.snap",
+		"snap_kernel": "krnl_40.snap",
+	})
+	c.Assert(err, IsNil)
+
+	info := &snap.Info{}
+	info.Type = snap.TypeKernel
+	info.RealName = "krnl"
+	info.Revision = snap.R(42)
+
+	err = boot.SetNextBoot(info)
+	c.Assert(err, IsNil)
+
+	m, err := bootloader.GetBootVars("snap_mode", "snap_kernel", "snap_try_kernel")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{
+		"snap_mode":       "try",
+		"snap_kernel":     "krnl_40.snap",
+		"snap_try_kernel": "krnl_42.snap",
+	})
+	c.Check(osutil.FileExists(filepath.Join(esp, "loader/entries/snapd-try+1.conf")), Equals, true)
+	c.Check(boot.KernelOrOsRebootRequired(info), Equals, true)
+
+	// simulate good boot
+	err = bootloader.SetBootVars(map[string]string{
+		"snap_mode":       "",
+		"snap_kernel":     "krnl_42.snap",
+		"snap_try_kernel": "",
+	})
+	c.Assert(err, IsNil)
+	c.Check(boot.KernelOrOsRebootRequired(info), Equals, false)
+	c.Check(osutil.FileExists(filepath.Join(esp, "loader/entries/snapd-try+1.conf")), Equals, false)
+}
+
 func (s *kernelOSSuite) TestInUse(c *C) {
 	for _, t := range []struct {
 		bootVarKey   string
@@ -0,0 +1,47 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"time"
+)
+
+// BootHistoryEntry records the outcome of an attempt to boot a new kernel
+// or core snap.
+type BootHistoryEntry struct {
+	Time time.Time `json:"time"`
+	// Result is one of "success", "retry" or "rollback".
+	Result string `json:"result"`
+	// Kind is either "kernel" or "core".
+	Kind string `json:"kind"`
+	// Snap is the snap blob that was tried.
+	Snap string `json:"snap"`
+	// Fallback is the snap blob that got booted instead, if any.
+	Fallback string `json:"fallback,omitempty"`
+	Attempts int    `json:"attempts"`
+}
+
+// BootHistory returns the recorded attempts to boot new kernel or core
+// snaps, oldest first.
+func (client *Client) BootHistory() ([]BootHistoryEntry, error) {
+	var history []BootHistoryEntry
+	_, err := client.doSync("GET", "/v2/boot-history", nil, nil, nil, &history)
+	return history, err
+}
@@ -0,0 +1,56 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientBootHistory(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": [
+			{"time": "2018-09-01T10:00:00Z", "result": "rollback", "kind": "kernel", "snap": "pc-kernel_2.snap", "fallback": "pc-kernel_1.snap", "attempts": 1},
+			{"time": "2018-09-02T10:00:00Z", "result": "success", "kind": "core", "snap": "core_3.snap", "attempts": 1}
+		]
+	}`
+	history, err := cs.cli.BootHistory()
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/boot-history")
+	c.Check(history, check.DeepEquals, []client.BootHistoryEntry{{
+		Time:     time.Date(2018, 9, 1, 10, 0, 0, 0, time.UTC),
+		Result:   "rollback",
+		Kind:     "kernel",
+		Snap:     "pc-kernel_2.snap",
+		Fallback: "pc-kernel_1.snap",
+		Attempts: 1,
+	}, {
+		Time:     time.Date(2018, 9, 2, 10, 0, 0, 0, time.UTC),
+		Result:   "success",
+		Kind:     "core",
+		Snap:     "core_3.snap",
+		Attempts: 1,
+	}})
+}
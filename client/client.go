@@ -489,11 +489,15 @@ type CreateUserResult struct {
 // system-user assertions and looking for a matching email. If Email is
 // empty then all such assertions are considered and multiple users may
 // be created.
+//
+// Automatic marks the creation as not requested by a user, which the
+// device can refuse through the users.create.automatic option.
 type CreateUserOptions struct {
 	Email        string `json:"email,omitempty"`
 	Sudoer       bool   `json:"sudoer,omitempty"`
 	Known        bool   `json:"known,omitempty"`
 	ForceManaged bool   `json:"force-managed,omitempty"`
+	Automatic    bool   `json:"automatic,omitempty"`
 }
 
 // CreateUser creates a local system user. See CreateUserOptions for details.
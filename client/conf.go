@@ -24,6 +24,7 @@ import (
 	"encoding/json"
 	"net/url"
 	"strings"
+	"time"
 )
 
 // SetConf requests a snap to apply the provided patch to the configuration.
@@ -48,3 +49,36 @@ func (client *Client) Conf(snapName string, keys []string) (configuration map[st
 
 	return configuration, nil
 }
+
+// ConfHistoryEntry is a recorded change of a snap's configuration.
+type ConfHistoryEntry struct {
+	ID       int                    `json:"id"`
+	Time     time.Time              `json:"time"`
+	Change   string                 `json:"change,omitempty"`
+	User     string                 `json:"user,omitempty"`
+	Values   map[string]interface{} `json:"values"`
+	Previous map[string]interface{} `json:"previous"`
+}
+
+// ConfHistory asks for the history of changes of a snap's configuration.
+func (client *Client) ConfHistory(snapName string) ([]*ConfHistoryEntry, error) {
+	var history []*ConfHistoryEntry
+	_, err := client.doSync("GET", "/v2/snaps/"+snapName+"/conf/history", nil, nil, nil, &history)
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// RevertConf requests to restore a snap's configuration to how it was
+// right after the configuration history entry with the given id.
+func (client *Client) RevertConf(snapName string, id int) (changeID string, err error) {
+	b, err := json.Marshal(map[string]interface{}{
+		"action": "revert",
+		"id":     id,
+	})
+	if err != nil {
+		return "", err
+	}
+	return client.doAsync("POST", "/v2/snaps/"+snapName+"/conf/history", nil, nil, bytes.NewReader(b))
+}
@@ -21,8 +21,11 @@ package client_test
 
 import (
 	"encoding/json"
+	"time"
 
 	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
 )
 
 func (cs *clientSuite) TestClientSetConfCallsEndpoint(c *check.C) {
@@ -102,3 +105,45 @@ func (cs *clientSuite) TestClientGetConfMultipleKeys(c *check.C) {
 		"test-key2": "test-value2",
 	})
 }
+
+func (cs *clientSuite) TestClientConfHistory(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": [{"id": 1, "time": "2018-05-01T10:00:00Z", "change": "42", "user": "root", "values": {"key": "new"}, "previous": {"key": null}}]
+	}`
+	history, err := cs.cli.ConfHistory("snap-name")
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps/snap-name/conf/history")
+	c.Check(history, check.DeepEquals, []*client.ConfHistoryEntry{{
+		ID:       1,
+		Time:     time.Date(2018, 5, 1, 10, 0, 0, 0, time.UTC),
+		Change:   "42",
+		User:     "root",
+		Values:   map[string]interface{}{"key": "new"},
+		Previous: map[string]interface{}{"key": nil},
+	}})
+}
+
+func (cs *clientSuite) TestClientRevertConf(c *check.C) {
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"result": { },
+		"change": "foo"
+	}`
+	id, err := cs.cli.RevertConf("snap-name", 3)
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "foo")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps/snap-name/conf/history")
+	var body map[string]interface{}
+	decoder := json.NewDecoder(cs.req.Body)
+	err = decoder.Decode(&body)
+	c.Check(err, check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action": "revert",
+		"id":     3.,
+	})
+}
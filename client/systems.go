@@ -0,0 +1,76 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// System holds information about a recovery system of the device.
+type System struct {
+	Label string `json:"label"`
+	// Current is set for the system the device got seeded from.
+	Current bool `json:"current,omitempty"`
+}
+
+// Systems lists the recovery systems of the device.
+func (client *Client) Systems() ([]System, error) {
+	var systems []System
+	_, err := client.doSync("GET", "/v2/systems", nil, nil, nil, &systems)
+	return systems, err
+}
+
+type systemAction struct {
+	Action string `json:"action"`
+	Label  string `json:"label,omitempty"`
+}
+
+func (client *Client) performSystemAction(sa *systemAction) (changeID string, err error) {
+	b, err := json.Marshal(sa)
+	if err != nil {
+		return "", err
+	}
+	return client.doAsync("POST", "/v2/systems", nil, nil, bytes.NewReader(b))
+}
+
+// CreateRecoverySystem creates a recovery system with the given label
+// out of the snaps currently installed.
+func (client *Client) CreateRecoverySystem(label string) (changeID string, err error) {
+	return client.performSystemAction(&systemAction{
+		Action: "create",
+		Label:  label,
+	})
+}
+
+// ResetSystem reboots the device and reseeds it from the recovery
+// system with the given label, or from its original seed if the label
+// is empty. With factoryReset the users, configuration and data of
+// the snaps are wiped as well.
+func (client *Client) ResetSystem(label string, factoryReset bool) (changeID string, err error) {
+	action := "recover"
+	if factoryReset {
+		action = "factory-reset"
+	}
+	return client.performSystemAction(&systemAction{
+		Action: action,
+		Label:  label,
+	})
+}
@@ -0,0 +1,88 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"encoding/json"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestClientSystems(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": [{"label": "1234"}, {"label": "5678", "current": true}]
+	}`
+	systems, err := cs.cli.Systems()
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/systems")
+	c.Check(systems, check.DeepEquals, []client.System{
+		{Label: "1234"},
+		{Label: "5678", Current: true},
+	})
+}
+
+func (cs *clientSuite) TestClientCreateRecoverySystem(c *check.C) {
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"result": { },
+		"change": "chgid"
+	}`
+	id, err := cs.cli.CreateRecoverySystem("1234")
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "chgid")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/systems")
+	var body map[string]interface{}
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"action": "create",
+		"label":  "1234",
+	})
+}
+
+func (cs *clientSuite) TestClientResetSystem(c *check.C) {
+	for _, t := range []struct {
+		label        string
+		factoryReset bool
+		body         map[string]interface{}
+	}{
+		{"1234", false, map[string]interface{}{"action": "recover", "label": "1234"}},
+		{"1234", true, map[string]interface{}{"action": "factory-reset", "label": "1234"}},
+		{"", true, map[string]interface{}{"action": "factory-reset"}},
+	} {
+		cs.rsp = `{
+			"type": "async",
+			"status-code": 202,
+			"result": { },
+			"change": "chgid"
+		}`
+		id, err := cs.cli.ResetSystem(t.label, t.factoryReset)
+		c.Assert(err, check.IsNil)
+		c.Check(id, check.Equals, "chgid")
+		var body map[string]interface{}
+		c.Assert(json.NewDecoder(cs.req.Body).Decode(&body), check.IsNil)
+		c.Check(body, check.DeepEquals, t.body)
+	}
+}
@@ -141,17 +141,16 @@ static void setup_private_pts(void)
 }
 
 /**
- * Setup mount profiles by running snap-update-ns.
+ * Run snap-update-ns with the given option to apply mount profiles.
  *
  * The first argument is an open file descriptor (though opened with O_PATH, so
  * not as powerful), to a copy of snap-update-ns. The program is opened before
  * the root filesystem is pivoted so that it is easier to pick the right copy.
  **/
-static void sc_setup_mount_profiles(struct sc_apparmor *apparmor,
-				    int snap_update_ns_fd,
-				    const char *snap_name)
+static void sc_call_snap_update_ns(struct sc_apparmor *apparmor,
+				   int snap_update_ns_fd,
+				   const char *snap_name, const char *option)
 {
-	debug("calling snap-update-ns to initialize mount namespace");
 	pid_t child = fork();
 	if (child < 0) {
 		die("cannot fork to run snap-update-ns");
@@ -169,8 +168,13 @@ static void sc_setup_mount_profiles(struct sc_apparmor *apparmor,
 		if (snap_name_copy == NULL) {
 			die("cannot copy snap name");
 		}
+		char *option_copy SC_CLEANUP(sc_cleanup_string) = NULL;
+		option_copy = strdup(option);
+		if (option_copy == NULL) {
+			die("cannot copy snap-update-ns option");
+		}
 		char *argv[] = {
-			"snap-update-ns", "--from-snap-confine", snap_name_copy,
+			"snap-update-ns", option_copy, snap_name_copy,
 			NULL
 		};
 		char *envp[3] = { NULL };
@@ -196,6 +200,46 @@ static void sc_setup_mount_profiles(struct sc_apparmor *apparmor,
 	debug("snap-update-ns finished successfully");
 }
 
+/**
+ * Setup mount profiles by running snap-update-ns.
+ **/
+static void sc_setup_mount_profiles(struct sc_apparmor *apparmor,
+				    int snap_update_ns_fd,
+				    const char *snap_name)
+{
+	debug("calling snap-update-ns to initialize mount namespace");
+	sc_call_snap_update_ns(apparmor, snap_update_ns_fd, snap_name,
+			       "--from-snap-confine");
+}
+
+void sc_setup_user_mounts(struct sc_apparmor *apparmor, int snap_update_ns_fd,
+			  const char *snap_name)
+{
+	char profile_path[PATH_MAX] = { 0 };
+	struct stat st;
+
+	sc_must_snprintf(profile_path, sizeof profile_path,
+			 "/var/lib/snapd/mount/snap.%s.user-fstab", snap_name);
+	if (stat(profile_path, &st) != 0) {
+		// It is ok for the user fstab to not exist.
+		debug("no user mount profile for snap %s", snap_name);
+		return;
+	}
+	// Construct a private copy of the per-snap mount namespace. The copy is
+	// used by this process (and its children) alone and is discarded when the
+	// last process in it terminates.
+	debug("unsharing the mount namespace for per-user mounts");
+	if (unshare(CLONE_NEWNS) < 0) {
+		die("cannot unshare the mount namespace for per-user mounts");
+	}
+	// Recursively change all mounts to slave mode, so that we see changes
+	// from the per-snap namespace but we don't propagate our own changes.
+	sc_do_mount("none", "/", NULL, MS_REC | MS_SLAVE, NULL);
+	debug("calling snap-update-ns to apply per-user mounts");
+	sc_call_snap_update_ns(apparmor, snap_update_ns_fd, snap_name,
+			       "--user-mounts");
+}
+
 struct sc_mount {
 	const char *path;
 	bool is_bidirectional;
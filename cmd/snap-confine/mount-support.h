@@ -45,6 +45,17 @@ int sc_open_snap_update_ns(void);
 void sc_populate_mount_ns(struct sc_apparmor *apparmor, int snap_update_ns_fd,
 			  const char *base_snap_name, const char *snap_name);
 
+/**
+ * Construct the per-user mount namespace and apply per-user mounts.
+ *
+ * If the snap has a per-user mount profile the calling process moves to a
+ * private copy of the per-snap mount namespace and snap-update-ns is used to
+ * apply the per-user mount profile there. The profile may refer to the home
+ * directory and the XDG runtime directory of the real user.
+ **/
+void sc_setup_user_mounts(struct sc_apparmor *apparmor, int snap_update_ns_fd,
+			  const char *snap_name);
+
 /**
  * Ensure that / or /snap is mounted with the SHARED option.
  *
@@ -250,6 +250,10 @@ int main(int argc, char **argv)
 				sc_preserve_populated_ns_group(group);
 			}
 			sc_close_ns_group(group);
+			// Apply per-user mounts (if any) in a private copy of the
+			// per-snap mount namespace.
+			sc_setup_user_mounts(&apparmor, snap_update_ns_fd,
+					     snap_name);
 			// older versions of snap-confine created incorrect
 			// 777 permissions for /var/lib and we need to fixup
 			// for systems that had their NS created with an
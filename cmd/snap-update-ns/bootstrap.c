@@ -208,6 +208,15 @@ void process_arguments(int argc, char *const *argv, const char** snap_name_out,
                 // option skip the setns call as snap-confine has
                 // already placed us in the right namespace.
                 should_setns = false;
+            } else if (!strcmp(arg, "--user-mounts")) {
+                // When we are running under "--user-mounts" option skip
+                // the setns call as snap-confine has already placed us in
+                // the per-user mount namespace.
+                should_setns = false;
+            } else if (!strcmp(arg, "--show-changes")) {
+                // When we are running under "--show-changes" option skip
+                // the setns call as we only inspect the mount profiles.
+                should_setns = false;
             } else {
                 bootstrap_errno = 0;
                 bootstrap_msg = "unsupported option";
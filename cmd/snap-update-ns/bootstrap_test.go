@@ -66,6 +66,10 @@ func (s *bootstrapSuite) TestProcessArguments(c *C) {
 		// The option --from-snap-confine disables setns.
 		{[]string{"argv0", "--from-snap-confine", "snapname"}, "snapname", false, ""},
 		{[]string{"argv0", "snapname", "--from-snap-confine"}, "snapname", false, ""},
+		// The option --user-mounts disables setns.
+		{[]string{"argv0", "--user-mounts", "snapname"}, "snapname", false, ""},
+		// The option --show-changes disables setns.
+		{[]string{"argv0", "--show-changes", "snapname"}, "snapname", false, ""},
 		// Unknown options are reported.
 		{[]string{"argv0", "-invalid"}, "", false, "unsupported option"},
 		{[]string{"argv0", "--option"}, "", false, "unsupported option"},
@@ -29,6 +29,8 @@ import (
 
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/sys"
+	"github.com/snapcore/snapd/strutil"
 )
 
 // Action represents a mount action (mount, remount, unmount, etc).
@@ -63,11 +65,18 @@ func (c *Change) createPath(path string, pokeHoles bool) ([]*Change, error) {
 	var changes []*Change
 
 	// In case we need to create something, some constants.
-	const (
-		mode = 0755
-		uid  = 0
-		gid  = 0
-	)
+	const mode = 0755
+
+	// Things are owned by root unless the mount entry says otherwise. Entries
+	// of the per-user mount profile are owned by the user.
+	uid, err := c.Entry.XSnapdUID()
+	if err != nil {
+		return nil, err
+	}
+	gid, err := c.Entry.XSnapdGID()
+	if err != nil {
+		return nil, err
+	}
 
 	// If the element doesn't exist we can attempt to create it.  We will
 	// create the parent directory and then the final element relative to it.
@@ -78,39 +87,53 @@ func (c *Change) createPath(path string, pokeHoles bool) ([]*Change, error) {
 	// TODO: re-factor this, if possible, with inspection and preemptive
 	// creation after the current release ships. This should be possible but
 	// will affect tests heavily (churn, not safe before release).
-	switch kind {
-	case "":
-		err = secureMkdirAll(path, mode, uid, gid)
-	case "file":
-		err = secureMkfileAll(path, mode, uid, gid)
-	case "symlink":
-		target, _ := c.Entry.OptStr("x-snapd.symlink")
-		if target == "" {
-			err = fmt.Errorf("cannot create symlink with empty target")
-		} else {
-			err = secureMksymlinkAll(path, mode, uid, gid, target)
+	create := func() error {
+		switch kind {
+		case "":
+			return secureMkdirAll(path, mode, sys.UserID(uid), sys.GroupID(gid))
+		case "file":
+			return secureMkfileAll(path, mode, sys.UserID(uid), sys.GroupID(gid))
+		case "symlink":
+			target, _ := c.Entry.OptStr("x-snapd.symlink")
+			if target == "" {
+				return fmt.Errorf("cannot create symlink with empty target")
+			}
+			return secureMksymlinkAll(path, mode, sys.UserID(uid), sys.GroupID(gid), target)
 		}
+		return nil
 	}
-	if err2, ok := err.(*ReadOnlyFsError); ok && pokeHoles {
-		// If the writing failed because the underlying file-system is read-only
-		// we can construct a writable mimic to fix that.
-		changes, err = createWritableMimic(err2.Path, path)
-		if err != nil {
-			err = fmt.Errorf("cannot create writable mimic over %q: %s", err2.Path, err)
-		} else {
-			// Try once again. Note that we care *just* about the error. We have already
-			// performed the hole poking and thus additional changes must be nil.
-			_, err = c.createPath(path, false)
+	err = create()
+
+	// If the writing failed because the underlying file-system is read-only
+	// we can construct a writable mimic to fix that. A writable mimic only
+	// makes one directory writable, the path may traverse another read-only
+	// directory deeper down (e.g. one bind mounted back from the original)
+	// so keep going for as long as each mimic is over a new directory.
+	var mimics []string
+	for pokeHoles {
+		err2, ok := err.(*ReadOnlyFsError)
+		if !ok || strutil.ListContains(mimics, err2.Path) {
+			break
+		}
+		mimics = append(mimics, err2.Path)
+		synthesised, err3 := createWritableMimic(err2.Path, c.Entry.XSnapdEntryID())
+		if err3 != nil {
+			return changes, fmt.Errorf("cannot create writable mimic over %q: %s", err2.Path, err3)
 		}
-	} else if err != nil {
+		changes = append(changes, synthesised...)
+		// Try once again. The mimic is in place so the error, if any, is
+		// either about another read-only directory or is final.
+		err = create()
+	}
+	if err != nil {
 		err = fmt.Errorf("cannot create path %q: %s", path, err)
 	}
 	return changes, err
 }
 
-func (c *Change) ensureTarget() ([]*Change, error) {
-	var changes []*Change
-
+// ensureTarget ensures the mount point of the change exists, creating it if
+// necessary. It reports whether the mount point was created.
+func (c *Change) ensureTarget() (changes []*Change, created bool, err error) {
 	kind, _ := c.Entry.OptStr("x-snapd.kind")
 	path := c.Entry.Dir
 
@@ -144,11 +167,12 @@ func (c *Change) ensureTarget() ([]*Change, error) {
 		}
 	} else if os.IsNotExist(err) {
 		changes, err = c.createPath(path, true)
+		created = err == nil
 	} else {
 		// If we cannot inspect the element let's just bail out.
 		err = fmt.Errorf("cannot inspect %q: %v", path, err)
 	}
-	return changes, err
+	return changes, created, err
 }
 
 func (c *Change) ensureSource() error {
@@ -188,6 +212,7 @@ func (c *Change) ensureSource() error {
 
 // changePerformImpl is the real implementation of Change.Perform
 func changePerformImpl(c *Change) (changes []*Change, err error) {
+	var created bool
 	if c.Action == Mount {
 		// We may be asked to bind mount a file, bind mount a directory, mount
 		// a filesystem over a directory, or create a symlink (which is abusing
@@ -197,7 +222,7 @@ func changePerformImpl(c *Change) (changes []*Change, err error) {
 		// As a result of this ensure call we may need to make the medium writable
 		// and that's why we may return more changes as a result of performing this
 		// one.
-		changes, err = c.ensureTarget()
+		changes, created, err = c.ensureTarget()
 		if err != nil {
 			return changes, err
 		}
@@ -216,6 +241,12 @@ func changePerformImpl(c *Change) (changes []*Change, err error) {
 
 	// Perform the underlying mount / unmount / unlink call.
 	err = c.lowLevelPerform()
+	kind, _ := c.Entry.OptStr("x-snapd.kind")
+	if err == nil && created && kind == "" && c.Entry.XSnapdOrigin() == "layout" {
+		// Remember that the mount point of the layout was created here so
+		// that it is removed, and only then, once the layout goes away.
+		c.Entry.Options = append(c.Entry.Options[:len(c.Entry.Options):len(c.Entry.Options)], osutil.XSnapdCreatedOpt())
+	}
 	return changes, err
 }
 
@@ -244,6 +275,10 @@ func (c *Change) lowLevelPerform() error {
 			// symlinks are handled in createInode directly, nothing to do here.
 		case "", "file":
 			flags, unparsed := osutil.MountOptsToCommonFlags(c.Entry.Options)
+			if c.isUserMount() {
+				err = c.userMount(flags, strings.Join(unparsed, ","))
+				break
+			}
 			err = sysMount(c.Entry.Name, c.Entry.Dir, c.Entry.Type, uintptr(flags), strings.Join(unparsed, ","))
 			logger.Debugf("mount %q %q %q %d %q (error: %v)", c.Entry.Name, c.Entry.Dir, c.Entry.Type, uintptr(flags), strings.Join(unparsed, ","), err)
 		}
@@ -261,6 +296,16 @@ func (c *Change) lowLevelPerform() error {
 			}
 			err = sysUnmount(c.Entry.Dir, flags)
 			logger.Debugf("umount %q (error: %v)", c.Entry.Dir, err)
+			if err == nil && kind == "" && c.Entry.XSnapdOrigin() == "layout" && c.Entry.XSnapdCreated() {
+				// When a layout is torn down try to remove the mount point
+				// that was created for it. Directories that existed before
+				// the layout was mounted are left alone. This only works
+				// for empty directories on writable file systems, which is
+				// exactly what we want. Directories created inside a
+				// writable mimic go away along with the mimic itself.
+				err2 := osRemove(c.Entry.Dir)
+				logger.Debugf("remove %q (error: %v)", c.Entry.Dir, err2)
+			}
 		}
 		return err
 	case Keep:
@@ -269,6 +314,62 @@ func (c *Change) lowLevelPerform() error {
 	return fmt.Errorf("cannot process mount change: unknown action: %q", c.Action)
 }
 
+// isUserMount tells whether the change comes from the per-user mount profile.
+//
+// Only entries of the per-user mount profile are tagged with the user that
+// owns the things created for them.
+func (c *Change) isUserMount() bool {
+	_, ok := c.Entry.OptStr("x-snapd.uid")
+	return ok
+}
+
+// userMount performs a mount of an entry of the per-user mount profile.
+//
+// Such mounts happen in locations controlled by the user, typically in $HOME,
+// where the user may replace any path segment with a symbolic link at any
+// time. The mount point, and the source of bind mounts, are therefore opened
+// with secureOpenPath and the mount is performed through the file descriptors
+// rather than through the paths.
+func (c *Change) userMount(flags int, data string) error {
+	targetFd, err := secureOpenPath(c.Entry.Dir)
+	if err != nil {
+		return err
+	}
+	defer sysClose(targetFd)
+	target := fmt.Sprintf("/proc/self/fd/%d", targetFd)
+
+	source := c.Entry.Name
+	if flags&syscall.MS_BIND != 0 {
+		sourceFd, err := secureOpenPath(c.Entry.Name)
+		if err != nil {
+			return err
+		}
+		defer sysClose(sourceFd)
+		source = fmt.Sprintf("/proc/self/fd/%d", sourceFd)
+	}
+
+	err = sysMount(source, target, c.Entry.Type, uintptr(flags), data)
+	logger.Debugf("mount %q (%q) %q (%q) %q %d %q (error: %v)", source, c.Entry.Name, target, c.Entry.Dir, c.Entry.Type, uintptr(flags), data, err)
+	return err
+}
+
+// equalIgnoringCreated compares a current mount entry with a desired one,
+// disregarding the marker of mount points created by snap-update-ns that
+// only current entries carry.
+func equalIgnoringCreated(current, desired *osutil.MountEntry) bool {
+	if !current.XSnapdCreated() {
+		return current.Equal(desired)
+	}
+	stripped := *current
+	stripped.Options = nil
+	for _, opt := range current.Options {
+		if opt != osutil.XSnapdCreatedOpt() {
+			stripped.Options = append(stripped.Options, opt)
+		}
+	}
+	return stripped.Equal(desired)
+}
+
 // NeededChanges computes the changes required to change current to desired mount entries.
 //
 // The current and desired profiles is a fstab like list of mount entries. The
@@ -347,7 +448,7 @@ func NeededChanges(currentProfile, desiredProfile *osutil.MountProfile) []*Chang
 		}
 
 		// Reuse entries that are desired and identical in the current profile.
-		if entry, ok := desiredMap[dir]; ok && current[i].Equal(entry) {
+		if entry, ok := desiredMap[dir]; ok && equalIgnoringCreated(&current[i], entry) {
 			logger.Debugf("reusing unchanged entry %q", current[i])
 			reuse[dir] = true
 			continue
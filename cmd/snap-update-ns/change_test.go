@@ -21,6 +21,7 @@ package main_test
 
 import (
 	"errors"
+	"os"
 	"syscall"
 
 	. "gopkg.in/check.v1"
@@ -80,6 +81,16 @@ func (s *changeSuite) TestNeededChangesNoChange(c *C) {
 	})
 }
 
+// When a layout mount point was created it is still reused.
+func (s *changeSuite) TestNeededChangesNoChangeCreatedMountPoint(c *C) {
+	current := &osutil.MountProfile{Entries: []osutil.MountEntry{{Dir: "/common/stuff", Options: []string{"x-snapd.origin=layout", "x-snapd.created"}}}}
+	desired := &osutil.MountProfile{Entries: []osutil.MountEntry{{Dir: "/common/stuff", Options: []string{"x-snapd.origin=layout"}}}}
+	changes := update.NeededChanges(current, desired)
+	c.Assert(changes, DeepEquals, []*update.Change{
+		{Entry: current.Entries[0], Action: update.Keep},
+	})
+}
+
 // When the content interface is connected we should mount the new entry.
 func (s *changeSuite) TestNeededChangesTrivialMount(c *C) {
 	current := &osutil.MountProfile{}
@@ -360,6 +371,29 @@ func (s *changeSuite) TestPerformFilesystemMountWithoutMountPoint(c *C) {
 	})
 }
 
+// Change.Perform wants to mount a layout and creates the missing mount point.
+func (s *changeSuite) TestPerformLayoutMountWithoutMountPoint(c *C) {
+	s.sys.InsertFault(`lstat "/target"`, syscall.ENOENT)
+	chg := &update.Change{Action: update.Mount, Entry: osutil.MountEntry{Name: "device", Dir: "/target", Type: "type", Options: []string{"x-snapd.origin=layout"}}}
+	synth, err := chg.Perform()
+	c.Assert(err, IsNil)
+	c.Assert(synth, HasLen, 0)
+	c.Assert(s.sys.Calls(), testutil.Contains, `mkdirat 3 "target" 0755`)
+	// The created mount point is tracked in the entry.
+	c.Assert(chg.Entry.Options, DeepEquals, []string{"x-snapd.origin=layout", "x-snapd.created"})
+}
+
+// Change.Perform wants to mount a layout over an existing mount point.
+func (s *changeSuite) TestPerformLayoutMountWithMountPoint(c *C) {
+	s.sys.InsertLstatResult(`lstat "/target"`, testutil.FileInfoDir)
+	chg := &update.Change{Action: update.Mount, Entry: osutil.MountEntry{Name: "device", Dir: "/target", Type: "type", Options: []string{"x-snapd.origin=layout"}}}
+	synth, err := chg.Perform()
+	c.Assert(err, IsNil)
+	c.Assert(synth, HasLen, 0)
+	// The mount point existed before and is not tracked.
+	c.Assert(chg.Entry.Options, DeepEquals, []string{"x-snapd.origin=layout"})
+}
+
 // Change.Perform wants to create a filesystem but the mount point isn't there and cannot be created.
 func (s *changeSuite) TestPerformFilesystemMountWithoutMountPointWithErrors(c *C) {
 	s.sys.InsertFault(`lstat "/target"`, syscall.ENOENT)
@@ -520,6 +554,66 @@ func (s *changeSuite) TestPerformFilesystemMountWithoutMountPointAndReadOnlyBase
 	})
 }
 
+// Change.Perform wants to mount a filesystem deep inside a read-only directory that needs two writable mimics.
+func (s *changeSuite) TestPerformFilesystemMountWithoutMountPointAndNestedReadOnlyBase(c *C) {
+	s.sys.InsertFault(`lstat "/rofs/sub/target"`, syscall.ENOENT)
+	s.sys.InsertFault(`mkdirat 3 "rofs" 0755`, syscall.EEXIST)
+	// The first attempt fails in /rofs, the subsequent attempts find the
+	// directory bind mounted back by the first writable mimic.
+	s.sys.InsertFault(`mkdirat 4 "sub" 0755`, syscall.EROFS, syscall.EEXIST, syscall.EEXIST)
+	// The second attempt fails in /rofs/sub which is still read-only.
+	s.sys.InsertFault(`mkdirat 5 "target" 0755`, syscall.EROFS, nil)
+	s.sys.InsertFault(`lstat "/tmp/.snap/rofs"`, syscall.ENOENT)
+	s.sys.InsertFault(`lstat "/tmp/.snap/rofs/sub"`, syscall.ENOENT)
+	s.sys.InsertLstatResult(`lstat "/rofs"`, testutil.FileInfoDir)
+	s.sys.InsertLstatResult(`lstat "/rofs/sub"`, testutil.FileInfoDir)
+	s.sys.InsertReadDirResult(`readdir "/rofs"`, []os.FileInfo{testutil.FakeFileInfo("sub", os.ModeDir)})
+	s.sys.InsertReadDirResult(`readdir "/rofs/sub"`, nil)
+
+	chg := &update.Change{Action: update.Mount, Entry: osutil.MountEntry{Name: "device", Dir: "/rofs/sub/target", Type: "type"}}
+	synth, err := chg.Perform()
+	c.Assert(err, IsNil)
+	c.Assert(synth, DeepEquals, []*update.Change{
+		{Action: update.Mount, Entry: osutil.MountEntry{
+			Name: "tmpfs", Dir: "/rofs", Type: "tmpfs",
+			Options: []string{"x-snapd.synthetic", "x-snapd.needed-by=/rofs/sub/target"}},
+		},
+		{Action: update.Mount, Entry: osutil.MountEntry{
+			Name: "/rofs/sub", Dir: "/rofs/sub",
+			Options: []string{"rbind", "x-snapd.synthetic", "x-snapd.needed-by=/rofs/sub/target", "x-snapd.detach"}},
+		},
+		{Action: update.Mount, Entry: osutil.MountEntry{
+			Name: "tmpfs", Dir: "/rofs/sub", Type: "tmpfs",
+			Options: []string{"x-snapd.synthetic", "x-snapd.needed-by=/rofs/sub/target"}},
+		},
+	})
+	calls := s.sys.Calls()
+	c.Assert(calls[len(calls)-1], Equals, `mount "device" "/rofs/sub/target" "type" 0 ""`)
+}
+
+// Change.Perform wants to mount a filesystem but the writable mimic doesn't make the parent writable.
+func (s *changeSuite) TestPerformFilesystemMountWithoutMountPointAndStubbornReadOnlyBase(c *C) {
+	s.sys.InsertFault(`lstat "/rofs/target"`, syscall.ENOENT)
+	s.sys.InsertFault(`mkdirat 3 "rofs" 0755`, syscall.EEXIST)
+	s.sys.InsertFault(`openat 3 "target" O_NOFOLLOW|O_CLOEXEC|O_DIRECTORY 0`, syscall.ENOENT)
+	s.sys.InsertFault(`mkdirat 4 "target" 0755`, syscall.EROFS) // always fails
+	s.sys.InsertReadDirResult(`readdir "/rofs"`, nil)
+	s.sys.InsertFault(`lstat "/tmp/.snap/rofs"`, syscall.ENOENT)
+	s.sys.InsertLstatResult(`lstat "/rofs"`, testutil.FileInfoDir)
+
+	chg := &update.Change{Action: update.Mount, Entry: osutil.MountEntry{Name: "device", Dir: "/rofs/target", Type: "type"}}
+	synth, err := chg.Perform()
+	// The same writable mimic is not attempted twice.
+	c.Assert(err, ErrorMatches, `cannot create path "/rofs/target": cannot operate on read-only filesystem at /rofs`)
+	c.Assert(synth, DeepEquals, []*update.Change{
+		{Action: update.Mount, Entry: osutil.MountEntry{
+			Name: "tmpfs", Dir: "/rofs", Type: "tmpfs",
+			Options: []string{"x-snapd.synthetic", "x-snapd.needed-by=/rofs/target"}},
+		},
+	})
+	c.Assert(s.sys.Calls(), Not(testutil.Contains), `mount "device" "/rofs/target" "type" 0 ""`)
+}
+
 // Change.Perform wants to mount a filesystem but there's a symlink in mount point.
 func (s *changeSuite) TestPerformFilesystemMountWithSymlinkInMountPoint(c *C) {
 	s.sys.InsertLstatResult(`lstat "/target"`, testutil.FileInfoSymlink)
@@ -562,6 +656,52 @@ func (s *changeSuite) TestPerformFilesystemDetch(c *C) {
 	c.Assert(synth, HasLen, 0)
 }
 
+// Change.Perform wants to unmount a layout and remove the mount point.
+func (s *changeSuite) TestPerformLayoutUnmount(c *C) {
+	chg := &update.Change{Action: update.Unmount, Entry: osutil.MountEntry{Name: "tmpfs", Dir: "/target", Type: "tmpfs", Options: []string{"x-snapd.origin=layout", "x-snapd.created"}}}
+	synth, err := chg.Perform()
+	c.Assert(err, IsNil)
+	c.Assert(s.sys.Calls(), DeepEquals, []string{
+		`unmount "/target" UMOUNT_NOFOLLOW`,
+		`remove "/target"`,
+	})
+	c.Assert(synth, HasLen, 0)
+}
+
+// Change.Perform wants to unmount a layout over a mount point that existed before.
+func (s *changeSuite) TestPerformLayoutUnmountPreExistingMountPoint(c *C) {
+	chg := &update.Change{Action: update.Unmount, Entry: osutil.MountEntry{Name: "tmpfs", Dir: "/target", Type: "tmpfs", Options: []string{"x-snapd.origin=layout"}}}
+	synth, err := chg.Perform()
+	c.Assert(err, IsNil)
+	// The directory was not created by snap-update-ns so it is left alone.
+	c.Assert(s.sys.Calls(), DeepEquals, []string{`unmount "/target" UMOUNT_NOFOLLOW`})
+	c.Assert(synth, HasLen, 0)
+}
+
+// Change.Perform wants to unmount a layout but the mount point cannot be removed.
+func (s *changeSuite) TestPerformLayoutUnmountBusyMountPoint(c *C) {
+	s.sys.InsertFault(`remove "/target"`, syscall.ENOTEMPTY)
+	chg := &update.Change{Action: update.Unmount, Entry: osutil.MountEntry{Name: "tmpfs", Dir: "/target", Type: "tmpfs", Options: []string{"x-snapd.origin=layout", "x-snapd.created"}}}
+	synth, err := chg.Perform()
+	// Removing the mount point is best-effort.
+	c.Assert(err, IsNil)
+	c.Assert(s.sys.Calls(), DeepEquals, []string{
+		`unmount "/target" UMOUNT_NOFOLLOW`,
+		`remove "/target"`,
+	})
+	c.Assert(synth, HasLen, 0)
+}
+
+// Change.Perform wants to unmount a layout but it fails, the mount point is left alone.
+func (s *changeSuite) TestPerformLayoutUnmountError(c *C) {
+	s.sys.InsertFault(`unmount "/target" UMOUNT_NOFOLLOW`, errTesting)
+	chg := &update.Change{Action: update.Unmount, Entry: osutil.MountEntry{Name: "tmpfs", Dir: "/target", Type: "tmpfs", Options: []string{"x-snapd.origin=layout", "x-snapd.created"}}}
+	synth, err := chg.Perform()
+	c.Assert(err, Equals, errTesting)
+	c.Assert(s.sys.Calls(), DeepEquals, []string{`unmount "/target" UMOUNT_NOFOLLOW`})
+	c.Assert(synth, HasLen, 0)
+}
+
 // Change.Perform wants to unmount a filesystem but it fails.
 func (s *changeSuite) TestPerformFilesystemUnmountError(c *C) {
 	s.sys.InsertFault(`unmount "/target" UMOUNT_NOFOLLOW`, errTesting)
@@ -657,6 +797,79 @@ func (s *changeSuite) TestPerformDirectoryBindMountWithError(c *C) {
 	})
 }
 
+// Change.Perform wants to bind mount a directory of the per-user mount profile.
+func (s *changeSuite) TestPerformUserDirectoryBindMount(c *C) {
+	s.sys.InsertLstatResult(`lstat "/source"`, testutil.FileInfoDir)
+	s.sys.InsertLstatResult(`lstat "/target"`, testutil.FileInfoDir)
+	s.sys.InsertFstatResult(`fstat 4 <ptr>`, syscall.Stat_t{Mode: syscall.S_IFDIR})
+	s.sys.InsertFstatResult(`fstat 5 <ptr>`, syscall.Stat_t{Mode: syscall.S_IFDIR})
+	chg := &update.Change{Action: update.Mount, Entry: osutil.MountEntry{Name: "/source", Dir: "/target", Options: []string{"bind", "x-snapd.uid=1000", "x-snapd.gid=1000"}}}
+	synth, err := chg.Perform()
+	c.Assert(err, IsNil)
+	c.Assert(synth, HasLen, 0)
+	c.Assert(s.sys.Calls(), DeepEquals, []string{
+		`lstat "/target"`,
+		`lstat "/source"`,
+		`open "/" O_NOFOLLOW|O_CLOEXEC|O_DIRECTORY|O_PATH 0`, // -> 3
+		`openat 3 "target" O_NOFOLLOW|O_CLOEXEC|O_PATH 0`,    // -> 4
+		`close 3`,
+		`fstat 4 <ptr>`,
+		`open "/" O_NOFOLLOW|O_CLOEXEC|O_DIRECTORY|O_PATH 0`, // -> 3
+		`openat 3 "source" O_NOFOLLOW|O_CLOEXEC|O_PATH 0`,    // -> 5
+		`close 3`,
+		`fstat 5 <ptr>`,
+		`mount "/proc/self/fd/5" "/proc/self/fd/4" "" MS_BIND ""`,
+		`close 5`,
+		`close 4`,
+	})
+}
+
+// Change.Perform wants to bind mount a directory of the per-user mount profile
+// but the mount point was replaced with a symbolic link.
+func (s *changeSuite) TestPerformUserDirectoryBindMountWithSymlinkMountPoint(c *C) {
+	s.sys.InsertLstatResult(`lstat "/source"`, testutil.FileInfoDir)
+	s.sys.InsertLstatResult(`lstat "/target"`, testutil.FileInfoDir)
+	s.sys.InsertFstatResult(`fstat 4 <ptr>`, syscall.Stat_t{Mode: syscall.S_IFLNK})
+	chg := &update.Change{Action: update.Mount, Entry: osutil.MountEntry{Name: "/source", Dir: "/target", Options: []string{"bind", "x-snapd.uid=1000", "x-snapd.gid=1000"}}}
+	synth, err := chg.Perform()
+	c.Assert(err, ErrorMatches, `cannot open "/target": symbolic link in the way`)
+	c.Assert(synth, HasLen, 0)
+	c.Assert(s.sys.Calls(), DeepEquals, []string{
+		`lstat "/target"`,
+		`lstat "/source"`,
+		`open "/" O_NOFOLLOW|O_CLOEXEC|O_DIRECTORY|O_PATH 0`, // -> 3
+		`openat 3 "target" O_NOFOLLOW|O_CLOEXEC|O_PATH 0`,    // -> 4
+		`close 3`,
+		`fstat 4 <ptr>`,
+		`close 4`,
+	})
+}
+
+// Change.Perform wants to bind mount a directory of the per-user mount profile
+// but a directory leading to the source was replaced with a symbolic link.
+func (s *changeSuite) TestPerformUserDirectoryBindMountWithSymlinkInSource(c *C) {
+	s.sys.InsertLstatResult(`lstat "/home/source"`, testutil.FileInfoDir)
+	s.sys.InsertLstatResult(`lstat "/target"`, testutil.FileInfoDir)
+	s.sys.InsertFstatResult(`fstat 4 <ptr>`, syscall.Stat_t{Mode: syscall.S_IFDIR})
+	s.sys.InsertFault(`openat 3 "home" O_NOFOLLOW|O_CLOEXEC|O_DIRECTORY|O_PATH 0`, syscall.ENOTDIR)
+	chg := &update.Change{Action: update.Mount, Entry: osutil.MountEntry{Name: "/home/source", Dir: "/target", Options: []string{"bind", "x-snapd.uid=1000", "x-snapd.gid=1000"}}}
+	synth, err := chg.Perform()
+	c.Assert(err, ErrorMatches, `cannot open path segment "home" \(got up to "/"\): not a directory`)
+	c.Assert(synth, HasLen, 0)
+	c.Assert(s.sys.Calls(), DeepEquals, []string{
+		`lstat "/target"`,
+		`lstat "/home/source"`,
+		`open "/" O_NOFOLLOW|O_CLOEXEC|O_DIRECTORY|O_PATH 0`, // -> 3
+		`openat 3 "target" O_NOFOLLOW|O_CLOEXEC|O_PATH 0`,    // -> 4
+		`close 3`,
+		`fstat 4 <ptr>`,
+		`open "/" O_NOFOLLOW|O_CLOEXEC|O_DIRECTORY|O_PATH 0`,        // -> 3
+		`openat 3 "home" O_NOFOLLOW|O_CLOEXEC|O_DIRECTORY|O_PATH 0`, // -> ENOTDIR
+		`close 3`,
+		`close 4`,
+	})
+}
+
 // Change.Perform wants to bind mount a directory but the mount point isn't there.
 func (s *changeSuite) TestPerformDirectoryBindMountWithoutMountPoint(c *C) {
 	s.sys.InsertLstatResult(`lstat "/source"`, testutil.FileInfoDir)
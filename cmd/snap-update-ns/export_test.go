@@ -20,7 +20,9 @@
 package main
 
 import (
+	"bytes"
 	"os"
+	"os/user"
 	"syscall"
 
 	. "gopkg.in/check.v1"
@@ -45,6 +47,11 @@ var (
 
 	// main
 	ComputeAndSaveChanges = computeAndSaveChanges
+	ShowChanges           = showChanges
+
+	// user
+	ApplyUserFstab         = applyUserFstab
+	ExpandUserMountProfile = expandUserMountProfile
 )
 
 // SystemCalls encapsulates various system interactions performed by this module.
@@ -153,3 +160,26 @@ func MockReadlink(fn func(string) (string, error)) (restore func()) {
 		osReadlink = old
 	}
 }
+
+func MockUser(uid, gid int, lookupId func(string) (*user.User, error)) (restore func()) {
+	oldGetuid := osGetuid
+	oldGetgid := osGetgid
+	oldLookupId := userLookupId
+	osGetuid = func() int { return uid }
+	osGetgid = func() int { return gid }
+	userLookupId = lookupId
+	return func() {
+		osGetuid = oldGetuid
+		osGetgid = oldGetgid
+		userLookupId = oldLookupId
+	}
+}
+
+func MockStdout() (buf *bytes.Buffer, restore func()) {
+	old := stdout
+	buf = &bytes.Buffer{}
+	stdout = buf
+	return buf, func() {
+		stdout = old
+	}
+}
@@ -21,7 +21,9 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"text/tabwriter"
 
 	"github.com/jessevdk/go-flags"
 
@@ -31,8 +33,12 @@ import (
 	"github.com/snapcore/snapd/osutil"
 )
 
+var stdout io.Writer = os.Stdout
+
 var opts struct {
 	FromSnapConfine bool `long:"from-snap-confine"`
+	UserMounts      bool `long:"user-mounts"`
+	ShowChanges     bool `long:"show-changes"`
 	Positionals     struct {
 		SnapName string `positional-arg-name:"SNAP_NAME" required:"yes"`
 	} `positional-args:"true"`
@@ -82,6 +88,21 @@ func run() error {
 
 	snapName := opts.Positionals.SnapName
 
+	// The per-user mount namespace is a private copy of the per-snap mount
+	// namespace, made by snap-confine for the application it is about to
+	// start. Since snap-confine holds the per-snap lock at this time and no
+	// other process can be in the per-user namespace yet there is no need
+	// to either lock or freeze anything.
+	if opts.UserMounts {
+		return applyUserFstab(snapName)
+	}
+
+	// Showing the changes only inspects the mount profiles, it does not touch
+	// the mount namespace in any way.
+	if opts.ShowChanges {
+		return showChanges(snapName)
+	}
+
 	// Lock the mount namespace so that any concurrently attempted invocations
 	// of snap-confine are synchronized and will see consistent state.
 	lock, err := mount.OpenLock(snapName)
@@ -124,24 +145,84 @@ func run() error {
 	return computeAndSaveChanges(snapName)
 }
 
-func computeAndSaveChanges(snapName string) error {
-	// Read the desired and current mount profiles. Note that missing files
-	// count as empty profiles so that we can gracefully handle a mount
-	// interface connection/disconnection.
+// loadProfiles loads the desired and the current mount profiles of the given snap.
+//
+// Note that missing files count as empty profiles so that we can gracefully
+// handle a mount interface connection/disconnection.
+func loadProfiles(snapName string) (desired, current *osutil.MountProfile, err error) {
 	desiredProfilePath := fmt.Sprintf("%s/snap.%s.fstab", dirs.SnapMountPolicyDir, snapName)
-	desired, err := osutil.LoadMountProfile(desiredProfilePath)
+	desired, err = osutil.LoadMountProfile(desiredProfilePath)
 	if err != nil {
-		return fmt.Errorf("cannot load desired mount profile of snap %q: %s", snapName, err)
+		return nil, nil, fmt.Errorf("cannot load desired mount profile of snap %q: %s", snapName, err)
 	}
-	debugShowProfile(desired, "desired mount profile")
 
 	currentProfilePath := fmt.Sprintf("%s/snap.%s.fstab", dirs.SnapRunNsDir, snapName)
-	currentBefore, err := osutil.LoadMountProfile(currentProfilePath)
+	current, err = osutil.LoadMountProfile(currentProfilePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot load current mount profile of snap %q: %s", snapName, err)
+	}
+	return desired, current, nil
+}
+
+func computeAndSaveChanges(snapName string) error {
+	// Read the desired and current mount profiles.
+	desired, currentBefore, err := loadProfiles(snapName)
 	if err != nil {
-		return fmt.Errorf("cannot load current mount profile of snap %q: %s", snapName, err)
+		return err
 	}
+	debugShowProfile(desired, "desired mount profile")
 	debugShowProfile(currentBefore, "current mount profile (before applying changes)")
 
+	currentAfter, err := applyProfile(snapName, currentBefore, desired)
+	if err != nil {
+		return err
+	}
+
+	currentProfilePath := fmt.Sprintf("%s/snap.%s.fstab", dirs.SnapRunNsDir, snapName)
+	logger.Debugf("saving current mount profile of snap %q", snapName)
+	if err := currentAfter.Save(currentProfilePath); err != nil {
+		return fmt.Errorf("cannot save current mount profile of snap %q: %s", snapName, err)
+	}
+	return nil
+}
+
+// showChanges prints the current and desired mount profiles of the given snap
+// along with the changes that would be needed to go from one to the other.
+func showChanges(snapName string) error {
+	desired, current, err := loadProfiles(snapName)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(stdout, 0, 8, 1, ' ', 0)
+	defer w.Flush()
+	showProfile(w, current, "Current mount profile:")
+	showProfile(w, desired, "Desired mount profile:")
+	fmt.Fprintln(w, "Needed changes:")
+	changes := NeededChanges(current, desired)
+	if len(changes) == 0 {
+		fmt.Fprintln(w, "  (none)")
+	}
+	for _, change := range changes {
+		fmt.Fprintf(w, "  %s\t%s\n", change.Action, change.Entry)
+	}
+	return nil
+}
+
+func showProfile(w io.Writer, profile *osutil.MountProfile, header string) {
+	fmt.Fprintln(w, header)
+	if len(profile.Entries) == 0 {
+		fmt.Fprintln(w, "  (none)")
+	}
+	for _, entry := range profile.Entries {
+		fmt.Fprintf(w, "  %s\n", entry)
+	}
+}
+
+// applyProfile changes the mount namespace from the current to the desired profile.
+//
+// The returned profile contains only the changes that were made, including
+// any synthetic changes that were needed along the way.
+func applyProfile(snapName string, currentBefore, desired *osutil.MountProfile) (*osutil.MountProfile, error) {
 	// Compute the needed changes and perform each change if needed, collecting
 	// those that we managed to perform or that were performed already.
 	changesNeeded := NeededChanges(currentBefore, desired)
@@ -163,7 +244,7 @@ func computeAndSaveChanges(snapName string) error {
 			// NOTE: we may have done something even if Perform itself has failed.
 			// We need to collect synthesized changes and store them.
 			if change.Entry.XSnapdOrigin() == "layout" {
-				return err
+				return nil, err
 			}
 			logger.Noticef("cannot change mount namespace of snap %q according to change %s: %s", snapName, change, err)
 			continue
@@ -181,12 +262,7 @@ func computeAndSaveChanges(snapName string) error {
 		}
 	}
 	debugShowProfile(&currentAfter, "current mount profile (after applying changes)")
-
-	logger.Debugf("saving current mount profile of snap %q", snapName)
-	if err := currentAfter.Save(currentProfilePath); err != nil {
-		return fmt.Errorf("cannot save current mount profile of snap %q: %s", snapName, err)
-	}
-	return nil
+	return &currentAfter, nil
 }
 
 func debugShowProfile(profile *osutil.MountProfile, header string) {
@@ -255,3 +255,55 @@ func (s *mainSuite) TestApplyingLayoutChanges(c *C) {
 
 	c.Check(currentProfilePath, testutil.FileEquals, "")
 }
+
+func (s *mainSuite) TestShowChanges(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("/")
+
+	restore := update.MockChangePerform(func(chg *update.Change) ([]*update.Change, error) {
+		c.Fatalf("unexpected change %s", chg)
+		return nil, nil
+	})
+	defer restore()
+	stdout, restore := update.MockStdout()
+	defer restore()
+
+	const snapName = "foo"
+	desiredProfilePath := fmt.Sprintf("%s/snap.%s.fstab", dirs.SnapMountPolicyDir, snapName)
+	c.Assert(os.MkdirAll(filepath.Dir(desiredProfilePath), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(desiredProfilePath, []byte("/snap/foo/42/usr/share/foo /usr/share/foo none rbind,rw,x-snapd.origin=layout 0 0\n"), 0644), IsNil)
+
+	currentProfilePath := fmt.Sprintf("%s/snap.%s.fstab", dirs.SnapRunNsDir, snapName)
+	c.Assert(os.MkdirAll(filepath.Dir(currentProfilePath), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(currentProfilePath, []byte("/snap/foo/41/usr/share/foo /usr/share/foo none rbind,rw,x-snapd.origin=layout 0 0\n"), 0644), IsNil)
+
+	c.Assert(update.ShowChanges(snapName), IsNil)
+	c.Check(stdout.String(), Equals, `Current mount profile:
+  /snap/foo/41/usr/share/foo /usr/share/foo none rbind,rw,x-snapd.origin=layout 0 0
+Desired mount profile:
+  /snap/foo/42/usr/share/foo /usr/share/foo none rbind,rw,x-snapd.origin=layout 0 0
+Needed changes:
+  unmount /snap/foo/41/usr/share/foo /usr/share/foo none rbind,rw,x-snapd.origin=layout 0 0
+  mount   /snap/foo/42/usr/share/foo /usr/share/foo none rbind,rw,x-snapd.origin=layout 0 0
+`)
+
+	// The current profile is left untouched.
+	c.Check(currentProfilePath, testutil.FileEquals, "/snap/foo/41/usr/share/foo /usr/share/foo none rbind,rw,x-snapd.origin=layout 0 0\n")
+}
+
+func (s *mainSuite) TestShowChangesNoProfiles(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("/")
+
+	stdout, restore := update.MockStdout()
+	defer restore()
+
+	c.Assert(update.ShowChanges("foo"), IsNil)
+	c.Check(stdout.String(), Equals, `Current mount profile:
+  (none)
+Desired mount profile:
+  (none)
+Needed changes:
+  (none)
+`)
+}
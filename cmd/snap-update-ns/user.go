@@ -0,0 +1,127 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+)
+
+var (
+	osGetuid     = os.Getuid
+	osGetgid     = os.Getgid
+	userLookupId = user.LookupId
+)
+
+// applyUserFstab applies the per-user mount profile of the given snap.
+//
+// The per-user mount namespace is constructed by snap-confine each time an
+// application of the snap is started and is never preserved. For that reason
+// there is no current profile to compare against, all the desired entries are
+// simply mounted.
+//
+// The mount profile may refer to $XDG_RUNTIME_DIR and $HOME. Both are expanded
+// for the user invoking the snap, as identified by the real user ID.
+func applyUserFstab(snapName string) error {
+	desiredProfilePath := fmt.Sprintf("%s/snap.%s.user-fstab", dirs.SnapMountPolicyDir, snapName)
+	desired, err := osutil.LoadMountProfile(desiredProfilePath)
+	if err != nil {
+		return fmt.Errorf("cannot load desired user mount profile of snap %q: %s", snapName, err)
+	}
+
+	uid, gid := osGetuid(), osGetgid()
+	if err := expandUserMountProfile(desired, uid, gid); err != nil {
+		return fmt.Errorf("cannot expand desired user mount profile of snap %q: %s", snapName, err)
+	}
+	debugShowProfile(desired, "desired user mount profile")
+
+	_, err = applyProfile(snapName, &osutil.MountProfile{}, desired)
+	return err
+}
+
+// expandUserMountProfile expands $XDG_RUNTIME_DIR and $HOME in the given mount profile.
+//
+// Each entry is also tagged with the user and group that should own any
+// directories, files or symbolic links that need to be created and tmpfs
+// entries are mounted so that they are owned by the user.
+func expandUserMountProfile(profile *osutil.MountProfile, uid, gid int) error {
+	if len(profile.Entries) == 0 {
+		return nil
+	}
+	u, err := userLookupId(strconv.Itoa(uid))
+	if err != nil {
+		return fmt.Errorf("cannot look up user %d: %s", uid, err)
+	}
+	if !filepath.IsAbs(u.HomeDir) {
+		return fmt.Errorf("cannot use home directory %q of user %d: not absolute", u.HomeDir, uid)
+	}
+	vars := map[string]string{
+		"HOME":            filepath.Clean(u.HomeDir),
+		"XDG_RUNTIME_DIR": fmt.Sprintf("%s/%d", dirs.XdgRuntimeDirBase, uid),
+	}
+
+	var unknown string
+	expand := func(s string) string {
+		return os.Expand(s, func(name string) string {
+			if value, ok := vars[name]; ok {
+				return value
+			}
+			unknown = name
+			return ""
+		})
+	}
+
+	for i := range profile.Entries {
+		entry := &profile.Entries[i]
+		entry.Name = expand(entry.Name)
+		entry.Dir = expand(entry.Dir)
+		if target, ok := entry.OptStr("x-snapd.symlink"); ok {
+			for j, opt := range entry.Options {
+				if opt == osutil.XSnapdSymlink(target) {
+					entry.Options[j] = osutil.XSnapdSymlink(expand(target))
+				}
+			}
+		}
+		if unknown != "" {
+			return fmt.Errorf("cannot expand mount entry (%s): unknown variable %q", entry, "$"+unknown)
+		}
+		if !filepath.IsAbs(entry.Dir) {
+			return fmt.Errorf("cannot use mount entry (%s): mount point is not absolute", entry)
+		}
+
+		entry.Options = append(entry.Options, fmt.Sprintf("x-snapd.uid=%d", uid), fmt.Sprintf("x-snapd.gid=%d", gid))
+		if entry.Type == "tmpfs" {
+			mode, err := entry.XSnapdMode()
+			if err != nil {
+				return err
+			}
+			entry.Options = append(entry.Options, fmt.Sprintf("uid=%d", uid), fmt.Sprintf("gid=%d", gid), fmt.Sprintf("mode=%#o", mode))
+		}
+		logger.Debugf("expanded user mount entry: %s", entry)
+	}
+	return nil
+}
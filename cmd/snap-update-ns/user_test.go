@@ -0,0 +1,163 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	. "gopkg.in/check.v1"
+
+	update "github.com/snapcore/snapd/cmd/snap-update-ns"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+)
+
+type userSuite struct{}
+
+var _ = Suite(&userSuite{})
+
+func (s *userSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+}
+
+func (s *userSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("/")
+}
+
+func mockUserLookup(c *C) func(string) (*user.User, error) {
+	return func(uid string) (*user.User, error) {
+		c.Assert(uid, Equals, "1000")
+		return &user.User{Uid: "1000", Gid: "1000", Username: "joe", HomeDir: "/home/joe"}, nil
+	}
+}
+
+func (s *userSuite) TestExpandUserMountProfile(c *C) {
+	restore := update.MockUser(1000, 1000, mockUserLookup(c))
+	defer restore()
+
+	profile, err := osutil.ReadMountProfile(strings.NewReader(`$XDG_RUNTIME_DIR/doc/by-app/snap.foo $XDG_RUNTIME_DIR/doc none bind,rw 0 0
+$HOME/snap/foo/42/.config/foo $HOME/.config/foo none rbind,rw,x-snapd.origin=layout 0 0
+tmpfs $HOME/.cache/foo tmpfs x-snapd.origin=layout 0 0
+none $HOME/.foorc none x-snapd.kind=symlink,x-snapd.symlink=$HOME/snap/foo/common/foorc,x-snapd.origin=layout 0 0
+`))
+	c.Assert(err, IsNil)
+	c.Assert(update.ExpandUserMountProfile(profile, 1000, 1000), IsNil)
+
+	runDir := dirs.XdgRuntimeDirBase + "/1000"
+	c.Check(profile.Entries, DeepEquals, []osutil.MountEntry{
+		{Name: runDir + "/doc/by-app/snap.foo", Dir: runDir + "/doc", Type: "none",
+			Options: []string{"bind", "rw", "x-snapd.uid=1000", "x-snapd.gid=1000"}},
+		{Name: "/home/joe/snap/foo/42/.config/foo", Dir: "/home/joe/.config/foo", Type: "none",
+			Options: []string{"rbind", "rw", "x-snapd.origin=layout", "x-snapd.uid=1000", "x-snapd.gid=1000"}},
+		{Name: "tmpfs", Dir: "/home/joe/.cache/foo", Type: "tmpfs",
+			Options: []string{"x-snapd.origin=layout", "x-snapd.uid=1000", "x-snapd.gid=1000", "uid=1000", "gid=1000", "mode=0755"}},
+		{Name: "none", Dir: "/home/joe/.foorc", Type: "none",
+			Options: []string{"x-snapd.kind=symlink", "x-snapd.symlink=/home/joe/snap/foo/common/foorc", "x-snapd.origin=layout", "x-snapd.uid=1000", "x-snapd.gid=1000"}},
+	})
+}
+
+func (s *userSuite) TestExpandUserMountProfileUnknownVariable(c *C) {
+	restore := update.MockUser(1000, 1000, mockUserLookup(c))
+	defer restore()
+
+	profile, err := osutil.ReadMountProfile(strings.NewReader("$SNAP_DATA/foo $HOME/foo none bind,rw 0 0\n"))
+	c.Assert(err, IsNil)
+	err = update.ExpandUserMountProfile(profile, 1000, 1000)
+	c.Assert(err, ErrorMatches, `cannot expand mount entry \(/foo /home/joe/foo none bind,rw 0 0\): unknown variable "\$SNAP_DATA"`)
+}
+
+func (s *userSuite) TestExpandUserMountProfileUserLookupError(c *C) {
+	restore := update.MockUser(1000, 1000, func(uid string) (*user.User, error) {
+		return nil, fmt.Errorf("no such user")
+	})
+	defer restore()
+
+	profile, err := osutil.ReadMountProfile(strings.NewReader("$HOME/snap/foo/42/bar $HOME/bar none bind,rw 0 0\n"))
+	c.Assert(err, IsNil)
+	err = update.ExpandUserMountProfile(profile, 1000, 1000)
+	c.Assert(err, ErrorMatches, `cannot look up user 1000: no such user`)
+
+	// An empty profile does not require looking up the user.
+	c.Assert(update.ExpandUserMountProfile(&osutil.MountProfile{}, 1000, 1000), IsNil)
+}
+
+func (s *userSuite) TestApplyUserFstab(c *C) {
+	restore := update.MockUser(1000, 1000, mockUserLookup(c))
+	defer restore()
+
+	const snapName = "foo"
+	desiredProfilePath := fmt.Sprintf("%s/snap.%s.user-fstab", dirs.SnapMountPolicyDir, snapName)
+	c.Assert(os.MkdirAll(filepath.Dir(desiredProfilePath), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(desiredProfilePath, []byte("$HOME/snap/foo/42/.config/foo $HOME/.config/foo none rbind,rw,x-snapd.origin=layout 0 0\n"), 0644), IsNil)
+
+	var changes []*update.Change
+	restore = update.MockChangePerform(func(chg *update.Change) ([]*update.Change, error) {
+		changes = append(changes, chg)
+		return nil, nil
+	})
+	defer restore()
+
+	c.Assert(update.ApplyUserFstab(snapName), IsNil)
+	c.Assert(changes, DeepEquals, []*update.Change{{
+		Action: update.Mount, Entry: osutil.MountEntry{
+			Name: "/home/joe/snap/foo/42/.config/foo", Dir: "/home/joe/.config/foo", Type: "none",
+			Options: []string{"rbind", "rw", "x-snapd.origin=layout", "x-snapd.uid=1000", "x-snapd.gid=1000"},
+		},
+	}})
+
+	// The per-user mount namespace is not preserved so nothing is saved.
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapRunNsDir, "snap.foo.user-fstab")), Equals, false)
+}
+
+func (s *userSuite) TestApplyUserFstabLayoutError(c *C) {
+	restore := update.MockUser(1000, 1000, mockUserLookup(c))
+	defer restore()
+
+	const snapName = "foo"
+	desiredProfilePath := fmt.Sprintf("%s/snap.%s.user-fstab", dirs.SnapMountPolicyDir, snapName)
+	c.Assert(os.MkdirAll(filepath.Dir(desiredProfilePath), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(desiredProfilePath, []byte("tmpfs $HOME/.cache/foo tmpfs x-snapd.origin=layout 0 0\n"), 0644), IsNil)
+
+	restore = update.MockChangePerform(func(chg *update.Change) ([]*update.Change, error) {
+		return nil, fmt.Errorf("testing")
+	})
+	defer restore()
+
+	c.Assert(update.ApplyUserFstab(snapName), ErrorMatches, "testing")
+}
+
+func (s *userSuite) TestApplyUserFstabMissingProfile(c *C) {
+	restore := update.MockUser(1000, 1000, mockUserLookup(c))
+	defer restore()
+
+	restore = update.MockChangePerform(func(chg *update.Change) ([]*update.Change, error) {
+		c.Fatalf("unexpected change %s", chg)
+		return nil, nil
+	})
+	defer restore()
+
+	// Missing profiles are treated as empty.
+	c.Assert(update.ApplyUserFstab("foo"), IsNil)
+}
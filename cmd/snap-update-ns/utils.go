@@ -385,6 +385,65 @@ func secureMksymlinkAll(name string, perm os.FileMode, uid sys.UserID, gid sys.G
 	return err
 }
 
+// secureOpenPath opens the given absolute path without following any
+// symbolic links, neither along the way nor in the final segment.
+//
+// The returned file descriptor is opened with O_PATH and is meant to be used,
+// through /proc/self/fd, with system calls that only accept paths. This way
+// the location cannot be swapped for a symbolic link between the time it was
+// checked and the time it is used. The caller is responsible for closing it.
+func secureOpenPath(name string) (int, error) {
+	logger.Debugf("secure-open-path %q", name)
+
+	// Only support absolute paths to avoid bugs in snap-confine when
+	// called from anywhere.
+	if !filepath.IsAbs(name) {
+		return -1, fmt.Errorf("cannot open relative path: %q", name)
+	}
+
+	// Split the path into segments.
+	segments, err := splitIntoSegments(name)
+	if err != nil {
+		return -1, err
+	}
+
+	const openFlags = syscall.O_NOFOLLOW | syscall.O_CLOEXEC | sys.O_PATH
+
+	// Open the root directory and start there.
+	fd, err := sysOpen("/", openFlags|syscall.O_DIRECTORY, 0)
+	if err != nil {
+		return -1, fmt.Errorf("cannot open root directory: %v", err)
+	}
+	for i, segment := range segments {
+		flags := openFlags
+		if i < len(segments)-1 {
+			// All but the last segment must be directories.
+			flags |= syscall.O_DIRECTORY
+		}
+		newFd, err := sysOpenat(fd, segment, flags, 0)
+		sysClose(fd)
+		if err != nil {
+			return -1, fmt.Errorf("cannot open path segment %q (got up to %q): %v", segment,
+				"/"+strings.Join(segments[:i], "/"), err)
+		}
+		fd = newFd
+	}
+
+	// With O_NOFOLLOW and O_PATH a symbolic link in the final segment is
+	// opened rather than followed, reject it here.
+	var statBuf syscall.Stat_t
+	if err := sysFstat(fd, &statBuf); err != nil {
+		sysClose(fd)
+		return -1, fmt.Errorf("cannot inspect %q: %v", name, err)
+	}
+	if statBuf.Mode&syscall.S_IFMT == syscall.S_IFLNK {
+		sysClose(fd)
+		return -1, fmt.Errorf("cannot open %q: symbolic link in the way", name)
+	}
+	logger.Debugf("secure-open-path %q -> %d", name, fd)
+	return fd, nil
+}
+
 // planWritableMimic plans how to transform a given directory from read-only to writable.
 //
 // The algorithm is designed to be universally reversible so that it can be
@@ -249,7 +249,7 @@ func init() {
 
 func autoAddUsers() error {
 	cmd := cmdCreateUser{
-		Known: true, Sudoer: true,
+		Known: true, Sudoer: true, automatic: true,
 	}
 	return cmd.Execute(nil)
 }
@@ -65,7 +65,7 @@ func (s *SnapSuite) TestAutoImportAssertsHappy(c *C) {
 			c.Check(r.URL.Path, Equals, "/v2/create-user")
 			postData, err := ioutil.ReadAll(r.Body)
 			c.Assert(err, IsNil)
-			c.Check(string(postData), Equals, `{"sudoer":true,"known":true}`)
+			c.Check(string(postData), Equals, `{"sudoer":true,"known":true,"automatic":true}`)
 
 			fmt.Fprintln(w, `{"type": "sync", "result": [{"username": "foo"}]}`)
 			n++
@@ -242,7 +242,7 @@ func (s *SnapSuite) TestAutoImportFromSpoolHappy(c *C) {
 			c.Check(r.URL.Path, Equals, "/v2/create-user")
 			postData, err := ioutil.ReadAll(r.Body)
 			c.Assert(err, IsNil)
-			c.Check(string(postData), Equals, `{"sudoer":true,"known":true}`)
+			c.Check(string(postData), Equals, `{"sudoer":true,"known":true,"automatic":true}`)
 
 			fmt.Fprintln(w, `{"type": "sync", "result": [{"username": "foo"}]}`)
 			n++
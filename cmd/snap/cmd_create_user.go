@@ -46,6 +46,10 @@ type cmdCreateUser struct {
 	Sudoer       bool `long:"sudoer"`
 	Known        bool `long:"known"`
 	ForceManaged bool `long:"force-managed"`
+
+	// automatic is set when users are created without being asked
+	// for, e.g. by auto-import
+	automatic bool
 }
 
 func init() {
@@ -76,6 +80,7 @@ func (x *cmdCreateUser) Execute(args []string) error {
 		Sudoer:       x.Sudoer,
 		Known:        x.Known,
 		ForceManaged: x.ForceManaged,
+		Automatic:    x.automatic,
 	}
 
 	var results []*client.CreateUserResult
@@ -51,6 +51,9 @@ Nested values may be retrieved via a dotted path:
 
     $ snap get snap-name author.name
     frank
+
+The --history option lists the recorded changes to the configuration of
+the snap, which can be restored with 'snap set --revert-to=<id>'.
 `)
 
 type cmdGet struct {
@@ -59,18 +62,22 @@ type cmdGet struct {
 		Keys []string
 	} `positional-args:"yes"`
 
+	timeMixin
+
 	Typed    bool `short:"t"`
 	Document bool `short:"d"`
 	List     bool `short:"l"`
+	History  bool `long:"history"`
 }
 
 func init() {
 	addCommand("get", shortGetHelp, longGetHelp, func() flags.Commander { return &cmdGet{} },
-		map[string]string{
-			"d": i18n.G("Always return document, even with single key"),
-			"l": i18n.G("Always return list, even with single key"),
-			"t": i18n.G("Strict typing with nulls and quoted strings"),
-		}, []argDesc{
+		timeDescs.also(map[string]string{
+			"d":       i18n.G("Always return document, even with single key"),
+			"l":       i18n.G("Always return list, even with single key"),
+			"t":       i18n.G("Strict typing with nulls and quoted strings"),
+			"history": i18n.G("Show the history of configuration changes"),
+		}), []argDesc{
 			{
 				name: "<snap>",
 				// TRANSLATORS: This should probably not start with a lowercase letter.
@@ -245,6 +252,16 @@ func (x *cmdGet) Execute(args []string) error {
 	snapName := string(x.Positional.Snap)
 	confKeys := x.Positional.Keys
 
+	if x.History {
+		if x.Document || x.Typed || x.List {
+			return fmt.Errorf("cannot use --history with -d, -t or -l")
+		}
+		if len(confKeys) > 0 {
+			return fmt.Errorf("cannot use --history with configuration keys")
+		}
+		return x.outputHistory(snapName)
+	}
+
 	cli := Client()
 	conf, err := cli.Conf(snapName, confKeys)
 	if err != nil {
@@ -260,3 +277,59 @@ func (x *cmdGet) Execute(args []string) error {
 		return x.outputDefault(conf, snapName, confKeys)
 	}
 }
+
+// outputHistory will be used when the user requested the history of
+// configuration changes via the "--history" commandline switch.
+func (x *cmdGet) outputHistory(snapName string) error {
+	history, err := Client().ConfHistory(snapName)
+	if err != nil {
+		return err
+	}
+	if len(history) == 0 {
+		return fmt.Errorf(i18n.G("snap %q has no configuration history"), snapName)
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+
+	fmt.Fprintf(w, i18n.G("ID\tTime\tUser\tChange\tValues\n"))
+	for _, entry := range history {
+		user := entry.User
+		if user == "" {
+			user = "-"
+		}
+		chg := entry.Change
+		if chg == "" {
+			chg = "-"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", entry.ID, x.fmtTime(entry.Time), user, chg, fmtHistoryValues(entry.Values))
+	}
+	return nil
+}
+
+func fmtHistoryValues(values map[string]interface{}) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		switch v := values[k].(type) {
+		case nil:
+			// an unset option
+			pairs[i] = k + "!"
+		case string:
+			pairs[i] = k + "=" + v
+		default:
+			b, err := json.Marshal(v)
+			if err != nil {
+				pairs[i] = fmt.Sprintf("%s=%v", k, v)
+				continue
+			}
+			pairs[i] = k + "=" + string(b)
+		}
+	}
+	return strings.Join(pairs, " ")
+}
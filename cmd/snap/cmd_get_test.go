@@ -224,3 +224,37 @@ func (s *SnapSuite) mockGetEmptyConfigServer(c *C) {
 		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": {}}`)
 	})
 }
+
+var getHistoryTests = []getCmdArgs{{
+	args: "get --history --abs-time snapname",
+	stdout: "ID   Time                  User  Change  Values\n" +
+		"1    2018-03-01T10:00:00Z  root  7       bar=100 foo={\"key1\":\"value1\"}\n" +
+		"2    2018-03-02T10:00:00Z  -     -       bar! name=frank\n",
+}, {
+	args:  "get --history -d snapname",
+	error: "cannot use --history with -d, -t or -l",
+}, {
+	args:  "get --history snapname test-key1",
+	error: "cannot use --history with configuration keys",
+}}
+
+func (s *SnapSuite) TestSnapGetHistory(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/v2/snaps/snapname/conf/history")
+		c.Check(r.Method, Equals, "GET")
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": [
+{"id": 1, "time": "2018-03-01T10:00:00Z", "change": "7", "user": "root", "values": {"foo": {"key1": "value1"}, "bar": 100}},
+{"id": 2, "time": "2018-03-02T10:00:00Z", "values": {"name": "frank", "bar": null}}
+]}`)
+	})
+	s.runTests(getHistoryTests, c)
+}
+
+func (s *SnapSuite) TestSnapGetHistoryEmpty(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/v2/snaps/snapname/conf/history")
+		fmt.Fprintln(w, `{"type":"sync", "status-code": 200, "result": []}`)
+	})
+	_, err := snapset.Parser().ParseArgs([]string{"get", "--history", "snapname"})
+	c.Check(err, ErrorMatches, `snap "snapname" has no configuration history`)
+}
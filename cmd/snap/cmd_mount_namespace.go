@@ -0,0 +1,72 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/osutil"
+)
+
+type cmdMountNamespace struct {
+	Positional struct {
+		Snap installedSnapName `positional-arg-name:"<snap>"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var shortMountNamespaceHelp = i18n.G("Show the mount profiles of a snap")
+var longMountNamespaceHelp = i18n.G(`
+The mount-namespace command shows the current and the desired mount
+profiles of the given snap, along with the mount changes that are needed
+to bring the mount namespace of the snap from one to the other.
+`)
+
+func init() {
+	addDebugCommand("mount-namespace", shortMountNamespaceHelp, longMountNamespaceHelp, func() flags.Commander {
+		return &cmdMountNamespace{}
+	})
+}
+
+func (x *cmdMountNamespace) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	snapUpdateNS := filepath.Join(dirs.DistroLibExecDir, "snap-update-ns")
+	// if we re-exec, we must use snap-update-ns from the core snap
+	// as well, it is the one that is going to apply the changes.
+	if isReexeced() {
+		snapUpdateNS = filepath.Join(dirs.SnapMountDir, "core/current", dirs.CoreLibExecDir, "snap-update-ns")
+	}
+	if !osutil.FileExists(snapUpdateNS) {
+		return fmt.Errorf(i18n.G("missing snap-update-ns: try updating your snapd package"))
+	}
+
+	cmd := exec.Command(snapUpdateNS, "--show-changes", string(x.Positional.Snap))
+	cmd.Stdout = Stdout
+	cmd.Stderr = Stderr
+	return cmd.Run()
+}
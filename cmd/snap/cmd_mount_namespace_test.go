@@ -0,0 +1,58 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func (s *SnapSuite) TestMountNamespace(c *C) {
+	c.Assert(os.MkdirAll(dirs.DistroLibExecDir, 0755), IsNil)
+	cmd := testutil.MockCommand(c, filepath.Join(dirs.DistroLibExecDir, "snap-update-ns"), `
+echo "Current mount profile:"
+echo "  (none)"
+`)
+	defer cmd.Restore()
+
+	_, err := snap.Parser().ParseArgs([]string{"debug", "mount-namespace", "foo"})
+	c.Assert(err, IsNil)
+	c.Check(cmd.Calls(), DeepEquals, [][]string{
+		{"snap-update-ns", "--show-changes", "foo"},
+	})
+	c.Check(s.Stdout(), Equals, "Current mount profile:\n  (none)\n")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestMountNamespaceMissingSnapUpdateNS(c *C) {
+	_, err := snap.Parser().ParseArgs([]string{"debug", "mount-namespace", "foo"})
+	c.Assert(err, ErrorMatches, "missing snap-update-ns: try updating your snapd package")
+}
+
+func (s *SnapSuite) TestMountNamespaceExtraArgs(c *C) {
+	_, err := snap.Parser().ParseArgs([]string{"debug", "mount-namespace", "foo", "bar"})
+	c.Assert(err, ErrorMatches, "too many arguments for command")
+}
@@ -36,6 +36,8 @@ type cmdPrepareImage struct {
 
 	ExtraSnaps []string `long:"extra-snaps"`
 	Channel    string   `long:"channel" default:"stable"`
+	DiskImage  bool     `long:"disk-image"`
+	Preseed    bool     `long:"preseed"`
 }
 
 func init() {
@@ -50,6 +52,8 @@ core device images.
 		}, map[string]string{
 			"extra-snaps": "Extra snaps to be installed",
 			"channel":     "The channel to use",
+			"disk-image":  "Also write a disk image for each volume of the gadget",
+			"preseed":     "Seed the image as far as possible by running its snapd in a chroot",
 		}, []argDesc{
 			{
 				// TRANSLATORS: This needs to be wrapped in <>s.
@@ -74,6 +78,10 @@ func (x *cmdPrepareImage) Execute(args []string) error {
 		GadgetUnpackDir: filepath.Join(x.Positional.Rootdir, "gadget"),
 		Channel:         x.Channel,
 		Snaps:           x.ExtraSnaps,
+		Preseed:         x.Preseed,
+	}
+	if x.DiskImage {
+		opts.DiskImageDir = x.Positional.Rootdir
 	}
 
 	return image.Prepare(opts)
@@ -0,0 +1,176 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdRecovery struct{}
+
+var shortRecoveryHelp = i18n.G("List the recovery systems of the device")
+var longRecoveryHelp = i18n.G(`
+The recovery command lists the recovery systems of the device that can be
+used with 'snap reboot' to reseed it. The system the device got last seeded
+from is noted as current.
+`)
+
+type cmdCreateRecoverySystem struct {
+	waitMixin
+	Positional struct {
+		Label string `positional-arg-name:"<label>"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var shortCreateRecoverySystemHelp = i18n.G("Create a recovery system from the installed snaps")
+var longCreateRecoverySystemHelp = i18n.G(`
+The create-recovery-system command creates a new recovery system with the
+given label out of the snaps currently installed on the device, together
+with the model and the assertions needed to install them.
+`)
+
+type cmdReboot struct {
+	waitMixin
+	Recover      bool `long:"recover"`
+	FactoryReset bool `long:"factory-reset"`
+	Positional   struct {
+		Label string `positional-arg-name:"<system>"`
+	} `positional-args:"yes"`
+}
+
+var shortRebootHelp = i18n.G("Reboot the device reseeding it from a recovery system")
+var longRebootHelp = i18n.G(`
+The reboot command reboots the device and seeds it again from the given
+recovery system, or from its original seed if none is given.
+
+With --recover the users, the configuration and the data of the snaps are
+kept. With --factory-reset all of them are wiped. The serial and the key
+of the device are always kept.
+`)
+
+func init() {
+	addCommand("recovery", shortRecoveryHelp, longRecoveryHelp, func() flags.Commander {
+		return &cmdRecovery{}
+	}, nil, nil)
+	addCommand("create-recovery-system", shortCreateRecoverySystemHelp, longCreateRecoverySystemHelp, func() flags.Commander {
+		return &cmdCreateRecoverySystem{}
+	}, waitDescs, []argDesc{{
+		// TRANSLATORS: This needs to be wrapped in <>s.
+		name: i18n.G("<label>"),
+		// TRANSLATORS: This should probably not start with a lowercase letter.
+		desc: i18n.G("The label of the new recovery system"),
+	}})
+	addCommand("reboot", shortRebootHelp, longRebootHelp, func() flags.Commander {
+		return &cmdReboot{}
+	}, waitDescs.also(map[string]string{
+		"recover":       i18n.G("Reseed the device keeping its data"),
+		"factory-reset": i18n.G("Reseed the device wiping its data"),
+	}), []argDesc{{
+		// TRANSLATORS: This needs to be wrapped in <>s.
+		name: i18n.G("<system>"),
+		// TRANSLATORS: This should probably not start with a lowercase letter.
+		desc: i18n.G("The recovery system to reseed the device from"),
+	}})
+}
+
+func (x *cmdRecovery) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	systems, err := Client().Systems()
+	if err != nil {
+		return err
+	}
+	if len(systems) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("No recovery systems found."))
+		return nil
+	}
+
+	w := tabWriter()
+	defer w.Flush()
+	fmt.Fprintln(w, i18n.G("Label\tNotes"))
+	for _, system := range systems {
+		notes := "-"
+		if system.Current {
+			notes = "current"
+		}
+		fmt.Fprintf(w, "%s\t%s\n", system.Label, notes)
+	}
+	return nil
+}
+
+func (x *cmdCreateRecoverySystem) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	cli := Client()
+	id, err := cli.CreateRecoverySystem(x.Positional.Label)
+	if err != nil {
+		return err
+	}
+	if _, err := x.wait(cli, id); err != nil {
+		if err == noWait {
+			return nil
+		}
+		return err
+	}
+
+	fmt.Fprintf(Stdout, i18n.G("Recovery system %q created.\n"), x.Positional.Label)
+	return nil
+}
+
+func (x *cmdReboot) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+	if x.Recover == x.FactoryReset {
+		return errors.New(i18n.G("exactly one of --recover or --factory-reset is required"))
+	}
+
+	cli := Client()
+	id, err := cli.ResetSystem(x.Positional.Label, x.FactoryReset)
+	if err != nil {
+		return err
+	}
+	if _, err := x.wait(cli, id); err != nil {
+		if err == noWait {
+			return nil
+		}
+		return err
+	}
+
+	from := i18n.G("its seed")
+	if x.Positional.Label != "" {
+		from = fmt.Sprintf(i18n.G("recovery system %q"), x.Positional.Label)
+	}
+	if x.FactoryReset {
+		fmt.Fprintf(Stdout, i18n.G("Rebooting to factory reset the device from %s.\n"), from)
+	} else {
+		fmt.Fprintf(Stdout, i18n.G("Rebooting to recover the device from %s.\n"), from)
+	}
+	return nil
+}
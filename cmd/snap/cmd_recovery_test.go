@@ -0,0 +1,121 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	. "gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestRecovery(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/v2/systems")
+		fmt.Fprintln(w, `{"type": "sync", "result": [{"label": "1234"}, {"label": "5678", "current": true}]}`)
+	})
+	rest, err := snap.Parser().ParseArgs([]string{"recovery"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, ""+
+		"Label  Notes\n"+
+		"1234   -\n"+
+		"5678   current\n")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestRecoveryNone(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+	})
+	_, err := snap.Parser().ParseArgs([]string{"recovery"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "")
+	c.Check(s.Stderr(), Equals, "No recovery systems found.\n")
+}
+
+func (s *SnapSuite) TestCreateRecoverySystem(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/systems":
+			c.Check(r.Method, Equals, "POST")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]interface{}{
+				"action": "create",
+				"label":  "1234",
+			})
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "zzz"}`)
+		case "/v2/changes/zzz":
+			fmt.Fprintln(w, `{"type":"sync", "result":{"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+	_, err := snap.Parser().ParseArgs([]string{"create-recovery-system", "1234"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "Recovery system \"1234\" created.\n")
+}
+
+func (s *SnapSuite) TestReboot(c *C) {
+	for _, t := range []struct {
+		args   []string
+		body   map[string]interface{}
+		stdout string
+	}{
+		{
+			[]string{"reboot", "--recover", "1234"},
+			map[string]interface{}{"action": "recover", "label": "1234"},
+			"Rebooting to recover the device from recovery system \"1234\".\n",
+		}, {
+			[]string{"reboot", "--factory-reset"},
+			map[string]interface{}{"action": "factory-reset"},
+			"Rebooting to factory reset the device from its seed.\n",
+		},
+	} {
+		s.ResetStdStreams()
+		s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/v2/systems":
+				c.Check(r.Method, Equals, "POST")
+				c.Check(DecodedRequestBody(c, r), DeepEquals, t.body)
+				fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "zzz"}`)
+			case "/v2/changes/zzz":
+				fmt.Fprintln(w, `{"type":"sync", "result":{"ready": true, "status": "Done"}}`)
+			default:
+				c.Fatalf("unexpected path %q", r.URL.Path)
+			}
+		})
+		_, err := snap.Parser().ParseArgs(t.args)
+		c.Assert(err, IsNil)
+		c.Check(s.Stdout(), Equals, t.stdout)
+	}
+}
+
+func (s *SnapSuite) TestRebootNeedsMode(c *C) {
+	for _, args := range [][]string{
+		{"reboot"},
+		{"reboot", "--recover", "--factory-reset"},
+	} {
+		_, err := snap.Parser().ParseArgs(args)
+		c.Check(err, ErrorMatches, "exactly one of --recover or --factory-reset is required")
+	}
+}
@@ -25,6 +25,7 @@ import (
 
 	"github.com/jessevdk/go-flags"
 
+	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/jsonutil"
 )
@@ -41,18 +42,26 @@ snap's configuration hook returns successfully.
 Nested values may be modified via a dotted path:
 
     $ snap set author.name=frank
+
+A previous configuration, as listed by 'snap get --history', may be
+restored with --revert-to:
+
+    $ snap set snap-name --revert-to=3
 `)
 
 type cmdSet struct {
 	waitMixin
+	RevertTo   int `long:"revert-to"`
 	Positional struct {
-		Snap       installedSnapName
-		ConfValues []string `required:"1"`
-	} `positional-args:"yes" required:"yes"`
+		Snap       installedSnapName `required:"yes"`
+		ConfValues []string
+	} `positional-args:"yes"`
 }
 
 func init() {
-	addCommand("set", shortSetHelp, longSetHelp, func() flags.Commander { return &cmdSet{} }, waitDescs, []argDesc{
+	addCommand("set", shortSetHelp, longSetHelp, func() flags.Commander { return &cmdSet{} }, waitDescs.also(map[string]string{
+		"revert-to": i18n.G("Restore the configuration to the given history entry"),
+	}), []argDesc{
 		{
 			name: "<snap>",
 			// TRANSLATORS: This should probably not start with a lowercase letter.
@@ -67,6 +76,17 @@ func init() {
 }
 
 func (x *cmdSet) Execute(args []string) error {
+	snapName := string(x.Positional.Snap)
+	if x.RevertTo != 0 {
+		if len(x.Positional.ConfValues) > 0 {
+			return fmt.Errorf(i18n.G("cannot use --revert-to together with configuration values"))
+		}
+		return x.revert(snapName)
+	}
+	if len(x.Positional.ConfValues) == 0 {
+		return fmt.Errorf(i18n.G("no configuration values provided (want key=value or --revert-to)"))
+	}
+
 	patchValues := make(map[string]interface{})
 	for _, patchValue := range x.Positional.ConfValues {
 		parts := strings.SplitN(patchValue, "=", 2)
@@ -82,13 +102,26 @@ func (x *cmdSet) Execute(args []string) error {
 		}
 	}
 
-	snapName := string(x.Positional.Snap)
 	cli := Client()
 	id, err := cli.SetConf(snapName, patchValues)
 	if err != nil {
 		return err
 	}
 
+	return x.waitConf(cli, id)
+}
+
+func (x *cmdSet) revert(snapName string) error {
+	cli := Client()
+	id, err := cli.RevertConf(snapName, x.RevertTo)
+	if err != nil {
+		return err
+	}
+
+	return x.waitConf(cli, id)
+}
+
+func (x *cmdSet) waitConf(cli *client.Client, id string) error {
 	if _, err := x.wait(cli, id); err != nil {
 		if err == noWait {
 			return nil
@@ -115,3 +115,39 @@ func (s *SnapSuite) mockSetConfigServer(c *check.C, expectedValue interface{}) {
 		}
 	})
 }
+
+func (s *SnapSuite) TestSnapSetRevertTo(c *check.C) {
+	snaptest.MockSnap(c, string(validApplyYaml), &snap.SideInfo{
+		Revision: snap.R(42),
+	})
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/snaps/snapname/conf/history":
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]interface{}{
+				"action": "revert",
+				"id":     json.Number("3"),
+			})
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "zzz"}`)
+		case "/v2/changes/zzz":
+			c.Check(r.Method, check.Equals, "GET")
+			fmt.Fprintln(w, `{"type":"sync", "result":{"ready": true, "status": "Done"}}`)
+		default:
+			c.Fatalf("unexpected path %q", r.URL.Path)
+		}
+	})
+
+	_, err := snapset.Parser().ParseArgs([]string{"set", "--revert-to=3", "snapname"})
+	c.Assert(err, check.IsNil)
+}
+
+func (s *SnapSuite) TestSnapSetRevertToWithValues(c *check.C) {
+	_, err := snapset.Parser().ParseArgs([]string{"set", "--revert-to=3", "snapname", "key=value"})
+	c.Check(err, check.ErrorMatches, "cannot use --revert-to together with configuration values")
+}
+
+func (s *SnapSuite) TestSnapSetNoValues(c *check.C) {
+	_, err := snapset.Parser().ParseArgs([]string{"set", "snapname"})
+	c.Check(err, check.ErrorMatches, `no configuration values provided \(want key=value or --revert-to\)`)
+}
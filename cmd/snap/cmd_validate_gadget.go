@@ -0,0 +1,59 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package main
+
+import (
+	"fmt"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdValidateGadget struct {
+	Positional struct {
+		GadgetDir string `positional-arg-name:"<gadget-dir>"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var shortValidateGadgetHelp = i18n.G("Validate the gadget.yaml of a gadget snap")
+var longValidateGadgetHelp = i18n.G(`
+The validate-gadget command checks the volumes declared in the gadget.yaml
+of the gadget snap unpacked in the given directory, along with the presence
+of the content they refer to.
+`)
+
+func init() {
+	addDebugCommand("validate-gadget", shortValidateGadgetHelp, longValidateGadgetHelp, func() flags.Commander {
+		return &cmdValidateGadget{}
+	})
+}
+
+func (x *cmdValidateGadget) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	if err := gadget.Validate(x.Positional.GadgetDir); err != nil {
+		return err
+	}
+	fmt.Fprintf(Stdout, i18n.G("gadget %q is valid\n"), x.Positional.GadgetDir)
+	return nil
+}
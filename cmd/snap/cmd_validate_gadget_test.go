@@ -0,0 +1,75 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package main_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) mockGadgetDir(c *C, gadgetYaml string) string {
+	gadgetDir := c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(gadgetDir, "meta"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(gadgetDir, "meta/gadget.yaml"), []byte(gadgetYaml), 0644), IsNil)
+	return gadgetDir
+}
+
+func (s *SnapSuite) TestValidateGadgetHappy(c *C) {
+	gadgetDir := s.mockGadgetDir(c, `
+volumes:
+  pc:
+    bootloader: grub
+    structure:
+      - name: writable
+        type: 0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        filesystem: ext4
+        role: system-data
+        size: 1M
+`)
+	_, err := snap.Parser().ParseArgs([]string{"debug", "validate-gadget", gadgetDir})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "gadget \""+gadgetDir+"\" is valid\n")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestValidateGadgetInvalid(c *C) {
+	gadgetDir := s.mockGadgetDir(c, `
+volumes:
+  pc:
+    bootloader: grub
+    structure:
+      - name: writable
+        type: 0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        filesystem: btrfs
+        size: 1M
+`)
+	_, err := snap.Parser().ParseArgs([]string{"debug", "validate-gadget", gadgetDir})
+	c.Assert(err, ErrorMatches, `invalid volume "pc": invalid structure #0 \("writable"\): invalid filesystem "btrfs"`)
+	c.Check(s.Stdout(), Equals, "")
+}
+
+func (s *SnapSuite) TestValidateGadgetExtraArgs(c *C) {
+	_, err := snap.Parser().ParseArgs([]string{"debug", "validate-gadget", "foo", "bar"})
+	c.Assert(err, ErrorMatches, "too many arguments for command")
+}
@@ -0,0 +1,60 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/seed"
+)
+
+type cmdValidateSeed struct {
+	Positional struct {
+		SeedDir string `positional-arg-name:"<seed-dir>"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var shortValidateSeedHelp = i18n.G("Validate the seed of an image")
+var longValidateSeedHelp = i18n.G(`
+The validate-seed command checks the seed.yaml, assertions and snaps in
+the given seed directory, as used to populate a device at first boot,
+and reports all the problems found.
+`)
+
+func init() {
+	addDebugCommand("validate-seed", shortValidateSeedHelp, longValidateSeedHelp, func() flags.Commander {
+		return &cmdValidateSeed{}
+	})
+}
+
+func (x *cmdValidateSeed) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	if err := seed.Validate(x.Positional.SeedDir); err != nil {
+		return err
+	}
+	fmt.Fprintf(Stdout, i18n.G("seed %q is valid\n"), x.Positional.SeedDir)
+	return nil
+}
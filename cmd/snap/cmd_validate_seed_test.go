@@ -0,0 +1,52 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	snap "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestValidateSeedInvalid(c *C) {
+	seedDir := c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(seedDir, "assertions"), 0755), IsNil)
+	seedYaml := `
+snaps:
+ - name: core
+   file: core_1.snap
+`
+	c.Assert(ioutil.WriteFile(filepath.Join(seedDir, "seed.yaml"), []byte(seedYaml), 0644), IsNil)
+
+	_, err := snap.Parser().ParseArgs([]string{"debug", "validate-seed", seedDir})
+	c.Assert(err, ErrorMatches, `cannot validate seed:
+ - seed has no model assertion
+ - cannot find file "core_1.snap" of snap "core"`)
+	c.Check(s.Stdout(), Equals, "")
+}
+
+func (s *SnapSuite) TestValidateSeedExtraArgs(c *C) {
+	_, err := snap.Parser().ParseArgs([]string{"debug", "validate-seed", "foo", "bar"})
+	c.Assert(err, ErrorMatches, "too many arguments for command")
+}
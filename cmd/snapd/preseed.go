@@ -0,0 +1,85 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+const preseedPollInterval = 1 * time.Second
+
+// runPreseed runs the overlord, without the daemon API, against the
+// image snapd got started in until seeding got as far as it can go
+// without the real device.
+func runPreseed() error {
+	o, err := overlord.New()
+	if err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	var once sync.Once
+	o.SetRestartHandler(func(t state.RestartType) {
+		if t == state.RestartDaemon {
+			once.Do(func() { close(done) })
+		}
+	})
+
+	o.Loop()
+	defer o.Stop()
+
+	ticker := time.NewTicker(preseedPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			logger.Noticef("Preseeding done.")
+			return nil
+		case <-ticker.C:
+			if err := seedingFinished(o.State()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// seedingFinished returns an error if the seed change is over, which
+// means it failed or it could not stop where it had to when preseeding.
+func seedingFinished(st *state.State) error {
+	st.Lock()
+	defer st.Unlock()
+
+	for _, chg := range st.Changes() {
+		if chg.Kind() != "seed" || !chg.IsReady() {
+			continue
+		}
+		if err := chg.Err(); err != nil {
+			return fmt.Errorf("cannot preseed: %v", err)
+		}
+		return fmt.Errorf("cannot preseed: seeding finished unexpectedly")
+	}
+	return nil
+}
@@ -45,6 +45,7 @@ import (
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/snapasserts"
+	"github.com/snapcore/snapd/boot"
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/i18n"
@@ -56,6 +57,7 @@ import (
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
 	"github.com/snapcore/snapd/overlord/ifacestate"
@@ -80,6 +82,7 @@ var api = []*Command{
 	snapsCmd,
 	snapCmd,
 	snapConfCmd,
+	snapConfHistoryCmd,
 	interfacesCmd,
 	assertsCmd,
 	assertsFindManyCmd,
@@ -95,6 +98,8 @@ var api = []*Command{
 	appsCmd,
 	logsCmd,
 	debugCmd,
+	systemsCmd,
+	bootHistoryCmd,
 }
 
 var (
@@ -169,6 +174,12 @@ var (
 		PUT:  setSnapConf,
 	}
 
+	snapConfHistoryCmd = &Command{
+		Path: "/v2/snaps/{name}/conf/history",
+		GET:  getSnapConfHistory,
+		POST: postSnapConfHistory,
+	}
+
 	interfacesCmd = &Command{
 		Path:     "/v2/interfaces",
 		UserOK:   true,
@@ -248,6 +259,18 @@ var (
 		GET:    getAliases,
 		POST:   changeAliases,
 	}
+
+	systemsCmd = &Command{
+		Path: "/v2/systems",
+		GET:  getSystems,
+		POST: postSystems,
+	}
+
+	bootHistoryCmd = &Command{
+		Path:   "/v2/boot-history",
+		UserOK: true,
+		GET:    getBootHistory,
+	}
 )
 
 func tbd(c *Command, r *http.Request, user *auth.UserState) Response {
@@ -1600,8 +1623,19 @@ func getSnapConf(c *Command, r *http.Request, user *auth.UserState) Response {
 	s := c.d.overlord.State()
 	s.Lock()
 	tr := config.NewTransaction(s)
+	var schema *snap.ConfigSchema
+	if info, err := snapstate.CurrentInfo(s, snapName); err == nil {
+		schema = info.ConfigSchema
+	}
 	s.Unlock()
 
+	if snapName == "core" {
+		// reflect the values actually used by the system
+		if err := configcore.ReadBack(tr); err != nil {
+			return InternalError("cannot read system configuration: %v", err)
+		}
+	}
+
 	currentConfValues := make(map[string]interface{})
 	// Special case - return root document
 	if len(keys) == 0 {
@@ -1613,9 +1647,17 @@ func getSnapConf(c *Command, r *http.Request, user *auth.UserState) Response {
 			if config.IsNoOption(err) {
 				if key == "" {
 					// no configuration - return empty document
+					// or just the defaults
 					currentConfValues = make(map[string]interface{})
+					if schema != nil {
+						currentConfValues = schema.ApplyDefaults(nil)
+					}
 					break
 				}
+				if dflt, ok := schemaDefault(schema, key); ok {
+					currentConfValues[key] = dflt
+					continue
+				}
 				return BadRequest("%v", err)
 			} else {
 				return InternalError("%v", err)
@@ -1625,6 +1667,9 @@ func getSnapConf(c *Command, r *http.Request, user *auth.UserState) Response {
 			if len(keys) > 1 {
 				return BadRequest("keys contains zero-length string")
 			}
+			if doc, ok := value.(map[string]interface{}); ok && schema != nil {
+				value = schema.ApplyDefaults(doc)
+			}
 			return SyncResponse(value, nil)
 		}
 
@@ -1634,6 +1679,13 @@ func getSnapConf(c *Command, r *http.Request, user *auth.UserState) Response {
 	return SyncResponse(currentConfValues, nil)
 }
 
+func schemaDefault(schema *snap.ConfigSchema, key string) (interface{}, bool) {
+	if schema == nil {
+		return nil, false
+	}
+	return schema.Default(key)
+}
+
 func setSnapConf(c *Command, r *http.Request, user *auth.UserState) Response {
 	vars := muxVars(r)
 	snapName := systemCoreSnapUnalias(vars["name"])
@@ -1652,11 +1704,89 @@ func setSnapConf(c *Command, r *http.Request, user *auth.UserState) Response {
 		if _, ok := err.(*snap.NotInstalledError); ok {
 			return SnapNotFound(snapName, err)
 		}
+		if _, ok := err.(*snap.ConfigValidationError); ok {
+			return BadRequest("cannot set configuration of snap %q: %v", snapName, err)
+		}
 		return InternalError("%v", err)
 	}
 
 	summary := fmt.Sprintf("Change configuration of %q snap", snapName)
 	change := newChange(st, "configure-snap", summary, []*state.TaskSet{taskset}, []string{snapName})
+	change.Set("config-user", configUser(user))
+
+	st.EnsureBefore(0)
+
+	return AsyncResponse(nil, &Meta{Change: change.ID()})
+}
+
+// configUser returns the name recorded in the configuration history
+// for changes requested by user.
+func configUser(user *auth.UserState) string {
+	if user == nil || user.Username == "" {
+		return "root"
+	}
+	return user.Username
+}
+
+func getSnapConfHistory(c *Command, r *http.Request, user *auth.UserState) Response {
+	vars := muxVars(r)
+	snapName := systemCoreSnapUnalias(vars["name"])
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	history, err := configstate.History(st, snapName)
+	if err != nil {
+		return InternalError("%v", err)
+	}
+	if history == nil {
+		history = []*configstate.HistoryEntry{}
+	}
+	return SyncResponse(history, nil)
+}
+
+type confHistoryAction struct {
+	Action string `json:"action"`
+	ID     int    `json:"id"`
+}
+
+func postSnapConfHistory(c *Command, r *http.Request, user *auth.UserState) Response {
+	vars := muxVars(r)
+	snapName := systemCoreSnapUnalias(vars["name"])
+
+	var a confHistoryAction
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&a); err != nil {
+		return BadRequest("cannot decode request body into a configuration history action: %v", err)
+	}
+	if a.Action != "revert" {
+		return BadRequest("unsupported configuration history action: %q", a.Action)
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	patch, err := configstate.RevertPatch(st, snapName, a.ID)
+	if err != nil {
+		return BadRequest("%v", err)
+	}
+
+	taskset, err := configstate.ConfigureInstalled(st, snapName, patch, 0)
+	if err != nil {
+		if _, ok := err.(*snap.NotInstalledError); ok {
+			return SnapNotFound(snapName, err)
+		}
+		if _, ok := err.(*snap.ConfigValidationError); ok {
+			return BadRequest("cannot revert configuration of snap %q: %v", snapName, err)
+		}
+		return InternalError("%v", err)
+	}
+
+	summary := fmt.Sprintf("Revert configuration of %q snap to #%d", snapName, a.ID)
+	change := newChange(st, "configure-snap", summary, []*state.TaskSet{taskset}, []string{snapName})
+	change.Set("config-user", configUser(user))
 
 	st.EnsureBefore(0)
 
@@ -2248,14 +2378,47 @@ func getUserDetailsFromAssertion(st *state.State, email string) (string, *osutil
 		Gecos:    gecos,
 		Password: su.Password(),
 	}
+
+	passwordAuth, err := coreConfigBool(st, "system.ssh.password-auth", true)
+	if err != nil {
+		return "", nil, fmt.Errorf(errorPrefix+"%v", err)
+	}
+	if !passwordAuth {
+		// only the keys from the assertion give access
+		if len(opts.SSHKeys) == 0 {
+			return "", nil, fmt.Errorf(errorPrefix + "no ssh keys and password authentication is disabled")
+		}
+		opts.Password = ""
+	}
 	return su.Username(), opts, nil
 }
 
+// coreConfigBool returns the value of the given boolean core
+// configuration option, or dflt if it is not set.
+func coreConfigBool(st *state.State, key string, dflt bool) (bool, error) {
+	st.Lock()
+	defer st.Unlock()
+
+	var value interface{}
+	tr := config.NewTransaction(st)
+	if err := tr.Get("core", key, &value); err != nil && !config.IsNoOption(err) {
+		return false, err
+	}
+	switch fmt.Sprintf("%v", value) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	return dflt, nil
+}
+
 type postUserCreateData struct {
 	Email        string `json:"email"`
 	Sudoer       bool   `json:"sudoer"`
 	Known        bool   `json:"known"`
 	ForceManaged bool   `json:"force-managed"`
+	Automatic    bool   `json:"automatic"`
 }
 
 var userLookup = user.Lookup
@@ -2322,6 +2485,17 @@ func postCreateUser(c *Command, r *http.Request, user *auth.UserState) Response
 
 	// verify request
 	st := c.d.overlord.State()
+	if createData.Automatic {
+		// automatic user creation can be switched off
+		enabled, err := coreConfigBool(st, "users.create.automatic", true)
+		if err != nil {
+			return InternalError("%v", err)
+		}
+		if !enabled {
+			return SyncResponse(nil, nil)
+		}
+	}
+
 	st.Lock()
 	users, err := auth.Users(st)
 	st.Unlock()
@@ -2487,6 +2661,19 @@ func readyToBuy(c *Command, r *http.Request, user *auth.UserState) Response {
 	return SyncResponse(true, nil)
 }
 
+// isReadOnlySnapctl returns whether the given snapctl command line only
+// queries the system and so can be run by regular users.
+func isReadOnlySnapctl(args []string) bool {
+	switch args[0] {
+	case "get", "is-connected", "model", "system-mode":
+		return true
+	case "services":
+		// querying the status is fine, acting on services is not
+		return len(args) == 1 || args[1] == "status"
+	}
+	return false
+}
+
 func runSnapctl(c *Command, r *http.Request, user *auth.UserState) Response {
 	var snapctlOptions client.SnapCtlOptions
 	if err := jsonutil.DecodeWithNumber(r.Body, &snapctlOptions); err != nil {
@@ -2501,8 +2688,8 @@ func runSnapctl(c *Command, r *http.Request, user *auth.UserState) Response {
 	if err != nil {
 		return Forbidden("cannot get remote user: %s", err)
 	}
-	// we only allow "get" from regular users in snapctl
-	if uid != 0 && snapctlOptions.Args[0] != "get" {
+	// we only allow read-only commands from regular users in snapctl
+	if uid != 0 && !isReadOnlySnapctl(snapctlOptions.Args) {
 		return Forbidden("cannot use %q with uid %d, try with sudo", snapctlOptions.Args[0], uid)
 	}
 
@@ -2806,3 +2993,92 @@ func systemCoreSnapUnalias(name string) string {
 	}
 	return name
 }
+
+type systemInfo struct {
+	Label   string `json:"label"`
+	Current bool   `json:"current,omitempty"`
+}
+
+// getSystems lists the recovery systems of the device.
+func getSystems(c *Command, r *http.Request, user *auth.UserState) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	labels, err := devicestate.RecoverySystems()
+	if err != nil {
+		return InternalError("%v", err)
+	}
+	current, err := devicestate.CurrentSystem(st)
+	if err != nil {
+		return InternalError("%v", err)
+	}
+
+	systems := make([]systemInfo, len(labels))
+	for i, label := range labels {
+		systems[i] = systemInfo{
+			Label:   label,
+			Current: label == current,
+		}
+	}
+	return SyncResponse(systems, nil)
+}
+
+// getBootHistory lists the recorded attempts to boot new kernel or core
+// snaps, oldest first.
+func getBootHistory(c *Command, r *http.Request, user *auth.UserState) Response {
+	history, err := boot.History()
+	if err != nil {
+		return InternalError("%v", err)
+	}
+	if history == nil {
+		history = []boot.HistoryEntry{}
+	}
+	return SyncResponse(history, nil)
+}
+
+type systemAction struct {
+	Action string `json:"action"`
+	Label  string `json:"label,omitempty"`
+}
+
+// postSystems creates a recovery system or resets the device from one.
+func postSystems(c *Command, r *http.Request, user *auth.UserState) Response {
+	var a systemAction
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&a); err != nil {
+		return BadRequest("cannot decode request body into a system action: %v", err)
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var ts *state.TaskSet
+	var err error
+	var kind, summary string
+	switch a.Action {
+	case "create":
+		ts, err = devicestate.CreateRecoverySystem(st, a.Label)
+		kind = "create-recovery-system"
+		summary = fmt.Sprintf(i18n.G("Create recovery system %q"), a.Label)
+	case "recover", "factory-reset":
+		ts, err = devicestate.ResetSystem(st, a.Label, a.Action == "factory-reset")
+		kind = "reset-system"
+		if a.Label == "" {
+			summary = i18n.G("Reset system from its seed")
+		} else {
+			summary = fmt.Sprintf(i18n.G("Reset system from recovery system %q"), a.Label)
+		}
+	default:
+		return BadRequest("unsupported system action: %q", a.Action)
+	}
+	if err != nil {
+		return BadRequest("%v", err)
+	}
+
+	change := newChange(st, kind, summary, []*state.TaskSet{ts}, nil)
+	st.EnsureBefore(0)
+
+	return AsyncResponse(nil, &Meta{Change: change.ID()})
+}
@@ -50,6 +50,7 @@ import (
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/assertstest"
 	"github.com/snapcore/snapd/asserts/sysdb"
+	"github.com/snapcore/snapd/boot"
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/interfaces"
@@ -59,6 +60,7 @@ import (
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
@@ -2596,6 +2598,32 @@ func (s *apiSuite) TestGetConfCoreSystemAlias(c *check.C) {
 	c.Check(result, check.DeepEquals, map[string]interface{}{"test-key1": "test-value1"})
 }
 
+func (s *apiSuite) TestGetConfCoreReadBack(c *check.C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+	d := s.daemon(c)
+
+	// the configured hostname was changed outside of snapd
+	d.overlord.State().Lock()
+	tr := config.NewTransaction(d.overlord.State())
+	tr.Set("core", "system.hostname", "old-name")
+	tr.Commit()
+	d.overlord.State().Unlock()
+
+	c.Assert(os.MkdirAll(filepath.Join(dirs.GlobalRootDir, "/etc"), 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.GlobalRootDir, "/etc/hostname"), []byte("new-name\n"), 0644), check.IsNil)
+
+	result := s.runGetConf(c, "system", []string{"system.hostname"}, 200)
+	c.Check(result, check.DeepEquals, map[string]interface{}{"system.hostname": "new-name"})
+
+	// the stored configuration is untouched
+	d.overlord.State().Lock()
+	defer d.overlord.State().Unlock()
+	var hostname string
+	c.Assert(config.NewTransaction(d.overlord.State()).Get("core", "system.hostname", &hostname), check.IsNil)
+	c.Check(hostname, check.Equals, "old-name")
+}
+
 func (s *apiSuite) TestGetConfMissingKey(c *check.C) {
 	result := s.runGetConf(c, "test-snap", []string{"test-key2"}, 400)
 	c.Check(result, check.DeepEquals, map[string]interface{}{"message": `snap "test-snap" has no "test-key2" configuration option`})
@@ -5090,6 +5118,94 @@ func (s *postCreateUserSuite) TestGetUserDetailsFromAssertionHappy(c *check.C) {
 	c.Check(err, check.IsNil)
 }
 
+func (s *postCreateUserSuite) setCoreConfig(c *check.C, key string, value interface{}) {
+	st := s.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	tr := config.NewTransaction(st)
+	c.Assert(tr.Set("core", key, value), check.IsNil)
+	tr.Commit()
+}
+
+func (s *postCreateUserSuite) TestGetUserDetailsFromAssertionNoPasswordAuth(c *check.C) {
+	keyedUser := make(map[string]interface{}, len(goodUser))
+	for k, v := range goodUser {
+		keyedUser[k] = v
+	}
+	keyedUser["email"] = "keyed@bar.com"
+	keyedUser["username"] = "keyedguy"
+	keyedUser["ssh-keys"] = []interface{}{"ssh-rsa AAAA keyedguy"}
+	s.makeSystemUsers(c, []map[string]interface{}{goodUser, keyedUser})
+	s.setCoreConfig(c, "system.ssh.password-auth", false)
+
+	// only the ssh keys give access
+	st := s.d.overlord.State()
+	username, opts, err := getUserDetailsFromAssertion(st, "keyed@bar.com")
+	c.Assert(err, check.IsNil)
+	c.Check(username, check.Equals, "keyedguy")
+	c.Check(opts, check.DeepEquals, &osutil.AddUserOptions{
+		Gecos:   "keyed@bar.com,Boring Guy",
+		SSHKeys: []string{"ssh-rsa AAAA keyedguy"},
+	})
+
+	// users without keys cannot be created
+	_, _, err = getUserDetailsFromAssertion(st, "foo@bar.com")
+	c.Check(err, check.ErrorMatches, `cannot add system-user "foo@bar.com": no ssh keys and password authentication is disabled`)
+}
+
+func (s *postCreateUserSuite) TestPostCreateUserAutomaticDisabled(c *check.C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	s.makeSystemUsers(c, []map[string]interface{}{goodUser})
+	s.setCoreConfig(c, "users.create.automatic", false)
+
+	osutilAddUser = func(username string, opts *osutil.AddUserOptions) error {
+		c.Fatalf("unexpected user creation for %q", username)
+		return nil
+	}
+
+	buf := bytes.NewBufferString(`{"known":true,"automatic":true}`)
+	req, err := http.NewRequest("POST", "/v2/create-user", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := postCreateUser(createUserCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.IsNil)
+
+	st := s.d.overlord.State()
+	st.Lock()
+	users, err := auth.Users(st)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Check(users, check.HasLen, 0)
+}
+
+func (s *postCreateUserSuite) TestPostCreateUserAutomaticDisabledExplicitRequest(c *check.C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	s.makeSystemUsers(c, []map[string]interface{}{goodUser})
+	s.setCoreConfig(c, "users.create.automatic", false)
+
+	created := 0
+	osutilAddUser = func(username string, opts *osutil.AddUserOptions) error {
+		c.Check(username, check.Equals, "guy")
+		created++
+		return nil
+	}
+
+	// users asking explicitly are not affected
+	buf := bytes.NewBufferString(`{"known":true}`)
+	req, err := http.NewRequest("POST", "/v2/create-user", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := postCreateUser(createUserCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, []userResponseData{{Username: "guy"}})
+	c.Check(created, check.Equals, 1)
+}
+
 // FIXME: These tests all look similar, with small deltas. Would be
 // nice to transform them into a table that is just the deltas, and
 // run on a loop.
@@ -5885,6 +6001,12 @@ func (s *apiSuite) TestSnapctlGetUID(c *check.C) {
 		{0, "get", "something", 200},
 		{1000, "set", "some=thing", 403},
 		{0, "set", "some=thing", 200},
+		{1000, "is-connected", "network", 200},
+		{1000, "services", "status", 200},
+		{1000, "services", "reload", 403},
+		{1000, "services", "snap.app", 403},
+		{0, "services", "reload", 200},
+		{1000, "unset", "something", 403},
 	} {
 		uid = t.uid
 		buf := bytes.NewBufferString(fmt.Sprintf(`{"context-id": "some-context", "args": [%q, %q]}`, t.cmd, t.arg))
@@ -6560,3 +6682,317 @@ func (s *appSuite) TestErrToResponseNoSnapsDoesNotPanic(c *check.C) {
 		c.Check(status/100 == 4 || status/100 == 5, check.Equals, true, com)
 	}
 }
+
+func (s *apiSuite) mockRecoverySystems(c *check.C, labels ...string) {
+	for _, label := range labels {
+		dir := filepath.Join(dirs.SnapSeedSystemsDir, label)
+		c.Assert(os.MkdirAll(dir, 0755), check.IsNil)
+		c.Assert(ioutil.WriteFile(filepath.Join(dir, "seed.yaml"), nil, 0644), check.IsNil)
+	}
+}
+
+func (s *apiSuite) TestGetSystems(c *check.C) {
+	d := s.daemon(c)
+	s.mockRecoverySystems(c, "1234", "5678")
+
+	st := d.overlord.State()
+	st.Lock()
+	st.Set("seed-system", "5678")
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/systems", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := getSystems(systemsCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, []systemInfo{
+		{Label: "1234"},
+		{Label: "5678", Current: true},
+	})
+}
+
+func (s *apiSuite) TestGetBootHistory(c *check.C) {
+	s.daemon(c)
+
+	req, err := http.NewRequest("GET", "/v2/boot-history", nil)
+	c.Assert(err, check.IsNil)
+
+	// nothing recorded yet
+	rsp := getBootHistory(bootHistoryCmd, req, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, []boot.HistoryEntry{})
+
+	history := `[{"time":"2018-09-01T10:00:00Z","result":"rollback","kind":"kernel","snap":"pc-kernel_2.snap","fallback":"pc-kernel_1.snap","attempts":1}]`
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapBootHistoryFile), 0755), check.IsNil)
+	c.Assert(ioutil.WriteFile(dirs.SnapBootHistoryFile, []byte(history), 0600), check.IsNil)
+
+	rsp = getBootHistory(bootHistoryCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, []boot.HistoryEntry{{
+		Time:     time.Date(2018, 9, 1, 10, 0, 0, 0, time.UTC),
+		Result:   boot.BootRollback,
+		Kind:     "kernel",
+		Snap:     "pc-kernel_2.snap",
+		Fallback: "pc-kernel_1.snap",
+		Attempts: 1,
+	}})
+
+	// broken history
+	c.Assert(ioutil.WriteFile(dirs.SnapBootHistoryFile, []byte("garbage"), 0600), check.IsNil)
+	rsp = getBootHistory(bootHistoryCmd, req, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, 500)
+}
+
+func (s *apiSuite) TestPostSystemsReset(c *check.C) {
+	d := s.daemon(c)
+	s.mockRecoverySystems(c, "1234")
+
+	d.overlord.Loop()
+	defer d.overlord.Stop()
+
+	for _, action := range []string{"recover", "factory-reset"} {
+		buf := bytes.NewBufferString(fmt.Sprintf(`{"action": %q, "label": "1234"}`, action))
+		req, err := http.NewRequest("POST", "/v2/systems", buf)
+		c.Assert(err, check.IsNil)
+
+		rsp := postSystems(systemsCmd, req, nil).(*resp)
+		c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+
+		st := d.overlord.State()
+		st.Lock()
+		chg := st.Change(rsp.Change)
+		c.Assert(chg, check.NotNil)
+		c.Check(chg.Kind(), check.Equals, "reset-system")
+		c.Check(chg.Summary(), check.Equals, `Reset system from recovery system "1234"`)
+		tasks := chg.Tasks()
+		c.Assert(tasks, check.HasLen, 1)
+		c.Check(tasks[0].Kind(), check.Equals, "request-system-reset")
+		var reset map[string]interface{}
+		c.Assert(tasks[0].Get("system-reset", &reset), check.IsNil)
+		c.Check(reset["factory-reset"] == true, check.Equals, action == "factory-reset")
+		st.Unlock()
+	}
+}
+
+func (s *apiSuite) TestPostSystemsErrors(c *check.C) {
+	s.daemon(c)
+
+	for _, scen := range []struct {
+		body string
+		err  string
+	}{
+		{`{"action": "create", "label": "-x"}`, `invalid recovery system label "-x"`},
+		{`{"action": "recover", "label": "1234"}`, `cannot reset system: no recovery system "1234"`},
+		{`{"action": "frobnicate"}`, `unsupported system action: "frobnicate"`},
+		{`{`, `cannot decode request body into a system action: .*`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/systems", bytes.NewBufferString(scen.body))
+		c.Assert(err, check.IsNil)
+
+		rsp := postSystems(systemsCmd, req, nil).(*resp)
+		c.Check(rsp.Type, check.Equals, ResponseTypeError)
+		c.Check(rsp.Status, check.Equals, 400)
+		c.Check(rsp.Result.(*errorResult).Message, check.Matches, scen.err)
+	}
+}
+
+var configSchemaYaml = `
+properties:
+  port:
+    type: integer
+    minimum: 1
+    maximum: 65535
+    default: 8080
+  mode:
+    type: string
+    enum: [fast, slow]
+`
+
+func (s *apiSuite) mockSnapWithConfigSchema(c *check.C) {
+	info := s.mockSnap(c, configYaml)
+	err := ioutil.WriteFile(filepath.Join(info.MountDir(), "meta", "config-schema.yaml"), []byte(configSchemaYaml), 0644)
+	c.Assert(err, check.IsNil)
+}
+
+func (s *apiSuite) TestSetConfSchemaViolation(c *check.C) {
+	d := s.daemon(c)
+	s.mockSnapWithConfigSchema(c)
+
+	text, err := json.Marshal(map[string]interface{}{"mode": "medium"})
+	c.Assert(err, check.IsNil)
+
+	buffer := bytes.NewBuffer(text)
+	req, err := http.NewRequest("PUT", "/v2/snaps/config-snap/conf", buffer)
+	c.Assert(err, check.IsNil)
+
+	s.vars = map[string]string{"name": "config-snap"}
+
+	rec := httptest.NewRecorder()
+	snapConfCmd.PUT(snapConfCmd, req, nil).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 400)
+
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Assert(err, check.IsNil)
+	c.Check(body["result"], check.DeepEquals, map[string]interface{}{
+		"message": `cannot set configuration of snap "config-snap": option "mode" must be one of: fast, slow`,
+	})
+
+	// no change was created
+	st := d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+	c.Check(st.Changes(), check.HasLen, 0)
+}
+
+func (s *apiSuite) TestGetConfSchemaDefaults(c *check.C) {
+	d := s.daemon(c)
+	s.mockSnapWithConfigSchema(c)
+
+	result := s.runGetConf(c, "config-snap", nil, 200)
+	c.Check(result, check.DeepEquals, map[string]interface{}{"port": 8080.})
+
+	result = s.runGetConf(c, "config-snap", []string{"port"}, 200)
+	c.Check(result, check.DeepEquals, map[string]interface{}{"port": 8080.})
+
+	d.overlord.State().Lock()
+	tr := config.NewTransaction(d.overlord.State())
+	tr.Set("config-snap", "mode", "fast")
+	tr.Commit()
+	d.overlord.State().Unlock()
+
+	result = s.runGetConf(c, "config-snap", nil, 200)
+	c.Check(result, check.DeepEquals, map[string]interface{}{"port": 8080., "mode": "fast"})
+
+	s.runGetConf(c, "config-snap", []string{"other"}, 400)
+}
+
+func (s *apiSuite) mockConfHistory(st *state.State) {
+	t0 := time.Date(2018, 3, 1, 10, 0, 0, 0, time.UTC)
+	st.Set("config-history", map[string][]*configstate.HistoryEntry{
+		"config-snap": {{
+			ID:       1,
+			Time:     t0,
+			Change:   "1",
+			User:     "root",
+			Values:   map[string]interface{}{"key": "value"},
+			Previous: map[string]interface{}{"key": nil},
+		}, {
+			ID:       2,
+			Time:     t0.Add(time.Hour),
+			Change:   "2",
+			Values:   map[string]interface{}{"key": "other"},
+			Previous: map[string]interface{}{"key": "value"},
+		}},
+	})
+}
+
+func (s *apiSuite) TestGetConfHistory(c *check.C) {
+	d := s.daemon(c)
+	s.mockSnap(c, configYaml)
+
+	st := d.overlord.State()
+	st.Lock()
+	s.mockConfHistory(st)
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/snaps/config-snap/conf/history", nil)
+	c.Assert(err, check.IsNil)
+	s.vars = map[string]string{"name": "config-snap"}
+
+	rsp := getSnapConfHistory(snapConfHistoryCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	history := rsp.Result.([]*configstate.HistoryEntry)
+	c.Assert(history, check.HasLen, 2)
+	c.Check(history[0].ID, check.Equals, 1)
+	c.Check(history[0].User, check.Equals, "root")
+	c.Check(history[1].ID, check.Equals, 2)
+	c.Check(history[1].Values, check.DeepEquals, map[string]interface{}{"key": "other"})
+
+	s.vars = map[string]string{"name": "other-snap"}
+	rsp = getSnapConfHistory(snapConfHistoryCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, []*configstate.HistoryEntry{})
+}
+
+func (s *apiSuite) TestPostConfHistoryRevert(c *check.C) {
+	d := s.daemon(c)
+	s.mockSnap(c, configYaml)
+
+	st := d.overlord.State()
+	st.Lock()
+	s.mockConfHistory(st)
+	st.Unlock()
+
+	// Mock the hook runner
+	hookRunner := testutil.MockCommand(c, "snap", "")
+	defer hookRunner.Restore()
+
+	d.overlord.Loop()
+	defer d.overlord.Stop()
+
+	buf := bytes.NewBufferString(`{"action": "revert", "id": 1}`)
+	req, err := http.NewRequest("POST", "/v2/snaps/config-snap/conf/history", buf)
+	c.Assert(err, check.IsNil)
+	s.vars = map[string]string{"name": "config-snap"}
+
+	rsp := postSnapConfHistory(snapConfHistoryCmd, req, nil).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+
+	st.Lock()
+	chg := st.Change(rsp.Change)
+	st.Unlock()
+	c.Assert(chg, check.NotNil)
+
+	<-chg.Ready()
+
+	st.Lock()
+	defer st.Unlock()
+	c.Assert(chg.Err(), check.IsNil)
+	c.Check(chg.Kind(), check.Equals, "configure-snap")
+	c.Check(chg.Summary(), check.Equals, `Revert configuration of "config-snap" snap to #1`)
+
+	var user string
+	c.Assert(chg.Get("config-user", &user), check.IsNil)
+	c.Check(user, check.Equals, "root")
+
+	tr := config.NewTransaction(st)
+	var value string
+	c.Assert(tr.Get("config-snap", "key", &value), check.IsNil)
+	c.Check(value, check.Equals, "value")
+
+	// the revert is itself recorded in the history
+	history, err := configstate.History(st, "config-snap")
+	c.Assert(err, check.IsNil)
+	c.Assert(history, check.HasLen, 3)
+	c.Check(history[2].Change, check.Equals, chg.ID())
+	c.Check(history[2].User, check.Equals, "root")
+}
+
+func (s *apiSuite) TestPostConfHistoryErrors(c *check.C) {
+	d := s.daemon(c)
+	s.mockSnap(c, configYaml)
+
+	st := d.overlord.State()
+	st.Lock()
+	s.mockConfHistory(st)
+	st.Unlock()
+
+	s.vars = map[string]string{"name": "config-snap"}
+	for _, t := range []struct {
+		body string
+		err  string
+	}{
+		{`{"action": "frobnicate", "id": 1}`, `unsupported configuration history action: "frobnicate"`},
+		{`{"action": "revert", "id": 42}`, `cannot find configuration history entry 42 for snap "config-snap"`},
+		{`}`, `cannot decode request body into a configuration history action: .*`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/snaps/config-snap/conf/history", bytes.NewBufferString(t.body))
+		c.Assert(err, check.IsNil)
+		rsp := postSnapConfHistory(snapConfHistoryCmd, req, nil).(*resp)
+		c.Check(rsp.Status, check.Equals, 400, check.Commentf(t.body))
+		c.Check(rsp.Result.(*errorResult).Message, check.Matches, t.err)
+	}
+}
@@ -56,16 +56,20 @@ var (
 	SnapRunNsDir              string
 	SnapRunLockDir            string
 
-	SnapSeedDir   string
-	SnapDeviceDir string
+	SnapSeedDir        string
+	SnapSeedSystemsDir string
+	SnapDeviceDir      string
+	SnapRollbackDir    string
 
 	SnapAssertsDBDir      string
 	SnapCookieDir         string
 	SnapTrustedAccountKey string
 	SnapAssertsSpoolDir   string
 
-	SnapStateFile     string
-	SnapSystemKeyFile string
+	SnapStateFile       string
+	SnapSystemKeyFile   string
+	SnapBootHistoryFile string
+	SnapSystemResetFile string
 
 	SnapRepairDir        string
 	SnapRepairStateFile  string
@@ -204,6 +208,8 @@ func SetRootDir(rootdir string) {
 
 	SnapStateFile = filepath.Join(rootdir, snappyDir, "state.json")
 	SnapSystemKeyFile = filepath.Join(rootdir, snappyDir, "system-key")
+	SnapBootHistoryFile = filepath.Join(rootdir, snappyDir, "boot-history.json")
+	SnapSystemResetFile = filepath.Join(rootdir, snappyDir, "system-reset.json")
 
 	SnapCacheDir = filepath.Join(rootdir, "/var/cache/snapd")
 	SnapNamesFile = filepath.Join(SnapCacheDir, "names")
@@ -211,7 +217,9 @@ func SetRootDir(rootdir string) {
 	SnapCommandsDB = filepath.Join(SnapCacheDir, "commands.db")
 
 	SnapSeedDir = filepath.Join(rootdir, snappyDir, "seed")
+	SnapSeedSystemsDir = filepath.Join(SnapSeedDir, "systems")
 	SnapDeviceDir = filepath.Join(rootdir, snappyDir, "device")
+	SnapRollbackDir = filepath.Join(rootdir, snappyDir, "rollback")
 
 	SnapRepairDir = filepath.Join(rootdir, snappyDir, "repair")
 	SnapRepairStateFile = filepath.Join(SnapRepairDir, "repair.json")
@@ -0,0 +1,134 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package gadget
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// ErrDeviceNotFound is returned when the block device of a structure
+// cannot be found.
+var ErrDeviceNotFound = errors.New("device not found")
+
+// ErrMountNotFound is returned when the filesystem of a structure is not
+// mounted.
+var ErrMountNotFound = errors.New("mount point not found")
+
+var procSelfMountInfo = osutil.ProcSelfMountInfo
+
+// encodeLabel encodes a partition name or a filesystem label the way udev
+// does when creating the /dev/disk/by-partlabel and /dev/disk/by-label
+// symlinks.
+func encodeLabel(in string) string {
+	var out bytes.Buffer
+	for _, r := range in {
+		switch {
+		case r == '/' || r == '\\' || r == ' ' || r > 0x7e || r < 0x20:
+			// udev escapes characters that are unsafe in file names
+			fmt.Fprintf(&out, `\x%02x`, r)
+		default:
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
+
+// FindDeviceForStructure returns the block device node of the partition
+// described by the structure. The partition is looked up using its name
+// and, failing that, the label of its filesystem.
+func FindDeviceForStructure(ps *LaidOutStructure) (string, error) {
+	var candidates []string
+	if ps.Name != "" {
+		candidates = append(candidates, filepath.Join(dirs.GlobalRootDir, "/dev/disk/by-partlabel", encodeLabel(ps.Name)))
+	}
+	if ps.Label != "" {
+		candidates = append(candidates, filepath.Join(dirs.GlobalRootDir, "/dev/disk/by-label", encodeLabel(ps.Label)))
+	}
+	for _, candidate := range candidates {
+		target, err := filepath.EvalSymlinks(candidate)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("cannot read device link: %v", err)
+		}
+		return target, nil
+	}
+	return "", ErrDeviceNotFound
+}
+
+// findDiskForPartition returns the block device node of the disk the given
+// partition device belongs to.
+func findDiskForPartition(partDevice string) (string, error) {
+	sysBlock := filepath.Join(dirs.GlobalRootDir, "/sys/class/block", filepath.Base(partDevice))
+	resolved, err := filepath.EvalSymlinks(sysBlock)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve disk of partition %v: %v", partDevice, err)
+	}
+	// /sys/devices/.../block/<disk>/<partition>
+	disk := filepath.Base(filepath.Dir(resolved))
+	return filepath.Join(filepath.Dir(partDevice), disk), nil
+}
+
+// FindDiskForVolume returns the block device node of the disk holding the
+// volume. The disk is located through any of its partitions that can be
+// found.
+func FindDiskForVolume(lv *LaidOutVolume) (string, error) {
+	for idx := range lv.LaidOutStructure {
+		ps := &lv.LaidOutStructure[idx]
+		if !IsPartition(ps.VolumeStructure) {
+			continue
+		}
+		partDevice, err := FindDeviceForStructure(ps)
+		if err == ErrDeviceNotFound {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return findDiskForPartition(partDevice)
+	}
+	return "", ErrDeviceNotFound
+}
+
+// FindMountPointForStructure returns the directory where the filesystem of
+// the structure is mounted.
+func FindMountPointForStructure(ps *LaidOutStructure) (string, error) {
+	device, err := FindDeviceForStructure(ps)
+	if err != nil {
+		return "", err
+	}
+	entries, err := osutil.LoadMountInfo(procSelfMountInfo)
+	if err != nil {
+		return "", fmt.Errorf("cannot read mount info: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.MountSource == device && entry.Root == "/" {
+			return entry.MountDir, nil
+		}
+	}
+	return "", ErrMountNotFound
+}
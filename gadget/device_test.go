@@ -0,0 +1,118 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package gadget_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/snap"
+)
+
+type deviceSuite struct {
+	dir string
+}
+
+var _ = Suite(&deviceSuite{})
+
+func (d *deviceSuite) SetUpTest(c *C) {
+	d.dir = c.MkDir()
+	dirs.SetRootDir(d.dir)
+
+	for _, p := range []string{"/dev/disk/by-label", "/dev/disk/by-partlabel", "/sys/devices/pci/block/sda/sda1", "/sys/class/block"} {
+		c.Assert(os.MkdirAll(filepath.Join(d.dir, p), 0755), IsNil)
+	}
+	c.Assert(ioutil.WriteFile(filepath.Join(d.dir, "/dev/sda1"), nil, 0644), IsNil)
+	c.Assert(os.Symlink("../../sda1", filepath.Join(d.dir, "/dev/disk/by-partlabel/system-boot")), IsNil)
+	c.Assert(os.Symlink("../../sda1", filepath.Join(d.dir, "/dev/disk/by-label/system\\x20boot")), IsNil)
+	c.Assert(os.Symlink("../../devices/pci/block/sda/sda1", filepath.Join(d.dir, "/sys/class/block/sda1")), IsNil)
+}
+
+func (d *deviceSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("/")
+}
+
+func (d *deviceSuite) TestEncodeLabel(c *C) {
+	c.Check(gadget.EncodeLabel("system-boot"), Equals, "system-boot")
+	c.Check(gadget.EncodeLabel("system boot"), Equals, `system\x20boot`)
+	c.Check(gadget.EncodeLabel("a/b"), Equals, `a\x2fb`)
+}
+
+func (d *deviceSuite) TestFindDeviceForStructureByName(c *C) {
+	ps := &gadget.LaidOutStructure{VolumeStructure: &snap.VolumeStructure{Name: "system-boot"}}
+	dev, err := gadget.FindDeviceForStructure(ps)
+	c.Assert(err, IsNil)
+	c.Check(dev, Equals, filepath.Join(d.dir, "/dev/sda1"))
+}
+
+func (d *deviceSuite) TestFindDeviceForStructureByLabel(c *C) {
+	ps := &gadget.LaidOutStructure{VolumeStructure: &snap.VolumeStructure{Name: "other", Label: "system boot"}}
+	dev, err := gadget.FindDeviceForStructure(ps)
+	c.Assert(err, IsNil)
+	c.Check(dev, Equals, filepath.Join(d.dir, "/dev/sda1"))
+}
+
+func (d *deviceSuite) TestFindDeviceForStructureNotFound(c *C) {
+	ps := &gadget.LaidOutStructure{VolumeStructure: &snap.VolumeStructure{Name: "other", Label: "other"}}
+	_, err := gadget.FindDeviceForStructure(ps)
+	c.Check(err, Equals, gadget.ErrDeviceNotFound)
+
+	ps = &gadget.LaidOutStructure{VolumeStructure: &snap.VolumeStructure{}}
+	_, err = gadget.FindDeviceForStructure(ps)
+	c.Check(err, Equals, gadget.ErrDeviceNotFound)
+}
+
+func (d *deviceSuite) TestFindDiskForVolume(c *C) {
+	lv := &gadget.LaidOutVolume{
+		LaidOutStructure: []gadget.LaidOutStructure{
+			{VolumeStructure: &snap.VolumeStructure{Name: "system-boot", Type: "bare"}},
+			{VolumeStructure: &snap.VolumeStructure{Name: "missing", Type: "83"}},
+			{VolumeStructure: &snap.VolumeStructure{Name: "system-boot", Type: "83"}},
+		},
+	}
+	disk, err := gadget.FindDiskForVolume(lv)
+	c.Assert(err, IsNil)
+	c.Check(disk, Equals, filepath.Join(d.dir, "/dev/sda"))
+
+	lv.LaidOutStructure = lv.LaidOutStructure[:2]
+	_, err = gadget.FindDiskForVolume(lv)
+	c.Check(err, Equals, gadget.ErrDeviceNotFound)
+}
+
+func (d *deviceSuite) TestFindMountPointForStructure(c *C) {
+	mountInfo := filepath.Join(d.dir, "mountinfo")
+	content := fmt.Sprintf("170 27 8:1 / /boot/efi rw,relatime shared:58 - vfat %s rw\n", filepath.Join(d.dir, "/dev/sda1"))
+	c.Assert(ioutil.WriteFile(mountInfo, []byte(content), 0644), IsNil)
+	defer gadget.MockProcSelfMountInfo(mountInfo)()
+
+	ps := &gadget.LaidOutStructure{VolumeStructure: &snap.VolumeStructure{Name: "system-boot"}}
+	mp, err := gadget.FindMountPointForStructure(ps)
+	c.Assert(err, IsNil)
+	c.Check(mp, Equals, "/boot/efi")
+
+	c.Assert(ioutil.WriteFile(mountInfo, nil, 0644), IsNil)
+	_, err = gadget.FindMountPointForStructure(ps)
+	c.Check(err, Equals, gadget.ErrMountNotFound)
+}
@@ -0,0 +1,287 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package gadget
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/snap"
+)
+
+// NonMBRStartOffset is the default start offset of the first structure
+// that is not a MBR.
+const NonMBRStartOffset = 1 * SizeMiB
+
+// LaidOutVolume is a volume with all of its structures placed at their
+// absolute offsets.
+type LaidOutVolume struct {
+	*snap.GadgetVolume
+	// Size is the total size of the volume.
+	Size Size
+	// RootDir is the root directory of the gadget data that the volume
+	// content is relative to.
+	RootDir string
+	// LaidOutStructure is the list of structures sorted by their start
+	// offset.
+	LaidOutStructure []LaidOutStructure
+}
+
+// LaidOutStructure describes a structure of the volume placed at its
+// start offset.
+type LaidOutStructure struct {
+	*snap.VolumeStructure
+	// StartOffset is the absolute offset of the structure within the
+	// volume.
+	StartOffset Size
+	// Size is the size of the structure.
+	Size Size
+	// Index is the position of the structure as declared in gadget.yaml.
+	Index int
+	// LaidOutContent is the list of raw images of the structure, placed at
+	// their absolute offsets. Empty for structures with a filesystem.
+	LaidOutContent []LaidOutContent
+}
+
+// LaidOutContent describes a raw image placed at its start offset.
+type LaidOutContent struct {
+	*snap.VolumeContent
+	// StartOffset is the absolute offset of the image within the volume.
+	StartOffset Size
+	// Size is the size of the image.
+	Size Size
+	// Index is the position of the content as declared in gadget.yaml.
+	Index int
+}
+
+func (p LaidOutStructure) String() string {
+	return fmtIndexAndName(p.Index, p.Name)
+}
+
+func (p LaidOutContent) String() string {
+	if p.Image != "" {
+		return fmt.Sprintf("#%d (%q@%#x{%v})", p.Index, p.Image, uint64(p.StartOffset), p.Size)
+	}
+	return fmt.Sprintf("#%d (source:%q)", p.Index, p.Source)
+}
+
+func fmtIndexAndName(idx int, name string) string {
+	if name != "" {
+		return fmt.Sprintf("#%d (%q)", idx, name)
+	}
+	return fmt.Sprintf("#%d", idx)
+}
+
+// HasFilesystem returns true if the structure carries a filesystem.
+func HasFilesystem(vs *snap.VolumeStructure) bool {
+	return vs.Filesystem != "none" && vs.Filesystem != ""
+}
+
+// IsPartition returns true when the structure describes a partition
+// rather than a bare area of the disk.
+func IsPartition(vs *snap.VolumeStructure) bool {
+	return vs.Type != "bare" && vs.Type != "mbr" && vs.Role != "mbr"
+}
+
+func isMBR(vs *snap.VolumeStructure) bool {
+	return vs.Role == "mbr" || vs.Type == "mbr"
+}
+
+// LayoutVolume places the structures of the volume at their absolute
+// offsets. Structures without an explicit offset are placed right after
+// the preceding one. Raw image content is placed within its structure and
+// sized using the image files found under rootDir.
+func LayoutVolume(rootDir string, volume *snap.GadgetVolume) (*LaidOutVolume, error) {
+	structures, volumeSize, err := layOutVolumeStructures(volume)
+	if err != nil {
+		return nil, err
+	}
+	for idx := range structures {
+		ps := &structures[idx]
+		if HasFilesystem(ps.VolumeStructure) {
+			continue
+		}
+		content, err := layOutStructureContent(rootDir, ps)
+		if err != nil {
+			return nil, err
+		}
+		ps.LaidOutContent = content
+	}
+
+	return &LaidOutVolume{
+		GadgetVolume:     volume,
+		Size:             volumeSize,
+		RootDir:          rootDir,
+		LaidOutStructure: structures,
+	}, nil
+}
+
+// layOutVolumeStructures places the structures of the volume without
+// looking at their content. The structures are returned sorted by their
+// start offset, along with the size of the volume.
+func layOutVolumeStructures(volume *snap.GadgetVolume) ([]LaidOutStructure, Size, error) {
+	structures := make([]LaidOutStructure, len(volume.Structure))
+	var previousEnd Size
+	var farthestEnd Size
+	for idx := range volume.Structure {
+		vs := &volume.Structure[idx]
+		size, err := ParseSize(vs.Size)
+		if err != nil {
+			return nil, 0, fmt.Errorf("cannot lay out structure %v: %v", fmtIndexAndName(idx, vs.Name), err)
+		}
+		var start Size
+		switch {
+		case vs.Offset != "":
+			start, err = ParseSize(vs.Offset)
+			if err != nil {
+				return nil, 0, fmt.Errorf("cannot lay out structure %v: invalid offset: %v", fmtIndexAndName(idx, vs.Name), err)
+			}
+		case idx == 0 && isMBR(vs):
+			start = 0
+		case idx == 0:
+			start = NonMBRStartOffset
+		default:
+			start = previousEnd
+		}
+		structures[idx] = LaidOutStructure{
+			VolumeStructure: vs,
+			StartOffset:     start,
+			Size:            size,
+			Index:           idx,
+		}
+		previousEnd = start + size
+		if previousEnd > farthestEnd {
+			farthestEnd = previousEnd
+		}
+	}
+
+	sort.Sort(byStartOffset(structures))
+	for idx := 1; idx < len(structures); idx++ {
+		prev := structures[idx-1]
+		cur := structures[idx]
+		if prev.StartOffset+prev.Size > cur.StartOffset {
+			return nil, 0, fmt.Errorf("cannot lay out volume: structure %v overlaps with preceding structure %v", cur, prev)
+		}
+	}
+	return structures, farthestEnd, nil
+}
+
+type byStartOffset []LaidOutStructure
+
+func (b byStartOffset) Len() int           { return len(b) }
+func (b byStartOffset) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byStartOffset) Less(i, j int) bool { return b[i].StartOffset < b[j].StartOffset }
+
+// RelativeOffset describes an offset, optionally relative to the start of
+// a named structure.
+type RelativeOffset struct {
+	// RelativeTo is the name of the structure the offset is relative
+	// to, empty when the offset is absolute
+	RelativeTo string
+	// Offset is the offset in bytes
+	Offset Size
+}
+
+func (r *RelativeOffset) String() string {
+	if r.RelativeTo != "" {
+		return fmt.Sprintf("%s+%v", r.RelativeTo, r.Offset)
+	}
+	return r.Offset.String()
+}
+
+// ParseRelativeOffset parses an offset as used by offset-write, in the
+// form [<name>+]<offset>.
+func ParseRelativeOffset(s string) (*RelativeOffset, error) {
+	var relativeTo string
+	offsetSpec := s
+	if idx := strings.IndexRune(s, '+'); idx != -1 {
+		relativeTo = s[:idx]
+		offsetSpec = s[idx+1:]
+		if relativeTo == "" {
+			return nil, fmt.Errorf("cannot parse relative offset %q: missing structure name", s)
+		}
+	}
+	offset, err := ParseSize(offsetSpec)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse relative offset %q: %v", s, err)
+	}
+	return &RelativeOffset{RelativeTo: relativeTo, Offset: offset}, nil
+}
+
+// ResolveRelativeOffset returns the absolute location of the relative
+// offset within the laid out structures.
+func ResolveRelativeOffset(ro *RelativeOffset, structures []LaidOutStructure) (Size, error) {
+	if ro.RelativeTo == "" {
+		return ro.Offset, nil
+	}
+	for _, ps := range structures {
+		if ps.Name == ro.RelativeTo {
+			return ps.StartOffset + ro.Offset, nil
+		}
+	}
+	return 0, fmt.Errorf("refers to an unknown structure %q", ro.RelativeTo)
+}
+
+func layOutStructureContent(rootDir string, ps *LaidOutStructure) ([]LaidOutContent, error) {
+	content := make([]LaidOutContent, len(ps.Content))
+	previousEnd := ps.StartOffset
+	for idx := range ps.Content {
+		vc := &ps.Content[idx]
+		if vc.Image == "" {
+			return nil, fmt.Errorf("cannot lay out structure %v: content #%d has no image", ps, idx)
+		}
+		st, err := os.Stat(filepath.Join(rootDir, vc.Image))
+		if err != nil {
+			return nil, fmt.Errorf("cannot lay out structure %v: content %q: %v", ps, vc.Image, err)
+		}
+		size := Size(st.Size())
+		if vc.Size != "" {
+			declared, err := ParseSize(vc.Size)
+			if err != nil {
+				return nil, fmt.Errorf("cannot lay out structure %v: content %q: %v", ps, vc.Image, err)
+			}
+			if declared < size {
+				return nil, fmt.Errorf("cannot lay out structure %v: content %q size %v is larger than declared %v", ps, vc.Image, size, declared)
+			}
+			size = declared
+		}
+		start := previousEnd
+		if vc.Offset != "" {
+			relative, err := ParseSize(vc.Offset)
+			if err != nil {
+				return nil, fmt.Errorf("cannot lay out structure %v: content %q: invalid offset: %v", ps, vc.Image, err)
+			}
+			start = ps.StartOffset + relative
+		}
+		if start+size > ps.StartOffset+ps.Size {
+			return nil, fmt.Errorf("cannot lay out structure %v: content %q does not fit in the structure", ps, vc.Image)
+		}
+		content[idx] = LaidOutContent{
+			VolumeContent: vc,
+			StartOffset:   start,
+			Size:          size,
+			Index:         idx,
+		}
+		previousEnd = start + size
+	}
+	return content, nil
+}
@@ -0,0 +1,173 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package gadget_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/snap"
+)
+
+type layoutTestSuite struct {
+	dir string
+}
+
+var _ = Suite(&layoutTestSuite{})
+
+func (p *layoutTestSuite) SetUpTest(c *C) {
+	p.dir = c.MkDir()
+}
+
+func (p *layoutTestSuite) TestLayoutVolumeImplicitOffsets(c *C) {
+	vol := &snap.GadgetVolume{
+		Structure: []snap.VolumeStructure{
+			{Name: "mbr", Role: "mbr", Type: "mbr", Size: "440"},
+			{Name: "first", Type: "83", Size: "2M", Filesystem: "vfat"},
+			{Name: "second", Type: "83", Size: "1M", Filesystem: "ext4"},
+		},
+	}
+	lv, err := gadget.LayoutVolume(p.dir, vol)
+	c.Assert(err, IsNil)
+	c.Check(lv.Size, Equals, 440+3*gadget.SizeMiB)
+	c.Check(lv.RootDir, Equals, p.dir)
+	c.Assert(lv.LaidOutStructure, HasLen, 3)
+	c.Check(lv.LaidOutStructure[0].StartOffset, Equals, gadget.Size(0))
+	c.Check(lv.LaidOutStructure[1].StartOffset, Equals, gadget.Size(440))
+	c.Check(lv.LaidOutStructure[2].StartOffset, Equals, 440+2*gadget.SizeMiB)
+	c.Check(lv.LaidOutStructure[2].Index, Equals, 2)
+	c.Check(lv.LaidOutStructure[2].String(), Equals, `#2 ("second")`)
+}
+
+func (p *layoutTestSuite) TestLayoutVolumeFirstStructureDefaultOffset(c *C) {
+	vol := &snap.GadgetVolume{
+		Structure: []snap.VolumeStructure{
+			{Type: "83", Size: "1M", Filesystem: "ext4"},
+		},
+	}
+	lv, err := gadget.LayoutVolume(p.dir, vol)
+	c.Assert(err, IsNil)
+	c.Check(lv.LaidOutStructure[0].StartOffset, Equals, gadget.NonMBRStartOffset)
+	c.Check(lv.LaidOutStructure[0].String(), Equals, "#0")
+	c.Check(lv.Size, Equals, 2*gadget.SizeMiB)
+}
+
+func (p *layoutTestSuite) TestLayoutVolumeSortsByOffset(c *C) {
+	vol := &snap.GadgetVolume{
+		Structure: []snap.VolumeStructure{
+			{Name: "later", Type: "83", Offset: "4M", Size: "1M", Filesystem: "ext4"},
+			{Name: "earlier", Type: "83", Offset: "1M", Size: "1M", Filesystem: "ext4"},
+		},
+	}
+	lv, err := gadget.LayoutVolume(p.dir, vol)
+	c.Assert(err, IsNil)
+	c.Check(lv.Size, Equals, 5*gadget.SizeMiB)
+	c.Check(lv.LaidOutStructure[0].Name, Equals, "earlier")
+	c.Check(lv.LaidOutStructure[0].Index, Equals, 1)
+	c.Check(lv.LaidOutStructure[1].Name, Equals, "later")
+}
+
+func (p *layoutTestSuite) TestLayoutVolumeOverlap(c *C) {
+	vol := &snap.GadgetVolume{
+		Structure: []snap.VolumeStructure{
+			{Name: "first", Type: "83", Offset: "1M", Size: "2M", Filesystem: "ext4"},
+			{Name: "second", Type: "83", Offset: "2M", Size: "1M", Filesystem: "ext4"},
+		},
+	}
+	_, err := gadget.LayoutVolume(p.dir, vol)
+	c.Check(err, ErrorMatches, `cannot lay out volume: structure #1 \("second"\) overlaps with preceding structure #0 \("first"\)`)
+}
+
+func (p *layoutTestSuite) TestLayoutVolumeBadSize(c *C) {
+	vol := &snap.GadgetVolume{
+		Structure: []snap.VolumeStructure{
+			{Name: "first", Type: "83", Size: "1K"},
+		},
+	}
+	_, err := gadget.LayoutVolume(p.dir, vol)
+	c.Check(err, ErrorMatches, `cannot lay out structure #0 \("first"\): cannot parse size "1K": invalid number`)
+}
+
+func (p *layoutTestSuite) TestLayoutVolumeRawContent(c *C) {
+	err := ioutil.WriteFile(filepath.Join(p.dir, "foo.img"), make([]byte, 100), 0644)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(filepath.Join(p.dir, "bar.img"), make([]byte, 50), 0644)
+	c.Assert(err, IsNil)
+
+	vol := &snap.GadgetVolume{
+		Structure: []snap.VolumeStructure{
+			{Name: "boot", Type: "bare", Offset: "1M", Size: "1M", Content: []snap.VolumeContent{
+				{Image: "foo.img"},
+				{Image: "bar.img", Offset: "1000"},
+			}},
+		},
+	}
+	lv, err := gadget.LayoutVolume(p.dir, vol)
+	c.Assert(err, IsNil)
+	content := lv.LaidOutStructure[0].LaidOutContent
+	c.Assert(content, HasLen, 2)
+	c.Check(content[0].StartOffset, Equals, gadget.SizeMiB)
+	c.Check(content[0].Size, Equals, gadget.Size(100))
+	c.Check(content[1].StartOffset, Equals, gadget.SizeMiB+1000)
+	c.Check(content[1].Size, Equals, gadget.Size(50))
+	c.Check(content[1].String(), Equals, `#1 ("bar.img"@0x1003e8{50})`)
+}
+
+func (p *layoutTestSuite) TestLayoutVolumeRawContentErrors(c *C) {
+	err := ioutil.WriteFile(filepath.Join(p.dir, "foo.img"), make([]byte, 100), 0644)
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		content snap.VolumeContent
+		err     string
+	}{
+		{snap.VolumeContent{Image: "missing.img"}, `cannot lay out structure #0 \("boot"\): content "missing.img": .* no such file or directory`},
+		{snap.VolumeContent{Image: "foo.img", Size: "10"}, `cannot lay out structure #0 \("boot"\): content "foo.img" size 100 is larger than declared 10`},
+		{snap.VolumeContent{Image: "foo.img", Offset: "1000"}, `cannot lay out structure #0 \("boot"\): content "foo.img" does not fit in the structure`},
+		{snap.VolumeContent{Source: "foo"}, `cannot lay out structure #0 \("boot"\): content #0 has no image`},
+	} {
+		vol := &snap.GadgetVolume{
+			Structure: []snap.VolumeStructure{
+				{Name: "boot", Type: "bare", Size: "1000", Content: []snap.VolumeContent{t.content}},
+			},
+		}
+		_, err := gadget.LayoutVolume(p.dir, vol)
+		c.Check(err, ErrorMatches, t.err)
+	}
+}
+
+func (s *layoutTestSuite) TestParseRelativeOffset(c *C) {
+	ro, err := gadget.ParseRelativeOffset("mbr+92")
+	c.Assert(err, IsNil)
+	c.Check(ro, DeepEquals, &gadget.RelativeOffset{RelativeTo: "mbr", Offset: 92})
+	c.Check(ro.String(), Equals, "mbr+92")
+
+	ro, err = gadget.ParseRelativeOffset("1M")
+	c.Assert(err, IsNil)
+	c.Check(ro, DeepEquals, &gadget.RelativeOffset{Offset: gadget.SizeMiB})
+	c.Check(ro.String(), Equals, "1048576")
+
+	_, err = gadget.ParseRelativeOffset("+12")
+	c.Check(err, ErrorMatches, `cannot parse relative offset "\+12": missing structure name`)
+	_, err = gadget.ParseRelativeOffset("foo+bar")
+	c.Check(err, ErrorMatches, `cannot parse relative offset "foo\+bar": cannot parse size "bar": invalid number`)
+}
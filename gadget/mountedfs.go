@@ -0,0 +1,257 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package gadget
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+// mountedFilesystemUpdater updates the content of a structure with a
+// filesystem by copying files into the location where the filesystem is
+// mounted.
+type mountedFilesystemUpdater struct {
+	ps          *LaidOutStructure
+	contentDir  string
+	rollbackDir string
+	mountPoint  string
+}
+
+// fileUpdate describes the update of a single file, src is an absolute
+// path to the new content while dst is relative to the mount point.
+type fileUpdate struct {
+	src string
+	dst string
+}
+
+func newMountedFilesystemUpdater(ps *LaidOutStructure, contentDir, rollbackDir string) (*mountedFilesystemUpdater, error) {
+	if rollbackDir == "" {
+		return nil, errors.New("internal error: backup directory cannot be unset")
+	}
+	mountPoint, err := FindMountPointForStructure(ps)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find mount location of structure %v: %v", ps, err)
+	}
+	return &mountedFilesystemUpdater{
+		ps:          ps,
+		contentDir:  contentDir,
+		rollbackDir: rollbackDir,
+		mountPoint:  mountPoint,
+	}, nil
+}
+
+// resolveFileUpdates resolves the content of the structure into a list of
+// files to copy. A source ending with a slash denotes the contents of a
+// directory, a target ending with a slash denotes a directory the source
+// is copied into.
+func resolveFileUpdates(ps *LaidOutStructure, contentDir string) ([]fileUpdate, error) {
+	var updates []fileUpdate
+	for _, content := range ps.Content {
+		if content.Source == "" || content.Target == "" {
+			return nil, fmt.Errorf("internal error: source and target must be set for content of structure %v", ps)
+		}
+		if !validTargetPath(content.Target) {
+			return nil, fmt.Errorf("cannot use content target %q: outside of the filesystem of structure %v", content.Target, ps)
+		}
+		src := filepath.Join(contentDir, content.Source)
+		dst := content.Target
+		st, err := os.Stat(src)
+		if err != nil {
+			return nil, fmt.Errorf("cannot use content source %q: %v", content.Source, err)
+		}
+		if !st.IsDir() {
+			if strings.HasSuffix(dst, "/") {
+				dst = filepath.Join(dst, filepath.Base(src))
+			}
+			updates = append(updates, fileUpdate{src: src, dst: filepath.Clean(dst)})
+			continue
+		}
+		if !strings.HasSuffix(content.Source, "/") {
+			// copy the directory itself
+			dst = filepath.Join(dst, filepath.Base(src))
+		}
+		err = filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(src, path)
+			if err != nil {
+				return err
+			}
+			updates = append(updates, fileUpdate{src: path, dst: filepath.Join(dst, rel)})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("cannot list content source %q: %v", content.Source, err)
+		}
+	}
+	return updates, nil
+}
+
+func (f *mountedFilesystemUpdater) fileUpdates() ([]fileUpdate, error) {
+	return resolveFileUpdates(f.ps, f.contentDir)
+}
+
+// CopyStructureContent copies the content of a structure with a filesystem
+// from contentDir into targetDir, following the same rules as when the
+// structure is updated.
+func CopyStructureContent(ps *LaidOutStructure, contentDir, targetDir string) error {
+	updates, err := resolveFileUpdates(ps, contentDir)
+	if err != nil {
+		return err
+	}
+	for _, u := range updates {
+		target := filepath.Join(targetDir, u.dst)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("cannot create directory for %q: %v", u.dst, err)
+		}
+		if err := osutil.CopyFile(u.src, target, osutil.CopyFlagPreserveAll|osutil.CopyFlagOverwrite); err != nil {
+			return fmt.Errorf("cannot copy %q: %v", u.dst, err)
+		}
+	}
+	return nil
+}
+
+func (f *mountedFilesystemUpdater) isPreserved(dst string) bool {
+	for _, p := range f.ps.Update.Preserve {
+		if filepath.Clean(p) == dst {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *mountedFilesystemUpdater) backupPrefix(dst string) string {
+	return filepath.Join(f.rollbackDir, fmt.Sprintf("struct-%v", f.ps.Index), dst)
+}
+
+func sameContent(a, b string) (bool, error) {
+	dataA, err := ioutil.ReadFile(a)
+	if err != nil {
+		return false, err
+	}
+	dataB, err := ioutil.ReadFile(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(dataA, dataB), nil
+}
+
+// Backup saves copies of the files that are about to be overwritten and
+// records which files are going to be created.
+func (f *mountedFilesystemUpdater) Backup() error {
+	updates, err := f.fileUpdates()
+	if err != nil {
+		return err
+	}
+	for _, u := range updates {
+		target := filepath.Join(f.mountPoint, u.dst)
+		prefix := f.backupPrefix(u.dst)
+		if err := os.MkdirAll(filepath.Dir(prefix), 0755); err != nil {
+			return fmt.Errorf("cannot create backup directory: %v", err)
+		}
+		if !osutil.FileExists(target) {
+			if err := osutil.AtomicWriteFile(prefix+".new", nil, 0644, 0); err != nil {
+				return fmt.Errorf("cannot backup %q: %v", u.dst, err)
+			}
+			continue
+		}
+		if f.isPreserved(u.dst) {
+			continue
+		}
+		same, err := sameContent(u.src, target)
+		if err != nil {
+			return fmt.Errorf("cannot backup %q: %v", u.dst, err)
+		}
+		if same {
+			if err := osutil.AtomicWriteFile(prefix+".same", nil, 0644, 0); err != nil {
+				return fmt.Errorf("cannot backup %q: %v", u.dst, err)
+			}
+			continue
+		}
+		if err := osutil.CopyFile(target, prefix+".backup", osutil.CopyFlagPreserveAll|osutil.CopyFlagOverwrite); err != nil {
+			return fmt.Errorf("cannot backup %q: %v", u.dst, err)
+		}
+	}
+	return nil
+}
+
+// Update copies the new content into the mounted filesystem. Files listed
+// in the preserve list of the structure are left untouched if present.
+func (f *mountedFilesystemUpdater) Update() error {
+	updates, err := f.fileUpdates()
+	if err != nil {
+		return err
+	}
+	for _, u := range updates {
+		prefix := f.backupPrefix(u.dst)
+		if osutil.FileExists(prefix + ".same") {
+			continue
+		}
+		target := filepath.Join(f.mountPoint, u.dst)
+		if !osutil.FileExists(prefix+".backup") && !osutil.FileExists(prefix+".new") {
+			if f.isPreserved(u.dst) {
+				continue
+			}
+			return fmt.Errorf("missing backup file for %q", u.dst)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("cannot create directory for %q: %v", u.dst, err)
+		}
+		if err := osutil.CopyFile(u.src, target, osutil.CopyFlagOverwrite|osutil.CopyFlagSync); err != nil {
+			return fmt.Errorf("cannot copy %q: %v", u.dst, err)
+		}
+	}
+	return nil
+}
+
+// Rollback restores the files that were overwritten and removes the ones
+// that were created by the update.
+func (f *mountedFilesystemUpdater) Rollback() error {
+	updates, err := f.fileUpdates()
+	if err != nil {
+		return err
+	}
+	for _, u := range updates {
+		dst := u.dst
+		prefix := f.backupPrefix(dst)
+		target := filepath.Join(f.mountPoint, dst)
+		switch {
+		case osutil.FileExists(prefix + ".backup"):
+			if err := osutil.CopyFile(prefix+".backup", target, osutil.CopyFlagPreserveAll|osutil.CopyFlagOverwrite|osutil.CopyFlagSync); err != nil {
+				return fmt.Errorf("cannot restore backup of %q: %v", dst, err)
+			}
+		case osutil.FileExists(prefix + ".new"):
+			if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("cannot remove %q: %v", dst, err)
+			}
+		}
+	}
+	return nil
+}
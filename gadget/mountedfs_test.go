@@ -0,0 +1,207 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package gadget_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type mountedfsTestSuite struct {
+	dir         string
+	contentDir  string
+	rollbackDir string
+	mountDir    string
+
+	restore func()
+}
+
+var _ = Suite(&mountedfsTestSuite{})
+
+func (s *mountedfsTestSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+	dirs.SetRootDir(s.dir)
+	s.contentDir = filepath.Join(s.dir, "content")
+	s.rollbackDir = filepath.Join(s.dir, "rollback")
+	s.mountDir = filepath.Join(s.dir, "boot")
+	for _, p := range []string{s.contentDir, s.mountDir, filepath.Join(s.dir, "/dev/disk/by-label")} {
+		c.Assert(os.MkdirAll(p, 0755), IsNil)
+	}
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, "/dev/sda1"), nil, 0644), IsNil)
+	c.Assert(os.Symlink("../../sda1", filepath.Join(s.dir, "/dev/disk/by-label/system-boot")), IsNil)
+
+	mountInfo := filepath.Join(s.dir, "mountinfo")
+	content := fmt.Sprintf("170 27 8:1 / %s rw,relatime shared:58 - vfat %s rw\n", s.mountDir, filepath.Join(s.dir, "/dev/sda1"))
+	c.Assert(ioutil.WriteFile(mountInfo, []byte(content), 0644), IsNil)
+	s.restore = gadget.MockProcSelfMountInfo(mountInfo)
+}
+
+func (s *mountedfsTestSuite) TearDownTest(c *C) {
+	s.restore()
+	dirs.SetRootDir("/")
+}
+
+func makeFiles(c *C, root string, files map[string]string) {
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		c.Assert(os.MkdirAll(filepath.Dir(p), 0755), IsNil)
+		c.Assert(ioutil.WriteFile(p, []byte(content), 0644), IsNil)
+	}
+}
+
+func (s *mountedfsTestSuite) structure(content []snap.VolumeContent, preserve []string) *gadget.LaidOutStructure {
+	return &gadget.LaidOutStructure{
+		VolumeStructure: &snap.VolumeStructure{
+			Label:      "system-boot",
+			Filesystem: "vfat",
+			Type:       "EF",
+			Content:    content,
+			Update:     snap.VolumeUpdate{Edition: 1, Preserve: preserve},
+		},
+		Index: 1,
+	}
+}
+
+func (s *mountedfsTestSuite) TestMountedUpdaterBackupUpdateRollback(c *C) {
+	makeFiles(c, s.contentDir, map[string]string{
+		"grub.conf":            "new grub.conf",
+		"EFI/boot/bootx64.efi": "new shim",
+		"EFI/boot/grubx64.efi": "same grub",
+		"EFI/ubuntu/grubenv":   "new grubenv",
+	})
+	makeFiles(c, s.mountDir, map[string]string{
+		"grub.conf":            "old grub.conf",
+		"EFI/boot/bootx64.efi": "old shim",
+		"EFI/boot/grubx64.efi": "same grub",
+		"EFI/ubuntu/grubenv":   "old grubenv",
+		"unrelated":            "unrelated",
+	})
+
+	ps := s.structure([]snap.VolumeContent{
+		{Source: "grub.conf", Target: "/"},
+		{Source: "EFI/", Target: "/EFI/"},
+	}, []string{"/EFI/ubuntu/grubenv"})
+	mu, err := gadget.NewMountedFilesystemUpdater(ps, s.contentDir, s.rollbackDir)
+	c.Assert(err, IsNil)
+
+	c.Assert(mu.Backup(), IsNil)
+	c.Check(filepath.Join(s.rollbackDir, "struct-1/grub.conf.backup"), testutil.FileEquals, "old grub.conf")
+	c.Check(filepath.Join(s.rollbackDir, "struct-1/EFI/boot/bootx64.efi.backup"), testutil.FileEquals, "old shim")
+	c.Check(osutil.FileExists(filepath.Join(s.rollbackDir, "struct-1/EFI/boot/grubx64.efi.same")), Equals, true)
+	c.Check(osutil.FileExists(filepath.Join(s.rollbackDir, "struct-1/EFI/ubuntu/grubenv.backup")), Equals, false)
+
+	c.Assert(mu.Update(), IsNil)
+	c.Check(filepath.Join(s.mountDir, "grub.conf"), testutil.FileEquals, "new grub.conf")
+	c.Check(filepath.Join(s.mountDir, "EFI/boot/bootx64.efi"), testutil.FileEquals, "new shim")
+	c.Check(filepath.Join(s.mountDir, "EFI/boot/grubx64.efi"), testutil.FileEquals, "same grub")
+	c.Check(filepath.Join(s.mountDir, "EFI/ubuntu/grubenv"), testutil.FileEquals, "old grubenv")
+	c.Check(filepath.Join(s.mountDir, "unrelated"), testutil.FileEquals, "unrelated")
+
+	c.Assert(mu.Rollback(), IsNil)
+	c.Check(filepath.Join(s.mountDir, "grub.conf"), testutil.FileEquals, "old grub.conf")
+	c.Check(filepath.Join(s.mountDir, "EFI/boot/bootx64.efi"), testutil.FileEquals, "old shim")
+	c.Check(filepath.Join(s.mountDir, "EFI/boot/grubx64.efi"), testutil.FileEquals, "same grub")
+	c.Check(filepath.Join(s.mountDir, "EFI/ubuntu/grubenv"), testutil.FileEquals, "old grubenv")
+}
+
+func (s *mountedfsTestSuite) TestMountedUpdaterNewFilesRemovedOnRollback(c *C) {
+	makeFiles(c, s.contentDir, map[string]string{
+		"boot-assets/splash.bmp": "splash",
+		"config.txt":             "config",
+	})
+
+	ps := s.structure([]snap.VolumeContent{
+		{Source: "boot-assets", Target: "/"},
+		{Source: "config.txt", Target: "/firmware-config.txt"},
+	}, nil)
+	mu, err := gadget.NewMountedFilesystemUpdater(ps, s.contentDir, s.rollbackDir)
+	c.Assert(err, IsNil)
+
+	c.Assert(mu.Backup(), IsNil)
+	c.Assert(mu.Update(), IsNil)
+	c.Check(filepath.Join(s.mountDir, "boot-assets/splash.bmp"), testutil.FileEquals, "splash")
+	c.Check(filepath.Join(s.mountDir, "firmware-config.txt"), testutil.FileEquals, "config")
+
+	c.Assert(mu.Rollback(), IsNil)
+	c.Check(osutil.FileExists(filepath.Join(s.mountDir, "boot-assets/splash.bmp")), Equals, false)
+	c.Check(osutil.FileExists(filepath.Join(s.mountDir, "firmware-config.txt")), Equals, false)
+}
+
+func (s *mountedfsTestSuite) TestMountedUpdaterErrors(c *C) {
+	ps := s.structure([]snap.VolumeContent{{Source: "missing", Target: "/"}}, nil)
+	mu, err := gadget.NewMountedFilesystemUpdater(ps, s.contentDir, s.rollbackDir)
+	c.Assert(err, IsNil)
+	c.Check(mu.Backup(), ErrorMatches, `cannot use content source "missing": .* no such file or directory`)
+
+	makeFiles(c, s.contentDir, map[string]string{"foo": "foo"})
+	ps = s.structure([]snap.VolumeContent{{Source: "foo", Target: "/foo"}}, nil)
+	mu, err = gadget.NewMountedFilesystemUpdater(ps, s.contentDir, s.rollbackDir)
+	c.Assert(err, IsNil)
+	c.Check(mu.Update(), ErrorMatches, `missing backup file for "/foo"`)
+
+	for _, target := range []string{"../foo", "/../../etc/foo", "/EFI/../../foo"} {
+		ps = s.structure([]snap.VolumeContent{{Source: "foo", Target: target}}, nil)
+		mu, err = gadget.NewMountedFilesystemUpdater(ps, s.contentDir, s.rollbackDir)
+		c.Assert(err, IsNil)
+		expected := fmt.Sprintf(`cannot use content target %q: outside of the filesystem of structure #1`, target)
+		c.Check(mu.Backup(), ErrorMatches, expected)
+		c.Check(mu.Update(), ErrorMatches, expected)
+		c.Check(mu.Rollback(), ErrorMatches, expected)
+		c.Check(gadget.CopyStructureContent(ps, s.contentDir, c.MkDir()), ErrorMatches, expected)
+	}
+	c.Check(osutil.FileExists(filepath.Join(s.dir, "foo")), Equals, false)
+
+	_, err = gadget.NewMountedFilesystemUpdater(ps, s.contentDir, "")
+	c.Check(err, ErrorMatches, `internal error: backup directory cannot be unset`)
+
+	ps.Label = "other"
+	_, err = gadget.NewMountedFilesystemUpdater(ps, s.contentDir, s.rollbackDir)
+	c.Check(err, ErrorMatches, `cannot find mount location of structure #1: device not found`)
+}
+
+func (s *mountedfsTestSuite) TestCopyStructureContent(c *C) {
+	makeFiles(c, s.contentDir, map[string]string{
+		"grub.conf":            "grub.conf",
+		"EFI/boot/bootx64.efi": "shim",
+	})
+	ps := s.structure([]snap.VolumeContent{
+		{Source: "grub.conf", Target: "/boot/"},
+		{Source: "EFI", Target: "/"},
+	}, nil)
+
+	target := c.MkDir()
+	err := gadget.CopyStructureContent(ps, s.contentDir, target)
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(target, "boot/grub.conf"), testutil.FileEquals, "grub.conf")
+	c.Check(filepath.Join(target, "EFI/boot/bootx64.efi"), testutil.FileEquals, "shim")
+
+	ps = s.structure([]snap.VolumeContent{{Source: "missing", Target: "/"}}, nil)
+	err = gadget.CopyStructureContent(ps, s.contentDir, target)
+	c.Check(err, ErrorMatches, `cannot use content source "missing": .* no such file or directory`)
+}
@@ -0,0 +1,182 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package gadget
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+// rawStructureUpdater updates the raw images of a structure without a
+// filesystem by writing them directly to the block device.
+type rawStructureUpdater struct {
+	ps          *LaidOutStructure
+	contentDir  string
+	rollbackDir string
+	// device is the block device node the images are written to,
+	// deviceOffset is the offset of the device within the volume
+	device       string
+	deviceOffset Size
+}
+
+func newRawStructureUpdater(ps *LaidOutStructure, lv *LaidOutVolume, rollbackDir string) (*rawStructureUpdater, error) {
+	if rollbackDir == "" {
+		return nil, errors.New("internal error: backup directory cannot be unset")
+	}
+	var device string
+	var deviceOffset Size
+	var err error
+	if IsPartition(ps.VolumeStructure) {
+		device, err = FindDeviceForStructure(ps)
+		deviceOffset = ps.StartOffset
+	} else {
+		device, err = FindDiskForVolume(lv)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot find device matching structure %v: %v", ps, err)
+	}
+	return &rawStructureUpdater{
+		ps:           ps,
+		contentDir:   lv.RootDir,
+		rollbackDir:  rollbackDir,
+		device:       device,
+		deviceOffset: deviceOffset,
+	}, nil
+}
+
+func (r *rawStructureUpdater) backupPath(pc *LaidOutContent) string {
+	return filepath.Join(r.rollbackDir, fmt.Sprintf("struct-%v-%v", r.ps.Index, pc.Index))
+}
+
+func readRegion(f *os.File, offset, size Size) ([]byte, error) {
+	buf := make([]byte, size)
+	n, err := f.ReadAt(buf, int64(offset))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// Backup saves the current content of the areas of the device that are
+// about to be overwritten. Areas that already hold the new images are
+// marked as such and skipped during the update.
+func (r *rawStructureUpdater) Backup() error {
+	if err := os.MkdirAll(r.rollbackDir, 0755); err != nil {
+		return fmt.Errorf("cannot create backup directory: %v", err)
+	}
+	dev, err := os.Open(r.device)
+	if err != nil {
+		return fmt.Errorf("cannot open device for reading: %v", err)
+	}
+	defer dev.Close()
+
+	for idx := range r.ps.LaidOutContent {
+		pc := &r.ps.LaidOutContent[idx]
+		image, err := ioutil.ReadFile(filepath.Join(r.contentDir, pc.Image))
+		if err != nil {
+			return fmt.Errorf("cannot read image %q: %v", pc.Image, err)
+		}
+		current, err := readRegion(dev, pc.StartOffset-r.deviceOffset, Size(len(image)))
+		if err != nil {
+			return fmt.Errorf("cannot backup image %v: %v", pc, err)
+		}
+		backup := r.backupPath(pc)
+		if bytes.Equal(current, image) {
+			if err := osutil.AtomicWriteFile(backup+".same", nil, 0644, 0); err != nil {
+				return fmt.Errorf("cannot backup image %v: %v", pc, err)
+			}
+			continue
+		}
+		if err := osutil.AtomicWriteFile(backup+".backup", current, 0644, 0); err != nil {
+			return fmt.Errorf("cannot backup image %v: %v", pc, err)
+		}
+	}
+	return nil
+}
+
+func writeRegion(dev *os.File, offset Size, data []byte) error {
+	if _, err := dev.WriteAt(data, int64(offset)); err != nil {
+		return err
+	}
+	return dev.Sync()
+}
+
+// Update writes the new images to the device.
+func (r *rawStructureUpdater) Update() error {
+	dev, err := os.OpenFile(r.device, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("cannot open device for writing: %v", err)
+	}
+	defer dev.Close()
+
+	for idx := range r.ps.LaidOutContent {
+		pc := &r.ps.LaidOutContent[idx]
+		backup := r.backupPath(pc)
+		if osutil.FileExists(backup + ".same") {
+			continue
+		}
+		if !osutil.FileExists(backup + ".backup") {
+			return fmt.Errorf("missing backup file for image %v", pc)
+		}
+		image, err := ioutil.ReadFile(filepath.Join(r.contentDir, pc.Image))
+		if err != nil {
+			return fmt.Errorf("cannot read image %q: %v", pc.Image, err)
+		}
+		if err := writeRegion(dev, pc.StartOffset-r.deviceOffset, image); err != nil {
+			return fmt.Errorf("cannot write image %v: %v", pc, err)
+		}
+	}
+	return nil
+}
+
+// Rollback restores the content of the device from the backup.
+func (r *rawStructureUpdater) Rollback() error {
+	dev, err := os.OpenFile(r.device, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("cannot open device for writing: %v", err)
+	}
+	defer dev.Close()
+
+	for idx := range r.ps.LaidOutContent {
+		pc := &r.ps.LaidOutContent[idx]
+		backup := r.backupPath(pc)
+		if osutil.FileExists(backup + ".same") {
+			continue
+		}
+		data, err := ioutil.ReadFile(backup + ".backup")
+		if os.IsNotExist(err) {
+			// never backed up, never written
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("cannot read backup of image %v: %v", pc, err)
+		}
+		if err := writeRegion(dev, pc.StartOffset-r.deviceOffset, data); err != nil {
+			return fmt.Errorf("cannot restore image %v: %v", pc, err)
+		}
+	}
+	return nil
+}
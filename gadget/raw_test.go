@@ -0,0 +1,131 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package gadget_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/snap"
+)
+
+type rawTestSuite struct {
+	dir         string
+	contentDir  string
+	rollbackDir string
+	disk        string
+}
+
+var _ = Suite(&rawTestSuite{})
+
+func (r *rawTestSuite) SetUpTest(c *C) {
+	r.dir = c.MkDir()
+	dirs.SetRootDir(r.dir)
+	r.contentDir = filepath.Join(r.dir, "content")
+	r.rollbackDir = filepath.Join(r.dir, "rollback")
+	c.Assert(os.MkdirAll(r.contentDir, 0755), IsNil)
+
+	// a disk with a single partition
+	for _, p := range []string{"/dev/disk/by-partlabel", "/sys/devices/pci/block/sda/sda1", "/sys/class/block"} {
+		c.Assert(os.MkdirAll(filepath.Join(r.dir, p), 0755), IsNil)
+	}
+	r.disk = filepath.Join(r.dir, "/dev/sda")
+	c.Assert(ioutil.WriteFile(r.disk, bytes.Repeat([]byte{'x'}, 4096), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(r.dir, "/dev/sda1"), nil, 0644), IsNil)
+	c.Assert(os.Symlink("../../sda1", filepath.Join(r.dir, "/dev/disk/by-partlabel/writable")), IsNil)
+	c.Assert(os.Symlink("../../devices/pci/block/sda/sda1", filepath.Join(r.dir, "/sys/class/block/sda1")), IsNil)
+}
+
+func (r *rawTestSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("/")
+}
+
+func (r *rawTestSuite) layout(c *C) *gadget.LaidOutVolume {
+	vol := &snap.GadgetVolume{
+		Structure: []snap.VolumeStructure{
+			{Name: "bootloader", Type: "bare", Offset: "100", Size: "1000", Content: []snap.VolumeContent{
+				{Image: "foo.img"},
+				{Image: "bar.img", Offset: "500"},
+			}},
+			{Name: "writable", Type: "83", Offset: "2000", Size: "1000", Filesystem: "ext4"},
+		},
+	}
+	lv, err := gadget.LayoutVolume(r.contentDir, vol)
+	c.Assert(err, IsNil)
+	return lv
+}
+
+func (r *rawTestSuite) TestRawUpdaterBackupUpdateRollback(c *C) {
+	c.Assert(ioutil.WriteFile(filepath.Join(r.contentDir, "foo.img"), bytes.Repeat([]byte{'f'}, 10), 0644), IsNil)
+	// bar.img is already in place
+	c.Assert(ioutil.WriteFile(filepath.Join(r.contentDir, "bar.img"), bytes.Repeat([]byte{'x'}, 10), 0644), IsNil)
+
+	lv := r.layout(c)
+	ru, err := gadget.NewRawStructureUpdater(&lv.LaidOutStructure[0], lv, r.rollbackDir)
+	c.Assert(err, IsNil)
+
+	c.Assert(ru.Backup(), IsNil)
+	backup, err := ioutil.ReadFile(filepath.Join(r.rollbackDir, "struct-0-0.backup"))
+	c.Assert(err, IsNil)
+	c.Check(backup, DeepEquals, bytes.Repeat([]byte{'x'}, 10))
+	c.Check(osutil.FileExists(filepath.Join(r.rollbackDir, "struct-0-1.same")), Equals, true)
+
+	c.Assert(ru.Update(), IsNil)
+	data, err := ioutil.ReadFile(r.disk)
+	c.Assert(err, IsNil)
+	c.Check(data[100:110], DeepEquals, bytes.Repeat([]byte{'f'}, 10))
+	c.Check(data[:100], DeepEquals, bytes.Repeat([]byte{'x'}, 100))
+	c.Check(data[110:], DeepEquals, bytes.Repeat([]byte{'x'}, 4096-110))
+
+	c.Assert(ru.Rollback(), IsNil)
+	data, err = ioutil.ReadFile(r.disk)
+	c.Assert(err, IsNil)
+	c.Check(data, DeepEquals, bytes.Repeat([]byte{'x'}, 4096))
+}
+
+func (r *rawTestSuite) TestRawUpdaterUpdateWithoutBackup(c *C) {
+	c.Assert(ioutil.WriteFile(filepath.Join(r.contentDir, "foo.img"), []byte("foo"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(r.contentDir, "bar.img"), []byte("bar"), 0644), IsNil)
+
+	lv := r.layout(c)
+	ru, err := gadget.NewRawStructureUpdater(&lv.LaidOutStructure[0], lv, r.rollbackDir)
+	c.Assert(err, IsNil)
+	err = ru.Update()
+	c.Check(err, ErrorMatches, `missing backup file for image #0 \("foo.img"@0x64\{3\}\)`)
+}
+
+func (r *rawTestSuite) TestRawUpdaterNoDevice(c *C) {
+	c.Assert(ioutil.WriteFile(filepath.Join(r.contentDir, "foo.img"), []byte("foo"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(r.contentDir, "bar.img"), []byte("bar"), 0644), IsNil)
+	c.Assert(os.Remove(filepath.Join(r.dir, "/dev/disk/by-partlabel/writable")), IsNil)
+
+	lv := r.layout(c)
+	_, err := gadget.NewRawStructureUpdater(&lv.LaidOutStructure[0], lv, r.rollbackDir)
+	c.Check(err, ErrorMatches, `cannot find device matching structure #0 \("bootloader"\): device not found`)
+
+	_, err = gadget.NewRawStructureUpdater(&lv.LaidOutStructure[0], lv, "")
+	c.Check(err, ErrorMatches, `internal error: backup directory cannot be unset`)
+}
@@ -0,0 +1,67 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+// Package gadget lays out and updates the volumes described in the
+// gadget.yaml of gadget snaps.
+package gadget
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Size describes a size or an offset in bytes.
+type Size uint64
+
+const (
+	SizeKiB = Size(1 << 10)
+	SizeMiB = Size(1 << 20)
+	SizeGiB = Size(1 << 30)
+)
+
+func (s Size) String() string {
+	return fmt.Sprintf("%d", uint64(s))
+}
+
+// ParseSize parses a size or an offset as used in gadget.yaml: a decimal
+// number of bytes, optionally followed by one of the M or G suffixes for
+// mebibytes and gibibytes respectively.
+func ParseSize(s string) (Size, error) {
+	if s == "" {
+		return 0, fmt.Errorf("cannot parse size: empty value")
+	}
+	unit := Size(1)
+	number := s
+	switch s[len(s)-1] {
+	case 'M':
+		unit = SizeMiB
+		number = s[:len(s)-1]
+	case 'G':
+		unit = SizeGiB
+		number = s[:len(s)-1]
+	}
+	val, err := strconv.ParseUint(number, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse size %q: invalid number", s)
+	}
+	if val > math.MaxUint64/uint64(unit) {
+		return 0, fmt.Errorf("cannot parse size %q: too large", s)
+	}
+	return Size(val) * unit, nil
+}
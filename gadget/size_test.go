@@ -0,0 +1,67 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package gadget_test
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/gadget"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type sizeTestSuite struct{}
+
+var _ = Suite(&sizeTestSuite{})
+
+func (s *sizeTestSuite) TestParseSizeHappy(c *C) {
+	for _, t := range []struct {
+		in  string
+		out gadget.Size
+	}{
+		{"0", 0},
+		{"440", 440},
+		{"1M", gadget.SizeMiB},
+		{"128M", 128 * gadget.SizeMiB},
+		{"2G", 2 * gadget.SizeGiB},
+	} {
+		size, err := gadget.ParseSize(t.in)
+		c.Assert(err, IsNil, Commentf("%q", t.in))
+		c.Check(size, Equals, t.out, Commentf("%q", t.in))
+	}
+}
+
+func (s *sizeTestSuite) TestParseSizeErrors(c *C) {
+	for _, t := range []struct {
+		in  string
+		err string
+	}{
+		{"", "cannot parse size: empty value"},
+		{"M", `cannot parse size "M": invalid number`},
+		{"12K", `cannot parse size "12K": invalid number`},
+		{"-1", `cannot parse size "-1": invalid number`},
+		{"1 M", `cannot parse size "1 M": invalid number`},
+		{"18446744073709551615G", `cannot parse size "18446744073709551615G": too large`},
+	} {
+		_, err := gadget.ParseSize(t.in)
+		c.Check(err, ErrorMatches, t.err, Commentf("%q", t.in))
+	}
+}
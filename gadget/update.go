@@ -0,0 +1,246 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package gadget
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/snap"
+)
+
+// ErrNoUpdate is returned when none of the structures of the new gadget
+// need to be updated.
+var ErrNoUpdate = errors.New("nothing to update")
+
+// GadgetData holds the gadget metadata and the directory where the gadget
+// content is available.
+type GadgetData struct {
+	Info    *snap.GadgetInfo
+	RootDir string
+}
+
+// Updater carries out the update of a single structure.
+type Updater interface {
+	// Backup prepares a backup copy of the data that is about to be
+	// modified by the update.
+	Backup() error
+	// Update applies the update.
+	Update() error
+	// Rollback restores the data from the backup copy.
+	Rollback() error
+}
+
+var updaterForStructure = updaterForStructureImpl
+
+func updaterForStructureImpl(ps *LaidOutStructure, lv *LaidOutVolume, rollbackDir string) (Updater, error) {
+	if HasFilesystem(ps.VolumeStructure) {
+		return newMountedFilesystemUpdater(ps, lv.RootDir, rollbackDir)
+	}
+	return newRawStructureUpdater(ps, lv, rollbackDir)
+}
+
+// Update applies the gadget update from the old to the new gadget. Only
+// structures with a higher update edition in the new gadget are updated.
+// The data that is about to be overwritten is first backed up under
+// rollbackDir and restored if any of the updates fails. ErrNoUpdate is
+// returned when no structure needs updating.
+func Update(old, new GadgetData, rollbackDir string) error {
+	toUpdate, newLv, err := structuresToUpdate(old, new)
+	if err != nil {
+		return err
+	}
+	return applyUpdates(toUpdate, newLv, rollbackDir)
+}
+
+// Rollback undoes a successful Update from the old to the new gadget,
+// restoring the structures from the backup kept under rollbackDir.
+// ErrNoUpdate is returned when no structure was subject to the update.
+func Rollback(old, new GadgetData, rollbackDir string) error {
+	toUpdate, newLv, err := structuresToUpdate(old, new)
+	if err != nil {
+		return err
+	}
+	updaters, err := prepareUpdaters(toUpdate, newLv, rollbackDir)
+	if err != nil {
+		return err
+	}
+	var rollbackErr error
+	for i := len(updaters) - 1; i >= 0; i-- {
+		if err := updaters[i].Rollback(); err != nil {
+			logger.Noticef("cannot rollback volume structure %v update: %v", toUpdate[i], err)
+			if rollbackErr == nil {
+				rollbackErr = fmt.Errorf("cannot rollback volume structure %v update: %v", toUpdate[i], err)
+			}
+		}
+	}
+	return rollbackErr
+}
+
+func structuresToUpdate(old, new GadgetData) ([]*LaidOutStructure, *LaidOutVolume, error) {
+	oldVol, newVol, err := resolveVolume(old.Info, new.Info)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	oldLv, err := LayoutVolume(old.RootDir, oldVol)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot lay out the old volume: %v", err)
+	}
+	newLv, err := LayoutVolume(new.RootDir, newVol)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot lay out the new volume: %v", err)
+	}
+
+	if err := canUpdateVolume(oldLv, newLv); err != nil {
+		return nil, nil, fmt.Errorf("cannot apply update to volume: %v", err)
+	}
+
+	toUpdate := resolveUpdate(oldLv, newLv)
+	if len(toUpdate) == 0 {
+		return nil, nil, ErrNoUpdate
+	}
+	return toUpdate, newLv, nil
+}
+
+func resolveVolume(old, new *snap.GadgetInfo) (oldVol, newVol *snap.GadgetVolume, err error) {
+	// TODO: support multiple volumes
+	if len(old.Volumes) != 1 || len(new.Volumes) != 1 {
+		return nil, nil, errors.New("cannot update with more than one volume")
+	}
+	var name string
+	for n := range old.Volumes {
+		name = n
+	}
+	oldV := old.Volumes[name]
+	newV, ok := new.Volumes[name]
+	if !ok {
+		return nil, nil, fmt.Errorf("cannot find entry for volume %q in updated gadget info", name)
+	}
+	return &oldV, &newV, nil
+}
+
+func canUpdateVolume(from, to *LaidOutVolume) error {
+	if from.Schema != to.Schema {
+		return fmt.Errorf("cannot change volume schema from %q to %q", from.Schema, to.Schema)
+	}
+	if from.ID != to.ID {
+		return fmt.Errorf("cannot change volume ID from %q to %q", from.ID, to.ID)
+	}
+	if len(from.LaidOutStructure) != len(to.LaidOutStructure) {
+		return fmt.Errorf("cannot change the number of structures within volume from %v to %v", len(from.LaidOutStructure), len(to.LaidOutStructure))
+	}
+	for idx := range from.LaidOutStructure {
+		if err := canUpdateStructure(&from.LaidOutStructure[idx], &to.LaidOutStructure[idx]); err != nil {
+			return fmt.Errorf("cannot update volume structure %v: %v", to.LaidOutStructure[idx], err)
+		}
+	}
+	return nil
+}
+
+func canUpdateStructure(from, to *LaidOutStructure) error {
+	switch {
+	case from.Name != to.Name:
+		return fmt.Errorf("cannot change structure name from %q to %q", from.Name, to.Name)
+	case from.StartOffset != to.StartOffset:
+		return fmt.Errorf("cannot change structure offset from %v to %v", from.StartOffset, to.StartOffset)
+	case from.Size != to.Size:
+		return fmt.Errorf("cannot change structure size from %v to %v", from.Size, to.Size)
+	case from.OffsetWrite != to.OffsetWrite:
+		return fmt.Errorf("cannot change structure offset-write from %q to %q", from.OffsetWrite, to.OffsetWrite)
+	case from.Role != to.Role:
+		return fmt.Errorf("cannot change structure role from %q to %q", from.Role, to.Role)
+	case from.Type != to.Type:
+		return fmt.Errorf("cannot change structure type from %q to %q", from.Type, to.Type)
+	case from.ID != to.ID:
+		return fmt.Errorf("cannot change structure ID from %q to %q", from.ID, to.ID)
+	}
+	if HasFilesystem(from.VolumeStructure) != HasFilesystem(to.VolumeStructure) {
+		return errors.New("cannot change a filesystem structure to a bare one or vice versa")
+	}
+	if HasFilesystem(from.VolumeStructure) {
+		if from.Filesystem != to.Filesystem {
+			return fmt.Errorf("cannot change filesystem from %q to %q", from.Filesystem, to.Filesystem)
+		}
+		if from.Label != to.Label {
+			return fmt.Errorf("cannot change filesystem label from %q to %q", from.Label, to.Label)
+		}
+	}
+	return nil
+}
+
+func resolveUpdate(oldVol, newVol *LaidOutVolume) []*LaidOutStructure {
+	var toUpdate []*LaidOutStructure
+	for idx := range oldVol.LaidOutStructure {
+		oldStruct := &oldVol.LaidOutStructure[idx]
+		newStruct := &newVol.LaidOutStructure[idx]
+		if newStruct.Update.Edition > oldStruct.Update.Edition {
+			toUpdate = append(toUpdate, newStruct)
+		}
+	}
+	return toUpdate
+}
+
+func prepareUpdaters(toUpdate []*LaidOutStructure, lv *LaidOutVolume, rollbackDir string) ([]Updater, error) {
+	updaters := make([]Updater, len(toUpdate))
+	for i, ps := range toUpdate {
+		up, err := updaterForStructure(ps, lv, rollbackDir)
+		if err != nil {
+			return nil, fmt.Errorf("cannot prepare update for volume structure %v: %v", ps, err)
+		}
+		updaters[i] = up
+	}
+	return updaters, nil
+}
+
+func applyUpdates(toUpdate []*LaidOutStructure, lv *LaidOutVolume, rollbackDir string) error {
+	updaters, err := prepareUpdaters(toUpdate, lv, rollbackDir)
+	if err != nil {
+		return err
+	}
+
+	for i, up := range updaters {
+		if err := up.Backup(); err != nil {
+			return fmt.Errorf("cannot backup volume structure %v: %v", toUpdate[i], err)
+		}
+	}
+
+	var updateErr error
+	var updateLastAttempted int
+	for i, up := range updaters {
+		updateLastAttempted = i
+		if err := up.Update(); err != nil {
+			updateErr = fmt.Errorf("cannot update volume structure %v: %v", toUpdate[i], err)
+			break
+		}
+	}
+	if updateErr == nil {
+		return nil
+	}
+
+	// roll back the structures that were (partially) updated, most
+	// recent first
+	for i := updateLastAttempted; i >= 0; i-- {
+		if err := updaters[i].Rollback(); err != nil {
+			logger.Noticef("cannot rollback volume structure %v update: %v", toUpdate[i], err)
+		}
+	}
+	return updateErr
+}
@@ -0,0 +1,224 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package gadget_test
+
+import (
+	"errors"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/snap"
+)
+
+type updateTestSuite struct{}
+
+var _ = Suite(&updateTestSuite{})
+
+type mockUpdater struct {
+	name        string
+	log         *[]string
+	updateErr   error
+	backupErr   error
+	rollbackErr error
+}
+
+func (m *mockUpdater) Backup() error {
+	*m.log = append(*m.log, "backup:"+m.name)
+	return m.backupErr
+}
+
+func (m *mockUpdater) Update() error {
+	*m.log = append(*m.log, "update:"+m.name)
+	return m.updateErr
+}
+
+func (m *mockUpdater) Rollback() error {
+	*m.log = append(*m.log, "rollback:"+m.name)
+	return m.rollbackErr
+}
+
+func gadgetData(editions ...uint32) gadget.GadgetData {
+	vol := snap.GadgetVolume{
+		Bootloader: "grub",
+		Structure: []snap.VolumeStructure{
+			{Name: "first", Type: "83", Size: "1M", Filesystem: "vfat", Update: snap.VolumeUpdate{Edition: editions[0]}},
+			{Name: "second", Type: "83", Size: "1M", Filesystem: "ext4", Update: snap.VolumeUpdate{Edition: editions[1]}},
+		},
+	}
+	return gadget.GadgetData{
+		Info: &snap.GadgetInfo{Volumes: map[string]snap.GadgetVolume{"pc": vol}},
+	}
+}
+
+func (u *updateTestSuite) TestUpdateNothingToUpdate(c *C) {
+	restore := gadget.MockUpdaterForStructure(func(ps *gadget.LaidOutStructure, lv *gadget.LaidOutVolume, rollbackDir string) (gadget.Updater, error) {
+		c.Fatalf("unexpected call")
+		return nil, nil
+	})
+	defer restore()
+
+	err := gadget.Update(gadgetData(1, 2), gadgetData(1, 2), "/rollback")
+	c.Check(err, Equals, gadget.ErrNoUpdate)
+
+	err = gadget.Update(gadgetData(1, 2), gadgetData(1, 1), "/rollback")
+	c.Check(err, Equals, gadget.ErrNoUpdate)
+}
+
+func (u *updateTestSuite) TestUpdateHappy(c *C) {
+	var log []string
+	restore := gadget.MockUpdaterForStructure(func(ps *gadget.LaidOutStructure, lv *gadget.LaidOutVolume, rollbackDir string) (gadget.Updater, error) {
+		c.Check(rollbackDir, Equals, "/rollback")
+		c.Check(lv.LaidOutStructure, HasLen, 2)
+		return &mockUpdater{name: ps.Name, log: &log}, nil
+	})
+	defer restore()
+
+	err := gadget.Update(gadgetData(0, 0), gadgetData(1, 1), "/rollback")
+	c.Assert(err, IsNil)
+	c.Check(log, DeepEquals, []string{"backup:first", "backup:second", "update:first", "update:second"})
+
+	log = nil
+	err = gadget.Update(gadgetData(0, 0), gadgetData(0, 1), "/rollback")
+	c.Assert(err, IsNil)
+	c.Check(log, DeepEquals, []string{"backup:second", "update:second"})
+}
+
+func (u *updateTestSuite) TestUpdateRollbackOnError(c *C) {
+	var log []string
+	restore := gadget.MockUpdaterForStructure(func(ps *gadget.LaidOutStructure, lv *gadget.LaidOutVolume, rollbackDir string) (gadget.Updater, error) {
+		up := &mockUpdater{name: ps.Name, log: &log}
+		if ps.Name == "second" {
+			up.updateErr = errors.New("failed")
+			up.rollbackErr = errors.New("rollback failed")
+		}
+		return up, nil
+	})
+	defer restore()
+
+	err := gadget.Update(gadgetData(0, 0), gadgetData(1, 1), "/rollback")
+	c.Assert(err, ErrorMatches, `cannot update volume structure #1 \("second"\): failed`)
+	c.Check(log, DeepEquals, []string{
+		"backup:first", "backup:second",
+		"update:first", "update:second",
+		"rollback:second", "rollback:first",
+	})
+}
+
+func (u *updateTestSuite) TestRollbackHappy(c *C) {
+	var log []string
+	restore := gadget.MockUpdaterForStructure(func(ps *gadget.LaidOutStructure, lv *gadget.LaidOutVolume, rollbackDir string) (gadget.Updater, error) {
+		c.Check(rollbackDir, Equals, "/rollback")
+		return &mockUpdater{name: ps.Name, log: &log}, nil
+	})
+	defer restore()
+
+	err := gadget.Rollback(gadgetData(0, 0), gadgetData(1, 1), "/rollback")
+	c.Assert(err, IsNil)
+	c.Check(log, DeepEquals, []string{"rollback:second", "rollback:first"})
+
+	log = nil
+	err = gadget.Rollback(gadgetData(0, 0), gadgetData(0, 1), "/rollback")
+	c.Assert(err, IsNil)
+	c.Check(log, DeepEquals, []string{"rollback:second"})
+
+	err = gadget.Rollback(gadgetData(1, 1), gadgetData(1, 1), "/rollback")
+	c.Check(err, Equals, gadget.ErrNoUpdate)
+}
+
+func (u *updateTestSuite) TestRollbackError(c *C) {
+	var log []string
+	restore := gadget.MockUpdaterForStructure(func(ps *gadget.LaidOutStructure, lv *gadget.LaidOutVolume, rollbackDir string) (gadget.Updater, error) {
+		up := &mockUpdater{name: ps.Name, log: &log}
+		if ps.Name == "second" {
+			up.rollbackErr = errors.New("failed")
+		}
+		return up, nil
+	})
+	defer restore()
+
+	// all structures are rolled back even if one of them fails
+	err := gadget.Rollback(gadgetData(0, 0), gadgetData(1, 1), "/rollback")
+	c.Assert(err, ErrorMatches, `cannot rollback volume structure #1 \("second"\) update: failed`)
+	c.Check(log, DeepEquals, []string{"rollback:second", "rollback:first"})
+}
+
+func (u *updateTestSuite) TestUpdateBackupError(c *C) {
+	var log []string
+	restore := gadget.MockUpdaterForStructure(func(ps *gadget.LaidOutStructure, lv *gadget.LaidOutVolume, rollbackDir string) (gadget.Updater, error) {
+		return &mockUpdater{name: ps.Name, log: &log, backupErr: errors.New("no space")}, nil
+	})
+	defer restore()
+
+	err := gadget.Update(gadgetData(0, 0), gadgetData(1, 1), "/rollback")
+	c.Assert(err, ErrorMatches, `cannot backup volume structure #0 \("first"\): no space`)
+	c.Check(log, DeepEquals, []string{"backup:first"})
+}
+
+func (u *updateTestSuite) TestUpdaterForStructureError(c *C) {
+	restore := gadget.MockUpdaterForStructure(func(ps *gadget.LaidOutStructure, lv *gadget.LaidOutVolume, rollbackDir string) (gadget.Updater, error) {
+		return nil, errors.New("boom")
+	})
+	defer restore()
+
+	err := gadget.Update(gadgetData(0, 0), gadgetData(1, 1), "/rollback")
+	c.Assert(err, ErrorMatches, `cannot prepare update for volume structure #0 \("first"\): boom`)
+}
+
+func (u *updateTestSuite) TestUpdateIncompatible(c *C) {
+	for _, t := range []struct {
+		mutate func(vs *snap.VolumeStructure)
+		err    string
+	}{
+		{func(vs *snap.VolumeStructure) { vs.Size = "2M" }, `cannot change structure size from 1048576 to 2097152`},
+		{func(vs *snap.VolumeStructure) { vs.Offset = "3M" }, `cannot change structure offset from 2097152 to 3145728`},
+		{func(vs *snap.VolumeStructure) { vs.Name = "other" }, `cannot change structure name from "second" to "other"`},
+		{func(vs *snap.VolumeStructure) { vs.Type = "EF" }, `cannot change structure type from "83" to "EF"`},
+		{func(vs *snap.VolumeStructure) { vs.Filesystem = "vfat" }, `cannot change filesystem from "ext4" to "vfat"`},
+		{func(vs *snap.VolumeStructure) { vs.Label = "foo" }, `cannot change filesystem label from "" to "foo"`},
+		{func(vs *snap.VolumeStructure) { vs.Filesystem = "" }, `cannot change a filesystem structure to a bare one or vice versa`},
+	} {
+		newData := gadgetData(1, 1)
+		vol := newData.Info.Volumes["pc"]
+		t.mutate(&vol.Structure[1])
+		newData.Info.Volumes["pc"] = vol
+
+		err := gadget.Update(gadgetData(0, 0), newData, "/rollback")
+		c.Check(err, ErrorMatches, `cannot apply update to volume: cannot update volume structure #1 \(".*"\): `+t.err)
+	}
+}
+
+func (u *updateTestSuite) TestUpdateVolumeErrors(c *C) {
+	newData := gadgetData(1, 1)
+	vol := newData.Info.Volumes["pc"]
+	vol.Structure = vol.Structure[:1]
+	newData.Info.Volumes["pc"] = vol
+	err := gadget.Update(gadgetData(0, 0), newData, "/rollback")
+	c.Check(err, ErrorMatches, `cannot apply update to volume: cannot change the number of structures within volume from 2 to 1`)
+
+	newData = gadgetData(1, 1)
+	newData.Info.Volumes["other"] = newData.Info.Volumes["pc"]
+	delete(newData.Info.Volumes, "pc")
+	err = gadget.Update(gadgetData(0, 0), newData, "/rollback")
+	c.Check(err, ErrorMatches, `cannot find entry for volume "pc" in updated gadget info`)
+
+	newData.Info.Volumes["pc"] = newData.Info.Volumes["other"]
+	err = gadget.Update(gadgetData(0, 0), newData, "/rollback")
+	c.Check(err, ErrorMatches, `cannot update with more than one volume`)
+}
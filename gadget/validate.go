@@ -0,0 +1,333 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package gadget
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/snap"
+)
+
+const (
+	// MBRSize is the size of the boot code area of the MBR
+	MBRSize = 446
+
+	sectorSize = 512
+)
+
+var (
+	validMBRType = regexp.MustCompile("^[0-9A-Fa-f]{2}$")
+	validGPTType = regexp.MustCompile("^(?i)[0-9A-F]{8}-[0-9A-F]{4}-[0-9A-F]{4}-[0-9A-F]{4}-[0-9A-F]{12}$")
+)
+
+// Validate checks the gadget.yaml of the gadget snap unpacked in gadgetDir
+// and the presence of the content referenced by its volumes.
+func Validate(gadgetDir string) error {
+	gi, err := snap.ReadGadgetInfoFromDir(gadgetDir, false)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(gi.Volumes))
+	for name := range gi.Volumes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		vol := gi.Volumes[name]
+		if err := ValidateVolume(name, &vol); err != nil {
+			return err
+		}
+		lv, err := LayoutVolume(gadgetDir, &vol)
+		if err != nil {
+			return fmt.Errorf("invalid volume %q: %v", name, err)
+		}
+		for _, ps := range lv.LaidOutStructure {
+			if !HasFilesystem(ps.VolumeStructure) {
+				continue
+			}
+			for _, content := range ps.Content {
+				if _, err := os.Stat(filepath.Join(gadgetDir, content.Source)); err != nil {
+					return fmt.Errorf("invalid volume %q: invalid structure %v: content source %q not found", name, ps, content.Source)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateVolume checks the description of the volume for consistency,
+// without looking at the content it refers to.
+func ValidateVolume(name string, vol *snap.GadgetVolume) error {
+	if err := validateVolume(vol); err != nil {
+		return fmt.Errorf("invalid volume %q: %v", name, err)
+	}
+	return nil
+}
+
+func validateVolume(vol *snap.GadgetVolume) error {
+	switch vol.Schema {
+	case "", "gpt", "mbr":
+	default:
+		return fmt.Errorf("invalid schema %q", vol.Schema)
+	}
+
+	structures, volumeSize, err := layOutVolumeStructures(vol)
+	if err != nil {
+		return err
+	}
+
+	knownNames := make(map[string]bool, len(structures))
+	knownRoles := make(map[string]bool)
+	for idx := range structures {
+		ps := &structures[idx]
+		if ps.Name != "" {
+			if knownNames[ps.Name] {
+				return fmt.Errorf("structure name %q is not unique", ps.Name)
+			}
+			knownNames[ps.Name] = true
+		}
+		role := ps.Role
+		if role == "" && ps.Type == "mbr" {
+			role = "mbr"
+		}
+		if role != "" {
+			if knownRoles[role] {
+				return fmt.Errorf("cannot have more than one structure with role %q", role)
+			}
+			knownRoles[role] = true
+		}
+		if err := validateStructure(ps, vol.Schema); err != nil {
+			return fmt.Errorf("invalid structure %v: %v", ps, err)
+		}
+		if err := validateOffsetWrites(ps, structures, volumeSize); err != nil {
+			return fmt.Errorf("invalid structure %v: %v", ps, err)
+		}
+	}
+	return nil
+}
+
+func validateStructure(ps *LaidOutStructure, schema string) error {
+	if ps.Size == 0 {
+		return errors.New("missing size")
+	}
+	if err := validateStructureType(ps.Type, schema); err != nil {
+		return err
+	}
+	if err := validateRole(ps); err != nil {
+		return err
+	}
+
+	switch ps.Filesystem {
+	case "", "none", "vfat", "ext4":
+	default:
+		return fmt.Errorf("invalid filesystem %q", ps.Filesystem)
+	}
+	if ps.Label != "" && !HasFilesystem(ps.VolumeStructure) {
+		return errors.New("filesystem label can only be set on structures with a filesystem")
+	}
+	if ps.Type == "bare" && HasFilesystem(ps.VolumeStructure) {
+		return errors.New("bare structures cannot have a filesystem")
+	}
+
+	if IsPartition(ps.VolumeStructure) {
+		if ps.StartOffset%sectorSize != 0 {
+			return fmt.Errorf("offset %v is not aligned to %v bytes", ps.StartOffset, sectorSize)
+		}
+		if ps.Size%sectorSize != 0 {
+			return fmt.Errorf("size %v is not a multiple of %v bytes", ps.Size, sectorSize)
+		}
+	}
+
+	for i, content := range ps.Content {
+		if err := validateContent(&content, HasFilesystem(ps.VolumeStructure)); err != nil {
+			return fmt.Errorf("invalid content #%v: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func validateStructureType(typ, schema string) error {
+	if typ == "" {
+		return errors.New("missing type")
+	}
+	if typ == "bare" || typ == "mbr" {
+		return nil
+	}
+
+	mbrType, gptType := typ, typ
+	hybrid := false
+	if idx := strings.IndexRune(typ, ','); idx != -1 {
+		mbrType, gptType = typ[:idx], typ[idx+1:]
+		hybrid = true
+	}
+	isMBRType := validMBRType.MatchString(mbrType)
+	isGPTType := validGPTType.MatchString(gptType)
+	switch {
+	case hybrid && (!isMBRType || !isGPTType):
+		return fmt.Errorf("invalid type %q: invalid hybrid type", typ)
+	case !hybrid && !isMBRType && !isGPTType:
+		return fmt.Errorf("invalid type %q: neither an MBR type nor a GPT type", typ)
+	case schema == "mbr" && !isMBRType:
+		return fmt.Errorf("invalid type %q: GPT type cannot be used with the MBR schema", typ)
+	case schema != "mbr" && !isGPTType:
+		return fmt.Errorf("invalid type %q: MBR type cannot be used with the GPT schema", typ)
+	}
+	return nil
+}
+
+func validateRole(ps *LaidOutStructure) error {
+	role := ps.Role
+	if ps.Type == "mbr" {
+		if role != "" && role != "mbr" {
+			return fmt.Errorf("conflicting type %q and role %q", ps.Type, role)
+		}
+		role = "mbr"
+	}
+
+	switch role {
+	case "":
+	case "mbr":
+		if ps.StartOffset != 0 {
+			return errors.New("mbr structures must start at offset 0")
+		}
+		if ps.Size > MBRSize {
+			return fmt.Errorf("mbr structures cannot be larger than %v bytes", MBRSize)
+		}
+		if HasFilesystem(ps.VolumeStructure) {
+			return errors.New("mbr structures must not specify a filesystem")
+		}
+		if ps.ID != "" {
+			return errors.New("mbr structures must not specify a partition ID")
+		}
+	case "system-boot", "system-data":
+		if !HasFilesystem(ps.VolumeStructure) {
+			return fmt.Errorf("%s structures must specify a filesystem", role)
+		}
+	default:
+		return fmt.Errorf("invalid role %q", role)
+	}
+	return nil
+}
+
+func validateContent(content *snap.VolumeContent, withFilesystem bool) error {
+	if withFilesystem {
+		if content.Image != "" {
+			return errors.New("cannot use image content in a structure with a filesystem")
+		}
+		if content.Source == "" || content.Target == "" {
+			return errors.New("missing source or target")
+		}
+		if !validContentPath(content.Source) {
+			return fmt.Errorf("invalid source %q", content.Source)
+		}
+		if !validTargetPath(content.Target) {
+			return fmt.Errorf("invalid target %q", content.Target)
+		}
+		return nil
+	}
+
+	if content.Source != "" || content.Target != "" {
+		return errors.New("cannot use source and target content in a structure without a filesystem")
+	}
+	if !validContentPath(content.Image) {
+		return fmt.Errorf("invalid image %q", content.Image)
+	}
+	if content.Offset != "" {
+		if _, err := ParseSize(content.Offset); err != nil {
+			return err
+		}
+	}
+	if content.Size != "" {
+		if _, err := ParseSize(content.Size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validContentPath tells whether a path to the content of a structure stays
+// within the gadget snap: it must be relative and cannot refer to a parent
+// directory.
+func validContentPath(p string) bool {
+	if p == "" || filepath.IsAbs(p) {
+		return false
+	}
+	for _, elem := range strings.Split(p, "/") {
+		if elem == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// validTargetPath tells whether a content target stays within the
+// filesystem of the structure: it is taken relative to the root of the
+// filesystem and cannot refer to a parent directory.
+func validTargetPath(p string) bool {
+	if p == "" {
+		return false
+	}
+	rel := strings.TrimLeft(p, "/")
+	if rel == "" {
+		// the root of the filesystem
+		return true
+	}
+	return validContentPath(rel)
+}
+
+func validateOffsetWrite(offsetWrite string, structures []LaidOutStructure, volumeSize Size) error {
+	ro, err := ParseRelativeOffset(offsetWrite)
+	if err != nil {
+		return err
+	}
+	where, err := ResolveRelativeOffset(ro, structures)
+	if err != nil {
+		return fmt.Errorf("offset-write %q %v", offsetWrite, err)
+	}
+	// offset-write stores a 32 bit value
+	if where+4 > volumeSize {
+		return fmt.Errorf("offset-write %q is beyond the end of the volume", offsetWrite)
+	}
+	return nil
+}
+
+func validateOffsetWrites(ps *LaidOutStructure, structures []LaidOutStructure, volumeSize Size) error {
+	if ps.OffsetWrite != "" {
+		if err := validateOffsetWrite(ps.OffsetWrite, structures, volumeSize); err != nil {
+			return err
+		}
+	}
+	for i, content := range ps.Content {
+		if content.OffsetWrite == "" {
+			continue
+		}
+		if err := validateOffsetWrite(content.OffsetWrite, structures, volumeSize); err != nil {
+			return fmt.Errorf("invalid content #%v: %v", i, err)
+		}
+	}
+	return nil
+}
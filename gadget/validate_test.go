@@ -0,0 +1,359 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package gadget_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+	"gopkg.in/yaml.v2"
+
+	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/snap"
+)
+
+type validateTestSuite struct {
+	dir string
+}
+
+var _ = Suite(&validateTestSuite{})
+
+func (s *validateTestSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+}
+
+const pcGadgetYaml = `
+volumes:
+  pc:
+    bootloader: grub
+    structure:
+      - name: mbr
+        type: mbr
+        size: 440
+        content:
+          - image: pc-boot.img
+      - name: BIOS Boot
+        type: DA,21686148-6449-6E6F-744E-656564454649
+        size: 1M
+        offset: 1M
+        offset-write: mbr+92
+        content:
+          - image: pc-core.img
+      - name: EFI System
+        type: EF,C12A7328-F81F-11D2-BA4B-00A0C93EC93B
+        filesystem: vfat
+        filesystem-label: system-boot
+        size: 50M
+        content:
+          - source: grubx64.efi
+            target: EFI/boot/grubx64.efi
+`
+
+func parseVolume(c *C, content string) *snap.GadgetVolume {
+	var gi snap.GadgetInfo
+	c.Assert(yaml.Unmarshal([]byte(content), &gi), IsNil)
+	c.Assert(gi.Volumes, HasLen, 1)
+	for _, vol := range gi.Volumes {
+		return &vol
+	}
+	return nil
+}
+
+func (s *validateTestSuite) TestValidateVolumeHappy(c *C) {
+	err := gadget.ValidateVolume("pc", parseVolume(c, pcGadgetYaml))
+	c.Check(err, IsNil)
+}
+
+func (s *validateTestSuite) TestValidateVolumeErrors(c *C) {
+	for _, t := range []struct {
+		structure string
+		err       string
+	}{
+		{`
+      - name: foo
+        type: 83,0FC63DAF-8483-4772-8E79-3D69D8477DE4
+`, `cannot lay out structure #0 \("foo"\): cannot parse size: empty value`},
+		{`
+      - name: foo
+        size: 1M
+`, `invalid structure #0 \("foo"\): missing type`},
+		{`
+      - name: foo
+        type: 83
+        size: 1M
+`, `invalid structure #0 \("foo"\): invalid type "83": MBR type cannot be used with the GPT schema`},
+		{`
+      - name: foo
+        type: ZZ,0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        size: 1M
+`, `invalid structure #0 \("foo"\): invalid type "ZZ,0FC63DAF-8483-4772-8E79-3D69D8477DE4": invalid hybrid type`},
+		{`
+      - name: foo
+        type: foobar
+        size: 1M
+`, `invalid structure #0 \("foo"\): invalid type "foobar": neither an MBR type nor a GPT type`},
+		{`
+      - name: foo
+        type: 0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        size: 1M
+        offset: 1000
+`, `invalid structure #0 \("foo"\): offset 1000 is not aligned to 512 bytes`},
+		{`
+      - name: foo
+        type: 0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        size: 1000
+`, `invalid structure #0 \("foo"\): size 1000 is not a multiple of 512 bytes`},
+		{`
+      - name: foo
+        type: mbr
+        size: 512
+`, `invalid structure #0 \("foo"\): mbr structures cannot be larger than 446 bytes`},
+		{`
+      - name: foo
+        type: bare
+        role: mbr
+        offset: 512
+        size: 440
+`, `invalid structure #0 \("foo"\): mbr structures must start at offset 0`},
+		{`
+      - name: foo
+        type: mbr
+        role: system-data
+        size: 440
+`, `invalid structure #0 \("foo"\): conflicting type "mbr" and role "system-data"`},
+		{`
+      - name: foo
+        type: mbr
+        size: 440
+        filesystem: vfat
+`, `invalid structure #0 \("foo"\): mbr structures must not specify a filesystem`},
+		{`
+      - name: foo
+        type: 0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        role: system-data
+        size: 1M
+`, `invalid structure #0 \("foo"\): system-data structures must specify a filesystem`},
+		{`
+      - name: foo
+        type: 0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        role: foobar
+        size: 1M
+`, `invalid structure #0 \("foo"\): invalid role "foobar"`},
+		{`
+      - name: foo
+        type: 0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        filesystem: zfs
+        size: 1M
+`, `invalid structure #0 \("foo"\): invalid filesystem "zfs"`},
+		{`
+      - name: foo
+        type: 0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        filesystem-label: foo
+        size: 1M
+`, `invalid structure #0 \("foo"\): filesystem label can only be set on structures with a filesystem`},
+		{`
+      - name: foo
+        type: bare
+        filesystem: ext4
+        size: 1M
+`, `invalid structure #0 \("foo"\): bare structures cannot have a filesystem`},
+		{`
+      - name: foo
+        type: 0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        size: 1M
+      - name: foo
+        type: 0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        size: 1M
+`, `structure name "foo" is not unique`},
+		{`
+      - name: foo
+        type: 0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        filesystem: ext4
+        role: system-data
+        size: 1M
+      - name: bar
+        type: 0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        filesystem: ext4
+        role: system-data
+        size: 1M
+`, `cannot have more than one structure with role "system-data"`},
+		{`
+      - name: foo
+        type: 0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        size: 2M
+      - name: bar
+        type: 0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        offset: 2M
+        size: 1M
+`, `cannot lay out volume: structure #1 \("bar"\) overlaps with preceding structure #0 \("foo"\)`},
+		{`
+      - name: foo
+        type: 0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        offset-write: bar+12
+        size: 1M
+`, `invalid structure #0 \("foo"\): offset-write "bar\+12" refers to an unknown structure "bar"`},
+		{`
+      - name: foo
+        type: 0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        offset-write: 3M
+        size: 1M
+`, `invalid structure #0 \("foo"\): offset-write "3M" is beyond the end of the volume`},
+		{`
+      - name: foo
+        type: 0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        size: 1M
+        content:
+          - image: foo.img
+            offset-write: bar+1
+`, `invalid structure #0 \("foo"\): invalid content #0: offset-write "bar\+1" refers to an unknown structure "bar"`},
+		{`
+      - name: foo
+        type: 0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        filesystem: ext4
+        size: 1M
+        content:
+          - image: foo.img
+`, `invalid structure #0 \("foo"\): invalid content #0: cannot use image content in a structure with a filesystem`},
+		{`
+      - name: foo
+        type: 0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        filesystem: ext4
+        size: 1M
+        content:
+          - source: foo
+`, `invalid structure #0 \("foo"\): invalid content #0: missing source or target`},
+		{`
+      - name: foo
+        type: bare
+        size: 1M
+        content:
+          - source: foo
+            target: /
+`, `invalid structure #0 \("foo"\): invalid content #0: cannot use source and target content in a structure without a filesystem`},
+		{`
+      - name: foo
+        type: bare
+        size: 1M
+        content:
+          - image: /foo.img
+`, `invalid structure #0 \("foo"\): invalid content #0: invalid image "/foo.img"`},
+		{`
+      - name: foo
+        type: bare
+        size: 1M
+        content:
+          - image: ../foo.img
+`, `invalid structure #0 \("foo"\): invalid content #0: invalid image "../foo.img"`},
+		{`
+      - name: foo
+        type: bare
+        size: 1M
+        content:
+          - image: boot/../../foo.img
+`, `invalid structure #0 \("foo"\): invalid content #0: invalid image "boot/../../foo.img"`},
+		{`
+      - name: foo
+        type: 0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        filesystem: ext4
+        size: 1M
+        content:
+          - source: /etc/shadow
+            target: /
+`, `invalid structure #0 \("foo"\): invalid content #0: invalid source "/etc/shadow"`},
+		{`
+      - name: foo
+        type: 0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        filesystem: ext4
+        size: 1M
+        content:
+          - source: ../../
+            target: /
+`, `invalid structure #0 \("foo"\): invalid content #0: invalid source "../../"`},
+		{`
+      - name: foo
+        type: 0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        filesystem: ext4
+        size: 1M
+        content:
+          - source: foo
+            target: /boot/../../etc/
+`, `invalid structure #0 \("foo"\): invalid content #0: invalid target "/boot/../../etc/"`},
+		{`
+      - name: foo
+        type: bare
+        size: 1M
+        content:
+          - image: foo.img
+            size: 12K
+`, `invalid structure #0 \("foo"\): invalid content #0: cannot parse size "12K": invalid number`},
+	} {
+		vol := parseVolume(c, "volumes:\n  pc:\n    structure:"+t.structure)
+		err := gadget.ValidateVolume("pc", vol)
+		c.Check(err, ErrorMatches, `invalid volume "pc": `+t.err, Commentf(t.structure))
+	}
+}
+
+func (s *validateTestSuite) TestValidateVolumeSchema(c *C) {
+	vol := parseVolume(c, `
+volumes:
+  pc:
+    schema: mbr
+    structure:
+      - name: foo
+        type: 0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        size: 1M
+`)
+	err := gadget.ValidateVolume("pc", vol)
+	c.Check(err, ErrorMatches, `invalid volume "pc": invalid structure #0 \("foo"\): invalid type "0FC63DAF-8483-4772-8E79-3D69D8477DE4": GPT type cannot be used with the MBR schema`)
+
+	vol.Schema = "bsd"
+	err = gadget.ValidateVolume("pc", vol)
+	c.Check(err, ErrorMatches, `invalid volume "pc": invalid schema "bsd"`)
+}
+
+func (s *validateTestSuite) writeGadget(c *C, gadgetYaml string, files ...string) {
+	c.Assert(os.MkdirAll(filepath.Join(s.dir, "meta"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, "meta/gadget.yaml"), []byte(gadgetYaml), 0644), IsNil)
+	for _, f := range files {
+		c.Assert(ioutil.WriteFile(filepath.Join(s.dir, f), []byte(f), 0644), IsNil)
+	}
+}
+
+func (s *validateTestSuite) TestValidateHappy(c *C) {
+	s.writeGadget(c, pcGadgetYaml, "pc-boot.img", "pc-core.img", "grubx64.efi")
+	c.Check(gadget.Validate(s.dir), IsNil)
+}
+
+func (s *validateTestSuite) TestValidateMissingContent(c *C) {
+	s.writeGadget(c, pcGadgetYaml, "pc-boot.img", "pc-core.img")
+	err := gadget.Validate(s.dir)
+	c.Check(err, ErrorMatches, `invalid volume "pc": invalid structure #2 \("EFI System"\): content source "grubx64.efi" not found`)
+
+	s.writeGadget(c, pcGadgetYaml, "pc-boot.img", "grubx64.efi")
+	c.Assert(os.Remove(filepath.Join(s.dir, "pc-core.img")), IsNil)
+	err = gadget.Validate(s.dir)
+	c.Check(err, ErrorMatches, `invalid volume "pc": cannot lay out structure #1 \("BIOS Boot"\): content "pc-core.img": .* no such file or directory`)
+}
+
+func (s *validateTestSuite) TestValidateMissingGadgetYaml(c *C) {
+	err := gadget.Validate(s.dir)
+	c.Check(err, ErrorMatches, `cannot read gadget snap details: .*/meta/gadget.yaml: no such file or directory`)
+}
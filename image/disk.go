@@ -0,0 +1,279 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package image
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/snap"
+)
+
+// mkfsHandlers create a filesystem of a given type in the file backed
+// image, populated with the contents of a directory.
+var mkfsHandlers = map[string]func(img, label, contentsDir string) error{
+	"ext4": mkfsExt4,
+	"vfat": mkfsVfat,
+}
+
+func mkfsExt4(img, label, contentsDir string) error {
+	cmd := []string{"mkfs.ext4", "-F", "-q", "-T", "default", "-O", "^metadata_csum,^64bit", "-d", contentsDir}
+	if label != "" {
+		cmd = append(cmd, "-L", label)
+	}
+	cmd = append(cmd, img)
+	return runCommand(cmd...)
+}
+
+func mkfsVfat(img, label, contentsDir string) error {
+	cmd := []string{"mkfs.vfat", "-S", "512"}
+	if label != "" {
+		cmd = append(cmd, "-n", label)
+	}
+	cmd = append(cmd, img)
+	if err := runCommand(cmd...); err != nil {
+		return err
+	}
+	// vfat cannot be populated at creation time, copy the files
+	// with mtools which work on the image file directly
+	entries, err := ioutil.ReadDir(contentsDir)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	cmd = []string{"mcopy", "-s", "-i", img}
+	for _, entry := range entries {
+		cmd = append(cmd, filepath.Join(contentsDir, entry.Name()))
+	}
+	cmd = append(cmd, "::")
+	return runCommand(cmd...)
+}
+
+// writeDiskImages writes a disk image for each of the volumes declared in
+// gadget.yaml of the gadget unpacked in gadgetDir. The system data
+// partition is populated with the prepared image from rootDir. The images
+// are named after their volumes and written to outputDir.
+func writeDiskImages(gadgetDir, rootDir, outputDir string) error {
+	gi, err := snap.ReadGadgetInfoFromDir(gadgetDir, false)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(gi.Volumes))
+	for name := range gi.Volumes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		vol := gi.Volumes[name]
+		lv, err := gadget.LayoutVolume(gadgetDir, &vol)
+		if err != nil {
+			return fmt.Errorf("cannot lay out volume %q: %v", name, err)
+		}
+		imgPath := filepath.Join(outputDir, name+".img")
+		if err := writeVolumeImage(lv, rootDir, imgPath); err != nil {
+			return fmt.Errorf("cannot write image of volume %q: %v", name, err)
+		}
+		fmt.Fprintf(Stdout, "Wrote disk image %s\n", imgPath)
+	}
+	return nil
+}
+
+func roundUp(size, to gadget.Size) gadget.Size {
+	return (size + to - 1) / to * to
+}
+
+func writeVolumeImage(lv *gadget.LaidOutVolume, rootDir, imgPath string) (err error) {
+	schema := lv.Schema
+	if schema == "" {
+		schema = "gpt"
+	}
+	if schema != "gpt" && schema != "mbr" {
+		return fmt.Errorf("unsupported schema %q", schema)
+	}
+
+	size := roundUp(lv.Size, sectorSize)
+	if schema == "gpt" {
+		size += gptBackupSectors * sectorSize
+	}
+
+	img, err := os.Create(imgPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		img.Close()
+		if err != nil {
+			os.Remove(imgPath)
+		}
+	}()
+	if err := img.Truncate(int64(size)); err != nil {
+		return err
+	}
+
+	for idx := range lv.LaidOutStructure {
+		ps := &lv.LaidOutStructure[idx]
+		if gadget.HasFilesystem(ps.VolumeStructure) {
+			err = writeFilesystemStructure(img, ps, lv.RootDir, rootDir)
+		} else {
+			err = writeRawStructure(img, ps, lv.RootDir)
+		}
+		if err != nil {
+			return fmt.Errorf("cannot write structure %v: %v", ps, err)
+		}
+	}
+
+	if schema == "gpt" {
+		err = writeGPT(img, lv, size)
+	} else {
+		err = writeMBR(img, lv)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot write partition table: %v", err)
+	}
+
+	if err := writeOffsets(img, lv); err != nil {
+		return err
+	}
+
+	return img.Sync()
+}
+
+func copyAt(w io.WriterAt, offset gadget.Size, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	buf := make([]byte, 1024*1024)
+	off := int64(offset)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			if _, werr := w.WriteAt(buf[:n], off); werr != nil {
+				return werr
+			}
+			off += int64(n)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func writeRawStructure(img io.WriterAt, ps *gadget.LaidOutStructure, gadgetDir string) error {
+	for _, pc := range ps.LaidOutContent {
+		if err := copyAt(img, pc.StartOffset, filepath.Join(gadgetDir, pc.Image)); err != nil {
+			return fmt.Errorf("cannot write image %v: %v", pc, err)
+		}
+	}
+	return nil
+}
+
+func writeFilesystemStructure(img io.WriterAt, ps *gadget.LaidOutStructure, gadgetDir, rootDir string) error {
+	mkfs := mkfsHandlers[ps.Filesystem]
+	if mkfs == nil {
+		return fmt.Errorf("cannot create filesystem %q: not supported", ps.Filesystem)
+	}
+
+	tmpDir, err := ioutil.TempDir("", "snap-disk-image-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	contentsDir := filepath.Join(tmpDir, "contents")
+	if err := os.Mkdir(contentsDir, 0755); err != nil {
+		return err
+	}
+	if err := gadget.CopyStructureContent(ps, gadgetDir, contentsDir); err != nil {
+		return err
+	}
+	if ps.Role == "system-data" && rootDir != "" {
+		if err := runCommand("cp", "-a", rootDir, filepath.Join(contentsDir, "system-data")); err != nil {
+			return err
+		}
+	}
+
+	fsImg := filepath.Join(tmpDir, "fs.img")
+	f, err := os.Create(fsImg)
+	if err != nil {
+		return err
+	}
+	err = f.Truncate(int64(ps.Size))
+	f.Close()
+	if err != nil {
+		return err
+	}
+	if err := mkfs(fsImg, ps.Label, contentsDir); err != nil {
+		return fmt.Errorf("cannot create filesystem %q: %v", ps.Filesystem, err)
+	}
+	return copyAt(img, ps.StartOffset, fsImg)
+}
+
+func writeOffset(img io.WriterAt, lv *gadget.LaidOutVolume, offsetWrite string, value gadget.Size) error {
+	ro, err := gadget.ParseRelativeOffset(offsetWrite)
+	if err != nil {
+		return err
+	}
+	where, err := gadget.ResolveRelativeOffset(ro, lv.LaidOutStructure)
+	if err != nil {
+		return fmt.Errorf("offset-write %q %v", offsetWrite, err)
+	}
+	if where+4 > lv.Size {
+		return fmt.Errorf("offset-write %q is beyond the end of the volume", offsetWrite)
+	}
+	// offsets are written as the number of sectors in little-endian
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], uint32(value/sectorSize))
+	_, err = img.WriteAt(buf[:], int64(where))
+	return err
+}
+
+// writeOffsets writes the start offsets of the structures and images that
+// declared a location to have them written to.
+func writeOffsets(img io.WriterAt, lv *gadget.LaidOutVolume) error {
+	for _, ps := range lv.LaidOutStructure {
+		if ps.OffsetWrite != "" {
+			if err := writeOffset(img, lv, ps.OffsetWrite, ps.StartOffset); err != nil {
+				return fmt.Errorf("cannot write offset of structure %v: %v", ps, err)
+			}
+		}
+		for _, pc := range ps.LaidOutContent {
+			if pc.OffsetWrite != "" {
+				if err := writeOffset(img, lv, pc.OffsetWrite, pc.StartOffset); err != nil {
+					return fmt.Errorf("cannot write offset of image %v: %v", pc, err)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,377 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package image_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"unicode/utf16"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/image"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type diskImageSuite struct {
+	gadgetDir string
+	rootDir   string
+	outputDir string
+
+	mkfsCalls [][]string
+
+	stdout  *bytes.Buffer
+	restore []func()
+}
+
+var _ = Suite(&diskImageSuite{})
+
+var pcGadgetYaml = `
+volumes:
+  pc:
+    bootloader: grub
+    structure:
+      - name: mbr
+        type: mbr
+        role: mbr
+        size: 440
+        content:
+          - image: pc-boot.img
+      - name: BIOS Boot
+        type: DA,21686148-6449-6E6F-744E-656564454649
+        size: 1M
+        offset: 1M
+        offset-write: mbr+92
+        content:
+          - image: pc-core.img
+      - name: EFI System
+        type: EF,C12A7328-F81F-11D2-BA4B-00A0C93EC93B
+        filesystem: vfat
+        filesystem-label: system-boot
+        role: system-boot
+        size: 2M
+        content:
+          - source: grubx64.efi
+            target: EFI/boot/grubx64.efi
+      - name: writable
+        type: 83,0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        filesystem: ext4
+        filesystem-label: writable
+        role: system-data
+        size: 1M
+`
+
+var mbrGadgetYaml = `
+volumes:
+  pi:
+    schema: mbr
+    bootloader: u-boot
+    structure:
+      - name: system-boot
+        type: 0C
+        filesystem: vfat
+        filesystem-label: system-boot
+        role: system-boot
+        size: 1M
+      - name: writable
+        type: 83
+        filesystem: ext4
+        filesystem-label: writable
+        role: system-data
+        size: 1M
+`
+
+func (s *diskImageSuite) SetUpTest(c *C) {
+	s.gadgetDir = c.MkDir()
+	s.rootDir = c.MkDir()
+	s.outputDir = c.MkDir()
+	s.mkfsCalls = nil
+
+	c.Assert(os.MkdirAll(filepath.Join(s.gadgetDir, "meta"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.gadgetDir, "pc-boot.img"), bytes.Repeat([]byte{'b'}, 440), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.gadgetDir, "pc-core.img"), []byte("core image"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.gadgetDir, "grubx64.efi"), []byte("grub"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.rootDir, "seeded"), []byte("seed"), 0644), IsNil)
+
+	mkfs := func(fstype string) func(img, label, contentsDir string) error {
+		return func(img, label, contentsDir string) error {
+			var files []string
+			filepath.Walk(contentsDir, func(path string, info os.FileInfo, err error) error {
+				if err == nil && !info.IsDir() {
+					rel, _ := filepath.Rel(contentsDir, path)
+					files = append(files, rel)
+				}
+				return nil
+			})
+			s.mkfsCalls = append(s.mkfsCalls, append([]string{fstype, label}, files...))
+			f, err := os.OpenFile(img, os.O_WRONLY, 0)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = f.Write([]byte(fstype + ":" + label))
+			return err
+		}
+	}
+	s.stdout = &bytes.Buffer{}
+	image.Stdout = s.stdout
+	s.restore = []func(){
+		image.MockMkfsHandlers(map[string]func(img, label, contentsDir string) error{
+			"ext4": mkfs("ext4"),
+			"vfat": mkfs("vfat"),
+		}),
+		image.MockRandomGUID([16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}),
+	}
+}
+
+func (s *diskImageSuite) TearDownTest(c *C) {
+	image.Stdout = os.Stdout
+	for _, r := range s.restore {
+		r()
+	}
+}
+
+func (s *diskImageSuite) writeGadgetYaml(c *C, content string) {
+	c.Assert(ioutil.WriteFile(filepath.Join(s.gadgetDir, "meta/gadget.yaml"), []byte(content), 0644), IsNil)
+}
+
+func (s *diskImageSuite) TestParseGUID(c *C) {
+	guid, err := image.ParseGUID("C12A7328-F81F-11D2-BA4B-00A0C93EC93B")
+	c.Assert(err, IsNil)
+	c.Check(guid, DeepEquals, [16]byte{0x28, 0x73, 0x2a, 0xc1, 0x1f, 0xf8, 0xd2, 0x11, 0xba, 0x4b, 0x00, 0xa0, 0xc9, 0x3e, 0xc9, 0x3b})
+
+	for _, bad := range []string{"", "C12A7328", "C12A7328-F81F-11D2-BA4B-00A0C93EC9ZZ", "C12A7328F-81F-11D2-BA4B-00A0C93EC93B"} {
+		_, err := image.ParseGUID(bad)
+		c.Check(err, ErrorMatches, "invalid GUID .*")
+	}
+}
+
+func (s *diskImageSuite) TestWriteDiskImagesGPT(c *C) {
+	s.writeGadgetYaml(c, pcGadgetYaml)
+
+	err := image.WriteDiskImages(s.gadgetDir, s.rootDir, s.outputDir)
+	c.Assert(err, IsNil)
+	imgPath := filepath.Join(s.outputDir, "pc.img")
+	c.Check(s.stdout.String(), Equals, "Wrote disk image "+imgPath+"\n")
+
+	data, err := ioutil.ReadFile(imgPath)
+	c.Assert(err, IsNil)
+	// 1M gap + 1M BIOS boot + 2M ESP + 1M writable + backup GPT
+	const MiB = 1024 * 1024
+	c.Assert(data, HasLen, 5*MiB+33*512)
+
+	// boot code is preserved, the BIOS boot offset is written into it
+	c.Check(data[:92], DeepEquals, bytes.Repeat([]byte{'b'}, 92))
+	c.Check(binary.LittleEndian.Uint32(data[92:]), Equals, uint32(MiB/512))
+	c.Check(data[96:440], DeepEquals, bytes.Repeat([]byte{'b'}, 440-96))
+	// protective MBR
+	c.Check(data[446+4], Equals, byte(0xee))
+	c.Check(binary.LittleEndian.Uint32(data[446+8:]), Equals, uint32(1))
+	c.Check(data[510:512], DeepEquals, []byte{0x55, 0xaa})
+
+	// primary GPT header
+	header := data[512 : 512+92]
+	c.Check(string(header[:8]), Equals, "EFI PART")
+	headerCopy := make([]byte, 92)
+	copy(headerCopy, header)
+	binary.LittleEndian.PutUint32(headerCopy[16:], 0)
+	c.Check(binary.LittleEndian.Uint32(header[16:]), Equals, crc32.ChecksumIEEE(headerCopy))
+	lastLBA := uint64(len(data)/512 - 1)
+	c.Check(binary.LittleEndian.Uint64(header[32:]), Equals, lastLBA)
+	entries := data[1024 : 1024+128*128]
+	c.Check(binary.LittleEndian.Uint32(header[88:]), Equals, crc32.ChecksumIEEE(entries))
+
+	// backup GPT
+	backupHeader := data[lastLBA*512:]
+	c.Check(string(backupHeader[:8]), Equals, "EFI PART")
+	c.Check(binary.LittleEndian.Uint64(backupHeader[24:]), Equals, lastLBA)
+	c.Check(data[(lastLBA-32)*512:lastLBA*512], DeepEquals, entries)
+
+	// partitions
+	for i, p := range []struct {
+		name  string
+		start uint64
+		end   uint64
+	}{
+		{"BIOS Boot", MiB / 512, 2*MiB/512 - 1},
+		{"EFI System", 2 * MiB / 512, 4*MiB/512 - 1},
+		{"writable", 4 * MiB / 512, 5*MiB/512 - 1},
+	} {
+		entry := entries[i*128 : (i+1)*128]
+		c.Check(binary.LittleEndian.Uint64(entry[32:]), Equals, p.start)
+		c.Check(binary.LittleEndian.Uint64(entry[40:]), Equals, p.end)
+		name := make([]uint16, len(p.name))
+		for j := range name {
+			name[j] = binary.LittleEndian.Uint16(entry[56+2*j:])
+		}
+		c.Check(string(utf16.Decode(name)), Equals, p.name)
+	}
+	// ESP type GUID
+	c.Check(entries[128:128+16], DeepEquals, []byte{0x28, 0x73, 0x2a, 0xc1, 0x1f, 0xf8, 0xd2, 0x11, 0xba, 0x4b, 0x00, 0xa0, 0xc9, 0x3e, 0xc9, 0x3b})
+	c.Check(entries[3*128:4*128], DeepEquals, make([]byte, 128))
+
+	// content
+	c.Check(string(data[MiB:MiB+10]), Equals, "core image")
+	c.Check(string(data[2*MiB:2*MiB+16]), Equals, "vfat:system-boot")
+	c.Check(string(data[4*MiB:4*MiB+13]), Equals, "ext4:writable")
+	c.Check(s.mkfsCalls, DeepEquals, [][]string{
+		{"vfat", "system-boot", "EFI/boot/grubx64.efi"},
+		{"ext4", "writable", "system-data/seeded"},
+	})
+}
+
+func (s *diskImageSuite) TestWriteDiskImagesMBR(c *C) {
+	s.writeGadgetYaml(c, mbrGadgetYaml)
+
+	err := image.WriteDiskImages(s.gadgetDir, s.rootDir, s.outputDir)
+	c.Assert(err, IsNil)
+
+	data, err := ioutil.ReadFile(filepath.Join(s.outputDir, "pi.img"))
+	c.Assert(err, IsNil)
+	const MiB = 1024 * 1024
+	c.Assert(data, HasLen, 3*MiB)
+
+	c.Check(data[:446], DeepEquals, make([]byte, 446))
+	first := data[446 : 446+16]
+	c.Check(first[0], Equals, byte(0x80))
+	c.Check(first[4], Equals, byte(0x0c))
+	c.Check(binary.LittleEndian.Uint32(first[8:]), Equals, uint32(MiB/512))
+	c.Check(binary.LittleEndian.Uint32(first[12:]), Equals, uint32(MiB/512))
+	second := data[446+16 : 446+32]
+	c.Check(second[0], Equals, byte(0))
+	c.Check(second[4], Equals, byte(0x83))
+	c.Check(binary.LittleEndian.Uint32(second[8:]), Equals, uint32(2*MiB/512))
+	c.Check(data[446+32:510], DeepEquals, make([]byte, 510-446-32))
+	c.Check(data[510:512], DeepEquals, []byte{0x55, 0xaa})
+}
+
+func (s *diskImageSuite) TestWriteDiskImagesErrors(c *C) {
+	for _, t := range []struct {
+		yaml string
+		err  string
+	}{
+		{
+			`
+volumes:
+  pc:
+    bootloader: grub
+    structure:
+      - name: data
+        type: 83
+        filesystem: btrfs
+        size: 1M
+`, `cannot write image of volume "pc": cannot write structure #0 \("data"\): cannot create filesystem "btrfs": not supported`,
+		}, {
+			`
+volumes:
+  pc:
+    bootloader: grub
+    schema: mbr
+    structure:
+      - name: data
+        type: C12A7328-F81F-11D2-BA4B-00A0C93EC93B
+        size: 1M
+`, `cannot write image of volume "pc": cannot write partition table: cannot use type "C12A7328-F81F-11D2-BA4B-00A0C93EC93B" in an MBR partition table`,
+		}, {
+			`
+volumes:
+  pc:
+    bootloader: grub
+    structure:
+      - name: data
+        type: 83
+        size: 1M
+`, `cannot write image of volume "pc": cannot write partition table: cannot use type "83" in a GPT partition table`,
+		}, {
+			`
+volumes:
+  pc:
+    bootloader: grub
+    structure:
+      - name: data
+        type: 83,0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        offset-write: other+10
+        size: 1M
+`, `cannot write image of volume "pc": cannot write offset of structure #0 \("data"\): offset-write "other\+10" refers to an unknown structure "other"`,
+		}, {
+			`
+volumes:
+  pc:
+    bootloader: grub
+    schema: bsd
+    structure:
+      - name: data
+        type: 83
+        size: 1M
+`, `cannot write image of volume "pc": unsupported schema "bsd"`,
+		},
+	} {
+		s.writeGadgetYaml(c, t.yaml)
+		err := image.WriteDiskImages(s.gadgetDir, s.rootDir, s.outputDir)
+		c.Check(err, ErrorMatches, t.err)
+		c.Check(osutil.FileExists(filepath.Join(s.outputDir, "pc.img")), Equals, false)
+	}
+}
+
+func (s *diskImageSuite) TestMkfsExt4(c *C) {
+	cmd := testutil.MockCommand(c, "mkfs.ext4", "")
+	defer cmd.Restore()
+
+	err := image.MkfsExt4("/tmp/fs.img", "writable", "/tmp/contents")
+	c.Assert(err, IsNil)
+	c.Check(cmd.Calls(), DeepEquals, [][]string{
+		{"mkfs.ext4", "-F", "-q", "-T", "default", "-O", "^metadata_csum,^64bit", "-d", "/tmp/contents", "-L", "writable", "/tmp/fs.img"},
+	})
+}
+
+func (s *diskImageSuite) TestMkfsVfat(c *C) {
+	cmd := testutil.MockCommand(c, "mkfs.vfat", "")
+	defer cmd.Restore()
+	mcopy := testutil.MockCommand(c, "mcopy", "")
+	defer mcopy.Restore()
+
+	contentsDir := c.MkDir()
+	err := image.MkfsVfat("/tmp/fs.img", "system-boot", contentsDir)
+	c.Assert(err, IsNil)
+	c.Check(cmd.Calls(), DeepEquals, [][]string{
+		{"mkfs.vfat", "-S", "512", "-n", "system-boot", "/tmp/fs.img"},
+	})
+	c.Check(mcopy.Calls(), HasLen, 0)
+
+	c.Assert(os.Mkdir(filepath.Join(contentsDir, "EFI"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(contentsDir, "grub.cfg"), nil, 0644), IsNil)
+	err = image.MkfsVfat("/tmp/fs.img", "", contentsDir)
+	c.Assert(err, IsNil)
+	c.Check(mcopy.Calls(), DeepEquals, [][]string{
+		{"mcopy", "-s", "-i", "/tmp/fs.img", filepath.Join(contentsDir, "EFI"), filepath.Join(contentsDir, "grub.cfg"), "::"},
+	})
+}
+
+func (s *diskImageSuite) TestMkfsError(c *C) {
+	cmd := testutil.MockCommand(c, "mkfs.ext4", "echo failed; exit 1")
+	defer cmd.Restore()
+
+	err := image.MkfsExt4("/tmp/fs.img", "", "/tmp/contents")
+	c.Assert(err, ErrorMatches, `cannot run \[mkfs.ext4 .*\]: failed`)
+}
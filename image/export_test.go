@@ -38,3 +38,41 @@ var (
 func (tsto *ToolingStore) User() *auth.UserState {
 	return tsto.user
 }
+
+var (
+	WriteDiskImages = writeDiskImages
+	ParseGUID       = parseGUID
+)
+
+func MockMkfsHandlers(handlers map[string]func(img, label, contentsDir string) error) (restore func()) {
+	old := mkfsHandlers
+	mkfsHandlers = handlers
+	return func() {
+		mkfsHandlers = old
+	}
+}
+
+func MockRandomGUID(guid [16]byte) (restore func()) {
+	old := randomGUID
+	randomGUID = func() ([16]byte, error) {
+		return guid, nil
+	}
+	return func() {
+		randomGUID = old
+	}
+}
+
+var Preseed = preseed
+
+func MockProcSelfMountInfo(path string) (restore func()) {
+	old := procSelfMountInfo
+	procSelfMountInfo = path
+	return func() {
+		procSelfMountInfo = old
+	}
+}
+
+var (
+	MkfsExt4 = mkfsExt4
+	MkfsVfat = mkfsVfat
+)
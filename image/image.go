@@ -52,6 +52,8 @@ type Options struct {
 	Channel         string
 	ModelFile       string
 	GadgetUnpackDir string
+	DiskImageDir    string
+	Preseed         bool
 }
 
 type localInfos struct {
@@ -151,7 +153,20 @@ func Prepare(opts *Options) error {
 		return err
 	}
 
-	return bootstrapToRootDir(tsto, model, opts, local)
+	if err := bootstrapToRootDir(tsto, model, opts, local); err != nil {
+		return err
+	}
+
+	if opts.Preseed {
+		if err := preseed(opts.RootDir); err != nil {
+			return err
+		}
+	}
+
+	if opts.DiskImageDir != "" {
+		return writeDiskImages(opts.GadgetUnpackDir, opts.RootDir, opts.DiskImageDir)
+	}
+	return nil
 }
 
 // these are postponed, not implemented or abandoned, not finalized,
@@ -0,0 +1,274 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package image
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/snapcore/snapd/gadget"
+)
+
+const (
+	sectorSize = 512
+
+	mbrPartitionTableOffset = 446
+	mbrPartitionEntrySize   = 16
+	mbrMaxPartitions        = 4
+
+	gptHeaderSize       = 92
+	gptEntrySize        = 128
+	gptNumEntries       = 128
+	gptEntriesSectors   = gptNumEntries * gptEntrySize / sectorSize
+	gptFirstUsableLBA   = 2 + gptEntriesSectors
+	gptBackupSectors    = 1 + gptEntriesSectors
+	gptPartitionNameLen = 36
+)
+
+// randomGUID returns a new random (version 4) GUID in its on-disk
+// representation.
+var randomGUID = func() ([16]byte, error) {
+	var guid [16]byte
+	if _, err := io.ReadFull(rand.Reader, guid[:]); err != nil {
+		return guid, fmt.Errorf("cannot generate GUID: %v", err)
+	}
+	// version 4, RFC 4122 variant; the version is stored in the
+	// little-endian third group of the on-disk format
+	guid[7] = (guid[7] & 0x0f) | 0x40
+	guid[8] = (guid[8] & 0x3f) | 0x80
+	return guid, nil
+}
+
+// parseGUID parses a GUID in its textual representation into the mixed
+// endian on-disk format used by GPT.
+func parseGUID(s string) ([16]byte, error) {
+	var guid [16]byte
+	parts := strings.Split(s, "-")
+	if len(parts) != 5 || len(parts[0]) != 8 || len(parts[1]) != 4 || len(parts[2]) != 4 || len(parts[3]) != 4 || len(parts[4]) != 12 {
+		return guid, fmt.Errorf("invalid GUID %q", s)
+	}
+	raw, err := hex.DecodeString(strings.Join(parts, ""))
+	if err != nil {
+		return guid, fmt.Errorf("invalid GUID %q", s)
+	}
+	// the first three groups are stored little-endian
+	binary.LittleEndian.PutUint32(guid[0:], binary.BigEndian.Uint32(raw[0:]))
+	binary.LittleEndian.PutUint16(guid[4:], binary.BigEndian.Uint16(raw[4:]))
+	binary.LittleEndian.PutUint16(guid[6:], binary.BigEndian.Uint16(raw[6:]))
+	copy(guid[8:], raw[8:])
+	return guid, nil
+}
+
+// partitionType returns the MBR or GPT specific part of a structure type,
+// which can be either an MBR type, a GPT type or a hybrid of both in the
+// form <mbr-type>,<gpt-type>.
+func partitionType(typ, schema string) (string, error) {
+	mbrType, gptType := typ, typ
+	if idx := strings.IndexRune(typ, ','); idx != -1 {
+		mbrType, gptType = typ[:idx], typ[idx+1:]
+	}
+	switch schema {
+	case "mbr":
+		if len(mbrType) != 2 {
+			return "", fmt.Errorf("cannot use type %q in an MBR partition table", typ)
+		}
+		return mbrType, nil
+	default:
+		if len(gptType) == 2 {
+			return "", fmt.Errorf("cannot use type %q in a GPT partition table", typ)
+		}
+		return gptType, nil
+	}
+}
+
+func partitions(lv *gadget.LaidOutVolume) ([]*gadget.LaidOutStructure, error) {
+	var parts []*gadget.LaidOutStructure
+	for idx := range lv.LaidOutStructure {
+		ps := &lv.LaidOutStructure[idx]
+		if !gadget.IsPartition(ps.VolumeStructure) {
+			continue
+		}
+		if ps.StartOffset%sectorSize != 0 || ps.Size%sectorSize != 0 {
+			return nil, fmt.Errorf("cannot create partition for structure %v: offset or size not aligned to sector size", ps)
+		}
+		parts = append(parts, ps)
+	}
+	return parts, nil
+}
+
+func mbrEntry(status, typ byte, startLBA, sectors uint32) []byte {
+	entry := make([]byte, mbrPartitionEntrySize)
+	entry[0] = status
+	// CHS addressing is not used, mark it as such
+	copy(entry[1:4], []byte{0xfe, 0xff, 0xff})
+	entry[4] = typ
+	copy(entry[5:8], []byte{0xfe, 0xff, 0xff})
+	binary.LittleEndian.PutUint32(entry[8:], startLBA)
+	binary.LittleEndian.PutUint32(entry[12:], sectors)
+	return entry
+}
+
+// writeMBR writes an MBR partition table with the partitions of the
+// volume. The boot code area of the first sector is left intact.
+func writeMBR(w io.WriterAt, lv *gadget.LaidOutVolume) error {
+	parts, err := partitions(lv)
+	if err != nil {
+		return err
+	}
+	if len(parts) > mbrMaxPartitions {
+		return fmt.Errorf("cannot create more than %d partitions in an MBR partition table", mbrMaxPartitions)
+	}
+	table := make([]byte, mbrMaxPartitions*mbrPartitionEntrySize+2)
+	for i, ps := range parts {
+		typ, err := partitionType(ps.Type, "mbr")
+		if err != nil {
+			return err
+		}
+		typByte, err := hex.DecodeString(typ)
+		if err != nil {
+			return fmt.Errorf("cannot use type %q in an MBR partition table", ps.Type)
+		}
+		var status byte
+		if ps.Role == "system-boot" {
+			status = 0x80
+		}
+		start := uint64(ps.StartOffset / sectorSize)
+		sectors := uint64(ps.Size / sectorSize)
+		if start+sectors > 0xffffffff {
+			return fmt.Errorf("cannot create partition for structure %v: beyond MBR addressable range", ps)
+		}
+		copy(table[i*mbrPartitionEntrySize:], mbrEntry(status, typByte[0], uint32(start), uint32(sectors)))
+	}
+	table[len(table)-2] = 0x55
+	table[len(table)-1] = 0xaa
+	_, err = w.WriteAt(table, mbrPartitionTableOffset)
+	return err
+}
+
+func gptPartitionEntries(parts []*gadget.LaidOutStructure) ([]byte, error) {
+	entries := make([]byte, gptNumEntries*gptEntrySize)
+	for i, ps := range parts {
+		typ, err := partitionType(ps.Type, "gpt")
+		if err != nil {
+			return nil, err
+		}
+		typGUID, err := parseGUID(typ)
+		if err != nil {
+			return nil, fmt.Errorf("cannot use type of structure %v: %v", ps, err)
+		}
+		uniqueGUID, err := randomGUID()
+		if err != nil {
+			return nil, err
+		}
+		entry := entries[i*gptEntrySize : (i+1)*gptEntrySize]
+		copy(entry[0:], typGUID[:])
+		copy(entry[16:], uniqueGUID[:])
+		binary.LittleEndian.PutUint64(entry[32:], uint64(ps.StartOffset/sectorSize))
+		binary.LittleEndian.PutUint64(entry[40:], uint64((ps.StartOffset+ps.Size)/sectorSize-1))
+		name := utf16.Encode([]rune(ps.Name))
+		if len(name) > gptPartitionNameLen {
+			return nil, fmt.Errorf("cannot use name of structure %v: too long", ps)
+		}
+		for j, r := range name {
+			binary.LittleEndian.PutUint16(entry[56+2*j:], r)
+		}
+	}
+	return entries, nil
+}
+
+func gptHeader(diskGUID [16]byte, currentLBA, backupLBA, lastUsableLBA, entriesLBA uint64, entriesCRC uint32) []byte {
+	header := make([]byte, sectorSize)
+	copy(header[0:], "EFI PART")
+	binary.LittleEndian.PutUint32(header[8:], 0x00010000)
+	binary.LittleEndian.PutUint32(header[12:], gptHeaderSize)
+	binary.LittleEndian.PutUint64(header[24:], currentLBA)
+	binary.LittleEndian.PutUint64(header[32:], backupLBA)
+	binary.LittleEndian.PutUint64(header[40:], gptFirstUsableLBA)
+	binary.LittleEndian.PutUint64(header[48:], lastUsableLBA)
+	copy(header[56:], diskGUID[:])
+	binary.LittleEndian.PutUint64(header[72:], entriesLBA)
+	binary.LittleEndian.PutUint32(header[80:], gptNumEntries)
+	binary.LittleEndian.PutUint32(header[84:], gptEntrySize)
+	binary.LittleEndian.PutUint32(header[88:], entriesCRC)
+	binary.LittleEndian.PutUint32(header[16:], crc32.ChecksumIEEE(header[:gptHeaderSize]))
+	return header
+}
+
+// writeGPT writes a protective MBR, and the primary and backup GPT
+// partition tables with the partitions of the volume. The disk must be
+// large enough to hold the backup partition table after the last
+// structure.
+func writeGPT(w io.WriterAt, lv *gadget.LaidOutVolume, diskSize gadget.Size) error {
+	parts, err := partitions(lv)
+	if err != nil {
+		return err
+	}
+	totalSectors := uint64(diskSize / sectorSize)
+	lastLBA := totalSectors - 1
+	lastUsableLBA := totalSectors - gptBackupSectors - 1
+	for _, ps := range parts {
+		start := uint64(ps.StartOffset / sectorSize)
+		end := uint64((ps.StartOffset + ps.Size) / sectorSize)
+		if start < gptFirstUsableLBA || end-1 > lastUsableLBA {
+			return fmt.Errorf("cannot create partition for structure %v: outside of the GPT usable area", ps)
+		}
+	}
+
+	entries, err := gptPartitionEntries(parts)
+	if err != nil {
+		return err
+	}
+	entriesCRC := crc32.ChecksumIEEE(entries)
+	diskGUID, err := randomGUID()
+	if err != nil {
+		return err
+	}
+
+	protectiveSectors := totalSectors - 1
+	if protectiveSectors > 0xffffffff {
+		protectiveSectors = 0xffffffff
+	}
+	protective := make([]byte, mbrMaxPartitions*mbrPartitionEntrySize+2)
+	copy(protective, mbrEntry(0, 0xee, 1, uint32(protectiveSectors)))
+	protective[len(protective)-2] = 0x55
+	protective[len(protective)-1] = 0xaa
+
+	backupEntriesLBA := lastLBA - gptEntriesSectors
+	for _, chunk := range []struct {
+		data []byte
+		off  uint64
+	}{
+		{protective, mbrPartitionTableOffset},
+		{gptHeader(diskGUID, 1, lastLBA, lastUsableLBA, 2, entriesCRC), sectorSize},
+		{entries, 2 * sectorSize},
+		{entries, backupEntriesLBA * sectorSize},
+		{gptHeader(diskGUID, lastLBA, 1, lastUsableLBA, backupEntriesLBA, entriesCRC), lastLBA * sectorSize},
+	} {
+		if _, err := w.WriteAt(chunk.data, int64(chunk.off)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package image
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/osutil"
+)
+
+var procSelfMountInfo = osutil.ProcSelfMountInfo
+
+// preseedMounts are the filesystems of the host made available to
+// snapd when preseeding.
+var preseedMounts = []string{"proc", "sys", "dev"}
+
+// preseed runs the snapd from the image in a chroot of rootDir, making
+// it seed the image as far as possible without the real device. The
+// resulting state and the generated security profiles stay in the
+// image, the remaining seeding happens on first boot.
+func preseed(rootDir string) (err error) {
+	snapd := filepath.Join(rootDir, "usr/lib/snapd/snapd")
+	if !osutil.FileExists(snapd) {
+		return fmt.Errorf("cannot preseed: %q has no snapd in usr/lib/snapd", rootDir)
+	}
+
+	var mounted []string
+	defer func() {
+		if umountErr := unmountPreseed(rootDir, mounted); umountErr != nil && err == nil {
+			err = umountErr
+		}
+	}()
+	for _, fs := range preseedMounts {
+		target := filepath.Join(rootDir, fs)
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return err
+		}
+		if err := runCommand("mount", "--bind", "/"+fs, target); err != nil {
+			return err
+		}
+		mounted = append(mounted, target)
+	}
+
+	cmd := exec.Command("chroot", rootDir, "/usr/lib/snapd/snapd")
+	cmd.Env = append(os.Environ(), "SNAPD_PRESEED=1", "SNAP_REEXEC=0")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("cannot preseed: %s", osutil.OutputErr(output, err))
+	}
+	return nil
+}
+
+// unmountPreseed unmounts what got mounted under rootDir while
+// preseeding, including the snaps mounted by snapd.
+func unmountPreseed(rootDir string, mounted []string) error {
+	entries, err := osutil.LoadMountInfo(procSelfMountInfo)
+	if err != nil {
+		return fmt.Errorf("cannot unmount preseeded snaps: %v", err)
+	}
+	snapMounts := filepath.Join(rootDir, "snap") + "/"
+	var snaps []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.MountDir, snapMounts) {
+			snaps = append(snaps, entry.MountDir)
+		}
+	}
+	sort.Strings(snaps)
+	for i := len(snaps) - 1; i >= 0; i-- {
+		if err := runCommand("umount", snaps[i]); err != nil {
+			return err
+		}
+	}
+	for i := len(mounted) - 1; i >= 0; i-- {
+		if err := runCommand("umount", mounted[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
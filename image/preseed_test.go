@@ -0,0 +1,127 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package image_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/image"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type preseedSuite struct {
+	rootDir string
+
+	chroot *testutil.MockCmd
+	mount  *testutil.MockCmd
+	umount *testutil.MockCmd
+
+	restore func()
+}
+
+var _ = Suite(&preseedSuite{})
+
+func (s *preseedSuite) SetUpTest(c *C) {
+	s.rootDir = c.MkDir()
+	snapd := filepath.Join(s.rootDir, "usr/lib/snapd/snapd")
+	c.Assert(os.MkdirAll(filepath.Dir(snapd), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(snapd, nil, 0755), IsNil)
+
+	envFile := filepath.Join(s.rootDir, "env")
+	s.chroot = testutil.MockCommand(c, "chroot", fmt.Sprintf(`echo "$SNAPD_PRESEED" > %s`, envFile))
+	s.mount = testutil.MockCommand(c, "mount", "")
+	s.umount = testutil.MockCommand(c, "umount", "")
+
+	mountInfo := filepath.Join(c.MkDir(), "mountinfo")
+	content := fmt.Sprintf(`26 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+100 26 7:1 / %[1]s/snap/core/1 ro,nodev,relatime shared:50 - squashfs /dev/loop1 ro
+101 26 7:2 / %[1]s/snap/pc/2 ro,nodev,relatime shared:51 - squashfs /dev/loop2 ro
+102 26 7:3 / /snap/core/3 ro,nodev,relatime shared:52 - squashfs /dev/loop3 ro
+`, s.rootDir)
+	c.Assert(ioutil.WriteFile(mountInfo, []byte(content), 0644), IsNil)
+	s.restore = image.MockProcSelfMountInfo(mountInfo)
+}
+
+func (s *preseedSuite) TearDownTest(c *C) {
+	s.chroot.Restore()
+	s.mount.Restore()
+	s.umount.Restore()
+	s.restore()
+}
+
+func (s *preseedSuite) TestPreseedHappy(c *C) {
+	err := image.Preseed(s.rootDir)
+	c.Assert(err, IsNil)
+
+	c.Check(s.mount.Calls(), DeepEquals, [][]string{
+		{"mount", "--bind", "/proc", filepath.Join(s.rootDir, "proc")},
+		{"mount", "--bind", "/sys", filepath.Join(s.rootDir, "sys")},
+		{"mount", "--bind", "/dev", filepath.Join(s.rootDir, "dev")},
+	})
+	c.Check(s.chroot.Calls(), DeepEquals, [][]string{
+		{"chroot", s.rootDir, "/usr/lib/snapd/snapd"},
+	})
+	c.Check(filepath.Join(s.rootDir, "env"), testutil.FileEquals, "1\n")
+	c.Check(s.umount.Calls(), DeepEquals, [][]string{
+		{"umount", filepath.Join(s.rootDir, "snap/pc/2")},
+		{"umount", filepath.Join(s.rootDir, "snap/core/1")},
+		{"umount", filepath.Join(s.rootDir, "dev")},
+		{"umount", filepath.Join(s.rootDir, "sys")},
+		{"umount", filepath.Join(s.rootDir, "proc")},
+	})
+}
+
+func (s *preseedSuite) TestPreseedNoSnapd(c *C) {
+	c.Assert(os.Remove(filepath.Join(s.rootDir, "usr/lib/snapd/snapd")), IsNil)
+
+	err := image.Preseed(s.rootDir)
+	c.Assert(err, ErrorMatches, `cannot preseed: ".*" has no snapd in usr/lib/snapd`)
+	c.Check(s.mount.Calls(), HasLen, 0)
+	c.Check(s.chroot.Calls(), HasLen, 0)
+}
+
+func (s *preseedSuite) TestPreseedSnapdFails(c *C) {
+	s.chroot.Restore()
+	s.chroot = testutil.MockCommand(c, "chroot", "echo seeding failed; exit 1")
+
+	err := image.Preseed(s.rootDir)
+	c.Assert(err, ErrorMatches, `cannot preseed: seeding failed`)
+	// everything still got unmounted
+	c.Check(s.umount.Calls(), HasLen, 5)
+}
+
+func (s *preseedSuite) TestPreseedMountFails(c *C) {
+	s.mount.Restore()
+	s.mount = testutil.MockCommand(c, "mount", `if [ "$2" = /sys ]; then echo no sys; exit 1; fi`)
+
+	err := image.Preseed(s.rootDir)
+	c.Assert(err, ErrorMatches, `cannot run \[mount --bind /sys .*\]: no sys`)
+	c.Check(s.chroot.Calls(), HasLen, 0)
+	c.Check(s.umount.Calls(), DeepEquals, [][]string{
+		{"umount", filepath.Join(s.rootDir, "snap/pc/2")},
+		{"umount", filepath.Join(s.rootDir, "snap/core/1")},
+		{"umount", filepath.Join(s.rootDir, "proc")},
+	})
+}
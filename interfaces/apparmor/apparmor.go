@@ -35,6 +35,7 @@ import (
 
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/release"
 )
 
 // LoadProfile loads an apparmor profile from the given file.
@@ -56,6 +57,11 @@ func UnloadProfile(name string) error {
 func loadProfile(fname, cacheDir string) error {
 	// Use no-expr-simplify since expr-simplify is actually slower on armhf (LP: #1383858)
 	args := []string{"--replace", "--write-cache", "-O", "no-expr-simplify", fmt.Sprintf("--cache-loc=%s", cacheDir)}
+	if release.Preseeding {
+		// only compile the profile into the cache, it is loaded
+		// on first boot of the preseeded image
+		args[0] = "--skip-kernel-load"
+	}
 	if !osutil.GetenvBool("SNAPD_DEBUG") {
 		args = append(args, "--quiet")
 	}
@@ -165,7 +165,11 @@ func (b *Backend) Initialize() error {
 	}
 
 	// We are not using apparmor.LoadProfile() because it uses other cache.
-	cmd := exec.Command("apparmor_parser", "--replace",
+	loadFlag := "--replace"
+	if release.Preseeding {
+		loadFlag = "--skip-kernel-load"
+	}
+	cmd := exec.Command("apparmor_parser", loadFlag,
 		// Use no-expr-simplify since expr-simplify is actually slower on armhf (LP: #1383858)
 		"-O", "no-expr-simplify",
 		"--write-cache", "--cache-loc", dirs.SystemApparmorCacheDir,
@@ -136,6 +136,11 @@ func (spec *Specification) AddSnapLayout(si *snap.Info) {
 		var buf bytes.Buffer
 		l := si.Layout[path]
 		fmt.Fprintf(&buf, "  # Layout %s\n", l)
+		if l.IsUserScoped() {
+			userLayoutUpdateNS(&buf, l)
+			spec.AddUpdateNS(buf.String())
+			continue
+		}
 		path := si.ExpandSnapVariables(l.Path)
 		switch {
 		case l.Bind != "":
@@ -144,7 +149,7 @@ func (spec *Specification) AddSnapLayout(si *snap.Info) {
 			fmt.Fprintf(&buf, "  mount options=(rbind, rw) %s/ -> %s/,\n", bind, path)
 			fmt.Fprintf(&buf, "  umount %s/,\n", path)
 			// Allow constructing writable mimic in both bind-mount source and mount point.
-			WritableProfile(&buf, path)
+			layoutWritableProfile(&buf, path, false, si.MountDir())
 			WritableProfile(&buf, bind)
 		case l.BindFile != "":
 			bindFile := si.ExpandSnapVariables(l.BindFile)
@@ -152,22 +157,77 @@ func (spec *Specification) AddSnapLayout(si *snap.Info) {
 			fmt.Fprintf(&buf, "  mount options=(bind, rw) %s -> %s,\n", bindFile, path)
 			fmt.Fprintf(&buf, "  umount %s,\n", path)
 			// Allow constructing writable mimic in both bind-mount source and mount point.
-			WritableFileProfile(&buf, path)
+			layoutWritableProfile(&buf, path, true, si.MountDir())
 			WritableFileProfile(&buf, bindFile)
 		case l.Type == "tmpfs":
 			fmt.Fprintf(&buf, "  mount fstype=tmpfs tmpfs -> %s/,\n", path)
 			fmt.Fprintf(&buf, "  umount %s/,\n", path)
 			// Allow constructing writable mimic to mount point.
-			WritableProfile(&buf, path)
+			layoutWritableProfile(&buf, path, false, si.MountDir())
 		case l.Symlink != "":
 			// Allow constructing writable mimic to symlink parent directory.
 			fmt.Fprintf(&buf, "  %s rw,\n", path)
-			WritableProfile(&buf, path)
+			layoutWritableProfile(&buf, path, false, si.MountDir())
 		}
 		spec.AddUpdateNS(buf.String())
 	}
 }
 
+// userLayoutUpdateNS writes snap-update-ns rules for constructing a user-scoped layout.
+//
+// User-scoped layouts are constructed in the per-user mount namespace, on
+// behalf of the user invoking the snap. All the paths are in the home
+// directory of that user so writable mimics are never required.
+func userLayoutUpdateNS(buf *bytes.Buffer, l *snap.Layout) {
+	si := l.Snap
+	path := homeToAppArmor(si.ExpandSnapVariables(l.Path))
+	switch {
+	case l.Bind != "":
+		bind := homeToAppArmor(si.ExpandSnapVariables(l.Bind))
+		fmt.Fprintf(buf, "  mount options=(rbind, rw) %s/ -> %s/,\n", bind, path)
+		fmt.Fprintf(buf, "  umount %s/,\n", path)
+		UserWritableProfile(buf, path)
+		UserWritableProfile(buf, bind)
+	case l.BindFile != "":
+		bindFile := homeToAppArmor(si.ExpandSnapVariables(l.BindFile))
+		fmt.Fprintf(buf, "  mount options=(bind, rw) %s -> %s,\n", bindFile, path)
+		fmt.Fprintf(buf, "  umount %s,\n", path)
+		UserWritableFileProfile(buf, path)
+		UserWritableFileProfile(buf, bindFile)
+	case l.Type == "tmpfs":
+		fmt.Fprintf(buf, "  mount fstype=tmpfs tmpfs -> %s/,\n", path)
+		fmt.Fprintf(buf, "  umount %s/,\n", path)
+		UserWritableProfile(buf, path)
+	case l.Symlink != "":
+		UserWritableFileProfile(buf, path)
+	}
+}
+
+// homeToAppArmor replaces the leading $HOME with the apparmor @{HOME} variable.
+func homeToAppArmor(path string) string {
+	if path == "$HOME" || strings.HasPrefix(path, "$HOME/") {
+		return "@{HOME}" + path[len("$HOME"):]
+	}
+	return path
+}
+
+// UserWritableFileProfile writes a profile for snap-update-ns for creating given file in @{HOME}.
+func UserWritableFileProfile(buf *bytes.Buffer, path string) {
+	fmt.Fprintf(buf, "  # Writable user file %s\n", path)
+	fmt.Fprintf(buf, "  owner %s rw,\n", path)
+	for p := parent(path); p != "@{HOME}" && p != "/" && p != "."; p = parent(p) {
+		fmt.Fprintf(buf, "  owner %s/ rw,\n", p)
+	}
+}
+
+// UserWritableProfile writes a profile for snap-update-ns for creating given directory in @{HOME}.
+func UserWritableProfile(buf *bytes.Buffer, path string) {
+	fmt.Fprintf(buf, "  # Writable user directory %s\n", path)
+	for p := path; p != "@{HOME}" && p != "/" && p != "."; p = parent(p) {
+		fmt.Fprintf(buf, "  owner %s/ rw,\n", p)
+	}
+}
+
 // isProbably writable returns true if the path is probably representing writable area.
 func isProbablyWritable(path string) bool {
 	return strings.HasPrefix(path, "/var/snap/") || strings.HasPrefix(path, "/home/") || strings.HasPrefix(path, "/root/")
@@ -194,29 +254,7 @@ func WritableFileProfile(buf *bytes.Buffer, path string) {
 			fmt.Fprintf(buf, "  %s/ rw,\n", p)
 		}
 	} else {
-		parentPath := parent(path)
-		fmt.Fprintf(buf, "  # Writable mimic %s\n", parentPath)
-		// Allow setting the read-only directory aside via a bind mount.
-		fmt.Fprintf(buf, "  mount options=(rbind, rw) %s/ -> /tmp/.snap%s/,\n", parentPath, parentPath)
-		// Allow mounting tmpfs over the read-only directory.
-		fmt.Fprintf(buf, "  mount fstype=tmpfs options=(rw) tmpfs -> %s/,\n", parentPath)
-		// Allow bind mounting things to reconstruct the now-writable parent directory.
-		fmt.Fprintf(buf, "  mount options=(rbind, rw) /tmp/.snap%s/** -> %s/**,\n", parentPath, parentPath)
-		fmt.Fprintf(buf, "  mount options=(bind, rw) /tmp/.snap%s/* -> %s/*,\n", parentPath, parentPath)
-		// Allow unmounting the temporary directory.
-		fmt.Fprintf(buf, "  umount /tmp/.snap%s/,\n", parentPath)
-		// Allow unmounting the destination directory as well as anything inside.
-		// This lets us perform the undo plan in case the writable mimic fails.
-		fmt.Fprintf(buf, "  umount %s{,/**},\n", parentPath)
-		// Allow creating directories on demand.
-		fmt.Fprintf(buf, "  %s/** rw,\n", parentPath)
-		for p := parentPath; !isProbablyPresent(p); p = parent(p) {
-			fmt.Fprintf(buf, "  %s/ rw,\n", p)
-		}
-		fmt.Fprintf(buf, "  /tmp/.snap%s/** rw,\n", parentPath)
-		for p := filepath.Join("/tmp/.snap/", parentPath); !isProbablyPresent(p); p = parent(p) {
-			fmt.Fprintf(buf, "  %s/ rw,\n", p)
-		}
+		writableMimicProfile(buf, parent(path))
 	}
 }
 
@@ -231,32 +269,67 @@ func WritableProfile(buf *bytes.Buffer, path string) {
 			fmt.Fprintf(buf, "  %s/ rw,\n", p)
 		}
 	} else {
-		parentPath := parent(path)
-		fmt.Fprintf(buf, "  # Writable mimic %s\n", parentPath)
-		// Allow setting the read-only directory aside via a bind mount.
-		fmt.Fprintf(buf, "  mount options=(rbind, rw) %s/ -> /tmp/.snap%s/,\n", parentPath, parentPath)
-		// Allow mounting tmpfs over the read-only directory.
-		fmt.Fprintf(buf, "  mount fstype=tmpfs options=(rw) tmpfs -> %s/,\n", parentPath)
-		// Allow bind mounting things to reconstruct the now-writable parent directory.
-		fmt.Fprintf(buf, "  mount options=(rbind, rw) /tmp/.snap%s/** -> %s/**,\n", parentPath, parentPath)
-		fmt.Fprintf(buf, "  mount options=(bind, rw) /tmp/.snap%s/* -> %s/*,\n", parentPath, parentPath)
-		// Allow unmounting the temporary directory.
-		fmt.Fprintf(buf, "  umount /tmp/.snap%s/,\n", parentPath)
-		// Allow unmounting the destination directory as well as anything inside.
-		// This lets us perform the undo plan in case the writable mimic fails.
-		fmt.Fprintf(buf, "  umount %s{,/**},\n", parentPath)
-		// Allow creating directories on demand.
-		fmt.Fprintf(buf, "  %s/** rw,\n", parentPath)
-		for p := parentPath; !isProbablyPresent(p); p = parent(p) {
-			fmt.Fprintf(buf, "  %s/ rw,\n", p)
+		writableMimicProfile(buf, parent(path))
+	}
+}
+
+// layoutWritableProfile writes a profile for snap-update-ns for creating the mount point of a layout.
+//
+// Layouts can be placed deep inside read-only directories, where any number
+// of leading directories may be missing (e.g. /usr/share/foo/bar). The
+// writable mimic is constructed over the deepest directory that exists, which
+// is not known in advance, so the profile allows constructing one over each
+// ancestor of the mount point that may be missing itself, plus the one right
+// below them. Directories of the skeleton file-system tree are always present
+// so the walk stops right below them, unless the mount point is placed
+// directly inside one. The walk also stops at the mount directory of the snap
+// as nothing above it may be replaced.
+func layoutWritableProfile(buf *bytes.Buffer, path string, isFile bool, snapMountDir string) {
+	if path == "/" {
+		return
+	}
+	if isProbablyWritable(path) {
+		if isFile {
+			WritableFileProfile(buf, path)
+		} else {
+			WritableProfile(buf, path)
 		}
-		fmt.Fprintf(buf, "  /tmp/.snap%s/** rw,\n", parentPath)
-		for p := filepath.Join("/tmp/.snap/", parentPath); !isProbablyPresent(p); p = parent(p) {
-			fmt.Fprintf(buf, "  %s/ rw,\n", p)
+		return
+	}
+	for p := parent(path); p != "/" && !strings.HasPrefix(snapMountDir, p+"/"); p = parent(p) {
+		writableMimicProfile(buf, p)
+		if isProbablyPresent(parent(p)) {
+			break
 		}
 	}
 }
 
+// writableMimicProfile writes a profile for snap-update-ns for constructing a writable mimic over given directory.
+func writableMimicProfile(buf *bytes.Buffer, dir string) {
+	fmt.Fprintf(buf, "  # Writable mimic %s\n", dir)
+	// Allow setting the read-only directory aside via a bind mount.
+	fmt.Fprintf(buf, "  mount options=(rbind, rw) %s/ -> /tmp/.snap%s/,\n", dir, dir)
+	// Allow mounting tmpfs over the read-only directory.
+	fmt.Fprintf(buf, "  mount fstype=tmpfs options=(rw) tmpfs -> %s/,\n", dir)
+	// Allow bind mounting things to reconstruct the now-writable parent directory.
+	fmt.Fprintf(buf, "  mount options=(rbind, rw) /tmp/.snap%s/** -> %s/**,\n", dir, dir)
+	fmt.Fprintf(buf, "  mount options=(bind, rw) /tmp/.snap%s/* -> %s/*,\n", dir, dir)
+	// Allow unmounting the temporary directory.
+	fmt.Fprintf(buf, "  umount /tmp/.snap%s/,\n", dir)
+	// Allow unmounting the destination directory as well as anything inside.
+	// This lets us perform the undo plan in case the writable mimic fails.
+	fmt.Fprintf(buf, "  umount %s{,/**},\n", dir)
+	// Allow creating directories on demand.
+	fmt.Fprintf(buf, "  %s/** rw,\n", dir)
+	for p := dir; !isProbablyPresent(p); p = parent(p) {
+		fmt.Fprintf(buf, "  %s/ rw,\n", p)
+	}
+	fmt.Fprintf(buf, "  /tmp/.snap%s/** rw,\n", dir)
+	for p := filepath.Join("/tmp/.snap/", dir); !isProbablyPresent(p); p = parent(p) {
+		fmt.Fprintf(buf, "  %s/ rw,\n", p)
+	}
+}
+
 // parent returns the parent directory of a given path.
 func parent(path string) string {
 	result, _ := filepath.Split(path)
@@ -292,10 +365,16 @@ func (spec *Specification) UpdateNS() map[string][]string {
 
 func snippetFromLayout(layout *snap.Layout) string {
 	mountPoint := layout.Snap.ExpandSnapVariables(layout.Path)
+	// Elements of user-scoped layouts are owned by the user.
+	var owner string
+	if layout.IsUserScoped() {
+		mountPoint = homeToAppArmor(mountPoint)
+		owner = "owner "
+	}
 	if layout.Bind != "" || layout.Type == "tmpfs" {
-		return fmt.Sprintf("# Layout path: %s\n%s{,/**} mrwklix,", mountPoint, mountPoint)
+		return fmt.Sprintf("# Layout path: %s\n%s%s{,/**} mrwklix,", mountPoint, owner, mountPoint)
 	} else if layout.BindFile != "" {
-		return fmt.Sprintf("# Layout path: %s\n%s mrwklix,", mountPoint, mountPoint)
+		return fmt.Sprintf("# Layout path: %s\n%s%s mrwklix,", mountPoint, owner, mountPoint)
 	}
 	return fmt.Sprintf("# Layout path: %s\n# (no extra permissions required for symlink)", mountPoint)
 }
@@ -20,6 +20,8 @@
 package apparmor_test
 
 import (
+	"strings"
+
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/interfaces"
@@ -280,3 +282,98 @@ func (s *specSuite) TestApparmorSnippetsFromLayout(c *C) {
 	c.Assert(updateNS[3], Equals, profile3)
 	c.Assert(updateNS, DeepEquals, []string{profile0, profile1, profile2, profile3})
 }
+
+const snapWithDeepLayout = `
+name: vanguard
+version: 0
+apps:
+  vanguard:
+    command: vanguard
+layout:
+  /usr/share/vanguard/data:
+    type: tmpfs
+  $SNAP/deep/dir:
+    type: tmpfs
+`
+
+func (s *specSuite) TestApparmorSnippetsFromDeepLayout(c *C) {
+	snapInfo := snaptest.MockInfo(c, snapWithDeepLayout, &snap.SideInfo{Revision: snap.R(42)})
+	restore := apparmor.SetSpecScope(s.spec, []string{"snap.vanguard.vanguard"}, "vanguard")
+	defer restore()
+
+	s.spec.AddSnapLayout(snapInfo)
+	updateNS := s.spec.UpdateNS()["vanguard"]
+	c.Assert(updateNS, HasLen, 2)
+
+	// Mimics are never constructed above the mount directory of the snap.
+	c.Check(writableMimics(updateNS[0]), DeepEquals, []string{"/snap/vanguard/42/deep", "/snap/vanguard/42"})
+	// The writable mimic may be needed over any of the ancestors of the
+	// mount point, depending on which of them are missing, but never over
+	// the directories of the skeleton file-system tree.
+	c.Check(writableMimics(updateNS[1]), DeepEquals, []string{"/usr/share/vanguard", "/usr/share"})
+}
+
+// writableMimics returns the directories of all the writable mimics allowed by given snippet.
+func writableMimics(snippet string) []string {
+	var mimics []string
+	for _, line := range strings.Split(snippet, "\n") {
+		if strings.HasPrefix(line, "  # Writable mimic ") {
+			mimics = append(mimics, strings.TrimPrefix(line, "  # Writable mimic "))
+		}
+	}
+	return mimics
+}
+
+const snapWithUserLayout = `
+name: vanguard
+version: 0
+apps:
+  vanguard:
+    command: vanguard
+layout:
+  $HOME/.config/vanguard:
+    bind: $SNAP_USER_DATA/.config/vanguard
+  $HOME/.vanguardrc:
+    bind-file: $SNAP_USER_COMMON/vanguardrc
+`
+
+func (s *specSuite) TestApparmorSnippetsFromUserLayout(c *C) {
+	snapInfo := snaptest.MockInfo(c, snapWithUserLayout, &snap.SideInfo{Revision: snap.R(42)})
+	restore := apparmor.SetSpecScope(s.spec, []string{"snap.vanguard.vanguard"}, "vanguard")
+	defer restore()
+
+	s.spec.AddSnapLayout(snapInfo)
+	c.Assert(s.spec.Snippets(), DeepEquals, map[string][]string{
+		"snap.vanguard.vanguard": {
+			"# Layout path: @{HOME}/.config/vanguard\nowner @{HOME}/.config/vanguard{,/**} mrwklix,",
+			"# Layout path: @{HOME}/.vanguardrc\nowner @{HOME}/.vanguardrc mrwklix,",
+		},
+	})
+
+	profile0 := `  # Layout $HOME/.config/vanguard: bind $SNAP_USER_DATA/.config/vanguard
+  mount options=(rbind, rw) @{HOME}/snap/vanguard/42/.config/vanguard/ -> @{HOME}/.config/vanguard/,
+  umount @{HOME}/.config/vanguard/,
+  # Writable user directory @{HOME}/.config/vanguard
+  owner @{HOME}/.config/vanguard/ rw,
+  owner @{HOME}/.config/ rw,
+  # Writable user directory @{HOME}/snap/vanguard/42/.config/vanguard
+  owner @{HOME}/snap/vanguard/42/.config/vanguard/ rw,
+  owner @{HOME}/snap/vanguard/42/.config/ rw,
+  owner @{HOME}/snap/vanguard/42/ rw,
+  owner @{HOME}/snap/vanguard/ rw,
+  owner @{HOME}/snap/ rw,
+`
+	profile1 := `  # Layout $HOME/.vanguardrc: bind-file $SNAP_USER_COMMON/vanguardrc
+  mount options=(bind, rw) @{HOME}/snap/vanguard/common/vanguardrc -> @{HOME}/.vanguardrc,
+  umount @{HOME}/.vanguardrc,
+  # Writable user file @{HOME}/.vanguardrc
+  owner @{HOME}/.vanguardrc rw,
+  # Writable user file @{HOME}/snap/vanguard/common/vanguardrc
+  owner @{HOME}/snap/vanguard/common/vanguardrc rw,
+  owner @{HOME}/snap/vanguard/common/ rw,
+  owner @{HOME}/snap/vanguard/ rw,
+  owner @{HOME}/snap/ rw,
+`
+	updateNS := s.spec.UpdateNS()["vanguard"]
+	c.Assert(updateNS, DeepEquals, []string{profile0, profile1})
+}
@@ -574,6 +574,15 @@ profile snap-update-ns.###SNAP_NAME### (attach_disconnected) {
   # snapd and represent the desired layout and content connections.
   /var/lib/snapd/mount/snap.###SNAP_NAME###.fstab r,
 
+  # Allow reading per-snap desired user mount profiles. Those are written by
+  # snapd and represent the desired user-scoped layout and per-user mounts.
+  /var/lib/snapd/mount/snap.###SNAP_NAME###.user-fstab r,
+
+  # Allow looking up the home directory of the user on whose behalf the
+  # per-user mount profile is applied.
+  /etc/passwd r,
+  /etc/nsswitch.conf r,
+
   # Allow reading and writing actual per-snap mount profiles. Note that
   # the wildcard in the rule to allow an atomic write + rename strategy.
   # Those files are written by snap-update-ns and represent the actual
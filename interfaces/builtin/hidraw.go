@@ -27,6 +27,7 @@ import (
 
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/hotplug"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/snap"
 )
@@ -189,6 +190,18 @@ SUBSYSTEM=="hidraw", SUBSYSTEMS=="usb", ATTRS{idVendor}=="%04x", ATTRS{idProduct
 	return nil
 }
 
+// HotplugDeviceDetected creates a slot for hidraw devices plugged into the system.
+func (iface *hidrawInterface) HotplugDeviceDetected(di *hotplug.HotplugDeviceInfo, spec *hotplug.Specification) error {
+	if di.Subsystem() != "hidraw" || !hidrawDeviceNodePattern.MatchString(di.DeviceName()) {
+		return nil
+	}
+	return spec.SetSlot(&hotplug.RequestedSlotSpec{
+		Attrs: map[string]interface{}{
+			"path": di.DeviceName(),
+		},
+	})
+}
+
 func (iface *hidrawInterface) AutoConnect(*interfaces.Plug, *interfaces.Slot) bool {
 	// allow what declarations allowed
 	return true
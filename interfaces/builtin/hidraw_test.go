@@ -25,6 +25,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/hotplug"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
@@ -353,3 +354,30 @@ SUBSYSTEM=="hidraw", SUBSYSTEMS=="usb", ATTRS{idVendor}=="ffff", ATTRS{idProduct
 func (s *HidrawInterfaceSuite) TestInterfaces(c *C) {
 	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
 }
+
+func (s *HidrawInterfaceSuite) TestHotplugDeviceDetected(c *C) {
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{
+		"DEVPATH":   "/devices/pci0000:00/0000:00:14.0/usb1/1-3/1-3:1.0/0003:046D:C52B.0001/hidraw/hidraw0",
+		"DEVNAME":   "/dev/hidraw0",
+		"SUBSYSTEM": "hidraw",
+	})
+	c.Assert(err, IsNil)
+	spec := hotplug.NewSpecification()
+	err = s.iface.(hotplug.Definer).HotplugDeviceDetected(di, spec)
+	c.Assert(err, IsNil)
+	c.Assert(spec.Slot(), DeepEquals, &hotplug.RequestedSlotSpec{
+		Attrs: map[string]interface{}{"path": "/dev/hidraw0"},
+	})
+}
+
+func (s *HidrawInterfaceSuite) TestHotplugDeviceDetectedIgnored(c *C) {
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{
+		"DEVPATH":   "/devices/pci0000:00/0000:00:14.0/usb1/1-3/1-3:1.0/0003:046D:C52B.0001/input/input5",
+		"SUBSYSTEM": "input",
+	})
+	c.Assert(err, IsNil)
+	spec := hotplug.NewSpecification()
+	err = s.iface.(hotplug.Definer).HotplugDeviceDetected(di, spec)
+	c.Assert(err, IsNil)
+	c.Check(spec.Slot(), IsNil)
+}
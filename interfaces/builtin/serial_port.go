@@ -27,6 +27,7 @@ import (
 
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
+	"github.com/snapcore/snapd/interfaces/hotplug"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/snap"
 )
@@ -202,6 +203,21 @@ SUBSYSTEM=="tty", SUBSYSTEMS=="usb", ATTRS{idVendor}=="%04x", ATTRS{idProduct}==
 	return nil
 }
 
+// HotplugDeviceDetected creates a slot for USB serial adapters plugged into the system.
+func (iface *serialPortInterface) HotplugDeviceDetected(di *hotplug.HotplugDeviceInfo, spec *hotplug.Specification) error {
+	if di.Subsystem() != "tty" || !serialDeviceNodePattern.MatchString(di.DeviceName()) {
+		return nil
+	}
+	if bus, _ := di.Attribute("ID_BUS"); bus != "usb" {
+		return nil
+	}
+	return spec.SetSlot(&hotplug.RequestedSlotSpec{
+		Attrs: map[string]interface{}{
+			"path": di.DeviceName(),
+		},
+	})
+}
+
 func (iface *serialPortInterface) AutoConnect(*interfaces.Plug, *interfaces.Slot) bool {
 	// allow what declarations allowed
 	return true
@@ -25,6 +25,7 @@ import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/apparmor"
 	"github.com/snapcore/snapd/interfaces/builtin"
+	"github.com/snapcore/snapd/interfaces/hotplug"
 	"github.com/snapcore/snapd/interfaces/udev"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/snaptest"
@@ -539,3 +540,37 @@ SUBSYSTEM=="tty", SUBSYSTEMS=="usb", ATTRS{idVendor}=="ffff", ATTRS{idProduct}==
 func (s *SerialPortInterfaceSuite) TestInterfaces(c *C) {
 	c.Check(builtin.Interfaces(), testutil.DeepContains, s.iface)
 }
+
+func (s *SerialPortInterfaceSuite) TestHotplugDeviceDetected(c *C) {
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{
+		"DEVPATH":   "/devices/pci0000:00/0000:00:14.0/usb2/2-1/2-1:1.0/ttyUSB0/tty/ttyUSB0",
+		"DEVNAME":   "/dev/ttyUSB0",
+		"SUBSYSTEM": "tty",
+		"ID_BUS":    "usb",
+	})
+	c.Assert(err, IsNil)
+	spec := hotplug.NewSpecification()
+	err = s.iface.(hotplug.Definer).HotplugDeviceDetected(di, spec)
+	c.Assert(err, IsNil)
+	c.Assert(spec.Slot(), DeepEquals, &hotplug.RequestedSlotSpec{
+		Attrs: map[string]interface{}{"path": "/dev/ttyUSB0"},
+	})
+}
+
+func (s *SerialPortInterfaceSuite) TestHotplugDeviceDetectedIgnored(c *C) {
+	for _, env := range []map[string]string{
+		// not an USB device
+		{"DEVPATH": "/devices/platform/serial8250/tty/ttyS0", "DEVNAME": "/dev/ttyS0", "SUBSYSTEM": "tty"},
+		// not a serial port
+		{"DEVPATH": "/devices/virtual/tty/tty1", "DEVNAME": "/dev/tty1", "SUBSYSTEM": "tty", "ID_BUS": "usb"},
+		// not a tty
+		{"DEVPATH": "/devices/pci0000:00/0000:00:14.0/usb2/2-1", "DEVNAME": "/dev/bus/usb/002/002", "SUBSYSTEM": "usb", "ID_BUS": "usb"},
+	} {
+		di, err := hotplug.NewHotplugDeviceInfo(env)
+		c.Assert(err, IsNil)
+		spec := hotplug.NewSpecification()
+		err = s.iface.(hotplug.Definer).HotplugDeviceDetected(di, spec)
+		c.Assert(err, IsNil)
+		c.Check(spec.Slot(), IsNil, Commentf("%v", env))
+	}
+}
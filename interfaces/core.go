@@ -51,6 +51,15 @@ func BeforePreparePlug(iface Interface, plugInfo *snap.PlugInfo) error {
 	return err
 }
 
+// PlugInfoWithDynamicAttrs returns a copy of the plug where the given dynamic
+// attributes are merged into the static ones, so that they can be sanitized
+// and checked against the policy as if they were static.
+func PlugInfoWithDynamicAttrs(plugInfo *snap.PlugInfo, dynamicAttrs map[string]interface{}) *snap.PlugInfo {
+	plugCopy := *plugInfo
+	plugCopy.Attrs = mergeDynamicAttrs(plugInfo.Attrs, dynamicAttrs)
+	return &plugCopy
+}
+
 // PlugRef is a reference to a plug.
 type PlugRef struct {
 	Snap string `json:"snap"`
@@ -86,6 +95,26 @@ func BeforePrepareSlot(iface Interface, slotInfo *snap.SlotInfo) error {
 	return err
 }
 
+// SlotInfoWithDynamicAttrs returns a copy of the slot where the given dynamic
+// attributes are merged into the static ones, so that they can be sanitized
+// and checked against the policy as if they were static.
+func SlotInfoWithDynamicAttrs(slotInfo *snap.SlotInfo, dynamicAttrs map[string]interface{}) *snap.SlotInfo {
+	slotCopy := *slotInfo
+	slotCopy.Attrs = mergeDynamicAttrs(slotInfo.Attrs, dynamicAttrs)
+	return &slotCopy
+}
+
+func mergeDynamicAttrs(staticAttrs, dynamicAttrs map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(staticAttrs)+len(dynamicAttrs))
+	for key, value := range staticAttrs {
+		merged[key] = copyRecursive(value)
+	}
+	for key, value := range dynamicAttrs {
+		merged[key] = normalize(copyRecursive(value))
+	}
+	return merged
+}
+
 // SlotRef is a reference to a slot.
 type SlotRef struct {
 	Snap string `json:"snap"`
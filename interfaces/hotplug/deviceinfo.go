@@ -0,0 +1,78 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package hotplug
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// HotplugDeviceInfo carries information about a device as reported by udev.
+type HotplugDeviceInfo struct {
+	// map of all attributes returned for given uevent.
+	data map[string]string
+}
+
+// NewHotplugDeviceInfo creates HotplugDeviceInfo structure related to udev add or remove event.
+func NewHotplugDeviceInfo(env map[string]string) (*HotplugDeviceInfo, error) {
+	if _, ok := env["DEVPATH"]; !ok {
+		return nil, fmt.Errorf("missing device path attribute")
+	}
+	return &HotplugDeviceInfo{
+		data: env,
+	}, nil
+}
+
+// Subsystem returns the value of "SUBSYSTEM" attribute of the udev event
+// associated with the device, e.g. "usb".
+func (h *HotplugDeviceInfo) Subsystem() string {
+	return h.data["SUBSYSTEM"]
+}
+
+// DevicePath returns full device path under /sys, e.g
+// /sys/devices/pci0000:00/0000:00:14.0/usb1/1-2. The path is derived from
+// DEVPATH attribute of the udev event.
+func (h *HotplugDeviceInfo) DevicePath() string {
+	return filepath.Join("/sys", h.data["DEVPATH"])
+}
+
+// DeviceName returns the value of "DEVNAME" attribute of the udev event
+// associated with the device, e.g. "/dev/ttyUSB1". The value may be empty.
+func (h *HotplugDeviceInfo) DeviceName() string {
+	return h.data["DEVNAME"]
+}
+
+// DeviceType returns the value of "DEVTYPE" attribute of the udev event
+// associated with the device, e.g. "usb_device". The value may be empty.
+func (h *HotplugDeviceInfo) DeviceType() string {
+	return h.data["DEVTYPE"]
+}
+
+// Attribute returns the value of an arbitrary attribute of the udev event.
+func (h *HotplugDeviceInfo) Attribute(name string) (string, bool) {
+	val, ok := h.data[name]
+	return val, ok
+}
+
+func (h *HotplugDeviceInfo) String() string {
+	if name := h.DeviceName(); name != "" {
+		return name
+	}
+	return h.DevicePath()
+}
@@ -0,0 +1,65 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package hotplug_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces/hotplug"
+)
+
+type deviceInfoSuite struct{}
+
+var _ = Suite(&deviceInfoSuite{})
+
+func (s *deviceInfoSuite) TestBasicProperties(c *C) {
+	env := map[string]string{
+		"DEVPATH":   "/devices/pci0000:00/0000:00:14.0/usb2/2-3",
+		"DEVNAME":   "/dev/bus/usb/002/003",
+		"DEVTYPE":   "usb_device",
+		"SUBSYSTEM": "usb",
+		"ID_MODEL":  "Mass_Storage",
+	}
+	di, err := hotplug.NewHotplugDeviceInfo(env)
+	c.Assert(err, IsNil)
+
+	c.Check(di.DevicePath(), Equals, "/sys/devices/pci0000:00/0000:00:14.0/usb2/2-3")
+	c.Check(di.DeviceName(), Equals, "/dev/bus/usb/002/003")
+	c.Check(di.DeviceType(), Equals, "usb_device")
+	c.Check(di.Subsystem(), Equals, "usb")
+	c.Check(di.String(), Equals, "/dev/bus/usb/002/003")
+
+	v, ok := di.Attribute("ID_MODEL")
+	c.Check(ok, Equals, true)
+	c.Check(v, Equals, "Mass_Storage")
+
+	_, ok = di.Attribute("ID_VENDOR")
+	c.Check(ok, Equals, false)
+}
+
+func (s *deviceInfoSuite) TestStringWithoutDeviceName(c *C) {
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVPATH": "/devices/virtual/net/lo"})
+	c.Assert(err, IsNil)
+	c.Check(di.String(), Equals, "/sys/devices/virtual/net/lo")
+}
+
+func (s *deviceInfoSuite) TestMissingDevicePath(c *C) {
+	_, err := hotplug.NewHotplugDeviceInfo(map[string]string{"DEVNAME": "/dev/ttyS0"})
+	c.Assert(err, ErrorMatches, "missing device path attribute")
+}
@@ -0,0 +1,90 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package hotplug
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// EnumerateExistingDevices returns the devices currently known to udev, as
+// reported by "udevadm info -e".
+func EnumerateExistingDevices() ([]*HotplugDeviceInfo, error) {
+	output, err := exec.Command("udevadm", "info", "-e").CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("cannot enumerate existing devices: %v", osutil.OutputErr(output, err))
+	}
+	return parseUdevadmOutput(bytes.NewReader(output))
+}
+
+// parseUdevadmOutput parses the database dump printed by "udevadm info -e".
+// Each device is described by a block of lines separated by an empty line;
+// only the "E:" lines, carrying device properties, are of interest.
+func parseUdevadmOutput(r io.Reader) ([]*HotplugDeviceInfo, error) {
+	var devices []*HotplugDeviceInfo
+	env := make(map[string]string)
+
+	flush := func() error {
+		if len(env) == 0 {
+			return nil
+		}
+		di, err := NewHotplugDeviceInfo(env)
+		if err != nil {
+			// one broken device must not hide the others
+			logger.Noticef("cannot use device from udevadm output: %v", err)
+		} else {
+			devices = append(devices, di)
+		}
+		env = make(map[string]string)
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if !strings.HasPrefix(line, "E: ") {
+			continue
+		}
+		kv := strings.SplitN(line[len("E: "):], "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("cannot parse udevadm output: invalid line %q", line)
+		}
+		env[kv[0]] = kv[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
@@ -0,0 +1,104 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package hotplug_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces/hotplug"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type enumSuite struct{}
+
+var _ = Suite(&enumSuite{})
+
+const udevadmOutput = `P: /devices/virtual/tty/ttyS0
+N: ttyS0
+E: DEVPATH=/devices/virtual/tty/ttyS0
+E: DEVNAME=/dev/ttyS0
+E: MAJOR=4
+E: MINOR=64
+E: SUBSYSTEM=tty
+
+P: /devices/pci0000:00/0000:00:14.0/usb1/1-2/1-2:1.0/tty/ttyACM0
+N: ttyACM0
+S: serial/by-id/usb-STMicro_00000000001A-if00
+E: DEVPATH=/devices/pci0000:00/0000:00:14.0/usb1/1-2/1-2:1.0/tty/ttyACM0
+E: DEVNAME=/dev/ttyACM0
+E: SUBSYSTEM=tty
+E: ID_VENDOR_ID=0483
+E: ID_MODEL_ID=5740
+E: ID_SERIAL=STMicro_00000000001A
+
+`
+
+func (s *enumSuite) TestEnumerateExistingDevices(c *C) {
+	cmd := testutil.MockCommand(c, "udevadm", "cat <<'EOF'\n"+udevadmOutput+"EOF\n")
+	defer cmd.Restore()
+
+	devices, err := hotplug.EnumerateExistingDevices()
+	c.Assert(err, IsNil)
+	c.Assert(cmd.Calls(), DeepEquals, [][]string{{"udevadm", "info", "-e"}})
+	c.Assert(devices, HasLen, 2)
+
+	c.Check(devices[0].DeviceName(), Equals, "/dev/ttyS0")
+	c.Check(devices[0].DevicePath(), Equals, "/sys/devices/virtual/tty/ttyS0")
+	c.Check(devices[0].Subsystem(), Equals, "tty")
+
+	c.Check(devices[1].DeviceName(), Equals, "/dev/ttyACM0")
+	v, ok := devices[1].Attribute("ID_SERIAL")
+	c.Check(ok, Equals, true)
+	c.Check(v, Equals, "STMicro_00000000001A")
+	_, ok = devices[1].Attribute("MAJOR")
+	c.Check(ok, Equals, false)
+}
+
+func (s *enumSuite) TestEnumerateExistingDevicesError(c *C) {
+	cmd := testutil.MockCommand(c, "udevadm", "echo boom; exit 1")
+	defer cmd.Restore()
+
+	_, err := hotplug.EnumerateExistingDevices()
+	c.Assert(err, ErrorMatches, "cannot enumerate existing devices: boom")
+}
+
+func (s *enumSuite) TestEnumerateExistingDevicesBadOutput(c *C) {
+	cmd := testutil.MockCommand(c, "udevadm", "echo 'E: DEVPATH'")
+	defer cmd.Restore()
+
+	_, err := hotplug.EnumerateExistingDevices()
+	c.Assert(err, ErrorMatches, `cannot parse udevadm output: invalid line "E: DEVPATH"`)
+}
+
+func (s *enumSuite) TestEnumerateExistingDevicesSkipsBrokenDevice(c *C) {
+	buf, restore := logger.MockLogger()
+	defer restore()
+
+	// the first device has no DEVPATH
+	cmd := testutil.MockCommand(c, "udevadm", "cat <<'EOF'\nP: /devices/broken\nE: DEVNAME=/dev/broken\n\n"+udevadmOutput+"EOF\n")
+	defer cmd.Restore()
+
+	devices, err := hotplug.EnumerateExistingDevices()
+	c.Assert(err, IsNil)
+	c.Assert(devices, HasLen, 2)
+	c.Check(devices[0].DeviceName(), Equals, "/dev/ttyS0")
+	c.Check(devices[1].DeviceName(), Equals, "/dev/ttyACM0")
+	c.Check(buf.String(), Matches, `(?s).*cannot use device from udevadm output: .*`)
+}
@@ -0,0 +1,73 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+// Package hotplug contains the types used by interfaces that can create
+// slots dynamically, in response to devices being plugged into the system.
+package hotplug
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// Definer can be implemented by any interface that wants to create slots
+// for hotplugged devices. When the interface doesn't handle the given
+// device it should leave the specification untouched.
+type Definer interface {
+	HotplugDeviceDetected(di *HotplugDeviceInfo, spec *Specification) error
+}
+
+// KeyHandler can be implemented by hotplug interfaces that want to
+// identify their devices by something else than the default key.
+type KeyHandler interface {
+	HotplugKey(di *HotplugDeviceInfo) (string, error)
+}
+
+// stableAttributes are the udev attributes that are expected to remain the
+// same when a device is unplugged and plugged in again, in the order they
+// are fed into the default device key.
+var stableAttributes = []string{
+	"ID_VENDOR_ID",
+	"ID_MODEL_ID",
+	"ID_REVISION",
+	"ID_SERIAL",
+	"ID_USB_INTERFACE_NUM",
+	"PCI_SLOT_NAME",
+}
+
+// DefaultDeviceKey computes a key identifying the device across
+// unplug/replug events, based on its stable udev attributes. An empty key is
+// returned when the device doesn't carry enough information to be
+// identified reliably.
+func DefaultDeviceKey(di *HotplugDeviceInfo) string {
+	_, hasVendor := di.Attribute("ID_VENDOR_ID")
+	_, hasModel := di.Attribute("ID_MODEL_ID")
+	_, hasSerial := di.Attribute("ID_SERIAL")
+	_, hasPCISlot := di.Attribute("PCI_SLOT_NAME")
+	if !(hasVendor && hasModel) && !hasSerial && !hasPCISlot {
+		return ""
+	}
+
+	h := sha256.New()
+	for _, attr := range stableAttributes {
+		if val, ok := di.Attribute(attr); ok {
+			fmt.Fprintf(h, "%s=%s\x00", attr, val)
+		}
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
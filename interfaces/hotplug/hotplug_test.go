@@ -0,0 +1,90 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package hotplug_test
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces/hotplug"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type hotplugSuite struct{}
+
+var _ = Suite(&hotplugSuite{})
+
+func (s *hotplugSuite) TestDefaultDeviceKey(c *C) {
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{
+		"DEVPATH":      "/devices/pci0000:00/0000:00:14.0/usb1/1-2",
+		"ID_VENDOR_ID": "0483",
+		"ID_MODEL_ID":  "5740",
+		"ID_SERIAL":    "STMicro_00000000001A",
+		"MINOR":        "1",
+	})
+	c.Assert(err, IsNil)
+	key := hotplug.DefaultDeviceKey(di)
+	c.Check(key, HasLen, 64)
+
+	// the key doesn't depend on where the device was plugged in
+	di2, err := hotplug.NewHotplugDeviceInfo(map[string]string{
+		"DEVPATH":      "/devices/pci0000:00/0000:00:14.0/usb1/1-3",
+		"ID_VENDOR_ID": "0483",
+		"ID_MODEL_ID":  "5740",
+		"ID_SERIAL":    "STMicro_00000000001A",
+		"MINOR":        "2",
+	})
+	c.Assert(err, IsNil)
+	c.Check(hotplug.DefaultDeviceKey(di2), Equals, key)
+
+	// but it does depend on the serial number
+	di3, err := hotplug.NewHotplugDeviceInfo(map[string]string{
+		"DEVPATH":      "/devices/pci0000:00/0000:00:14.0/usb1/1-2",
+		"ID_VENDOR_ID": "0483",
+		"ID_MODEL_ID":  "5740",
+		"ID_SERIAL":    "STMicro_00000000001B",
+	})
+	c.Assert(err, IsNil)
+	c.Check(hotplug.DefaultDeviceKey(di3), Not(Equals), key)
+}
+
+func (s *hotplugSuite) TestDefaultDeviceKeyNotIdentifiable(c *C) {
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{
+		"DEVPATH":   "/devices/virtual/tty/ttyS0",
+		"DEVNAME":   "/dev/ttyS0",
+		"SUBSYSTEM": "tty",
+	})
+	c.Assert(err, IsNil)
+	c.Check(hotplug.DefaultDeviceKey(di), Equals, "")
+}
+
+func (s *hotplugSuite) TestSpecificationSetSlot(c *C) {
+	spec := hotplug.NewSpecification()
+	c.Check(spec.Slot(), IsNil)
+
+	slotSpec := &hotplug.RequestedSlotSpec{Name: "slot", Attrs: map[string]interface{}{"path": "/dev/ttyUSB0"}}
+	c.Assert(spec.SetSlot(slotSpec), IsNil)
+	c.Check(spec.Slot(), Equals, slotSpec)
+
+	err := spec.SetSlot(&hotplug.RequestedSlotSpec{Name: "other"})
+	c.Assert(err, ErrorMatches, "slot specification already set")
+	c.Check(spec.Slot(), Equals, slotSpec)
+}
@@ -21,6 +21,8 @@ package kmod
 
 import (
 	"os/exec"
+
+	"github.com/snapcore/snapd/release"
 )
 
 // loadModules loads given list of modules via modprobe.
@@ -29,6 +31,10 @@ import (
 // (otherwise failure to load a module means failure to connect the interface
 // and the other security backends)
 func loadModules(modules []string) {
+	if release.Preseeding {
+		// the modules are loaded when the preseeded image boots
+		return
+	}
 	for _, mod := range modules {
 		// ignore errors which are logged by loadModule() via syslog
 		_ = exec.Command("modprobe", "--syslog", mod).Run()
@@ -35,9 +35,10 @@ import (
 // holds internal state that is used by the mount backend during the interface
 // setup process.
 type Specification struct {
-	layoutMountEntries []osutil.MountEntry
-	mountEntries       []osutil.MountEntry
-	userMountEntries   []osutil.MountEntry
+	layoutMountEntries     []osutil.MountEntry
+	mountEntries           []osutil.MountEntry
+	layoutUserMountEntries []osutil.MountEntry
+	userMountEntries       []osutil.MountEntry
 }
 
 // AddMountEntry adds a new mount entry.
@@ -46,7 +47,11 @@ func (spec *Specification) AddMountEntry(e osutil.MountEntry) error {
 	return nil
 }
 
-//AddUserMountEntry adds a new user mount entry.
+// AddUserMountEntry adds a new user mount entry.
+//
+// User mount entries are applied by snap-update-ns in the per-user mount
+// namespace. They may refer to $XDG_RUNTIME_DIR and $HOME, both of which are
+// expanded for the user invoking the snap.
 func (spec *Specification) AddUserMountEntry(e osutil.MountEntry) error {
 	spec.userMountEntries = append(spec.userMountEntries, e)
 	return nil
@@ -122,8 +127,14 @@ func (spec *Specification) AddSnapLayout(si *snap.Info) {
 	sort.Strings(paths)
 
 	for _, path := range paths {
-		entry := mountEntryFromLayout(si.Layout[path])
-		spec.layoutMountEntries = append(spec.layoutMountEntries, entry)
+		layout := si.Layout[path]
+		entry := mountEntryFromLayout(layout)
+		if layout.IsUserScoped() {
+			// User-scoped layouts are applied in the per-user mount namespace.
+			spec.layoutUserMountEntries = append(spec.layoutUserMountEntries, entry)
+		} else {
+			spec.layoutMountEntries = append(spec.layoutMountEntries, entry)
+		}
 	}
 }
 
@@ -138,8 +149,9 @@ func (spec *Specification) MountEntries() []osutil.MountEntry {
 
 // UserMountEntries returns a copy of the added user mount entries.
 func (spec *Specification) UserMountEntries() []osutil.MountEntry {
-	result := make([]osutil.MountEntry, len(spec.userMountEntries))
-	copy(result, spec.userMountEntries)
+	result := make([]osutil.MountEntry, 0, len(spec.layoutUserMountEntries)+len(spec.userMountEntries))
+	result = append(result, spec.layoutUserMountEntries...)
+	result = append(result, spec.userMountEntries...)
 	unclashMountEntries(result)
 	return result
 }
@@ -163,3 +163,31 @@ func (s *specSuite) TestMountEntryFromLayout(c *C) {
 		{Dir: "/usr", Name: "/snap/vanguard/42/usr", Options: []string{"rbind", "rw", "x-snapd.origin=layout"}},
 	})
 }
+
+const snapWithUserLayout = `
+name: vanguard
+version: 0
+layout:
+  /usr:
+    bind: $SNAP/usr
+  $HOME/.config/vanguard:
+    bind: $SNAP_USER_DATA/.config/vanguard
+  $HOME/.vanguardrc:
+    bind-file: $SNAP_USER_COMMON/vanguardrc
+  $HOME/.cache/vanguard:
+    type: tmpfs
+`
+
+func (s *specSuite) TestUserMountEntryFromLayout(c *C) {
+	snapInfo := snaptest.MockInfo(c, snapWithUserLayout, &snap.SideInfo{Revision: snap.R(42)})
+	s.spec.AddSnapLayout(snapInfo)
+	c.Assert(s.spec.MountEntries(), DeepEquals, []osutil.MountEntry{
+		{Dir: "/usr", Name: "/snap/vanguard/42/usr", Options: []string{"rbind", "rw", "x-snapd.origin=layout"}},
+	})
+	c.Assert(s.spec.UserMountEntries(), DeepEquals, []osutil.MountEntry{
+		// User-scoped layout entries keep $HOME for snap-update-ns to expand.
+		{Dir: "$HOME/.cache/vanguard", Name: "tmpfs", Type: "tmpfs", Options: []string{"x-snapd.origin=layout"}},
+		{Dir: "$HOME/.config/vanguard", Name: "$HOME/snap/vanguard/42/.config/vanguard", Options: []string{"rbind", "rw", "x-snapd.origin=layout"}},
+		{Dir: "$HOME/.vanguardrc", Name: "$HOME/snap/vanguard/common/vanguardrc", Options: []string{"bind", "rw", "x-snapd.kind=file", "x-snapd.origin=layout"}},
+	})
+}
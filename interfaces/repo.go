@@ -65,6 +65,19 @@ func (r *Repository) Interface(interfaceName string) Interface {
 	return r.ifaces[interfaceName]
 }
 
+// AllInterfaces returns all the interfaces added to the repository, ordered by name.
+func (r *Repository) AllInterfaces() []Interface {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	ifaces := make([]Interface, 0, len(r.ifaces))
+	for _, iface := range r.ifaces {
+		ifaces = append(ifaces, iface)
+	}
+	sort.Sort(byInterfaceName(ifaces))
+	return ifaces
+}
+
 // AddInterface adds the provided interface to the repository.
 func (r *Repository) AddInterface(i Interface) error {
 	r.m.Lock()
@@ -588,6 +601,80 @@ func (r *Repository) Connect(ref ConnRef) error {
 	return nil
 }
 
+// SetConnectionAttrs replaces the dynamic attributes of the plug and the slot
+// of an established connection and returns them as sanitized by the
+// interface, which is what the connection uses from then on.
+//
+// It is an error to use a dynamic attribute with the same name as one of the
+// static attributes of the plug or slot, or attributes that the interface
+// refuses, in which case no changes are made.
+func (r *Repository) SetConnectionAttrs(ref ConnRef, plugAttrs, slotAttrs map[string]interface{}) (sanitizedPlugAttrs, sanitizedSlotAttrs map[string]interface{}, err error) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	plug := r.plugs[ref.PlugRef.Snap][ref.PlugRef.Name]
+	if plug == nil {
+		return nil, nil, fmt.Errorf("snap %q has no plug named %q", ref.PlugRef.Snap, ref.PlugRef.Name)
+	}
+	slot := r.slots[ref.SlotRef.Snap][ref.SlotRef.Name]
+	if slot == nil {
+		return nil, nil, fmt.Errorf("snap %q has no slot named %q", ref.SlotRef.Snap, ref.SlotRef.Name)
+	}
+	conn := r.slotPlugs[slot][plug]
+	if conn == nil {
+		return nil, nil, fmt.Errorf("cannot set attributes of %s:%s and %s:%s, they are not connected",
+			ref.PlugRef.Snap, ref.PlugRef.Name, ref.SlotRef.Snap, ref.SlotRef.Name)
+	}
+
+	// dynamic attributes cannot replace static ones
+	cplug := NewConnectedPlug(plug, nil)
+	for key, value := range plugAttrs {
+		if err := cplug.SetAttr(key, copyRecursive(value)); err != nil {
+			return nil, nil, err
+		}
+	}
+	cslot := NewConnectedSlot(slot, nil)
+	for key, value := range slotAttrs {
+		if err := cslot.SetAttr(key, copyRecursive(value)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// The interface sanitizes the attributes as if they were static, the
+	// same way it did when the plug and slot were added.
+	iface := r.ifaces[plug.Interface]
+	sanitizedPlug := PlugInfoWithDynamicAttrs(plug, plugAttrs)
+	if err := BeforePreparePlug(iface, sanitizedPlug); err != nil {
+		return nil, nil, fmt.Errorf("cannot set attributes of %s:%s: %v", ref.PlugRef.Snap, ref.PlugRef.Name, err)
+	}
+	sanitizedSlot := SlotInfoWithDynamicAttrs(slot, slotAttrs)
+	if err := BeforePrepareSlot(iface, sanitizedSlot); err != nil {
+		return nil, nil, fmt.Errorf("cannot set attributes of %s:%s: %v", ref.SlotRef.Snap, ref.SlotRef.Name, err)
+	}
+	sanitizedPlugAttrs = dynamicAttrsOf(plug.Attrs, sanitizedPlug.Attrs)
+	sanitizedSlotAttrs = dynamicAttrsOf(slot.Attrs, sanitizedSlot.Attrs)
+
+	conn.plug = NewConnectedPlug(plug, copyAttributes(sanitizedPlugAttrs))
+	conn.slot = NewConnectedSlot(slot, copyAttributes(sanitizedSlotAttrs))
+	return sanitizedPlugAttrs, sanitizedSlotAttrs, nil
+}
+
+// dynamicAttrsOf returns the attributes that are not among the given static
+// ones, or nil if there are none.
+func dynamicAttrsOf(staticAttrs, attrs map[string]interface{}) map[string]interface{} {
+	var dynamic map[string]interface{}
+	for key, value := range attrs {
+		if _, ok := staticAttrs[key]; ok {
+			continue
+		}
+		if dynamic == nil {
+			dynamic = make(map[string]interface{})
+		}
+		dynamic[key] = value
+	}
+	return dynamic
+}
+
 // Disconnect disconnects the named plug from the slot of the given snap.
 //
 // Disconnect() finds a specific slot and a specific plug and disconnects that
@@ -21,6 +21,7 @@ package interfaces_test
 
 import (
 	"fmt"
+	"strings"
 
 	. "gopkg.in/check.v1"
 
@@ -175,6 +176,15 @@ func (s *RepositorySuite) TestInterface(c *C) {
 	c.Assert(iface, Equals, s.iface)
 }
 
+func (s *RepositorySuite) TestAllInterfaces(c *C) {
+	c.Assert(s.emptyRepo.AllInterfaces(), HasLen, 0)
+	ifaceB := &ifacetest.TestInterface{InterfaceName: "b"}
+	ifaceA := &ifacetest.TestInterface{InterfaceName: "a"}
+	c.Assert(s.emptyRepo.AddInterface(ifaceB), IsNil)
+	c.Assert(s.emptyRepo.AddInterface(ifaceA), IsNil)
+	c.Assert(s.emptyRepo.AllInterfaces(), DeepEquals, []Interface{ifaceA, ifaceB})
+}
+
 func (s *RepositorySuite) TestInterfaceSearch(c *C) {
 	ifaceA := &ifacetest.TestInterface{InterfaceName: "a"}
 	ifaceB := &ifacetest.TestInterface{InterfaceName: "b"}
@@ -1120,6 +1130,119 @@ func (s *RepositorySuite) TestConnectSucceeds(c *C) {
 	c.Assert(err, IsNil)
 }
 
+// Tests for Repository.SetConnectionAttrs()
+
+func (s *RepositorySuite) TestSetConnectionAttrs(c *C) {
+	repo := s.emptyRepo
+	c.Assert(repo.AddBackend(&ifacetest.TestSecurityBackend{BackendName: testSecurity}), IsNil)
+	c.Assert(repo.AddInterface(&ifacetest.TestInterface{
+		InterfaceName: "interface",
+		TestConnectedPlugCallback: func(spec *ifacetest.Specification, plug *ConnectedPlug, slot *ConnectedSlot) error {
+			var plugValue, slotValue string
+			plug.Attr("dynamic", &plugValue)
+			slot.Attr("dynamic", &slotValue)
+			spec.AddSnippet(plugValue + "," + slotValue)
+			return nil
+		},
+	}), IsNil)
+	c.Assert(repo.AddPlug(s.plug), IsNil)
+	c.Assert(repo.AddSlot(s.slot), IsNil)
+	connRef := NewConnRef(s.plug, s.slot)
+	c.Assert(repo.Connect(*connRef), IsNil)
+
+	snippets := func() []string {
+		spec, err := repo.SnapSpecification(testSecurity, "consumer")
+		c.Assert(err, IsNil)
+		return spec.(*ifacetest.Specification).Snippets
+	}
+	c.Check(snippets(), DeepEquals, []string{","})
+
+	plugAttrs, slotAttrs, err := repo.SetConnectionAttrs(*connRef,
+		map[string]interface{}{"dynamic": "plug-value"},
+		map[string]interface{}{"dynamic": "slot-value"})
+	c.Assert(err, IsNil)
+	c.Check(plugAttrs, DeepEquals, map[string]interface{}{"dynamic": "plug-value"})
+	c.Check(slotAttrs, DeepEquals, map[string]interface{}{"dynamic": "slot-value"})
+	c.Check(snippets(), DeepEquals, []string{"plug-value,slot-value"})
+
+	// dynamic attributes are replaced, not merged
+	plugAttrs, slotAttrs, err = repo.SetConnectionAttrs(*connRef, nil, map[string]interface{}{"dynamic": "other-value"})
+	c.Assert(err, IsNil)
+	c.Check(plugAttrs, IsNil)
+	c.Check(slotAttrs, DeepEquals, map[string]interface{}{"dynamic": "other-value"})
+	c.Check(snippets(), DeepEquals, []string{",other-value"})
+}
+
+func (s *RepositorySuite) TestSetConnectionAttrsSanitized(c *C) {
+	repo := s.emptyRepo
+	c.Assert(repo.AddBackend(&ifacetest.TestSecurityBackend{BackendName: testSecurity}), IsNil)
+	c.Assert(repo.AddInterface(&ifacetest.TestInterface{
+		InterfaceName: "interface",
+		TestConnectedPlugCallback: func(spec *ifacetest.Specification, plug *ConnectedPlug, slot *ConnectedSlot) error {
+			var path string
+			slot.Attr("path", &path)
+			spec.AddSnippet(path)
+			return nil
+		},
+		BeforePrepareSlotCallback: func(slot *snap.SlotInfo) error {
+			// static attributes are seen along with the dynamic ones
+			c.Check(slot.Attrs["attr"], Equals, "value")
+			if path, ok := slot.Attrs["path"].(string); ok && strings.Contains(path, "..") {
+				return fmt.Errorf("invalid path %q", path)
+			}
+			// the interface can rewrite the attributes
+			if path, ok := slot.Attrs["path"].(string); ok {
+				slot.Attrs["path"] = strings.TrimSuffix(path, "/")
+			}
+			return nil
+		},
+	}), IsNil)
+	c.Assert(repo.AddPlug(s.plug), IsNil)
+	c.Assert(repo.AddSlot(s.slot), IsNil)
+	connRef := NewConnRef(s.plug, s.slot)
+	c.Assert(repo.Connect(*connRef), IsNil)
+
+	_, slotAttrs, err := repo.SetConnectionAttrs(*connRef, nil, map[string]interface{}{"path": "/dev/foo/"})
+	c.Assert(err, IsNil)
+	c.Check(slotAttrs, DeepEquals, map[string]interface{}{"path": "/dev/foo"})
+
+	_, _, err = repo.SetConnectionAttrs(*connRef, nil, map[string]interface{}{"path": "/dev/../etc"})
+	c.Assert(err, ErrorMatches, `cannot set attributes of producer:slot: invalid path "/dev/../etc"`)
+
+	// the attributes that were accepted remain in place
+	spec, err := repo.SnapSpecification(testSecurity, "consumer")
+	c.Assert(err, IsNil)
+	c.Check(spec.(*ifacetest.Specification).Snippets, DeepEquals, []string{"/dev/foo"})
+	// the static attributes of the slot are not changed
+	c.Check(s.slot.Attrs, DeepEquals, map[string]interface{}{"attr": "value"})
+}
+
+func (s *RepositorySuite) TestSetConnectionAttrsStaticAttribute(c *C) {
+	c.Assert(s.testRepo.AddPlug(s.plug), IsNil)
+	c.Assert(s.testRepo.AddSlot(s.slot), IsNil)
+	c.Assert(s.testRepo.Connect(*NewConnRef(s.plug, s.slot)), IsNil)
+
+	_, _, err := s.testRepo.SetConnectionAttrs(*NewConnRef(s.plug, s.slot),
+		map[string]interface{}{"dynamic": "a"},
+		map[string]interface{}{"attr": "other"})
+	c.Assert(err, ErrorMatches, `cannot change attribute "attr" as it was statically specified in the snap details`)
+}
+
+func (s *RepositorySuite) TestSetConnectionAttrsNotConnected(c *C) {
+	c.Assert(s.testRepo.AddPlug(s.plug), IsNil)
+	c.Assert(s.testRepo.AddSlot(s.slot), IsNil)
+
+	_, _, err := s.testRepo.SetConnectionAttrs(*NewConnRef(s.plug, s.slot), nil, nil)
+	c.Assert(err, ErrorMatches, `cannot set attributes of consumer:plug and producer:slot, they are not connected`)
+}
+
+func (s *RepositorySuite) TestSetConnectionAttrsWithoutPlug(c *C) {
+	c.Assert(s.testRepo.AddSlot(s.slot), IsNil)
+
+	_, _, err := s.testRepo.SetConnectionAttrs(*NewConnRef(s.plug, s.slot), nil, nil)
+	c.Assert(err, ErrorMatches, `snap "consumer" has no plug named "plug"`)
+}
+
 // Tests for Repository.Disconnect() and DisconnectAll()
 
 // Disconnect fails if any argument is empty
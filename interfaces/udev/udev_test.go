@@ -25,6 +25,7 @@ import (
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/interfaces/udev"
+	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -49,6 +50,16 @@ func (s *uDevSuite) TestReloadUDevRulesRunsUDevAdm(c *C) {
 	})
 }
 
+func (s *uDevSuite) TestReloadUDevRulesPreseeding(c *C) {
+	restore := release.MockPreseeding(true)
+	defer restore()
+	cmd := testutil.MockCommand(c, "udevadm", "")
+	defer cmd.Restore()
+	err := udev.ReloadRules()
+	c.Assert(err, IsNil)
+	c.Assert(cmd.Calls(), HasLen, 0)
+}
+
 func (s *uDevSuite) TestReloadUDevRulesReportsErrorsFromReloadRules(c *C) {
 	cmd := testutil.MockCommand(c, "udevadm", `
 if [ "$1" = "control" ]; then
@@ -349,6 +349,21 @@ func (e *MountEntry) XSnapdSynthetic() bool {
 	return e.OptBool("x-snapd.synthetic")
 }
 
+// XSnapdCreated returns true if the mount point of a given entry was created
+// by snap-update-ns.
+//
+// Mount points created by snap-update-ns are identified by having the
+// "x-snapd.created" mount option. Only such mount points are removed when the
+// entry is unmounted, pre-existing directories are left alone.
+func (e *MountEntry) XSnapdCreated() bool {
+	return e.OptBool("x-snapd.created")
+}
+
+// XSnapdCreatedOpt returns the string "x-snapd.created".
+func XSnapdCreatedOpt() string {
+	return "x-snapd.created"
+}
+
 // XSnapdKindSymlink returns the string "x-snapd.kind=symlink".
 func XSnapdKindSymlink() string {
 	return "x-snapd.kind=symlink"
@@ -340,6 +340,16 @@ func (s *entrySuite) TestXSnapdSynthetic(c *C) {
 	c.Assert(e.XSnapdSynthetic(), Equals, true)
 }
 
+func (s *entrySuite) TestXSnapdCreated(c *C) {
+	// Mount points are not created by snap-update-ns unless tagged as such.
+	e := &osutil.MountEntry{}
+	c.Assert(e.XSnapdCreated(), Equals, false)
+
+	// Tagging is done with x-snapd.created option.
+	e = &osutil.MountEntry{Options: []string{osutil.XSnapdCreatedOpt()}}
+	c.Assert(e.XSnapdCreated(), Equals, true)
+}
+
 func (s *entrySuite) TextXSnapdOriginLayout(c *C) {
 	c.Assert(osutil.XSnapdOriginLayout(), Equals, "x-snapd.origin=layout")
 }
@@ -0,0 +1,174 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+// Package netlink allows listening to device events sent by the kernel and
+// udev over a netlink socket.
+package netlink
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Mode selects the source of the events to listen to.
+type Mode int
+
+const (
+	// KernelEvent are the raw events sent by the kernel, before udev
+	// processed them.
+	KernelEvent Mode = 1
+	// UdevEvent are the events sent by udev once its rules were applied,
+	// they carry all the device properties set by udev.
+	UdevEvent Mode = 2
+)
+
+// readTimeout is how long a read on the socket blocks before checking
+// whether monitoring was stopped.
+var readTimeout = 100 * time.Millisecond
+
+// UEventConn is a connection to the kobject uevent netlink socket.
+type UEventConn struct {
+	fd   int
+	mode Mode
+}
+
+// Connect opens the netlink socket and subscribes to the events of the
+// given mode.
+func (c *UEventConn) Connect(mode Mode) error {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_KOBJECT_UEVENT)
+	if err != nil {
+		return fmt.Errorf("cannot open netlink socket: %v", err)
+	}
+	addr := syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Pid:    uint32(os.Getpid()),
+		Groups: uint32(mode),
+	}
+	if err := syscall.Bind(fd, &addr); err != nil {
+		// the pid may be in use by another socket of the process, let
+		// the kernel pick one
+		addr.Pid = 0
+		if err := syscall.Bind(fd, &addr); err != nil {
+			syscall.Close(fd)
+			return fmt.Errorf("cannot bind netlink socket: %v", err)
+		}
+	}
+	tv := syscall.NsecToTimeval(readTimeout.Nanoseconds())
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv); err != nil {
+		syscall.Close(fd)
+		return fmt.Errorf("cannot set netlink socket timeout: %v", err)
+	}
+	c.fd = fd
+	c.mode = mode
+	return nil
+}
+
+// Close closes the netlink socket.
+func (c *UEventConn) Close() error {
+	return syscall.Close(c.fd)
+}
+
+// trustedSender tells whether a message received by a connection subscribed
+// to the events of the given mode was sent by the expected party. Anyone can
+// send a unicast message to the socket, but only privileged processes can
+// send to the multicast groups: the kernel sends its events to the kernel
+// group, with a port ID of 0, while udev sends to the udev group.
+func trustedSender(mode Mode, from syscall.Sockaddr) bool {
+	addr, ok := from.(*syscall.SockaddrNetlink)
+	if !ok {
+		return false
+	}
+	// the groups of the sender address are the ones the message was
+	// sent to, none at all for unicast messages
+	if addr.Groups != uint32(mode) {
+		return false
+	}
+	if mode == KernelEvent && addr.Pid != 0 {
+		return false
+	}
+	return true
+}
+
+// ReadMsg reads a single message from the socket. It returns a nil message
+// and no error if nothing was received before the read timeout, or if the
+// message did not come from the kernel or from udev and was dropped.
+func (c *UEventConn) ReadMsg() ([]byte, error) {
+	buf := make([]byte, os.Getpagesize())
+	for {
+		// peek at the message to learn its size
+		n, _, err := syscall.Recvfrom(c.fd, buf, syscall.MSG_PEEK|syscall.MSG_TRUNC)
+		if err == syscall.EAGAIN || err == syscall.EINTR {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if n <= len(buf) {
+			break
+		}
+		buf = make([]byte, n)
+	}
+	n, from, err := syscall.Recvfrom(c.fd, buf, 0)
+	if err != nil {
+		return nil, err
+	}
+	if !trustedSender(c.mode, from) {
+		return nil, nil
+	}
+	return buf[:n], nil
+}
+
+// Monitor reads events from the socket and sends them to the queue until
+// stop is closed. Messages that cannot be parsed are reported on errors,
+// as are read errors, which also terminate monitoring.
+func (c *UEventConn) Monitor(queue chan<- *UEvent, errors chan<- error, stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		msg, err := c.ReadMsg()
+		if err != nil {
+			select {
+			case errors <- fmt.Errorf("cannot read uevent: %v", err):
+			case <-stop:
+			}
+			return
+		}
+		if msg == nil {
+			continue
+		}
+		ev, err := ParseUEvent(msg)
+		if err != nil {
+			select {
+			case errors <- err:
+			case <-stop:
+				return
+			}
+			continue
+		}
+		select {
+		case queue <- ev:
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package netlink
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+// KObjAction is the action of a kernel object event, e.g. "add".
+type KObjAction string
+
+const (
+	ADD    KObjAction = "add"
+	REMOVE KObjAction = "remove"
+	UPDATE KObjAction = "change"
+	MOVE   KObjAction = "move"
+	ONLINE KObjAction = "online"
+	BIND   KObjAction = "bind"
+	UNBIND KObjAction = "unbind"
+)
+
+// UEvent is a device event, either coming directly from the kernel or
+// after being processed by udev.
+type UEvent struct {
+	Action KObjAction
+	KObj   string
+	Env    map[string]string
+}
+
+func (e UEvent) String() string {
+	return fmt.Sprintf("%s@%s", e.Action, e.KObj)
+}
+
+// libudevPrefix starts all the messages sent by udev to its monitors.
+var libudevPrefix = []byte("libudev\x00")
+
+// libudevMagic is the magic number following the prefix, in network byte
+// order.
+const libudevMagic = 0xfeedcafe
+
+// nativeEndian is the byte order of the host, used by udev for all the
+// header fields but the magic number.
+var nativeEndian binary.ByteOrder = func() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+// ParseUEvent parses a raw netlink message, as sent either by the kernel
+// ("action@devpath\x00KEY=VALUE\x00...") or by udev (a binary header
+// followed by "KEY=VALUE\x00..." properties).
+func ParseUEvent(raw []byte) (*UEvent, error) {
+	var props []byte
+	if bytes.HasPrefix(raw, libudevPrefix) {
+		// struct udev_monitor_netlink_header
+		if len(raw) < 24 {
+			return nil, fmt.Errorf("cannot parse udev event: header too short")
+		}
+		if binary.BigEndian.Uint32(raw[8:12]) != libudevMagic {
+			return nil, fmt.Errorf("cannot parse udev event: invalid magic")
+		}
+		offset := nativeEndian.Uint32(raw[16:20])
+		length := nativeEndian.Uint32(raw[20:24])
+		if uint64(offset)+uint64(length) > uint64(len(raw)) {
+			return nil, fmt.Errorf("cannot parse udev event: invalid properties offset")
+		}
+		props = raw[offset : offset+length]
+	} else {
+		idx := bytes.IndexByte(raw, 0)
+		if idx < 0 || bytes.IndexByte(raw[:idx], '@') < 0 {
+			return nil, fmt.Errorf("cannot parse kernel event: invalid header")
+		}
+		props = raw[idx+1:]
+	}
+
+	env := make(map[string]string)
+	for _, field := range bytes.Split(props, []byte{0}) {
+		if len(field) == 0 {
+			continue
+		}
+		kv := strings.SplitN(string(field), "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("cannot parse event property %q", field)
+		}
+		env[kv[0]] = kv[1]
+	}
+
+	action, ok := env["ACTION"]
+	if !ok {
+		return nil, fmt.Errorf("cannot parse event: missing ACTION property")
+	}
+	devpath, ok := env["DEVPATH"]
+	if !ok {
+		return nil, fmt.Errorf("cannot parse event: missing DEVPATH property")
+	}
+	return &UEvent{
+		Action: KObjAction(action),
+		KObj:   devpath,
+		Env:    env,
+	}, nil
+}
@@ -0,0 +1,123 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package netlink_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"syscall"
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/osutil/udev/netlink"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type ueventSuite struct{}
+
+var _ = Suite(&ueventSuite{})
+
+func (s *ueventSuite) TestParseKernelEvent(c *C) {
+	raw := []byte("add@/devices/pci0000:00/0000:00:14.0/usb1/1-2\x00ACTION=add\x00DEVPATH=/devices/pci0000:00/0000:00:14.0/usb1/1-2\x00SUBSYSTEM=usb\x00SEQNUM=2107\x00")
+	ev, err := netlink.ParseUEvent(raw)
+	c.Assert(err, IsNil)
+	c.Check(ev.Action, Equals, netlink.ADD)
+	c.Check(ev.KObj, Equals, "/devices/pci0000:00/0000:00:14.0/usb1/1-2")
+	c.Check(ev.Env, DeepEquals, map[string]string{
+		"ACTION":    "add",
+		"DEVPATH":   "/devices/pci0000:00/0000:00:14.0/usb1/1-2",
+		"SUBSYSTEM": "usb",
+		"SEQNUM":    "2107",
+	})
+	c.Check(ev.String(), Equals, "add@/devices/pci0000:00/0000:00:14.0/usb1/1-2")
+}
+
+func udevMessage(props string, order binary.ByteOrder) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("libudev\x00")
+	binary.Write(&buf, binary.BigEndian, uint32(0xfeedcafe))
+	// header size, properties offset, properties length
+	binary.Write(&buf, order, uint32(40))
+	binary.Write(&buf, order, uint32(40))
+	binary.Write(&buf, order, uint32(len(props)))
+	// filter hashes and tag bloom filter
+	buf.Write(make([]byte, 16))
+	buf.WriteString(props)
+	return buf.Bytes()
+}
+
+func (s *ueventSuite) TestParseUdevEvent(c *C) {
+	raw := udevMessage("ACTION=remove\x00DEVPATH=/devices/virtual/tty/ttyACM0\x00DEVNAME=/dev/ttyACM0\x00ID_SERIAL=STMicro_00000000001A\x00", netlink.NativeEndian)
+	ev, err := netlink.ParseUEvent(raw)
+	c.Assert(err, IsNil)
+	c.Check(ev.Action, Equals, netlink.REMOVE)
+	c.Check(ev.KObj, Equals, "/devices/virtual/tty/ttyACM0")
+	c.Check(ev.Env, DeepEquals, map[string]string{
+		"ACTION":    "remove",
+		"DEVPATH":   "/devices/virtual/tty/ttyACM0",
+		"DEVNAME":   "/dev/ttyACM0",
+		"ID_SERIAL": "STMicro_00000000001A",
+	})
+}
+
+func (s *ueventSuite) TestParseErrors(c *C) {
+	for _, t := range []struct {
+		raw []byte
+		err string
+	}{
+		{[]byte("garbage"), "cannot parse kernel event: invalid header"},
+		{[]byte("garbage\x00ACTION=add\x00"), "cannot parse kernel event: invalid header"},
+		{[]byte("add@/devices/foo\x00ACTION\x00"), `cannot parse event property "ACTION"`},
+		{[]byte("add@/devices/foo\x00DEVPATH=/devices/foo\x00"), "cannot parse event: missing ACTION property"},
+		{[]byte("add@/devices/foo\x00ACTION=add\x00"), "cannot parse event: missing DEVPATH property"},
+		{[]byte("libudev\x00"), "cannot parse udev event: header too short"},
+		{append([]byte("libudev\x00"), make([]byte, 32)...), "cannot parse udev event: invalid magic"},
+	} {
+		_, err := netlink.ParseUEvent(t.raw)
+		c.Check(err, ErrorMatches, t.err, Commentf("%q", t.raw))
+	}
+
+	raw := udevMessage("ACTION=add\x00", netlink.NativeEndian)
+	_, err := netlink.ParseUEvent(raw[:len(raw)-2])
+	c.Check(err, ErrorMatches, "cannot parse udev event: invalid properties offset")
+}
+
+func (s *ueventSuite) TestTrustedSender(c *C) {
+	for _, t := range []struct {
+		mode    netlink.Mode
+		from    syscall.Sockaddr
+		trusted bool
+	}{
+		{netlink.KernelEvent, &syscall.SockaddrNetlink{Pid: 0, Groups: 1}, true},
+		{netlink.UdevEvent, &syscall.SockaddrNetlink{Pid: 1234, Groups: 2}, true},
+		// unicast messages
+		{netlink.KernelEvent, &syscall.SockaddrNetlink{Pid: 0, Groups: 0}, false},
+		{netlink.UdevEvent, &syscall.SockaddrNetlink{Pid: 1234, Groups: 0}, false},
+		// kernel events come from the kernel only
+		{netlink.KernelEvent, &syscall.SockaddrNetlink{Pid: 1234, Groups: 1}, false},
+		// events of the other group
+		{netlink.UdevEvent, &syscall.SockaddrNetlink{Pid: 0, Groups: 1}, false},
+		{netlink.UdevEvent, &syscall.SockaddrUnix{Name: "foo"}, false},
+		{netlink.UdevEvent, nil, false},
+	} {
+		c.Check(netlink.TrustedSender(t.mode, t.from), Equals, t.trusted, Commentf("%v %#v", t.mode, t.from))
+	}
+}
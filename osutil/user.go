@@ -78,9 +78,7 @@ func AddUser(name string, opts *AddUserOptions) error {
 	}
 
 	if opts.Sudoer {
-		// Must escape "." as files containing it are ignored in sudoers.d.
-		sudoersFile := filepath.Join(sudoersDotD, "create-user-"+strings.Replace(name, ".", "%2E", -1))
-		if err := AtomicWriteFile(sudoersFile, []byte(fmt.Sprintf(sudoersTemplate, name)), 0400, 0); err != nil {
+		if err := AtomicWriteFile(sudoersFile(name), []byte(fmt.Sprintf(sudoersTemplate, name)), 0400, 0); err != nil {
 			return fmt.Errorf("cannot create file under sudoers.d: %s", err)
 		}
 	}
@@ -120,6 +118,42 @@ func AddUser(name string, opts *AddUserOptions) error {
 	return nil
 }
 
+// sudoersFile returns the path of the sudoers.d file AddUser creates
+// for the given user.
+func sudoersFile(name string) string {
+	// Must escape "." as files containing it are ignored in sudoers.d.
+	return filepath.Join(sudoersDotD, "create-user-"+strings.Replace(name, ".", "%2E", -1))
+}
+
+// DelUserOptions holds the options for DelUser.
+type DelUserOptions struct {
+	ExtraUsers bool
+}
+
+// DelUser removes a user created by AddUser, together with its home
+// directory and its sudoers.d file, if any.
+func DelUser(name string, opts *DelUserOptions) error {
+	if opts == nil {
+		opts = &DelUserOptions{}
+	}
+
+	cmdStr := []string{"userdel"}
+	if opts.ExtraUsers {
+		cmdStr = append(cmdStr, "--extrausers")
+	}
+	cmdStr = append(cmdStr, "--remove", name)
+
+	if output, err := exec.Command(cmdStr[0], cmdStr[1:]...).CombinedOutput(); err != nil {
+		return fmt.Errorf("cannot delete user %q: %s", name, OutputErr(output, err))
+	}
+
+	if err := os.Remove(sudoersFile(name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot remove file under sudoers.d: %s", err)
+	}
+
+	return nil
+}
+
 // RealUser finds the user behind a sudo invocation when root, if applicable
 // and possible.
 //
@@ -20,6 +20,7 @@
 package osutil_test
 
 import (
+	"io/ioutil"
 	"os"
 	"os/user"
 	"path/filepath"
@@ -152,6 +153,38 @@ func (s *createUserSuite) TestAddUserWithPassword(c *check.C) {
 
 }
 
+func (s *createUserSuite) TestDelUser(c *check.C) {
+	mockSudoers := c.MkDir()
+	restorer := osutil.MockSudoersDotD(mockSudoers)
+	defer restorer()
+	mockUserDel := testutil.MockCommand(c, "userdel", "")
+	defer mockUserDel.Restore()
+
+	sudoersFile := filepath.Join(mockSudoers, "create-user-karl%2Esagan")
+	c.Assert(ioutil.WriteFile(sudoersFile, nil, 0400), check.IsNil)
+
+	err := osutil.DelUser("karl.sagan", &osutil.DelUserOptions{ExtraUsers: true})
+	c.Assert(err, check.IsNil)
+
+	c.Check(mockUserDel.Calls(), check.DeepEquals, [][]string{
+		{"userdel", "--extrausers", "--remove", "karl.sagan"},
+	})
+	c.Check(osutil.FileExists(sudoersFile), check.Equals, false)
+
+	// a user without a sudoers.d file is fine as well
+	err = osutil.DelUser("lakatos", nil)
+	c.Assert(err, check.IsNil)
+	c.Check(mockUserDel.Calls()[1], check.DeepEquals, []string{"userdel", "--remove", "lakatos"})
+}
+
+func (s *createUserSuite) TestDelUserFails(c *check.C) {
+	mockUserDel := testutil.MockCommand(c, "userdel", "echo nope; exit 1")
+	defer mockUserDel.Restore()
+
+	err := osutil.DelUser("lakatos", nil)
+	c.Assert(err, check.ErrorMatches, `cannot delete user "lakatos": nope`)
+}
+
 func (s *createUserSuite) TestRealUser(c *check.C) {
 	oldUser := os.Getenv("SUDO_USER")
 	defer func() { os.Setenv("SUDO_USER", oldUser) }()
@@ -65,6 +65,10 @@ func PatchConfig(snapName string, subkeys []string, pos int, config interface{},
 		if err := jsonutil.DecodeWithNumber(bytes.NewReader(*config), &configm); err != nil {
 			return nil, fmt.Errorf("snap %q option %q is not a map", snapName, strings.Join(subkeys[:pos], "."))
 		}
+		if configm == nil {
+			// unset before, start afresh
+			configm = make(map[string]interface{})
+		}
 		_, err := PatchConfig(snapName, subkeys, pos, configm, value)
 		if err != nil {
 			return nil, err
@@ -88,6 +92,23 @@ func PatchConfig(snapName string, subkeys []string, pos int, config interface{},
 	panic(fmt.Errorf("internal error: unexpected configuration type %T", config))
 }
 
+// purgeNulls returns a copy of the given configuration changes
+// without the keys that are unset.
+func purgeNulls(config interface{}) interface{} {
+	configm, ok := config.(map[string]interface{})
+	if !ok {
+		return config
+	}
+	purged := make(map[string]interface{}, len(configm))
+	for k, v := range configm {
+		if raw, ok := v.(*json.RawMessage); ok && isNull(raw) {
+			continue
+		}
+		purged[k] = purgeNulls(v)
+	}
+	return purged
+}
+
 // Get unmarshals into result the value of the provided snap's configuration key.
 // If the key does not exist, an error of type *NoOptionError is returned.
 // The provided key may be formed as a dotted key path through nested maps.
@@ -98,7 +119,7 @@ func GetFromChange(snapName string, subkeys []string, pos int, config map[string
 		if config == nil {
 			return &NoOptionError{SnapName: snapName, Key: ""}
 		}
-		raw := jsonRaw(config)
+		raw := jsonRaw(purgeNulls(config))
 
 		if err := jsonutil.DecodeWithNumber(bytes.NewReader(*raw), &result); err != nil {
 			return fmt.Errorf("internal error: cannot unmarshal snap %q root document: %s", snapName, err)
@@ -113,7 +134,7 @@ func GetFromChange(snapName string, subkeys []string, pos int, config map[string
 	if pos+1 == len(subkeys) {
 		raw, ok := value.(*json.RawMessage)
 		if !ok {
-			raw = jsonRaw(value)
+			raw = jsonRaw(purgeNulls(value))
 		}
 		if err := jsonutil.DecodeWithNumber(bytes.NewReader(*raw), &result); err != nil {
 			key := strings.Join(subkeys, ".")
@@ -23,11 +23,13 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 
 	"github.com/snapcore/snapd/jsonutil"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
 )
 
 // Transaction holds a copy of the configuration originally present in the
@@ -72,6 +74,9 @@ func (t *Transaction) State() *state.State {
 // When the key is provided in that form, intermediate maps are mutated
 // rather than replaced, and created when necessary.
 //
+// Setting a key to nil removes the key, along with anything nested
+// under it.
+//
 // The provided value must marshal properly by encoding/json.
 // Changes are not persisted until Commit is called.
 func (t *Transaction) Set(snapName, key string, value interface{}) error {
@@ -96,7 +101,7 @@ func (t *Transaction) Set(snapName, key string, value interface{}) error {
 
 	// Check whether it's trying to traverse a non-map from pristine. This
 	// would go unperceived by the configuration patching below.
-	if len(subkeys) > 1 {
+	if len(subkeys) > 1 && !isUnset(config, subkeys[:len(subkeys)-1]) {
 		var result interface{}
 		err = getFromPristine(snapName, subkeys, 0, t.pristine[snapName], &result)
 		if err != nil && !IsNoOption(err) {
@@ -127,14 +132,46 @@ func (t *Transaction) Get(snapName, key string, result interface{}) error {
 		return err
 	}
 
+	replacement := replacedBy(t.changes[snapName], subkeys)
+	if replacement != nil && isNull(replacement) {
+		return &NoOptionError{SnapName: snapName, Key: key}
+	}
+
 	err = GetFromChange(snapName, subkeys, 0, t.changes[snapName], result)
-	if IsNoOption(err) {
+	if IsNoOption(err) && replacement == nil {
 		err = getFromPristine(snapName, subkeys, 0, t.pristine[snapName], result)
 	}
 
 	return err
 }
 
+// replacedBy returns the value in changes that replaces the key
+// described by subkeys or one of its parents, if any.
+func replacedBy(changes map[string]interface{}, subkeys []string) *json.RawMessage {
+	for _, subkey := range subkeys {
+		switch value := changes[subkey].(type) {
+		case map[string]interface{}:
+			changes = value
+		case *json.RawMessage:
+			return value
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// isUnset returns whether the key described by subkeys, or one of its
+// parents, is removed by the given changes.
+func isUnset(changes map[string]interface{}, subkeys []string) bool {
+	replacement := replacedBy(changes, subkeys)
+	return replacement != nil && isNull(replacement)
+}
+
+func isNull(raw *json.RawMessage) bool {
+	return raw == nil || string(*raw) == "null"
+}
+
 // GetMaybe unmarshals into result the cached value of the provided snap's configuration key.
 // If the key does not exist, no error is returned.
 //
@@ -147,6 +184,54 @@ func (t *Transaction) GetMaybe(snapName, key string, result interface{}) error {
 	return nil
 }
 
+// Changes returns the changed configuration keys in the transaction,
+// as sorted "snap.key.path" strings.
+func (t *Transaction) Changes() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var out []string
+	for snapName, changes := range t.changes {
+		out = append(out, changedKeys(snapName, changes)...)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func changedKeys(prefix string, changes map[string]interface{}) []string {
+	var out []string
+	for k, v := range changes {
+		key := prefix + "." + k
+		if m, ok := v.(map[string]interface{}); ok {
+			out = append(out, changedKeys(key, m)...)
+		} else {
+			out = append(out, key)
+		}
+	}
+	return out
+}
+
+// ValidateSchema checks the configuration of the given snap, as it
+// would result from committing the transaction, against the schema.
+func (t *Transaction) ValidateSchema(snapName string, schema *snap.ConfigSchema) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	config := make(map[string]*json.RawMessage, len(t.pristine[snapName]))
+	for k, v := range t.pristine[snapName] {
+		config[k] = v
+	}
+	for k, v := range t.changes[snapName] {
+		commitKey(config, k, v)
+	}
+
+	var doc map[string]interface{}
+	if err := jsonutil.DecodeWithNumber(bytes.NewReader(*jsonRaw(config)), &doc); err != nil {
+		return fmt.Errorf("internal error: cannot unmarshal snap %q root document: %s", snapName, err)
+	}
+	return schema.Validate(doc)
+}
+
 func getFromPristine(snapName string, subkeys []string, pos int, config map[string]*json.RawMessage, result interface{}) error {
 	// special case - get root document
 	if len(subkeys) == 0 {
@@ -214,7 +299,7 @@ func (t *Transaction) Commit() {
 			config = make(map[string]*json.RawMessage)
 		}
 		for k, v := range snapChanges {
-			config[k] = commitChange(config[k], v)
+			commitKey(config, k, v)
 		}
 		t.pristine[snapName] = config
 	}
@@ -234,21 +319,33 @@ func jsonRaw(v interface{}) *json.RawMessage {
 	return &raw
 }
 
+// commitKey applies the change for key onto config, removing key if
+// the change unsets it.
+func commitKey(config map[string]*json.RawMessage, key string, change interface{}) {
+	if raw, ok := change.(*json.RawMessage); ok && isNull(raw) {
+		delete(config, key)
+		return
+	}
+	config[key] = commitChange(config[key], change)
+}
+
 func commitChange(pristine *json.RawMessage, change interface{}) *json.RawMessage {
 	switch change := change.(type) {
 	case *json.RawMessage:
 		return change
 	case map[string]interface{}:
-		if pristine == nil {
-			return jsonRaw(change)
-		}
 		var pristinem map[string]*json.RawMessage
-		if err := jsonutil.DecodeWithNumber(bytes.NewReader(*pristine), &pristinem); err != nil {
-			// Not a map. Overwrite with the change.
-			return jsonRaw(change)
+		if pristine != nil {
+			if err := jsonutil.DecodeWithNumber(bytes.NewReader(*pristine), &pristinem); err != nil {
+				// Not a map. Overwrite with the change.
+				pristinem = nil
+			}
+		}
+		if pristinem == nil {
+			pristinem = make(map[string]*json.RawMessage)
 		}
 		for k, v := range change {
-			pristinem[k] = commitChange(pristinem[k], v)
+			commitKey(pristinem, k, v)
 		}
 		return jsonRaw(pristinem)
 	}
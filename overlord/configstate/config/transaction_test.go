@@ -31,6 +31,7 @@ import (
 	"github.com/snapcore/snapd/jsonutil"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
 )
 
 func TestT(t *testing.T) { TestingT(t) }
@@ -153,6 +154,53 @@ var setGetTests = [][]setGetOp{{
 	`set one.two.three=3`,
 	`commit`,
 	`getunder one={"two":{"three":3}}`,
+}, {
+	// Unsetting keys.
+	`set one=1 two=2 three={"four":4,"five":5}`,
+	`commit`,
+	`set one=null three.four=null`,
+	`get one=- two=2 three={} three.four=-`,
+	`getunder one=1 two=2 three={"four":4,"five":5}`,
+	`commit`,
+	`get one=- two=2 three={"five":5} three.four=-`,
+	`getunder one=- two=2 three={"five":5}`,
+}, {
+	// Unsetting a subtree.
+	`set one.two.three=3 one.five=5`,
+	`commit`,
+	`set one.two=null`,
+	`get one={} one.two=- one.two.three=-`,
+	`commit`,
+	`get one={"five":5}`,
+	`getunder one={"five":5}`,
+	`set one=null`,
+	`get one=- one.five=-`,
+	`commit`,
+	`getunder one=-`,
+}, {
+	// Setting again after unsetting.
+	`set one.two=2`,
+	`commit`,
+	`set one=null`,
+	`set one.three=3`,
+	`get one={"three":3} one.two=-`,
+	`commit`,
+	`getunder one={"three":3}`,
+}, {
+	// Unsetting may go through a known scalar.
+	`set one=1`,
+	`commit`,
+	`set one=null`,
+	`set one.two=2`,
+	`get one={"two":2}`,
+	`commit`,
+	`getunder one={"two":2}`,
+}, {
+	// Unsetting unknown keys is fine.
+	`set one.two=null`,
+	`get one.two=-`,
+	`commit`,
+	`getunder one={}`,
 }, {
 	// Invalid option names.
 	`set BAD=1 => invalid option name: "BAD"`,
@@ -326,3 +374,66 @@ func (s *transactionSuite) TestState(c *C) {
 	tr := config.NewTransaction(s.state)
 	c.Check(tr.State(), DeepEquals, s.state)
 }
+
+func (s *transactionSuite) TestChanges(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	c.Check(tr.Changes(), HasLen, 0)
+
+	c.Assert(tr.Set("test-snap", "foo", "bar"), IsNil)
+	c.Assert(tr.Set("test-snap", "a.b", 1), IsNil)
+	c.Assert(tr.Set("other-snap", "c", map[string]interface{}{"d": true, "e": false}), IsNil)
+	c.Check(tr.Changes(), DeepEquals, []string{
+		"other-snap.c",
+		"test-snap.a.b",
+		"test-snap.foo",
+	})
+
+	tr.Commit()
+	c.Check(tr.Changes(), HasLen, 0)
+}
+
+func (s *transactionSuite) TestValidateSchema(c *C) {
+	schema, err := snap.ReadConfigSchema([]byte(`
+properties:
+  port:
+    type: integer
+  tls:
+    type: object
+    properties:
+      cert:
+        type: string
+      verify:
+        type: boolean
+`))
+	c.Assert(err, IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// committed configuration is taken into account
+	c.Assert(s.transaction.Set("test-snap", "tls.cert", "foo"), IsNil)
+	c.Assert(s.transaction.Set("other-snap", "unknown", "foo"), IsNil)
+	c.Check(s.transaction.ValidateSchema("test-snap", schema), IsNil)
+	s.transaction.Commit()
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("test-snap", "tls.verify", "yes"), IsNil)
+	c.Check(tr.ValidateSchema("test-snap", schema), ErrorMatches, `option "tls.verify" must be a boolean`)
+
+	tr = config.NewTransaction(s.state)
+	c.Assert(tr.Set("test-snap", "tls.key", "bar"), IsNil)
+	c.Check(tr.ValidateSchema("test-snap", schema), ErrorMatches, `option "tls.key" is not defined in the config schema`)
+
+	tr = config.NewTransaction(s.state)
+	c.Assert(tr.Set("test-snap", "port", json.Number("80")), IsNil)
+	c.Assert(tr.Set("test-snap", "tls.verify", true), IsNil)
+	c.Check(tr.ValidateSchema("test-snap", schema), IsNil)
+
+	// unsetting is fine
+	tr = config.NewTransaction(s.state)
+	c.Assert(tr.Set("test-snap", "tls.cert", nil), IsNil)
+	c.Check(tr.ValidateSchema("test-snap", schema), IsNil)
+}
@@ -0,0 +1,41 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"strconv"
+)
+
+func validateBootMaxAttempts(tr Conf) error {
+	maxAttemptsStr, err := coreCfg(tr, "boot.max-attempts")
+	if err != nil {
+		return err
+	}
+	if maxAttemptsStr == "" {
+		return nil
+	}
+
+	maxAttempts, err := strconv.Atoi(maxAttemptsStr)
+	if err != nil || maxAttempts < 1 {
+		return fmt.Errorf("boot.max-attempts must be a positive integer, not %q", maxAttemptsStr)
+	}
+	return nil
+}
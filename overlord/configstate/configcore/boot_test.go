@@ -0,0 +1,56 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+)
+
+type bootSuite struct {
+	configcoreSuite
+}
+
+var _ = Suite(&bootSuite{})
+
+func (s *bootSuite) TestConfigureBootMaxAttemptsHappy(c *C) {
+	for _, v := range []interface{}{"3", 3, 1.0} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"boot.max-attempts": v,
+			},
+		})
+		c.Assert(err, IsNil)
+	}
+}
+
+func (s *bootSuite) TestConfigureBootMaxAttemptsRejected(c *C) {
+	for _, v := range []interface{}{"0", -1, "many", 1.5} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"boot.max-attempts": v,
+			},
+		})
+		c.Assert(err, ErrorMatches, `boot.max-attempts must be a positive integer, not ".*"`)
+	}
+}
@@ -44,6 +44,10 @@ func coreCfg(tr Conf, key string) (result string, err error) {
 	if err := tr.Get("core", key, &v); err != nil && !config.IsNoOption(err) {
 		return "", err
 	}
+	if v == nil {
+		// unset, restore the default
+		return "", nil
+	}
 	// TODO: we could have a fully typed approach but at the
 	// moment we also always use "" to mean unset as well, this is
 	// the smallest change
@@ -57,6 +61,39 @@ func Run(tr Conf) error {
 	if err := validateRefreshSchedule(tr); err != nil {
 		return err
 	}
+	if err := validateBootMaxAttempts(tr); err != nil {
+		return err
+	}
+	if err := validateKernelCmdline(tr); err != nil {
+		return err
+	}
+	if err := validateKernelModules(tr); err != nil {
+		return err
+	}
+	if err := validateHostname(tr); err != nil {
+		return err
+	}
+	if err := validateTimezone(tr); err != nil {
+		return err
+	}
+	if err := validateNTPServers(tr); err != nil {
+		return err
+	}
+	if err := validateJournalConfiguration(tr); err != nil {
+		return err
+	}
+	if err := validateNetplanConfiguration(tr); err != nil {
+		return err
+	}
+	if err := validateSwapSize(tr); err != nil {
+		return err
+	}
+	if err := validateUsersConfiguration(tr); err != nil {
+		return err
+	}
+	if err := validateSSHConfiguration(tr); err != nil {
+		return err
+	}
 
 	// capture cloud information
 	if err := setCloudInfoWhenSeeding(tr); err != nil {
@@ -88,6 +125,66 @@ func Run(tr Conf) error {
 	if err := handleProxyConfiguration(tr); err != nil {
 		return err
 	}
+	// system.kernel.cmdline-append
+	if err := handleKernelCmdlineConfiguration(tr); err != nil {
+		return err
+	}
+	// system.kernel.modules.*
+	if err := handleKernelModulesConfiguration(tr); err != nil {
+		return err
+	}
+	// system.hostname
+	if err := handleHostnameConfiguration(tr); err != nil {
+		return err
+	}
+	// system.timezone
+	if err := handleTimezoneConfiguration(tr); err != nil {
+		return err
+	}
+	// system.ntp.servers
+	if err := handleNTPConfiguration(tr); err != nil {
+		return err
+	}
+	// system.journal.{persistent,max-size}
+	if err := handleJournalConfiguration(tr); err != nil {
+		return err
+	}
+	// system.network.netplan
+	if err := handleNetplanConfiguration(tr); err != nil {
+		return err
+	}
+	// swap.size
+	if err := handleSwapConfiguration(tr); err != nil {
+		return err
+	}
+	// service.console-conf.disable
+	if err := handleConsoleConfConfiguration(tr); err != nil {
+		return err
+	}
+	// system.ssh.password-auth
+	if err := handleSSHConfiguration(tr); err != nil {
+		return err
+	}
 
 	return nil
 }
+
+// ReadBack sets the options that reflect the state of the system,
+// like the hostname or the timezone, to the values actually in use so
+// that reading them returns what the system uses even if they were
+// changed outside of snapd. The given configuration is not committed.
+func ReadBack(tr Conf) error {
+	if release.OnClassic {
+		return nil
+	}
+	if err := readBackHostname(tr); err != nil {
+		return err
+	}
+	if err := readBackTimezone(tr); err != nil {
+		return err
+	}
+	if err := readBackNTPServers(tr); err != nil {
+		return err
+	}
+	return readBackNetplan(tr)
+}
@@ -19,9 +19,88 @@
 
 package configcore
 
+import (
+	"time"
+
+	"github.com/snapcore/snapd/osutil/sys"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
 var (
 	UpdatePiConfig       = updatePiConfig
 	SwitchHandlePowerKey = switchHandlePowerKey
 	SwitchDisableService = switchDisableService
 	UpdateKeyValueStream = updateKeyValueStream
 )
+
+func MockSetHostname(f func(hostname string) error) (restore func()) {
+	old := setHostname
+	setHostname = f
+	return func() {
+		setHostname = old
+	}
+}
+
+func MockSetTimezone(f func(timezone string) error) (restore func()) {
+	old := setTimezone
+	setTimezone = f
+	return func() {
+		setTimezone = old
+	}
+}
+
+func MockFindGid(f func(group string) (uint64, error)) (restore func()) {
+	old := findGid
+	findGid = f
+	return func() {
+		findGid = old
+	}
+}
+
+func MockChownPath(f func(path string, uid sys.UserID, gid sys.GroupID) error) (restore func()) {
+	old := chownPath
+	chownPath = f
+	return func() {
+		chownPath = old
+	}
+}
+
+func MockNetplanCommand(f func(args ...string) error) (restore func()) {
+	old := netplanCommand
+	netplanCommand = f
+	return func() {
+		netplanCommand = old
+	}
+}
+
+func MockStoreConnectivityCheck(f func(st *state.State) error) (restore func()) {
+	old := storeConnectivityCheck
+	storeConnectivityCheck = f
+	return func() {
+		storeConnectivityCheck = old
+	}
+}
+
+func MockNetplanConnectivityRetry(attempts int, delay time.Duration) (restore func()) {
+	oldAttempts, oldDelay := netplanConnectivityAttempts, netplanConnectivityDelay
+	netplanConnectivityAttempts, netplanConnectivityDelay = attempts, delay
+	return func() {
+		netplanConnectivityAttempts, netplanConnectivityDelay = oldAttempts, oldDelay
+	}
+}
+
+func MockDiskFree(f func(path string) (uint64, error)) (restore func()) {
+	old := diskFree
+	diskFree = f
+	return func() {
+		diskFree = old
+	}
+}
+
+func MockMkswap(f func(path string) error) (restore func()) {
+	old := mkswap
+	mkswap = f
+	return func() {
+		mkswap = old
+	}
+}
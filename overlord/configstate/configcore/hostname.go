@@ -0,0 +1,108 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// maxHostnameLen is the maximum length of a static hostname as
+// accepted by systemd.
+const maxHostnameLen = 64
+
+// validHostname matches a hostname made of RFC 1123 labels.
+var validHostname = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
+
+func hostnameFile() string {
+	return filepath.Join(dirs.GlobalRootDir, "/etc/hostname")
+}
+
+var setHostname = func(hostname string) error {
+	output, err := exec.Command("hostnamectl", "set-hostname", hostname).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("cannot set hostname: %v", osutil.OutputErr(output, err))
+	}
+	return nil
+}
+
+// currentHostname returns the static hostname of the system or "" if
+// it cannot be determined.
+func currentHostname() (string, error) {
+	content, err := ioutil.ReadFile(hostnameFile())
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+func validateHostname(tr Conf) error {
+	hostname, err := coreCfg(tr, "system.hostname")
+	if err != nil {
+		return err
+	}
+	if hostname == "" {
+		return nil
+	}
+	if len(hostname) > maxHostnameLen || !validHostname.MatchString(hostname) {
+		return fmt.Errorf("cannot set hostname: invalid hostname %q", hostname)
+	}
+	return nil
+}
+
+// handleHostnameConfiguration sets the system hostname if it differs
+// from the configured one. Unsetting the option leaves the hostname
+// untouched.
+func handleHostnameConfiguration(tr Conf) error {
+	hostname, err := coreCfg(tr, "system.hostname")
+	if err != nil {
+		return err
+	}
+	if hostname == "" {
+		return nil
+	}
+	current, err := currentHostname()
+	if err != nil {
+		return err
+	}
+	if current == hostname {
+		return nil
+	}
+	return setHostname(hostname)
+}
+
+func readBackHostname(tr Conf) error {
+	hostname, err := currentHostname()
+	if err != nil || hostname == "" {
+		return err
+	}
+	return tr.Set("core", "system.hostname", hostname)
+}
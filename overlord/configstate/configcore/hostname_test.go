@@ -0,0 +1,138 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type hostnameSuite struct {
+	configcoreSuite
+	testutil.BaseTest
+
+	hostnames []string
+}
+
+var _ = Suite(&hostnameSuite{})
+
+func (s *hostnameSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.configcoreSuite.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	c.Assert(os.MkdirAll(filepath.Join(dirs.GlobalRootDir, "etc"), 0755), IsNil)
+	s.AddCleanup(func() { dirs.SetRootDir("/") })
+	s.AddCleanup(release.MockOnClassic(false))
+
+	s.hostnames = nil
+	s.AddCleanup(configcore.MockSetHostname(func(hostname string) error {
+		s.hostnames = append(s.hostnames, hostname)
+		return nil
+	}))
+}
+
+func (s *hostnameSuite) TearDownTest(c *C) {
+	s.BaseTest.TearDownTest(c)
+}
+
+func (s *hostnameSuite) mockHostname(c *C, hostname string) {
+	err := ioutil.WriteFile(filepath.Join(dirs.GlobalRootDir, "/etc/hostname"), []byte(hostname+"\n"), 0644)
+	c.Assert(err, IsNil)
+}
+
+func (s *hostnameSuite) TestConfigureHostname(c *C) {
+	s.mockHostname(c, "localhost")
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.hostname": "my-device.example.com",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.hostnames, DeepEquals, []string{"my-device.example.com"})
+}
+
+func (s *hostnameSuite) TestConfigureHostnameUnchanged(c *C) {
+	s.mockHostname(c, "my-device")
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.hostname": "my-device",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.hostnames, HasLen, 0)
+}
+
+func (s *hostnameSuite) TestConfigureHostnameUnset(c *C) {
+	s.mockHostname(c, "my-device")
+
+	err := configcore.Run(&mockConf{state: s.state})
+	c.Assert(err, IsNil)
+	c.Check(s.hostnames, HasLen, 0)
+}
+
+func (s *hostnameSuite) TestConfigureHostnameInvalid(c *C) {
+	for _, hostname := range []string{
+		"-foo",
+		"foo-",
+		"foo_bar",
+		"foo..bar",
+		"foo bar",
+		"a123456789a123456789a123456789a123456789a123456789a123456789a1234",
+	} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"system.hostname": hostname,
+			},
+		})
+		c.Check(err, ErrorMatches, `cannot set hostname: invalid hostname ".*"`, Commentf(hostname))
+	}
+	c.Check(s.hostnames, HasLen, 0)
+}
+
+func (s *hostnameSuite) TestReadBack(c *C) {
+	s.mockHostname(c, "my-device")
+
+	conf := &mockConf{state: s.state}
+	c.Assert(configcore.ReadBack(conf), IsNil)
+	c.Check(conf.conf["system.hostname"], Equals, "my-device")
+}
+
+func (s *hostnameSuite) TestReadBackOnClassic(c *C) {
+	restore := release.MockOnClassic(true)
+	defer restore()
+	s.mockHostname(c, "my-device")
+
+	conf := &mockConf{state: s.state}
+	c.Assert(configcore.ReadBack(conf), IsNil)
+	c.Check(conf.conf, HasLen, 0)
+}
@@ -0,0 +1,181 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/sys"
+	"github.com/snapcore/snapd/systemd"
+)
+
+var validJournalSize = regexp.MustCompile(`^[0-9]+[KMGT]?$`)
+
+var (
+	findGid   = osutil.FindGid
+	chownPath = sys.ChownPath
+)
+
+func journalDir() string {
+	return filepath.Join(dirs.GlobalRootDir, "/var/log/journal")
+}
+
+func journaldCfg() string {
+	return filepath.Join(dirs.GlobalRootDir, "/etc/systemd/journald.conf.d/00-snap-core.conf")
+}
+
+func validateJournalConfiguration(tr Conf) error {
+	persistent, err := coreCfg(tr, "system.journal.persistent")
+	if err != nil {
+		return err
+	}
+	switch persistent {
+	case "", "true", "false":
+	default:
+		return fmt.Errorf("system.journal.persistent can only be set to 'true' or 'false'")
+	}
+
+	maxSize, err := coreCfg(tr, "system.journal.max-size")
+	if err != nil {
+		return err
+	}
+	if maxSize != "" && !validJournalSize.MatchString(maxSize) {
+		return fmt.Errorf("cannot set journal size: invalid size %q (want a number with an optional K, M, G or T suffix)", maxSize)
+	}
+	return nil
+}
+
+// createJournalDir creates the directory where journald keeps
+// persistent logs, owned by the systemd-journal group as journald
+// expects. It returns whether the directory was created.
+func createJournalDir() (created bool, err error) {
+	if osutil.IsDirectory(journalDir()) {
+		return false, nil
+	}
+	gid, err := findGid("systemd-journal")
+	if err != nil {
+		return false, fmt.Errorf("cannot find systemd-journal group: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(journalDir()), 0755); err != nil {
+		return false, err
+	}
+	if err := os.Mkdir(journalDir(), 0755); err != nil {
+		return false, err
+	}
+	if err := chownPath(journalDir(), 0, sys.GroupID(gid)); err != nil {
+		os.Remove(journalDir())
+		return false, err
+	}
+	// setgid so that journal files inherit the group
+	if err := os.Chmod(journalDir(), os.ModeSetgid|0755); err != nil {
+		os.Remove(journalDir())
+		return false, err
+	}
+	return true, nil
+}
+
+// writeJournaldCfg writes the given journald drop-in content, removing
+// the drop-in when there is no content.
+func writeJournaldCfg(content []byte) error {
+	if content == nil {
+		if err := os.Remove(journaldCfg()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(journaldCfg()), 0755); err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(journaldCfg(), content, 0644, 0)
+}
+
+// handleJournalConfiguration configures journald storage and size
+// limits via a drop-in and restarts journald if they changed. When
+// unset journald falls back to the defaults of the system. If journald
+// cannot be restarted the previous configuration is restored.
+func handleJournalConfiguration(tr Conf) error {
+	persistent, err := coreCfg(tr, "system.journal.persistent")
+	if err != nil {
+		return err
+	}
+	maxSize, err := coreCfg(tr, "system.journal.max-size")
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	switch persistent {
+	case "true":
+		fmt.Fprintf(&buf, "Storage=persistent\n")
+	case "false":
+		fmt.Fprintf(&buf, "Storage=volatile\n")
+	}
+	if maxSize != "" {
+		fmt.Fprintf(&buf, "SystemMaxUse=%s\nRuntimeMaxUse=%s\n", maxSize, maxSize)
+	}
+	var content []byte
+	if buf.Len() > 0 {
+		content = append([]byte("[Journal]\n"), buf.Bytes()...)
+	}
+
+	old, err := ioutil.ReadFile(journaldCfg())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if bytes.Equal(old, content) {
+		return nil
+	}
+
+	var createdDir bool
+	if persistent == "true" {
+		createdDir, err = createJournalDir()
+		if err != nil {
+			return err
+		}
+	}
+	undo := func() {
+		writeJournaldCfg(old)
+		if createdDir {
+			os.Remove(journalDir())
+		}
+	}
+
+	if err := writeJournaldCfg(content); err != nil {
+		undo()
+		return err
+	}
+
+	sysd := systemd.New(dirs.GlobalRootDir, &sysdLogger{})
+	if err := sysd.Restart("systemd-journald.service", 5*time.Minute); err != nil {
+		undo()
+		// best effort, bring journald back with the old configuration
+		sysd.Start("systemd-journald.service")
+		return fmt.Errorf("cannot restart journald: %v", err)
+	}
+	return nil
+}
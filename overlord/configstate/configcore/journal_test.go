@@ -0,0 +1,195 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/osutil/sys"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/systemd"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type journalSuite struct {
+	configcoreSuite
+	testutil.BaseTest
+
+	chowns []chownCall
+}
+
+type chownCall struct {
+	path string
+	uid  sys.UserID
+	gid  sys.GroupID
+}
+
+var _ = Suite(&journalSuite{})
+
+func (s *journalSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.configcoreSuite.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("/") })
+	s.AddCleanup(release.MockOnClassic(false))
+	s.AddCleanup(configcore.MockFindGid(func(group string) (uint64, error) {
+		c.Check(group, Equals, "systemd-journal")
+		return 42, nil
+	}))
+	s.chowns = nil
+	s.AddCleanup(configcore.MockChownPath(func(path string, uid sys.UserID, gid sys.GroupID) error {
+		s.chowns = append(s.chowns, chownCall{path, uid, gid})
+		return nil
+	}))
+	s.systemctlArgs = nil
+}
+
+func (s *journalSuite) TearDownTest(c *C) {
+	s.BaseTest.TearDownTest(c)
+}
+
+func (s *journalSuite) journaldCfg() string {
+	return filepath.Join(dirs.GlobalRootDir, "/etc/systemd/journald.conf.d/00-snap-core.conf")
+}
+
+func (s *journalSuite) journalDir() string {
+	return filepath.Join(dirs.GlobalRootDir, "/var/log/journal")
+}
+
+var journaldRestart = [][]string{
+	{"stop", "systemd-journald.service"},
+	{"show", "--property=ActiveState", "systemd-journald.service"},
+	{"start", "systemd-journald.service"},
+}
+
+func (s *journalSuite) TestConfigurePersistent(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.journal.persistent": true,
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.journaldCfg(), testutil.FileEquals, "[Journal]\nStorage=persistent\n")
+	c.Check(s.systemctlArgs, DeepEquals, journaldRestart)
+
+	st, err := os.Stat(s.journalDir())
+	c.Assert(err, IsNil)
+	c.Check(st.IsDir(), Equals, true)
+	c.Check(st.Mode()&os.ModePerm, Equals, os.FileMode(0755))
+	c.Check(st.Mode()&os.ModeSetgid, Equals, os.ModeSetgid)
+	c.Check(s.chowns, DeepEquals, []chownCall{{s.journalDir(), 0, 42}})
+}
+
+func (s *journalSuite) TestConfigureVolatileWithMaxSize(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.journal.persistent": false,
+			"system.journal.max-size":   "100M",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.journaldCfg(), testutil.FileEquals, "[Journal]\nStorage=volatile\nSystemMaxUse=100M\nRuntimeMaxUse=100M\n")
+	c.Check(osutil.FileExists(s.journalDir()), Equals, false)
+	c.Check(s.systemctlArgs, DeepEquals, journaldRestart)
+}
+
+func (s *journalSuite) TestConfigureUnchanged(c *C) {
+	c.Assert(os.MkdirAll(filepath.Dir(s.journaldCfg()), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(s.journaldCfg(), []byte("[Journal]\nSystemMaxUse=1G\nRuntimeMaxUse=1G\n"), 0644), IsNil)
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.journal.max-size": "1G",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.systemctlArgs, HasLen, 0)
+}
+
+func (s *journalSuite) TestConfigureUnset(c *C) {
+	c.Assert(os.MkdirAll(filepath.Dir(s.journaldCfg()), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(s.journaldCfg(), []byte("[Journal]\nStorage=volatile\n"), 0644), IsNil)
+
+	err := configcore.Run(&mockConf{state: s.state})
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(s.journaldCfg()), Equals, false)
+	c.Check(s.systemctlArgs, DeepEquals, journaldRestart)
+}
+
+func (s *journalSuite) TestConfigureInvalid(c *C) {
+	for _, t := range []struct {
+		key, value string
+		err        string
+	}{
+		{"system.journal.persistent", "yes", `system.journal.persistent can only be set to 'true' or 'false'`},
+		{"system.journal.max-size", "10X", `cannot set journal size: invalid size "10X" .*`},
+		{"system.journal.max-size", "-1", `cannot set journal size: invalid size "-1" .*`},
+	} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				t.key: t.value,
+			},
+		})
+		c.Check(err, ErrorMatches, t.err)
+	}
+	c.Check(osutil.FileExists(s.journaldCfg()), Equals, false)
+	c.Check(s.systemctlArgs, HasLen, 0)
+}
+
+func (s *journalSuite) TestConfigureRestartFailureUndoes(c *C) {
+	c.Assert(os.MkdirAll(filepath.Dir(s.journaldCfg()), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(s.journaldCfg(), []byte("[Journal]\nStorage=volatile\n"), 0644), IsNil)
+
+	var systemctlArgs [][]string
+	restore := systemd.MockSystemctl(func(args ...string) ([]byte, error) {
+		systemctlArgs = append(systemctlArgs, args)
+		if args[0] == "start" {
+			return nil, fmt.Errorf("boom")
+		}
+		return []byte("ActiveState=inactive"), nil
+	})
+	defer restore()
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.journal.persistent": true,
+		},
+	})
+	c.Assert(err, ErrorMatches, "cannot restart journald: boom")
+
+	// the previous configuration is restored
+	c.Check(s.journaldCfg(), testutil.FileEquals, "[Journal]\nStorage=volatile\n")
+	c.Check(osutil.FileExists(s.journalDir()), Equals, false)
+	c.Check(s.chowns, DeepEquals, []chownCall{{s.journalDir(), 0, 42}})
+	c.Check(systemctlArgs, DeepEquals, append(journaldRestart, []string{"start", "systemd-journald.service"}))
+}
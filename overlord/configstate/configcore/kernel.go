@@ -0,0 +1,239 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/partition"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+)
+
+// extraCmdlineVar is the bootloader variable the bootloader
+// configuration appends to the kernel command line.
+const extraCmdlineVar = "snap_extra_cmdline"
+
+var validModuleName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+func modprobeCfg() string {
+	return filepath.Join(dirs.GlobalRootDir, "/etc/modprobe.d/snap-core.conf")
+}
+
+func gadgetKernelCmdlineAllow(st *state.State) ([]string, error) {
+	st.Lock()
+	defer st.Unlock()
+
+	info, err := snapstate.GadgetInfo(st)
+	if err == state.ErrNoState {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	gi, err := snap.ReadGadgetInfo(info, release.OnClassic)
+	if err != nil {
+		return nil, err
+	}
+	return gi.KernelCmdline.Allow, nil
+}
+
+func cmdlineParamAllowed(param string, allow []string) bool {
+	for _, pattern := range allow {
+		if ok, _ := filepath.Match(pattern, param); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func validateKernelCmdline(tr Conf) error {
+	cmdline, err := coreCfg(tr, "system.kernel.cmdline-append")
+	if err != nil {
+		return err
+	}
+	params := strings.Fields(cmdline)
+	if len(params) == 0 {
+		return nil
+	}
+
+	allow, err := gadgetKernelCmdlineAllow(tr.State())
+	if err != nil {
+		return err
+	}
+	for _, param := range params {
+		if !cmdlineParamAllowed(param, allow) {
+			return fmt.Errorf("cannot use kernel command line parameter %q: not allowed by the gadget", param)
+		}
+	}
+	return nil
+}
+
+// handleKernelCmdlineConfiguration sets the extra kernel command line
+// arguments in the bootloader environment and requests a reboot for
+// them to take effect if they changed.
+func handleKernelCmdlineConfiguration(tr Conf) error {
+	cmdline, err := coreCfg(tr, "system.kernel.cmdline-append")
+	if err != nil {
+		return err
+	}
+	cmdline = strings.Join(strings.Fields(cmdline), " ")
+
+	loader, err := partition.FindBootloader()
+	if err != nil {
+		if cmdline == "" {
+			// nothing to set or clear
+			return nil
+		}
+		return fmt.Errorf("cannot set kernel command line: %v", err)
+	}
+
+	m, err := loader.GetBootVars(extraCmdlineVar)
+	if err != nil {
+		return err
+	}
+	if m[extraCmdlineVar] == cmdline {
+		return nil
+	}
+	if err := loader.SetBootVars(map[string]string{extraCmdlineVar: cmdline}); err != nil {
+		return err
+	}
+
+	st := tr.State()
+	st.Lock()
+	defer st.Unlock()
+	st.RequestRestart(state.RestartSystem)
+	return nil
+}
+
+func kernelModulesCfg(tr Conf) (map[string]interface{}, error) {
+	var modules map[string]interface{}
+	if err := tr.Get("core", "system.kernel.modules", &modules); err != nil && !config.IsNoOption(err) {
+		return nil, err
+	}
+	return modules, nil
+}
+
+// kernelModulesModprobeCfg generates the modprobe.d entries from the
+// system.kernel.modules.<name>.{blacklist,options} options.
+func kernelModulesModprobeCfg(tr Conf) ([]byte, error) {
+	modules, err := kernelModulesCfg(tr)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		if !validModuleName.MatchString(name) {
+			return nil, fmt.Errorf("invalid kernel module name %q", name)
+		}
+		if modules[name] == nil {
+			continue
+		}
+		opts, ok := modules[name].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("system.kernel.modules.%s must be a map with blacklist and/or options", name)
+		}
+		for key := range opts {
+			if key != "blacklist" && key != "options" {
+				return nil, fmt.Errorf("unsupported kernel module option system.kernel.modules.%s.%s", name, key)
+			}
+		}
+		if value := opts["blacklist"]; value != nil {
+			switch fmt.Sprintf("%v", value) {
+			case "true":
+				fmt.Fprintf(&buf, "blacklist %s\n", name)
+			case "false":
+			default:
+				return nil, fmt.Errorf("system.kernel.modules.%s.blacklist can only be set to 'true' or 'false'", name)
+			}
+		}
+		if value := opts["options"]; value != nil {
+			options := fmt.Sprintf("%v", value)
+			if strings.ContainsAny(options, "\n\r") {
+				return nil, fmt.Errorf("system.kernel.modules.%s.options cannot contain newlines", name)
+			}
+			if options != "" {
+				fmt.Fprintf(&buf, "options %s %s\n", name, options)
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func validateKernelModules(tr Conf) error {
+	_, err := kernelModulesModprobeCfg(tr)
+	return err
+}
+
+// handleKernelModulesConfiguration writes the modprobe.d entries for the
+// system.kernel.modules.* options and requests a reboot for them to
+// apply to the modules loaded already if they changed.
+func handleKernelModulesConfiguration(tr Conf) error {
+	content, err := kernelModulesModprobeCfg(tr)
+	if err != nil {
+		return err
+	}
+
+	old, err := ioutil.ReadFile(modprobeCfg())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if bytes.Equal(old, content) {
+		return nil
+	}
+
+	if len(content) == 0 {
+		if err := os.Remove(modprobeCfg()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		if err := os.MkdirAll(filepath.Dir(modprobeCfg()), 0755); err != nil {
+			return err
+		}
+		if err := osutil.AtomicWriteFile(modprobeCfg(), content, 0644, 0); err != nil {
+			return err
+		}
+	}
+
+	st := tr.State()
+	st.Lock()
+	defer st.Unlock()
+	st.RequestRestart(state.RestartSystem)
+	return nil
+}
@@ -0,0 +1,237 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/boot/boottest"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/partition"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type witnessRestartReqStateBackend struct {
+	restartRequested []state.RestartType
+}
+
+func (b *witnessRestartReqStateBackend) Checkpoint([]byte) error {
+	return nil
+}
+
+func (b *witnessRestartReqStateBackend) RequestRestart(t state.RestartType) {
+	b.restartRequested = append(b.restartRequested, t)
+}
+
+func (b *witnessRestartReqStateBackend) EnsureBefore(time.Duration) {}
+
+type kernelSuite struct {
+	configcoreSuite
+
+	backend    *witnessRestartReqStateBackend
+	bootloader *boottest.MockBootloader
+
+	restoreOnClassic func()
+	restoreSanitize  func()
+}
+
+var _ = Suite(&kernelSuite{})
+
+func (s *kernelSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	s.backend = &witnessRestartReqStateBackend{}
+	s.state = state.New(s.backend)
+
+	s.bootloader = boottest.NewMockBootloader("mock", c.MkDir())
+	partition.ForceBootloader(s.bootloader)
+
+	s.restoreOnClassic = release.MockOnClassic(false)
+	s.restoreSanitize = snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {})
+}
+
+func (s *kernelSuite) TearDownTest(c *C) {
+	s.restoreSanitize()
+	s.restoreOnClassic()
+	partition.ForceBootloader(nil)
+	dirs.SetRootDir("/")
+}
+
+func (s *kernelSuite) mockGadget(c *C, gadgetYaml string) {
+	si := &snap.SideInfo{RealName: "pc", Revision: snap.R(1)}
+	info := snaptest.MockSnap(c, "name: pc\ntype: gadget\nversion: 1.0", si)
+	err := ioutil.WriteFile(filepath.Join(info.MountDir(), "meta", "gadget.yaml"), []byte(gadgetYaml), 0644)
+	c.Assert(err, IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.Set(s.state, "pc", &snapstate.SnapState{
+		SnapType: "gadget",
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+	})
+}
+
+const kernelCmdlineGadgetYaml = `
+volumes:
+  pc:
+    bootloader: grub
+kernel-cmdline:
+  allow:
+    - console=*
+    - quiet
+`
+
+func (s *kernelSuite) TestKernelCmdlineAppend(c *C) {
+	s.mockGadget(c, kernelCmdlineGadgetYaml)
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.kernel.cmdline-append": "console=ttyS0,115200  quiet",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.bootloader.BootVars["snap_extra_cmdline"], Equals, "console=ttyS0,115200 quiet")
+	c.Check(s.backend.restartRequested, DeepEquals, []state.RestartType{state.RestartSystem})
+
+	// no change, no reboot
+	err = configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.kernel.cmdline-append": "console=ttyS0,115200 quiet",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.backend.restartRequested, HasLen, 1)
+
+	// unset clears it again
+	err = configcore.Run(&mockConf{
+		state: s.state,
+		conf:  map[string]interface{}{},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.bootloader.BootVars["snap_extra_cmdline"], Equals, "")
+	c.Check(s.backend.restartRequested, DeepEquals, []state.RestartType{state.RestartSystem, state.RestartSystem})
+}
+
+func (s *kernelSuite) TestKernelCmdlineAppendNotAllowed(c *C) {
+	s.mockGadget(c, kernelCmdlineGadgetYaml)
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.kernel.cmdline-append": "quiet init=/bin/sh",
+		},
+	})
+	c.Assert(err, ErrorMatches, `cannot use kernel command line parameter "init=/bin/sh": not allowed by the gadget`)
+	c.Check(s.bootloader.BootVars["snap_extra_cmdline"], Equals, "")
+	c.Check(s.backend.restartRequested, HasLen, 0)
+}
+
+func (s *kernelSuite) TestKernelCmdlineAppendNoGadget(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.kernel.cmdline-append": "quiet",
+		},
+	})
+	c.Assert(err, ErrorMatches, `cannot use kernel command line parameter "quiet": not allowed by the gadget`)
+}
+
+func (s *kernelSuite) TestKernelModules(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.kernel.modules": map[string]interface{}{
+				"pcspkr": map[string]interface{}{"blacklist": true},
+				"btusb":  map[string]interface{}{"blacklist": "true", "options": "reset=1"},
+				"snd":    map[string]interface{}{"blacklist": false},
+				"gone":   nil,
+			},
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(dirs.GlobalRootDir, "/etc/modprobe.d/snap-core.conf"), testutil.FileEquals, `blacklist btusb
+options btusb reset=1
+blacklist pcspkr
+`)
+	c.Check(s.backend.restartRequested, DeepEquals, []state.RestartType{state.RestartSystem})
+
+	// no change, no reboot
+	err = configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.kernel.modules": map[string]interface{}{
+				"pcspkr": map[string]interface{}{"blacklist": true},
+				"btusb":  map[string]interface{}{"blacklist": true, "options": "reset=1"},
+			},
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.backend.restartRequested, HasLen, 1)
+
+	// removed when nothing is configured
+	err = configcore.Run(&mockConf{
+		state: s.state,
+		conf:  map[string]interface{}{},
+	})
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(filepath.Join(dirs.GlobalRootDir, "/etc/modprobe.d/snap-core.conf")), Equals, false)
+	c.Check(s.backend.restartRequested, DeepEquals, []state.RestartType{state.RestartSystem, state.RestartSystem})
+}
+
+func (s *kernelSuite) TestKernelModulesInvalid(c *C) {
+	s.mockGadget(c, kernelCmdlineGadgetYaml)
+
+	for _, t := range []struct {
+		modules map[string]interface{}
+		err     string
+	}{
+		{map[string]interface{}{"../foo": map[string]interface{}{"blacklist": true}}, `invalid kernel module name "../foo"`},
+		{map[string]interface{}{"foo": "blacklist"}, `system.kernel.modules.foo must be a map with blacklist and/or options`},
+		{map[string]interface{}{"foo": map[string]interface{}{"blacklist": "maybe"}}, `system.kernel.modules.foo.blacklist can only be set to 'true' or 'false'`},
+		{map[string]interface{}{"foo": map[string]interface{}{"options": "a=1\ninstall foo /bin/sh"}}, `system.kernel.modules.foo.options cannot contain newlines`},
+		{map[string]interface{}{"foo": map[string]interface{}{"alias": "bar"}}, `unsupported kernel module option system.kernel.modules.foo.alias`},
+	} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"system.kernel.cmdline-append": "quiet",
+				"system.kernel.modules":        t.modules,
+			},
+		})
+		c.Check(err, ErrorMatches, t.err)
+		// nothing was applied
+		c.Check(s.bootloader.BootVars["snap_extra_cmdline"], Equals, "")
+		c.Check(s.backend.restartRequested, HasLen, 0)
+	}
+}
@@ -0,0 +1,378 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var (
+	validNetplanDevice = regexp.MustCompile(`^[a-zA-Z0-9_.:-]+$`)
+
+	// netplanDeviceKinds are the netplan sections that define devices
+	netplanDeviceKinds = map[string]bool{
+		"ethernets": true,
+		"wifis":     true,
+		"bridges":   true,
+		"bonds":     true,
+		"vlans":     true,
+		"tunnels":   true,
+	}
+)
+
+// netplanSecrets are the netplan settings holding credentials, like wifi
+// passwords or the private keys of tunnels; they are not exposed when
+// reading back the configuration in effect.
+var netplanSecrets = map[string]bool{
+	"password":            true,
+	"client-key-password": true,
+	"keys.private":        true,
+	"keys.shared":         true,
+}
+
+const redactedNetplanValue = "*****"
+
+var (
+	// how often and how long apart the store is contacted after
+	// applying a new network configuration before rolling back
+	netplanConnectivityAttempts = 6
+	netplanConnectivityDelay    = 5 * time.Second
+)
+
+func netplanDir() string {
+	return filepath.Join(dirs.GlobalRootDir, "/etc/netplan")
+}
+
+// netplanDropIn is the netplan configuration file owned by snapd; as
+// netplan merges files in lexical order it overrides the other ones.
+func netplanDropIn() string {
+	return filepath.Join(netplanDir(), "90-snapd-config.yaml")
+}
+
+var netplanCommand = func(args ...string) error {
+	output, err := exec.Command("netplan", args...).CombinedOutput()
+	if err != nil {
+		return osutil.OutputErr(output, err)
+	}
+	return nil
+}
+
+var storeConnectivityCheck = func(st *state.State) error {
+	st.Lock()
+	sto := snapstate.Store(st)
+	st.Unlock()
+	return sto.ConnectivityCheck()
+}
+
+func netplanCfg(tr Conf) (map[string]interface{}, error) {
+	var netplan map[string]interface{}
+	if err := tr.Get("core", "system.network.netplan", &netplan); err != nil && !config.IsNoOption(err) {
+		return nil, err
+	}
+	return netplan, nil
+}
+
+func validateNetplanConfiguration(tr Conf) error {
+	netplan, err := netplanCfg(tr)
+	if err != nil {
+		return err
+	}
+	if len(netplan) == 0 {
+		return nil
+	}
+
+	for key := range netplan {
+		if key != "network" {
+			return fmt.Errorf("cannot use netplan configuration: unsupported top-level key %q", key)
+		}
+	}
+	network, ok := netplan["network"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("cannot use netplan configuration: network must be a map")
+	}
+	for key, value := range network {
+		switch {
+		case key == "version":
+			if fmt.Sprintf("%v", value) != "2" {
+				return fmt.Errorf("cannot use netplan configuration: unsupported version %v", value)
+			}
+		case key == "renderer":
+			if value != "networkd" && value != "NetworkManager" {
+				return fmt.Errorf("cannot use netplan configuration: unsupported renderer %v", value)
+			}
+		case netplanDeviceKinds[key]:
+			devices, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("cannot use netplan configuration: network.%s must be a map", key)
+			}
+			for name, device := range devices {
+				if !validNetplanDevice.MatchString(name) {
+					return fmt.Errorf("cannot use netplan configuration: invalid device name %q", name)
+				}
+				if _, ok := device.(map[string]interface{}); !ok {
+					return fmt.Errorf("cannot use netplan configuration: network.%s.%s must be a map", key, name)
+				}
+			}
+		default:
+			return fmt.Errorf("cannot use netplan configuration: unsupported key network.%s", key)
+		}
+	}
+	if _, ok := network["version"]; !ok {
+		return fmt.Errorf("cannot use netplan configuration: network.version must be set")
+	}
+	return nil
+}
+
+// yamlValue turns the numbers of a configuration value into native
+// numbers so that they are not written out as strings.
+func yamlValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = yamlValue(e)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = yamlValue(e)
+		}
+		return l
+	}
+	return v
+}
+
+// configValue turns a value decoded from YAML into one that can be
+// stored in the configuration.
+func configValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("non-string key: %v", k)
+			}
+			value, err := configValue(e)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+		}
+		return m, nil
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			value, err := configValue(e)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = value
+		}
+		return l, nil
+	}
+	return v, nil
+}
+
+// mergeNetplan merges src into dst the way netplan merges its
+// configuration files: maps are merged, anything else is replaced.
+func mergeNetplan(dst, src map[string]interface{}) {
+	for k, v := range src {
+		srcMap, ok1 := v.(map[string]interface{})
+		dstMap, ok2 := dst[k].(map[string]interface{})
+		if ok1 && ok2 {
+			mergeNetplan(dstMap, srcMap)
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+// currentNetplan returns the netplan configuration in effect, merged
+// from all netplan configuration files.
+func currentNetplan() (map[string]interface{}, error) {
+	files, err := filepath.Glob(filepath.Join(netplanDir(), "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	merged := make(map[string]interface{})
+	for _, fn := range files {
+		content, err := ioutil.ReadFile(fn)
+		if err != nil {
+			return nil, err
+		}
+		var raw map[interface{}]interface{}
+		if err := yaml.Unmarshal(content, &raw); err != nil {
+			return nil, fmt.Errorf("cannot read netplan configuration %q: %v", fn, err)
+		}
+		value, err := configValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read netplan configuration %q: %v", fn, err)
+		}
+		mergeNetplan(merged, value.(map[string]interface{}))
+	}
+	return merged, nil
+}
+
+func writeNetplanDropIn(content []byte) error {
+	if content == nil {
+		if err := os.Remove(netplanDropIn()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(netplanDir(), 0755); err != nil {
+		return err
+	}
+	// the configuration may contain secrets like wifi passwords
+	return osutil.AtomicWriteFile(netplanDropIn(), content, 0600, 0)
+}
+
+// waitForStore checks whether the store can be reached, trying a few
+// times to give the network time to come up.
+func waitForStore(st *state.State) error {
+	var err error
+	for i := 0; i < netplanConnectivityAttempts; i++ {
+		if i > 0 {
+			time.Sleep(netplanConnectivityDelay)
+		}
+		if err = storeConnectivityCheck(st); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// handleNetplanConfiguration writes the system.network.netplan
+// configuration as a snapd owned netplan drop-in and applies it. If the
+// store could be reached before but not after applying it the previous
+// configuration is restored. Unsetting the option removes the drop-in.
+func handleNetplanConfiguration(tr Conf) error {
+	netplan, err := netplanCfg(tr)
+	if err != nil {
+		return err
+	}
+
+	var content []byte
+	if len(netplan) > 0 {
+		content, err = yaml.Marshal(yamlValue(netplan))
+		if err != nil {
+			return err
+		}
+	}
+
+	old, err := ioutil.ReadFile(netplanDropIn())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if bytes.Equal(old, content) {
+		return nil
+	}
+
+	if err := writeNetplanDropIn(content); err != nil {
+		return err
+	}
+	if err := netplanCommand("generate"); err != nil {
+		writeNetplanDropIn(old)
+		return fmt.Errorf("cannot use netplan configuration: %v", err)
+	}
+
+	st := tr.State()
+	wasConnected := storeConnectivityCheck(st) == nil
+
+	if err := netplanCommand("apply"); err != nil {
+		writeNetplanDropIn(old)
+		if err := netplanCommand("apply"); err != nil {
+			logger.Noticef("cannot restore previous netplan configuration: %v", err)
+		}
+		return fmt.Errorf("cannot apply netplan configuration: %v", err)
+	}
+	if !wasConnected {
+		// nothing to compare against
+		return nil
+	}
+	if err := waitForStore(st); err != nil {
+		writeNetplanDropIn(old)
+		if err := netplanCommand("apply"); err != nil {
+			logger.Noticef("cannot restore previous netplan configuration: %v", err)
+		}
+		return fmt.Errorf("cannot apply netplan configuration, the store cannot be reached with it (%v): previous configuration restored", err)
+	}
+	return nil
+}
+
+// redactNetplan replaces the credentials in the given netplan
+// configuration by a placeholder.
+func redactNetplan(netplan map[string]interface{}, parent string) {
+	for k, v := range netplan {
+		if netplanSecrets[k] || netplanSecrets[parent+"."+k] {
+			netplan[k] = redactedNetplanValue
+			continue
+		}
+		switch v := v.(type) {
+		case map[string]interface{}:
+			redactNetplan(v, k)
+		case []interface{}:
+			for _, e := range v {
+				if m, ok := e.(map[string]interface{}); ok {
+					redactNetplan(m, k)
+				}
+			}
+		}
+	}
+}
+
+func readBackNetplan(tr Conf) error {
+	netplan, err := currentNetplan()
+	if err != nil || len(netplan) == 0 {
+		return err
+	}
+	redactNetplan(netplan, "")
+	return tr.Set("core", "system.network.netplan", netplan)
+}
@@ -0,0 +1,342 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type netplanSuite struct {
+	configcoreSuite
+	testutil.BaseTest
+
+	netplanCalls []string
+	netplanErr   map[string]error
+	connectivity []error
+}
+
+var _ = Suite(&netplanSuite{})
+
+func (s *netplanSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.configcoreSuite.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("/") })
+	s.AddCleanup(release.MockOnClassic(false))
+
+	s.netplanCalls = nil
+	s.netplanErr = nil
+	s.AddCleanup(configcore.MockNetplanCommand(func(args ...string) error {
+		c.Assert(args, HasLen, 1)
+		s.netplanCalls = append(s.netplanCalls, args[0])
+		return s.netplanErr[args[0]]
+	}))
+
+	s.connectivity = nil
+	s.AddCleanup(configcore.MockStoreConnectivityCheck(func(st *state.State) error {
+		c.Check(st, Equals, s.state)
+		if len(s.connectivity) == 0 {
+			return nil
+		}
+		err := s.connectivity[0]
+		s.connectivity = s.connectivity[1:]
+		return err
+	}))
+	s.AddCleanup(configcore.MockNetplanConnectivityRetry(3, 0))
+}
+
+func (s *netplanSuite) TearDownTest(c *C) {
+	s.BaseTest.TearDownTest(c)
+}
+
+func (s *netplanSuite) dropIn() string {
+	return filepath.Join(dirs.GlobalRootDir, "/etc/netplan/90-snapd-config.yaml")
+}
+
+func (s *netplanSuite) mockNetplanFile(c *C, name, content string) {
+	fn := filepath.Join(dirs.GlobalRootDir, "/etc/netplan", name)
+	c.Assert(os.MkdirAll(filepath.Dir(fn), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(fn, []byte(content), 0644), IsNil)
+}
+
+func wifiNetplan() map[string]interface{} {
+	return map[string]interface{}{
+		"network": map[string]interface{}{
+			"version": json.Number("2"),
+			"wifis": map[string]interface{}{
+				"wlan0": map[string]interface{}{
+					"dhcp4": true,
+					"access-points": map[string]interface{}{
+						"my-ap": map[string]interface{}{
+							"password": "secret",
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+const wifiNetplanYaml = `network:
+  version: 2
+  wifis:
+    wlan0:
+      access-points:
+        my-ap:
+          password: secret
+      dhcp4: true
+`
+
+func (s *netplanSuite) TestConfigureNetplan(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.network.netplan": wifiNetplan(),
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.dropIn(), testutil.FileEquals, wifiNetplanYaml)
+	c.Check(s.netplanCalls, DeepEquals, []string{"generate", "apply"})
+
+	st, err := os.Stat(s.dropIn())
+	c.Assert(err, IsNil)
+	c.Check(st.Mode().Perm(), Equals, os.FileMode(0600))
+}
+
+func (s *netplanSuite) TestConfigureNetplanUnchanged(c *C) {
+	s.mockNetplanFile(c, "90-snapd-config.yaml", wifiNetplanYaml)
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.network.netplan": wifiNetplan(),
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.netplanCalls, HasLen, 0)
+}
+
+func (s *netplanSuite) TestConfigureNetplanUnset(c *C) {
+	s.mockNetplanFile(c, "90-snapd-config.yaml", wifiNetplanYaml)
+
+	err := configcore.Run(&mockConf{state: s.state})
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(s.dropIn()), Equals, false)
+	c.Check(s.netplanCalls, DeepEquals, []string{"generate", "apply"})
+}
+
+func (s *netplanSuite) TestConfigureNetplanGenerateFails(c *C) {
+	s.netplanErr = map[string]error{"generate": fmt.Errorf("bad config")}
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.network.netplan": wifiNetplan(),
+		},
+	})
+	c.Assert(err, ErrorMatches, "cannot use netplan configuration: bad config")
+	c.Check(osutil.FileExists(s.dropIn()), Equals, false)
+	c.Check(s.netplanCalls, DeepEquals, []string{"generate"})
+}
+
+func (s *netplanSuite) TestConfigureNetplanRollbackOnLostConnectivity(c *C) {
+	old := "network:\n  version: 2\n"
+	s.mockNetplanFile(c, "90-snapd-config.yaml", old)
+	// connected before, never after
+	s.connectivity = []error{nil, fmt.Errorf("no route"), fmt.Errorf("no route"), fmt.Errorf("no route")}
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.network.netplan": wifiNetplan(),
+		},
+	})
+	c.Assert(err, ErrorMatches, `cannot apply netplan configuration, the store cannot be reached with it \(no route\): previous configuration restored`)
+	c.Check(s.dropIn(), testutil.FileEquals, old)
+	c.Check(s.netplanCalls, DeepEquals, []string{"generate", "apply", "apply"})
+	c.Check(s.connectivity, HasLen, 0)
+}
+
+func (s *netplanSuite) TestConfigureNetplanConnectivityComesBack(c *C) {
+	s.connectivity = []error{nil, fmt.Errorf("no route"), nil}
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.network.netplan": wifiNetplan(),
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.dropIn(), testutil.FileEquals, wifiNetplanYaml)
+	c.Check(s.netplanCalls, DeepEquals, []string{"generate", "apply"})
+}
+
+func (s *netplanSuite) TestConfigureNetplanNoConnectivityBefore(c *C) {
+	// not connected before, so the new configuration is kept
+	s.connectivity = []error{fmt.Errorf("no route"), fmt.Errorf("no route")}
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.network.netplan": wifiNetplan(),
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.dropIn(), testutil.FileEquals, wifiNetplanYaml)
+	c.Check(s.netplanCalls, DeepEquals, []string{"generate", "apply"})
+	c.Check(s.connectivity, HasLen, 1)
+}
+
+func (s *netplanSuite) TestConfigureNetplanInvalid(c *C) {
+	for _, t := range []struct {
+		netplan map[string]interface{}
+		err     string
+	}{
+		{map[string]interface{}{"foo": "bar"}, `cannot use netplan configuration: unsupported top-level key "foo"`},
+		{map[string]interface{}{"network": "bar"}, `cannot use netplan configuration: network must be a map`},
+		{map[string]interface{}{"network": map[string]interface{}{"version": json.Number("1")}}, `cannot use netplan configuration: unsupported version 1`},
+		{map[string]interface{}{"network": map[string]interface{}{"ethernets": map[string]interface{}{}}}, `cannot use netplan configuration: network.version must be set`},
+		{map[string]interface{}{"network": map[string]interface{}{"version": json.Number("2"), "renderer": "foo"}}, `cannot use netplan configuration: unsupported renderer foo`},
+		{map[string]interface{}{"network": map[string]interface{}{"version": json.Number("2"), "foo": "bar"}}, `cannot use netplan configuration: unsupported key network.foo`},
+		{map[string]interface{}{"network": map[string]interface{}{"version": json.Number("2"), "wifis": "bar"}}, `cannot use netplan configuration: network.wifis must be a map`},
+		{map[string]interface{}{"network": map[string]interface{}{"version": json.Number("2"), "wifis": map[string]interface{}{"wlan0": "bar"}}}, `cannot use netplan configuration: network.wifis.wlan0 must be a map`},
+		{map[string]interface{}{"network": map[string]interface{}{"version": json.Number("2"), "wifis": map[string]interface{}{"wlan 0": map[string]interface{}{}}}}, `cannot use netplan configuration: invalid device name "wlan 0"`},
+	} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"system.network.netplan": t.netplan,
+			},
+		})
+		c.Check(err, ErrorMatches, t.err)
+	}
+	c.Check(s.netplanCalls, HasLen, 0)
+}
+
+func (s *netplanSuite) TestReadBack(c *C) {
+	s.mockNetplanFile(c, "00-default.yaml", `network:
+  version: 2
+  renderer: networkd
+  ethernets:
+    eth0:
+      dhcp4: true
+`)
+	s.mockNetplanFile(c, "90-snapd-config.yaml", `network:
+  ethernets:
+    eth0:
+      dhcp4: false
+      addresses: [10.0.0.2/24]
+`)
+
+	conf := &mockConf{state: s.state}
+	c.Assert(configcore.ReadBack(conf), IsNil)
+	c.Check(conf.conf["system.network.netplan"], DeepEquals, map[string]interface{}{
+		"network": map[string]interface{}{
+			"version":  2,
+			"renderer": "networkd",
+			"ethernets": map[string]interface{}{
+				"eth0": map[string]interface{}{
+					"dhcp4":     false,
+					"addresses": []interface{}{"10.0.0.2/24"},
+				},
+			},
+		},
+	})
+}
+
+func (s *netplanSuite) TestReadBackRedactsSecrets(c *C) {
+	s.mockNetplanFile(c, "00-installer-config.yaml", `network:
+  version: 2
+  wifis:
+    wlan0:
+      access-points:
+        home:
+          password: secret
+        work:
+          auth:
+            key-management: eap
+            identity: joe
+            password: secret
+            client-key-password: secret
+  tunnels:
+    wg0:
+      mode: wireguard
+      keys:
+        private: secret
+      peers:
+        - keys:
+            public: public-key
+            shared: secret
+`)
+
+	conf := &mockConf{state: s.state}
+	c.Assert(configcore.ReadBack(conf), IsNil)
+	c.Check(conf.conf["system.network.netplan"], DeepEquals, map[string]interface{}{
+		"network": map[string]interface{}{
+			"version": 2,
+			"wifis": map[string]interface{}{
+				"wlan0": map[string]interface{}{
+					"access-points": map[string]interface{}{
+						"home": map[string]interface{}{"password": "*****"},
+						"work": map[string]interface{}{
+							"auth": map[string]interface{}{
+								"key-management":      "eap",
+								"identity":            "joe",
+								"password":            "*****",
+								"client-key-password": "*****",
+							},
+						},
+					},
+				},
+			},
+			"tunnels": map[string]interface{}{
+				"wg0": map[string]interface{}{
+					"mode": "wireguard",
+					"keys": map[string]interface{}{"private": "*****"},
+					"peers": []interface{}{
+						map[string]interface{}{
+							"keys": map[string]interface{}{"public": "public-key", "shared": "*****"},
+						},
+					},
+				},
+			},
+		},
+	})
+}
+
+func (s *netplanSuite) TestReadBackInvalid(c *C) {
+	s.mockNetplanFile(c, "00-default.yaml", "{{")
+
+	conf := &mockConf{state: s.state}
+	c.Assert(configcore.ReadBack(conf), ErrorMatches, `cannot read netplan configuration ".*/00-default.yaml": .*`)
+}
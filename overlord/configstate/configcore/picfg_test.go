@@ -28,6 +28,7 @@ import (
 	. "gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/configstate/configcore"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/testutil"
@@ -155,3 +156,26 @@ func (s *piCfgSuite) TestConfigurePiConfigIntegration(c *C) {
 	s.checkMockConfig(c, mockConfigTxt)
 
 }
+
+func (s *piCfgSuite) TestConfigurePiConfigUnset(c *C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	s.state.Lock()
+	tr := config.NewTransaction(s.state)
+	s.state.Unlock()
+	c.Assert(tr.Set("core", "pi-config.disable-overscan", 1), IsNil)
+	c.Assert(configcore.Run(tr), IsNil)
+
+	expected := strings.Replace(mockConfigTxt, "#disable_overscan=1", "disable_overscan=1", -1)
+	s.checkMockConfig(c, expected)
+
+	s.state.Lock()
+	tr.Commit()
+	s.state.Unlock()
+
+	// unsetting restores the default
+	c.Assert(tr.Set("core", "pi-config.disable-overscan", nil), IsNil)
+	c.Assert(configcore.Run(tr), IsNil)
+	s.checkMockConfig(c, mockConfigTxt)
+}
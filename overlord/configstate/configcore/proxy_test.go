@@ -32,6 +32,7 @@ import (
 	"github.com/snapcore/snapd/asserts/assertstest"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/configstate/configcore"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/testutil"
@@ -124,6 +125,43 @@ PATH="/usr/bin"
 no_proxy=example.com,bar.com`)
 }
 
+func (s *proxySuite) TestConfigureProxyUnset(c *C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+
+	err := ioutil.WriteFile(s.mockEtcEnvironment, []byte(`
+PATH="/usr/bin"
+http_proxy=http://example.com
+no_proxy=example.com`), 0644)
+	c.Assert(err, IsNil)
+
+	s.state.Lock()
+	tr := config.NewTransaction(s.state)
+	s.state.Unlock()
+	c.Assert(tr.Set("core", "proxy.http", "http://example.com"), IsNil)
+	c.Assert(tr.Set("core", "proxy.no-proxy", "example.com"), IsNil)
+	c.Assert(configcore.Run(tr), IsNil)
+
+	s.state.Lock()
+	tr.Commit()
+	s.state.Unlock()
+
+	// unsetting restores the default of no proxy
+	c.Assert(tr.Set("core", "proxy.http", nil), IsNil)
+	c.Assert(configcore.Run(tr), IsNil)
+	c.Check(s.mockEtcEnvironment, testutil.FileEquals, `
+PATH="/usr/bin"
+#http_proxy=http://example.com
+no_proxy=example.com`)
+
+	c.Assert(tr.Set("core", "proxy", nil), IsNil)
+	c.Assert(configcore.Run(tr), IsNil)
+	c.Check(s.mockEtcEnvironment, testutil.FileEquals, `
+PATH="/usr/bin"
+#http_proxy=http://example.com
+#no_proxy=example.com`)
+}
+
 func (s *proxySuite) TestConfigureProxyStore(c *C) {
 	// set to ""
 	err := configcore.Run(&mockConf{
@@ -0,0 +1,129 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/systemd"
+)
+
+const sshdDropIn = "/etc/ssh/sshd_config.d/00-snap-core.conf"
+
+func sshdCfg() string {
+	return filepath.Join(dirs.GlobalRootDir, sshdDropIn)
+}
+
+func sshdMainCfg() string {
+	return filepath.Join(dirs.GlobalRootDir, "/etc/ssh/sshd_config")
+}
+
+// sshdReadsDropIn tells whether the main sshd configuration includes the
+// snapd drop-in, older core systems ship one without an Include.
+func sshdReadsDropIn() (bool, error) {
+	f, err := os.Open(sshdMainCfg())
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.EqualFold(fields[0], "Include") {
+			continue
+		}
+		for _, pattern := range fields[1:] {
+			// relative paths are relative to /etc/ssh
+			if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join("/etc/ssh", pattern)
+			}
+			if matched, _ := filepath.Match(pattern, sshdDropIn); matched {
+				return true, nil
+			}
+		}
+	}
+	return false, scanner.Err()
+}
+
+func validateSSHConfiguration(tr Conf) error {
+	return validateBoolOptions(tr, "system.ssh.password-auth")
+}
+
+// handleSSHConfiguration writes the sshd drop-in for
+// system.ssh.password-auth and reloads sshd if it changed. When unset
+// the sshd defaults apply. Setting it fails if sshd would not read the
+// drop-in.
+func handleSSHConfiguration(tr Conf) error {
+	passwordAuth, err := coreCfg(tr, "system.ssh.password-auth")
+	if err != nil {
+		return err
+	}
+
+	var content []byte
+	switch passwordAuth {
+	case "true":
+		content = []byte("PasswordAuthentication yes\n")
+	case "false":
+		content = []byte("PasswordAuthentication no\nChallengeResponseAuthentication no\n")
+	}
+
+	old, err := ioutil.ReadFile(sshdCfg())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if bytes.Equal(old, content) {
+		return nil
+	}
+
+	if content == nil {
+		if err := os.Remove(sshdCfg()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		ok, err := sshdReadsDropIn()
+		if err != nil {
+			return fmt.Errorf("cannot read sshd configuration: %v", err)
+		}
+		if !ok {
+			return fmt.Errorf("cannot set system.ssh.password-auth: sshd configuration does not include %s", sshdDropIn)
+		}
+		if err := os.MkdirAll(filepath.Dir(sshdCfg()), 0755); err != nil {
+			return err
+		}
+		if err := osutil.AtomicWriteFile(sshdCfg(), content, 0644, 0); err != nil {
+			return err
+		}
+	}
+
+	sysd := systemd.New(dirs.GlobalRootDir, &sysdLogger{})
+	if err := sysd.ReloadOrRestart("sshd.service"); err != nil {
+		return fmt.Errorf("cannot reload sshd: %v", err)
+	}
+	return nil
+}
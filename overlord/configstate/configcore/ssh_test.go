@@ -0,0 +1,157 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type sshSuite struct {
+	configcoreSuite
+	testutil.BaseTest
+}
+
+var _ = Suite(&sshSuite{})
+
+func (s *sshSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.configcoreSuite.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("/") })
+	s.AddCleanup(release.MockOnClassic(false))
+	s.systemctlArgs = nil
+
+	sshdConfig := filepath.Join(dirs.GlobalRootDir, "/etc/ssh/sshd_config")
+	c.Assert(os.MkdirAll(filepath.Dir(sshdConfig), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(sshdConfig, []byte("Include sshd_config.d/*.conf\nPort 22\n"), 0644), IsNil)
+}
+
+func (s *sshSuite) TearDownTest(c *C) {
+	s.BaseTest.TearDownTest(c)
+}
+
+func (s *sshSuite) sshdCfg() string {
+	return filepath.Join(dirs.GlobalRootDir, "/etc/ssh/sshd_config.d/00-snap-core.conf")
+}
+
+func (s *sshSuite) TestConfigurePasswordAuth(c *C) {
+	for _, t := range []struct {
+		value   interface{}
+		content string
+	}{
+		{false, "PasswordAuthentication no\nChallengeResponseAuthentication no\n"},
+		{"true", "PasswordAuthentication yes\n"},
+	} {
+		s.systemctlArgs = nil
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"system.ssh.password-auth": t.value,
+			},
+		})
+		c.Assert(err, IsNil)
+		c.Check(s.sshdCfg(), testutil.FileEquals, t.content)
+		c.Check(s.systemctlArgs, DeepEquals, [][]string{
+			{"try-reload-or-restart", "sshd.service"},
+		})
+	}
+}
+
+func (s *sshSuite) TestConfigurePasswordAuthUnchanged(c *C) {
+	c.Assert(os.MkdirAll(filepath.Dir(s.sshdCfg()), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(s.sshdCfg(), []byte("PasswordAuthentication yes\n"), 0644), IsNil)
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.ssh.password-auth": true,
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.systemctlArgs, HasLen, 0)
+}
+
+func (s *sshSuite) TestConfigurePasswordAuthUnset(c *C) {
+	c.Assert(os.MkdirAll(filepath.Dir(s.sshdCfg()), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(s.sshdCfg(), []byte("PasswordAuthentication no\n"), 0644), IsNil)
+
+	err := configcore.Run(&mockConf{state: s.state})
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(s.sshdCfg()), Equals, false)
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{
+		{"try-reload-or-restart", "sshd.service"},
+	})
+}
+
+func (s *sshSuite) TestConfigurePasswordAuthInvalid(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.ssh.password-auth": "no",
+		},
+	})
+	c.Check(err, ErrorMatches, "system.ssh.password-auth can only be set to 'true' or 'false'")
+	c.Check(osutil.FileExists(s.sshdCfg()), Equals, false)
+}
+
+func (s *sshSuite) TestConfigurePasswordAuthNoInclude(c *C) {
+	sshdConfig := filepath.Join(dirs.GlobalRootDir, "/etc/ssh/sshd_config")
+	for _, content := range []string{
+		"Port 22\n",
+		"# Include sshd_config.d/*.conf\n",
+		"Include /etc/ssh/other.d/*.conf\n",
+	} {
+		c.Assert(ioutil.WriteFile(sshdConfig, []byte(content), 0644), IsNil)
+
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"system.ssh.password-auth": false,
+			},
+		})
+		c.Check(err, ErrorMatches, `cannot set system.ssh.password-auth: sshd configuration does not include /etc/ssh/sshd_config.d/00-snap-core.conf`)
+		c.Check(osutil.FileExists(s.sshdCfg()), Equals, false)
+		c.Check(s.systemctlArgs, HasLen, 0)
+	}
+}
+
+func (s *sshSuite) TestConfigurePasswordAuthAbsoluteInclude(c *C) {
+	sshdConfig := filepath.Join(dirs.GlobalRootDir, "/etc/ssh/sshd_config")
+	c.Assert(ioutil.WriteFile(sshdConfig, []byte("include /etc/ssh/sshd_config.d/00-snap-core.conf\n"), 0644), IsNil)
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.ssh.password-auth": true,
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.sshdCfg(), testutil.FileEquals, "PasswordAuthentication yes\n")
+}
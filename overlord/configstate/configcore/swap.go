@@ -0,0 +1,195 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/systemd"
+)
+
+const (
+	// swapFile lives on writable in a directory owned by snapd so that
+	// nothing else cleans it up
+	swapFile = "/var/lib/snapd/swap/swapfile"
+
+	mib = 1024 * 1024
+)
+
+var validSwapSize = regexp.MustCompile(`^([0-9]+)([MG])$`)
+
+var diskFree = func(path string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return st.Bavail * uint64(st.Bsize), nil
+}
+
+var mkswap = func(path string) error {
+	output, err := exec.Command("mkswap", path).CombinedOutput()
+	if err != nil {
+		return osutil.OutputErr(output, err)
+	}
+	return nil
+}
+
+// swapSizeCfg returns the configured swap size in bytes, 0 meaning no
+// swap.
+func swapSizeCfg(tr Conf) (uint64, error) {
+	size, err := coreCfg(tr, "swap.size")
+	if err != nil {
+		return 0, err
+	}
+	if size == "" || size == "0" {
+		return 0, nil
+	}
+	m := validSwapSize.FindStringSubmatch(size)
+	if m == nil {
+		return 0, fmt.Errorf("cannot set swap size: invalid size %q (want a number with a M or G suffix, or 0)", size)
+	}
+	n, err := strconv.ParseUint(m[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cannot set swap size: invalid size %q: %v", size, err)
+	}
+	if m[2] == "G" {
+		n *= 1024
+	}
+	return n * mib, nil
+}
+
+func validateSwapSize(tr Conf) error {
+	_, err := swapSizeCfg(tr)
+	return err
+}
+
+// createSwapFile allocates a swap file of the given size and formats it.
+func createSwapFile(path string, size uint64) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	// swap files cannot have holes
+	err = syscall.Fallocate(int(f.Fd()), 0, 0, int64(size))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = mkswap(path)
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("cannot create swap file: %v", err)
+	}
+	return nil
+}
+
+// handleSwapConfiguration creates, resizes or removes the swap file
+// and the swap unit activating it according to swap.size.
+func handleSwapConfiguration(tr Conf) error {
+	size, err := swapSizeCfg(tr)
+	if err != nil {
+		return err
+	}
+
+	swapPath := filepath.Join(dirs.GlobalRootDir, swapFile)
+	unitPath := systemd.SwapUnitPath(swapFile)
+	unitName := filepath.Base(unitPath)
+	haveUnit := osutil.FileExists(unitPath)
+
+	var current uint64
+	if st, err := os.Stat(swapPath); err == nil {
+		current = uint64(st.Size())
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if current == size && haveUnit == (size > 0) {
+		return nil
+	}
+
+	if size > 0 {
+		dir := filepath.Dir(swapPath)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		free, err := diskFree(dir)
+		if err != nil {
+			return err
+		}
+		// the current swap file is replaced
+		if size > free+current {
+			return fmt.Errorf("cannot set swap size to %dM: only %dM of disk space available", size/mib, (free+current)/mib)
+		}
+	}
+
+	sysd := systemd.New(dirs.GlobalRootDir, &sysdLogger{})
+	if haveUnit {
+		if err := sysd.Stop(unitName, 5*time.Minute); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(swapPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if size == 0 {
+		if !haveUnit {
+			return nil
+		}
+		if err := sysd.Disable(unitName); err != nil {
+			return err
+		}
+		if err := os.Remove(unitPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return sysd.DaemonReload()
+	}
+
+	if err := createSwapFile(swapPath, size); err != nil {
+		// best effort, bring back the previous swap so that the unit
+		// does not point to a missing file
+		if haveUnit && current > 0 && createSwapFile(swapPath, current) == nil {
+			sysd.Start(unitName)
+		}
+		return err
+	}
+	if _, err := sysd.WriteSwapUnitFile(swapFile); err != nil {
+		return err
+	}
+	if err := sysd.DaemonReload(); err != nil {
+		return err
+	}
+	if err := sysd.Enable(unitName); err != nil {
+		return err
+	}
+	return sysd.Start(unitName)
+}
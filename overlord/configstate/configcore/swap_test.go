@@ -0,0 +1,255 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type swapSuite struct {
+	configcoreSuite
+	testutil.BaseTest
+
+	free    uint64
+	mkswaps []string
+}
+
+var _ = Suite(&swapSuite{})
+
+const mib = 1024 * 1024
+
+func (s *swapSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.configcoreSuite.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("/") })
+	s.AddCleanup(release.MockOnClassic(false))
+	c.Assert(os.MkdirAll(dirs.SnapServicesDir, 0755), IsNil)
+	s.systemctlArgs = nil
+
+	s.free = 100 * mib
+	s.AddCleanup(configcore.MockDiskFree(func(path string) (uint64, error) {
+		c.Check(path, Equals, filepath.Join(dirs.GlobalRootDir, "/var/lib/snapd/swap"))
+		return s.free, nil
+	}))
+	s.mkswaps = nil
+	s.AddCleanup(configcore.MockMkswap(func(path string) error {
+		s.mkswaps = append(s.mkswaps, path)
+		return nil
+	}))
+}
+
+func (s *swapSuite) TearDownTest(c *C) {
+	s.BaseTest.TearDownTest(c)
+}
+
+func (s *swapSuite) swapFile() string {
+	return filepath.Join(dirs.GlobalRootDir, "/var/lib/snapd/swap/swapfile")
+}
+
+func (s *swapSuite) swapUnit() string {
+	return filepath.Join(dirs.SnapServicesDir, "var-lib-snapd-swap-swapfile.swap")
+}
+
+func (s *swapSuite) mockSwap(c *C, size int64) {
+	c.Assert(os.MkdirAll(filepath.Dir(s.swapFile()), 0755), IsNil)
+	f, err := os.Create(s.swapFile())
+	c.Assert(err, IsNil)
+	c.Assert(f.Truncate(size), IsNil)
+	c.Assert(f.Close(), IsNil)
+	c.Assert(ioutil.WriteFile(s.swapUnit(), nil, 0644), IsNil)
+}
+
+func (s *swapSuite) TestConfigureSwapCreate(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"swap.size": "2M",
+		},
+	})
+	c.Assert(err, IsNil)
+
+	st, err := os.Stat(s.swapFile())
+	c.Assert(err, IsNil)
+	c.Check(st.Size(), Equals, int64(2*mib))
+	c.Check(st.Mode().Perm(), Equals, os.FileMode(0600))
+	c.Check(s.mkswaps, DeepEquals, []string{s.swapFile()})
+	c.Check(s.swapUnit(), testutil.FileContains, "What=/var/lib/snapd/swap/swapfile\n")
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{
+		{"daemon-reload"},
+		{"--root", dirs.GlobalRootDir, "enable", "var-lib-snapd-swap-swapfile.swap"},
+		{"start", "var-lib-snapd-swap-swapfile.swap"},
+	})
+}
+
+func (s *swapSuite) TestConfigureSwapResize(c *C) {
+	s.mockSwap(c, 1*mib)
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"swap.size": "2M",
+		},
+	})
+	c.Assert(err, IsNil)
+
+	st, err := os.Stat(s.swapFile())
+	c.Assert(err, IsNil)
+	c.Check(st.Size(), Equals, int64(2*mib))
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{
+		{"stop", "var-lib-snapd-swap-swapfile.swap"},
+		{"show", "--property=ActiveState", "var-lib-snapd-swap-swapfile.swap"},
+		{"daemon-reload"},
+		{"--root", dirs.GlobalRootDir, "enable", "var-lib-snapd-swap-swapfile.swap"},
+		{"start", "var-lib-snapd-swap-swapfile.swap"},
+	})
+}
+
+func (s *swapSuite) TestConfigureSwapUnchanged(c *C) {
+	s.mockSwap(c, 1*mib)
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"swap.size": "1M",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.systemctlArgs, HasLen, 0)
+	c.Check(s.mkswaps, HasLen, 0)
+}
+
+func (s *swapSuite) TestConfigureSwapRemove(c *C) {
+	for _, size := range []interface{}{nil, "0"} {
+		s.systemctlArgs = nil
+		s.mockSwap(c, 1*mib)
+
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"swap.size": size,
+			},
+		})
+		c.Assert(err, IsNil)
+		c.Check(osutil.FileExists(s.swapFile()), Equals, false)
+		c.Check(osutil.FileExists(s.swapUnit()), Equals, false)
+		c.Check(s.systemctlArgs, DeepEquals, [][]string{
+			{"stop", "var-lib-snapd-swap-swapfile.swap"},
+			{"show", "--property=ActiveState", "var-lib-snapd-swap-swapfile.swap"},
+			{"--root", dirs.GlobalRootDir, "disable", "var-lib-snapd-swap-swapfile.swap"},
+			{"daemon-reload"},
+		})
+	}
+}
+
+func (s *swapSuite) TestConfigureSwapNotEnoughSpace(c *C) {
+	s.mockSwap(c, 1*mib)
+	s.free = 2 * mib
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"swap.size": "1G",
+		},
+	})
+	c.Assert(err, ErrorMatches, "cannot set swap size to 1024M: only 3M of disk space available")
+
+	// the existing swap is untouched
+	st, err := os.Stat(s.swapFile())
+	c.Assert(err, IsNil)
+	c.Check(st.Size(), Equals, int64(1*mib))
+	c.Check(s.systemctlArgs, HasLen, 0)
+}
+
+func (s *swapSuite) TestConfigureSwapMkswapFails(c *C) {
+	restore := configcore.MockMkswap(func(path string) error {
+		return fmt.Errorf("boom")
+	})
+	defer restore()
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"swap.size": "1M",
+		},
+	})
+	c.Assert(err, ErrorMatches, "cannot create swap file: boom")
+	c.Check(osutil.FileExists(s.swapFile()), Equals, false)
+	c.Check(osutil.FileExists(s.swapUnit()), Equals, false)
+}
+
+func (s *swapSuite) TestConfigureSwapResizeMkswapFailsRestoresSwap(c *C) {
+	s.mockSwap(c, 1*mib)
+
+	restore := configcore.MockMkswap(func(path string) error {
+		st, err := os.Stat(path)
+		c.Assert(err, IsNil)
+		if st.Size() == 2*mib {
+			return fmt.Errorf("boom")
+		}
+		s.mkswaps = append(s.mkswaps, path)
+		return nil
+	})
+	defer restore()
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"swap.size": "2M",
+		},
+	})
+	c.Assert(err, ErrorMatches, "cannot create swap file: boom")
+
+	// the previous swap is back
+	st, err := os.Stat(s.swapFile())
+	c.Assert(err, IsNil)
+	c.Check(st.Size(), Equals, int64(1*mib))
+	c.Check(s.mkswaps, DeepEquals, []string{s.swapFile()})
+	c.Check(osutil.FileExists(s.swapUnit()), Equals, true)
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{
+		{"stop", "var-lib-snapd-swap-swapfile.swap"},
+		{"show", "--property=ActiveState", "var-lib-snapd-swap-swapfile.swap"},
+		{"start", "var-lib-snapd-swap-swapfile.swap"},
+	})
+}
+
+func (s *swapSuite) TestConfigureSwapInvalid(c *C) {
+	for _, size := range []string{"1", "1K", "-1M", "1.5G", "M"} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"swap.size": size,
+			},
+		})
+		c.Check(err, ErrorMatches, fmt.Sprintf(`cannot set swap size: invalid size %q \(want a number with a M or G suffix, or 0\)`, size))
+	}
+	c.Check(s.systemctlArgs, HasLen, 0)
+}
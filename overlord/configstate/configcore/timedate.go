@@ -0,0 +1,225 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/systemd"
+)
+
+var (
+	validTimezone  = regexp.MustCompile(`^[a-zA-Z0-9_+-]+(/[a-zA-Z0-9_+-]+)*$`)
+	validNTPServer = regexp.MustCompile(`^[a-zA-Z0-9.:-]+$`)
+)
+
+func timezoneFile() string {
+	return filepath.Join(dirs.GlobalRootDir, "/etc/timezone")
+}
+
+func zoneinfoDir() string {
+	return filepath.Join(dirs.GlobalRootDir, "/usr/share/zoneinfo")
+}
+
+func timesyncdCfg() string {
+	return filepath.Join(dirs.GlobalRootDir, "/etc/systemd/timesyncd.conf.d/00-snap-core.conf")
+}
+
+var setTimezone = func(timezone string) error {
+	output, err := exec.Command("timedatectl", "set-timezone", timezone).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("cannot set timezone: %v", osutil.OutputErr(output, err))
+	}
+	return nil
+}
+
+// currentTimezone returns the timezone of the system or "" if it
+// cannot be determined.
+func currentTimezone() (string, error) {
+	content, err := ioutil.ReadFile(timezoneFile())
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+func validateTimezone(tr Conf) error {
+	timezone, err := coreCfg(tr, "system.timezone")
+	if err != nil {
+		return err
+	}
+	if timezone == "" {
+		return nil
+	}
+	if !validTimezone.MatchString(timezone) || strings.Contains(timezone, "..") {
+		return fmt.Errorf("cannot set timezone: invalid timezone %q", timezone)
+	}
+	if !osutil.FileExists(filepath.Join(zoneinfoDir(), timezone)) {
+		return fmt.Errorf("cannot set timezone: unknown timezone %q", timezone)
+	}
+	return nil
+}
+
+// handleTimezoneConfiguration sets the system timezone if it differs
+// from the configured one. Unsetting the option leaves the timezone
+// untouched.
+func handleTimezoneConfiguration(tr Conf) error {
+	timezone, err := coreCfg(tr, "system.timezone")
+	if err != nil {
+		return err
+	}
+	if timezone == "" {
+		return nil
+	}
+	current, err := currentTimezone()
+	if err != nil {
+		return err
+	}
+	if current == timezone {
+		return nil
+	}
+	return setTimezone(timezone)
+}
+
+func readBackTimezone(tr Conf) error {
+	timezone, err := currentTimezone()
+	if err != nil || timezone == "" {
+		return err
+	}
+	return tr.Set("core", "system.timezone", timezone)
+}
+
+// ntpServersCfg returns the configured NTP servers, which can be given
+// either as a list or as a space or comma separated string.
+func ntpServersCfg(tr Conf) ([]string, error) {
+	var v interface{}
+	if err := tr.Get("core", "system.ntp.servers", &v); err != nil && !config.IsNoOption(err) {
+		return nil, err
+	}
+
+	var servers []string
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		servers = strings.FieldsFunc(v, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+	case []interface{}:
+		for _, server := range v {
+			s, ok := server.(string)
+			if !ok {
+				return nil, fmt.Errorf("system.ntp.servers can only contain strings")
+			}
+			servers = append(servers, s)
+		}
+	default:
+		return nil, fmt.Errorf("system.ntp.servers must be a list or a string")
+	}
+
+	for _, server := range servers {
+		if !validNTPServer.MatchString(server) {
+			return nil, fmt.Errorf("cannot set NTP servers: invalid server %q", server)
+		}
+	}
+	return servers, nil
+}
+
+func validateNTPServers(tr Conf) error {
+	_, err := ntpServersCfg(tr)
+	return err
+}
+
+// handleNTPConfiguration configures the NTP servers used by
+// systemd-timesyncd and restarts it if they changed. Unsetting the
+// option restores the default servers.
+func handleNTPConfiguration(tr Conf) error {
+	servers, err := ntpServersCfg(tr)
+	if err != nil {
+		return err
+	}
+
+	var content []byte
+	if len(servers) > 0 {
+		content = []byte(fmt.Sprintf("[Time]\nNTP=%s\n", strings.Join(servers, " ")))
+	}
+
+	current, err := ioutil.ReadFile(timesyncdCfg())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if bytes.Equal(current, content) {
+		return nil
+	}
+
+	if content == nil {
+		if err := os.Remove(timesyncdCfg()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		if err := os.MkdirAll(filepath.Dir(timesyncdCfg()), 0755); err != nil {
+			return err
+		}
+		if err := osutil.AtomicWriteFile(timesyncdCfg(), content, 0644, 0); err != nil {
+			return err
+		}
+	}
+
+	sysd := systemd.New(dirs.GlobalRootDir, &sysdLogger{})
+	return sysd.ReloadOrRestart("systemd-timesyncd.service")
+}
+
+func readBackNTPServers(tr Conf) error {
+	f, err := os.Open(timesyncdCfg())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "NTP=") {
+			servers := strings.Fields(strings.TrimPrefix(line, "NTP="))
+			if len(servers) == 0 {
+				return nil
+			}
+			return tr.Set("core", "system.ntp.servers", strings.Join(servers, " "))
+		}
+	}
+	return scanner.Err()
+}
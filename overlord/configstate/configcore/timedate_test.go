@@ -0,0 +1,205 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type timedateSuite struct {
+	configcoreSuite
+	testutil.BaseTest
+
+	timezones []string
+}
+
+var _ = Suite(&timedateSuite{})
+
+func (s *timedateSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.configcoreSuite.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	c.Assert(os.MkdirAll(filepath.Join(dirs.GlobalRootDir, "etc"), 0755), IsNil)
+	s.AddCleanup(func() { dirs.SetRootDir("/") })
+	s.AddCleanup(release.MockOnClassic(false))
+	s.systemctlArgs = nil
+
+	s.timezones = nil
+	s.AddCleanup(configcore.MockSetTimezone(func(timezone string) error {
+		s.timezones = append(s.timezones, timezone)
+		return nil
+	}))
+
+	zoneinfo := filepath.Join(dirs.GlobalRootDir, "/usr/share/zoneinfo/Europe")
+	c.Assert(os.MkdirAll(zoneinfo, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(zoneinfo, "Berlin"), nil, 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(zoneinfo, "London"), nil, 0644), IsNil)
+}
+
+func (s *timedateSuite) TearDownTest(c *C) {
+	s.BaseTest.TearDownTest(c)
+}
+
+func (s *timedateSuite) mockTimezone(c *C, timezone string) {
+	err := ioutil.WriteFile(filepath.Join(dirs.GlobalRootDir, "/etc/timezone"), []byte(timezone+"\n"), 0644)
+	c.Assert(err, IsNil)
+}
+
+func (s *timedateSuite) timesyncdCfg() string {
+	return filepath.Join(dirs.GlobalRootDir, "/etc/systemd/timesyncd.conf.d/00-snap-core.conf")
+}
+
+func (s *timedateSuite) TestConfigureTimezone(c *C) {
+	s.mockTimezone(c, "Europe/London")
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.timezone": "Europe/Berlin",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.timezones, DeepEquals, []string{"Europe/Berlin"})
+}
+
+func (s *timedateSuite) TestConfigureTimezoneUnchanged(c *C) {
+	s.mockTimezone(c, "Europe/Berlin")
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.timezone": "Europe/Berlin",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.timezones, HasLen, 0)
+}
+
+func (s *timedateSuite) TestConfigureTimezoneInvalid(c *C) {
+	for _, t := range []struct {
+		timezone string
+		err      string
+	}{
+		{"Europe/../../etc/passwd", `cannot set timezone: invalid timezone "Europe/../../etc/passwd"`},
+		{"/Europe/Berlin", `cannot set timezone: invalid timezone "/Europe/Berlin"`},
+		{"Europe/Paris", `cannot set timezone: unknown timezone "Europe/Paris"`},
+	} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"system.timezone": t.timezone,
+			},
+		})
+		c.Check(err, ErrorMatches, t.err)
+	}
+	c.Check(s.timezones, HasLen, 0)
+}
+
+func (s *timedateSuite) TestConfigureNTPServers(c *C) {
+	for _, servers := range []interface{}{
+		"ntp1.example.com ntp2.example.com",
+		"ntp1.example.com,ntp2.example.com",
+		[]interface{}{"ntp1.example.com", "ntp2.example.com"},
+	} {
+		s.systemctlArgs = nil
+		os.Remove(s.timesyncdCfg())
+
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"system.ntp.servers": servers,
+			},
+		})
+		c.Assert(err, IsNil)
+		c.Check(s.timesyncdCfg(), testutil.FileEquals, "[Time]\nNTP=ntp1.example.com ntp2.example.com\n")
+		c.Check(s.systemctlArgs, DeepEquals, [][]string{
+			{"try-reload-or-restart", "systemd-timesyncd.service"},
+		})
+	}
+}
+
+func (s *timedateSuite) TestConfigureNTPServersUnchanged(c *C) {
+	c.Assert(os.MkdirAll(filepath.Dir(s.timesyncdCfg()), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(s.timesyncdCfg(), []byte("[Time]\nNTP=ntp.example.com\n"), 0644), IsNil)
+
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"system.ntp.servers": "ntp.example.com",
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(s.systemctlArgs, HasLen, 0)
+}
+
+func (s *timedateSuite) TestConfigureNTPServersUnset(c *C) {
+	c.Assert(os.MkdirAll(filepath.Dir(s.timesyncdCfg()), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(s.timesyncdCfg(), []byte("[Time]\nNTP=ntp.example.com\n"), 0644), IsNil)
+
+	err := configcore.Run(&mockConf{state: s.state})
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(s.timesyncdCfg()), Equals, false)
+	c.Check(s.systemctlArgs, DeepEquals, [][]string{
+		{"try-reload-or-restart", "systemd-timesyncd.service"},
+	})
+}
+
+func (s *timedateSuite) TestConfigureNTPServersInvalid(c *C) {
+	for _, t := range []struct {
+		servers interface{}
+		err     string
+	}{
+		{"ntp.example.com;reboot", `cannot set NTP servers: invalid server "ntp.example.com;reboot"`},
+		{[]interface{}{"ntp.example.com", 1.0}, `system.ntp.servers can only contain strings`},
+		{map[string]interface{}{"a": "b"}, `system.ntp.servers must be a list or a string`},
+	} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"system.ntp.servers": t.servers,
+			},
+		})
+		c.Check(err, ErrorMatches, t.err)
+	}
+	c.Check(osutil.FileExists(s.timesyncdCfg()), Equals, false)
+}
+
+func (s *timedateSuite) TestReadBack(c *C) {
+	s.mockTimezone(c, "Europe/Berlin")
+	c.Assert(os.MkdirAll(filepath.Dir(s.timesyncdCfg()), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(s.timesyncdCfg(), []byte("[Time]\nNTP=ntp1.example.com  ntp2.example.com\n"), 0644), IsNil)
+
+	conf := &mockConf{state: s.state}
+	c.Assert(configcore.ReadBack(conf), IsNil)
+	c.Check(conf.conf, DeepEquals, map[string]interface{}{
+		"system.timezone":    "Europe/Berlin",
+		"system.ntp.servers": "ntp1.example.com ntp2.example.com",
+	})
+}
@@ -0,0 +1,98 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// consoleConfComplete is the marker that stops console-conf from
+// offering to configure the device.
+func consoleConfComplete() string {
+	return filepath.Join(dirs.GlobalRootDir, "/var/lib/console-conf/complete")
+}
+
+func validateBoolOptions(tr Conf, keys ...string) error {
+	for _, key := range keys {
+		value, err := coreCfg(tr, key)
+		if err != nil {
+			return err
+		}
+		switch value {
+		case "", "true", "false":
+		default:
+			return fmt.Errorf("%s can only be set to 'true' or 'false'", key)
+		}
+	}
+	return nil
+}
+
+// validateUsersConfiguration validates users.create.automatic, which
+// is honoured when users are created from system-user assertions, and
+// service.console-conf.disable.
+func validateUsersConfiguration(tr Conf) error {
+	return validateBoolOptions(tr, "users.create.automatic", "service.console-conf.disable")
+}
+
+// consoleConfMarker is the content of the console-conf marker written by
+// snapd, which tells it apart from the one console-conf writes itself once
+// the device is configured.
+var consoleConfMarker = []byte("disabled by snapd with service.console-conf.disable\n")
+
+// handleConsoleConfConfiguration disables console-conf by marking it
+// as complete, or enables it again by removing the marker, but only if
+// snapd wrote it.
+func handleConsoleConfConfiguration(tr Conf) error {
+	disable, err := coreCfg(tr, "service.console-conf.disable")
+	if err != nil {
+		return err
+	}
+	switch disable {
+	case "true":
+		if osutil.FileExists(consoleConfComplete()) {
+			return nil
+		}
+		if err := os.MkdirAll(filepath.Dir(consoleConfComplete()), 0755); err != nil {
+			return err
+		}
+		return osutil.AtomicWriteFile(consoleConfComplete(), consoleConfMarker, 0644, 0)
+	case "false":
+		content, err := ioutil.ReadFile(consoleConfComplete())
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(content, consoleConfMarker) {
+			// console-conf completed on its own, keep it that way
+			return nil
+		}
+		return os.Remove(consoleConfComplete())
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type usersSuite struct {
+	configcoreSuite
+	testutil.BaseTest
+}
+
+var _ = Suite(&usersSuite{})
+
+func (s *usersSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.configcoreSuite.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("/") })
+	s.AddCleanup(release.MockOnClassic(false))
+}
+
+func (s *usersSuite) TearDownTest(c *C) {
+	s.BaseTest.TearDownTest(c)
+}
+
+func (s *usersSuite) consoleConfComplete() string {
+	return filepath.Join(dirs.GlobalRootDir, "/var/lib/console-conf/complete")
+}
+
+func (s *usersSuite) TestConfigureConsoleConfDisable(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"service.console-conf.disable": true,
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(s.consoleConfComplete()), Equals, true)
+}
+
+func (s *usersSuite) TestConfigureConsoleConfEnable(c *C) {
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"service.console-conf.disable": true,
+		},
+	})
+	c.Assert(err, IsNil)
+
+	err = configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"service.console-conf.disable": false,
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(s.consoleConfComplete()), Equals, false)
+}
+
+func (s *usersSuite) TestConfigureConsoleConfEnableKeepsCompleted(c *C) {
+	// console-conf marked itself as complete
+	c.Assert(os.MkdirAll(filepath.Dir(s.consoleConfComplete()), 0755), IsNil)
+	f, err := os.Create(s.consoleConfComplete())
+	c.Assert(err, IsNil)
+	f.Close()
+
+	for _, disable := range []bool{false, true, false} {
+		err = configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				"service.console-conf.disable": disable,
+			},
+		})
+		c.Assert(err, IsNil)
+		c.Check(s.consoleConfComplete(), testutil.FileEquals, "")
+	}
+}
+
+func (s *usersSuite) TestConfigureConsoleConfUnset(c *C) {
+	c.Assert(os.MkdirAll(filepath.Dir(s.consoleConfComplete()), 0755), IsNil)
+	f, err := os.Create(s.consoleConfComplete())
+	c.Assert(err, IsNil)
+	f.Close()
+
+	err = configcore.Run(&mockConf{state: s.state})
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(s.consoleConfComplete()), Equals, true)
+}
+
+func (s *usersSuite) TestConfigureInvalid(c *C) {
+	for _, key := range []string{"users.create.automatic", "service.console-conf.disable"} {
+		err := configcore.Run(&mockConf{
+			state: s.state,
+			conf: map[string]interface{}{
+				key: "maybe",
+			},
+		})
+		c.Check(err, ErrorMatches, key+" can only be set to 'true' or 'false'")
+	}
+}
+
+func (s *usersSuite) TestConfigureUsersCreateAutomatic(c *C) {
+	// only validated here, it is honoured when creating users
+	err := configcore.Run(&mockConf{
+		state: s.state,
+		conf: map[string]interface{}{
+			"users.create.automatic": false,
+		},
+	})
+	c.Assert(err, IsNil)
+}
@@ -27,6 +27,7 @@ import (
 	"time"
 
 	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
@@ -49,7 +50,9 @@ func ConfigureHookTimeout() time.Duration {
 
 // ConfigureInstalled returns a taskset to apply the given
 // configuration patch for an installed snap. It returns
-// snap.NotInstalledError if the snap is not installed.
+// snap.NotInstalledError if the snap is not installed and
+// snap.ConfigValidationError if the patch does not conform to the
+// snap config schema.
 func ConfigureInstalled(st *state.State, snapName string, patch map[string]interface{}, flags int) (*state.TaskSet, error) {
 	// core is handled internally and can be configured before
 	// being installed
@@ -62,12 +65,45 @@ func ConfigureInstalled(st *state.State, snapName string, patch map[string]inter
 		if !snapst.IsInstalled() {
 			return nil, &snap.NotInstalledError{Snap: snapName}
 		}
+
+		// report schema violations right away, before the
+		// configure hook gets to run
+		if err := validatePatch(st, snapName, patch); err != nil {
+			return nil, err
+		}
 	}
 
 	taskset := Configure(st, snapName, patch, flags)
 	return taskset, nil
 }
 
+// validateSchema checks the configuration of the snap in the
+// transaction against the snap config schema, if it has one.
+func validateSchema(st *state.State, tr *config.Transaction, snapName string) error {
+	info, err := snapstate.CurrentInfo(st, snapName)
+	if _, ok := err.(*snap.NotInstalledError); ok {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.ConfigSchema == nil {
+		return nil
+	}
+	return tr.ValidateSchema(snapName, info.ConfigSchema)
+}
+
+func validatePatch(st *state.State, snapName string, patch map[string]interface{}) error {
+	tr := config.NewTransaction(st)
+	for key, value := range patch {
+		if err := tr.Set(snapName, key, value); err != nil {
+			// invalid keys are reported by the configure task
+			return nil
+		}
+	}
+	return validateSchema(st, tr, snapName)
+}
+
 // Configure returns a taskset to apply the given configuration patch.
 func Configure(st *state.State, snapName string, patch map[string]interface{}, flags int) *state.TaskSet {
 	summary := fmt.Sprintf(i18n.G("Run configure hook of %q snap"), snapName)
@@ -20,10 +20,14 @@
 package configstate_test
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
 	"time"
 
 	. "gopkg.in/check.v1"
 
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/configstate/configcore"
@@ -31,6 +35,7 @@ import (
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
 )
 
 type tasksetsSuite struct {
@@ -156,6 +161,41 @@ func (s *tasksetsSuite) TestConfigureNotInstalled(c *C) {
 	c.Check(err, IsNil)
 }
 
+func (s *tasksetsSuite) TestConfigureInstalledValidatesSchema(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("/")
+	restore := snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {})
+	defer restore()
+
+	si := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(1)}
+	info := snaptest.MockSnap(c, "name: test-snap\nversion: 1", si)
+	err := ioutil.WriteFile(filepath.Join(info.MountDir(), "meta", "config-schema.yaml"), []byte(`
+properties:
+  port:
+    type: integer
+`), 0644)
+	c.Assert(err, IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+		Active:   true,
+	})
+
+	_, err = configstate.ConfigureInstalled(s.state, "test-snap", map[string]interface{}{"port": "http"}, 0)
+	c.Check(err, ErrorMatches, `option "port" must be an integer`)
+	c.Check(err, FitsTypeOf, &snap.ConfigValidationError{})
+
+	_, err = configstate.ConfigureInstalled(s.state, "test-snap", map[string]interface{}{"prot": json.Number("80")}, 0)
+	c.Check(err, ErrorMatches, `option "prot" is not defined in the config schema`)
+
+	ts, err := configstate.ConfigureInstalled(s.state, "test-snap", map[string]interface{}{"port": json.Number("80")}, 0)
+	c.Assert(err, IsNil)
+	c.Check(ts.Tasks(), HasLen, 1)
+}
+
 type configcoreHijackSuite struct {
 	o     *overlord.Overlord
 	state *state.State
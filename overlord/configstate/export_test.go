@@ -20,3 +20,11 @@
 package configstate
 
 var NewConfigureHandler = newConfigureHandler
+
+func MockMaxConfigHistory(n int) (restore func()) {
+	old := maxConfigHistory
+	maxConfigHistory = n
+	return func() {
+		maxConfigHistory = old
+	}
+}
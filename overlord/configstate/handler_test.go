@@ -212,3 +212,137 @@ volumes:
 	err = s.handler.Before()
 	c.Check(err, ErrorMatches, `cannot apply gadget config defaults for snap "test-snap", no configure hook`)
 }
+
+func (s *configureHandlerSuite) TestDoneValidatesSchema(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("/")
+
+	si := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(1)}
+	info := snaptest.MockSnap(c, "name: test-snap\nversion: 1", si)
+	err := ioutil.WriteFile(filepath.Join(info.MountDir(), "meta", "config-schema.yaml"), []byte(`
+properties:
+  foo:
+    type: string
+    enum: [bar, baz]
+`), 0644)
+	c.Assert(err, IsNil)
+
+	s.context.Lock()
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+	})
+	s.context.Set("patch", map[string]interface{}{
+		"foo": "bar",
+	})
+	s.context.Unlock()
+
+	c.Assert(s.handler.Before(), IsNil)
+	c.Check(s.handler.Done(), IsNil)
+
+	// as if set by the hook via snapctl
+	s.context.Lock()
+	tr := configstate.ContextTransaction(s.context)
+	s.context.Unlock()
+	c.Assert(tr.Set("test-snap", "foo", "qux"), IsNil)
+
+	c.Check(s.handler.Done(), ErrorMatches, `option "foo" must be one of: bar, baz`)
+}
+
+func (s *configureHandlerSuite) TestDoneQueuesReloadOnConfig(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("/")
+
+	si := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(1)}
+	snaptest.MockSnap(c, `name: test-snap
+version: 1
+apps:
+  svc:
+    daemon: simple
+    reload-on-config: true
+  other:
+    daemon: simple
+`, si)
+
+	s.state.Lock()
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+	})
+	chg := s.state.NewChange("configure-snap", "...")
+	task := s.state.NewTask("run-hook", "...")
+	chg.AddTask(task)
+	s.state.Unlock()
+
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: "configure"}
+	context, err := hookstate.NewContext(task, s.state, setup, hooktest.NewMockHandler(), "")
+	c.Assert(err, IsNil)
+	handler := configstate.NewConfigureHandler(context)
+
+	// no configuration change, nothing to reload
+	c.Assert(handler.Before(), IsNil)
+	c.Assert(handler.Done(), IsNil)
+	s.state.Lock()
+	c.Check(chg.Tasks(), HasLen, 1)
+	s.state.Unlock()
+
+	context.Lock()
+	tr := configstate.ContextTransaction(context)
+	context.Unlock()
+	c.Assert(tr.Set("test-snap", "foo", "bar"), IsNil)
+	c.Assert(handler.Done(), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	tasks := chg.Tasks()
+	c.Assert(tasks, HasLen, 2)
+	c.Check(tasks[1].Kind(), Equals, "reload-snap-services")
+	c.Check(tasks[1].WaitTasks(), DeepEquals, []*state.Task{task})
+	s.state.Unlock()
+
+	// a reload already queued, e.g. via snapctl, is not repeated
+	c.Assert(handler.Done(), IsNil)
+	s.state.Lock()
+	c.Check(chg.Tasks(), HasLen, 2)
+}
+
+func (s *configureHandlerSuite) TestDoneNoReloadOnConfig(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("/")
+
+	si := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(1)}
+	snaptest.MockSnap(c, `name: test-snap
+version: 1
+apps:
+  svc:
+    daemon: simple
+`, si)
+
+	s.state.Lock()
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+	})
+	chg := s.state.NewChange("configure-snap", "...")
+	task := s.state.NewTask("run-hook", "...")
+	chg.AddTask(task)
+	s.state.Unlock()
+
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: "configure"}
+	context, err := hookstate.NewContext(task, s.state, setup, hooktest.NewMockHandler(), "")
+	c.Assert(err, IsNil)
+	handler := configstate.NewConfigureHandler(context)
+
+	context.Lock()
+	context.Set("patch", map[string]interface{}{"foo": "bar"})
+	context.Unlock()
+	c.Assert(handler.Before(), IsNil)
+	c.Assert(handler.Done(), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(chg.Tasks(), HasLen, 1)
+}
@@ -0,0 +1,203 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configstate
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// maxConfigHistory is the number of configuration history entries
+// kept for each snap.
+var maxConfigHistory = 20
+
+// HistoryEntry records a committed configuration change of a snap.
+type HistoryEntry struct {
+	ID     int       `json:"id"`
+	Time   time.Time `json:"time"`
+	Change string    `json:"change,omitempty"`
+	User   string    `json:"user,omitempty"`
+	// Values and Previous map the changed option keys to their new
+	// and previous values respectively, nil meaning unset.
+	Values   map[string]interface{} `json:"values"`
+	Previous map[string]interface{} `json:"previous"`
+}
+
+func getHistory(st *state.State) (map[string][]*HistoryEntry, error) {
+	var history map[string][]*HistoryEntry
+	err := st.Get("config-history", &history)
+	if err == state.ErrNoState {
+		return make(map[string][]*HistoryEntry), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// History returns the recorded configuration history of the given
+// snap, oldest first.
+func History(st *state.State, snapName string) ([]*HistoryEntry, error) {
+	history, err := getHistory(st)
+	if err != nil {
+		return nil, err
+	}
+	return history[snapName], nil
+}
+
+// recordHistory records in the configuration history the changes of
+// tr that are about to be committed.
+func recordHistory(st *state.State, tr *config.Transaction, chg *state.Change) error {
+	changes := tr.Changes()
+	if len(changes) == 0 {
+		return nil
+	}
+
+	history, err := getHistory(st)
+	if err != nil {
+		return err
+	}
+
+	var user string
+	var changeID string
+	if chg != nil {
+		changeID = chg.ID()
+		if err := chg.Get("config-user", &user); err != nil && err != state.ErrNoState {
+			return err
+		}
+	}
+
+	now := time.Now()
+	current := config.NewTransaction(st)
+	entries := make(map[string]*HistoryEntry)
+	for _, path := range changes {
+		parts := strings.SplitN(path, ".", 2)
+		snapName, key := parts[0], parts[1]
+
+		var value, previous interface{}
+		if err := tr.GetMaybe(snapName, key, &value); err != nil {
+			return err
+		}
+		if err := current.GetMaybe(snapName, key, &previous); err != nil {
+			return err
+		}
+		if reflect.DeepEqual(value, previous) {
+			continue
+		}
+
+		entry := entries[snapName]
+		if entry == nil {
+			entry = &HistoryEntry{
+				Time:     now,
+				Change:   changeID,
+				User:     user,
+				Values:   make(map[string]interface{}),
+				Previous: make(map[string]interface{}),
+			}
+			entries[snapName] = entry
+		}
+		entry.Values[key] = value
+		entry.Previous[key] = previous
+	}
+
+	for snapName, entry := range entries {
+		snapHistory := history[snapName]
+		entry.ID = 1
+		if len(snapHistory) > 0 {
+			entry.ID = snapHistory[len(snapHistory)-1].ID + 1
+		}
+		snapHistory = append(snapHistory, entry)
+		if len(snapHistory) > maxConfigHistory {
+			snapHistory = snapHistory[len(snapHistory)-maxConfigHistory:]
+		}
+		history[snapName] = snapHistory
+	}
+	st.Set("config-history", history)
+	return nil
+}
+
+// RevertPatch returns the configuration patch that restores the
+// configuration of the given snap to how it was right after the
+// history entry with the given id was committed.
+func RevertPatch(st *state.State, snapName string, id int) (map[string]interface{}, error) {
+	history, err := History(st, snapName)
+	if err != nil {
+		return nil, err
+	}
+
+	pos := -1
+	for i, entry := range history {
+		if entry.ID == id {
+			pos = i
+			break
+		}
+	}
+	if pos < 0 {
+		return nil, fmt.Errorf("cannot find configuration history entry %d for snap %q", id, snapName)
+	}
+
+	patch := make(map[string]interface{})
+	// going backwards the oldest previous value of each key wins
+	for i := len(history) - 1; i > pos; i-- {
+		for key, value := range history[i].Previous {
+			addToPatch(patch, key, value)
+		}
+	}
+	return patch, nil
+}
+
+// addToPatch sets key to value in patch, superseding any value set
+// before for key or its sub-keys, and setting it inside the value of
+// a parent key if there is one already.
+func addToPatch(patch map[string]interface{}, key string, value interface{}) {
+	for k := range patch {
+		if strings.HasPrefix(k, key+".") {
+			delete(patch, k)
+		}
+	}
+	subkeys := strings.Split(key, ".")
+	for i := len(subkeys) - 1; i > 0; i-- {
+		parent, ok := patch[strings.Join(subkeys[:i], ".")]
+		if !ok {
+			continue
+		}
+		m, ok := parent.(map[string]interface{})
+		if !ok {
+			m = make(map[string]interface{})
+			patch[strings.Join(subkeys[:i], ".")] = m
+		}
+		for _, subkey := range subkeys[i : len(subkeys)-1] {
+			sub, ok := m[subkey].(map[string]interface{})
+			if !ok {
+				sub = make(map[string]interface{})
+				m[subkey] = sub
+			}
+			m = sub
+		}
+		m[subkeys[len(subkeys)-1]] = value
+		return
+	}
+	patch[key] = value
+}
@@ -0,0 +1,167 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configstate_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+type historySuite struct {
+	state *state.State
+}
+
+var _ = Suite(&historySuite{})
+
+func (s *historySuite) SetUpTest(c *C) {
+	s.state = state.New(nil)
+}
+
+// set commits the given patch through a hook context as the configure
+// handler would, and returns the id of the change used.
+func (s *historySuite) set(c *C, user string, patch map[string]interface{}) string {
+	s.state.Lock()
+	chg := s.state.NewChange("configure-snap", "...")
+	if user != "" {
+		chg.Set("config-user", user)
+	}
+	task := s.state.NewTask("run-hook", "...")
+	chg.AddTask(task)
+	s.state.Unlock()
+
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: "configure"}
+	context, err := hookstate.NewContext(task, s.state, setup, hooktest.NewMockHandler(), "")
+	c.Assert(err, IsNil)
+
+	context.Lock()
+	tr := configstate.ContextTransaction(context)
+	context.Unlock()
+	for key, value := range patch {
+		c.Assert(tr.Set("test-snap", key, value), IsNil)
+	}
+
+	context.Lock()
+	defer context.Unlock()
+	c.Assert(context.Done(), IsNil)
+	return chg.ID()
+}
+
+func (s *historySuite) TestRecordHistory(c *C) {
+	chg1 := s.set(c, "frank", map[string]interface{}{"foo": "bar", "baz": 1})
+	chg2 := s.set(c, "", map[string]interface{}{"foo": "qux", "baz": 1})
+	// no actual change, no entry
+	s.set(c, "", map[string]interface{}{"foo": "qux"})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	history, err := configstate.History(s.state, "test-snap")
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 2)
+
+	c.Check(history[0].ID, Equals, 1)
+	c.Check(history[0].Change, Equals, chg1)
+	c.Check(history[0].User, Equals, "frank")
+	c.Check(history[0].Values, DeepEquals, map[string]interface{}{"foo": "bar", "baz": 1.0})
+	c.Check(history[0].Previous, DeepEquals, map[string]interface{}{"foo": nil, "baz": nil})
+	c.Check(history[0].Time.IsZero(), Equals, false)
+
+	c.Check(history[1].ID, Equals, 2)
+	c.Check(history[1].Change, Equals, chg2)
+	c.Check(history[1].User, Equals, "")
+	c.Check(history[1].Values, DeepEquals, map[string]interface{}{"foo": "qux"})
+	c.Check(history[1].Previous, DeepEquals, map[string]interface{}{"foo": "bar"})
+
+	history, err = configstate.History(s.state, "other-snap")
+	c.Assert(err, IsNil)
+	c.Check(history, HasLen, 0)
+}
+
+func (s *historySuite) TestRecordHistoryBounded(c *C) {
+	restore := configstate.MockMaxConfigHistory(3)
+	defer restore()
+
+	for i := 1; i <= 5; i++ {
+		s.set(c, "", map[string]interface{}{"foo": i})
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	history, err := configstate.History(s.state, "test-snap")
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 3)
+	for i, entry := range history {
+		c.Check(entry.ID, Equals, i+3)
+	}
+}
+
+func (s *historySuite) TestRevertPatch(c *C) {
+	s.set(c, "", map[string]interface{}{"foo": "bar"})
+	s.set(c, "", map[string]interface{}{"foo": "baz", "a": map[string]interface{}{"b": 1, "c": 2}})
+	s.set(c, "", map[string]interface{}{"a.b": 3})
+	s.set(c, "", map[string]interface{}{"a": map[string]interface{}{"d": 4}, "foo": "qux"})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	patch, err := configstate.RevertPatch(s.state, "test-snap", 4)
+	c.Assert(err, IsNil)
+	c.Check(patch, HasLen, 0)
+
+	patch, err = configstate.RevertPatch(s.state, "test-snap", 3)
+	c.Assert(err, IsNil)
+	c.Check(patch, DeepEquals, map[string]interface{}{
+		"foo": "baz",
+		"a":   map[string]interface{}{"b": 3.0, "c": 2.0},
+	})
+
+	patch, err = configstate.RevertPatch(s.state, "test-snap", 2)
+	c.Assert(err, IsNil)
+	c.Check(patch, DeepEquals, map[string]interface{}{
+		"foo": "baz",
+		"a":   map[string]interface{}{"b": 1.0, "c": 2.0},
+	})
+
+	patch, err = configstate.RevertPatch(s.state, "test-snap", 1)
+	c.Assert(err, IsNil)
+	c.Check(patch, DeepEquals, map[string]interface{}{
+		"foo": "bar",
+		"a":   nil,
+	})
+
+	// applying the patch restores the configuration
+	tr := config.NewTransaction(s.state)
+	for key, value := range patch {
+		c.Assert(tr.Set("test-snap", key, value), IsNil)
+	}
+	var foo string
+	c.Assert(tr.Get("test-snap", "foo", &foo), IsNil)
+	c.Check(foo, Equals, "bar")
+
+	_, err = configstate.RevertPatch(s.state, "test-snap", 42)
+	c.Check(err, ErrorMatches, `cannot find configuration history entry 42 for snap "test-snap"`)
+}
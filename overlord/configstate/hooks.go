@@ -21,11 +21,13 @@ package configstate
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
 )
 
 // configureHandler is the handler for the configure hook.
@@ -50,6 +52,13 @@ func ContextTransaction(context *hookstate.Context) *config.Transaction {
 	tr = config.NewTransaction(context.State())
 
 	context.OnDone(func() error {
+		var chg *state.Change
+		if task, ok := context.Task(); ok {
+			chg = task.Change()
+		}
+		if err := recordHistory(context.State(), tr, chg); err != nil {
+			return err
+		}
 		tr.Commit()
 		return nil
 	})
@@ -114,6 +123,73 @@ func (h *configureHandler) Before() error {
 // Done is called by the HookManager after the configure hook has exited
 // successfully.
 func (h *configureHandler) Done() error {
+	h.context.Lock()
+	defer h.context.Unlock()
+
+	tr := ContextTransaction(h.context)
+	if err := validateSchema(h.context.State(), tr, h.context.SnapName()); err != nil {
+		return err
+	}
+	return queueReloadOnConfig(h.context, tr)
+}
+
+// queueReloadOnConfig adds to the change of the hook a task to reload
+// the services of the snap declaring reload-on-config, if the hook
+// changed the configuration of the snap.
+func queueReloadOnConfig(context *hookstate.Context, tr *config.Transaction) error {
+	hookTask, ok := context.Task()
+	if !ok || hookTask.Change() == nil {
+		return nil
+	}
+
+	snapName := context.SnapName()
+	changed := false
+	for _, key := range tr.Changes() {
+		if strings.HasPrefix(key, snapName+".") {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return nil
+	}
+	for _, t := range hookTask.HaltTasks() {
+		if t.Kind() == "reload-snap-services" {
+			// already requested by the hook via snapctl
+			return nil
+		}
+	}
+
+	st := context.State()
+	info, err := snapstate.CurrentInfo(st, snapName)
+	if _, ok := err.(*snap.NotInstalledError); ok {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	reload := false
+	for _, svc := range info.Services() {
+		if svc.ReloadOnConfig {
+			reload = true
+			break
+		}
+	}
+	if !reload {
+		return nil
+	}
+
+	ts, err := snapstate.ReloadServices(st, snapName, nil)
+	if err != nil {
+		return err
+	}
+	ts.WaitFor(hookTask)
+	for _, lane := range hookTask.Lanes() {
+		if lane != 0 {
+			ts.JoinLane(lane)
+		}
+	}
+	hookTask.Change().AddAll(ts)
 	return nil
 }
 
@@ -27,8 +27,10 @@ import (
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/sysdb"
+	"github.com/snapcore/snapd/boot"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/hookstate"
@@ -78,6 +80,9 @@ func Manager(s *state.State, hookManager *hookstate.HookManager) (*DeviceManager
 	runner.AddHandler("generate-device-key", m.doGenerateDeviceKey, nil)
 	runner.AddHandler("request-serial", m.doRequestSerial, nil)
 	runner.AddHandler("mark-seeded", m.doMarkSeeded, nil)
+	runner.AddHandler("mark-preseeded", m.doMarkPreseeded, nil)
+	runner.AddHandler("create-recovery-system", m.doCreateRecoverySystem, m.undoCreateRecoverySystem)
+	runner.AddHandler("request-system-reset", m.doRequestSystemReset, nil)
 
 	return m, nil
 }
@@ -351,6 +356,22 @@ func (m *DeviceManager) ensureBootOk() error {
 	}
 
 	if !m.bootOkRan {
+		outcome, err := boot.UpdateBootAttempts(maxBootAttempts(m.state))
+		if err != nil {
+			return err
+		}
+		if outcome.Retry {
+			// the new kernel or core snap will be tried again
+			logger.Noticef("boot of new kernel or core snap failed, trying again")
+			m.bootOkRan = true
+			m.bootRevisionsUpdated = true
+			m.state.RequestRestart(state.RestartSystem)
+			return nil
+		}
+		for _, e := range outcome.RolledBack {
+			logger.Noticef("boot of %s snap %s failed %d times, rolled back to %s", e.Kind, e.Snap, e.Attempts, e.Fallback)
+		}
+
 		bootloader, err := partition.FindBootloader()
 		if err != nil {
 			return fmt.Errorf(i18n.G("cannot mark boot successful: %s"), err)
@@ -397,6 +418,15 @@ func (m *DeviceManager) Ensure() error {
 	if err := m.ensureSeedYaml(); err != nil {
 		errs = append(errs, err)
 	}
+	if release.Preseeding {
+		// only seeding happens when preseeding an image, the
+		// rest needs the real device
+		m.runner.Ensure()
+		if len(errs) > 0 {
+			return &ensureError{errs}
+		}
+		return nil
+	}
 	if err := m.ensureOperational(); err != nil {
 		errs = append(errs, err)
 	}
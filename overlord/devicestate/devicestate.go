@@ -23,9 +23,11 @@ package devicestate
 
 import (
 	"fmt"
+	"strconv"
 	"sync"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/boot"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
@@ -126,7 +128,7 @@ func canAutoRefresh(st *state.State) (bool, error) {
 	return true, nil
 }
 
-func checkGadgetOrKernel(st *state.State, snapInfo, curInfo *snap.Info, flags snapstate.Flags) error {
+func checkGadgetOrKernel(st *state.State, snapInfo, curInfo *snap.Info, snapf snap.Container, flags snapstate.Flags) error {
 	kind := ""
 	var currentInfo func(*state.State) (*snap.Info, error)
 	var getName func(*asserts.Model) string
@@ -226,6 +228,26 @@ func ProxyStore(st *state.State) (*asserts.Store, error) {
 	return a.(*asserts.Store), nil
 }
 
+// maxBootAttempts returns the number of times a new kernel or core
+// snap is tried before falling back, as set with core.boot.max-attempts.
+func maxBootAttempts(st *state.State) int {
+	tr := config.NewTransaction(st)
+	var v interface{}
+	if err := tr.GetMaybe("core", "boot.max-attempts", &v); err != nil {
+		logger.Noticef("cannot get boot.max-attempts setting: %v", err)
+		return boot.DefaultMaxBootAttempts
+	}
+	if v == nil {
+		return boot.DefaultMaxBootAttempts
+	}
+	n, err := strconv.Atoi(fmt.Sprintf("%v", v))
+	if err != nil || n < 1 {
+		logger.Noticef("invalid boot.max-attempts setting: %v", v)
+		return boot.DefaultMaxBootAttempts
+	}
+	return n
+}
+
 // interfaceConnected returns true if the given snap/interface names
 // are connected
 func interfaceConnected(st *state.State, snapName, ifName string) bool {
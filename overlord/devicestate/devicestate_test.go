@@ -28,6 +28,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"sort"
 	"sync"
 	"testing"
@@ -40,6 +41,7 @@ import (
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/assertstest"
 	"github.com/snapcore/snapd/asserts/sysdb"
+	"github.com/snapcore/snapd/boot"
 	"github.com/snapcore/snapd/boot/boottest"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/httputil"
@@ -324,7 +326,48 @@ func (s *deviceMgrSuite) findBecomeOperationalChange(skipIDs ...string) *state.C
 func (s *deviceMgrSuite) TestKnownTaskKinds(c *C) {
 	kinds := s.mgr.KnownTaskKinds()
 	sort.Strings(kinds)
-	c.Assert(kinds, DeepEquals, []string{"generate-device-key", "mark-seeded", "request-serial"})
+	c.Assert(kinds, DeepEquals, []string{"create-recovery-system", "generate-device-key", "mark-preseeded", "mark-seeded", "request-serial", "request-system-reset"})
+}
+
+func (s *deviceMgrSuite) TestDoMarkPreseededWhenPreseeding(c *C) {
+	restore := release.MockPreseeding(true)
+	defer restore()
+
+	s.state.Lock()
+	chg := s.state.NewChange("seed", "Seed system")
+	t := s.state.NewTask("mark-preseeded", "...")
+	chg.AddTask(t)
+	s.state.Unlock()
+
+	s.mgr.Ensure()
+	s.mgr.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// the task is held until the first boot of the image
+	c.Check(t.Status(), Equals, state.DoingStatus)
+	c.Check(s.state.Restarting(), Equals, true)
+	var preseeded bool
+	c.Assert(s.state.Get("preseeded", &preseeded), IsNil)
+	c.Check(preseeded, Equals, true)
+}
+
+func (s *deviceMgrSuite) TestDoMarkPreseededOnFirstBoot(c *C) {
+	s.state.Lock()
+	chg := s.state.NewChange("seed", "Seed system")
+	t := s.state.NewTask("mark-preseeded", "...")
+	chg.AddTask(t)
+	s.state.Unlock()
+
+	s.mgr.Ensure()
+	s.mgr.Wait()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(t.Status(), Equals, state.DoneStatus)
+	c.Check(s.state.Restarting(), Equals, false)
 }
 
 func (s *deviceMgrSuite) TestFullDeviceRegistrationHappy(c *C) {
@@ -1096,6 +1139,190 @@ hooks:
 	c.Check(device.KeyID, Equals, privKey.PublicKey().ID())
 }
 
+func (s *deviceMgrSuite) TestDoRequestSerialPreDelivered(c *C) {
+	privKey, _ := assertstest.GenerateKey(testKeyLength)
+	encDevKey, err := asserts.EncodePublicKey(privKey.PublicKey())
+	c.Assert(err, IsNil)
+
+	// no device service is reachable
+	nowhere := "http://nowhere.nowhere.test"
+	restore := devicestate.MockRequestIDURL(nowhere + requestIDURLPath)
+	defer restore()
+	restore = devicestate.MockSerialRequestURL(nowhere + serialURLPath)
+	defer restore()
+
+	// serial assertion delivered via auto-import
+	serial, err := s.storeSigning.Sign(asserts.SerialType, map[string]interface{}{
+		"brand-id":            "canonical",
+		"model":               "pc",
+		"serial":              "F0001",
+		"device-key":          string(encDevKey),
+		"device-key-sha3-384": privKey.PublicKey().ID(),
+		"timestamp":           time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	c.Assert(os.MkdirAll(dirs.SnapAssertsSpoolDir, 0755), IsNil)
+	err = ioutil.WriteFile(filepath.Join(dirs.SnapAssertsSpoolDir, "other.assert"), []byte("garbage"), 0644)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(filepath.Join(dirs.SnapAssertsSpoolDir, "serial.assert"), asserts.Encode(serial), 0644)
+	c.Assert(err, IsNil)
+
+	// setup state as done by first-boot/Ensure/doGenerateDeviceKey
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupGadget(c, `
+name: gadget
+type: gadget
+version: gadget
+`, "")
+
+	auth.SetDevice(s.state, &auth.DeviceState{
+		Brand: "canonical",
+		Model: "pc",
+		KeyID: privKey.PublicKey().ID(),
+	})
+	devicestate.KeypairManager(s.mgr).Put(privKey)
+
+	t := s.state.NewTask("request-serial", "test")
+	chg := s.state.NewChange("become-operational", "...")
+	chg.AddTask(t)
+
+	// avoid full seeding
+	s.seeding()
+
+	s.state.Unlock()
+	s.mgr.Ensure()
+	s.mgr.Wait()
+	s.state.Lock()
+
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+
+	device, err := auth.Device(s.state)
+	c.Assert(err, IsNil)
+	c.Check(device.Serial, Equals, "F0001")
+
+	_, err = s.db.Find(asserts.SerialType, map[string]string{
+		"brand-id": "canonical",
+		"model":    "pc",
+		"serial":   "F0001",
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *deviceMgrSuite) TestFullDeviceRegistrationOffline(c *C) {
+	r1 := devicestate.MockKeyLength(testKeyLength)
+	defer r1()
+
+	// the local registration service
+	s.reqID = "REQID-1"
+	mockServer := s.mockServer(c)
+	defer mockServer.Close()
+
+	// immediately
+	r2 := devicestate.MockRetryInterval(0)
+	defer r2()
+
+	r3 := hookstate.MockRunHook(func(ctx *hookstate.Context, _ *tomb.Tomb) ([]byte, error) {
+		c.Assert(ctx.HookName(), Equals, "prepare-device")
+
+		_, _, err := ctlcmd.Run(ctx, []string{"set", "registration.offline=true"})
+		c.Assert(err, IsNil)
+		_, _, err = ctlcmd.Run(ctx, []string{"set", fmt.Sprintf("registration.proposed-serial=%q", "OFF1")})
+		c.Assert(err, IsNil)
+		return nil, nil
+	})
+	defer r3()
+
+	// setup state as will be done by first-boot
+	// & have a gadget with a prepare-device hook
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.makeModelAssertionInState(c, "canonical", "pc2", map[string]string{
+		"architecture": "amd64",
+		"kernel":       "pc-kernel",
+		"gadget":       "gadget",
+	})
+
+	s.setupGadget(c, `
+name: gadget
+type: gadget
+version: gadget
+hooks:
+    prepare-device:
+`, "")
+
+	auth.SetDevice(s.state, &auth.DeviceState{
+		Brand: "canonical",
+		Model: "pc2",
+	})
+
+	// avoid full seeding
+	s.seeding()
+
+	s.state.Unlock()
+	s.settle(c)
+	s.state.Lock()
+
+	becomeOperational := s.findBecomeOperationalChange()
+	c.Assert(becomeOperational, NotNil)
+	c.Check(becomeOperational.Status().Ready(), Equals, false)
+
+	// the serial-request is left for out of band processing
+	serialRequestFile := filepath.Join(dirs.SnapDeviceDir, "serial-request")
+	b, err := ioutil.ReadFile(serialRequestFile)
+	c.Assert(err, IsNil)
+	a, err := asserts.Decode(b)
+	c.Assert(err, IsNil)
+	serialReq := a.(*asserts.SerialRequest)
+	c.Check(serialReq.RequestID(), Equals, "offline")
+	c.Check(serialReq.Serial(), Equals, "OFF1")
+
+	// nothing changes until the serial is delivered
+	s.state.Unlock()
+	s.settle(c)
+	s.state.Lock()
+	c.Check(becomeOperational.Status().Ready(), Equals, false)
+	b1, err := ioutil.ReadFile(serialRequestFile)
+	c.Assert(err, IsNil)
+	c.Check(b1, DeepEquals, b)
+
+	// the serial-request is processed by the local service and
+	// the serial is delivered via auto-import
+	req, err := http.NewRequest("POST", mockServer.URL+serialURLPath, bytes.NewReader(b))
+	c.Assert(err, IsNil)
+	req.Header.Set("User-Agent", httputil.UserAgent())
+	resp, err := http.DefaultClient.Do(req)
+	c.Assert(err, IsNil)
+	defer resp.Body.Close()
+	c.Assert(resp.StatusCode, Equals, 200)
+	serialData, err := ioutil.ReadAll(resp.Body)
+	c.Assert(err, IsNil)
+	c.Assert(os.MkdirAll(dirs.SnapAssertsSpoolDir, 0755), IsNil)
+	err = ioutil.WriteFile(filepath.Join(dirs.SnapAssertsSpoolDir, "serial.assert"), serialData, 0644)
+	c.Assert(err, IsNil)
+
+	s.state.Unlock()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Check(becomeOperational.Status().Ready(), Equals, true)
+	c.Check(becomeOperational.Err(), IsNil)
+
+	device, err := auth.Device(s.state)
+	c.Assert(err, IsNil)
+	c.Check(device.Serial, Equals, "OFF1")
+
+	a, err = s.db.Find(asserts.SerialType, map[string]string{
+		"brand-id": "canonical",
+		"model":    "pc2",
+		"serial":   "OFF1",
+	})
+	c.Assert(err, IsNil)
+	c.Check(a.(*asserts.Serial).DeviceKey().ID(), Equals, device.KeyID)
+}
+
 func (s *deviceMgrSuite) TestFullDeviceRegistrationErrorBackoff(c *C) {
 	r1 := devicestate.MockKeyLength(testKeyLength)
 	defer r1()
@@ -1593,6 +1820,50 @@ func (s *deviceMgrSuite) TestDeviceManagerEnsureBootOkUpdateBootRevisionsHappy(c
 
 	c.Check(s.state.Changes(), HasLen, 1)
 	c.Check(s.state.Changes()[0].Kind(), Equals, "update-revisions")
+	c.Check(s.state.Changes()[0].Summary(), Equals, "Boot rolled back from core rev 2 to rev 1")
+	c.Check(s.bootloader.BootVars["snap_try_core"], Equals, "")
+
+	history, err := boot.History()
+	c.Assert(err, IsNil)
+	c.Assert(history, HasLen, 1)
+	c.Check(history[0].Result, Equals, boot.BootRollback)
+	c.Check(history[0].Snap, Equals, "core_2.snap")
+}
+
+func (s *deviceMgrSuite) TestDeviceManagerEnsureBootOkRetriesBoot(c *C) {
+	// simulate that we have a new core_2, tried to boot it but that failed
+	s.bootloader.SetBootVars(map[string]string{
+		"snap_mode":     "",
+		"snap_try_core": "core_2.snap",
+		"snap_core":     "core_1.snap",
+	})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "boot.max-attempts", 2)
+	tr.Commit()
+
+	siCore1 := &snap.SideInfo{RealName: "core", Revision: snap.R(1)}
+	siCore2 := &snap.SideInfo{RealName: "core", Revision: snap.R(2)}
+	snapstate.Set(s.state, "core", &snapstate.SnapState{
+		SnapType: "os",
+		Active:   true,
+		Sequence: []*snap.SideInfo{siCore1, siCore2},
+		Current:  siCore2.Revision,
+	})
+
+	s.state.Unlock()
+	err := devicestate.EnsureBootOk(s.mgr)
+	s.state.Lock()
+	c.Assert(err, IsNil)
+
+	// core_2 is tried once more
+	c.Check(s.state.Changes(), HasLen, 0)
+	c.Check(s.state.Restarting(), Equals, true)
+	c.Check(s.bootloader.BootVars["snap_mode"], Equals, "try")
+	c.Check(s.bootloader.BootVars["snap_try_core"], Equals, "core_2.snap")
+	c.Check(s.bootloader.BootVars["snap_boot_attempts"], Equals, "1")
 }
 
 func (s *deviceMgrSuite) TestDeviceManagerEnsureBootOkNotRunAgain(c *C) {
@@ -1666,7 +1937,7 @@ func (s *deviceMgrSuite) TestCheckGadget(c *C) {
 	// nothing is setup
 	gadgetInfo := snaptest.MockInfo(c, "{type: gadget, name: other-gadget, version: 0}", nil)
 
-	err := devicestate.CheckGadgetOrKernel(s.state, gadgetInfo, nil, snapstate.Flags{})
+	err := devicestate.CheckGadgetOrKernel(s.state, gadgetInfo, nil, nil, snapstate.Flags{})
 	c.Check(err, ErrorMatches, `cannot install gadget without model assertion`)
 
 	// setup model assertion
@@ -1690,7 +1961,7 @@ func (s *deviceMgrSuite) TestCheckGadget(c *C) {
 	})
 	c.Assert(err, IsNil)
 
-	err = devicestate.CheckGadgetOrKernel(s.state, gadgetInfo, nil, snapstate.Flags{})
+	err = devicestate.CheckGadgetOrKernel(s.state, gadgetInfo, nil, nil, snapstate.Flags{})
 	c.Check(err, ErrorMatches, `cannot install gadget "other-gadget", model assertion requests "gadget"`)
 
 	// brand gadget
@@ -1709,20 +1980,20 @@ func (s *deviceMgrSuite) TestCheckGadget(c *C) {
 	otherGadgetInfo.SnapID = "other-gadget-id"
 
 	// install brand gadget ok
-	err = devicestate.CheckGadgetOrKernel(s.state, brandGadgetInfo, nil, snapstate.Flags{})
+	err = devicestate.CheckGadgetOrKernel(s.state, brandGadgetInfo, nil, nil, snapstate.Flags{})
 	c.Check(err, IsNil)
 
 	// install canonical gadget ok
-	err = devicestate.CheckGadgetOrKernel(s.state, canonicalGadgetInfo, nil, snapstate.Flags{})
+	err = devicestate.CheckGadgetOrKernel(s.state, canonicalGadgetInfo, nil, nil, snapstate.Flags{})
 	c.Check(err, IsNil)
 
 	// install other gadget fails
-	err = devicestate.CheckGadgetOrKernel(s.state, otherGadgetInfo, nil, snapstate.Flags{})
+	err = devicestate.CheckGadgetOrKernel(s.state, otherGadgetInfo, nil, nil, snapstate.Flags{})
 	c.Check(err, ErrorMatches, `cannot install gadget "gadget" published by "other-brand" for model by "my-brand"`)
 
 	// unasserted installation of other works
 	otherGadgetInfo.SnapID = ""
-	err = devicestate.CheckGadgetOrKernel(s.state, otherGadgetInfo, nil, snapstate.Flags{})
+	err = devicestate.CheckGadgetOrKernel(s.state, otherGadgetInfo, nil, nil, snapstate.Flags{})
 	c.Check(err, IsNil)
 }
 
@@ -1754,7 +2025,7 @@ func (s *deviceMgrSuite) TestCheckGadgetOnClassic(c *C) {
 	})
 	c.Assert(err, IsNil)
 
-	err = devicestate.CheckGadgetOrKernel(s.state, gadgetInfo, nil, snapstate.Flags{})
+	err = devicestate.CheckGadgetOrKernel(s.state, gadgetInfo, nil, nil, snapstate.Flags{})
 	c.Check(err, ErrorMatches, `cannot install gadget "other-gadget", model assertion requests "gadget"`)
 
 	// brand gadget
@@ -1773,20 +2044,20 @@ func (s *deviceMgrSuite) TestCheckGadgetOnClassic(c *C) {
 	otherGadgetInfo.SnapID = "other-gadget-id"
 
 	// install brand gadget ok
-	err = devicestate.CheckGadgetOrKernel(s.state, brandGadgetInfo, nil, snapstate.Flags{})
+	err = devicestate.CheckGadgetOrKernel(s.state, brandGadgetInfo, nil, nil, snapstate.Flags{})
 	c.Check(err, IsNil)
 
 	// install canonical gadget ok
-	err = devicestate.CheckGadgetOrKernel(s.state, canonicalGadgetInfo, nil, snapstate.Flags{})
+	err = devicestate.CheckGadgetOrKernel(s.state, canonicalGadgetInfo, nil, nil, snapstate.Flags{})
 	c.Check(err, IsNil)
 
 	// install other gadget fails
-	err = devicestate.CheckGadgetOrKernel(s.state, otherGadgetInfo, nil, snapstate.Flags{})
+	err = devicestate.CheckGadgetOrKernel(s.state, otherGadgetInfo, nil, nil, snapstate.Flags{})
 	c.Check(err, ErrorMatches, `cannot install gadget "gadget" published by "other-brand" for model by "my-brand"`)
 
 	// unasserted installation of other works
 	otherGadgetInfo.SnapID = ""
-	err = devicestate.CheckGadgetOrKernel(s.state, otherGadgetInfo, nil, snapstate.Flags{})
+	err = devicestate.CheckGadgetOrKernel(s.state, otherGadgetInfo, nil, nil, snapstate.Flags{})
 	c.Check(err, IsNil)
 }
 
@@ -1817,7 +2088,7 @@ func (s *deviceMgrSuite) TestCheckGadgetOnClassicGadgetNotSpecified(c *C) {
 	})
 	c.Assert(err, IsNil)
 
-	err = devicestate.CheckGadgetOrKernel(s.state, gadgetInfo, nil, snapstate.Flags{})
+	err = devicestate.CheckGadgetOrKernel(s.state, gadgetInfo, nil, nil, snapstate.Flags{})
 	c.Check(err, ErrorMatches, `cannot install gadget snap on classic if not requested by the model`)
 }
 
@@ -1828,12 +2099,12 @@ func (s *deviceMgrSuite) TestCheckKernel(c *C) {
 
 	// not on classic
 	release.OnClassic = true
-	err := devicestate.CheckGadgetOrKernel(s.state, kernelInfo, nil, snapstate.Flags{})
+	err := devicestate.CheckGadgetOrKernel(s.state, kernelInfo, nil, nil, snapstate.Flags{})
 	c.Check(err, ErrorMatches, `cannot install a kernel snap on classic`)
 	release.OnClassic = false
 
 	// nothing is setup
-	err = devicestate.CheckGadgetOrKernel(s.state, kernelInfo, nil, snapstate.Flags{})
+	err = devicestate.CheckGadgetOrKernel(s.state, kernelInfo, nil, nil, snapstate.Flags{})
 	c.Check(err, ErrorMatches, `cannot install kernel without model assertion`)
 
 	// setup model assertion
@@ -1857,7 +2128,7 @@ func (s *deviceMgrSuite) TestCheckKernel(c *C) {
 	})
 	c.Assert(err, IsNil)
 
-	err = devicestate.CheckGadgetOrKernel(s.state, kernelInfo, nil, snapstate.Flags{})
+	err = devicestate.CheckGadgetOrKernel(s.state, kernelInfo, nil, nil, snapstate.Flags{})
 	c.Check(err, ErrorMatches, `cannot install kernel "lnrk", model assertion requests "krnl"`)
 
 	// brand kernel
@@ -1876,20 +2147,20 @@ func (s *deviceMgrSuite) TestCheckKernel(c *C) {
 	otherKrnlInfo.SnapID = "other-krnl-id"
 
 	// install brand kernel ok
-	err = devicestate.CheckGadgetOrKernel(s.state, brandKrnlInfo, nil, snapstate.Flags{})
+	err = devicestate.CheckGadgetOrKernel(s.state, brandKrnlInfo, nil, nil, snapstate.Flags{})
 	c.Check(err, IsNil)
 
 	// install canonical kernel ok
-	err = devicestate.CheckGadgetOrKernel(s.state, canonicalKrnlInfo, nil, snapstate.Flags{})
+	err = devicestate.CheckGadgetOrKernel(s.state, canonicalKrnlInfo, nil, nil, snapstate.Flags{})
 	c.Check(err, IsNil)
 
 	// install other kernel fails
-	err = devicestate.CheckGadgetOrKernel(s.state, otherKrnlInfo, nil, snapstate.Flags{})
+	err = devicestate.CheckGadgetOrKernel(s.state, otherKrnlInfo, nil, nil, snapstate.Flags{})
 	c.Check(err, ErrorMatches, `cannot install kernel "krnl" published by "other-brand" for model by "my-brand"`)
 
 	// unasserted installation of other works
 	otherKrnlInfo.SnapID = ""
-	err = devicestate.CheckGadgetOrKernel(s.state, otherKrnlInfo, nil, snapstate.Flags{})
+	err = devicestate.CheckGadgetOrKernel(s.state, otherKrnlInfo, nil, nil, snapstate.Flags{})
 	c.Check(err, IsNil)
 }
 
@@ -20,9 +20,12 @@
 package devicestate
 
 import (
+	"os/user"
 	"time"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/state"
 )
 
@@ -108,6 +111,32 @@ func MockPopulateStateFromSeed(f func(*state.State) ([]*state.TaskSet, error)) (
 	}
 }
 
+func MockResetSecurityBackends(be []interfaces.SecurityBackend) (restore func()) {
+	old := resetSecurityBackends
+	resetSecurityBackends = be
+	return func() {
+		resetSecurityBackends = old
+	}
+}
+
+func MockUserLookup(f func(name string) (*user.User, error)) (restore func()) {
+	old := userLookup
+	userLookup = f
+	return func() {
+		userLookup = old
+	}
+}
+
+func MockOsutilDelUser(f func(name string, opts *osutil.DelUserOptions) error) (restore func()) {
+	old := osutilDelUser
+	osutilDelUser = f
+	return func() {
+		osutilDelUser = old
+	}
+}
+
+var ResetStateFile = resetStateFile
+
 func EnsureBootOk(m *DeviceManager) error {
 	return m.ensureBootOk()
 }
@@ -28,7 +28,6 @@ import (
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/snapasserts"
-	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/assertstate"
@@ -41,7 +40,7 @@ import (
 
 var errNothingToDo = errors.New("nothing to do")
 
-func installSeedSnap(st *state.State, sn *snap.SeedSnap, flags snapstate.Flags) (*state.TaskSet, error) {
+func installSeedSnap(st *state.State, seedDir string, sn *snap.SeedSnap, flags snapstate.Flags) (*state.TaskSet, error) {
 	if sn.Classic {
 		flags.Classic = true
 	}
@@ -49,7 +48,7 @@ func installSeedSnap(st *state.State, sn *snap.SeedSnap, flags snapstate.Flags)
 		flags.DevMode = true
 	}
 
-	path := filepath.Join(dirs.SnapSeedDir, "snaps", sn.File)
+	path := filepath.Join(seedDir, "snaps", sn.File)
 
 	var sideInfo snap.SideInfo
 	if sn.Unasserted {
@@ -74,7 +73,84 @@ func trivialSeeding(st *state.State, markSeeded *state.Task) []*state.TaskSet {
 	// give the internal core config a chance to run
 	configTs := snapstate.ConfigureSnap(st, "core", 0)
 	markSeeded.WaitAll(configTs)
-	return []*state.TaskSet{configTs, state.NewTaskSet(markSeeded)}
+	return withPreseeding(st, []*state.TaskSet{configTs, state.NewTaskSet(markSeeded)})
+}
+
+// preseedDeviceTaskKinds are the kinds of tasks that need the real
+// device and so cannot run while preseeding an image; any task
+// following one of them in a task set is held back as well.
+var preseedDeviceTaskKinds = map[string]bool{
+	"run-hook":            true,
+	"start-snap-services": true,
+	"mark-seeded":         true,
+}
+
+// splitForPreseed splits the tasks of ts into the ones that can run
+// while preseeding an image and the ones that need the real device.
+func splitForPreseed(ts *state.TaskSet) (fs, device []*state.Task) {
+	tasks := ts.Tasks()
+	for i, t := range tasks {
+		if preseedDeviceTaskKinds[t.Kind()] {
+			return tasks[:i], tasks[i:]
+		}
+	}
+	return tasks, nil
+}
+
+// seedChain chains the task sets installing the seed snaps one after
+// the other. When preseeding, only the tasks needing the real device
+// wait for the device tasks of the previous task sets, so that all
+// the other tasks can run ahead of them.
+type seedChain struct {
+	preseed bool
+	lastFs  []*state.Task
+}
+
+func (sc *seedChain) chain(ts, prev *state.TaskSet) {
+	if !sc.preseed {
+		ts.WaitAll(prev)
+		return
+	}
+	fs, device := splitForPreseed(ts)
+	for _, t := range fs {
+		for _, w := range sc.lastFs {
+			t.WaitFor(w)
+		}
+	}
+	for _, t := range device {
+		t.WaitAll(prev)
+	}
+	sc.add(ts)
+}
+
+func (sc *seedChain) add(ts *state.TaskSet) {
+	if !sc.preseed {
+		return
+	}
+	if fs, _ := splitForPreseed(ts); len(fs) > 0 {
+		sc.lastFs = fs
+	}
+}
+
+// withPreseeding adds a mark-preseeded task to the seeding task sets
+// when preseeding an image: it waits for all the tasks that can run
+// without the real device, and all the others wait for it.
+func withPreseeding(st *state.State, tsAll []*state.TaskSet) []*state.TaskSet {
+	if !release.Preseeding {
+		return tsAll
+	}
+	markPreseeded := st.NewTask("mark-preseeded", i18n.G("Mark system preseeded"))
+	for _, ts := range tsAll {
+		fs, device := splitForPreseed(ts)
+		for _, t := range fs {
+			markPreseeded.WaitFor(t)
+		}
+		for _, t := range device {
+			t.WaitFor(markPreseeded)
+		}
+	}
+	n := len(tsAll) - 1
+	return append(tsAll[:n:n], state.NewTaskSet(markPreseeded), tsAll[n])
 }
 
 func populateStateFromSeedImpl(st *state.State) ([]*state.TaskSet, error) {
@@ -99,7 +175,11 @@ func populateStateFromSeedImpl(st *state.State) ([]*state.TaskSet, error) {
 		return nil, err
 	}
 
-	seedYamlFile := filepath.Join(dirs.SnapSeedDir, "seed.yaml")
+	seedDir, err := currentSeedDir(st)
+	if err != nil {
+		return nil, err
+	}
+	seedYamlFile := filepath.Join(seedDir, "seed.yaml")
 	if release.OnClassic && !osutil.FileExists(seedYamlFile) {
 		// on classic it is ok to not seed any snaps
 		return trivialSeeding(st, markSeeded), nil
@@ -127,6 +207,7 @@ func populateStateFromSeedImpl(st *state.State) ([]*state.TaskSet, error) {
 
 	tsAll := []*state.TaskSet{}
 	configTss := []*state.TaskSet{}
+	sc := &seedChain{preseed: release.Preseeding}
 
 	// if there are snaps to seed, core needs to be seeded too
 	if len(seed.Snaps) != 0 {
@@ -134,11 +215,12 @@ func populateStateFromSeedImpl(st *state.State) ([]*state.TaskSet, error) {
 		if coreSeed == nil {
 			return nil, fmt.Errorf("cannot proceed without seeding core")
 		}
-		ts, err := installSeedSnap(st, coreSeed, snapstate.Flags{SkipConfigure: true})
+		ts, err := installSeedSnap(st, seedDir, coreSeed, snapstate.Flags{SkipConfigure: true})
 		if err != nil {
 			return nil, err
 		}
 		tsAll = append(tsAll, ts)
+		sc.add(ts)
 		alreadySeeded["core"] = true
 		configTss = append(configTss, snapstate.ConfigureSnap(st, "core", snapstate.UseConfigDefaults))
 	}
@@ -149,11 +231,11 @@ func populateStateFromSeedImpl(st *state.State) ([]*state.TaskSet, error) {
 		if kernelSeed == nil {
 			return nil, fmt.Errorf("cannot find seed information for kernel snap %q", kernelName)
 		}
-		ts, err := installSeedSnap(st, kernelSeed, snapstate.Flags{SkipConfigure: true})
+		ts, err := installSeedSnap(st, seedDir, kernelSeed, snapstate.Flags{SkipConfigure: true})
 		if err != nil {
 			return nil, err
 		}
-		ts.WaitAll(tsAll[last])
+		sc.chain(ts, tsAll[last])
 		tsAll = append(tsAll, ts)
 		alreadySeeded[kernelName] = true
 		configTs := snapstate.ConfigureSnap(st, kernelName, snapstate.UseConfigDefaults)
@@ -167,11 +249,11 @@ func populateStateFromSeedImpl(st *state.State) ([]*state.TaskSet, error) {
 		if gadgetSeed == nil {
 			return nil, fmt.Errorf("cannot find seed information for gadget snap %q", gadgetName)
 		}
-		ts, err := installSeedSnap(st, gadgetSeed, snapstate.Flags{SkipConfigure: true})
+		ts, err := installSeedSnap(st, seedDir, gadgetSeed, snapstate.Flags{SkipConfigure: true})
 		if err != nil {
 			return nil, err
 		}
-		ts.WaitAll(tsAll[last])
+		sc.chain(ts, tsAll[last])
 		tsAll = append(tsAll, ts)
 		alreadySeeded[gadgetName] = true
 		configTs := snapstate.ConfigureSnap(st, gadgetName, snapstate.UseConfigDefaults)
@@ -196,12 +278,12 @@ func populateStateFromSeedImpl(st *state.State) ([]*state.TaskSet, error) {
 			flags.Required = true
 		}
 
-		ts, err := installSeedSnap(st, sn, flags)
+		ts, err := installSeedSnap(st, seedDir, sn, flags)
 		if err != nil {
 			return nil, err
 		}
 
-		ts.WaitAll(tsAll[last])
+		sc.chain(ts, tsAll[last])
 		tsAll = append(tsAll, ts)
 		last++
 	}
@@ -214,7 +296,7 @@ func populateStateFromSeedImpl(st *state.State) ([]*state.TaskSet, error) {
 	markSeeded.WaitAll(ts)
 	tsAll = append(tsAll, state.NewTaskSet(markSeeded))
 
-	return tsAll, nil
+	return withPreseeding(st, tsAll), nil
 }
 
 func readAsserts(fn string, batch *assertstate.Batch) ([]*asserts.Ref, error) {
@@ -232,8 +314,13 @@ func importAssertionsFromSeed(st *state.State) (*asserts.Model, error) {
 		return nil, err
 	}
 
+	seedDir, err := currentSeedDir(st)
+	if err != nil {
+		return nil, err
+	}
+
 	// set device,model from the model assertion
-	assertSeedDir := filepath.Join(dirs.SnapSeedDir, "assertions")
+	assertSeedDir := filepath.Join(seedDir, "assertions")
 	dc, err := ioutil.ReadDir(assertSeedDir)
 	if release.OnClassic && os.IsNotExist(err) {
 		// on classic seeding is optional
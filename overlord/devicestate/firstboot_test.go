@@ -535,6 +535,65 @@ func (s *FirstBootTestSuite) TestPopulateFromSeedHappy(c *C) {
 	c.Check(seedTime.IsZero(), Equals, false)
 }
 
+func (s *FirstBootTestSuite) TestPopulateFromSeedPreseeding(c *C) {
+	bootloader := boottest.NewMockBootloader("mock", c.MkDir())
+	partition.ForceBootloader(bootloader)
+	defer partition.ForceBootloader(nil)
+	bootloader.SetBootVars(map[string]string{
+		"snap_core":   "core_1.snap",
+		"snap_kernel": "pc-kernel_1.snap",
+	})
+
+	restore := release.MockPreseeding(true)
+	defer restore()
+	// snaps get mounted directly when preseeding
+	mount := testutil.MockCommand(c, "mount", "")
+	defer mount.Restore()
+
+	st := s.overlord.State()
+	chg := s.makeBecomeOperationalChange(c, st)
+	err := s.overlord.Settle(settleTimeout)
+	c.Assert(err, IsNil)
+
+	st.Lock()
+	c.Assert(chg.Err(), IsNil)
+	c.Check(chg.Status(), Equals, state.DoingStatus)
+
+	var markPreseeded *state.Task
+	for _, t := range chg.Tasks() {
+		switch t.Kind() {
+		case "mark-preseeded":
+			markPreseeded = t
+			c.Check(t.Status(), Equals, state.DoingStatus)
+		case "link-snap", "mount-snap", "setup-profiles":
+			c.Check(t.Status(), Equals, state.DoneStatus, Commentf("%s", t.Summary()))
+		case "run-hook", "start-snap-services", "mark-seeded":
+			c.Check(t.Status(), Equals, state.DoStatus, Commentf("%s", t.Summary()))
+		}
+	}
+	c.Assert(markPreseeded, NotNil)
+	var preseeded bool
+	c.Assert(st.Get("preseeded", &preseeded), IsNil)
+	c.Check(preseeded, Equals, true)
+	c.Check(mount.Calls(), Not(HasLen), 0)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapMountDir, "foo", "128", "meta", "snap.yaml")), Equals, true)
+	st.Unlock()
+
+	// first boot of the preseeded image
+	restore()
+	err = s.overlord.Settle(settleTimeout)
+	c.Assert(err, IsNil)
+
+	st.Lock()
+	defer st.Unlock()
+	c.Assert(chg.Err(), IsNil)
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+
+	var seeded bool
+	c.Assert(st.Get("seeded", &seeded), IsNil)
+	c.Check(seeded, Equals, true)
+}
+
 func (s *FirstBootTestSuite) TestPopulateFromSeedMissingBootloader(c *C) {
 	st0 := s.overlord.State()
 	st0.Lock()
@@ -994,6 +1053,32 @@ func (s *FirstBootTestSuite) TestImportAssertionsFromSeedHappy(c *C) {
 	c.Check(model.Model(), Equals, "my-model")
 }
 
+func (s *FirstBootTestSuite) TestImportAssertionsFromRecoverySystem(c *C) {
+	st := s.overlord.State()
+
+	// the original seed has no assertions, the recovery system does
+	systemAssertsDir := filepath.Join(dirs.SnapSeedSystemsDir, "1234", "assertions")
+	err := os.MkdirAll(systemAssertsDir, 0755)
+	c.Assert(err, IsNil)
+	assertsChain := s.makeModelAssertionChain(c, "my-model")
+	for i, as := range assertsChain {
+		fn := filepath.Join(systemAssertsDir, strconv.Itoa(i))
+		err := ioutil.WriteFile(fn, asserts.Encode(as), 0644)
+		c.Assert(err, IsNil)
+	}
+
+	st.Lock()
+	defer st.Unlock()
+
+	_, err = devicestate.ImportAssertionsFromSeed(st)
+	c.Assert(err, ErrorMatches, "need a model assertion")
+
+	st.Set("seed-system", "1234")
+	model, err := devicestate.ImportAssertionsFromSeed(st)
+	c.Assert(err, IsNil)
+	c.Check(model.Model(), Equals, "my-model")
+}
+
 func (s *FirstBootTestSuite) TestImportAssertionsFromSeedMissingSig(c *C) {
 	st := s.overlord.State()
 	st.Lock()
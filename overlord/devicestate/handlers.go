@@ -23,21 +23,28 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
 	"time"
 
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/httputil"
+	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/release"
 )
 
 func (m *DeviceManager) doMarkSeeded(t *state.Task, _ *tomb.Tomb) error {
@@ -50,6 +57,22 @@ func (m *DeviceManager) doMarkSeeded(t *state.Task, _ *tomb.Tomb) error {
 	return nil
 }
 
+func (m *DeviceManager) doMarkPreseeded(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	if release.Preseeding {
+		// everything not needing the real device is done, stop
+		// here and resume from this point on first boot
+		st.Set("preseeded", true)
+		st.RequestRestart(state.RestartDaemon)
+		return &state.Retry{}
+	}
+
+	return nil
+}
+
 func useStaging() bool {
 	return osutil.GetenvBool("SNAPPY_USE_STAGING_STORE")
 }
@@ -196,6 +219,10 @@ func prepareSerialRequest(t *state.Task, privKey asserts.PrivateKey, device *aut
 		return "", retryErr(t, nTentatives, "cannot read response with request-id for making a request for a serial: %v", err)
 	}
 
+	return signSerialRequest(privKey, device, requestID.RequestID, cfg)
+}
+
+func signSerialRequest(privKey asserts.PrivateKey, device *auth.DeviceState, requestID string, cfg *serialRequestConfig) (string, error) {
 	encodedPubKey, err := asserts.EncodePublicKey(privKey.PublicKey())
 	if err != nil {
 		return "", fmt.Errorf("internal error: cannot encode device public key: %v", err)
@@ -205,7 +232,7 @@ func prepareSerialRequest(t *state.Task, privKey asserts.PrivateKey, device *aut
 	headers := map[string]interface{}{
 		"brand-id":   device.Brand,
 		"model":      device.Model,
-		"request-id": requestID.RequestID,
+		"request-id": requestID,
 		"device-key": string(encodedPubKey),
 	}
 	if cfg.proposedSerial != "" {
@@ -326,12 +353,112 @@ func getSerial(t *state.Task, privKey asserts.PrivateKey, device *auth.DeviceSta
 	return serial, nil
 }
 
+// offlineRequestID is used as request-id of serial requests that are
+// processed out of band, without a device service issuing request-ids.
+const offlineRequestID = "offline"
+
+// prepareOfflineSerialRequest makes the serial-request available in
+// SnapDeviceDir for a local registration service to process out of band,
+// the obtained serial assertion is then expected to be delivered back
+// through the auto-import mechanism.
+func prepareOfflineSerialRequest(t *state.Task, privKey asserts.PrivateKey, device *auth.DeviceState, cfg *serialRequestConfig) error {
+	var serialSup serialSetup
+	err := t.Get("serial-setup", &serialSup)
+	if err != nil && err != state.ErrNoState {
+		return err
+	}
+
+	if serialSup.SerialRequest == "" {
+		serialRequest, err := signSerialRequest(privKey, device, offlineRequestID, cfg)
+		if err != nil {
+			return err
+		}
+		serialSup.SerialRequest = serialRequest
+		t.Set("serial-setup", serialSup)
+		t.Logf("Waiting for a serial assertion for the serial request in %s", offlineSerialRequestFile())
+	}
+
+	if err := os.MkdirAll(dirs.SnapDeviceDir, 0755); err != nil {
+		return err
+	}
+	return osutil.AtomicWriteFile(offlineSerialRequestFile(), []byte(serialSup.SerialRequest), 0600, 0)
+}
+
+func offlineSerialRequestFile() string {
+	return filepath.Join(dirs.SnapDeviceDir, "serial-request")
+}
+
+// preDeliveredSerial looks for a serial assertion for the device key
+// that was delivered out of band, e.g. from a USB stick via snap
+// auto-import, and adds it together with its prerequisites to the
+// system assertion database. Serial assertions shipped in the seed
+// are imported with the other seed assertions and are found in the
+// database directly.
+func preDeliveredSerial(st *state.State, device *auth.DeviceState, keyID string) (*asserts.Serial, error) {
+	fis, err := ioutil.ReadDir(dirs.SnapAssertsSpoolDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fi := range fis {
+		fn := filepath.Join(dirs.SnapAssertsSpoolDir, fi.Name())
+		serial, err := readPreDeliveredSerial(st, fn, device, keyID)
+		if err != nil {
+			logger.Noticef("cannot use serial assertion from %q: %v", fn, err)
+			continue
+		}
+		if serial != nil {
+			return serial, nil
+		}
+	}
+	return nil, nil
+}
+
+func readPreDeliveredSerial(st *state.State, fn string, device *auth.DeviceState, keyID string) (*asserts.Serial, error) {
+	data, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	var serial *asserts.Serial
+	dec := asserts.NewDecoder(bytes.NewReader(data))
+	for {
+		a, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := a.(*asserts.Serial); ok && s.BrandID() == device.Brand && s.Model() == device.Model && s.DeviceKey().ID() == keyID {
+			serial = s
+			break
+		}
+	}
+	if serial == nil {
+		return nil, nil
+	}
+
+	batch := assertstate.NewBatch()
+	if _, err := batch.AddStream(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	if err := batch.Commit(st); err != nil {
+		return nil, err
+	}
+	return serial, nil
+}
+
 type serialRequestConfig struct {
 	requestIDURL     string
 	serialRequestURL string
 	headers          map[string]string
 	proposedSerial   string
 	body             []byte
+	offline          bool
 }
 
 func (cfg *serialRequestConfig) applyHeaders(req *http.Request) {
@@ -364,6 +491,21 @@ func getSerialRequestConfig(t *state.Task) (*serialRequestConfig, error) {
 		if err != nil {
 			return nil, err
 		}
+
+		var offline bool
+		err = tr.GetMaybe(gadgetName, "registration.offline", &offline)
+		if err != nil {
+			return nil, err
+		}
+		if offline {
+			cfg := serialRequestConfig{
+				offline: true,
+			}
+			if err := getRegistrationConfig(tr, gadgetName, &cfg); err != nil {
+				return nil, err
+			}
+			return &cfg, nil
+		}
 	}
 
 	if svcURL == "" {
@@ -396,28 +538,35 @@ func getSerialRequestConfig(t *state.Task) (*serialRequestConfig, error) {
 	}
 	cfg.requestIDURL = reqIDURL.String()
 
-	var bodyStr string
-	err = tr.GetMaybe(gadgetName, "registration.body", &bodyStr)
+	serialURL, err := baseURL.Parse("serial")
 	if err != nil {
+		return nil, fmt.Errorf("cannot build /serial URL from %v: %v", baseURL, err)
+	}
+	cfg.serialRequestURL = serialURL.String()
+
+	if err := getRegistrationConfig(tr, gadgetName, &cfg); err != nil {
 		return nil, err
 	}
 
-	cfg.body = []byte(bodyStr)
+	return &cfg, nil
+}
 
-	serialURL, err := baseURL.Parse("serial")
+func getRegistrationConfig(tr *config.Transaction, gadgetName string, cfg *serialRequestConfig) error {
+	var bodyStr string
+	err := tr.GetMaybe(gadgetName, "registration.body", &bodyStr)
 	if err != nil {
-		return nil, fmt.Errorf("cannot build /serial URL from %v: %v", baseURL, err)
+		return err
 	}
-	cfg.serialRequestURL = serialURL.String()
+	cfg.body = []byte(bodyStr)
 
 	var proposedSerial string
 	err = tr.GetMaybe(gadgetName, "registration.proposed-serial", &proposedSerial)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	cfg.proposedSerial = proposedSerial
 
-	return &cfg, nil
+	return nil
 }
 
 func (m *DeviceManager) finishRegistration(t *state.Task, device *auth.DeviceState, serial *asserts.Serial) error {
@@ -473,7 +622,23 @@ func (m *DeviceManager) doRequestSerial(t *state.Task, _ *tomb.Tomb) error {
 		return fmt.Errorf("internal error: multiple serial assertions for the same device key")
 	}
 
-	serial, err := getSerial(t, privKey, device, cfg)
+	serial, err := preDeliveredSerial(st, device, privKey.PublicKey().ID())
+	if err != nil {
+		return err
+	}
+	if serial != nil {
+		return m.finishRegistration(t, device, serial)
+	}
+
+	if cfg.offline {
+		if err := prepareOfflineSerialRequest(t, privKey, device, cfg); err != nil {
+			return err
+		}
+		// poll for the serial to be delivered
+		return &state.Retry{After: retryInterval}
+	}
+
+	serial, err = getSerial(t, privKey, device, cfg)
 	if err == errPoll {
 		t.Logf("Will poll for device serial assertion in 60 seconds")
 		return &state.Retry{After: retryInterval}
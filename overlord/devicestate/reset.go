@@ -0,0 +1,332 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package devicestate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/interfaces/backends"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/backend"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+)
+
+// systemReset is recorded in dirs.SnapSystemResetFile until the reset
+// is carried out on the next start of snapd.
+type systemReset struct {
+	System       string `json:"system,omitempty"`
+	FactoryReset bool   `json:"factory-reset,omitempty"`
+}
+
+// ResetSystem returns a task set preparing for the device to be
+// reseeded from the recovery system with the given label, or from
+// its original seed if the label is empty, and rebooting into it.
+// With factoryReset all the users, configuration and snap data go
+// as well, otherwise they are kept across the reset. The serial and
+// the device key are always kept.
+func ResetSystem(st *state.State, label string, factoryReset bool) (*state.TaskSet, error) {
+	if label != "" {
+		if err := checkSystemLabel(label); err != nil {
+			return nil, err
+		}
+	}
+	if !osutil.FileExists(filepath.Join(systemSeedDir(label), "seed.yaml")) {
+		if label == "" {
+			return nil, fmt.Errorf("cannot reset system: the device has no seed")
+		}
+		return nil, fmt.Errorf("cannot reset system: no recovery system %q", label)
+	}
+
+	var summary string
+	switch {
+	case label == "" && factoryReset:
+		summary = i18n.G("Prepare factory reset of the system")
+	case label == "":
+		summary = i18n.G("Prepare recovery of the system")
+	case factoryReset:
+		summary = fmt.Sprintf(i18n.G("Prepare factory reset of the system from recovery system %q"), label)
+	default:
+		summary = fmt.Sprintf(i18n.G("Prepare recovery of the system from recovery system %q"), label)
+	}
+	t := st.NewTask("request-system-reset", summary)
+	t.Set("system-reset", &systemReset{System: label, FactoryReset: factoryReset})
+	return state.NewTaskSet(t), nil
+}
+
+func (m *DeviceManager) doRequestSystemReset(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var reset systemReset
+	if err := t.Get("system-reset", &reset); err != nil {
+		return err
+	}
+	data, err := json.Marshal(&reset)
+	if err != nil {
+		return err
+	}
+	if err := osutil.AtomicWriteFile(dirs.SnapSystemResetFile, data, 0600, 0); err != nil {
+		return err
+	}
+
+	st.RequestRestart(state.RestartSystem)
+	return nil
+}
+
+// ApplyPendingReset carries out the reset of the system requested
+// through ResetSystem, if any. It needs to happen before the state is
+// loaded: the installed snaps are removed and the state is replaced by
+// one that gets the device seeded again from the chosen system.
+func ApplyPendingReset() error {
+	data, err := ioutil.ReadFile(dirs.SnapSystemResetFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot read system reset request: %v", err)
+	}
+	var reset systemReset
+	if err := json.Unmarshal(data, &reset); err != nil {
+		return fmt.Errorf("cannot decode system reset request: %v", err)
+	}
+
+	logger.Noticef("Resetting the system from recovery system %q (factory reset: %v).", reset.System, reset.FactoryReset)
+	if err := applyReset(&reset); err != nil {
+		return fmt.Errorf("cannot reset system: %v", err)
+	}
+
+	return os.Remove(dirs.SnapSystemResetFile)
+}
+
+var resetSecurityBackends = backends.All
+
+// resetStateFile is where the state replacing the current one is
+// staged while the reset is being carried out.
+func resetStateFile() string {
+	return dirs.SnapStateFile + ".reset"
+}
+
+// applyReset stages the state the device is reseeded with before
+// tearing anything down and only puts it in place once the teardown
+// is complete. If snapd is interrupted halfway through, the current
+// state and the reset request are still there and the next start
+// carries on with the teardown, all of its steps cope with the work
+// being done already.
+func applyReset(reset *systemReset) error {
+	f, err := os.Open(dirs.SnapStateFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	old, err := state.ReadState(nil, f)
+	if err != nil {
+		return err
+	}
+	old.Lock()
+	defer old.Unlock()
+
+	// Nothing about seeding is carried over, including the
+	// "preseeded" flag: the installed snaps, the ones put in place
+	// by preseeding included, are all torn down below and the
+	// device seeds from scratch, as if it had never been preseeded.
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	if reset.FactoryReset {
+		// only the identity of the device survives
+		device, err := auth.Device(old)
+		if err != nil {
+			return err
+		}
+		if err := auth.SetDevice(st, device); err != nil {
+			return err
+		}
+	} else {
+		for _, key := range []string{"auth", "config"} {
+			var value *json.RawMessage
+			err := old.Get(key, &value)
+			if err == state.ErrNoState {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			st.Set(key, value)
+		}
+	}
+	if reset.System != "" {
+		st.Set("seed-system", reset.System)
+	}
+
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	if err := osutil.AtomicWriteFile(resetStateFile(), data, 0600, 0); err != nil {
+		return err
+	}
+
+	if err := removeInstalledSnaps(old); err != nil {
+		return err
+	}
+	if reset.FactoryReset {
+		if err := removeUsers(old); err != nil {
+			return err
+		}
+		if err := removeSnapData(); err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(resetStateFile(), dirs.SnapStateFile)
+}
+
+// removeInstalledSnaps tears down the installed snaps the way removing
+// them does: their services are stopped and their aliases, wrappers,
+// security profiles, mount namespaces, mount units and revisions are
+// removed. The core and kernel snaps the device is running off are
+// left in place, only their other revisions go, seeding takes them
+// over again.
+func removeInstalledSnaps(st *state.State) error {
+	all, err := snapstate.All(st)
+	if err != nil {
+		return err
+	}
+	var b backend.Backend
+	meter := progress.Null
+	for name, snapst := range all {
+		typ, err := snapst.Type()
+		if err != nil {
+			return err
+		}
+		booted := typ == snap.TypeOS || typ == snap.TypeKernel
+		if !booted {
+			if err := tearDownSnap(b, name, snapst, meter); err != nil {
+				return err
+			}
+		}
+		for _, si := range snapst.Sequence {
+			if booted && si.Revision == snapst.Current {
+				continue
+			}
+			if err := b.RemoveSnapFiles(snap.MinimalPlaceInfo(name, si.Revision), typ, meter); err != nil {
+				return fmt.Errorf("cannot remove snap %q: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// tearDownSnap undoes everything linking and connecting the given
+// snap set up, except for the removal of its revisions.
+func tearDownSnap(b backend.Backend, name string, snapst *snapstate.SnapState, meter progress.Meter) error {
+	if snapst.Active {
+		// if an interrupted reset removed the current revision
+		// already this is a broken info, good enough to unlink
+		info, err := snapst.CurrentInfo()
+		if err != nil {
+			return err
+		}
+		if err := b.StopServices(info.Services(), snap.StopReasonRemove, meter); err != nil {
+			return fmt.Errorf("cannot stop services of snap %q: %v", name, err)
+		}
+		if err := b.UnlinkSnap(info, meter); err != nil {
+			return fmt.Errorf("cannot unlink snap %q: %v", name, err)
+		}
+	}
+	if err := b.RemoveSnapAliases(name); err != nil {
+		return fmt.Errorf("cannot remove aliases of snap %q: %v", name, err)
+	}
+	for _, secBackend := range resetSecurityBackends {
+		if err := secBackend.Remove(name); err != nil {
+			return fmt.Errorf("cannot remove %s security profiles of snap %q: %v", secBackend.Name(), name, err)
+		}
+	}
+	if err := b.DiscardSnapNamespace(name); err != nil {
+		return err
+	}
+	return nil
+}
+
+var (
+	userLookup    = user.Lookup
+	osutilDelUser = osutil.DelUser
+)
+
+// removeUsers removes the system users created with create-user.
+func removeUsers(st *state.State) error {
+	users, err := auth.Users(st)
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		if u.Username == "" {
+			continue
+		}
+		if _, err := userLookup(u.Username); err != nil {
+			if _, ok := err.(user.UnknownUserError); ok {
+				// removed already by an interrupted reset
+				continue
+			}
+			return fmt.Errorf("cannot look up user %q: %v", u.Username, err)
+		}
+		if err := osutilDelUser(u.Username, &osutil.DelUserOptions{ExtraUsers: !release.OnClassic}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeSnapData removes the system and user data of all snaps.
+func removeSnapData() error {
+	dataDirs, err := filepath.Glob(dirs.SnapDataHomeGlob)
+	if err != nil {
+		return err
+	}
+	fis, err := ioutil.ReadDir(dirs.SnapDataDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, fi := range fis {
+		dataDirs = append(dataDirs, filepath.Join(dirs.SnapDataDir, fi.Name()))
+	}
+	for _, dir := range dataDirs {
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
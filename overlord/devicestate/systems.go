@@ -0,0 +1,294 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package devicestate
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/snapasserts"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+var validSystemLabel = regexp.MustCompile("^[a-z0-9](?:-?[a-z0-9])*$")
+
+func checkSystemLabel(label string) error {
+	if !validSystemLabel.MatchString(label) {
+		return fmt.Errorf("invalid recovery system label %q", label)
+	}
+	return nil
+}
+
+// systemSeedDir returns the seed directory of the recovery system with
+// the given label, the empty label stands for the original seed.
+func systemSeedDir(label string) string {
+	if label == "" {
+		return dirs.SnapSeedDir
+	}
+	return filepath.Join(dirs.SnapSeedSystemsDir, label)
+}
+
+// RecoverySystems returns the labels of the recovery systems of the
+// device, in order. The original seed of the device is not included.
+func RecoverySystems() ([]string, error) {
+	fis, err := ioutil.ReadDir(dirs.SnapSeedSystemsDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot list recovery systems: %v", err)
+	}
+	var labels []string
+	for _, fi := range fis {
+		label := fi.Name()
+		if !fi.IsDir() || checkSystemLabel(label) != nil {
+			continue
+		}
+		if !osutil.FileExists(filepath.Join(systemSeedDir(label), "seed.yaml")) {
+			continue
+		}
+		labels = append(labels, label)
+	}
+	return labels, nil
+}
+
+// CurrentSystem returns the label of the recovery system the device
+// got seeded from, or "" for the original seed.
+func CurrentSystem(st *state.State) (string, error) {
+	var label string
+	err := st.Get("seed-system", &label)
+	if err != nil && err != state.ErrNoState {
+		return "", err
+	}
+	return label, nil
+}
+
+// currentSeedDir returns the seed directory to populate the state from.
+func currentSeedDir(st *state.State) (string, error) {
+	label, err := CurrentSystem(st)
+	if err != nil {
+		return "", err
+	}
+	return systemSeedDir(label), nil
+}
+
+// CreateRecoverySystem returns a task set creating a new recovery
+// system with the given label out of the snaps currently installed
+// and their assertions.
+func CreateRecoverySystem(st *state.State, label string) (*state.TaskSet, error) {
+	if err := checkSystemLabel(label); err != nil {
+		return nil, err
+	}
+	if osutil.FileExists(systemSeedDir(label)) {
+		return nil, fmt.Errorf("recovery system %q already exists", label)
+	}
+	if _, err := Model(st); err == state.ErrNoState {
+		return nil, fmt.Errorf("cannot create recovery system %q without a model", label)
+	} else if err != nil {
+		return nil, err
+	}
+
+	t := st.NewTask("create-recovery-system", fmt.Sprintf(i18n.G("Create recovery system %q"), label))
+	t.Set("system-label", label)
+	return state.NewTaskSet(t), nil
+}
+
+func (m *DeviceManager) doCreateRecoverySystem(t *state.Task, _ *tomb.Tomb) (err error) {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var label string
+	if err := t.Get("system-label", &label); err != nil {
+		return err
+	}
+	dir := systemSeedDir(label)
+	if osutil.FileExists(dir) {
+		return fmt.Errorf("recovery system %q already exists", label)
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	return writeRecoverySystem(st, dir)
+}
+
+func (m *DeviceManager) undoCreateRecoverySystem(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var label string
+	if err := t.Get("system-label", &label); err != nil {
+		return err
+	}
+	return os.RemoveAll(systemSeedDir(label))
+}
+
+// recoverySnap is a snap going into a recovery system.
+type recoverySnap struct {
+	info   *snap.Info
+	seed   *snap.SeedSnap
+	digest string
+}
+
+// writeRecoverySystem writes a seed in dir with the active snaps, the
+// model and the assertions needed to install them. It must be called
+// with the state locked, it unlocks it while the snaps are copied and
+// hashed.
+func writeRecoverySystem(st *state.State, dir string) error {
+	model, err := Model(st)
+	if err != nil {
+		return err
+	}
+
+	all, err := snapstate.All(st)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(all))
+	for name, snapst := range all {
+		if snapst.Active {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	snaps := make([]*recoverySnap, 0, len(names))
+	for _, name := range names {
+		snapst := all[name]
+		info, err := snapst.CurrentInfo()
+		if err != nil {
+			return err
+		}
+		snaps = append(snaps, &recoverySnap{
+			info: info,
+			seed: &snap.SeedSnap{
+				Name:       name,
+				SnapID:     info.SnapID,
+				Channel:    snapst.Channel,
+				File:       filepath.Base(info.MountFile()),
+				DevMode:    snapst.DevMode,
+				Classic:    snapst.Classic,
+				Private:    info.Private,
+				Contact:    info.Contact,
+				Unasserted: info.SnapID == "",
+			},
+		})
+	}
+
+	// copying and hashing the snaps takes a while, don't hold the
+	// state lock meanwhile
+	st.Unlock()
+	err = copyRecoverySnaps(dir, snaps)
+	st.Lock()
+	if err != nil {
+		return err
+	}
+
+	db := assertstate.DB(st)
+	var assertions []asserts.Assertion
+	retrieve := func(ref *asserts.Ref) (asserts.Assertion, error) {
+		return ref.Resolve(db.Find)
+	}
+	save := func(a asserts.Assertion) error {
+		assertions = append(assertions, a)
+		return nil
+	}
+	f := asserts.NewFetcher(db, retrieve, save)
+	if err := f.Save(model); err != nil {
+		return fmt.Errorf("cannot find the assertions of the model: %v", err)
+	}
+
+	var seed snap.Seed
+	for _, rs := range snaps {
+		if rs.digest != "" {
+			if err := snapasserts.FetchSnapAssertions(f, rs.digest); err != nil {
+				return fmt.Errorf("cannot find the assertions of snap %q: %v", rs.seed.Name, err)
+			}
+		}
+		seed.Snaps = append(seed.Snaps, rs.seed)
+	}
+
+	assertsDir := filepath.Join(dir, "assertions")
+	if err := os.MkdirAll(assertsDir, 0755); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	enc := asserts.NewEncoder(&buf)
+	for _, a := range assertions {
+		if err := enc.Encode(a); err != nil {
+			return err
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(assertsDir, "recovery-system"), buf.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	return seed.Write(filepath.Join(dir, "seed.yaml"))
+}
+
+// copyRecoverySnaps puts the given snaps into the recovery system in
+// dir and computes the digests of the asserted ones.
+func copyRecoverySnaps(dir string, snaps []*recoverySnap) error {
+	snapsDir := filepath.Join(dir, "snaps")
+	if err := os.MkdirAll(snapsDir, 0755); err != nil {
+		return err
+	}
+	for _, rs := range snaps {
+		if err := linkOrCopy(rs.info.MountFile(), filepath.Join(snapsDir, rs.seed.File)); err != nil {
+			return fmt.Errorf("cannot add snap %q to recovery system: %v", rs.seed.Name, err)
+		}
+		if rs.info.SnapID == "" {
+			continue
+		}
+		digest, _, err := asserts.SnapFileSHA3_384(rs.info.MountFile())
+		if err != nil {
+			return err
+		}
+		rs.digest = digest
+	}
+	return nil
+}
+
+// linkOrCopy hard links src to dst, falling back to a copy; snap files
+// never change once installed so sharing them is fine.
+func linkOrCopy(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	return osutil.CopyFile(src, dst, osutil.CopyFlagPreserveAll|osutil.CopyFlagSync)
+}
@@ -0,0 +1,431 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package devicestate_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/ifacetest"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func (s *deviceMgrSuite) setupRecoverySystemModel(c *C) {
+	s.setupBrands(c)
+	model, err := s.brandSigning.Sign(asserts.ModelType, map[string]interface{}{
+		"series":       "16",
+		"brand-id":     "my-brand",
+		"model":        "my-model",
+		"gadget":       "gadget",
+		"kernel":       "krnl",
+		"architecture": "amd64",
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	c.Assert(assertstate.Add(s.state, model), IsNil)
+	c.Assert(auth.SetDevice(s.state, &auth.DeviceState{
+		Brand: "my-brand",
+		Model: "my-model",
+	}), IsNil)
+}
+
+func (s *deviceMgrSuite) installSnapWithBlob(c *C, si *snap.SideInfo, typ snap.Type) *snap.Info {
+	info := snaptest.MockSnap(c, "{name: "+si.RealName+", version: 1.0, type: "+string(typ)+"}", si)
+	c.Assert(os.MkdirAll(dirs.SnapBlobDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(info.MountFile(), []byte(si.RealName+" blob"), 0644), IsNil)
+	snapstate.Set(s.state, si.RealName, &snapstate.SnapState{
+		SnapType: string(typ),
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+		Channel:  si.Channel,
+	})
+	return info
+}
+
+func (s *deviceMgrSuite) TestCreateRecoverySystem(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupRecoverySystemModel(c)
+
+	// an asserted snap
+	s.setupSnapDecl(c, "foo", "foo-id", "my-brand")
+	foo := s.installSnapWithBlob(c, &snap.SideInfo{RealName: "foo", SnapID: "foo-id", Revision: snap.R(5), Channel: "beta"}, snap.TypeApp)
+	digest, size, err := asserts.SnapFileSHA3_384(foo.MountFile())
+	c.Assert(err, IsNil)
+	fooRev, err := s.storeSigning.Sign(asserts.SnapRevisionType, map[string]interface{}{
+		"snap-sha3-384": digest,
+		"snap-size":     "8",
+		"snap-id":       "foo-id",
+		"snap-revision": "5",
+		"developer-id":  "my-brand",
+		"timestamp":     time.Now().UTC().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	c.Assert(size, Equals, uint64(8))
+	c.Assert(assertstate.Add(s.state, fooRev), IsNil)
+	// a local one
+	s.installSnapWithBlob(c, &snap.SideInfo{RealName: "bar", Revision: snap.R(-1)}, snap.TypeApp)
+
+	ts, err := devicestate.CreateRecoverySystem(s.state, "1234")
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("create-recovery-system", "...")
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	s.mgr.Ensure()
+	s.mgr.Wait()
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+
+	systemDir := filepath.Join(dirs.SnapSeedSystemsDir, "1234")
+	c.Check(filepath.Join(systemDir, "snaps", "foo_5.snap"), testutil.FileEquals, "foo blob")
+	c.Check(filepath.Join(systemDir, "snaps", "bar_x1.snap"), testutil.FileEquals, "bar blob")
+
+	seed, err := snap.ReadSeedYaml(filepath.Join(systemDir, "seed.yaml"))
+	c.Assert(err, IsNil)
+	c.Check(seed.Snaps, DeepEquals, []*snap.SeedSnap{
+		{Name: "bar", File: "bar_x1.snap", Unasserted: true},
+		{Name: "foo", SnapID: "foo-id", File: "foo_5.snap", Channel: "beta"},
+	})
+
+	data, err := ioutil.ReadFile(filepath.Join(systemDir, "assertions", "recovery-system"))
+	c.Assert(err, IsNil)
+	dec := asserts.NewDecoder(bytes.NewReader(data))
+	var types []string
+	for {
+		a, err := dec.Decode()
+		if err != nil {
+			break
+		}
+		types = append(types, a.Type().Name)
+	}
+	c.Check(types, DeepEquals, []string{
+		"account-key", "account", "account-key", "model",
+		"snap-declaration", "snap-revision",
+	})
+
+	labels, err := devicestate.RecoverySystems()
+	c.Assert(err, IsNil)
+	c.Check(labels, DeepEquals, []string{"1234"})
+}
+
+func (s *deviceMgrSuite) TestCreateRecoverySystemErrors(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := devicestate.CreateRecoverySystem(s.state, "-foo")
+	c.Check(err, ErrorMatches, `invalid recovery system label "-foo"`)
+
+	_, err = devicestate.CreateRecoverySystem(s.state, "1234")
+	c.Check(err, ErrorMatches, `cannot create recovery system "1234" without a model`)
+
+	c.Assert(os.MkdirAll(filepath.Join(dirs.SnapSeedSystemsDir, "1234"), 0755), IsNil)
+	_, err = devicestate.CreateRecoverySystem(s.state, "1234")
+	c.Check(err, ErrorMatches, `recovery system "1234" already exists`)
+}
+
+func (s *deviceMgrSuite) TestCreateRecoverySystemCleansUpOnError(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setupRecoverySystemModel(c)
+	bar := s.installSnapWithBlob(c, &snap.SideInfo{RealName: "bar", Revision: snap.R(-1)}, snap.TypeApp)
+	c.Assert(os.Remove(bar.MountFile()), IsNil)
+
+	ts, err := devicestate.CreateRecoverySystem(s.state, "1234")
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("create-recovery-system", "...")
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	s.mgr.Ensure()
+	s.mgr.Wait()
+	s.state.Lock()
+
+	c.Check(chg.Err(), ErrorMatches, `(?s).*bar_x1.snap: no such file or directory.*`)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapSeedSystemsDir, "1234")), Equals, false)
+}
+
+func (s *deviceMgrSuite) TestResetSystem(c *C) {
+	systemDir := filepath.Join(dirs.SnapSeedSystemsDir, "1234")
+	c.Assert(os.MkdirAll(systemDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(systemDir, "seed.yaml"), nil, 0644), IsNil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := devicestate.ResetSystem(s.state, "4567", false)
+	c.Check(err, ErrorMatches, `cannot reset system: no recovery system "4567"`)
+	_, err = devicestate.ResetSystem(s.state, "", true)
+	c.Check(err, ErrorMatches, `cannot reset system: the device has no seed`)
+
+	ts, err := devicestate.ResetSystem(s.state, "1234", true)
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("reset-system", "...")
+	chg.AddAll(ts)
+	c.Check(ts.Tasks()[0].Summary(), Equals, `Prepare factory reset of the system from recovery system "1234"`)
+
+	s.state.Unlock()
+	s.mgr.Ensure()
+	s.mgr.Wait()
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Check(s.state.Restarting(), Equals, true)
+	c.Check(dirs.SnapSystemResetFile, testutil.FileEquals, `{"system":"1234","factory-reset":true}`)
+}
+
+func (s *deviceMgrSuite) writeStateForReset(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	c.Assert(auth.SetDevice(st, &auth.DeviceState{
+		Brand:  "my-brand",
+		Model:  "my-model",
+		Serial: "serial-1",
+		KeyID:  "key-id",
+	}), IsNil)
+	_, err := auth.NewUser(st, "user", "user@example.com", "macaroon", nil)
+	c.Assert(err, IsNil)
+	st.Set("config", map[string]interface{}{"foo": map[string]interface{}{"key": "value"}})
+	st.Set("seeded", true)
+
+	st.Set("preseeded", true)
+
+	s.installSnapWithBlobIn(c, st, "{name: foo, version: 1.0, apps: {svc: {daemon: simple}}}", &snap.SideInfo{RealName: "foo", Revision: snap.R(5)}, snap.TypeApp)
+	s.installSnapWithBlobIn(c, st, "{name: core, version: 1.0, type: os}", &snap.SideInfo{RealName: "core", Revision: snap.R(1)}, snap.TypeOS)
+
+	data, err := json.Marshal(st)
+	c.Assert(err, IsNil)
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapStateFile), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(dirs.SnapStateFile, data, 0600), IsNil)
+
+	c.Assert(os.MkdirAll(filepath.Join(dirs.SnapDataDir, "foo", "5"), 0755), IsNil)
+	c.Assert(os.MkdirAll(dirs.SnapServicesDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dirs.SnapServicesDir, "snap.foo.svc.service"), nil, 0644), IsNil)
+}
+
+func (s *deviceMgrSuite) installSnapWithBlobIn(c *C, st *state.State, snapYaml string, si *snap.SideInfo, typ snap.Type) {
+	info := snaptest.MockSnap(c, snapYaml, si)
+	c.Assert(os.MkdirAll(dirs.SnapBlobDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(info.MountFile(), []byte(si.RealName+" blob"), 0644), IsNil)
+	snapstate.Set(st, si.RealName, &snapstate.SnapState{
+		SnapType: string(typ),
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+	})
+}
+
+func (s *deviceMgrSuite) readStateAfterReset(c *C) *state.State {
+	f, err := os.Open(dirs.SnapStateFile)
+	c.Assert(err, IsNil)
+	defer f.Close()
+	st, err := state.ReadState(nil, f)
+	c.Assert(err, IsNil)
+	return st
+}
+
+func (s *deviceMgrSuite) TestApplyPendingResetNothingToDo(c *C) {
+	c.Assert(devicestate.ApplyPendingReset(), IsNil)
+	c.Check(osutil.FileExists(dirs.SnapStateFile), Equals, false)
+}
+
+func (s *deviceMgrSuite) TestApplyPendingFactoryReset(c *C) {
+	restore := release.MockOnClassic(false)
+	defer restore()
+	systemctl := testutil.MockCommand(c, "systemctl", "")
+	defer systemctl.Restore()
+	secBackend := &ifacetest.TestSecurityBackend{}
+	restore = devicestate.MockResetSecurityBackends([]interfaces.SecurityBackend{secBackend})
+	defer restore()
+	restore = devicestate.MockUserLookup(func(name string) (*user.User, error) {
+		return &user.User{Username: name}, nil
+	})
+	defer restore()
+	var delUserCalls []string
+	restore = devicestate.MockOsutilDelUser(func(name string, opts *osutil.DelUserOptions) error {
+		c.Check(opts, DeepEquals, &osutil.DelUserOptions{ExtraUsers: true})
+		delUserCalls = append(delUserCalls, name)
+		return nil
+	})
+	defer restore()
+
+	s.writeStateForReset(c)
+	c.Assert(ioutil.WriteFile(dirs.SnapSystemResetFile, []byte(`{"system":"1234","factory-reset":true}`), 0600), IsNil)
+
+	c.Assert(devicestate.ApplyPendingReset(), IsNil)
+	c.Check(osutil.FileExists(dirs.SnapSystemResetFile), Equals, false)
+	c.Check(osutil.FileExists(devicestate.ResetStateFile()), Equals, false)
+
+	// services are stopped and profiles and users removed
+	c.Check(systemctl.Calls(), testutil.DeepContains, []string{"systemctl", "stop", "snap.foo.svc.service"})
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapServicesDir, "snap.foo.svc.service")), Equals, false)
+	c.Check(secBackend.RemoveCalls, DeepEquals, []string{"foo"})
+	c.Check(delUserCalls, DeepEquals, []string{"user"})
+
+	// snaps and their data are gone, except for the booted core
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapBlobDir, "foo_5.snap")), Equals, false)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapMountDir, "foo", "5")), Equals, false)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapDataDir, "foo")), Equals, false)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapBlobDir, "core_1.snap")), Equals, true)
+
+	st := s.readStateAfterReset(c)
+	st.Lock()
+	defer st.Unlock()
+
+	device, err := auth.Device(st)
+	c.Assert(err, IsNil)
+	c.Check(device, DeepEquals, &auth.DeviceState{
+		Brand:  "my-brand",
+		Model:  "my-model",
+		Serial: "serial-1",
+		KeyID:  "key-id",
+	})
+	users, err := auth.Users(st)
+	c.Assert(err, IsNil)
+	c.Check(users, HasLen, 0)
+	var seeded bool
+	c.Check(st.Get("seeded", &seeded), Equals, state.ErrNoState)
+	var config map[string]interface{}
+	c.Check(st.Get("config", &config), Equals, state.ErrNoState)
+	var preseeded bool
+	c.Check(st.Get("preseeded", &preseeded), Equals, state.ErrNoState)
+	_, err = snapstate.All(st)
+	c.Assert(err, IsNil)
+	var snaps map[string]interface{}
+	c.Check(st.Get("snaps", &snaps), Equals, state.ErrNoState)
+
+	label, err := devicestate.CurrentSystem(st)
+	c.Assert(err, IsNil)
+	c.Check(label, Equals, "1234")
+}
+
+func (s *deviceMgrSuite) TestApplyPendingRecovery(c *C) {
+	systemctl := testutil.MockCommand(c, "systemctl", "")
+	defer systemctl.Restore()
+	secBackend := &ifacetest.TestSecurityBackend{}
+	restore := devicestate.MockResetSecurityBackends([]interfaces.SecurityBackend{secBackend})
+	defer restore()
+	restore = devicestate.MockOsutilDelUser(func(name string, opts *osutil.DelUserOptions) error {
+		c.Fatalf("unexpected removal of user %q", name)
+		return nil
+	})
+	defer restore()
+
+	s.writeStateForReset(c)
+	c.Assert(ioutil.WriteFile(dirs.SnapSystemResetFile, []byte(`{}`), 0600), IsNil)
+
+	c.Assert(devicestate.ApplyPendingReset(), IsNil)
+
+	// snaps are gone but their data is kept
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapBlobDir, "foo_5.snap")), Equals, false)
+	c.Check(secBackend.RemoveCalls, DeepEquals, []string{"foo"})
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapDataDir, "foo", "5")), Equals, true)
+
+	st := s.readStateAfterReset(c)
+	st.Lock()
+	defer st.Unlock()
+
+	users, err := auth.Users(st)
+	c.Assert(err, IsNil)
+	c.Check(users, HasLen, 1)
+	var config map[string]map[string]string
+	c.Assert(st.Get("config", &config), IsNil)
+	c.Check(config, DeepEquals, map[string]map[string]string{"foo": {"key": "value"}})
+	var snaps map[string]interface{}
+	c.Check(st.Get("snaps", &snaps), Equals, state.ErrNoState)
+
+	// reseeding from the original seed
+	label, err := devicestate.CurrentSystem(st)
+	c.Assert(err, IsNil)
+	c.Check(label, Equals, "")
+}
+
+func (s *deviceMgrSuite) TestApplyPendingResetResumes(c *C) {
+	systemctl := testutil.MockCommand(c, "systemctl", "")
+	defer systemctl.Restore()
+	restore := devicestate.MockResetSecurityBackends(nil)
+	defer restore()
+	restore = devicestate.MockUserLookup(func(name string) (*user.User, error) {
+		return &user.User{Username: name}, nil
+	})
+	defer restore()
+	restore = devicestate.MockOsutilDelUser(func(name string, opts *osutil.DelUserOptions) error {
+		return fmt.Errorf("boom")
+	})
+	defer restore()
+
+	s.writeStateForReset(c)
+	c.Assert(ioutil.WriteFile(dirs.SnapSystemResetFile, []byte(`{"factory-reset":true}`), 0600), IsNil)
+	oldState, err := ioutil.ReadFile(dirs.SnapStateFile)
+	c.Assert(err, IsNil)
+
+	// interrupted after the snaps are gone
+	c.Assert(devicestate.ApplyPendingReset(), ErrorMatches, "cannot reset system: boom")
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapBlobDir, "foo_5.snap")), Equals, false)
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapMountDir, "foo", "5")), Equals, false)
+	// the state and the request are left alone
+	c.Check(dirs.SnapStateFile, testutil.FileEquals, oldState)
+	c.Check(osutil.FileExists(dirs.SnapSystemResetFile), Equals, true)
+
+	// the next start carries on
+	var delUserCalls []string
+	restore = devicestate.MockOsutilDelUser(func(name string, opts *osutil.DelUserOptions) error {
+		delUserCalls = append(delUserCalls, name)
+		return nil
+	})
+	defer restore()
+	c.Assert(devicestate.ApplyPendingReset(), IsNil)
+	c.Check(delUserCalls, DeepEquals, []string{"user"})
+	c.Check(osutil.FileExists(dirs.SnapSystemResetFile), Equals, false)
+	c.Check(osutil.FileExists(devicestate.ResetStateFile()), Equals, false)
+
+	st := s.readStateAfterReset(c)
+	st.Lock()
+	defer st.Unlock()
+	var snaps map[string]interface{}
+	c.Check(st.Get("snaps", &snaps), Equals, state.ErrNoState)
+}
@@ -21,6 +21,8 @@ package ctlcmd
 
 import (
 	"fmt"
+
+	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/servicestate"
 	"github.com/snapcore/snapd/overlord/state"
@@ -78,3 +80,15 @@ func (c *MockCommand) Execute(args []string) error {
 
 	return nil
 }
+
+func MockDevicestateModel(f func(*state.State) (*asserts.Model, error)) (restore func()) {
+	old := devicestateModel
+	devicestateModel = f
+	return func() { devicestateModel = old }
+}
+
+func MockAssertstateSnapDeclaration(f func(*state.State, string) (*asserts.SnapDeclaration, error)) (restore func()) {
+	old := assertstateSnapDeclaration
+	assertstateSnapDeclaration = f
+	return func() { assertstateSnapDeclaration = old }
+}
@@ -44,7 +44,7 @@ type getCommand struct {
 		Keys           []string `positional-arg-name:"<keys>" description:"option keys"`
 	} `positional-args:"yes"`
 
-	Document bool `short:"d" description:"always return document, even with single key"`
+	Document bool `short:"d" long:"document" description:"always return document, even with single key"`
 	Typed    bool `short:"t" description:"strict typing with nulls and quoted strings"`
 }
 
@@ -196,6 +196,12 @@ const (
 	prepareSlotHook
 	connectPlugHook
 	connectSlotHook
+	unpreparePlugHook
+	unprepareSlotHook
+	disconnectPlugHook
+	disconnectSlotHook
+	changedPlugHook
+	changedSlotHook
 	unknownHook
 )
 
@@ -208,6 +214,18 @@ func interfaceHookType(hookName string) (ifaceHookType, error) {
 		return prepareSlotHook, nil
 	} else if strings.HasPrefix(hookName, "connect-slot-") {
 		return connectSlotHook, nil
+	} else if strings.HasPrefix(hookName, "unprepare-plug-") {
+		return unpreparePlugHook, nil
+	} else if strings.HasPrefix(hookName, "unprepare-slot-") {
+		return unprepareSlotHook, nil
+	} else if strings.HasPrefix(hookName, "disconnect-plug-") {
+		return disconnectPlugHook, nil
+	} else if strings.HasPrefix(hookName, "disconnect-slot-") {
+		return disconnectSlotHook, nil
+	} else if strings.HasPrefix(hookName, "changed-plug-") {
+		return changedPlugHook, nil
+	} else if strings.HasPrefix(hookName, "changed-slot-") {
+		return changedSlotHook, nil
 	}
 	return unknownHook, fmt.Errorf("unknown hook type")
 }
@@ -275,7 +293,9 @@ func (c *getCommand) getInterfaceSetting(context *hookstate.Context, plugOrSlot
 		return fmt.Errorf("cannot use --plug and --slot together")
 	}
 
-	isPlugSide := (hookType == preparePlugHook || hookType == connectPlugHook)
+	isPlugSide := (hookType == preparePlugHook || hookType == connectPlugHook ||
+		hookType == unpreparePlugHook || hookType == disconnectPlugHook ||
+		hookType == changedPlugHook)
 	if err = validatePlugOrSlot(attrsTask, isPlugSide, plugOrSlot); err != nil {
 		return err
 	}
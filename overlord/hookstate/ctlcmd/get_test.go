@@ -106,6 +106,9 @@ var getTests = []struct {
 }, {
 	args:   "get -d test-key1",
 	stdout: "{\n\t\"test-key1\": \"test-value1\"\n}\n",
+}, {
+	args:   "get --document test-key1",
+	stdout: "{\n\t\"test-key1\": \"test-value1\"\n}\n",
 }, {
 	args:   "get test-key1 test-key2",
 	stdout: "{\n\t\"test-key1\": \"test-value1\",\n\t\"test-key2\": 2\n}\n",
@@ -250,6 +253,43 @@ func (s *getAttrSuite) TestGetPlugAttributeInSlotHook(c *C) {
 	c.Check(string(stderr), Equals, "")
 }
 
+func (s *getAttrSuite) TestGetAttributesInDisconnectAndUnprepareHooks(c *C) {
+	st := s.mockPlugHookContext.State()
+	st.Lock()
+	var attrsTask *state.Task
+	for _, t := range st.Tasks() {
+		if t.Kind() == "connect-task" {
+			attrsTask = t
+		}
+	}
+	st.Unlock()
+	c.Assert(attrsTask, NotNil)
+
+	for _, tc := range []struct {
+		hook, plugOrSlot, attr, value string
+	}{
+		{"disconnect-plug-aplug", ":aplug", "aattr", "foo"},
+		{"unprepare-plug-aplug", ":aplug", "aattr", "foo"},
+		{"disconnect-slot-bslot", ":bslot", "battr", "bar"},
+		{"unprepare-slot-bslot", ":bslot", "battr", "bar"},
+	} {
+		st.Lock()
+		task := st.NewTask("run-hook", "my test task")
+		st.Unlock()
+		setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: tc.hook}
+		context, err := hookstate.NewContext(task, st, setup, s.mockHandler, "")
+		c.Assert(err, IsNil)
+		context.Lock()
+		context.Set("attrs-task", attrsTask.ID())
+		context.Unlock()
+
+		stdout, stderr, err := ctlcmd.Run(context, []string{"get", tc.plugOrSlot, tc.attr})
+		c.Check(err, IsNil, Commentf("hook %s", tc.hook))
+		c.Check(string(stdout), Equals, tc.value+"\n")
+		c.Check(string(stderr), Equals, "")
+	}
+}
+
 func (s *getAttrSuite) TestUnknownPlugAttribute(c *C) {
 	stdout, stderr, err := ctlcmd.Run(s.mockPlugHookContext, []string{"get", ":aplug", "x"})
 	c.Check(err, NotNil)
@@ -309,11 +349,10 @@ func (s *setSuite) TestNull(c *C) {
 	defer s.mockContext.Unlock()
 	c.Check(s.mockContext.Done(), IsNil)
 
-	// Verify config value
+	// Verify config value; setting null removes the option
 	var value interface{}
 	tr := config.NewTransaction(s.mockContext.State())
-	c.Assert(tr.Get("test-snap", "foo", &value), IsNil)
-	c.Assert(value, IsNil)
+	c.Assert(tr.Get("test-snap", "foo", &value), ErrorMatches, `snap "test-snap" has no "foo" configuration option`)
 	c.Assert(tr.Get("test-snap", "bar", &value), IsNil)
 	c.Assert(value, DeepEquals, []interface{}{nil})
 }
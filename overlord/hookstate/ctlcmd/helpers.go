@@ -123,10 +123,18 @@ func runServiceCommand(context *hookstate.Context, inst *servicestate.Instructio
 		return err
 	}
 
+	return runTaskSets(context, inst.Action, tts)
+}
+
+// runTaskSets queues the given task sets after the configure hook when
+// run from it, or otherwise runs them in a change of their own and
+// waits for them.
+func runTaskSets(context *hookstate.Context, action string, tts []*state.TaskSet) error {
 	if !context.IsEphemeral() && context.HookName() == "configure" {
 		return queueCommand(context, tts)
 	}
 
+	st := context.State()
 	st.Lock()
 	chg := st.NewChange("service-control", fmt.Sprintf("Running service command for snap %q", context.SnapName()))
 	for _, ts := range tts {
@@ -141,6 +149,6 @@ func runServiceCommand(context *hookstate.Context, inst *servicestate.Instructio
 		defer st.Unlock()
 		return chg.Err()
 	case <-time.After(configstate.ConfigureHookTimeout() / 2):
-		return fmt.Errorf("%s command is taking too long", inst.Action)
+		return fmt.Errorf("%s command is taking too long", action)
 	}
 }
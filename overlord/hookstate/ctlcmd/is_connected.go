@@ -0,0 +1,70 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/ifacestate/ifacerepo"
+)
+
+var (
+	shortIsConnectedHelp = i18n.G("Return success if the given plug or slot is connected")
+	longIsConnectedHelp  = i18n.G(`
+The is-connected command succeeds if the given plug or slot of the snap is
+connected, and fails otherwise.
+
+    $ snapctl is-connected network
+`)
+)
+
+func init() {
+	addCommand("is-connected", shortIsConnectedHelp, longIsConnectedHelp, func() command { return &isConnectedCommand{} })
+}
+
+type isConnectedCommand struct {
+	baseCommand
+	Positional struct {
+		PlugOrSlot string `positional-arg-name:"<plug|slot>" required:"yes"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (c *isConnectedCommand) Execute(args []string) error {
+	context := c.context()
+	if context == nil {
+		return fmt.Errorf(i18n.G("cannot check connections without a context"))
+	}
+
+	st := context.State()
+	st.Lock()
+	repo := ifacerepo.Get(st)
+	st.Unlock()
+
+	// only the plugs and slots of the snap of the context can be queried
+	conns, err := repo.Connected(context.SnapName(), c.Positional.PlugOrSlot)
+	if err != nil {
+		return err
+	}
+	if len(conns) == 0 {
+		return fmt.Errorf(i18n.G("%q is not connected"), c.Positional.PlugOrSlot)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/ifacetest"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/ifacestate/ifacerepo"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+type isConnectedSuite struct {
+	mockContext *hookstate.Context
+	mockHandler *hooktest.MockHandler
+}
+
+var _ = Suite(&isConnectedSuite{})
+
+const isConnectedConsumerYaml = `name: consumer
+version: 1
+plugs:
+ plug1:
+  interface: test
+ plug2:
+  interface: test
+`
+
+const isConnectedProducerYaml = `name: producer
+version: 1
+slots:
+ slot1:
+  interface: test
+`
+
+func (s *isConnectedSuite) SetUpTest(c *C) {
+	s.mockHandler = hooktest.NewMockHandler()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	repo := interfaces.NewRepository()
+	c.Assert(repo.AddInterface(&ifacetest.TestInterface{InterfaceName: "test"}), IsNil)
+	consumer := snaptest.MockInfo(c, isConnectedConsumerYaml, nil)
+	producer := snaptest.MockInfo(c, isConnectedProducerYaml, nil)
+	c.Assert(repo.AddSnap(consumer), IsNil)
+	c.Assert(repo.AddSnap(producer), IsNil)
+	c.Assert(repo.Connect(interfaces.ConnRef{
+		PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "plug1"},
+		SlotRef: interfaces.SlotRef{Snap: "producer", Name: "slot1"},
+	}), IsNil)
+	ifacerepo.Replace(st, repo)
+
+	task := st.NewTask("test-task", "my test task")
+	setup := &hookstate.HookSetup{Snap: "consumer", Revision: snap.R(1), Hook: "test-hook"}
+
+	var err error
+	s.mockContext, err = hookstate.NewContext(task, st, setup, s.mockHandler, "")
+	c.Assert(err, IsNil)
+}
+
+func (s *isConnectedSuite) TestConnected(c *C) {
+	stdout, stderr, err := ctlcmd.Run(s.mockContext, []string{"is-connected", "plug1"})
+	c.Check(err, IsNil)
+	c.Check(string(stdout), Equals, "")
+	c.Check(string(stderr), Equals, "")
+}
+
+func (s *isConnectedSuite) TestNotConnected(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"is-connected", "plug2"})
+	c.Check(err, ErrorMatches, `"plug2" is not connected`)
+}
+
+func (s *isConnectedSuite) TestUnknownPlugOrSlot(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"is-connected", "plug3"})
+	c.Check(err, ErrorMatches, `snap "consumer" has no plug or slot named "plug3"`)
+}
+
+func (s *isConnectedSuite) TestOtherSnapNotQueried(c *C) {
+	// the slot of the producer is not visible from the consumer context
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"is-connected", "slot1"})
+	c.Check(err, ErrorMatches, `snap "consumer" has no plug or slot named "slot1"`)
+}
+
+func (s *isConnectedSuite) TestMissingArgument(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"is-connected"})
+	c.Check(err, ErrorMatches, ".*the required argument `<plug|slot>` was not provided")
+}
+
+func (s *isConnectedSuite) TestCommandWithoutContext(c *C) {
+	_, _, err := ctlcmd.Run(nil, []string{"is-connected", "plug1"})
+	c.Check(err, ErrorMatches, "cannot check connections without a context")
+}
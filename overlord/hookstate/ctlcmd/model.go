@@ -0,0 +1,100 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+var (
+	shortModelHelp = i18n.G("Print the model assertion of the device")
+	longModelHelp  = i18n.G(`
+The model command prints the model assertion of the device. Only the gadget and
+kernel snaps and snaps published by the brand of the device can use it.
+`)
+)
+
+var (
+	devicestateModel           = devicestate.Model
+	assertstateSnapDeclaration = assertstate.SnapDeclaration
+)
+
+func init() {
+	addCommand("model", shortModelHelp, longModelHelp, func() command { return &modelCommand{} })
+}
+
+type modelCommand struct {
+	baseCommand
+}
+
+// canSeeModel returns whether the given snap may see the model
+// assertion.
+func canSeeModel(st *state.State, info *snap.Info, model *asserts.Model) bool {
+	if info.Type == snap.TypeGadget || info.Type == snap.TypeKernel {
+		return true
+	}
+	if info.SnapID == "" {
+		// unasserted snaps have no publisher
+		return false
+	}
+	decl, err := assertstateSnapDeclaration(st, info.SnapID)
+	if err != nil {
+		return false
+	}
+	return decl.PublisherID() == model.BrandID()
+}
+
+func (c *modelCommand) Execute(args []string) error {
+	context := c.context()
+	if context == nil {
+		return fmt.Errorf(i18n.G("cannot get the model without a context"))
+	}
+
+	st := context.State()
+	st.Lock()
+	defer st.Unlock()
+
+	snapName := context.SnapName()
+	info, err := snapstate.CurrentInfo(st, snapName)
+	if err != nil {
+		return err
+	}
+	model, err := devicestateModel(st)
+	if err == state.ErrNoState {
+		return fmt.Errorf(i18n.G("no model assertion yet"))
+	}
+	if err != nil {
+		return err
+	}
+	if !canSeeModel(st, info, model) {
+		return fmt.Errorf(i18n.G("snap %q cannot access the model assertion"), snapName)
+	}
+
+	c.printf("%s", asserts.Encode(model))
+	return nil
+}
@@ -0,0 +1,174 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd_test
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type modelSuite struct {
+	testutil.BaseTest
+	st          *state.State
+	mockHandler *hooktest.MockHandler
+
+	model *asserts.Model
+	decls map[string]*asserts.SnapDeclaration
+}
+
+var _ = Suite(&modelSuite{})
+
+var (
+	brandPrivKey, _ = assertstest.GenerateKey(752)
+	storePrivKey, _ = assertstest.GenerateKey(752)
+)
+
+func (s *modelSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+
+	s.mockHandler = hooktest.NewMockHandler()
+	s.st = state.New(nil)
+
+	brandSigning := assertstest.NewSigningDB("my-brand", brandPrivKey)
+	a, err := brandSigning.Sign(asserts.ModelType, map[string]interface{}{
+		"series":       "16",
+		"authority-id": "my-brand",
+		"brand-id":     "my-brand",
+		"model":        "my-model",
+		"architecture": "amd64",
+		"gadget":       "gadget",
+		"kernel":       "krnl",
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	s.model = a.(*asserts.Model)
+
+	storeSigning := assertstest.NewSigningDB("canonical", storePrivKey)
+	s.decls = make(map[string]*asserts.SnapDeclaration)
+	for name, publisher := range map[string]string{
+		"brand-snap": "my-brand",
+		"other-snap": "other-publisher",
+	} {
+		a, err := storeSigning.Sign(asserts.SnapDeclarationType, map[string]interface{}{
+			"series":       "16",
+			"snap-id":      name + "-id",
+			"snap-name":    name,
+			"publisher-id": publisher,
+			"timestamp":    time.Now().Format(time.RFC3339),
+		}, nil, "")
+		c.Assert(err, IsNil)
+		s.decls[name+"-id"] = a.(*asserts.SnapDeclaration)
+	}
+
+	s.AddCleanup(ctlcmd.MockDevicestateModel(func(*state.State) (*asserts.Model, error) {
+		if s.model == nil {
+			return nil, state.ErrNoState
+		}
+		return s.model, nil
+	}))
+	s.AddCleanup(ctlcmd.MockAssertstateSnapDeclaration(func(_ *state.State, snapID string) (*asserts.SnapDeclaration, error) {
+		decl, ok := s.decls[snapID]
+		if !ok {
+			return nil, state.ErrNoState
+		}
+		return decl, nil
+	}))
+}
+
+func (s *modelSuite) mockContext(c *C, snapYaml, snapID string) *hookstate.Context {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	si := &snap.SideInfo{Revision: snap.R(1), SnapID: snapID}
+	info := snaptest.MockSnap(c, snapYaml, si)
+	si.RealName = info.Name()
+	snapstate.Set(s.st, info.Name(), &snapstate.SnapState{
+		Active:   true,
+		Sequence: []*snap.SideInfo{si},
+		Current:  si.Revision,
+	})
+
+	task := s.st.NewTask("test-task", "my test task")
+	setup := &hookstate.HookSetup{Snap: info.Name(), Revision: snap.R(1), Hook: "test-hook"}
+	context, err := hookstate.NewContext(task, s.st, setup, s.mockHandler, "")
+	c.Assert(err, IsNil)
+	return context
+}
+
+func (s *modelSuite) TestModelGadget(c *C) {
+	context := s.mockContext(c, "name: gadget\nversion: 1\ntype: gadget\n", "")
+	stdout, stderr, err := ctlcmd.Run(context, []string{"model"})
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, string(asserts.Encode(s.model)))
+	c.Check(string(stderr), Equals, "")
+}
+
+func (s *modelSuite) TestModelKernel(c *C) {
+	context := s.mockContext(c, "name: krnl\nversion: 1\ntype: kernel\n", "")
+	stdout, _, err := ctlcmd.Run(context, []string{"model"})
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, string(asserts.Encode(s.model)))
+}
+
+func (s *modelSuite) TestModelBrandSnap(c *C) {
+	context := s.mockContext(c, "name: brand-snap\nversion: 1\n", "brand-snap-id")
+	stdout, _, err := ctlcmd.Run(context, []string{"model"})
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, string(asserts.Encode(s.model)))
+}
+
+func (s *modelSuite) TestModelOtherPublisher(c *C) {
+	context := s.mockContext(c, "name: other-snap\nversion: 1\n", "other-snap-id")
+	_, _, err := ctlcmd.Run(context, []string{"model"})
+	c.Check(err, ErrorMatches, `snap "other-snap" cannot access the model assertion`)
+}
+
+func (s *modelSuite) TestModelUnasserted(c *C) {
+	context := s.mockContext(c, "name: local-snap\nversion: 1\n", "")
+	_, _, err := ctlcmd.Run(context, []string{"model"})
+	c.Check(err, ErrorMatches, `snap "local-snap" cannot access the model assertion`)
+}
+
+func (s *modelSuite) TestModelNotYet(c *C) {
+	s.model = nil
+	context := s.mockContext(c, "name: gadget\nversion: 1\ntype: gadget\n", "")
+	_, _, err := ctlcmd.Run(context, []string{"model"})
+	c.Check(err, ErrorMatches, "no model assertion yet")
+}
+
+func (s *modelSuite) TestCommandWithoutContext(c *C) {
+	_, _, err := ctlcmd.Run(nil, []string{"model"})
+	c.Check(err, ErrorMatches, "cannot get the model without a context")
+}
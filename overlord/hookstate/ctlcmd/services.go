@@ -0,0 +1,177 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/systemd"
+)
+
+var (
+	shortServicesHelp = i18n.G("Query the status of services or act on them")
+	longServicesHelp  = i18n.G(`
+The services command acts on the services of the snap. The "status" action,
+which is the default, shows the status of the given services, or of all the
+services of the snap if none are given.
+
+    $ snapctl services status
+    Service              Startup  Current
+    test-snap.service-1  enabled  active
+
+The "reload" action reloads the given services if they support it and restarts
+them otherwise; services that are not running are left alone. Without services,
+those declaring "reload-on-config" are reloaded. If executed from the
+"configure" hook, the services will be reloaded after the hook finishes.
+
+    $ snapctl services reload test-snap.service-1`)
+)
+
+func init() {
+	addCommand("services", shortServicesHelp, longServicesHelp, func() command { return &servicesCommand{} })
+}
+
+type servicesCommand struct {
+	baseCommand
+	Positional struct {
+		Action       string   `positional-arg-name:"<action>"`
+		ServiceNames []string `positional-arg-name:"<service>"`
+	} `positional-args:"yes"`
+}
+
+func (c *servicesCommand) Execute(args []string) error {
+	context := c.context()
+	action := c.Positional.Action
+	switch action {
+	case "", "status":
+		return c.status(context, c.Positional.ServiceNames)
+	case "reload":
+		// handled below
+	default:
+		return fmt.Errorf(i18n.G("unsupported services action: %q"), action)
+	}
+
+	if context == nil {
+		return fmt.Errorf(i18n.G("cannot reload services without a context"))
+	}
+
+	st := context.State()
+	snapName := context.SnapName()
+	var names []string
+	if len(c.Positional.ServiceNames) > 0 {
+		appInfos, err := getServiceInfos(st, snapName, c.Positional.ServiceNames)
+		if err != nil {
+			return err
+		}
+		for _, app := range appInfos {
+			names = append(names, app.Name)
+		}
+	}
+
+	st.Lock()
+	if len(names) == 0 {
+		info, err := snapstate.CurrentInfo(st, snapName)
+		if err != nil {
+			st.Unlock()
+			return err
+		}
+		reload := false
+		for _, svc := range info.Services() {
+			reload = reload || svc.ReloadOnConfig
+		}
+		if !reload {
+			st.Unlock()
+			return fmt.Errorf(i18n.G("snap %q has no services to reload on configuration change"), snapName)
+		}
+	}
+	ts, err := snapstate.ReloadServices(st, snapName, names)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return runTaskSets(context, "reload", []*state.TaskSet{ts})
+}
+
+// status prints the status of the given services of the snap of the
+// context, or of all of them.
+func (c *servicesCommand) status(context *hookstate.Context, serviceNames []string) error {
+	if context == nil {
+		return fmt.Errorf(i18n.G("cannot query services without a context"))
+	}
+
+	st := context.State()
+	snapName := context.SnapName()
+	if len(serviceNames) == 0 {
+		serviceNames = []string{snapName}
+	}
+	svcs, err := getServiceInfos(st, snapName, serviceNames)
+	if err != nil {
+		return err
+	}
+	if len(svcs) == 0 {
+		return fmt.Errorf(i18n.G("snap %q has no services"), snapName)
+	}
+	sort.Sort(byAppName(svcs))
+
+	unitNames := make([]string, len(svcs))
+	for i, svc := range svcs {
+		unitNames[i] = svc.ServiceName()
+	}
+	sysd := systemd.New(dirs.GlobalRootDir, progress.Null)
+	sts, err := sysd.Status(unitNames...)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 5, 3, 2, ' ', 0)
+	fmt.Fprintln(w, i18n.G("Service\tStartup\tCurrent"))
+	for i, svc := range svcs {
+		startup := i18n.G("disabled")
+		if sts[i].Enabled {
+			startup = i18n.G("enabled")
+		}
+		current := i18n.G("inactive")
+		if sts[i].Active {
+			current = i18n.G("active")
+		}
+		fmt.Fprintf(w, "%s.%s\t%s\t%s\n", snapName, svc.Name, startup, current)
+	}
+	w.Flush()
+	c.printf("%s", buf.String())
+	return nil
+}
+
+type byAppName []*snap.AppInfo
+
+func (a byAppName) Len() int           { return len(a) }
+func (a byAppName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byAppName) Less(i, j int) bool { return a[i].Name < a[j].Name }
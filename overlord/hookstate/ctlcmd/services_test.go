@@ -40,6 +40,7 @@ import (
 	"github.com/snapcore/snapd/snap/snaptest"
 	"github.com/snapcore/snapd/store"
 	"github.com/snapcore/snapd/store/storetest"
+	"github.com/snapcore/snapd/systemd"
 	"github.com/snapcore/snapd/testutil"
 )
 
@@ -422,3 +423,85 @@ func (s *servicectlSuite) TestQueuedCommandsSingleLane(c *C) {
 	c.Check(laneTasks[14].Summary(), Equals, "start of [test-snap.test-service]")
 	c.Check(laneTasks[15].Summary(), Equals, "restart of [test-snap.test-service]")
 }
+
+func (s *servicectlSuite) TestServicesCommandUnsupportedAction(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"services", "frobnicate"})
+	c.Check(err, ErrorMatches, `unsupported services action: "frobnicate"`)
+}
+
+func (s *servicectlSuite) TestServicesReloadUnknownService(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"services", "reload", "test-snap.fooservice"})
+	c.Check(err, ErrorMatches, `unknown service: "test-snap.fooservice"`)
+}
+
+func (s *servicectlSuite) TestServicesReloadNothingToReload(c *C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"services", "reload"})
+	c.Check(err, ErrorMatches, `snap "test-snap" has no services to reload on configuration change`)
+}
+
+func (s *servicectlSuite) TestServicesReloadQueued(c *C) {
+	s.st.Lock()
+	chg := s.st.NewChange("configure-snap", "...")
+	task := s.st.NewTask("run-hook", "...")
+	chg.AddTask(task)
+	s.st.Unlock()
+
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: "configure"}
+	context, err := hookstate.NewContext(task, s.st, setup, s.mockHandler, "")
+	c.Assert(err, IsNil)
+
+	_, _, err = ctlcmd.Run(context, []string{"services", "reload", "test-snap.test-service"})
+	c.Assert(err, IsNil)
+
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	tasks := chg.Tasks()
+	c.Assert(tasks, HasLen, 2)
+	c.Check(tasks[1].Kind(), Equals, "reload-snap-services")
+	c.Check(tasks[1].Summary(), Equals, `Reload snap "test-snap" services`)
+	c.Check(tasks[1].WaitTasks(), DeepEquals, []*state.Task{task})
+	var names []string
+	c.Assert(tasks[1].Get("services", &names), IsNil)
+	c.Check(names, DeepEquals, []string{"test-service"})
+}
+
+func (s *servicectlSuite) TestServicesStatus(c *C) {
+	var sysctlArgs [][]string
+	restore := systemd.MockSystemctl(func(args ...string) ([]byte, error) {
+		sysctlArgs = append(sysctlArgs, args)
+		return []byte("Id=snap.test-snap.test-service.service\nType=simple\nActiveState=active\nUnitFileState=enabled\n"), nil
+	})
+	defer restore()
+
+	for _, args := range [][]string{{"services"}, {"services", "status"}, {"services", "status", "test-snap.test-service"}} {
+		sysctlArgs = nil
+		stdout, stderr, err := ctlcmd.Run(s.mockContext, args)
+		c.Assert(err, IsNil)
+		c.Check(string(stdout), Equals, `
+Service                 Startup  Current
+test-snap.test-service  enabled  active
+`[1:])
+		c.Check(string(stderr), Equals, "")
+		c.Check(sysctlArgs, DeepEquals, [][]string{
+			{"show", "--property=Id,Type,ActiveState,UnitFileState", "snap.test-snap.test-service.service"},
+		})
+	}
+}
+
+func (s *servicectlSuite) TestServicesStatusOtherSnap(c *C) {
+	// only the services of the snap of the context can be queried
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"services", "status", "other-snap.test-service"})
+	c.Check(err, ErrorMatches, `unknown service: "other-snap.test-service"`)
+}
+
+func (s *servicectlSuite) TestServicesStatusNeedsAction(c *C) {
+	// service names are not mistaken for actions
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"services", "test-snap.test-service"})
+	c.Check(err, ErrorMatches, `unsupported services action: "test-snap.test-service"`)
+}
+
+func (s *servicectlSuite) TestServicesStatusWithoutContext(c *C) {
+	_, _, err := ctlcmd.Run(nil, []string{"services"})
+	c.Check(err, ErrorMatches, "cannot query services without a context")
+}
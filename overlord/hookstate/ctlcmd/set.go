@@ -27,6 +27,8 @@ import (
 	"github.com/snapcore/snapd/jsonutil"
 	"github.com/snapcore/snapd/overlord/configstate"
 	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/state"
 )
 
 type setCommand struct {
@@ -55,6 +57,12 @@ Plug and slot attributes may be set in the respective prepare and connect hooks
 naming the respective plug or slot:
 
     $ snapctl set :myplug path=/dev/ttyS0
+
+Outside of interface hooks, the attributes of a connected plug or slot are
+updated in all of its connections, and the connected snaps are notified via
+their changed-plug-<plug> or changed-slot-<slot> hooks:
+
+    $ snapctl set :myslot port=8080
 `)
 
 func init() {
@@ -113,9 +121,61 @@ func (s *setCommand) setConfigSetting(context *hookstate.Context) error {
 	return nil
 }
 
+func parseAttributes(attrValues []string) (map[string]interface{}, error) {
+	attributes := make(map[string]interface{})
+	for _, attrValue := range attrValues {
+		parts := strings.SplitN(attrValue, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf(i18n.G("invalid parameter: %q (want key=value)"), attrValue)
+		}
+
+		var value interface{}
+		if err := jsonutil.DecodeWithNumber(strings.NewReader(parts[1]), &value); err != nil {
+			// Not valid JSON, save the string as-is
+			value = parts[1]
+		}
+		attributes[parts[0]] = value
+	}
+	return attributes, nil
+}
+
+// setConnectionAttributes sets dynamic attributes of the established
+// connections of the given plug or slot.
+func (s *setCommand) setConnectionAttributes(context *hookstate.Context, plugOrSlot string) error {
+	attributes, err := parseAttributes(s.Positional.ConfValues)
+	if err != nil {
+		return err
+	}
+
+	st := context.State()
+	st.Lock()
+	// passing context so we can ignore self-conflicts with the current change
+	ts, err := ifacestate.SetAttributes(st, context.SnapName(), plugOrSlot, attributes, context)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if !context.IsEphemeral() && context.HookName() == "configure" {
+		return queueCommand(context, []*state.TaskSet{ts})
+	}
+
+	st.Lock()
+	defer st.Unlock()
+	chg := st.NewChange("set-attrs", fmt.Sprintf("Set attributes of %s:%s", context.SnapName(), plugOrSlot))
+	chg.AddAll(ts)
+	st.EnsureBefore(0)
+	return nil
+}
+
 func (s *setCommand) setInterfaceSetting(context *hookstate.Context, plugOrSlot string) error {
+	hookType, err := interfaceHookType(context.HookName())
+	if err != nil {
+		// outside of interface hooks the attributes of the
+		// established connections are updated
+		return s.setConnectionAttributes(context, plugOrSlot)
+	}
 	// Make sure set :<plug|slot> is only supported during the execution of prepare-[plug|slot] hooks
-	hookType, _ := interfaceHookType(context.HookName())
 	if hookType != preparePlugHook && hookType != prepareSlotHook {
 		return fmt.Errorf(i18n.G("interface attributes can only be set during the execution of prepare hooks"))
 	}
@@ -146,18 +206,12 @@ func (s *setCommand) setInterfaceSetting(context *hookstate.Context, plugOrSlot
 		return fmt.Errorf(i18n.G("internal error: cannot get %s from appropriate task"), which)
 	}
 
-	for _, attrValue := range s.Positional.ConfValues {
-		parts := strings.SplitN(attrValue, "=", 2)
-		if len(parts) != 2 {
-			return fmt.Errorf(i18n.G("invalid parameter: %q (want key=value)"), attrValue)
-		}
-
-		var value interface{}
-		if err := jsonutil.DecodeWithNumber(strings.NewReader(parts[1]), &value); err != nil {
-			// Not valid JSON, save the string as-is
-			value = parts[1]
-		}
-		attributes[parts[0]] = value
+	newAttributes, err := parseAttributes(s.Positional.ConfValues)
+	if err != nil {
+		return err
+	}
+	for key, value := range newAttributes {
+		attributes[key] = value
 	}
 
 	attrsTask.Set(which, attributes)
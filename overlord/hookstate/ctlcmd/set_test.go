@@ -22,13 +22,17 @@ package ctlcmd_test
 import (
 	"encoding/json"
 
+	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
 	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
 
 	. "gopkg.in/check.v1"
 )
@@ -44,8 +48,14 @@ type setAttrSuite struct {
 	mockHandler         *hooktest.MockHandler
 }
 
+type setConnAttrSuite struct {
+	testutil.BaseTest
+	st *state.State
+}
+
 var _ = Suite(&setSuite{})
 var _ = Suite(&setAttrSuite{})
+var _ = Suite(&setConnAttrSuite{})
 
 func (s *setSuite) SetUpTest(c *C) {
 	s.mockHandler = hooktest.NewMockHandler()
@@ -68,7 +78,7 @@ func (s *setSuite) TestInvalidArguments(c *C) {
 	_, _, err = ctlcmd.Run(s.mockContext, []string{"set", "foo", "bar"})
 	c.Check(err, ErrorMatches, ".*invalid parameter.*want key=value.*")
 	_, _, err = ctlcmd.Run(s.mockContext, []string{"set", ":foo", "bar=baz"})
-	c.Check(err, ErrorMatches, ".*interface attributes can only be set during the execution of prepare hooks.*")
+	c.Check(err, ErrorMatches, `snap "test-snap" is not installed`)
 }
 
 func (s *setSuite) TestCommand(c *C) {
@@ -269,7 +279,7 @@ func (s *setAttrSuite) TestSetCommandFailsOutsideOfValidContext(c *C) {
 	defer state.Unlock()
 
 	task := state.NewTask("test-task", "my test task")
-	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: "not-a-connect-hook"}
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: "connect-plug-aplug"}
 	mockContext, err = hookstate.NewContext(task, task.State(), setup, s.mockHandler, "")
 	c.Assert(err, IsNil)
 
@@ -279,3 +289,127 @@ func (s *setAttrSuite) TestSetCommandFailsOutsideOfValidContext(c *C) {
 	c.Check(string(stdout), Equals, "")
 	c.Check(string(stderr), Equals, "")
 }
+
+const producerYaml = `name: producer
+version: 1.0
+slots:
+  slot:
+    interface: content
+    content: data
+`
+
+const consumerYaml = `name: consumer
+version: 1.0
+plugs:
+  plug:
+    interface: content
+    content: data
+`
+
+func (s *setConnAttrSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.BaseTest.AddCleanup(func() { dirs.SetRootDir("/") })
+	s.BaseTest.AddCleanup(snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {}))
+
+	s.st = state.New(nil)
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	for _, snapYaml := range []string{producerYaml, consumerYaml} {
+		info := snaptest.MockSnap(c, snapYaml, &snap.SideInfo{Revision: snap.R(1)})
+		snapstate.Set(s.st, info.Name(), &snapstate.SnapState{
+			Active:   true,
+			Sequence: []*snap.SideInfo{{RealName: info.Name(), Revision: info.Revision}},
+			Current:  info.Revision,
+		})
+	}
+	s.st.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "content"},
+	})
+}
+
+func (s *setConnAttrSuite) TestSetConnectionAttributes(c *C) {
+	setup := &hookstate.HookSetup{Snap: "producer", Revision: snap.R(1)}
+	mockContext, err := hookstate.NewContext(nil, s.st, setup, nil, "")
+	c.Assert(err, IsNil)
+
+	stdout, stderr, err := ctlcmd.Run(mockContext, []string{"set", ":slot", "path=/run/socket", "port=8080"})
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "")
+	c.Check(string(stderr), Equals, "")
+
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	changes := s.st.Changes()
+	c.Assert(changes, HasLen, 1)
+	c.Check(changes[0].Kind(), Equals, "set-attrs")
+	tasks := changes[0].Tasks()
+	c.Assert(tasks, HasLen, 2)
+
+	c.Check(tasks[0].Kind(), Equals, "set-attrs")
+	var attrs map[string]interface{}
+	c.Assert(tasks[0].Get("attrs", &attrs), IsNil)
+	c.Check(attrs, DeepEquals, map[string]interface{}{"path": "/run/socket", "port": float64(8080)})
+	c.Assert(tasks[0].Get("slot-attrs", &attrs), IsNil)
+	c.Check(attrs, DeepEquals, map[string]interface{}{"content": "data", "path": "/run/socket", "port": float64(8080)})
+
+	c.Check(tasks[1].Kind(), Equals, "run-hook")
+	var hooksup hookstate.HookSetup
+	c.Assert(tasks[1].Get("hook-setup", &hooksup), IsNil)
+	c.Check(hooksup, Equals, hookstate.HookSetup{Snap: "consumer", Hook: "changed-plug-plug", Optional: true})
+}
+
+func (s *setConnAttrSuite) TestSetConnectionAttributesQueuedInConfigureHook(c *C) {
+	s.st.Lock()
+	chg := s.st.NewChange("configure", "...")
+	task := s.st.NewTask("run-hook", "...")
+	chg.AddTask(task)
+	s.st.Unlock()
+
+	setup := &hookstate.HookSetup{Snap: "consumer", Revision: snap.R(1), Hook: "configure"}
+	mockContext, err := hookstate.NewContext(task, s.st, setup, nil, "")
+	c.Assert(err, IsNil)
+
+	_, _, err = ctlcmd.Run(mockContext, []string{"set", ":plug", "foo=bar"})
+	c.Assert(err, IsNil)
+
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	c.Assert(s.st.Changes(), HasLen, 1)
+	tasks := chg.Tasks()
+	c.Assert(tasks, HasLen, 3)
+	c.Check(tasks[1].Kind(), Equals, "set-attrs")
+	c.Check(tasks[1].WaitTasks(), DeepEquals, []*state.Task{task})
+
+	var hooksup hookstate.HookSetup
+	c.Assert(tasks[2].Get("hook-setup", &hooksup), IsNil)
+	c.Check(hooksup, Equals, hookstate.HookSetup{Snap: "producer", Hook: "changed-slot-slot", Optional: true})
+}
+
+func (s *setConnAttrSuite) TestSetConnectionAttributesErrors(c *C) {
+	setup := &hookstate.HookSetup{Snap: "producer", Revision: snap.R(1)}
+	mockContext, err := hookstate.NewContext(nil, s.st, setup, nil, "")
+	c.Assert(err, IsNil)
+
+	_, _, err = ctlcmd.Run(mockContext, []string{"set", ":slot", "content=other"})
+	c.Check(err, ErrorMatches, `cannot change attribute "content" as it was statically specified in the snap details`)
+
+	_, _, err = ctlcmd.Run(mockContext, []string{"set", ":unknown", "foo=bar"})
+	c.Check(err, ErrorMatches, `snap "producer" has no plug or slot named "unknown"`)
+
+	_, _, err = ctlcmd.Run(mockContext, []string{"set", ":slot", "foo"})
+	c.Check(err, ErrorMatches, `invalid parameter: "foo" \(want key=value\)`)
+
+	s.st.Lock()
+	s.st.Set("conns", map[string]interface{}{})
+	s.st.Unlock()
+	_, _, err = ctlcmd.Run(mockContext, []string{"set", ":slot", "foo=bar"})
+	c.Check(err, ErrorMatches, `cannot set attributes of producer:slot, it is not connected`)
+
+	s.st.Lock()
+	defer s.st.Unlock()
+	c.Check(s.st.Changes(), HasLen, 0)
+}
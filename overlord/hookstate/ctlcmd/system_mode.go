@@ -0,0 +1,64 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var (
+	shortSystemModeHelp = i18n.G("Print the mode of the system")
+	longSystemModeHelp  = i18n.G(`
+The system-mode command prints whether the seed of the device has been loaded.
+
+    $ snapctl system-mode
+    seed-loaded: true
+`)
+)
+
+func init() {
+	addCommand("system-mode", shortSystemModeHelp, longSystemModeHelp, func() command { return &systemModeCommand{} })
+}
+
+type systemModeCommand struct {
+	baseCommand
+}
+
+func (c *systemModeCommand) Execute(args []string) error {
+	context := c.context()
+	if context == nil {
+		return fmt.Errorf(i18n.G("cannot get the system mode without a context"))
+	}
+
+	st := context.State()
+	st.Lock()
+	var seeded bool
+	err := st.Get("seeded", &seeded)
+	st.Unlock()
+	if err != nil && err != state.ErrNoState {
+		return err
+	}
+
+	c.printf("seed-loaded: %t\n", seeded)
+	return nil
+}
@@ -0,0 +1,76 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ctlcmd_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/hookstate/ctlcmd"
+	"github.com/snapcore/snapd/overlord/hookstate/hooktest"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+type systemModeSuite struct {
+	st          *state.State
+	mockContext *hookstate.Context
+	mockHandler *hooktest.MockHandler
+}
+
+var _ = Suite(&systemModeSuite{})
+
+func (s *systemModeSuite) SetUpTest(c *C) {
+	s.mockHandler = hooktest.NewMockHandler()
+
+	s.st = state.New(nil)
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	task := s.st.NewTask("test-task", "my test task")
+	setup := &hookstate.HookSetup{Snap: "test-snap", Revision: snap.R(1), Hook: "test-hook"}
+
+	var err error
+	s.mockContext, err = hookstate.NewContext(task, s.st, setup, s.mockHandler, "")
+	c.Assert(err, IsNil)
+}
+
+func (s *systemModeSuite) TestSystemModeNotSeeded(c *C) {
+	stdout, stderr, err := ctlcmd.Run(s.mockContext, []string{"system-mode"})
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "seed-loaded: false\n")
+	c.Check(string(stderr), Equals, "")
+}
+
+func (s *systemModeSuite) TestSystemModeSeeded(c *C) {
+	s.st.Lock()
+	s.st.Set("seeded", true)
+	s.st.Unlock()
+
+	stdout, stderr, err := ctlcmd.Run(s.mockContext, []string{"system-mode"})
+	c.Assert(err, IsNil)
+	c.Check(string(stdout), Equals, "seed-loaded: true\n")
+	c.Check(string(stderr), Equals, "")
+}
+
+func (s *systemModeSuite) TestCommandWithoutContext(c *C) {
+	_, _, err := ctlcmd.Run(nil, []string{"system-mode"})
+	c.Check(err, ErrorMatches, "cannot get the system mode without a context")
+}
@@ -37,3 +37,12 @@ func HookTask(st *state.State, summary string, setup *HookSetup, contextData map
 	}
 	return task
 }
+
+// HookTaskWithUndo returns a task that will run the specified hook. On
+// error the undo hook will be executed. Note that the initial context must
+// properly marshal and unmarshal with encoding/json.
+func HookTaskWithUndo(st *state.State, summary string, setup *HookSetup, undo *HookSetup, contextData map[string]interface{}) *state.Task {
+	task := HookTask(st, summary, setup, contextData)
+	task.Set("undo-hook-setup", undo)
+	return task
+}
@@ -198,6 +198,96 @@ func (s *hookManagerSuite) TestHookTask(c *C) {
 	c.Check(setup.Hook, Equals, "configure")
 }
 
+func (s *hookManagerSuite) TestHookTaskWithUndo(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	hooksup := &hookstate.HookSetup{
+		Snap:     "test-snap",
+		Hook:     "configure",
+		Revision: snap.R(1),
+	}
+	undohooksup := &hookstate.HookSetup{
+		Snap:     "test-snap",
+		Hook:     "prepare-device",
+		Revision: snap.R(1),
+		Optional: true,
+	}
+
+	task := hookstate.HookTaskWithUndo(s.state, "test summary", hooksup, undohooksup, nil)
+	c.Check(task.Kind(), Equals, "run-hook")
+
+	var setup hookstate.HookSetup
+	err := task.Get("hook-setup", &setup)
+	c.Check(err, IsNil)
+	c.Check(setup.Hook, Equals, "configure")
+
+	var undosetup hookstate.HookSetup
+	err = task.Get("undo-hook-setup", &undosetup)
+	c.Check(err, IsNil)
+	c.Check(undosetup.Snap, Equals, "test-snap")
+	c.Check(undosetup.Revision, Equals, snap.R(1))
+	c.Check(undosetup.Hook, Equals, "prepare-device")
+	c.Check(undosetup.Optional, Equals, true)
+}
+
+func (s *hookManagerSuite) TestHookTaskUndoRunsUndoHook(c *C) {
+	s.manager.Register(regexp.MustCompile("prepare-device"), func(context *hookstate.Context) hookstate.Handler {
+		return hooktest.NewMockHandler()
+	})
+
+	s.state.Lock()
+	s.task.Set("undo-hook-setup", &hookstate.HookSetup{
+		Snap:     "test-snap",
+		Hook:     "prepare-device",
+		Revision: snap.R(1),
+	})
+	// the hook of a snap that is not installed will fail, triggering
+	// the undo of the previous task
+	failing := hookstate.HookTask(s.state, "failing hook", &hookstate.HookSetup{
+		Snap:     "missing-snap",
+		Hook:     "configure",
+		Revision: snap.R(1),
+	}, nil)
+	failing.WaitFor(s.task)
+	s.change.AddTask(failing)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(s.task.Status(), Equals, state.UndoneStatus)
+	c.Check(failing.Status(), Equals, state.ErrorStatus)
+	c.Check(s.command.Calls(), DeepEquals, [][]string{
+		{"snap", "run", "--hook", "configure", "-r", "1", "test-snap"},
+		{"snap", "run", "--hook", "prepare-device", "-r", "1", "test-snap"},
+	})
+}
+
+func (s *hookManagerSuite) TestHookTaskUndoWithoutUndoHook(c *C) {
+	s.state.Lock()
+	failing := hookstate.HookTask(s.state, "failing hook", &hookstate.HookSetup{
+		Snap:     "missing-snap",
+		Hook:     "configure",
+		Revision: snap.R(1),
+	}, nil)
+	failing.WaitFor(s.task)
+	s.change.AddTask(failing)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(s.task.Status(), Equals, state.UndoneStatus)
+	c.Check(s.command.Calls(), DeepEquals, [][]string{
+		{"snap", "run", "--hook", "configure", "-r", "1", "test-snap"},
+	})
+}
+
 func (s *hookManagerSuite) TestHookTaskEnsure(c *C) {
 	s.manager.Ensure()
 	s.manager.Wait()
@@ -23,11 +23,14 @@ import (
 
 	"gopkg.in/tomb.v2"
 
+	"github.com/snapcore/snapd/overlord/ifacestate/udevmonitor"
 	"github.com/snapcore/snapd/overlord/state"
 )
 
 var (
 	AddImplicitSlots = addImplicitSlots
+	MakeSlotName     = makeSlotName
+	EnsureUniqueName = ensureUniqueName
 )
 
 // AddForeignTaskHandlers registers handlers for tasks handled outside of the
@@ -45,3 +48,9 @@ func MockContentLinkRetryTimeout(d time.Duration) (restore func()) {
 	contentLinkRetryTimeout = d
 	return func() { contentLinkRetryTimeout = old }
 }
+
+func MockCreateUDevMonitor(new func(udevmonitor.DeviceAddedFunc, udevmonitor.DeviceRemovedFunc, udevmonitor.EnumerationDoneFunc) udevmonitor.Interface) (restore func()) {
+	old := createUDevMonitor
+	createUDevMonitor = new
+	return func() { createUDevMonitor = old }
+}
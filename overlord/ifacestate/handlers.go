@@ -29,6 +29,7 @@ import (
 
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/hotplug"
 	"github.com/snapcore/snapd/interfaces/policy"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
@@ -65,6 +66,9 @@ func (m *InterfaceManager) setupAffectedSnaps(task *state.Task, affectingSnap st
 			return err
 		}
 		addImplicitSlots(affectedSnapInfo)
+		if err := addHotplugSlots(st, affectedSnapInfo); err != nil {
+			return err
+		}
 		opts := confinementOptions(snapst.Flags)
 		if err := m.setupSnapSecurity(task, affectedSnapInfo, opts); err != nil {
 			return err
@@ -127,6 +131,9 @@ func (m *InterfaceManager) doSetupProfiles(task *state.Task, tomb *tomb.Tomb) er
 
 func (m *InterfaceManager) setupProfilesForSnap(task *state.Task, _ *tomb.Tomb, snapInfo *snap.Info, opts interfaces.ConfinementOptions) error {
 	addImplicitSlots(snapInfo)
+	if err := addHotplugSlots(task.State(), snapInfo); err != nil {
+		return err
+	}
 	snapName := snapInfo.Name()
 
 	// The snap may have been updated so perform the following operation to
@@ -450,12 +457,95 @@ func (m *InterfaceManager) doConnect(task *state.Task, _ *tomb.Tomb) error {
 		return err
 	}
 
-	conns[connRef.ID()] = connState{Interface: plug.Interface, Auto: autoConnect}
+	if oldconn, ok := conns[connRef.ID()]; ok {
+		// the connection existed already, undo must keep it
+		task.Set("old-conn", oldconn)
+	}
+	task.Set("connected", true)
+	hotplugKey, err := hotplugKeyOfSlot(st, slot)
+	if err != nil {
+		return err
+	}
+	conns[connRef.ID()] = connState{Interface: plug.Interface, Auto: autoConnect, HotplugKey: hotplugKey}
 	setConns(st, conns)
 
 	return nil
 }
 
+// setupSnapSecurityByName sets up the security of the current revision of
+// the given snaps.
+func (m *InterfaceManager) setupSnapSecurityByName(task *state.Task, snapNames ...string) error {
+	st := task.State()
+	for _, snapName := range snapNames {
+		var snapst snapstate.SnapState
+		if err := snapstate.Get(st, snapName, &snapst); err != nil {
+			return err
+		}
+		snapInfo, err := snapst.CurrentInfo()
+		if err != nil {
+			return err
+		}
+		opts := confinementOptions(snapst.Flags)
+		if err := m.setupSnapSecurity(task, snapInfo, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *InterfaceManager) undoConnect(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var connected bool
+	if err := task.Get("connected", &connected); err != nil && err != state.ErrNoState {
+		return err
+	}
+	if !connected {
+		return nil
+	}
+
+	plugRef, slotRef, err := getPlugAndSlotRefs(task)
+	if err != nil {
+		return err
+	}
+
+	conns, err := getConns(st)
+	if err != nil {
+		return err
+	}
+	connRef := interfaces.ConnRef{PlugRef: plugRef, SlotRef: slotRef}
+
+	var oldconn connState
+	err = task.Get("old-conn", &oldconn)
+	switch {
+	case err == nil && !oldconn.HotplugGone:
+		conns[connRef.ID()] = oldconn
+		setConns(st, conns)
+		return nil
+	case err != nil && err != state.ErrNoState:
+		return err
+	}
+
+	if err := m.repo.Disconnect(plugRef.Snap, plugRef.Name, slotRef.Snap, slotRef.Name); err != nil {
+		return err
+	}
+	if err := m.setupSnapSecurityByName(task, slotRef.Snap, plugRef.Snap); err != nil {
+		return err
+	}
+
+	if oldconn.HotplugGone {
+		// the connection of an unplugged device was restored,
+		// it is kept for when the device comes back
+		conns[connRef.ID()] = oldconn
+	} else {
+		delete(conns, connRef.ID())
+	}
+	setConns(st, conns)
+	return nil
+}
+
 func (m *InterfaceManager) doDisconnect(task *state.Task, _ *tomb.Tomb) error {
 	st := task.State()
 	st.Lock()
@@ -500,13 +590,183 @@ func (m *InterfaceManager) doDisconnect(task *state.Task, _ *tomb.Tomb) error {
 		}
 	}
 
+	var hotplugGone bool
+	if err := task.Get("hotplug-gone", &hotplugGone); err != nil && err != state.ErrNoState {
+		return err
+	}
+
 	conn := interfaces.ConnRef{PlugRef: plugRef, SlotRef: slotRef}
-	delete(conns, conn.ID())
+	if oldconn, ok := conns[conn.ID()]; ok {
+		// remember the connection so that undo can restore it
+		task.Set("old-conn", oldconn)
+		if hotplugGone {
+			// the device was unplugged, keep the connection so that
+			// it is restored when the device comes back
+			var hotplugKey string
+			if err := task.Get("hotplug-key", &hotplugKey); err != nil {
+				return err
+			}
+			oldconn.HotplugKey = hotplugKey
+			oldconn.HotplugGone = true
+			conns[conn.ID()] = oldconn
+		}
+	}
+	if !hotplugGone {
+		delete(conns, conn.ID())
+	}
+
+	setConns(st, conns)
+	return nil
+}
+
+func (m *InterfaceManager) undoDisconnect(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var oldconn connState
+	err := task.Get("old-conn", &oldconn)
+	if err == state.ErrNoState {
+		// nothing was disconnected
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	plugRef, slotRef, err := getPlugAndSlotRefs(task)
+	if err != nil {
+		return err
+	}
+
+	conns, err := getConns(st)
+	if err != nil {
+		return err
+	}
+
+	connRef := interfaces.ConnRef{PlugRef: plugRef, SlotRef: slotRef}
+	if err := m.repo.Connect(connRef); err != nil {
+		return err
+	}
+
+	if err := m.setupSnapSecurityByName(task, slotRef.Snap, plugRef.Snap); err != nil {
+		return err
+	}
+
+	conns[connRef.ID()] = oldconn
+	setConns(st, conns)
+	task.Set("old-conn", nil)
+	return nil
+}
+
+func (m *InterfaceManager) doSetAttrs(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	plugRef, slotRef, err := getPlugAndSlotRefs(task)
+	if err != nil {
+		return err
+	}
+	var plugSide bool
+	if err := task.Get("plug-side", &plugSide); err != nil {
+		return err
+	}
+	var attrs map[string]interface{}
+	if err := task.Get("attrs", &attrs); err != nil {
+		return err
+	}
+
+	conns, err := getConns(st)
+	if err != nil {
+		return err
+	}
 
+	connRef := interfaces.ConnRef{PlugRef: plugRef, SlotRef: slotRef}
+	conn, ok := conns[connRef.ID()]
+	if !ok {
+		task.Logf("skipping setting attributes of connection %s %s, it no longer exists", plugRef, slotRef)
+		return nil
+	}
+	oldconn := conn
+
+	if plugSide {
+		conn.PlugDynamic = mergeAttrs(conn.PlugDynamic, attrs)
+	} else {
+		conn.SlotDynamic = mergeAttrs(conn.SlotDynamic, attrs)
+	}
+
+	// the connection must still be allowed with the new attributes
+	plug := m.repo.Plug(plugRef.Snap, plugRef.Name)
+	if plug == nil {
+		return fmt.Errorf("snap %q has no %q plug", plugRef.Snap, plugRef.Name)
+	}
+	slot := m.repo.Slot(slotRef.Snap, slotRef.Name)
+	if slot == nil {
+		return fmt.Errorf("snap %q has no %q slot", slotRef.Snap, slotRef.Name)
+	}
+	plugWithAttrs := interfaces.PlugInfoWithDynamicAttrs(plug, conn.PlugDynamic)
+	slotWithAttrs := interfaces.SlotInfoWithDynamicAttrs(slot, conn.SlotDynamic)
+	if err := checkConnectionPolicy(st, plugWithAttrs, slotWithAttrs, conn.Auto); err != nil {
+		return fmt.Errorf("cannot set attributes of connection %s %s: %v", plugRef, slotRef, err)
+	}
+
+	// the attributes are sanitized by the interface before they are used,
+	// and stored the way they are used
+	conn.PlugDynamic, conn.SlotDynamic, err = m.repo.SetConnectionAttrs(connRef, conn.PlugDynamic, conn.SlotDynamic)
+	if err != nil {
+		return err
+	}
+	// remember the connection so that undo can restore it
+	task.Set("old-conn", oldconn)
+	if err := m.setupSnapSecurityByName(task, slotRef.Snap, plugRef.Snap); err != nil {
+		return err
+	}
+
+	conns[connRef.ID()] = conn
 	setConns(st, conns)
 	return nil
 }
 
+func (m *InterfaceManager) undoSetAttrs(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var oldconn connState
+	err := task.Get("old-conn", &oldconn)
+	if err == state.ErrNoState {
+		// nothing was changed
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	plugRef, slotRef, err := getPlugAndSlotRefs(task)
+	if err != nil {
+		return err
+	}
+
+	conns, err := getConns(st)
+	if err != nil {
+		return err
+	}
+
+	connRef := interfaces.ConnRef{PlugRef: plugRef, SlotRef: slotRef}
+	if _, _, err := m.repo.SetConnectionAttrs(connRef, oldconn.PlugDynamic, oldconn.SlotDynamic); err != nil {
+		return err
+	}
+	if err := m.setupSnapSecurityByName(task, slotRef.Snap, plugRef.Snap); err != nil {
+		return err
+	}
+
+	conns[connRef.ID()] = oldconn
+	setConns(st, conns)
+	task.Set("old-conn", nil)
+	return nil
+}
+
 // timeout for shared content retry
 var contentLinkRetryTimeout = 30 * time.Second
 
@@ -665,31 +925,146 @@ func (m *InterfaceManager) doAutoConnect(task *state.Task, _ *tomb.Tomb) error {
 	}
 
 	task.SetStatus(state.DoneStatus)
+	injectTasks(task, autots)
+
+	st.EnsureBefore(0)
+	return nil
+}
+
+func (m *InterfaceManager) undoAutoConnect(task *state.Task, _ *tomb.Tomb) error {
+	// the connect tasks added by auto-connect are undone on their own,
+	// running the disconnect hooks as needed.
+	return nil
+}
 
-	lanes := task.Lanes()
+// injectTasks adds the given tasks to the change of the main task, in the
+// same lanes, and makes all the tasks waiting for the main task wait for
+// them as well.
+func injectTasks(mainTask *state.Task, ts *state.TaskSet) {
+	lanes := mainTask.Lanes()
 	if len(lanes) == 1 && lanes[0] == 0 {
 		lanes = nil
 	}
-	ht := task.HaltTasks()
-
-	// add all connect tasks to the change of main "auto-connect" task and to the same lane.
 	for _, l := range lanes {
-		autots.JoinLane(l)
+		ts.JoinLane(l)
+	}
+	mainTask.Change().AddAll(ts)
+	for _, t := range mainTask.HaltTasks() {
+		t.WaitAll(ts)
+	}
+}
+
+// doAutoDisconnect creates tasks to disconnect all the connections of the
+// snap being removed, giving the disconnect hooks of both sides a chance to
+// run.
+func (m *InterfaceManager) doAutoDisconnect(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	snapsup, err := snapstate.TaskSnapSetup(task)
+	if err != nil {
+		return err
 	}
-	chg.AddAll(autots)
-	// make all halt tasks of the main 'auto-connect' task wait on connect tasks
-	for _, t := range ht {
-		t.WaitAll(autots)
+	snapName := snapsup.Name()
+
+	conns, err := getConns(st)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(conns))
+	for id := range conns {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	autots := state.NewTaskSet()
+	oldConns := make(map[string]connState)
+	for _, id := range ids {
+		connRef, err := interfaces.ParseConnRef(id)
+		if err != nil {
+			return err
+		}
+		if connRef.PlugRef.Snap != snapName && connRef.SlotRef.Snap != snapName {
+			continue
+		}
+
+		ts, err := disconnect(st, connRef.PlugRef.Snap, connRef.PlugRef.Name, connRef.SlotRef.Snap, connRef.SlotRef.Name)
+		if err != nil {
+			// the connection is discarded along with the snap anyway
+			task.Logf("cannot auto-disconnect %s from %s: %s", connRef.PlugRef, connRef.SlotRef, err)
+			continue
+		}
+		autots.AddAll(ts)
+		oldConns[id] = conns[id]
 	}
+	// remember the connections so that undo can restore them
+	task.Set("old-conns", oldConns)
+
+	task.SetStatus(state.DoneStatus)
+	injectTasks(task, autots)
+	// undo must only run once the disconnect tasks are undone
+	autots.WaitFor(task)
 
 	st.EnsureBefore(0)
 	return nil
 }
 
-func (m *InterfaceManager) undoAutoConnect(task *state.Task, _ *tomb.Tomb) error {
-	// TODO Introduce disconnection hooks, and run them here as well to give a chance
-	// for the snap to undo whatever it did when the connection was established.
-	return nil
+// undoAutoDisconnect reconnects the connections removed by the tasks
+// injected by doAutoDisconnect which were not restored by undoing those.
+func (m *InterfaceManager) undoAutoDisconnect(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var oldConns map[string]connState
+	err := task.Get("old-conns", &oldConns)
+	if err == state.ErrNoState {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	conns, err := getConns(st)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(oldConns))
+	for id := range oldConns {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	affected := make(map[string]bool)
+	for _, id := range ids {
+		if _, ok := conns[id]; ok {
+			// restored already
+			continue
+		}
+		connRef, err := interfaces.ParseConnRef(id)
+		if err != nil {
+			return err
+		}
+		if err := m.repo.Connect(connRef); err != nil {
+			task.Logf("cannot restore connection %s: %s", id, err)
+			continue
+		}
+		conns[id] = oldConns[id]
+		affected[connRef.PlugRef.Snap] = true
+		affected[connRef.SlotRef.Snap] = true
+	}
+	setConns(st, conns)
+	task.Set("old-conns", nil)
+
+	affectedSnaps := make([]string, 0, len(affected))
+	for name := range affected {
+		affectedSnaps = append(affectedSnaps, name)
+	}
+	sort.Strings(affectedSnaps)
+	return m.setupSnapSecurityByName(task, affectedSnaps...)
 }
 
 // transitionConnectionsCoreMigration will transition all connections
@@ -763,3 +1138,307 @@ func (m *InterfaceManager) undoTransitionUbuntuCore(t *state.Task, _ *tomb.Tomb)
 
 	return m.transitionConnectionsCoreMigration(st, newName, oldName)
 }
+
+// hotplugTaskSetup returns the device and the slot definition a hotplug
+// task operates on, along with the core snap hosting the slot.
+func hotplugTaskSetup(task *state.Task) (dev hotplugDevice, slots map[string]*hotplugSlotDef, coreSnapInfo *snap.Info, err error) {
+	st := task.State()
+	if err := getHotplugAttrs(task, &dev); err != nil {
+		return dev, nil, nil, err
+	}
+	slots, err = getHotplugSlots(st)
+	if err != nil {
+		return dev, nil, nil, err
+	}
+	coreSnapInfo, err = snapstate.CoreInfo(st)
+	if err != nil {
+		return dev, nil, nil, fmt.Errorf("cannot find the core snap: %v", err)
+	}
+	return dev, slots, coreSnapInfo, nil
+}
+
+func (m *InterfaceManager) doHotplugAddSlot(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	dev, slots, coreSnapInfo, err := hotplugTaskSetup(task)
+	if err != nil {
+		return err
+	}
+	var slotSpec hotplug.RequestedSlotSpec
+	if err := task.Get("slot-spec", &slotSpec); err != nil {
+		return fmt.Errorf("cannot obtain slot specification from task %q: %v", task.ID(), err)
+	}
+	iface := m.repo.Interface(dev.iface)
+	if iface == nil {
+		return fmt.Errorf("internal error: cannot find interface %q", dev.iface)
+	}
+
+	def := findHotplugSlot(slots, dev.iface, dev.key)
+	if def != nil && !def.HotplugGone {
+		task.Logf("slot %q for the device is already present", def.Name)
+		return nil
+	}
+	if def != nil {
+		// the device was plugged in again, the slot keeps its name
+		oldDef := *def
+		task.Set("old-slot-def", &oldDef)
+	} else {
+		coreName := coreSnapInfo.Name()
+		name := ensureUniqueName(slotSpec.Name, func(name string) bool {
+			_, ok := slots[name]
+			return ok || m.repo.Slot(coreName, name) != nil || m.repo.Plug(coreName, name) != nil
+		})
+		def = &hotplugSlotDef{
+			Name:       name,
+			Interface:  dev.iface,
+			HotplugKey: dev.key,
+		}
+	}
+	def.Label = slotSpec.Label
+	def.StaticAttrs = slotSpec.Attrs
+	def.HotplugGone = false
+
+	slot := hotplugSlotInfo(coreSnapInfo, def)
+	if err := interfaces.BeforePrepareSlot(iface, slot); err != nil {
+		return fmt.Errorf("cannot create slot %q for hotplugged device: %v", def.Name, err)
+	}
+	if err := m.repo.AddSlot(slot); err != nil {
+		return err
+	}
+	slots[def.Name] = def
+	setHotplugSlots(st, slots)
+	task.Set("slot-name", def.Name)
+	return nil
+}
+
+func (m *InterfaceManager) undoHotplugAddSlot(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var slotName string
+	if err := task.Get("slot-name", &slotName); err != nil {
+		if err == state.ErrNoState {
+			// the slot was there already
+			return nil
+		}
+		return err
+	}
+	_, slots, coreSnapInfo, err := hotplugTaskSetup(task)
+	if err != nil {
+		return err
+	}
+	if err := m.repo.RemoveSlot(coreSnapInfo.Name(), slotName); err != nil {
+		return err
+	}
+	var oldDef hotplugSlotDef
+	err = task.Get("old-slot-def", &oldDef)
+	switch err {
+	case nil:
+		slots[slotName] = &oldDef
+	case state.ErrNoState:
+		delete(slots, slotName)
+	default:
+		return err
+	}
+	setHotplugSlots(st, slots)
+	return nil
+}
+
+// doHotplugConnect creates tasks to restore the connections a hotplugged
+// device had when it was unplugged and to auto-connect its slot according
+// to the policy, giving the connect hooks of both sides a chance to run.
+func (m *InterfaceManager) doHotplugConnect(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	dev, slots, coreSnapInfo, err := hotplugTaskSetup(task)
+	if err != nil {
+		return err
+	}
+	def := findHotplugSlot(slots, dev.iface, dev.key)
+	if def == nil || def.HotplugGone {
+		return fmt.Errorf("internal error: cannot find slot of interface %q for hotplug key %q", dev.iface, dev.key)
+	}
+	conns, err := getConns(st)
+	if err != nil {
+		return err
+	}
+
+	// the connections to make, along with whether they are automatic
+	connRefs := make(map[string]interfaces.ConnRef)
+	auto := make(map[string]bool)
+	for id, cstate := range conns {
+		if !cstate.HotplugGone || cstate.Interface != dev.iface || cstate.HotplugKey != dev.key {
+			continue
+		}
+		connRef, err := interfaces.ParseConnRef(id)
+		if err != nil {
+			return err
+		}
+		connRefs[id] = connRef
+		auto[id] = cstate.Auto
+	}
+
+	autochecker, err := newAutoConnectChecker(st)
+	if err != nil {
+		return err
+	}
+	slot := m.repo.Slot(coreSnapInfo.Name(), def.Name)
+	if slot == nil {
+		return fmt.Errorf("internal error: slot %q is not in the repository", def.Name)
+	}
+	for _, plug := range m.repo.AutoConnectCandidatePlugs(coreSnapInfo.Name(), def.Name, autochecker.check) {
+		connRef := interfaces.NewConnRef(plug, slot)
+		id := connRef.ID()
+		if _, ok := conns[id]; ok {
+			continue
+		}
+		// make sure slot is the only viable connection for plug, same
+		// check as when auto-connecting the plug of an installed snap
+		candSlots := m.repo.AutoConnectCandidateSlots(plug.Snap.Name(), plug.Name, autochecker.check)
+		if len(candSlots) != 1 || candSlots[0].String() != slot.String() {
+			crefs := make([]string, len(candSlots))
+			for i, candidate := range candSlots {
+				crefs[i] = candidate.String()
+			}
+			task.Logf("cannot auto-connect slot %s to %s, candidates found: %s", slot, plug, strings.Join(crefs, ", "))
+			continue
+		}
+		connRefs[id] = *connRef
+		auto[id] = true
+	}
+
+	ids := make([]string, 0, len(connRefs))
+	for id := range connRefs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	hotplugts := state.NewTaskSet()
+	for _, id := range ids {
+		connRef := connRefs[id]
+		ts, err := connect(st, task.Change(), connRef.PlugRef.Snap, connRef.PlugRef.Name, connRef.SlotRef.Snap, connRef.SlotRef.Name, nil)
+		if err != nil {
+			task.Logf("cannot connect %s to %s: %s", connRef.PlugRef, connRef.SlotRef, err)
+			continue
+		}
+		for _, t := range ts.Tasks() {
+			if t.Kind() == "connect" {
+				t.Set("auto", auto[id])
+			}
+		}
+		hotplugts.AddAll(ts)
+	}
+
+	task.SetStatus(state.DoneStatus)
+	injectTasks(task, hotplugts)
+	// the slot can only be removed again once the connect tasks are undone
+	hotplugts.WaitFor(task)
+
+	st.EnsureBefore(0)
+	return nil
+}
+
+// doHotplugDisconnect creates tasks to disconnect the slot of an unplugged
+// device, giving the disconnect hooks of both sides a chance to run. The
+// connections are kept in the state so that they are restored when the
+// device is plugged in again.
+func (m *InterfaceManager) doHotplugDisconnect(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	dev, slots, coreSnapInfo, err := hotplugTaskSetup(task)
+	if err != nil {
+		return err
+	}
+	def := findHotplugSlot(slots, dev.iface, dev.key)
+	if def == nil || def.HotplugGone {
+		return nil
+	}
+	connRefs, err := m.repo.Connected(coreSnapInfo.Name(), def.Name)
+	if err != nil {
+		return err
+	}
+
+	hotplugts := state.NewTaskSet()
+	for _, connRef := range connRefs {
+		ts, err := disconnect(st, connRef.PlugRef.Snap, connRef.PlugRef.Name, connRef.SlotRef.Snap, connRef.SlotRef.Name)
+		if err != nil {
+			return fmt.Errorf("cannot disconnect %s from %s: %v", connRef.PlugRef, connRef.SlotRef, err)
+		}
+		for _, t := range ts.Tasks() {
+			if t.Kind() == "disconnect" {
+				t.Set("hotplug-gone", true)
+				t.Set("hotplug-key", dev.key)
+			}
+		}
+		hotplugts.AddAll(ts)
+	}
+
+	task.SetStatus(state.DoneStatus)
+	injectTasks(task, hotplugts)
+
+	st.EnsureBefore(0)
+	return nil
+}
+
+func (m *InterfaceManager) doHotplugRemoveSlot(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	dev, slots, coreSnapInfo, err := hotplugTaskSetup(task)
+	if err != nil {
+		return err
+	}
+	def := findHotplugSlot(slots, dev.iface, dev.key)
+	if def == nil || def.HotplugGone {
+		return nil
+	}
+	if m.repo.Slot(coreSnapInfo.Name(), def.Name) != nil {
+		if err := m.repo.RemoveSlot(coreSnapInfo.Name(), def.Name); err != nil {
+			return err
+		}
+	}
+	def.HotplugGone = true
+	setHotplugSlots(st, slots)
+	task.Set("slot-name", def.Name)
+	return nil
+}
+
+func (m *InterfaceManager) undoHotplugRemoveSlot(task *state.Task, _ *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var slotName string
+	if err := task.Get("slot-name", &slotName); err != nil {
+		if err == state.ErrNoState {
+			// the slot was gone already
+			return nil
+		}
+		return err
+	}
+	_, slots, coreSnapInfo, err := hotplugTaskSetup(task)
+	if err != nil {
+		return err
+	}
+	def, ok := slots[slotName]
+	if !ok {
+		return fmt.Errorf("internal error: cannot find hotplug slot %q", slotName)
+	}
+	if m.repo.Slot(coreSnapInfo.Name(), slotName) == nil {
+		if err := m.repo.AddSlot(hotplugSlotInfo(coreSnapInfo, def)); err != nil {
+			return err
+		}
+	}
+	def.HotplugGone = false
+	setHotplugSlots(st, slots)
+	task.Set("slot-name", nil)
+	return nil
+}
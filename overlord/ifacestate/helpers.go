@@ -106,6 +106,9 @@ func (m *InterfaceManager) addSnaps() error {
 	}
 	for _, snapInfo := range snaps {
 		addImplicitSlots(snapInfo)
+		if err := addHotplugSlots(m.state, snapInfo); err != nil {
+			return err
+		}
 		if err := m.repo.AddSnap(snapInfo); err != nil {
 			logger.Noticef("%s", err)
 		}
@@ -142,9 +145,12 @@ func (m *InterfaceManager) regenerateAllSecurityProfiles() error {
 	if err != nil {
 		return err
 	}
-	// Add implicit slots to all snaps
+	// Add implicit and hotplug slots to all snaps
 	for _, snapInfo := range snaps {
 		addImplicitSlots(snapInfo)
+		if err := addHotplugSlots(m.state, snapInfo); err != nil {
+			return err
+		}
 	}
 
 	// For each snap:
@@ -217,7 +223,7 @@ func (m *InterfaceManager) reloadConnections(snapName string) ([]string, error)
 		return nil, err
 	}
 	affected := make(map[string]bool)
-	for id := range conns {
+	for id, conn := range conns {
 		connRef, err := interfaces.ParseConnRef(id)
 		if err != nil {
 			return nil, err
@@ -225,8 +231,15 @@ func (m *InterfaceManager) reloadConnections(snapName string) ([]string, error)
 		if snapName != "" && connRef.PlugRef.Snap != snapName && connRef.SlotRef.Snap != snapName {
 			continue
 		}
+		if conn.HotplugGone {
+			continue
+		}
 		if err := m.repo.Connect(connRef); err != nil {
 			logger.Noticef("%s", err)
+		} else if conn.PlugDynamic != nil || conn.SlotDynamic != nil {
+			if _, _, err := m.repo.SetConnectionAttrs(connRef, conn.PlugDynamic, conn.SlotDynamic); err != nil {
+				logger.Noticef("%s", err)
+			}
 		}
 		affected[connRef.PlugRef.Snap] = true
 		affected[connRef.SlotRef.Snap] = true
@@ -271,6 +284,14 @@ func (m *InterfaceManager) removeSnapSecurity(task *state.Task, snapName string)
 type connState struct {
 	Auto      bool   `json:"auto,omitempty"`
 	Interface string `json:"interface,omitempty"`
+	// dynamic attributes of the plug and slot, set by the snaps at runtime
+	PlugDynamic map[string]interface{} `json:"plug-dynamic,omitempty"`
+	SlotDynamic map[string]interface{} `json:"slot-dynamic,omitempty"`
+	// HotplugKey identifies the device of a slot created by hotplug
+	HotplugKey string `json:"hotplug-key,omitempty"`
+	// HotplugGone is set while the device of the slot is unplugged, the
+	// connection is restored when the device comes back
+	HotplugGone bool `json:"hotplug-gone,omitempty"`
 }
 
 type autoConnectChecker struct {
@@ -337,6 +358,42 @@ func (c *autoConnectChecker) check(plug *interfaces.Plug, slot *interfaces.Slot)
 	return ic.CheckAutoConnect() == nil
 }
 
+// checkConnectionPolicy checks the plug and slot of an established
+// connection, with their dynamic attributes in place, against the rules of
+// the snap declarations and of the base declaration.
+func checkConnectionPolicy(st *state.State, plug *snap.PlugInfo, slot *snap.SlotInfo, autoConnect bool) error {
+	// if either of plug or slot snaps don't have a declaration it
+	// means they were installed with "dangerous", so the security
+	// check is skipped, as when connecting
+	if plug.Snap.SnapID == "" || slot.Snap.SnapID == "" {
+		return nil
+	}
+	plugDecl, err := assertstate.SnapDeclaration(st, plug.Snap.SnapID)
+	if err != nil {
+		return fmt.Errorf("cannot find snap declaration for %q: %v", plug.Snap.Name(), err)
+	}
+	slotDecl, err := assertstate.SnapDeclaration(st, slot.Snap.SnapID)
+	if err != nil {
+		return fmt.Errorf("cannot find snap declaration for %q: %v", slot.Snap.Name(), err)
+	}
+	baseDecl, err := assertstate.BaseDeclaration(st)
+	if err != nil {
+		return fmt.Errorf("internal error: cannot find base declaration: %v", err)
+	}
+
+	ic := policy.ConnectCandidate{
+		Plug:                plug,
+		PlugSnapDeclaration: plugDecl,
+		Slot:                slot,
+		SlotSnapDeclaration: slotDecl,
+		BaseDeclaration:     baseDecl,
+	}
+	if autoConnect {
+		return ic.CheckAutoConnect()
+	}
+	return ic.Check()
+}
+
 func getPlugAndSlotRefs(task *state.Task) (interfaces.PlugRef, interfaces.SlotRef, error) {
 	var plugRef interfaces.PlugRef
 	var slotRef interfaces.SlotRef
@@ -0,0 +1,428 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package ifacestate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/hotplug"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/ifacestate/udevmonitor"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+var createUDevMonitor = udevmonitor.New
+
+// hotplugSlotDef is the definition of a slot created on the core snap for
+// a hotplugged device, as kept in the state under "hotplug-slots".
+type hotplugSlotDef struct {
+	Name        string                 `json:"name"`
+	Interface   string                 `json:"interface"`
+	Label       string                 `json:"label,omitempty"`
+	StaticAttrs map[string]interface{} `json:"static-attrs,omitempty"`
+	HotplugKey  string                 `json:"hotplug-key"`
+	// HotplugGone is set when the device was unplugged; the definition is
+	// kept so that the slot and its connections are restored with the
+	// same name when the device comes back.
+	HotplugGone bool `json:"hotplug-gone,omitempty"`
+}
+
+// hotplugDevice identifies the slot of a given interface created for a
+// hotplugged device.
+type hotplugDevice struct {
+	iface string
+	key   string
+}
+
+func getHotplugSlots(st *state.State) (map[string]*hotplugSlotDef, error) {
+	var slots map[string]*hotplugSlotDef
+	err := st.Get("hotplug-slots", &slots)
+	if err != nil && err != state.ErrNoState {
+		return nil, fmt.Errorf("cannot obtain data about hotplug slots: %v", err)
+	}
+	if slots == nil {
+		slots = make(map[string]*hotplugSlotDef)
+	}
+	return slots, nil
+}
+
+func setHotplugSlots(st *state.State, slots map[string]*hotplugSlotDef) {
+	st.Set("hotplug-slots", slots)
+}
+
+func findHotplugSlot(slots map[string]*hotplugSlotDef, ifaceName, hotplugKey string) *hotplugSlotDef {
+	for _, def := range slots {
+		if def.Interface == ifaceName && def.HotplugKey == hotplugKey {
+			return def
+		}
+	}
+	return nil
+}
+
+// hotplugKeyOfSlot returns the hotplug key of the device the given slot was
+// created for, or an empty string if it's a regular slot.
+func hotplugKeyOfSlot(st *state.State, slot *snap.SlotInfo) (string, error) {
+	if slot.Snap.Type != snap.TypeOS {
+		return "", nil
+	}
+	slots, err := getHotplugSlots(st)
+	if err != nil {
+		return "", err
+	}
+	if def, ok := slots[slot.Name]; ok {
+		return def.HotplugKey, nil
+	}
+	return "", nil
+}
+
+func hotplugSlotInfo(snapInfo *snap.Info, def *hotplugSlotDef) *snap.SlotInfo {
+	return &snap.SlotInfo{
+		Snap:      snapInfo,
+		Name:      def.Name,
+		Label:     def.Label,
+		Interface: def.Interface,
+		Attrs:     def.StaticAttrs,
+	}
+}
+
+// addHotplugSlots adds the slots of the hotplugged devices currently
+// present to the given core snap.
+func addHotplugSlots(st *state.State, snapInfo *snap.Info) error {
+	if snapInfo.Type != snap.TypeOS {
+		return nil
+	}
+	slots, err := getHotplugSlots(st)
+	if err != nil {
+		return err
+	}
+	for name, def := range slots {
+		if def.HotplugGone {
+			continue
+		}
+		if _, ok := snapInfo.Slots[name]; ok {
+			logger.Noticef("cannot add hotplug slot %q to snap %q, name already in use", name, snapInfo.Name())
+			continue
+		}
+		snapInfo.Slots[name] = hotplugSlotInfo(snapInfo, def)
+	}
+	return nil
+}
+
+// hotplugEnabled tells whether hotplug support was enabled with the
+// "experimental.hotplug" core option.
+func (m *InterfaceManager) hotplugEnabled() (bool, error) {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	tr := config.NewTransaction(m.state)
+	var enabled bool
+	if err := tr.GetMaybe("core", "experimental.hotplug", &enabled); err != nil {
+		return false, err
+	}
+	return enabled, nil
+}
+
+// ensureUDevMonitor starts monitoring udev events once hotplug support is
+// enabled. If the monitor cannot be started, hotplug support stays disabled
+// until snapd restarts.
+func (m *InterfaceManager) ensureUDevMonitor() error {
+	if m.udevMon != nil || m.udevMonFailed {
+		return nil
+	}
+	enabled, err := m.hotplugEnabled()
+	if err != nil || !enabled {
+		return err
+	}
+
+	mon := createUDevMonitor(m.hotplugDeviceAdded, m.hotplugDeviceRemoved, m.hotplugEnumerationDone)
+	m.state.Lock()
+	m.enumeratedDevices = make(map[hotplugDevice]bool)
+	m.state.Unlock()
+	if err := mon.Connect(); err != nil {
+		m.udevMonFailed = true
+		return err
+	}
+	if err := mon.Run(); err != nil {
+		m.udevMonFailed = true
+		return err
+	}
+	m.udevMon = mon
+	return nil
+}
+
+func (m *InterfaceManager) hotplugDeviceKey(iface interfaces.Interface, devinfo *hotplug.HotplugDeviceInfo) (string, error) {
+	if keyHandler, ok := iface.(hotplug.KeyHandler); ok {
+		return keyHandler.HotplugKey(devinfo)
+	}
+	return hotplug.DefaultDeviceKey(devinfo), nil
+}
+
+// hotplugDeviceAdded is called by the udev monitor when a device is added
+// to the system; it creates changes adding the slots requested by the
+// hotplug interfaces for the device.
+func (m *InterfaceManager) hotplugDeviceAdded(devinfo *hotplug.HotplugDeviceInfo) {
+	st := m.state
+	st.Lock()
+	defer st.Unlock()
+
+	slots, err := getHotplugSlots(st)
+	if err != nil {
+		logger.Noticef("%v", err)
+		return
+	}
+
+	devPath := devinfo.DevicePath()
+	for _, iface := range m.repo.AllInterfaces() {
+		definer, ok := iface.(hotplug.Definer)
+		if !ok {
+			continue
+		}
+		spec := hotplug.NewSpecification()
+		if err := definer.HotplugDeviceDetected(devinfo, spec); err != nil {
+			logger.Noticef("cannot process hotplug event of device %s by the %s interface: %v", devinfo, iface.Name(), err)
+			continue
+		}
+		slotSpec := spec.Slot()
+		if slotSpec == nil {
+			continue
+		}
+		key, err := m.hotplugDeviceKey(iface, devinfo)
+		if err != nil {
+			logger.Noticef("cannot compute hotplug key of device %s for the %s interface: %v", devinfo, iface.Name(), err)
+			continue
+		}
+		if key == "" {
+			logger.Debugf("device %s cannot be identified reliably, ignoring it", devinfo)
+			continue
+		}
+
+		dev := hotplugDevice{iface: iface.Name(), key: key}
+		m.hotplugDevicePaths[devPath] = append(m.hotplugDevicePaths[devPath], dev)
+		if m.enumeratedDevices != nil {
+			m.enumeratedDevices[dev] = true
+		}
+
+		if slotSpec.Name == "" {
+			slotSpec.Name = suggestedSlotName(devinfo, iface.Name())
+		}
+		if def := findHotplugSlot(slots, iface.Name(), key); def != nil && !def.HotplugGone {
+			if attrsEqual(def.StaticAttrs, slotSpec.Attrs) {
+				// the slot was restored from the state on startup
+				continue
+			}
+			// the attributes of the device changed while snapd wasn't
+			// watching, re-create the slot
+			m.queueHotplugChange(m.hotplugRemoveSlotTasks(dev, devinfo.String()), "hotplug-remove-slot",
+				fmt.Sprintf("Remove slot for device %s of interface %q", devinfo, iface.Name()))
+		}
+		m.queueHotplugChange(m.hotplugAddSlotTasks(dev, devinfo.String(), slotSpec), "hotplug-add-slot",
+			fmt.Sprintf("Create slot for device %s of interface %q", devinfo, iface.Name()))
+	}
+}
+
+// hotplugDeviceRemoved is called by the udev monitor when a device is
+// removed from the system; it creates changes disconnecting and removing
+// the slots of the device, while remembering the connections.
+func (m *InterfaceManager) hotplugDeviceRemoved(devinfo *hotplug.HotplugDeviceInfo) {
+	st := m.state
+	st.Lock()
+	defer st.Unlock()
+
+	devPath := devinfo.DevicePath()
+	devices := m.hotplugDevicePaths[devPath]
+	delete(m.hotplugDevicePaths, devPath)
+
+	for _, dev := range devices {
+		m.queueHotplugChange(m.hotplugRemoveSlotTasks(dev, devinfo.String()), "hotplug-remove-slot",
+			fmt.Sprintf("Remove slot for device %s of interface %q", devinfo, dev.iface))
+	}
+}
+
+// hotplugEnumerationDone is called by the udev monitor once all the devices
+// present on startup were reported; the slots of the devices that were
+// removed while snapd wasn't running are removed.
+func (m *InterfaceManager) hotplugEnumerationDone() {
+	st := m.state
+	st.Lock()
+	defer st.Unlock()
+
+	enumerated := m.enumeratedDevices
+	m.enumeratedDevices = nil
+
+	slots, err := getHotplugSlots(st)
+	if err != nil {
+		logger.Noticef("%v", err)
+		return
+	}
+	names := make([]string, 0, len(slots))
+	for name := range slots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		def := slots[name]
+		dev := hotplugDevice{iface: def.Interface, key: def.HotplugKey}
+		if def.HotplugGone || enumerated[dev] {
+			continue
+		}
+		m.queueHotplugChange(m.hotplugRemoveSlotTasks(dev, fmt.Sprintf("with hotplug key %q", def.HotplugKey)), "hotplug-remove-slot",
+			fmt.Sprintf("Remove slot %q of interface %q for device no longer present", name, def.Interface))
+	}
+}
+
+func (m *InterfaceManager) hotplugAddSlotTasks(dev hotplugDevice, deviceName string, slotSpec *hotplug.RequestedSlotSpec) *state.TaskSet {
+	st := m.state
+	addSlot := st.NewTask("hotplug-add-slot", fmt.Sprintf("Create slot of interface %q for device %s", dev.iface, deviceName))
+	setHotplugAttrs(addSlot, dev)
+	addSlot.Set("slot-spec", slotSpec)
+	connect := st.NewTask("hotplug-connect", fmt.Sprintf("Connect slot of interface %q for device %s", dev.iface, deviceName))
+	setHotplugAttrs(connect, dev)
+	connect.WaitFor(addSlot)
+	return state.NewTaskSet(addSlot, connect)
+}
+
+func (m *InterfaceManager) hotplugRemoveSlotTasks(dev hotplugDevice, deviceName string) *state.TaskSet {
+	st := m.state
+	disconnect := st.NewTask("hotplug-disconnect", fmt.Sprintf("Disconnect slot of interface %q for device %s", dev.iface, deviceName))
+	setHotplugAttrs(disconnect, dev)
+	removeSlot := st.NewTask("hotplug-remove-slot", fmt.Sprintf("Remove slot of interface %q for device %s", dev.iface, deviceName))
+	setHotplugAttrs(removeSlot, dev)
+	removeSlot.WaitFor(disconnect)
+	return state.NewTaskSet(disconnect, removeSlot)
+}
+
+// queueHotplugChange creates a change with the given tasks, making it wait
+// for the pending hotplug changes of the same device so that they are
+// processed in the order the events were received.
+func (m *InterfaceManager) queueHotplugChange(ts *state.TaskSet, kind, summary string) {
+	st := m.state
+	var dev hotplugDevice
+	if err := getHotplugAttrs(ts.Tasks()[0], &dev); err != nil {
+		logger.Noticef("internal error: %v", err)
+		return
+	}
+	for _, chg := range st.Changes() {
+		if chg.Status().Ready() || !strings.HasPrefix(chg.Kind(), "hotplug-") {
+			continue
+		}
+		var other hotplugDevice
+		if err := getHotplugAttrs(chg.Tasks()[0], &other); err != nil || other != dev {
+			continue
+		}
+		for _, t := range ts.Tasks() {
+			t.WaitAll(state.NewTaskSet(chg.Tasks()...))
+		}
+	}
+	chg := st.NewChange(kind, summary)
+	chg.AddAll(ts)
+	st.EnsureBefore(0)
+}
+
+func setHotplugAttrs(task *state.Task, dev hotplugDevice) {
+	task.Set("interface", dev.iface)
+	task.Set("hotplug-key", dev.key)
+}
+
+func getHotplugAttrs(task *state.Task, dev *hotplugDevice) error {
+	if err := task.Get("interface", &dev.iface); err != nil {
+		return fmt.Errorf("cannot obtain interface name from task %q: %v", task.ID(), err)
+	}
+	if err := task.Get("hotplug-key", &dev.key); err != nil {
+		return fmt.Errorf("cannot obtain hotplug key from task %q: %v", task.ID(), err)
+	}
+	return nil
+}
+
+// attrsEqual compares slot attributes, regardless of whether they went
+// through the state or not.
+func attrsEqual(a, b map[string]interface{}) bool {
+	aJSON, errA := json.Marshal(a)
+	bJSON, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(aJSON, bJSON)
+}
+
+// suggestedSlotName returns the name to use for the slot of a device, when
+// the interface doesn't suggest any.
+func suggestedSlotName(devinfo *hotplug.HotplugDeviceInfo, fallbackName string) string {
+	for _, attr := range []string{"ID_MODEL_FROM_DATABASE", "ID_MODEL"} {
+		if val, ok := devinfo.Attribute(attr); ok {
+			if name := makeSlotName(val); name != "" {
+				return name
+			}
+		}
+	}
+	return fallbackName
+}
+
+// makeSlotName turns an arbitrary string into a valid slot name, e.g.
+// "CP2102 USB to UART" becomes "cp2102-usb-to-uart". Long names are cut at
+// a word boundary. An empty string is returned if no valid name can be made.
+func makeSlotName(s string) string {
+	const maxLen = 20
+	var out []rune
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		isAlnum := r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))
+		switch {
+		case isAlnum && (len(out) > 0 || unicode.IsLetter(r)):
+			if dash {
+				out = append(out, '-')
+				dash = false
+			}
+			out = append(out, r)
+		case !isAlnum && len(out) > 0:
+			dash = true
+		}
+	}
+	if len(out) > maxLen {
+		cut := maxLen
+		if out[maxLen] != '-' {
+			for cut > 0 && out[cut] != '-' {
+				cut--
+			}
+			if cut == 0 {
+				cut = maxLen
+			}
+		}
+		out = out[:cut]
+	}
+	return string(out)
+}
+
+// ensureUniqueName returns the given name, with a numeric suffix if needed
+// to make it different from all the names already taken.
+func ensureUniqueName(name string, taken func(string) bool) string {
+	if !taken(name) {
+		return name
+	}
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s-%d", name, i)
+		if !taken(candidate) {
+			return candidate
+		}
+	}
+}
@@ -0,0 +1,547 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package ifacestate_test
+
+import (
+	"fmt"
+	"strconv"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces"
+	"github.com/snapcore/snapd/interfaces/hotplug"
+	"github.com/snapcore/snapd/interfaces/ifacetest"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/ifacestate/udevmonitor"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type hotplugTestInterface struct {
+	ifacetest.TestInterface
+}
+
+func (iface *hotplugTestInterface) HotplugDeviceDetected(di *hotplug.HotplugDeviceInfo, spec *hotplug.Specification) error {
+	if di.Subsystem() != "tty" {
+		return nil
+	}
+	return spec.SetSlot(&hotplug.RequestedSlotSpec{
+		Attrs: map[string]interface{}{"path": di.DeviceName()},
+	})
+}
+
+type udevMonitorMock struct {
+	connected, running, stopped bool
+	connectErr                  error
+
+	added           udevmonitor.DeviceAddedFunc
+	removed         udevmonitor.DeviceRemovedFunc
+	enumerationDone udevmonitor.EnumerationDoneFunc
+}
+
+func (u *udevMonitorMock) Connect() error {
+	u.connected = true
+	return u.connectErr
+}
+
+func (u *udevMonitorMock) Run() error {
+	u.running = true
+	return nil
+}
+
+func (u *udevMonitorMock) Stop() error {
+	u.stopped = true
+	return nil
+}
+
+func (s *interfaceManagerSuite) mockUDevMonitor(c *C) *udevMonitorMock {
+	mon := &udevMonitorMock{}
+	restore := ifacestate.MockCreateUDevMonitor(func(added udevmonitor.DeviceAddedFunc, removed udevmonitor.DeviceRemovedFunc, done udevmonitor.EnumerationDoneFunc) udevmonitor.Interface {
+		mon.added = added
+		mon.removed = removed
+		mon.enumerationDone = done
+		return mon
+	})
+	s.AddCleanup(restore)
+	return mon
+}
+
+func (s *interfaceManagerSuite) enableHotplug(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "experimental.hotplug", true), IsNil)
+	tr.Commit()
+}
+
+func serialDevice(c *C, devname string) *hotplug.HotplugDeviceInfo {
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{
+		"DEVPATH":      "/devices/pci0000:00/0000:00:14.0/usb2/2-1/2-1:1.0/tty/" + devname,
+		"DEVNAME":      "/dev/" + devname,
+		"SUBSYSTEM":    "tty",
+		"ID_VENDOR_ID": "0403",
+		"ID_MODEL_ID":  "6001",
+		"ID_SERIAL":    "FTDI_FT232R_USB_UART_A1B2C3",
+		"ID_MODEL":     "FT232R USB UART",
+	})
+	c.Assert(err, IsNil)
+	return di
+}
+
+func (s *interfaceManagerSuite) setupHotplug(c *C) (*ifacestate.InterfaceManager, *udevMonitorMock) {
+	s.mockIfaces(c, &hotplugTestInterface{TestInterface: ifacetest.TestInterface{InterfaceName: "test"}})
+	s.mockSnap(c, coreSnapYaml)
+	s.mockSnap(c, consumerYaml)
+	s.enableHotplug(c)
+	mon := s.mockUDevMonitor(c)
+
+	mgr := s.manager(c)
+	c.Assert(mgr.Ensure(), IsNil)
+	c.Assert(mon.connected, Equals, true)
+	c.Assert(mon.running, Equals, true)
+	return mgr, mon
+}
+
+// lastChange returns the most recently created change, the state does not
+// keep the changes in order.
+func (s *interfaceManagerSuite) lastChange(c *C) *state.Change {
+	var last *state.Change
+	lastID := -1
+	for _, chg := range s.state.Changes() {
+		id, err := strconv.Atoi(chg.ID())
+		c.Assert(err, IsNil)
+		if id > lastID {
+			last, lastID = chg, id
+		}
+	}
+	c.Assert(last, NotNil)
+	return last
+}
+
+func (s *interfaceManagerSuite) TestHotplugDisabled(c *C) {
+	mon := s.mockUDevMonitor(c)
+	mgr := s.manager(c)
+	c.Assert(mgr.Ensure(), IsNil)
+	c.Check(mon.connected, Equals, false)
+}
+
+func (s *interfaceManagerSuite) TestHotplugMonitorFailure(c *C) {
+	s.enableHotplug(c)
+	mon := s.mockUDevMonitor(c)
+	mon.connectErr = fmt.Errorf("boom")
+	mgr := s.manager(c)
+	c.Assert(mgr.Ensure(), ErrorMatches, "boom")
+	c.Check(mon.running, Equals, false)
+
+	// the monitor is not retried
+	mon.connected = false
+	c.Assert(mgr.Ensure(), IsNil)
+	c.Check(mon.connected, Equals, false)
+}
+
+func (s *interfaceManagerSuite) TestHotplugAddRemoveReplug(c *C) {
+	mgr, mon := s.setupHotplug(c)
+	repo := mgr.Repository()
+
+	mon.added(serialDevice(c, "ttyUSB0"))
+	s.settle(c)
+
+	s.state.Lock()
+	chg := s.lastChange(c)
+	c.Check(chg.Kind(), Equals, "hotplug-add-slot")
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	var conns map[string]interface{}
+	c.Assert(s.state.Get("conns", &conns), IsNil)
+	var hotplugSlots map[string]interface{}
+	c.Assert(s.state.Get("hotplug-slots", &hotplugSlots), IsNil)
+	s.state.Unlock()
+
+	slot := repo.Slot("core", "ft232r-usb-uart")
+	c.Assert(slot, NotNil)
+	c.Check(slot.Interface, Equals, "test")
+	c.Check(slot.Attrs, DeepEquals, map[string]interface{}{"path": "/dev/ttyUSB0"})
+	connected, err := repo.Connected("core", "ft232r-usb-uart")
+	c.Assert(err, IsNil)
+	c.Check(connected, DeepEquals, []interfaces.ConnRef{{
+		PlugRef: interfaces.PlugRef{Snap: "consumer", Name: "plug"},
+		SlotRef: interfaces.SlotRef{Snap: "core", Name: "ft232r-usb-uart"}}})
+
+	key := hotplug.DefaultDeviceKey(serialDevice(c, "ttyUSB0"))
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug core:ft232r-usb-uart": map[string]interface{}{
+			"interface": "test", "auto": true, "hotplug-key": key,
+		}})
+	c.Check(hotplugSlots, DeepEquals, map[string]interface{}{
+		"ft232r-usb-uart": map[string]interface{}{
+			"name":         "ft232r-usb-uart",
+			"interface":    "test",
+			"hotplug-key":  key,
+			"static-attrs": map[string]interface{}{"path": "/dev/ttyUSB0"},
+		}})
+
+	// the device is unplugged
+	mon.removed(serialDevice(c, "ttyUSB0"))
+	s.settle(c)
+
+	c.Check(repo.Slot("core", "ft232r-usb-uart"), IsNil)
+	s.state.Lock()
+	c.Check(s.lastChange(c).Status(), Equals, state.DoneStatus)
+	conns = nil
+	c.Assert(s.state.Get("conns", &conns), IsNil)
+	hotplugSlots = nil
+	c.Assert(s.state.Get("hotplug-slots", &hotplugSlots), IsNil)
+	s.state.Unlock()
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug core:ft232r-usb-uart": map[string]interface{}{
+			"interface": "test", "auto": true, "hotplug-key": key, "hotplug-gone": true,
+		}})
+	c.Check(hotplugSlots["ft232r-usb-uart"].(map[string]interface{})["hotplug-gone"], Equals, true)
+
+	// it comes back under a different device node
+	mon.added(serialDevice(c, "ttyUSB1"))
+	s.settle(c)
+
+	slot = repo.Slot("core", "ft232r-usb-uart")
+	c.Assert(slot, NotNil)
+	c.Check(slot.Attrs, DeepEquals, map[string]interface{}{"path": "/dev/ttyUSB1"})
+	connected, err = repo.Connected("core", "ft232r-usb-uart")
+	c.Assert(err, IsNil)
+	c.Check(connected, HasLen, 1)
+
+	s.state.Lock()
+	conns = nil
+	c.Assert(s.state.Get("conns", &conns), IsNil)
+	s.state.Unlock()
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug core:ft232r-usb-uart": map[string]interface{}{
+			"interface": "test", "auto": true, "hotplug-key": key,
+		}})
+}
+
+func (s *interfaceManagerSuite) TestHotplugAddRunsConnectHooks(c *C) {
+	_, mon := s.setupHotplug(c)
+
+	mon.added(serialDevice(c, "ttyUSB0"))
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	chg := s.lastChange(c)
+	c.Assert(chg.Kind(), Equals, "hotplug-add-slot")
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	var kinds, hooks []string
+	for _, t := range chg.Tasks() {
+		kinds = append(kinds, t.Kind())
+		switch t.Kind() {
+		case "run-hook":
+			var hs hookstate.HookSetup
+			c.Assert(t.Get("hook-setup", &hs), IsNil)
+			hooks = append(hooks, hs.Snap+":"+hs.Hook)
+		case "connect":
+			var auto bool
+			c.Assert(t.Get("auto", &auto), IsNil)
+			c.Check(auto, Equals, true)
+		}
+	}
+	c.Check(kinds, DeepEquals, []string{
+		"hotplug-add-slot", "hotplug-connect",
+		"run-hook", "run-hook", "connect", "run-hook", "run-hook",
+	})
+	c.Check(hooks, DeepEquals, []string{
+		"consumer:prepare-plug-plug",
+		"core:prepare-slot-ft232r-usb-uart",
+		"core:connect-slot-ft232r-usb-uart",
+		"consumer:connect-plug-plug",
+	})
+}
+
+func (s *interfaceManagerSuite) TestHotplugRemoveRunsDisconnectHooks(c *C) {
+	_, mon := s.setupHotplug(c)
+
+	mon.added(serialDevice(c, "ttyUSB0"))
+	s.settle(c)
+	mon.removed(serialDevice(c, "ttyUSB0"))
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	chg := s.lastChange(c)
+	c.Assert(chg.Kind(), Equals, "hotplug-remove-slot")
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	var kinds, hooks []string
+	for _, t := range chg.Tasks() {
+		kinds = append(kinds, t.Kind())
+		if t.Kind() == "run-hook" {
+			var hs hookstate.HookSetup
+			c.Assert(t.Get("hook-setup", &hs), IsNil)
+			hooks = append(hooks, hs.Snap+":"+hs.Hook)
+		}
+	}
+	c.Check(kinds, DeepEquals, []string{
+		"hotplug-disconnect", "hotplug-remove-slot",
+		"run-hook", "run-hook", "disconnect", "run-hook", "run-hook",
+	})
+	c.Check(hooks, DeepEquals, []string{
+		"core:disconnect-slot-ft232r-usb-uart",
+		"consumer:disconnect-plug-plug",
+		"core:unprepare-slot-ft232r-usb-uart",
+		"consumer:unprepare-plug-plug",
+	})
+}
+
+func (s *interfaceManagerSuite) TestHotplugRemoveUndo(c *C) {
+	mgr, mon := s.setupHotplug(c)
+	repo := mgr.Repository()
+
+	mon.added(serialDevice(c, "ttyUSB0"))
+	s.settle(c)
+	mon.removed(serialDevice(c, "ttyUSB0"))
+
+	s.state.Lock()
+	chg := s.lastChange(c)
+	c.Assert(chg.Kind(), Equals, "hotplug-remove-slot")
+	terr := s.state.NewTask("error-trigger", "provoking total undo")
+	terr.WaitAll(state.NewTaskSet(chg.Tasks()...))
+	chg.AddTask(terr)
+	s.state.Unlock()
+	s.settle(c)
+
+	s.state.Lock()
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+	var conns map[string]interface{}
+	c.Assert(s.state.Get("conns", &conns), IsNil)
+	var hotplugSlots map[string]interface{}
+	c.Assert(s.state.Get("hotplug-slots", &hotplugSlots), IsNil)
+	s.state.Unlock()
+
+	// the slot and its connection are back
+	c.Assert(repo.Slot("core", "ft232r-usb-uart"), NotNil)
+	connected, err := repo.Connected("core", "ft232r-usb-uart")
+	c.Assert(err, IsNil)
+	c.Check(connected, HasLen, 1)
+	key := hotplug.DefaultDeviceKey(serialDevice(c, "ttyUSB0"))
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug core:ft232r-usb-uart": map[string]interface{}{
+			"interface": "test", "auto": true, "hotplug-key": key,
+		}})
+	c.Check(hotplugSlots["ft232r-usb-uart"].(map[string]interface{})["hotplug-gone"], IsNil)
+}
+
+func (s *interfaceManagerSuite) TestHotplugReplugUndo(c *C) {
+	mgr, mon := s.setupHotplug(c)
+	repo := mgr.Repository()
+
+	mon.added(serialDevice(c, "ttyUSB0"))
+	s.settle(c)
+	mon.removed(serialDevice(c, "ttyUSB0"))
+	s.settle(c)
+	mon.added(serialDevice(c, "ttyUSB0"))
+
+	s.state.Lock()
+	chg := s.lastChange(c)
+	c.Assert(chg.Kind(), Equals, "hotplug-add-slot")
+	terr := s.state.NewTask("error-trigger", "provoking total undo")
+	terr.WaitAll(state.NewTaskSet(chg.Tasks()...))
+	chg.AddTask(terr)
+	s.state.Unlock()
+	s.settle(c)
+
+	s.state.Lock()
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+	var conns map[string]interface{}
+	c.Assert(s.state.Get("conns", &conns), IsNil)
+	var hotplugSlots map[string]interface{}
+	c.Assert(s.state.Get("hotplug-slots", &hotplugSlots), IsNil)
+	s.state.Unlock()
+
+	// the device is still considered gone
+	c.Check(repo.Slot("core", "ft232r-usb-uart"), IsNil)
+	key := hotplug.DefaultDeviceKey(serialDevice(c, "ttyUSB0"))
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug core:ft232r-usb-uart": map[string]interface{}{
+			"interface": "test", "auto": true, "hotplug-key": key, "hotplug-gone": true,
+		}})
+	c.Check(hotplugSlots["ft232r-usb-uart"].(map[string]interface{})["hotplug-gone"], Equals, true)
+}
+
+func (s *interfaceManagerSuite) TestHotplugDeviceIgnored(c *C) {
+	mgr, mon := s.setupHotplug(c)
+
+	// not handled by any interface
+	di, err := hotplug.NewHotplugDeviceInfo(map[string]string{
+		"DEVPATH":      "/devices/pci0000:00/0000:00:14.0/usb2/2-1",
+		"SUBSYSTEM":    "usb",
+		"ID_VENDOR_ID": "0403",
+		"ID_MODEL_ID":  "6001",
+	})
+	c.Assert(err, IsNil)
+	mon.added(di)
+
+	// cannot be identified reliably
+	di, err = hotplug.NewHotplugDeviceInfo(map[string]string{
+		"DEVPATH":   "/devices/platform/serial8250/tty/ttyS1",
+		"DEVNAME":   "/dev/ttyS1",
+		"SUBSYSTEM": "tty",
+	})
+	c.Assert(err, IsNil)
+	mon.added(di)
+
+	s.state.Lock()
+	c.Check(s.state.Changes(), HasLen, 0)
+	var hotplugSlots map[string]interface{}
+	c.Check(s.state.Get("hotplug-slots", &hotplugSlots), Equals, state.ErrNoState)
+	s.state.Unlock()
+	c.Check(mgr.Repository().AllSlots("test"), HasLen, 0)
+}
+
+func (s *interfaceManagerSuite) TestHotplugSlotNameClash(c *C) {
+	mgr, mon := s.setupHotplug(c)
+
+	mon.added(serialDevice(c, "ttyUSB0"))
+	other, err := hotplug.NewHotplugDeviceInfo(map[string]string{
+		"DEVPATH":      "/devices/pci0000:00/0000:00:14.0/usb2/2-2/2-2:1.0/tty/ttyUSB1",
+		"DEVNAME":      "/dev/ttyUSB1",
+		"SUBSYSTEM":    "tty",
+		"ID_VENDOR_ID": "0403",
+		"ID_MODEL_ID":  "6001",
+		"ID_SERIAL":    "FTDI_FT232R_USB_UART_D4E5F6",
+		"ID_MODEL":     "FT232R USB UART",
+	})
+	c.Assert(err, IsNil)
+	mon.added(other)
+	s.settle(c)
+
+	repo := mgr.Repository()
+	c.Assert(repo.AllSlots("test"), HasLen, 2)
+	c.Check(repo.Slot("core", "ft232r-usb-uart"), NotNil)
+	c.Check(repo.Slot("core", "ft232r-usb-uart-1"), NotNil)
+}
+
+func (s *interfaceManagerSuite) TestHotplugDeviceGoneWhileNotRunning(c *C) {
+	s.mockIfaces(c, &hotplugTestInterface{TestInterface: ifacetest.TestInterface{InterfaceName: "test"}})
+	s.mockSnap(c, coreSnapYaml)
+	s.mockSnap(c, consumerYaml)
+	s.enableHotplug(c)
+	mon := s.mockUDevMonitor(c)
+
+	s.state.Lock()
+	s.state.Set("hotplug-slots", map[string]interface{}{
+		"serial": map[string]interface{}{
+			"name":         "serial",
+			"interface":    "test",
+			"hotplug-key":  "1234",
+			"static-attrs": map[string]interface{}{"path": "/dev/ttyUSB0"},
+		}})
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug core:serial": map[string]interface{}{
+			"interface": "test", "hotplug-key": "1234",
+		}})
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+	repo := mgr.Repository()
+	// the slot and its connection are restored on startup
+	c.Assert(repo.Slot("core", "serial"), NotNil)
+	connected, err := repo.Connected("core", "serial")
+	c.Assert(err, IsNil)
+	c.Check(connected, HasLen, 1)
+
+	c.Assert(mgr.Ensure(), IsNil)
+	// the device is not among the ones present
+	mon.enumerationDone()
+	s.settle(c)
+
+	c.Check(repo.Slot("core", "serial"), IsNil)
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(s.state.Changes(), HasLen, 1)
+	c.Check(s.state.Changes()[0].Kind(), Equals, "hotplug-remove-slot")
+	var conns map[string]interface{}
+	c.Assert(s.state.Get("conns", &conns), IsNil)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug core:serial": map[string]interface{}{
+			"interface": "test", "hotplug-key": "1234", "hotplug-gone": true,
+		}})
+}
+
+func (s *interfaceManagerSuite) TestHotplugManualConnect(c *C) {
+	s.mockIfaces(c, &hotplugTestInterface{TestInterface: ifacetest.TestInterface{
+		InterfaceName: "test",
+		AutoConnectCallback: func(*interfaces.Plug, *interfaces.Slot) bool {
+			return false
+		},
+	}})
+	s.mockSnap(c, coreSnapYaml)
+	s.mockSnap(c, consumerYaml)
+	s.enableHotplug(c)
+	mon := s.mockUDevMonitor(c)
+	mgr := s.manager(c)
+	c.Assert(mgr.Ensure(), IsNil)
+
+	mon.added(serialDevice(c, "ttyUSB0"))
+	s.settle(c)
+	connected, err := mgr.Repository().Connected("core", "ft232r-usb-uart")
+	c.Assert(err, IsNil)
+	c.Check(connected, HasLen, 0)
+
+	s.state.Lock()
+	ts, err := ifacestate.Connect(s.state, "consumer", "plug", "core", "ft232r-usb-uart")
+	c.Assert(err, IsNil)
+	chg := s.state.NewChange("connect", "")
+	chg.AddAll(ts)
+	s.state.Unlock()
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(chg.Err(), IsNil)
+	var conns map[string]interface{}
+	c.Assert(s.state.Get("conns", &conns), IsNil)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug core:ft232r-usb-uart": map[string]interface{}{
+			"interface": "test", "hotplug-key": hotplug.DefaultDeviceKey(serialDevice(c, "ttyUSB0")),
+		}})
+}
+
+func (s *interfaceManagerSuite) TestHotplugStop(c *C) {
+	mgr, mon := s.setupHotplug(c)
+	mgr.Stop()
+	c.Check(mon.stopped, Equals, true)
+}
+
+func (s *interfaceManagerSuite) TestMakeSlotName(c *C) {
+	for _, t := range []struct{ in, out string }{
+		{"FT232R USB UART", "ft232r-usb-uart"},
+		{"  CP2102 -- USB to UART Bridge Controller", "cp2102-usb-to-uart"},
+		{"abcdefghijklmnopqrstuvwxyz", "abcdefghijklmnopqrst"},
+		{"123 Serial", "serial"},
+		{"__", ""},
+		{"Über device", "ber-device"},
+	} {
+		c.Check(ifacestate.MakeSlotName(t.in), Equals, t.out, Commentf("%q", t.in))
+	}
+}
+
+func (s *interfaceManagerSuite) TestEnsureUniqueName(c *C) {
+	taken := map[string]bool{"slot": true, "slot-1": true}
+	isTaken := func(name string) bool { return taken[name] }
+	c.Check(ifacestate.EnsureUniqueName("other", isTaken), Equals, "other")
+	c.Check(ifacestate.EnsureUniqueName("slot", isTaken), Equals, "slot-2")
+}
@@ -22,8 +22,10 @@ package ifacestate
 import (
 	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/interfaces/backends"
+	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/ifacestate/ifacerepo"
+	"github.com/snapcore/snapd/overlord/ifacestate/udevmonitor"
 	"github.com/snapcore/snapd/overlord/state"
 )
 
@@ -34,6 +36,14 @@ type InterfaceManager struct {
 	state  *state.State
 	runner *state.TaskRunner
 	repo   *interfaces.Repository
+
+	udevMon       udevmonitor.Interface
+	udevMonFailed bool
+	// slots of the hotplugged devices present, indexed by device path;
+	// protected by the state lock.
+	hotplugDevicePaths map[string][]hotplugDevice
+	// devices reported during the initial enumeration of udevMon.
+	enumeratedDevices map[hotplugDevice]bool
 }
 
 // Manager returns a new InterfaceManager.
@@ -51,6 +61,8 @@ func Manager(s *state.State, hookManager *hookstate.HookManager, extraInterfaces
 		state:  s,
 		runner: runner,
 		repo:   interfaces.NewRepository(),
+
+		hotplugDevicePaths: make(map[string][]hotplugDevice),
 	}
 
 	if err := m.initialize(extraInterfaces, extraBackends); err != nil {
@@ -69,12 +81,20 @@ func Manager(s *state.State, hookManager *hookstate.HookManager, extraInterfaces
 		return len(running) != 0
 	})
 
-	runner.AddHandler("connect", m.doConnect, nil)
-	runner.AddHandler("disconnect", m.doDisconnect, nil)
+	runner.AddHandler("connect", m.doConnect, m.undoConnect)
+	runner.AddHandler("disconnect", m.doDisconnect, m.undoDisconnect)
+	runner.AddHandler("set-attrs", m.doSetAttrs, m.undoSetAttrs)
 	runner.AddHandler("setup-profiles", m.doSetupProfiles, m.undoSetupProfiles)
 	runner.AddHandler("remove-profiles", m.doRemoveProfiles, m.doSetupProfiles)
 	runner.AddHandler("discard-conns", m.doDiscardConns, m.undoDiscardConns)
 	runner.AddHandler("auto-connect", m.doAutoConnect, m.undoAutoConnect)
+	runner.AddHandler("auto-disconnect", m.doAutoDisconnect, m.undoAutoDisconnect)
+	runner.AddHandler("hotplug-add-slot", m.doHotplugAddSlot, m.undoHotplugAddSlot)
+	// the connect tasks injected by hotplug-connect undo themselves
+	runner.AddHandler("hotplug-connect", m.doHotplugConnect, nil)
+	// the disconnect tasks injected by hotplug-disconnect undo themselves
+	runner.AddHandler("hotplug-disconnect", m.doHotplugDisconnect, nil)
+	runner.AddHandler("hotplug-remove-slot", m.doHotplugRemoveSlot, m.undoHotplugRemoveSlot)
 
 	// helper for ubuntu-core -> core
 	runner.AddHandler("transition-ubuntu-core", m.doTransitionUbuntuCore, m.undoTransitionUbuntuCore)
@@ -88,8 +108,9 @@ func (m *InterfaceManager) KnownTaskKinds() []string {
 
 // Ensure implements StateManager.Ensure.
 func (m *InterfaceManager) Ensure() error {
+	err := m.ensureUDevMonitor()
 	m.runner.Ensure()
-	return nil
+	return err
 }
 
 // Wait implements StateManager.Wait.
@@ -99,6 +120,12 @@ func (m *InterfaceManager) Wait() {
 
 // Stop implements StateManager.Stop.
 func (m *InterfaceManager) Stop() {
+	if m.udevMon != nil {
+		if err := m.udevMon.Stop(); err != nil {
+			logger.Noticef("cannot stop udev monitor: %v", err)
+		}
+		m.udevMon = nil
+	}
 	m.runner.Stop()
 }
 
@@ -23,6 +23,7 @@ package ifacestate
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -132,6 +133,7 @@ func connect(st *state.State, change *state.Change, plugSnap, plugName, slotSnap
 	// 'snapctl set' can only modify own attributes (plug's attributes in the *-plug-* hook and
 	// slot's attributes in the *-slot-* hook).
 	// 'snapctl get' can read both slot's and plug's attributes.
+	// Each hook task runs its counterpart (unprepare- or disconnect-) hook when undone.
 	summary := fmt.Sprintf(i18n.G("Connect %s:%s to %s:%s"),
 		plugSnap, plugName, slotSnap, slotName)
 	connectInterface := st.NewTask("connect", summary)
@@ -139,23 +141,8 @@ func connect(st *state.State, change *state.Change, plugSnap, plugName, slotSnap
 	initialContext := make(map[string]interface{})
 	initialContext["attrs-task"] = connectInterface.ID()
 
-	plugHookSetup := &hookstate.HookSetup{
-		Snap:     plugSnap,
-		Hook:     "prepare-plug-" + plugName,
-		Optional: true,
-	}
-
-	summary = fmt.Sprintf(i18n.G("Run hook %s of snap %q"), plugHookSetup.Hook, plugHookSetup.Snap)
-	preparePlugConnection := hookstate.HookTask(st, summary, plugHookSetup, initialContext)
-
-	slotHookSetup := &hookstate.HookSetup{
-		Snap:     slotSnap,
-		Hook:     "prepare-slot-" + slotName,
-		Optional: true,
-	}
-
-	summary = fmt.Sprintf(i18n.G("Run hook %s of snap %q"), slotHookSetup.Hook, slotHookSetup.Snap)
-	prepareSlotConnection := hookstate.HookTask(st, summary, slotHookSetup, initialContext)
+	preparePlugConnection := interfaceHookTask(st, plugSnap, "prepare-plug-"+plugName, "unprepare-plug-"+plugName, initialContext)
+	prepareSlotConnection := interfaceHookTask(st, slotSnap, "prepare-slot-"+slotName, "unprepare-slot-"+slotName, initialContext)
 	prepareSlotConnection.WaitFor(preparePlugConnection)
 
 	connectInterface.Set("slot", interfaces.SlotRef{Snap: slotSnap, Name: slotName})
@@ -167,29 +154,33 @@ func connect(st *state.State, change *state.Change, plugSnap, plugName, slotSnap
 	}
 	connectInterface.WaitFor(prepareSlotConnection)
 
-	connectSlotHookSetup := &hookstate.HookSetup{
-		Snap:     slotSnap,
-		Hook:     "connect-slot-" + slotName,
-		Optional: true,
-	}
-
-	summary = fmt.Sprintf(i18n.G("Run hook %s of snap %q"), connectSlotHookSetup.Hook, connectSlotHookSetup.Snap)
-	connectSlotConnection := hookstate.HookTask(st, summary, connectSlotHookSetup, initialContext)
+	connectSlotConnection := interfaceHookTask(st, slotSnap, "connect-slot-"+slotName, "disconnect-slot-"+slotName, initialContext)
 	connectSlotConnection.WaitFor(connectInterface)
 
-	connectPlugHookSetup := &hookstate.HookSetup{
-		Snap:     plugSnap,
-		Hook:     "connect-plug-" + plugName,
-		Optional: true,
-	}
-
-	summary = fmt.Sprintf(i18n.G("Run hook %s of snap %q"), connectPlugHookSetup.Hook, connectPlugHookSetup.Snap)
-	connectPlugConnection := hookstate.HookTask(st, summary, connectPlugHookSetup, initialContext)
+	connectPlugConnection := interfaceHookTask(st, plugSnap, "connect-plug-"+plugName, "disconnect-plug-"+plugName, initialContext)
 	connectPlugConnection.WaitFor(connectSlotConnection)
 
 	return state.NewTaskSet(preparePlugConnection, prepareSlotConnection, connectInterface, connectSlotConnection, connectPlugConnection), nil
 }
 
+// interfaceHookTask returns a task running the given optional interface
+// hook of a snap, along with the optional hook to run when it is undone.
+func interfaceHookTask(st *state.State, snapName, hook, undoHook string, initialContext map[string]interface{}) *state.Task {
+	hookSetup := &hookstate.HookSetup{
+		Snap:     snapName,
+		Hook:     hook,
+		Optional: true,
+	}
+	undoHookSetup := &hookstate.HookSetup{
+		Snap:     snapName,
+		Hook:     undoHook,
+		Optional: true,
+	}
+
+	summary := fmt.Sprintf(i18n.G("Run hook %s of snap %q"), hookSetup.Hook, hookSetup.Snap)
+	return hookstate.HookTaskWithUndo(st, summary, hookSetup, undoHookSetup, initialContext)
+}
+
 func setInitialConnectAttributes(ts *state.Task, plugSnap string, plugName string, slotSnap string, slotName string) error {
 	// Set initial interface attributes for the plug and slot snaps in connect task.
 	var snapst snapstate.SnapState
@@ -217,6 +208,9 @@ func setInitialConnectAttributes(ts *state.Task, plugSnap string, plugName strin
 		return err
 	}
 	addImplicitSlots(snapInfo)
+	if err := addHotplugSlots(st, snapInfo); err != nil {
+		return err
+	}
 	if slot, ok := snapInfo.Slots[slotName]; ok {
 		ts.Set("slot-attrs", slot.Attrs)
 	} else {
@@ -226,7 +220,7 @@ func setInitialConnectAttributes(ts *state.Task, plugSnap string, plugName strin
 	return nil
 }
 
-// Disconnect returns a set of tasks for  disconnecting an interface.
+// Disconnect returns a set of tasks for disconnecting an interface.
 func Disconnect(st *state.State, plugSnap, plugName, slotSnap, slotName string) (*state.TaskSet, error) {
 	if err := snapstate.CheckChangeConflict(st, plugSnap, noConflictOnConnectTasks, nil); err != nil {
 		return nil, err
@@ -235,12 +229,209 @@ func Disconnect(st *state.State, plugSnap, plugName, slotSnap, slotName string)
 		return nil, err
 	}
 
+	return disconnect(st, plugSnap, plugName, slotSnap, slotName)
+}
+
+func disconnect(st *state.State, plugSnap, plugName, slotSnap, slotName string) (*state.TaskSet, error) {
+	// Create a series of tasks, mirroring the ones created by connect:
+	//  - disconnect-slot-<slot> hook
+	//  - disconnect-plug-<plug> hook
+	//  - disconnect task
+	//  - unprepare-slot-<slot> hook
+	//  - unprepare-plug-<plug> hook
+	// The tasks run in sequence (are serialized by WaitFor).
+	// The hooks can read the attributes of the connection with
+	// 'snapctl get' but cannot modify them.
 	summary := fmt.Sprintf(i18n.G("Disconnect %s:%s from %s:%s"),
 		plugSnap, plugName, slotSnap, slotName)
-	task := st.NewTask("disconnect", summary)
-	task.Set("slot", interfaces.SlotRef{Snap: slotSnap, Name: slotName})
-	task.Set("plug", interfaces.PlugRef{Snap: plugSnap, Name: plugName})
-	return state.NewTaskSet(task), nil
+	disconnectInterface := st.NewTask("disconnect", summary)
+	disconnectInterface.Set("slot", interfaces.SlotRef{Snap: slotSnap, Name: slotName})
+	disconnectInterface.Set("plug", interfaces.PlugRef{Snap: plugSnap, Name: plugName})
+
+	if err := setInitialConnectAttributes(disconnectInterface, plugSnap, plugName, slotSnap, slotName); err != nil {
+		return nil, err
+	}
+
+	initialContext := make(map[string]interface{})
+	initialContext["attrs-task"] = disconnectInterface.ID()
+
+	disconnectSlot := interfaceHookTask(st, slotSnap, "disconnect-slot-"+slotName, "connect-slot-"+slotName, initialContext)
+	disconnectPlug := interfaceHookTask(st, plugSnap, "disconnect-plug-"+plugName, "connect-plug-"+plugName, initialContext)
+	disconnectPlug.WaitFor(disconnectSlot)
+	disconnectInterface.WaitFor(disconnectPlug)
+
+	unprepareSlot := interfaceHookTask(st, slotSnap, "unprepare-slot-"+slotName, "prepare-slot-"+slotName, initialContext)
+	unprepareSlot.WaitFor(disconnectInterface)
+	unpreparePlug := interfaceHookTask(st, plugSnap, "unprepare-plug-"+plugName, "prepare-plug-"+plugName, initialContext)
+	unpreparePlug.WaitFor(unprepareSlot)
+
+	return state.NewTaskSet(disconnectSlot, disconnectPlug, disconnectInterface, unprepareSlot, unpreparePlug), nil
+}
+
+// SetAttributes returns a set of tasks for setting dynamic attributes of the
+// given plug or slot of a snap in all of its connections. The connected snaps
+// are notified via their changed-plug-<plug> or changed-slot-<slot> hooks.
+//
+// Context is used to determine change conflicts - the tasks will not conflict
+// with the tasks from the same change as that of context's.
+func SetAttributes(st *state.State, snapName, plugOrSlot string, attrs map[string]interface{}, context *hookstate.Context) (*state.TaskSet, error) {
+	var snapst snapstate.SnapState
+	if err := snapstate.Get(st, snapName, &snapst); err != nil {
+		if err == state.ErrNoState {
+			return nil, &snap.NotInstalledError{Snap: snapName}
+		}
+		return nil, err
+	}
+	snapInfo, err := snapst.CurrentInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	var staticAttrs map[string]interface{}
+	plug, isPlug := snapInfo.Plugs[plugOrSlot]
+	slot, isSlot := snapInfo.Slots[plugOrSlot]
+	switch {
+	case isPlug:
+		staticAttrs = plug.Attrs
+	case isSlot:
+		staticAttrs = slot.Attrs
+	default:
+		return nil, fmt.Errorf("snap %q has no plug or slot named %q", snapName, plugOrSlot)
+	}
+	for key := range attrs {
+		if _, ok := staticAttrs[key]; ok {
+			return nil, fmt.Errorf("cannot change attribute %q as it was statically specified in the snap details", key)
+		}
+	}
+
+	conns, err := getConns(st)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(conns))
+	for id := range conns {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var connRefs []interfaces.ConnRef
+	for _, id := range ids {
+		connRef, err := interfaces.ParseConnRef(id)
+		if err != nil {
+			return nil, err
+		}
+		if isPlug && connRef.PlugRef == (interfaces.PlugRef{Snap: snapName, Name: plugOrSlot}) ||
+			isSlot && connRef.SlotRef == (interfaces.SlotRef{Snap: snapName, Name: plugOrSlot}) {
+			connRefs = append(connRefs, connRef)
+		}
+	}
+	if len(connRefs) == 0 {
+		return nil, fmt.Errorf("cannot set attributes of %s:%s, it is not connected", snapName, plugOrSlot)
+	}
+
+	checkConflict := noConflictOnConnectTasks
+	if context != nil && !context.IsEphemeral() {
+		if task, ok := context.Task(); ok && task.Change() != nil {
+			chgID := task.Change().ID()
+			checkConflict = func(otherTask *state.Task) bool {
+				if otherTask.Change() != nil && otherTask.Change().ID() == chgID {
+					return false
+				}
+				return noConflictOnConnectTasks(otherTask)
+			}
+		}
+	}
+	for _, connRef := range connRefs {
+		for _, name := range []string{connRef.PlugRef.Snap, connRef.SlotRef.Snap} {
+			if err := snapstate.CheckChangeConflict(st, name, checkConflict, nil); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Create a pair of tasks for each connection:
+	//  - set-attrs task
+	//  - changed-plug-<plug> or changed-slot-<slot> hook of the other side
+	// The tasks run in sequence (are serialized by WaitFor).
+	ts := state.NewTaskSet()
+	var prev *state.Task
+	for _, connRef := range connRefs {
+		conn := conns[connRef.ID()]
+		plugRef, slotRef := connRef.PlugRef, connRef.SlotRef
+
+		summary := fmt.Sprintf(i18n.G("Set attributes of connection %s:%s to %s:%s"),
+			plugRef.Snap, plugRef.Name, slotRef.Snap, slotRef.Name)
+		setAttrs := st.NewTask("set-attrs", summary)
+		setAttrs.Set("plug", plugRef)
+		setAttrs.Set("slot", slotRef)
+		setAttrs.Set("plug-side", isPlug)
+		setAttrs.Set("attrs", attrs)
+
+		// the hooks see all the attributes of the connection, once
+		// the new ones are in place
+		if err := setInitialConnectAttributes(setAttrs, plugRef.Snap, plugRef.Name, slotRef.Snap, slotRef.Name); err != nil {
+			return nil, err
+		}
+		plugDynamic, slotDynamic := conn.PlugDynamic, conn.SlotDynamic
+		if isPlug {
+			plugDynamic = mergeAttrs(plugDynamic, attrs)
+		} else {
+			slotDynamic = mergeAttrs(slotDynamic, attrs)
+		}
+		if err := addTaskAttrs(setAttrs, "plug-attrs", plugDynamic); err != nil {
+			return nil, err
+		}
+		if err := addTaskAttrs(setAttrs, "slot-attrs", slotDynamic); err != nil {
+			return nil, err
+		}
+
+		initialContext := make(map[string]interface{})
+		initialContext["attrs-task"] = setAttrs.ID()
+
+		hookSetup := &hookstate.HookSetup{Optional: true}
+		if isPlug {
+			hookSetup.Snap = slotRef.Snap
+			hookSetup.Hook = "changed-slot-" + slotRef.Name
+		} else {
+			hookSetup.Snap = plugRef.Snap
+			hookSetup.Hook = "changed-plug-" + plugRef.Name
+		}
+		summary = fmt.Sprintf(i18n.G("Run hook %s of snap %q"), hookSetup.Hook, hookSetup.Snap)
+		changedHook := hookstate.HookTask(st, summary, hookSetup, initialContext)
+
+		if prev != nil {
+			setAttrs.WaitFor(prev)
+		}
+		changedHook.WaitFor(setAttrs)
+		prev = changedHook
+		ts.AddTask(setAttrs)
+		ts.AddTask(changedHook)
+	}
+
+	return ts, nil
+}
+
+// mergeAttrs returns a copy of the given attributes, updated with the new ones.
+func mergeAttrs(attrs, newAttrs map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(attrs)+len(newAttrs))
+	for key, value := range attrs {
+		merged[key] = value
+	}
+	for key, value := range newAttrs {
+		merged[key] = value
+	}
+	return merged
+}
+
+// addTaskAttrs adds the given attributes to the attributes stored in the task
+// under the given key.
+func addTaskAttrs(task *state.Task, which string, attrs map[string]interface{}) error {
+	var taskAttrs map[string]interface{}
+	if err := task.Get(which, &taskAttrs); err != nil && err != state.ErrNoState {
+		return err
+	}
+	task.Set(which, mergeAttrs(taskAttrs, attrs))
+	return nil
 }
 
 // CheckInterfaces checks whether plugs and slots of snap are allowed for installation.
@@ -277,7 +468,7 @@ var once sync.Once
 func delayedCrossMgrInit() {
 	// hook interface checks into snapstate installation logic
 	once.Do(func() {
-		snapstate.AddCheckSnapCallback(func(st *state.State, snapInfo, _ *snap.Info, _ snapstate.Flags) error {
+		snapstate.AddCheckSnapCallback(func(st *state.State, snapInfo, _ *snap.Info, _ snap.Container, _ snapstate.Flags) error {
 			return CheckInterfaces(st, snapInfo)
 		})
 	})
@@ -20,6 +20,7 @@
 package ifacestate_test
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
@@ -156,10 +157,16 @@ func (s *interfaceManagerSuite) TestKnownTaskKinds(c *C) {
 	sort.Strings(kinds)
 	c.Assert(kinds, DeepEquals, []string{
 		"auto-connect",
+		"auto-disconnect",
 		"connect",
 		"discard-conns",
 		"disconnect",
+		"hotplug-add-slot",
+		"hotplug-connect",
+		"hotplug-disconnect",
+		"hotplug-remove-slot",
 		"remove-profiles",
+		"set-attrs",
 		"setup-profiles",
 		"transition-ubuntu-core"})
 }
@@ -192,12 +199,19 @@ func (s *interfaceManagerSuite) TestConnectTask(c *C) {
 	err = task.Get("hook-setup", &hookSetup)
 	c.Assert(err, IsNil)
 	c.Assert(hookSetup, Equals, hookstate.HookSetup{Snap: "consumer", Hook: "prepare-plug-plug", Optional: true})
+	var undoHookSetup hookstate.HookSetup
+	err = task.Get("undo-hook-setup", &undoHookSetup)
+	c.Assert(err, IsNil)
+	c.Assert(undoHookSetup, Equals, hookstate.HookSetup{Snap: "consumer", Hook: "unprepare-plug-plug", Optional: true})
 	i++
 	task = ts.Tasks()[i]
 	c.Check(task.Kind(), Equals, "run-hook")
 	err = task.Get("hook-setup", &hookSetup)
 	c.Assert(err, IsNil)
 	c.Assert(hookSetup, Equals, hookstate.HookSetup{Snap: "producer", Hook: "prepare-slot-slot", Optional: true})
+	err = task.Get("undo-hook-setup", &undoHookSetup)
+	c.Assert(err, IsNil)
+	c.Assert(undoHookSetup, Equals, hookstate.HookSetup{Snap: "producer", Hook: "unprepare-slot-slot", Optional: true})
 	i++
 	task = ts.Tasks()[i]
 	c.Assert(task.Kind(), Equals, "connect")
@@ -230,12 +244,18 @@ func (s *interfaceManagerSuite) TestConnectTask(c *C) {
 	err = task.Get("hook-setup", &hs)
 	c.Assert(err, IsNil)
 	c.Assert(hs, Equals, hookstate.HookSetup{Snap: "producer", Hook: "connect-slot-slot", Optional: true})
+	err = task.Get("undo-hook-setup", &undoHookSetup)
+	c.Assert(err, IsNil)
+	c.Assert(undoHookSetup, Equals, hookstate.HookSetup{Snap: "producer", Hook: "disconnect-slot-slot", Optional: true})
 	i++
 	task = ts.Tasks()[i]
 	c.Check(task.Kind(), Equals, "run-hook")
 	err = task.Get("hook-setup", &hs)
 	c.Assert(err, IsNil)
 	c.Assert(hs, Equals, hookstate.HookSetup{Snap: "consumer", Hook: "connect-plug-plug", Optional: true})
+	err = task.Get("undo-hook-setup", &undoHookSetup)
+	c.Assert(err, IsNil)
+	c.Assert(undoHookSetup, Equals, hookstate.HookSetup{Snap: "consumer", Hook: "disconnect-plug-plug", Optional: true})
 }
 
 func (s *interfaceManagerSuite) testConnectDisconnectConflicts(c *C, f func(*state.State, string, string, string, string) (*state.TaskSet, error), snapName string, otherTaskKind string, expectedErr string) {
@@ -553,13 +573,37 @@ slots:
 }
 
 func (s *interfaceManagerSuite) TestDisconnectTask(c *C) {
+	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+	_ = s.manager(c)
+
 	s.state.Lock()
 	defer s.state.Unlock()
 
 	ts, err := ifacestate.Disconnect(s.state, "consumer", "plug", "producer", "slot")
 	c.Assert(err, IsNil)
+	c.Assert(ts.Tasks(), HasLen, 5)
 
-	task := ts.Tasks()[0]
+	checkHookTask := func(task *state.Task, expected, expectedUndo hookstate.HookSetup) {
+		c.Check(task.Kind(), Equals, "run-hook")
+		var hs hookstate.HookSetup
+		err := task.Get("hook-setup", &hs)
+		c.Assert(err, IsNil)
+		c.Check(hs, Equals, expected)
+		err = task.Get("undo-hook-setup", &hs)
+		c.Assert(err, IsNil)
+		c.Check(hs, Equals, expectedUndo)
+	}
+
+	checkHookTask(ts.Tasks()[0],
+		hookstate.HookSetup{Snap: "producer", Hook: "disconnect-slot-slot", Optional: true},
+		hookstate.HookSetup{Snap: "producer", Hook: "connect-slot-slot", Optional: true})
+	checkHookTask(ts.Tasks()[1],
+		hookstate.HookSetup{Snap: "consumer", Hook: "disconnect-plug-plug", Optional: true},
+		hookstate.HookSetup{Snap: "consumer", Hook: "connect-plug-plug", Optional: true})
+
+	task := ts.Tasks()[2]
 	c.Assert(task.Kind(), Equals, "disconnect")
 	var plug interfaces.PlugRef
 	err = task.Get("plug", &plug)
@@ -571,6 +615,29 @@ func (s *interfaceManagerSuite) TestDisconnectTask(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(slot.Snap, Equals, "producer")
 	c.Assert(slot.Name, Equals, "slot")
+
+	// verify initial attributes are present in disconnect task
+	var attrs map[string]interface{}
+	err = task.Get("plug-attrs", &attrs)
+	c.Assert(err, IsNil)
+	c.Assert(attrs["attr1"], Equals, "value1")
+	err = task.Get("slot-attrs", &attrs)
+	c.Assert(err, IsNil)
+	c.Assert(attrs["attr2"], Equals, "value2")
+
+	checkHookTask(ts.Tasks()[3],
+		hookstate.HookSetup{Snap: "producer", Hook: "unprepare-slot-slot", Optional: true},
+		hookstate.HookSetup{Snap: "producer", Hook: "prepare-slot-slot", Optional: true})
+	checkHookTask(ts.Tasks()[4],
+		hookstate.HookSetup{Snap: "consumer", Hook: "unprepare-plug-plug", Optional: true},
+		hookstate.HookSetup{Snap: "consumer", Hook: "prepare-plug-plug", Optional: true})
+
+	// all the hooks read the attributes from the disconnect task
+	for _, t := range []*state.Task{ts.Tasks()[0], ts.Tasks()[1], ts.Tasks()[3], ts.Tasks()[4]} {
+		var context map[string]interface{}
+		c.Assert(t.Get("hook-context", &context), IsNil)
+		c.Check(context["attrs-task"], Equals, task.ID())
+	}
 }
 
 // Disconnect works when both plug and slot are specified
@@ -609,15 +676,15 @@ func (s *interfaceManagerSuite) testDisconnect(c *C, plugSnap, plugName, slotSna
 	c.Assert(err, IsNil)
 	change.AddAll(ts)
 	s.state.Unlock()
-	mgr.Ensure()
-	mgr.Wait()
+
+	s.settle(c)
 
 	s.state.Lock()
 	defer s.state.Unlock()
 
 	// Ensure that the task succeeded.
 	c.Assert(change.Err(), IsNil)
-	task := change.Tasks()[0]
+	task := ts.Tasks()[2]
 	c.Check(task.Kind(), Equals, "disconnect")
 	c.Check(task.Status(), Equals, state.DoneStatus)
 
@@ -709,6 +776,7 @@ func (s *interfaceManagerSuite) mockSnap(c *C, yamlText string) *snap.Info {
 		Active:   true,
 		Sequence: []*snap.SideInfo{sideInfo},
 		Current:  sideInfo.Revision,
+		SnapType: string(snapInfo.Type),
 	})
 	return snapInfo
 }
@@ -1667,7 +1735,7 @@ func (s *interfaceManagerSuite) TestDisconnectSetsUpSecurity(c *C) {
 	})
 	s.state.Unlock()
 
-	mgr := s.manager(c)
+	_ = s.manager(c)
 
 	s.state.Lock()
 	ts, err := ifacestate.Disconnect(s.state, "consumer", "plug", "producer", "slot")
@@ -1682,9 +1750,7 @@ func (s *interfaceManagerSuite) TestDisconnectSetsUpSecurity(c *C) {
 	change.AddAll(ts)
 	s.state.Unlock()
 
-	mgr.Ensure()
-	mgr.Wait()
-	mgr.Stop()
+	s.settle(c)
 
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -1711,7 +1777,7 @@ func (s *interfaceManagerSuite) TestDisconnectTracksConnectionsInState(c *C) {
 	})
 	s.state.Unlock()
 
-	mgr := s.manager(c)
+	_ = s.manager(c)
 
 	s.state.Lock()
 	ts, err := ifacestate.Disconnect(s.state, "consumer", "plug", "producer", "slot")
@@ -1726,9 +1792,7 @@ func (s *interfaceManagerSuite) TestDisconnectTracksConnectionsInState(c *C) {
 	change.AddAll(ts)
 	s.state.Unlock()
 
-	mgr.Ensure()
-	mgr.Wait()
-	mgr.Stop()
+	s.settle(c)
 
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -1741,6 +1805,515 @@ func (s *interfaceManagerSuite) TestDisconnectTracksConnectionsInState(c *C) {
 	c.Check(conns, DeepEquals, map[string]interface{}{})
 }
 
+func (s *interfaceManagerSuite) TestDisconnectUndo(c *C) {
+	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test", "auto": true},
+	})
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+
+	s.state.Lock()
+	ts, err := ifacestate.Disconnect(s.state, "consumer", "plug", "producer", "slot")
+	c.Assert(err, IsNil)
+
+	change := s.state.NewChange("disconnect", "")
+	change.AddAll(ts)
+	terr := s.state.NewTask("error-trigger", "provoking total undo")
+	terr.WaitAll(ts)
+	change.AddTask(terr)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(change.Status(), Equals, state.ErrorStatus)
+	c.Check(ts.Tasks()[2].Kind(), Equals, "disconnect")
+	c.Check(ts.Tasks()[2].Status(), Equals, state.UndoneStatus)
+
+	// the connection was restored in the state and in the repository
+	var conns map[string]interface{}
+	err = s.state.Get("conns", &conns)
+	c.Assert(err, IsNil)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test", "auto": true},
+	})
+	ifaces := mgr.Repository().Interfaces()
+	c.Check(ifaces.Connections, DeepEquals, []*interfaces.ConnRef{{interfaces.PlugRef{Snap: "consumer", Name: "plug"}, interfaces.SlotRef{Snap: "producer", Name: "slot"}}})
+}
+
+func (s *interfaceManagerSuite) TestConnectUndo(c *C) {
+	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	mgr := s.manager(c)
+
+	s.state.Lock()
+	ts, err := ifacestate.Connect(s.state, "consumer", "plug", "producer", "slot")
+	c.Assert(err, IsNil)
+
+	change := s.state.NewChange("connect", "")
+	change.AddAll(ts)
+	terr := s.state.NewTask("error-trigger", "provoking total undo")
+	terr.WaitAll(ts)
+	change.AddTask(terr)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(change.Status(), Equals, state.ErrorStatus)
+	c.Check(ts.Tasks()[2].Kind(), Equals, "connect")
+	c.Check(ts.Tasks()[2].Status(), Equals, state.UndoneStatus)
+
+	var conns map[string]interface{}
+	err = s.state.Get("conns", &conns)
+	c.Assert(err, IsNil)
+	c.Check(conns, HasLen, 0)
+	ifaces := mgr.Repository().Interfaces()
+	c.Check(ifaces.Connections, HasLen, 0)
+}
+
+func (s *interfaceManagerSuite) TestAutoDisconnect(c *C) {
+	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+
+	s.state.Lock()
+	change := s.state.NewChange("remove", "")
+	task := s.state.NewTask("auto-disconnect", "")
+	task.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: "producer",
+		},
+	})
+	change.AddTask(task)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(change.Err(), IsNil)
+	c.Check(change.Status(), Equals, state.DoneStatus)
+
+	var kinds []string
+	for _, t := range change.Tasks() {
+		kinds = append(kinds, t.Kind())
+	}
+	c.Check(kinds, DeepEquals, []string{"auto-disconnect", "run-hook", "run-hook", "disconnect", "run-hook", "run-hook"})
+
+	var conns map[string]interface{}
+	err := s.state.Get("conns", &conns)
+	c.Assert(err, IsNil)
+	c.Check(conns, HasLen, 0)
+	ifaces := mgr.Repository().Interfaces()
+	c.Check(ifaces.Connections, HasLen, 0)
+}
+
+func (s *interfaceManagerSuite) TestAutoDisconnectUndo(c *C) {
+	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test", "auto": true},
+	})
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+
+	s.state.Lock()
+	change := s.state.NewChange("remove", "")
+	task := s.state.NewTask("auto-disconnect", "")
+	task.Set("snap-setup", &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{
+			RealName: "producer",
+		},
+	})
+	change.AddTask(task)
+	terr := s.state.NewTask("error-trigger", "provoking total undo")
+	terr.WaitFor(task)
+	change.AddTask(terr)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(change.Status(), Equals, state.ErrorStatus)
+	c.Check(task.Status(), Equals, state.UndoneStatus)
+	for _, t := range change.Tasks() {
+		if t.Kind() == "disconnect" {
+			c.Check(t.Status(), Equals, state.UndoneStatus)
+		}
+	}
+
+	var conns map[string]interface{}
+	err := s.state.Get("conns", &conns)
+	c.Assert(err, IsNil)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test", "auto": true},
+	})
+	ifaces := mgr.Repository().Interfaces()
+	c.Check(ifaces.Connections, HasLen, 1)
+}
+
+func (s *interfaceManagerSuite) TestUndoAutoDisconnectRestoresConns(c *C) {
+	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+
+	mgr := s.manager(c)
+
+	s.state.Lock()
+	change := s.state.NewChange("remove", "")
+	task := s.state.NewTask("auto-disconnect", "")
+	// the connection is gone but was not restored by undoing its
+	// disconnect tasks
+	task.Set("old-conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test", "auto": true},
+	})
+	task.SetStatus(state.DoneStatus)
+	change.AddTask(task)
+	terr := s.state.NewTask("error-trigger", "provoking total undo")
+	terr.WaitFor(task)
+	change.AddTask(terr)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(task.Status(), Equals, state.UndoneStatus)
+	var conns map[string]interface{}
+	err := s.state.Get("conns", &conns)
+	c.Assert(err, IsNil)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test", "auto": true},
+	})
+	ifaces := mgr.Repository().Interfaces()
+	c.Check(ifaces.Connections, HasLen, 1)
+	c.Check(s.secBackend.SetupCalls, HasLen, 2)
+}
+
+// mockDynamicAttrIface mocks a "test" interface whose connected plug snippet
+// reports the "dynamic" attribute of the slot, as seen by the backends.
+func (s *interfaceManagerSuite) mockDynamicAttrIface(c *C) {
+	s.mockIfaces(c, &ifacetest.TestInterface{
+		InterfaceName: "test",
+		TestConnectedPlugCallback: func(spec *ifacetest.Specification, plug *interfaces.ConnectedPlug, slot *interfaces.ConnectedSlot) error {
+			var value string
+			slot.Attr("dynamic", &value)
+			spec.AddSnippet("dynamic=" + value)
+			return nil
+		},
+	}, &ifacetest.TestInterface{InterfaceName: "test2"})
+}
+
+func (s *interfaceManagerSuite) consumerSnippets(c *C, repo *interfaces.Repository) []string {
+	spec, err := repo.SnapSpecification(s.secBackend.Name(), "consumer")
+	c.Assert(err, IsNil)
+	return spec.(*ifacetest.Specification).Snippets
+}
+
+func (s *interfaceManagerSuite) TestSetAttributesTasks(c *C) {
+	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+	_ = s.manager(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface": "test", "slot-dynamic": map[string]interface{}{"other": "value"},
+		},
+	})
+
+	ts, err := ifacestate.SetAttributes(s.state, "producer", "slot", map[string]interface{}{"dynamic": "value"}, nil)
+	c.Assert(err, IsNil)
+	c.Assert(ts.Tasks(), HasLen, 2)
+
+	task := ts.Tasks()[0]
+	c.Check(task.Kind(), Equals, "set-attrs")
+	var plugSide bool
+	c.Assert(task.Get("plug-side", &plugSide), IsNil)
+	c.Check(plugSide, Equals, false)
+	var attrs map[string]interface{}
+	c.Assert(task.Get("attrs", &attrs), IsNil)
+	c.Check(attrs, DeepEquals, map[string]interface{}{"dynamic": "value"})
+	// the hooks see the static and dynamic attributes of both sides
+	var plugAttrs, slotAttrs map[string]interface{}
+	c.Assert(task.Get("plug-attrs", &plugAttrs), IsNil)
+	c.Check(plugAttrs, DeepEquals, map[string]interface{}{"attr1": "value1"})
+	c.Assert(task.Get("slot-attrs", &slotAttrs), IsNil)
+	c.Check(slotAttrs, DeepEquals, map[string]interface{}{"attr2": "value2", "other": "value", "dynamic": "value"})
+
+	hookTask := ts.Tasks()[1]
+	c.Check(hookTask.Kind(), Equals, "run-hook")
+	c.Check(hookTask.WaitTasks(), DeepEquals, []*state.Task{task})
+	var hs hookstate.HookSetup
+	c.Assert(hookTask.Get("hook-setup", &hs), IsNil)
+	c.Check(hs, Equals, hookstate.HookSetup{Snap: "consumer", Hook: "changed-plug-plug", Optional: true})
+	var context map[string]interface{}
+	c.Assert(hookTask.Get("hook-context", &context), IsNil)
+	c.Check(context["attrs-task"], Equals, task.ID())
+}
+
+func (s *interfaceManagerSuite) TestSetAttributesErrors(c *C) {
+	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+	_ = s.manager(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := ifacestate.SetAttributes(s.state, "producer", "slot", map[string]interface{}{"dynamic": "value"}, nil)
+	c.Check(err, ErrorMatches, `cannot set attributes of producer:slot, it is not connected`)
+
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
+	_, err = ifacestate.SetAttributes(s.state, "producer", "slot", map[string]interface{}{"attr2": "value"}, nil)
+	c.Check(err, ErrorMatches, `cannot change attribute "attr2" as it was statically specified in the snap details`)
+	_, err = ifacestate.SetAttributes(s.state, "producer", "foo", nil, nil)
+	c.Check(err, ErrorMatches, `snap "producer" has no plug or slot named "foo"`)
+	_, err = ifacestate.SetAttributes(s.state, "missing", "foo", nil, nil)
+	c.Check(err, ErrorMatches, `snap "missing" is not installed`)
+}
+
+func (s *interfaceManagerSuite) TestSetAttrsTask(c *C) {
+	s.mockDynamicAttrIface(c)
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{"interface": "test"},
+	})
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+	c.Check(s.consumerSnippets(c, mgr.Repository()), DeepEquals, []string{"dynamic="})
+
+	s.state.Lock()
+	ts, err := ifacestate.SetAttributes(s.state, "producer", "slot", map[string]interface{}{"dynamic": "value"}, nil)
+	c.Assert(err, IsNil)
+	change := s.state.NewChange("set-attrs", "")
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Assert(change.Err(), IsNil)
+	c.Check(change.Status(), Equals, state.DoneStatus)
+
+	var conns map[string]interface{}
+	err = s.state.Get("conns", &conns)
+	c.Assert(err, IsNil)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface": "test", "slot-dynamic": map[string]interface{}{"dynamic": "value"},
+		},
+	})
+
+	// the security of both snaps was regenerated with the new attributes
+	c.Check(s.consumerSnippets(c, mgr.Repository()), DeepEquals, []string{"dynamic=value"})
+	c.Assert(s.secBackend.SetupCalls, HasLen, 2)
+	c.Check(s.secBackend.SetupCalls[0].SnapInfo.Name(), Equals, "producer")
+	c.Check(s.secBackend.SetupCalls[1].SnapInfo.Name(), Equals, "consumer")
+}
+
+func (s *interfaceManagerSuite) TestSetAttrsTaskUndo(c *C) {
+	s.mockDynamicAttrIface(c)
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface": "test", "slot-dynamic": map[string]interface{}{"dynamic": "old"},
+		},
+	})
+	s.state.Unlock()
+
+	mgr := s.manager(c)
+	c.Check(s.consumerSnippets(c, mgr.Repository()), DeepEquals, []string{"dynamic=old"})
+
+	s.state.Lock()
+	ts, err := ifacestate.SetAttributes(s.state, "producer", "slot", map[string]interface{}{"dynamic": "new"}, nil)
+	c.Assert(err, IsNil)
+	change := s.state.NewChange("set-attrs", "")
+	change.AddAll(ts)
+	terr := s.state.NewTask("error-trigger", "provoking total undo")
+	terr.WaitAll(ts)
+	change.AddTask(terr)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(change.Status(), Equals, state.ErrorStatus)
+	c.Check(ts.Tasks()[0].Status(), Equals, state.UndoneStatus)
+
+	var conns map[string]interface{}
+	err = s.state.Get("conns", &conns)
+	c.Assert(err, IsNil)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface": "test", "slot-dynamic": map[string]interface{}{"dynamic": "old"},
+		},
+	})
+	c.Check(s.consumerSnippets(c, mgr.Repository()), DeepEquals, []string{"dynamic=old"})
+}
+
+func (s *interfaceManagerSuite) testSetAttrsTaskRejected(c *C, value string, errMatch string) {
+	s.state.Lock()
+	ts, err := ifacestate.SetAttributes(s.state, "producer", "slot", map[string]interface{}{"dynamic": value}, nil)
+	c.Assert(err, IsNil)
+	change := s.state.NewChange("set-attrs", "")
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	c.Check(change.Err(), ErrorMatches, errMatch)
+	c.Check(change.Status(), Equals, state.ErrorStatus)
+	var oldconn map[string]interface{}
+	c.Check(ts.Tasks()[0].Get("old-conn", &oldconn), Equals, state.ErrNoState)
+
+	// the refused attributes were not stored
+	var conns map[string]interface{}
+	err = s.state.Get("conns", &conns)
+	c.Assert(err, IsNil)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface": "test", "slot-dynamic": map[string]interface{}{"dynamic": "old"},
+		},
+	})
+}
+
+func (s *interfaceManagerSuite) TestSetAttrsTaskSanitized(c *C) {
+	s.mockIfaces(c, &ifacetest.TestInterface{
+		InterfaceName: "test",
+		BeforePrepareSlotCallback: func(slot *snap.SlotInfo) error {
+			if slot.Attrs["dynamic"] == "bad" {
+				return fmt.Errorf("invalid dynamic attribute")
+			}
+			if value, ok := slot.Attrs["dynamic"].(string); ok {
+				slot.Attrs["dynamic"] = strings.TrimSpace(value)
+			}
+			return nil
+		},
+	}, &ifacetest.TestInterface{InterfaceName: "test2"})
+	s.mockSnap(c, consumerYaml)
+	s.mockSnap(c, producerYaml)
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface": "test", "slot-dynamic": map[string]interface{}{"dynamic": "old"},
+		},
+	})
+	s.state.Unlock()
+	_ = s.manager(c)
+
+	s.testSetAttrsTaskRejected(c, "bad", `(?s).*cannot set attributes of producer:slot: invalid dynamic attribute.*`)
+
+	s.state.Lock()
+	ts, err := ifacestate.SetAttributes(s.state, "producer", "slot", map[string]interface{}{"dynamic": " good "}, nil)
+	c.Assert(err, IsNil)
+	change := s.state.NewChange("set-attrs", "")
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(change.Err(), IsNil)
+
+	// the attributes are stored the way the interface sanitized them
+	var conns map[string]interface{}
+	err = s.state.Get("conns", &conns)
+	c.Assert(err, IsNil)
+	c.Check(conns, DeepEquals, map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface": "test", "slot-dynamic": map[string]interface{}{"dynamic": "good"},
+		},
+	})
+}
+
+func (s *interfaceManagerSuite) TestSetAttrsTaskPolicy(c *C) {
+	restore := assertstest.MockBuiltinBaseDeclaration([]byte(`
+type: base-declaration
+authority-id: canonical
+series: 16
+slots:
+  test:
+    allow-connection:
+      slot-attributes:
+        dynamic: old|allowed
+`))
+	defer restore()
+	s.mockDynamicAttrIface(c)
+	s.mockSnapDecl(c, "consumer", "one-publisher", nil)
+	s.mockSnap(c, consumerYaml)
+	s.mockSnapDecl(c, "producer", "one-publisher", nil)
+	s.mockSnap(c, producerYaml)
+	s.state.Lock()
+	s.state.Set("conns", map[string]interface{}{
+		"consumer:plug producer:slot": map[string]interface{}{
+			"interface": "test", "slot-dynamic": map[string]interface{}{"dynamic": "old"},
+		},
+	})
+	s.state.Unlock()
+	mgr := s.manager(c)
+
+	s.testSetAttrsTaskRejected(c, "forbidden", `(?s).*cannot set attributes of connection consumer:plug producer:slot: connection not allowed by slot rule of interface "test".*`)
+	c.Check(s.consumerSnippets(c, mgr.Repository()), DeepEquals, []string{"dynamic=old"})
+
+	s.state.Lock()
+	ts, err := ifacestate.SetAttributes(s.state, "producer", "slot", map[string]interface{}{"dynamic": "allowed"}, nil)
+	c.Assert(err, IsNil)
+	change := s.state.NewChange("set-attrs", "")
+	change.AddAll(ts)
+	s.state.Unlock()
+
+	s.settle(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Assert(change.Err(), IsNil)
+	c.Check(s.consumerSnippets(c, mgr.Repository()), DeepEquals, []string{"dynamic=allowed"})
+}
+
 func (s *interfaceManagerSuite) TestManagerReloadsConnections(c *C) {
 	s.mockIfaces(c, &ifacetest.TestInterface{InterfaceName: "test"}, &ifacetest.TestInterface{InterfaceName: "test2"})
 	s.mockSnap(c, consumerYaml)
@@ -0,0 +1,154 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+// Package udevmonitor reports devices being added to and removed from the
+// system, starting with the devices that are already present.
+package udevmonitor
+
+import (
+	"fmt"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/interfaces/hotplug"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil/udev/netlink"
+)
+
+// Interface is the interface of the udev monitor.
+type Interface interface {
+	Connect() error
+	Run() error
+	Stop() error
+}
+
+// DeviceAddedFunc is called when a device is added to the system.
+type DeviceAddedFunc func(device *hotplug.HotplugDeviceInfo)
+
+// DeviceRemovedFunc is called when a device is removed from the system.
+type DeviceRemovedFunc func(device *hotplug.HotplugDeviceInfo)
+
+// EnumerationDoneFunc is called when all the devices present when the
+// monitor started were reported.
+type EnumerationDoneFunc func()
+
+// Monitor monitors kernel uevents, after they were processed by udev, and
+// reports devices being added and removed.
+type Monitor struct {
+	tomb            tomb.Tomb
+	deviceAdded     DeviceAddedFunc
+	deviceRemoved   DeviceRemovedFunc
+	enumerationDone EnumerationDoneFunc
+	netlinkConn     *netlink.UEventConn
+	// devices seen during the initial enumeration, add events for them
+	// are ignored.
+	seen map[string]bool
+}
+
+// New creates a udev monitor reporting devices to the given callbacks.
+func New(added DeviceAddedFunc, removed DeviceRemovedFunc, enumerationDone EnumerationDoneFunc) Interface {
+	return &Monitor{
+		deviceAdded:     added,
+		deviceRemoved:   removed,
+		enumerationDone: enumerationDone,
+		netlinkConn:     &netlink.UEventConn{},
+		seen:            make(map[string]bool),
+	}
+}
+
+// Connect opens the netlink socket used to receive udev events.
+func (m *Monitor) Connect() error {
+	if err := m.netlinkConn.Connect(netlink.UdevEvent); err != nil {
+		return fmt.Errorf("cannot start udev monitor: %v", err)
+	}
+	return nil
+}
+
+// Run starts reporting devices in the background: first the devices that
+// are already present, then the ones being added or removed.
+func (m *Monitor) Run() error {
+	m.tomb.Go(func() error {
+		// The socket is already connected, so events about devices
+		// added or removed during the enumeration are not lost.
+		if devices, err := hotplug.EnumerateExistingDevices(); err != nil {
+			logger.Noticef("%v", err)
+		} else {
+			for _, dev := range devices {
+				m.seen[dev.DevicePath()] = true
+				m.deviceAdded(dev)
+			}
+			m.enumerationDone()
+		}
+
+		events := make(chan *netlink.UEvent)
+		errors := make(chan error)
+		stop := make(chan struct{})
+		m.tomb.Go(func() error {
+			m.netlinkConn.Monitor(events, errors, stop)
+			return m.netlinkConn.Close()
+		})
+		for {
+			select {
+			case err := <-errors:
+				logger.Noticef("udev event error: %v", err)
+			case ev := <-events:
+				m.udevEvent(ev)
+			case <-m.tomb.Dying():
+				close(stop)
+				return nil
+			}
+		}
+	})
+	return nil
+}
+
+// Stop stops reporting devices. It must only be called after Run.
+func (m *Monitor) Stop() error {
+	m.tomb.Kill(nil)
+	return m.tomb.Wait()
+}
+
+func (m *Monitor) udevEvent(ev *netlink.UEvent) {
+	switch ev.Action {
+	case netlink.ADD:
+		m.addDevice(ev.Env)
+	case netlink.REMOVE:
+		m.removeDevice(ev.Env)
+	}
+}
+
+func (m *Monitor) addDevice(env map[string]string) {
+	di, err := hotplug.NewHotplugDeviceInfo(env)
+	if err != nil {
+		return
+	}
+	if m.seen[di.DevicePath()] {
+		return
+	}
+	m.seen[di.DevicePath()] = true
+	m.deviceAdded(di)
+}
+
+func (m *Monitor) removeDevice(env map[string]string) {
+	di, err := hotplug.NewHotplugDeviceInfo(env)
+	if err != nil {
+		return
+	}
+	delete(m.seen, di.DevicePath())
+	m.deviceRemoved(di)
+}
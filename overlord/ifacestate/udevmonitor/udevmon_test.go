@@ -0,0 +1,77 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package udevmonitor
+
+import (
+	"testing"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/interfaces/hotplug"
+	"github.com/snapcore/snapd/osutil/udev/netlink"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type udevMonitorSuite struct{}
+
+var _ = Suite(&udevMonitorSuite{})
+
+func (s *udevMonitorSuite) TestUdevEvents(c *C) {
+	var added, removed []string
+	mon := New(func(di *hotplug.HotplugDeviceInfo) {
+		added = append(added, di.DevicePath())
+	}, func(di *hotplug.HotplugDeviceInfo) {
+		removed = append(removed, di.DevicePath())
+	}, func() {}).(*Monitor)
+
+	env := map[string]string{
+		"ACTION":    "add",
+		"DEVPATH":   "/devices/a/tty/ttyACM0",
+		"SUBSYSTEM": "tty",
+	}
+	mon.udevEvent(&netlink.UEvent{Action: netlink.ADD, KObj: env["DEVPATH"], Env: env})
+	// a device already reported is not reported again
+	mon.udevEvent(&netlink.UEvent{Action: netlink.ADD, KObj: env["DEVPATH"], Env: env})
+	// other actions are ignored
+	mon.udevEvent(&netlink.UEvent{Action: netlink.BIND, KObj: env["DEVPATH"], Env: env})
+	c.Check(added, DeepEquals, []string{"/sys/devices/a/tty/ttyACM0"})
+	c.Check(removed, HasLen, 0)
+
+	mon.udevEvent(&netlink.UEvent{Action: netlink.REMOVE, KObj: env["DEVPATH"], Env: env})
+	c.Check(removed, DeepEquals, []string{"/sys/devices/a/tty/ttyACM0"})
+
+	// the device can be added again once removed
+	mon.udevEvent(&netlink.UEvent{Action: netlink.ADD, KObj: env["DEVPATH"], Env: env})
+	c.Check(added, DeepEquals, []string{"/sys/devices/a/tty/ttyACM0", "/sys/devices/a/tty/ttyACM0"})
+}
+
+func (s *udevMonitorSuite) TestUdevEventWithoutDevicePath(c *C) {
+	called := false
+	mon := New(func(di *hotplug.HotplugDeviceInfo) {
+		called = true
+	}, func(di *hotplug.HotplugDeviceInfo) {
+		called = true
+	}, func() {}).(*Monitor)
+
+	env := map[string]string{"ACTION": "add"}
+	mon.udevEvent(&netlink.UEvent{Action: netlink.ADD, Env: env})
+	mon.udevEvent(&netlink.UEvent{Action: netlink.REMOVE, Env: env})
+	c.Check(called, Equals, false)
+}
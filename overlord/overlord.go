@@ -96,6 +96,12 @@ func New() (*Overlord, error) {
 		ensureBefore:   o.ensureBefore,
 		requestRestart: o.requestRestart,
 	}
+	// a reset of the system replaces the state, if it cannot be
+	// carried out now the request is kept and the reset is retried
+	// on the next start
+	if err := devicestate.ApplyPendingReset(); err != nil {
+		logger.Noticef("cannot apply pending system reset: %v", err)
+	}
 	s, err := loadState(backend)
 	if err != nil {
 		return nil, err
@@ -33,6 +33,7 @@ import (
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/hookstate"
@@ -131,6 +132,26 @@ func (ovs *overlordSuite) TestNewWithInvalidState(c *C) {
 	c.Assert(err, ErrorMatches, "EOF")
 }
 
+func (ovs *overlordSuite) TestNewWithFailingReset(c *C) {
+	fakeState := []byte(fmt.Sprintf(`{"data":{"patch-level":%d,"some":"data"},"changes":null,"tasks":null,"last-change-id":0,"last-task-id":0,"last-lane-id":0}`, patch.Level))
+	c.Assert(ioutil.WriteFile(dirs.SnapStateFile, fakeState, 0600), IsNil)
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapSystemResetFile), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(dirs.SnapSystemResetFile, []byte(`garbage`), 0600), IsNil)
+
+	o, err := overlord.New()
+	c.Assert(err, IsNil)
+
+	st := o.State()
+	st.Lock()
+	defer st.Unlock()
+	var some string
+	c.Assert(st.Get("some", &some), IsNil)
+	c.Check(some, Equals, "data")
+
+	// the request is kept for a retry
+	c.Check(osutil.FileExists(dirs.SnapSystemResetFile), Equals, true)
+}
+
 func (ovs *overlordSuite) TestNewWithPatches(c *C) {
 	p := func(s *state.State) error {
 		s.Set("patched", true)
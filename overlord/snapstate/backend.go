@@ -47,6 +47,8 @@ type StoreService interface {
 	SuggestedCurrency() string
 	Buy(options *store.BuyOptions, user *auth.UserState) (*store.BuyResult, error)
 	ReadyToBuy(*auth.UserState) error
+
+	ConnectivityCheck() error
 }
 
 type managerBackend interface {
@@ -56,6 +58,7 @@ type managerBackend interface {
 	LinkSnap(info *snap.Info) error
 	StartServices(svcs []*snap.AppInfo, meter progress.Meter) error
 	StopServices(svcs []*snap.AppInfo, reason snap.ServiceStopReason, meter progress.Meter) error
+	ReloadOrRestartServices(svcs []*snap.AppInfo, meter progress.Meter) error
 
 	// the undoers for install
 	UndoSetupSnap(s snap.PlaceInfo, typ snap.Type, meter progress.Meter) error
@@ -78,6 +78,10 @@ func (b Backend) StartServices(apps []*snap.AppInfo, meter progress.Meter) error
 	return wrappers.StartServices(apps, meter)
 }
 
+func (b Backend) ReloadOrRestartServices(apps []*snap.AppInfo, meter progress.Meter) error {
+	return wrappers.ReloadOrRestartServices(apps, meter)
+}
+
 func (b Backend) StopServices(apps []*snap.AppInfo, reason snap.ServiceStopReason, meter progress.Meter) error {
 	return wrappers.StopServices(apps, reason, meter)
 }
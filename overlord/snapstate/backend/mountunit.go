@@ -28,6 +28,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/systemd"
 )
@@ -42,6 +43,15 @@ func addMountUnit(s *snap.Info, meter progress.Meter) error {
 		return err
 	}
 
+	if release.Preseeding {
+		// systemd is not running when preseeding an image, enable
+		// the unit for the first boot and mount the snap directly
+		if err := sysd.Enable(mountUnitName); err != nil {
+			return err
+		}
+		return mountSnap(s.MountFile(), s.MountDir())
+	}
+
 	// we need to do a daemon-reload here to ensure that systemd really
 	// knows about this new mount unit file
 	if err := sysd.DaemonReload(); err != nil {
@@ -55,6 +65,16 @@ func addMountUnit(s *snap.Info, meter progress.Meter) error {
 	return sysd.Start(mountUnitName)
 }
 
+func mountSnap(squashfsPath, whereDir string) error {
+	if err := os.MkdirAll(whereDir, 0755); err != nil {
+		return err
+	}
+	if output, err := exec.Command("mount", "-t", "squashfs", "-o", "ro,nodev", squashfsPath, whereDir).CombinedOutput(); err != nil {
+		return osutil.OutputErr(output, err)
+	}
+	return nil
+}
+
 func removeMountUnit(baseDir string, meter progress.Meter) error {
 	sysd := systemd.New(dirs.GlobalRootDir, meter)
 	unit := systemd.MountUnitPath(dirs.StripRootDir(baseDir))
@@ -30,6 +30,7 @@ import (
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/snapstate/backend"
 	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/systemd"
 	"github.com/snapcore/snapd/testutil"
@@ -91,6 +92,42 @@ WantedBy=multi-user.target
 `[1:], dirs.StripRootDir(dirs.SnapMountDir)))
 }
 
+func (s *mountunitSuite) TestAddMountUnitPreseeding(c *C) {
+	restore := release.MockPreseeding(true)
+	defer restore()
+
+	var sysdCalls [][]string
+	restoreSystemctl := systemd.MockSystemctl(func(cmd ...string) ([]byte, error) {
+		sysdCalls = append(sysdCalls, cmd)
+		return nil, nil
+	})
+	defer restoreSystemctl()
+	mount := testutil.MockCommand(c, "mount", "")
+	defer mount.Restore()
+
+	info := &snap.Info{
+		SideInfo: snap.SideInfo{
+			RealName: "foo",
+			Revision: snap.R(13),
+		},
+		Version:       "1.1",
+		Architectures: []string{"all"},
+	}
+	err := backend.AddMountUnit(info, progress.Null)
+	c.Assert(err, IsNil)
+
+	un := fmt.Sprintf("%s.mount", systemd.EscapeUnitNamePath(filepath.Join(dirs.StripRootDir(dirs.SnapMountDir), "foo", "13")))
+	c.Check(osutil.FileExists(filepath.Join(dirs.SnapServicesDir, un)), Equals, true)
+	// the unit is enabled but not started, the snap is mounted directly
+	c.Check(sysdCalls, DeepEquals, [][]string{
+		{"--root", dirs.GlobalRootDir, "enable", un},
+	})
+	c.Check(mount.Calls(), DeepEquals, [][]string{
+		{"mount", "-t", "squashfs", "-o", "ro,nodev", info.MountFile(), info.MountDir()},
+	})
+	c.Check(osutil.IsDirectory(info.MountDir()), Equals, true)
+}
+
 func (s *mountunitSuite) TestRemoveMountUnit(c *C) {
 	info := &snap.Info{
 		SideInfo: snap.SideInfo{
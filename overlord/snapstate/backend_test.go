@@ -54,6 +54,8 @@ type fakeOp struct {
 	aliases   []*backend.Alias
 	rmAliases []*backend.Alias
 
+	services []string
+
 	userID int
 }
 
@@ -406,6 +408,7 @@ apps:
     daemon: simple
   svc2:
     daemon: simple
+    reload-on-config: true
 `))
 		if err != nil {
 			panic(err)
@@ -512,6 +515,19 @@ func (f *fakeSnappyBackend) StopServices(svcs []*snap.AppInfo, reason snap.Servi
 	return nil
 }
 
+func (f *fakeSnappyBackend) ReloadOrRestartServices(svcs []*snap.AppInfo, meter progress.Meter) error {
+	names := make([]string, len(svcs))
+	for i, svc := range svcs {
+		names[i] = svc.Name
+	}
+	f.ops = append(f.ops, fakeOp{
+		op:       "reload-snap-services",
+		name:     svcSnapMountDir(svcs),
+		services: names,
+	})
+	return nil
+}
+
 func (f *fakeSnappyBackend) UndoSetupSnap(s snap.PlaceInfo, typ snap.Type, p progress.Meter) error {
 	p.Notify("setup-snap")
 	f.ops = append(f.ops, fakeOp{
@@ -51,8 +51,8 @@ func nameAndRevnoFromSnap(sn string) (string, snap.Revision, error) {
 // fallback logic will revert to "os=v1" but on the filesystem snappy
 // still has the "active" version set to "v2" which is
 // misleading. This code will check what kernel/os booted and set
-// those versions active.To do this it creates a Change and kicks
-// start it directly.
+// those versions active.To do this it creates a Change, whose summary
+// reports the rollback, and kicks start it directly.
 func UpdateBootRevisions(st *state.State) error {
 	const errorPrefix = "cannot update revisions after boot changes: "
 
@@ -80,7 +80,9 @@ func UpdateBootRevisions(st *state.State) error {
 	}
 
 	var tsAll []*state.TaskSet
-	for _, snapNameAndRevno := range []string{m["snap_kernel"], m["snap_core"]} {
+	var rolledBack []string
+	for _, kind := range []string{"kernel", "core"} {
+		snapNameAndRevno := m["snap_"+kind]
 		name, rev, err := nameAndRevnoFromSnap(snapNameAndRevno)
 		if err != nil {
 			logger.Noticef("cannot parse %q: %s", snapNameAndRevno, err)
@@ -99,6 +101,7 @@ func UpdateBootRevisions(st *state.State) error {
 				return err
 			}
 			tsAll = append(tsAll, ts)
+			rolledBack = append(rolledBack, fmt.Sprintf("%s rev %s to rev %s", kind, info.Revision, rev))
 		}
 	}
 
@@ -106,7 +109,7 @@ func UpdateBootRevisions(st *state.State) error {
 		return nil
 	}
 
-	msg := fmt.Sprintf("Update kernel and core snap revisions")
+	msg := fmt.Sprintf("Boot rolled back from %s", strings.Join(rolledBack, " and "))
 	chg := st.NewChange("update-revisions", msg)
 	for _, ts := range tsAll {
 		chg.AddAll(ts)
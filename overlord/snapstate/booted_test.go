@@ -142,6 +142,7 @@ func (bs *bootedSuite) TestUpdateBootRevisionsOSSimple(c *C) {
 	chg := st.Changes()[0]
 	c.Assert(chg.Err(), IsNil)
 	c.Assert(chg.Kind(), Equals, "update-revisions")
+	c.Check(chg.Summary(), Equals, "Boot rolled back from core rev 2 to rev 1")
 	c.Assert(chg.IsReady(), Equals, true)
 
 	// core "current" got reverted but canonical-pc-linux did not
@@ -176,6 +177,7 @@ func (bs *bootedSuite) TestUpdateBootRevisionsKernelSimple(c *C) {
 	chg := st.Changes()[0]
 	c.Assert(chg.Err(), IsNil)
 	c.Assert(chg.Kind(), Equals, "update-revisions")
+	c.Check(chg.Summary(), Equals, "Boot rolled back from kernel rev 2 to rev 1")
 	c.Assert(chg.IsReady(), Equals, true)
 
 	// canonical-pc-linux "current" got reverted but core did not
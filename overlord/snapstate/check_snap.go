@@ -21,12 +21,15 @@ package snapstate
 
 import (
 	"fmt"
+	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/snapcore/snapd/arch"
 	"github.com/snapcore/snapd/cmd"
+	"github.com/snapcore/snapd/gadget"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/snapstate/backend"
 	"github.com/snapcore/snapd/overlord/state"
@@ -191,6 +194,36 @@ func validateContainer(c snap.Container, s *snap.Info, logf func(format string,
 	return fmt.Errorf("%v; contact developer", err)
 }
 
+// validateGadgetYaml checks the layout of the volumes declared in
+// gadget.yaml of a gadget snap. Whether gadget.yaml is required at all is
+// decided when the gadget gets used.
+func validateGadgetYaml(c snap.Container) error {
+	gmeta, err := c.ReadFile("meta/gadget.yaml")
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	gi, err := snap.InfoFromGadgetYaml(gmeta, release.OnClassic)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(gi.Volumes))
+	for name := range gi.Volumes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		vol := gi.Volumes[name]
+		if err := gadget.ValidateVolume(name, &vol); err != nil {
+			return fmt.Errorf("cannot use gadget snap: %v", err)
+		}
+	}
+	return nil
+}
+
 // checkSnap ensures that the snap can be installed.
 func checkSnap(st *state.State, snapFilePath string, si *snap.SideInfo, curInfo *snap.Info, flags Flags) error {
 	// This assumes that the snap was already verified or --dangerous was used.
@@ -212,7 +245,7 @@ func checkSnap(st *state.State, snapFilePath string, si *snap.SideInfo, curInfo
 	defer st.Unlock()
 
 	for _, check := range checkSnapCallbacks {
-		err := check(st, s, curInfo, flags)
+		err := check(st, s, curInfo, c, flags)
 		if err != nil {
 			return err
 		}
@@ -222,7 +255,7 @@ func checkSnap(st *state.State, snapFilePath string, si *snap.SideInfo, curInfo
 }
 
 // CheckSnapCallback defines callbacks for checking a snap for installation or refresh.
-type CheckSnapCallback func(st *state.State, snap, curSnap *snap.Info, flags Flags) error
+type CheckSnapCallback func(st *state.State, snap, curSnap *snap.Info, snapf snap.Container, flags Flags) error
 
 var checkSnapCallbacks []CheckSnapCallback
 
@@ -239,7 +272,7 @@ func MockCheckSnapCallbacks(checks []CheckSnapCallback) (restore func()) {
 	}
 }
 
-func checkCoreName(st *state.State, snapInfo, curInfo *snap.Info, flags Flags) error {
+func checkCoreName(st *state.State, snapInfo, curInfo *snap.Info, snapf snap.Container, flags Flags) error {
 	if snapInfo.Type != snap.TypeOS {
 		// not a relevant check
 		return nil
@@ -275,13 +308,16 @@ func checkCoreName(st *state.State, snapInfo, curInfo *snap.Info, flags Flags) e
 	return nil
 }
 
-func checkGadgetOrKernel(st *state.State, snapInfo, curInfo *snap.Info, flags Flags) error {
+func checkGadgetOrKernel(st *state.State, snapInfo, curInfo *snap.Info, snapf snap.Container, flags Flags) error {
 	kind := ""
 	var currentInfo func(*state.State) (*snap.Info, error)
 	switch snapInfo.Type {
 	case snap.TypeGadget:
 		kind = "gadget"
 		currentInfo = GadgetInfo
+		if err := validateGadgetYaml(snapf); err != nil {
+			return err
+		}
 	case snap.TypeKernel:
 		kind = "kernel"
 		currentInfo = KernelInfo
@@ -319,7 +355,7 @@ func checkGadgetOrKernel(st *state.State, snapInfo, curInfo *snap.Info, flags Fl
 	return nil
 }
 
-func checkBases(st *state.State, snapInfo, curInfo *snap.Info, flags Flags) error {
+func checkBases(st *state.State, snapInfo, curInfo *snap.Info, snapf snap.Container, flags Flags) error {
 	// check if this is relevant
 	if snapInfo.Type != snap.TypeApp && snapInfo.Type != snap.TypeGadget {
 		return nil
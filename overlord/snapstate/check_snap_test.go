@@ -193,7 +193,7 @@ version: 1.0`
 	defer r1()
 
 	checkCbCalled := false
-	checkCb := func(st *state.State, s, cur *snap.Info, flags snapstate.Flags) error {
+	checkCb := func(st *state.State, s, cur *snap.Info, snapf snap.Container, flags snapstate.Flags) error {
 		c.Assert(s.Name(), Equals, "foo")
 		c.Assert(s.SnapID, Equals, "snap-id")
 		checkCbCalled = true
@@ -222,7 +222,7 @@ version: 1.0`
 	defer restore()
 
 	fail := errors.New("bad snap")
-	checkCb := func(st *state.State, s, cur *snap.Info, flags snapstate.Flags) error {
+	checkCb := func(st *state.State, s, cur *snap.Info, snapf snap.Container, flags snapstate.Flags) error {
 		return fail
 	}
 	r2 := snapstate.MockCheckSnapCallbacks(nil)
@@ -275,6 +275,45 @@ version: 2
 	c.Check(err, IsNil)
 }
 
+func (s *checkSnapSuite) TestCheckSnapGadgetInvalidGadgetYaml(c *C) {
+	reset := release.MockOnClassic(false)
+	defer reset()
+
+	st := state.New(nil)
+
+	const yaml = `name: gadget
+type: gadget
+version: 2
+`
+	info, err := snap.InfoFromSnapYaml([]byte(yaml))
+	c.Assert(err, IsNil)
+
+	container := emptyContainer(c)
+	err = ioutil.WriteFile(filepath.Join(container.Path(), "meta", "gadget.yaml"), []byte(`
+volumes:
+  pc:
+    bootloader: grub
+    structure:
+      - name: foo
+        type: 0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        size: 2M
+      - name: bar
+        type: 0FC63DAF-8483-4772-8E79-3D69D8477DE4
+        offset: 2M
+        size: 1M
+`), 0644)
+	c.Assert(err, IsNil)
+
+	var openSnapFile = func(path string, si *snap.SideInfo) (*snap.Info, snap.Container, error) {
+		return info, container, nil
+	}
+	restore := snapstate.MockOpenSnapFile(openSnapFile)
+	defer restore()
+
+	err = snapstate.CheckSnap(st, "snap-path", nil, nil, snapstate.Flags{})
+	c.Check(err, ErrorMatches, `cannot use gadget snap: invalid volume "pc": cannot lay out volume: structure #1 \("bar"\) overlaps with preceding structure #0 \("foo"\)`)
+}
+
 func (s *checkSnapSuite) TestCheckSnapGadgetUpdateLocal(c *C) {
 	reset := release.MockOnClassic(false)
 	defer reset()
@@ -25,6 +25,7 @@ import (
 
 	"gopkg.in/tomb.v2"
 
+	"github.com/snapcore/snapd/gadget"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
 )
@@ -58,6 +59,7 @@ func (m *SnapManager) AddForeignTaskHandlers(tracker ForeignTaskTracker) {
 	}
 	m.runner.AddHandler("setup-profiles", fakeHandler, fakeHandler)
 	m.runner.AddHandler("auto-connect", fakeHandler, nil)
+	m.runner.AddHandler("auto-disconnect", fakeHandler, nil)
 	m.runner.AddHandler("remove-profiles", fakeHandler, fakeHandler)
 	m.runner.AddHandler("discard-conns", fakeHandler, fakeHandler)
 	m.runner.AddHandler("validate-snap", fakeHandler, nil)
@@ -89,6 +91,18 @@ func MockReadInfo(mock func(name string, si *snap.SideInfo) (*snap.Info, error))
 	return func() { readInfo = old }
 }
 
+func MockGadgetUpdate(mock func(current, update gadget.GadgetData, rollbackDir string) error) (restore func()) {
+	old := gadgetUpdate
+	gadgetUpdate = mock
+	return func() { gadgetUpdate = old }
+}
+
+func MockGadgetRollback(mock func(current, update gadget.GadgetData, rollbackDir string) error) (restore func()) {
+	old := gadgetRollback
+	gadgetRollback = mock
+	return func() { gadgetRollback = old }
+}
+
 func MockOpenSnapFile(mock func(path string, si *snap.SideInfo) (*snap.Info, snap.Container, error)) (restore func()) {
 	prevOpenSnapFile := openSnapFile
 	openSnapFile = mock
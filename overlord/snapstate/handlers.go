@@ -22,6 +22,7 @@ package snapstate
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -29,6 +30,8 @@ import (
 	"gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/gadget"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/configstate/config"
@@ -614,6 +617,138 @@ func (m *SnapManager) cleanupCopySnapData(t *state.Task, _ *tomb.Tomb) error {
 	return nil
 }
 
+var (
+	gadgetUpdate   = gadget.Update
+	gadgetRollback = gadget.Rollback
+)
+
+func gadgetDataFromInfo(info *snap.Info) (*gadget.GadgetData, error) {
+	gi, err := snap.ReadGadgetInfo(info, false)
+	if err != nil {
+		return nil, err
+	}
+	return &gadget.GadgetData{Info: gi, RootDir: info.MountDir()}, nil
+}
+
+// gadgetUpdateData returns the gadget data of the current and of the
+// candidate revision of the gadget snap being refreshed.
+func gadgetUpdateData(snapsup *SnapSetup, snapst *SnapState) (current, update *gadget.GadgetData, err error) {
+	newInfo, err := readInfo(snapsup.Name(), snapsup.SideInfo)
+	if err != nil {
+		return nil, nil, err
+	}
+	currentInfo, err := snapst.CurrentInfo()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	current, err = gadgetDataFromInfo(currentInfo)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot read current gadget snap details: %v", err)
+	}
+	update, err = gadgetDataFromInfo(newInfo)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot read candidate gadget snap details: %v", err)
+	}
+	return current, update, nil
+}
+
+func gadgetRollbackDir(snapsup *SnapSetup) string {
+	return filepath.Join(dirs.SnapRollbackDir, fmt.Sprintf("%s_%s", snapsup.Name(), snapsup.Revision()))
+}
+
+func (m *SnapManager) doUpdateGadgetAssets(t *state.Task, _ *tomb.Tomb) error {
+	if release.OnClassic {
+		return nil
+	}
+
+	st := t.State()
+	st.Lock()
+	snapsup, snapst, err := snapSetupAndState(t)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	currentData, updateData, err := gadgetUpdateData(snapsup, snapst)
+	if err != nil {
+		return err
+	}
+
+	// the backup is kept until the change is ready, so that the update
+	// can be undone if a later task fails
+	if err := gadgetUpdate(*currentData, *updateData, gadgetRollbackDir(snapsup)); err != nil {
+		if err == gadget.ErrNoUpdate {
+			// nothing to update
+			return nil
+		}
+		return err
+	}
+
+	st.Lock()
+	defer st.Unlock()
+	t.Set("gadget-assets-updated", true)
+	t.Logf("Updated gadget assets, requested system restart.")
+	st.RequestRestart(state.RestartSystem)
+
+	return nil
+}
+
+func (m *SnapManager) undoUpdateGadgetAssets(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	var updated bool
+	if err := t.Get("gadget-assets-updated", &updated); err != nil && err != state.ErrNoState {
+		st.Unlock()
+		return err
+	}
+	snapsup, snapst, err := snapSetupAndState(t)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return nil
+	}
+
+	currentData, updateData, err := gadgetUpdateData(snapsup, snapst)
+	if err != nil {
+		return err
+	}
+
+	rollbackDir := gadgetRollbackDir(snapsup)
+	if err := gadgetRollback(*currentData, *updateData, rollbackDir); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(rollbackDir); err != nil {
+		logger.Noticef("cannot remove gadget update backup directory %q: %v", rollbackDir, err)
+	}
+
+	st.Lock()
+	defer st.Unlock()
+	t.Set("gadget-assets-updated", false)
+	t.Logf("Restored gadget assets, requested system restart.")
+	st.RequestRestart(state.RestartSystem)
+
+	return nil
+}
+
+func (m *SnapManager) cleanupUpdateGadgetAssets(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	snapsup, err := TaskSnapSetup(t)
+	st.Unlock()
+	if err != nil {
+		return err
+	}
+
+	rollbackDir := gadgetRollbackDir(snapsup)
+	if err := os.RemoveAll(rollbackDir); err != nil {
+		logger.Noticef("cannot remove gadget update backup directory %q: %v", rollbackDir, err)
+	}
+	return nil
+}
+
 func (m *SnapManager) doLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 	st := t.State()
 	st.Lock()
@@ -732,6 +867,10 @@ func (m *SnapManager) doLinkSnap(t *state.Task, _ *tomb.Tomb) error {
 // maybeRestart will schedule a reboot or restart as needed for the just linked
 // snap with info if it's a core or kernel snap.
 func maybeRestart(t *state.Task, info *snap.Info) {
+	if release.Preseeding {
+		// nothing is running yet when preseeding an image
+		return
+	}
 	st := t.State()
 	if release.OnClassic && info.Type == snap.TypeOS {
 		t.Logf("Requested daemon restart.")
@@ -968,6 +1107,58 @@ func (m *SnapManager) stopSnapServices(t *state.Task, _ *tomb.Tomb) error {
 	return err
 }
 
+func (m *SnapManager) reloadSnapServices(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	_, snapst, err := snapSetupAndState(t)
+	if err != nil {
+		return err
+	}
+	if !snapst.Active {
+		// disabled snaps have no services running
+		return nil
+	}
+
+	currentInfo, err := snapst.CurrentInfo()
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	if err := t.Get("services", &names); err != nil && err != state.ErrNoState {
+		return err
+	}
+
+	var svcs []*snap.AppInfo
+	if len(names) == 0 {
+		for _, svc := range currentInfo.Services() {
+			if svc.ReloadOnConfig {
+				svcs = append(svcs, svc)
+			}
+		}
+	} else {
+		for _, name := range names {
+			svc, ok := currentInfo.Apps[name]
+			if !ok || !svc.IsService() {
+				// the service went away, e.g. with an undone refresh
+				continue
+			}
+			svcs = append(svcs, svc)
+		}
+	}
+	if len(svcs) == 0 {
+		return nil
+	}
+
+	pb := NewTaskProgressAdapterUnlocked(t)
+	st.Unlock()
+	err = m.backend.ReloadOrRestartServices(svcs, pb)
+	st.Lock()
+	return err
+}
+
 func (m *SnapManager) doUnlinkSnap(t *state.Task, _ *tomb.Tomb) error {
 	// invoked only if snap has a current active revision
 	st := t.State()
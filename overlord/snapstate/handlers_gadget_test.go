@@ -0,0 +1,298 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+package snapstate_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/gadget"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+type gadgetUpdateSuite struct {
+	state   *state.State
+	snapmgr *snapstate.SnapManager
+
+	fakeBackend  *fakeSnappyBackend
+	stateBackend *witnessRestartReqStateBackend
+
+	reset func()
+}
+
+var _ = Suite(&gadgetUpdateSuite{})
+
+const gadgetSnapYaml = `name: foo-gadget
+version: 1.0
+type: gadget
+`
+
+const gadgetUpdateYaml = `volumes:
+  pc:
+    bootloader: grub
+    structure:
+      - name: system-boot
+        type: EF,C12A7328-F81F-11D2-BA4B-00A0C93EC93B
+        size: 1M
+        filesystem: vfat
+        update:
+          edition: %d
+`
+
+func (s *gadgetUpdateSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+
+	s.stateBackend = &witnessRestartReqStateBackend{}
+	s.fakeBackend = &fakeSnappyBackend{}
+	s.state = state.New(s.stateBackend)
+
+	var err error
+	s.snapmgr, err = snapstate.Manager(s.state)
+	c.Assert(err, IsNil)
+	s.snapmgr.AddForeignTaskHandlers(s.fakeBackend)
+	snapstate.SetSnapManagerBackend(s.snapmgr, s.fakeBackend)
+
+	restoreOnClassic := release.MockOnClassic(false)
+	s.reset = func() {
+		restoreOnClassic()
+		dirs.SetRootDir("/")
+	}
+}
+
+func (s *gadgetUpdateSuite) TearDownTest(c *C) {
+	s.reset()
+}
+
+func mockGadget(c *C, rev snap.Revision, edition int) *snap.Info {
+	info := snaptest.MockSnap(c, gadgetSnapYaml, &snap.SideInfo{RealName: "foo-gadget", Revision: rev})
+	content := []byte(fmt.Sprintf(gadgetUpdateYaml, edition))
+	c.Assert(ioutil.WriteFile(filepath.Join(info.MountDir(), "meta/gadget.yaml"), content, 0644), IsNil)
+	return info
+}
+
+func (s *gadgetUpdateSuite) runUpdateGadgetAssets(c *C) *state.Task {
+	si1 := &snap.SideInfo{RealName: "foo-gadget", Revision: snap.R(1)}
+	si2 := &snap.SideInfo{RealName: "foo-gadget", Revision: snap.R(2)}
+
+	s.state.Lock()
+	snapstate.Set(s.state, "foo-gadget", &snapstate.SnapState{
+		SnapType: "gadget",
+		Sequence: []*snap.SideInfo{si1},
+		Current:  si1.Revision,
+		Active:   true,
+	})
+	t := s.state.NewTask("update-gadget-assets", "test")
+	t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: si2})
+	chg := s.state.NewChange("dummy", "...")
+	chg.AddTask(t)
+	s.state.Unlock()
+
+	s.snapmgr.Ensure()
+	s.snapmgr.Wait()
+
+	return t
+}
+
+func (s *gadgetUpdateSuite) TestUpdateGadgetAssetsHappy(c *C) {
+	mockGadget(c, snap.R(1), 1)
+	mockGadget(c, snap.R(2), 2)
+
+	var called int
+	restore := snapstate.MockGadgetUpdate(func(current, update gadget.GadgetData, rollbackDir string) error {
+		called++
+		c.Check(current.RootDir, Equals, filepath.Join(dirs.SnapMountDir, "foo-gadget/1"))
+		c.Check(update.RootDir, Equals, filepath.Join(dirs.SnapMountDir, "foo-gadget/2"))
+		c.Check(current.Info.Volumes["pc"].Structure[0].Update.Edition, Equals, uint32(1))
+		c.Check(update.Info.Volumes["pc"].Structure[0].Update.Edition, Equals, uint32(2))
+		c.Check(rollbackDir, Equals, filepath.Join(dirs.SnapRollbackDir, "foo-gadget_2"))
+		return nil
+	})
+	defer restore()
+
+	t := s.runUpdateGadgetAssets(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(t.Status(), Equals, state.DoneStatus)
+	c.Check(called, Equals, 1)
+	c.Check(s.stateBackend.restartRequested, DeepEquals, []state.RestartType{state.RestartSystem})
+}
+
+func (s *gadgetUpdateSuite) TestUpdateGadgetAssetsNothingToUpdate(c *C) {
+	mockGadget(c, snap.R(1), 1)
+	mockGadget(c, snap.R(2), 1)
+
+	restore := snapstate.MockGadgetUpdate(func(current, update gadget.GadgetData, rollbackDir string) error {
+		return gadget.ErrNoUpdate
+	})
+	defer restore()
+
+	t := s.runUpdateGadgetAssets(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(t.Status(), Equals, state.DoneStatus)
+	c.Check(s.stateBackend.restartRequested, HasLen, 0)
+}
+
+func (s *gadgetUpdateSuite) TestUpdateGadgetAssetsError(c *C) {
+	mockGadget(c, snap.R(1), 1)
+	mockGadget(c, snap.R(2), 2)
+
+	restore := snapstate.MockGadgetUpdate(func(current, update gadget.GadgetData, rollbackDir string) error {
+		return errors.New("failed")
+	})
+	defer restore()
+
+	t := s.runUpdateGadgetAssets(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(t.Status(), Equals, state.ErrorStatus)
+	c.Check(t.Change().Err(), ErrorMatches, `(?s).*\(failed\)`)
+	c.Check(s.stateBackend.restartRequested, HasLen, 0)
+}
+
+func (s *gadgetUpdateSuite) TestUpdateGadgetAssetsBrokenGadget(c *C) {
+	mockGadget(c, snap.R(1), 1)
+	snaptest.MockSnap(c, gadgetSnapYaml, &snap.SideInfo{RealName: "foo-gadget", Revision: snap.R(2)})
+
+	restore := snapstate.MockGadgetUpdate(func(current, update gadget.GadgetData, rollbackDir string) error {
+		c.Fatalf("unexpected call")
+		return nil
+	})
+	defer restore()
+
+	t := s.runUpdateGadgetAssets(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(t.Status(), Equals, state.ErrorStatus)
+	c.Check(t.Change().Err(), ErrorMatches, `(?s).*cannot read candidate gadget snap details: .*`)
+}
+
+func (s *gadgetUpdateSuite) TestUpdateGadgetAssetsSkippedOnClassic(c *C) {
+	restoreOnClassic := release.MockOnClassic(true)
+	defer restoreOnClassic()
+
+	restore := snapstate.MockGadgetUpdate(func(current, update gadget.GadgetData, rollbackDir string) error {
+		c.Fatalf("unexpected call")
+		return nil
+	})
+	defer restore()
+
+	t := s.runUpdateGadgetAssets(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(t.Status(), Equals, state.DoneStatus)
+}
+
+func (s *gadgetUpdateSuite) TestUpdateGadgetAssetsBackupRemovedWhenReady(c *C) {
+	mockGadget(c, snap.R(1), 1)
+	mockGadget(c, snap.R(2), 2)
+
+	rollbackDir := filepath.Join(dirs.SnapRollbackDir, "foo-gadget_2")
+	restore := snapstate.MockGadgetUpdate(func(current, update gadget.GadgetData, rollbackDir string) error {
+		c.Assert(os.MkdirAll(rollbackDir, 0755), IsNil)
+		return ioutil.WriteFile(filepath.Join(rollbackDir, "backup"), nil, 0644)
+	})
+	defer restore()
+
+	t := s.runUpdateGadgetAssets(c)
+	for i := 0; i < 3; i++ {
+		s.snapmgr.Ensure()
+		s.snapmgr.Wait()
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(t.Status(), Equals, state.DoneStatus)
+	c.Check(t.Change().IsClean(), Equals, true)
+	c.Check(osutil.FileExists(rollbackDir), Equals, false)
+}
+
+func (s *gadgetUpdateSuite) TestUpdateGadgetAssetsUndoOnLinkFailure(c *C) {
+	mockGadget(c, snap.R(1), 1)
+	mockGadget(c, snap.R(2), 2)
+
+	rollbackDir := filepath.Join(dirs.SnapRollbackDir, "foo-gadget_2")
+	restore := snapstate.MockGadgetUpdate(func(current, update gadget.GadgetData, rollbackDir string) error {
+		c.Assert(os.MkdirAll(rollbackDir, 0755), IsNil)
+		return ioutil.WriteFile(filepath.Join(rollbackDir, "backup"), nil, 0644)
+	})
+	defer restore()
+	var rollbackCalled int
+	restore = snapstate.MockGadgetRollback(func(current, update gadget.GadgetData, dir string) error {
+		rollbackCalled++
+		c.Check(current.RootDir, Equals, filepath.Join(dirs.SnapMountDir, "foo-gadget/1"))
+		c.Check(update.RootDir, Equals, filepath.Join(dirs.SnapMountDir, "foo-gadget/2"))
+		c.Check(dir, Equals, rollbackDir)
+		// the backup was kept after the update
+		c.Check(osutil.FileExists(filepath.Join(dir, "backup")), Equals, true)
+		return nil
+	})
+	defer restore()
+
+	si1 := &snap.SideInfo{RealName: "foo-gadget", Revision: snap.R(1)}
+	si2 := &snap.SideInfo{RealName: "foo-gadget", Revision: snap.R(2)}
+	s.fakeBackend.linkSnapFailTrigger = filepath.Join(dirs.SnapMountDir, "foo-gadget/2")
+
+	s.state.Lock()
+	snapstate.Set(s.state, "foo-gadget", &snapstate.SnapState{
+		SnapType: "gadget",
+		Sequence: []*snap.SideInfo{si1},
+		Current:  si1.Revision,
+		Active:   true,
+	})
+	updateAssets := s.state.NewTask("update-gadget-assets", "test")
+	updateAssets.Set("snap-setup", &snapstate.SnapSetup{SideInfo: si2})
+	link := s.state.NewTask("link-snap", "test")
+	link.Set("snap-setup", &snapstate.SnapSetup{SideInfo: si2})
+	link.WaitFor(updateAssets)
+	chg := s.state.NewChange("dummy", "...")
+	chg.AddTask(updateAssets)
+	chg.AddTask(link)
+	s.state.Unlock()
+
+	for i := 0; i < 5; i++ {
+		s.snapmgr.Ensure()
+		s.snapmgr.Wait()
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(link.Status(), Equals, state.ErrorStatus)
+	c.Check(updateAssets.Status(), Equals, state.UndoneStatus)
+	c.Check(rollbackCalled, Equals, 1)
+	// restart requested once for the update and once for the undo
+	c.Check(s.stateBackend.restartRequested, DeepEquals, []state.RestartType{state.RestartSystem, state.RestartSystem})
+	c.Check(osutil.FileExists(rollbackDir), Equals, false)
+}
@@ -307,8 +307,13 @@ func Manager(st *state.State) (*SnapManager, error) {
 	runner.AddHandler("unlink-current-snap", m.doUnlinkCurrentSnap, m.undoUnlinkCurrentSnap)
 	runner.AddHandler("copy-snap-data", m.doCopySnapData, m.undoCopySnapData)
 	runner.AddCleanup("copy-snap-data", m.cleanupCopySnapData)
+	runner.AddHandler("update-gadget-assets", m.doUpdateGadgetAssets, m.undoUpdateGadgetAssets)
+	runner.AddCleanup("update-gadget-assets", m.cleanupUpdateGadgetAssets)
 	runner.AddHandler("link-snap", m.doLinkSnap, m.undoLinkSnap)
 	runner.AddHandler("start-snap-services", m.startSnapServices, m.stopSnapServices)
+	// reloading again on undo makes the services pick up the
+	// configuration that is current after the undo
+	runner.AddHandler("reload-snap-services", m.reloadSnapServices, m.reloadSnapServices)
 	runner.AddHandler("switch-snap-channel", m.doSwitchSnapChannel, nil)
 	runner.AddHandler("toggle-snap-flags", m.doToggleSnapFlags, nil)
 
@@ -163,6 +163,15 @@ func doInstall(st *state.State, snapst *SnapState, snapsup *SnapSetup, flags int
 		prev = unlink
 	}
 
+	// update gadget assets when refreshing the gadget
+	if snapst.IsInstalled() {
+		if typ, err := snapst.Type(); err == nil && typ == snap.TypeGadget {
+			updateGadgetAssets := st.NewTask("update-gadget-assets", fmt.Sprintf(i18n.G("Update assets from gadget %q%s"), snapsup.Name(), revisionStr))
+			addTask(updateGadgetAssets)
+			prev = updateGadgetAssets
+		}
+	}
+
 	// copy-data (needs stopped services by unlink)
 	if !snapsup.Flags.Revert {
 		copyData := st.NewTask("copy-snap-data", fmt.Sprintf(i18n.G("Copy snap %q data"), snapsup.Name()))
@@ -1153,6 +1162,36 @@ func Enable(st *state.State, name string) (*state.TaskSet, error) {
 	return state.NewTaskSet(prepareSnap, setupProfiles, linkSnap, setupAliases, startSnapServices), nil
 }
 
+// ReloadServices returns a taskset to reload, or restart if they do not
+// support reloading, the given running services of the snap. Without
+// service names the services that declare reload-on-config are used.
+func ReloadServices(st *state.State, name string, serviceNames []string) (*state.TaskSet, error) {
+	var snapst SnapState
+	err := Get(st, name, &snapst)
+	if err == state.ErrNoState {
+		return nil, &snap.NotInstalledError{Snap: name}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	snapsup := &SnapSetup{
+		SideInfo: snapst.CurrentSideInfo(),
+	}
+
+	summary := fmt.Sprintf(i18n.G("Reload snap %q services"), snapsup.Name())
+	if len(serviceNames) == 0 {
+		summary = fmt.Sprintf(i18n.G("Reload snap %q services on configuration change"), snapsup.Name())
+	}
+	reloadSnapServices := st.NewTask("reload-snap-services", summary)
+	reloadSnapServices.Set("snap-setup", &snapsup)
+	if len(serviceNames) > 0 {
+		reloadSnapServices.Set("services", serviceNames)
+	}
+
+	return state.NewTaskSet(reloadSnapServices), nil
+}
+
 // Disable sets a snap to the inactive state
 func Disable(st *state.State, name string) (*state.TaskSet, error) {
 	var snapst SnapState
@@ -1360,6 +1399,16 @@ func Remove(st *state.State, name string, revision snap.Revision) (*state.TaskSe
 			prev = removeHook
 		}
 
+		if removeAll {
+			// disconnect interfaces while the snap is still around to
+			// run its disconnect hooks
+			autoDisconnect := st.NewTask("auto-disconnect", fmt.Sprintf(i18n.G("Disconnect interfaces of snap %q"), name))
+			autoDisconnect.Set("snap-setup-task", stopSnapServices.ID())
+			autoDisconnect.WaitFor(prev)
+			tasks = append(tasks, autoDisconnect)
+			prev = autoDisconnect
+		}
+
 		removeAliases := st.NewTask("remove-aliases", fmt.Sprintf(i18n.G("Remove aliases for snap %q"), name))
 		removeAliases.WaitFor(prev)
 		removeAliases.Set("snap-setup-task", stopSnapServices.ID())
@@ -162,6 +162,7 @@ func (s *snapmgrTestSuite) TestKnownTaskKinds(c *C) {
 	c.Assert(kinds, DeepEquals, []string{
 		"alias",
 		"auto-connect",
+		"auto-disconnect",
 		"cleanup",
 		"clear-aliases",
 		"clear-snap",
@@ -180,6 +181,7 @@ func (s *snapmgrTestSuite) TestKnownTaskKinds(c *C) {
 		"prerequisites",
 		"prune-auto-aliases",
 		"refresh-aliases",
+		"reload-snap-services",
 		"remove-aliases",
 		"remove-profiles",
 		"run-hook",
@@ -195,6 +197,7 @@ func (s *snapmgrTestSuite) TestKnownTaskKinds(c *C) {
 		"unalias",
 		"unlink-current-snap",
 		"unlink-snap",
+		"update-gadget-assets",
 		"validate-snap"})
 }
 
@@ -246,6 +249,7 @@ const (
 	cleanupAfter
 	maybeCore
 	runCoreConfigure
+	updatesGadget
 )
 
 func taskKinds(tasks []*state.Task) []string {
@@ -335,6 +339,11 @@ func verifyUpdateTasks(c *C, opts, discards int, ts *state.TaskSet, st *state.St
 			"unlink-current-snap",
 		)
 	}
+	if opts&updatesGadget != 0 {
+		expected = append(expected,
+			"update-gadget-assets",
+		)
+	}
 	expected = append(expected,
 		"copy-snap-data",
 		"setup-profiles",
@@ -373,6 +382,7 @@ func verifyRemoveTasks(c *C, ts *state.TaskSet) {
 	c.Assert(taskKinds(ts.Tasks()), DeepEquals, []string{
 		"stop-snap-services",
 		"run-hook[remove]",
+		"auto-disconnect",
 		"remove-aliases",
 		"unlink-snap",
 		"remove-profiles",
@@ -1238,6 +1248,24 @@ func (s *snapmgrTestSuite) TestUpdateTasks(c *C) {
 	c.Check(snapsup.Channel, Equals, "some-channel")
 }
 
+func (s *snapmgrTestSuite) TestUpdateTasksGadget(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Channel:  "edge",
+		Sequence: []*snap.SideInfo{{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(7)}},
+		Current:  snap.R(7),
+		SnapType: "gadget",
+	})
+
+	ts, err := snapstate.Update(s.state, "some-snap", "some-channel", snap.R(0), s.user.ID, snapstate.Flags{})
+	c.Assert(err, IsNil)
+	verifyUpdateTasks(c, unlinkBefore|cleanupAfter|updatesGadget, 0, ts, s.state)
+	c.Assert(s.state.TaskCount(), Equals, len(ts.Tasks()))
+}
+
 func (s *snapmgrTestSuite) TestUpdateTasksCoreSetsIgnoreOnConfigure(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -3883,6 +3911,11 @@ func (s *snapmgrTestSuite) TestRemoveRunThrough(c *C) {
 	s.state.Lock()
 
 	expected := fakeOps{
+		{
+			op:    "auto-disconnect:Doing",
+			name:  "some-snap",
+			revno: snap.R(7),
+		},
 		{
 			op:   "remove-snap-aliases",
 			name: "some-snap",
@@ -3993,6 +4026,11 @@ func (s *snapmgrTestSuite) TestRemoveWithManyRevisionsRunThrough(c *C) {
 	s.state.Lock()
 
 	expected := fakeOps{
+		{
+			op:    "auto-disconnect:Doing",
+			name:  "some-snap",
+			revno: snap.R(7),
+		},
 		{
 			op:   "remove-snap-aliases",
 			name: "some-snap",
@@ -5273,6 +5311,126 @@ func (s *snapmgrTestSuite) TestDisableRunThrough(c *C) {
 	c.Assert(snapst.AliasesPending, Equals, true)
 }
 
+func (s *snapmgrTestSuite) TestReloadServicesRunThrough(c *C) {
+	si := snap.SideInfo{
+		RealName: "services-snap",
+		Revision: snap.R(7),
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "services-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{&si},
+		Current:  si.Revision,
+		Active:   true,
+	})
+
+	chg := s.state.NewChange("configure", "configure a snap")
+	// services with reload-on-config
+	ts, err := snapstate.ReloadServices(s.state, "services-snap", nil)
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+	// explicitly given services
+	ts, err = snapstate.ReloadServices(s.state, "services-snap", []string{"svc1"})
+	c.Assert(err, IsNil)
+	ts.WaitFor(chg.Tasks()[0])
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	expected := fakeOps{
+		{
+			op:       "reload-snap-services",
+			name:     filepath.Join(dirs.SnapMountDir, "services-snap/7"),
+			services: []string{"svc2"},
+		},
+		{
+			op:       "reload-snap-services",
+			name:     filepath.Join(dirs.SnapMountDir, "services-snap/7"),
+			services: []string{"svc1"},
+		},
+	}
+	c.Assert(s.fakeBackend.ops, DeepEquals, expected)
+}
+
+func (s *snapmgrTestSuite) TestReloadServicesUndo(c *C) {
+	si := snap.SideInfo{
+		RealName: "services-snap",
+		Revision: snap.R(7),
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "services-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{&si},
+		Current:  si.Revision,
+		Active:   true,
+	})
+
+	chg := s.state.NewChange("configure", "configure a snap")
+	ts, err := snapstate.ReloadServices(s.state, "services-snap", nil)
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	terr := s.state.NewTask("error-trigger", "provoking total undo")
+	terr.WaitAll(ts)
+	chg.AddTask(terr)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Check(chg.Status(), Equals, state.ErrorStatus)
+	c.Check(chg.Tasks()[0].Status(), Equals, state.UndoneStatus)
+	c.Check(s.fakeBackend.ops.Ops(), DeepEquals, []string{
+		"reload-snap-services",
+		"reload-snap-services",
+	})
+}
+
+func (s *snapmgrTestSuite) TestReloadServicesInactiveSnap(c *C) {
+	si := snap.SideInfo{
+		RealName: "services-snap",
+		Revision: snap.R(7),
+	}
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "services-snap", &snapstate.SnapState{
+		Sequence: []*snap.SideInfo{&si},
+		Current:  si.Revision,
+	})
+
+	chg := s.state.NewChange("configure", "configure a snap")
+	ts, err := snapstate.ReloadServices(s.state, "services-snap", nil)
+	c.Assert(err, IsNil)
+	chg.AddAll(ts)
+
+	s.state.Unlock()
+	defer s.snapmgr.Stop()
+	s.settle(c)
+	s.state.Lock()
+
+	c.Assert(chg.Err(), IsNil)
+	c.Check(s.fakeBackend.ops, HasLen, 0)
+}
+
+func (s *snapmgrTestSuite) TestReloadServicesNotInstalled(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	_, err := snapstate.ReloadServices(s.state, "services-snap", nil)
+	c.Assert(err, FitsTypeOf, &snap.NotInstalledError{})
+}
+
 func (s *snapmgrTestSuite) TestSwitchRunThrough(c *C) {
 	si := snap.SideInfo{
 		RealName: "some-snap",
@@ -7080,11 +7238,12 @@ func (s *snapmgrTestSuite) TestRemoveMany(c *C) {
 	c.Assert(tts, HasLen, 2)
 	c.Check(removed, DeepEquals, []string{"one", "two"})
 
-	c.Assert(s.state.TaskCount(), Equals, 8*2)
+	c.Assert(s.state.TaskCount(), Equals, 9*2)
 	for _, ts := range tts {
 		c.Assert(taskKinds(ts.Tasks()), DeepEquals, []string{
 			"stop-snap-services",
 			"run-hook[remove]",
+			"auto-disconnect",
 			"remove-aliases",
 			"unlink-snap",
 			"remove-profiles",
@@ -7498,6 +7657,11 @@ func (s *snapmgrTestSuite) TestTransitionCoreRunThrough(c *C) {
 			op:   "transition-ubuntu-core:Doing",
 			name: "ubuntu-core",
 		},
+		{
+			op:    "auto-disconnect:Doing",
+			name:  "ubuntu-core",
+			revno: snap.R(1),
+		},
 		{
 			op:   "remove-snap-aliases",
 			name: "ubuntu-core",
@@ -7585,6 +7749,11 @@ func (s *snapmgrTestSuite) TestTransitionCoreRunThroughWithCore(c *C) {
 			op:   "transition-ubuntu-core:Doing",
 			name: "ubuntu-core",
 		},
+		{
+			op:    "auto-disconnect:Doing",
+			name:  "ubuntu-core",
+			revno: snap.R(1),
+		},
 		{
 			op:   "remove-snap-aliases",
 			name: "ubuntu-core",
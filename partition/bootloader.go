@@ -67,7 +67,7 @@ type Bootloader interface {
 // InstallBootConfig installs the bootloader config from the gadget
 // snap dir into the right place.
 func InstallBootConfig(gadgetDir string) error {
-	for _, bl := range []Bootloader{&grub{}, &uboot{}, &androidboot{}} {
+	for _, bl := range []Bootloader{&grub{}, &uboot{}, &androidboot{}, &systemdboot{}} {
 		// the bootloader config file has to be root of the gadget snap
 		gadgetFile := filepath.Join(gadgetDir, bl.Name()+".conf")
 		if !osutil.FileExists(gadgetFile) {
@@ -108,6 +108,11 @@ func FindBootloader() (Bootloader, error) {
 		return androidboot, nil
 	}
 
+	// no, try systemd-boot
+	if systemdboot := newSystemdBoot(); systemdboot != nil {
+		return systemdboot, nil
+	}
+
 	// no, weeeee
 	return nil, ErrBootloader
 }
@@ -147,6 +147,7 @@ func (s *PartitionTestSuite) TestInstallBootloaderConfig(c *C) {
 		{"grub.conf", "/boot/grub/grub.cfg"},
 		{"uboot.conf", "/boot/uboot/uboot.env"},
 		{"androidboot.conf", "/boot/androidboot/androidboot.env"},
+		{"systemd-boot.conf", "/boot/efi/loader/loader.conf"},
 	} {
 		mockGadgetDir := c.MkDir()
 		err := ioutil.WriteFile(filepath.Join(mockGadgetDir, t.gadgetFile), nil, 0644)
@@ -0,0 +1,290 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package partition
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/partition/androidbootenv"
+)
+
+const (
+	// systemd-boot loader entries managed by snapd; the try entry is
+	// written with a boot counter of one so that systemd-boot boots it
+	// exactly once and falls back to the run entry when the try boot
+	// is not marked as successful
+	systemdBootRunEntry = "snapd-run"
+	systemdBootTryEntry = "snapd-try"
+
+	// EFI variable set by systemd-boot to the ID of the entry it booted
+	loaderEntrySelectedVar = "LoaderEntrySelected-4a67b082-0a4c-41cf-b6c7-440b29bb8c4f"
+
+	// the kernel command line the grub and u-boot configurations boot
+	// with, split around the snap_core and snap_kernel arguments
+	staticCmdlineHead = "root=LABEL=writable"
+	staticCmdlineTail = "ro net.ifnames=0 init=/lib/systemd/systemd panic=-1"
+)
+
+type systemdboot struct{}
+
+// newSystemdBoot creates a new systemd-boot bootloader object
+func newSystemdBoot() Bootloader {
+	s := &systemdboot{}
+	if !osutil.FileExists(s.ConfigFile()) {
+		return nil
+	}
+	return s
+}
+
+func (s *systemdboot) Name() string {
+	return "systemd-boot"
+}
+
+// Dir returns the mount point of the EFI system partition, kernel
+// assets are extracted there so that loader entries can refer to them.
+func (s *systemdboot) Dir() string {
+	return filepath.Join(dirs.GlobalRootDir, "/boot/efi")
+}
+
+func (s *systemdboot) ConfigFile() string {
+	return filepath.Join(s.Dir(), "loader/loader.conf")
+}
+
+func (s *systemdboot) envFile() string {
+	return filepath.Join(s.Dir(), "loader/snapd.env")
+}
+
+func (s *systemdboot) entriesDir() string {
+	return filepath.Join(s.Dir(), "loader/entries")
+}
+
+// gadgetCmdlineFile is the file the gadget can put in the EFI system
+// partition to add its own arguments, like the console, to the kernel
+// command line.
+func (s *systemdboot) gadgetCmdlineFile() string {
+	return filepath.Join(s.Dir(), "loader/cmdline")
+}
+
+func (s *systemdboot) GetBootVars(names ...string) (map[string]string, error) {
+	env := androidbootenv.NewEnv(s.envFile())
+	if err := env.Load(); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	mode, err := s.bootMode(env.Get(bootmodeVar))
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]string, len(names))
+	for _, name := range names {
+		if name == bootmodeVar {
+			out[name] = mode
+			continue
+		}
+		out[name] = env.Get(name)
+	}
+
+	return out, nil
+}
+
+// bootMode maps the snap_mode stored in the environment to the
+// "" -> "try" -> "trying" -> "" transitions that other bootloaders
+// implement in their boot scripts.
+func (s *systemdboot) bootMode(mode string) (string, error) {
+	if mode != modeTry {
+		return mode, nil
+	}
+
+	if strings.HasPrefix(s.selectedEntry(), systemdBootTryEntry) {
+		// systemd-boot picked the try entry
+		return "trying", nil
+	}
+
+	tryEntry, err := s.findEntry(systemdBootTryEntry)
+	if err != nil {
+		return "", err
+	}
+	if tryEntry != "" && !strings.HasPrefix(tryEntry, systemdBootTryEntry+"+1.") {
+		// the try entry has been attempted already but we are not
+		// running it, systemd-boot fell back to the run entry
+		return modeSuccess, nil
+	}
+
+	return mode, nil
+}
+
+// selectedEntry returns the ID of the loader entry systemd-boot
+// booted, or an empty string if it cannot be determined.
+func (s *systemdboot) selectedEntry() string {
+	buf, err := ioutil.ReadFile(filepath.Join(dirs.GlobalRootDir, "/sys/firmware/efi/efivars", loaderEntrySelectedVar))
+	// the first 4 bytes hold the variable attributes
+	if err != nil || len(buf) < 4 {
+		return ""
+	}
+	buf = buf[4:]
+
+	u16 := make([]uint16, 0, len(buf)/2)
+	for i := 0; i+1 < len(buf); i += 2 {
+		u16 = append(u16, uint16(buf[i])|uint16(buf[i+1])<<8)
+	}
+	return strings.TrimRight(string(utf16.Decode(u16)), "\x00")
+}
+
+// findEntry returns the file name of the given loader entry, which
+// may carry a boot counter suffix, or an empty string if there is none.
+func (s *systemdboot) findEntry(entry string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(s.entriesDir(), entry+"*.conf"))
+	if err != nil || len(matches) == 0 {
+		return "", err
+	}
+	return filepath.Base(matches[0]), nil
+}
+
+func (s *systemdboot) SetBootVars(values map[string]string) error {
+	env := androidbootenv.NewEnv(s.envFile())
+	if err := env.Load(); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for k, v := range values {
+		env.Set(k, v)
+	}
+	if err := env.Save(); err != nil {
+		return err
+	}
+
+	// a new try boot always starts with a fresh boot counter
+	_, newTry := values[bootmodeVar]
+	return s.writeEntries(env, newTry)
+}
+
+// writeEntries regenerates the snapd loader entries from the
+// environment, adding a try entry while a new kernel or core snap is
+// being tried.
+func (s *systemdboot) writeEntries(env *androidbootenv.Env, newTry bool) error {
+	if err := os.MkdirAll(s.entriesDir(), 0755); err != nil {
+		return err
+	}
+	if err := s.updateLoaderConf(); err != nil {
+		return err
+	}
+
+	cmdline, err := s.extraCmdline(env)
+	if err != nil {
+		return err
+	}
+
+	kernel := env.Get("snap_kernel")
+	core := env.Get("snap_core")
+	if kernel != "" {
+		if err := s.writeEntry(systemdBootRunEntry+".conf", kernel, core, cmdline); err != nil {
+			return err
+		}
+	}
+
+	tryEntry, err := s.findEntry(systemdBootTryEntry)
+	if err != nil {
+		return err
+	}
+
+	switch env.Get(bootmodeVar) {
+	case modeTry:
+		if tryEntry != "" && !newTry {
+			// do not reset the boot counter of an existing entry
+			return nil
+		}
+		if tryEntry != "" {
+			if err := os.Remove(filepath.Join(s.entriesDir(), tryEntry)); err != nil {
+				return err
+			}
+		}
+		if tryKernel := env.Get("snap_try_kernel"); tryKernel != "" {
+			kernel = tryKernel
+		}
+		if tryCore := env.Get("snap_try_core"); tryCore != "" {
+			core = tryCore
+		}
+		return s.writeEntry(systemdBootTryEntry+"+1.conf", kernel, core, cmdline)
+	default:
+		if tryEntry == "" {
+			return nil
+		}
+		return os.Remove(filepath.Join(s.entriesDir(), tryEntry))
+	}
+}
+
+// extraCmdline returns the kernel command line arguments of the gadget
+// followed by the ones set through snap_extra_cmdline.
+func (s *systemdboot) extraCmdline(env *androidbootenv.Env) (string, error) {
+	gadgetCmdline, err := ioutil.ReadFile(s.gadgetCmdlineFile())
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	args := strings.Fields(string(gadgetCmdline))
+	args = append(args, strings.Fields(env.Get("snap_extra_cmdline"))...)
+	return strings.Join(args, " "), nil
+}
+
+func (s *systemdboot) writeEntry(name, kernel, core, extraCmdline string) error {
+	options := fmt.Sprintf("%s snap_core=%s snap_kernel=%s %s", staticCmdlineHead, core, kernel, staticCmdlineTail)
+	if extraCmdline != "" {
+		options += " " + extraCmdline
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "title Ubuntu Core (%s)\n", kernel)
+	fmt.Fprintf(&buf, "linux /%s/kernel.img\n", kernel)
+	fmt.Fprintf(&buf, "initrd /%s/initrd.img\n", kernel)
+	fmt.Fprintf(&buf, "options %s\n", options)
+
+	return osutil.AtomicWriteFile(filepath.Join(s.entriesDir(), name), buf.Bytes(), 0644, 0)
+}
+
+// updateLoaderConf makes sure that systemd-boot defaults to the snapd
+// entries. Entries whose boot counter is exhausted are sorted last so
+// the run entry is used once the try entry was attempted.
+func (s *systemdboot) updateLoaderConf() error {
+	content, err := ioutil.ReadFile(s.ConfigFile())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("default snapd-*\n")
+	for _, line := range strings.Split(string(content), "\n") {
+		if line == "" || strings.HasPrefix(strings.TrimSpace(line), "default ") {
+			continue
+		}
+		fmt.Fprintf(&buf, "%s\n", line)
+	}
+	if bytes.Equal(buf.Bytes(), content) {
+		return nil
+	}
+
+	return osutil.AtomicWriteFile(s.ConfigFile(), buf.Bytes(), 0644, 0)
+}
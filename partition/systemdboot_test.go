@@ -0,0 +1,214 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package partition
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"unicode/utf16"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func entryContent(kernel, core string) string {
+	return fmt.Sprintf("title Ubuntu Core (%[1]s)\nlinux /%[1]s/kernel.img\ninitrd /%[1]s/initrd.img\noptions root=LABEL=writable snap_core=%[2]s snap_kernel=%[1]s ro net.ifnames=0 init=/lib/systemd/systemd panic=-1\n", kernel, core)
+}
+
+func (s *PartitionTestSuite) makeFakeESP(c *C) *systemdboot {
+	sb := &systemdboot{}
+	err := os.MkdirAll(filepath.Dir(sb.ConfigFile()), 0755)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(sb.ConfigFile(), []byte("timeout 3\ndefault ubuntu\n"), 0644)
+	c.Assert(err, IsNil)
+	return sb
+}
+
+// mockEntrySelected mimics systemd-boot picking the given entry,
+// decrementing its boot counter if it has one
+func (s *PartitionTestSuite) mockEntrySelected(c *C, sb *systemdboot, entry string) {
+	if entry == systemdBootTryEntry {
+		err := os.Rename(filepath.Join(sb.entriesDir(), "snapd-try+1.conf"), filepath.Join(sb.entriesDir(), "snapd-try+0-1.conf"))
+		c.Assert(err, IsNil)
+	}
+
+	buf := []byte{0x6, 0, 0, 0}
+	for _, r := range utf16.Encode([]rune(entry + "\x00")) {
+		buf = append(buf, byte(r), byte(r>>8))
+	}
+	efivars := filepath.Join(dirs.GlobalRootDir, "/sys/firmware/efi/efivars")
+	err := os.MkdirAll(efivars, 0755)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(filepath.Join(efivars, loaderEntrySelectedVar), buf, 0644)
+	c.Assert(err, IsNil)
+}
+
+func (s *PartitionTestSuite) TestNewSystemdBootNoESPReturnsNil(c *C) {
+	c.Assert(newSystemdBoot(), IsNil)
+}
+
+func (s *PartitionTestSuite) TestGetBootloaderWithSystemdBoot(c *C) {
+	s.makeFakeESP(c)
+
+	bootloader, err := FindBootloader()
+	c.Assert(err, IsNil)
+	c.Assert(bootloader, FitsTypeOf, &systemdboot{})
+	c.Check(bootloader.Name(), Equals, "systemd-boot")
+	c.Check(bootloader.Dir(), Equals, filepath.Join(dirs.GlobalRootDir, "/boot/efi"))
+}
+
+func (s *PartitionTestSuite) TestSystemdBootSetGetBootVars(c *C) {
+	sb := s.makeFakeESP(c)
+
+	err := sb.SetBootVars(map[string]string{
+		"snap_mode":   "",
+		"snap_core":   "core_1.snap",
+		"snap_kernel": "pc-kernel_1.snap",
+	})
+	c.Assert(err, IsNil)
+
+	m, err := sb.GetBootVars("snap_mode", "snap_core", "snap_kernel", "snap_try_kernel")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{
+		"snap_mode":       "",
+		"snap_core":       "core_1.snap",
+		"snap_kernel":     "pc-kernel_1.snap",
+		"snap_try_kernel": "",
+	})
+
+	c.Check(sb.ConfigFile(), testutil.FileEquals, "default snapd-*\ntimeout 3\n")
+	c.Check(filepath.Join(sb.entriesDir(), "snapd-run.conf"), testutil.FileEquals, entryContent("pc-kernel_1.snap", "core_1.snap"))
+	matches, err := filepath.Glob(filepath.Join(sb.entriesDir(), "snapd-try*"))
+	c.Assert(err, IsNil)
+	c.Check(matches, HasLen, 0)
+}
+
+func (s *PartitionTestSuite) TestSystemdBootExtraCmdline(c *C) {
+	sb := s.makeFakeESP(c)
+	err := ioutil.WriteFile(sb.gadgetCmdlineFile(), []byte("console=ttyS0\n"), 0644)
+	c.Assert(err, IsNil)
+
+	err = sb.SetBootVars(map[string]string{
+		"snap_core":          "core_1.snap",
+		"snap_kernel":        "pc-kernel_1.snap",
+		"snap_extra_cmdline": "quiet  splash",
+	})
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(sb.entriesDir(), "snapd-run.conf"), testutil.FileContains, "\noptions root=LABEL=writable snap_core=core_1.snap snap_kernel=pc-kernel_1.snap ro net.ifnames=0 init=/lib/systemd/systemd panic=-1 console=ttyS0 quiet splash\n")
+
+	err = sb.SetBootVars(map[string]string{
+		"snap_mode":       "try",
+		"snap_try_kernel": "pc-kernel_2.snap",
+	})
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(sb.entriesDir(), "snapd-try+1.conf"), testutil.FileContains, "\noptions root=LABEL=writable snap_core=core_1.snap snap_kernel=pc-kernel_2.snap ro net.ifnames=0 init=/lib/systemd/systemd panic=-1 console=ttyS0 quiet splash\n")
+
+	// clearing the extra arguments updates the entries
+	err = sb.SetBootVars(map[string]string{"snap_extra_cmdline": ""})
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(sb.entriesDir(), "snapd-run.conf"), testutil.FileContains, "\noptions root=LABEL=writable snap_core=core_1.snap snap_kernel=pc-kernel_1.snap ro net.ifnames=0 init=/lib/systemd/systemd panic=-1 console=ttyS0\n")
+}
+
+func (s *PartitionTestSuite) TestSystemdBootTryBootSuccessful(c *C) {
+	sb := s.makeFakeESP(c)
+	err := sb.SetBootVars(map[string]string{
+		"snap_core":   "core_1.snap",
+		"snap_kernel": "pc-kernel_1.snap",
+	})
+	c.Assert(err, IsNil)
+
+	err = sb.SetBootVars(map[string]string{
+		"snap_mode":       "try",
+		"snap_try_kernel": "pc-kernel_2.snap",
+	})
+	c.Assert(err, IsNil)
+	c.Check(filepath.Join(sb.entriesDir(), "snapd-try+1.conf"), testutil.FileEquals, entryContent("pc-kernel_2.snap", "core_1.snap"))
+	m, err := sb.GetBootVars("snap_mode")
+	c.Assert(err, IsNil)
+	c.Check(m["snap_mode"], Equals, "try")
+
+	// systemd-boot boots the try entry
+	s.mockEntrySelected(c, sb, systemdBootTryEntry)
+	m, err = sb.GetBootVars("snap_mode")
+	c.Assert(err, IsNil)
+	c.Check(m["snap_mode"], Equals, "trying")
+
+	err = MarkBootSuccessful(sb)
+	c.Assert(err, IsNil)
+
+	m, err = sb.GetBootVars("snap_mode", "snap_kernel", "snap_try_kernel")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{
+		"snap_mode":       "",
+		"snap_kernel":     "pc-kernel_2.snap",
+		"snap_try_kernel": "",
+	})
+	c.Check(filepath.Join(sb.entriesDir(), "snapd-run.conf"), testutil.FileEquals, entryContent("pc-kernel_2.snap", "core_1.snap"))
+	c.Check(osutil.FileExists(filepath.Join(sb.entriesDir(), "snapd-try+0-1.conf")), Equals, false)
+}
+
+func (s *PartitionTestSuite) TestSystemdBootTryBootFallback(c *C) {
+	sb := s.makeFakeESP(c)
+	err := sb.SetBootVars(map[string]string{
+		"snap_core":   "core_1.snap",
+		"snap_kernel": "pc-kernel_1.snap",
+	})
+	c.Assert(err, IsNil)
+	err = sb.SetBootVars(map[string]string{
+		"snap_mode":     "try",
+		"snap_try_core": "core_2.snap",
+	})
+	c.Assert(err, IsNil)
+
+	// the try boot fails, systemd-boot falls back to the run entry
+	s.mockEntrySelected(c, sb, systemdBootTryEntry)
+	s.mockEntrySelected(c, sb, systemdBootRunEntry)
+
+	m, err := sb.GetBootVars("snap_mode", "snap_core")
+	c.Assert(err, IsNil)
+	c.Check(m, DeepEquals, map[string]string{
+		"snap_mode": "",
+		"snap_core": "core_1.snap",
+	})
+
+	// nothing gets promoted
+	err = MarkBootSuccessful(sb)
+	c.Assert(err, IsNil)
+	m, err = sb.GetBootVars("snap_core", "snap_try_core")
+	c.Assert(err, IsNil)
+	c.Check(m["snap_core"], Equals, "core_1.snap")
+
+	// a new try boot gets a fresh boot counter
+	err = sb.SetBootVars(map[string]string{
+		"snap_mode":     "try",
+		"snap_try_core": "core_3.snap",
+	})
+	c.Assert(err, IsNil)
+	c.Check(osutil.FileExists(filepath.Join(sb.entriesDir(), "snapd-try+0-1.conf")), Equals, false)
+	c.Check(osutil.FileExists(filepath.Join(sb.entriesDir(), "snapd-try+1.conf")), Equals, true)
+	m, err = sb.GetBootVars("snap_mode")
+	c.Assert(err, IsNil)
+	c.Check(m["snap_mode"], Equals, "try")
+}
@@ -44,3 +44,5 @@ var (
 	ProbeAppArmor            = probeAppArmor
 	RequiredAppArmorFeatures = requiredAppArmorFeatures
 )
+
+var PreseedingFromEnv = preseedingFromEnv
@@ -0,0 +1,43 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package release
+
+import (
+	"os"
+	"strconv"
+)
+
+// Preseeding states whether snapd runs in a chroot against the root
+// filesystem of an image to preseed it, instead of on a device. It is
+// requested by setting SNAPD_PRESEED=1 in the environment.
+var Preseeding = preseedingFromEnv()
+
+func preseedingFromEnv() bool {
+	preseed, _ := strconv.ParseBool(os.Getenv("SNAPD_PRESEED"))
+	return preseed
+}
+
+// MockPreseeding forces snapd to appear to be preseeding an image or
+// not, for testing purposes.
+func MockPreseeding(preseeding bool) (restore func()) {
+	old := Preseeding
+	Preseeding = preseeding
+	return func() { Preseeding = old }
+}
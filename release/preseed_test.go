@@ -0,0 +1,57 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package release_test
+
+import (
+	"os"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/release"
+)
+
+type preseedSuite struct{}
+
+var _ = Suite(&preseedSuite{})
+
+func (s *preseedSuite) TestPreseedingFromEnv(c *C) {
+	defer os.Unsetenv("SNAPD_PRESEED")
+
+	for _, t := range []struct {
+		value      string
+		preseeding bool
+	}{
+		{"", false},
+		{"0", false},
+		{"garbage", false},
+		{"1", true},
+		{"true", true},
+	} {
+		os.Setenv("SNAPD_PRESEED", t.value)
+		c.Check(release.PreseedingFromEnv(), Equals, t.preseeding, Commentf("%q", t.value))
+	}
+}
+
+func (s *preseedSuite) TestMockPreseeding(c *C) {
+	restore := release.MockPreseeding(true)
+	c.Check(release.Preseeding, Equals, true)
+	restore()
+	c.Check(release.Preseeding, Equals, false)
+}
@@ -0,0 +1,342 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package seed implements checks of the seed of an image, that is the
+// snaps, assertions and seed.yaml used to populate a device at first
+// boot.
+package seed
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/snapasserts"
+	"github.com/snapcore/snapd/asserts/sysdb"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
+)
+
+// ValidationError collects all the problems found in a seed.
+type ValidationError struct {
+	Errors []error
+}
+
+func (e *ValidationError) Error() string {
+	var buf bytes.Buffer
+	buf.WriteString("cannot validate seed:")
+	for _, err := range e.Errors {
+		fmt.Fprintf(&buf, "\n - %s", err)
+	}
+	return buf.String()
+}
+
+var readInfo = func(snapPath string, si *snap.SideInfo) (*snap.Info, error) {
+	snapf, err := snap.Open(snapPath)
+	if err != nil {
+		return nil, err
+	}
+	return snap.ReadInfoFromSnapFile(snapf, si)
+}
+
+// Validate checks that the seed in seedDir can be used to populate a
+// device at first boot: seed.yaml must parse, the assertions must
+// verify against the trusted ones and include the model, every snap
+// must be present and match its assertions, and the snaps required
+// by the model as well as the bases and default content providers of
+// the seeded snaps must be part of the seed. All the problems found
+// are reported at once in a *ValidationError.
+func Validate(seedDir string) error {
+	seed, err := snap.ReadSeedYaml(filepath.Join(seedDir, "seed.yaml"))
+	if err != nil {
+		return &ValidationError{Errors: []error{err}}
+	}
+
+	db, model, errs := loadAssertions(filepath.Join(seedDir, "assertions"))
+	if db == nil {
+		return &ValidationError{Errors: errs}
+	}
+
+	infos := make(map[string]*snap.Info, len(seed.Snaps))
+	for _, sn := range seed.Snaps {
+		if _, ok := infos[sn.Name]; ok {
+			errs = append(errs, fmt.Errorf("cannot seed snap %q more than once", sn.Name))
+			continue
+		}
+		info, err := seedSnapInfo(seedDir, sn, db)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		// snaps that cannot be read still count as seeded below
+		infos[sn.Name] = info
+	}
+
+	if model != nil {
+		errs = append(errs, checkModelSnaps(model, infos, db, len(seed.Snaps) != 0)...)
+	}
+	errs = append(errs, checkSnapsClosure(infos)...)
+
+	if len(errs) != 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// loadAssertions reads and verifies the seed assertions into a new
+// database, returning it together with the model assertion.
+func loadAssertions(assertSeedDir string) (*asserts.Database, *asserts.Model, []error) {
+	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore: asserts.NewMemoryBackstore(),
+		Trusted:   sysdb.Trusted(),
+	})
+	if err != nil {
+		return nil, nil, []error{err}
+	}
+
+	dc, err := ioutil.ReadDir(assertSeedDir)
+	if err != nil {
+		return db, nil, []error{fmt.Errorf("cannot read assertions: %v", err)}
+	}
+
+	var errs []error
+	var refs []*asserts.Ref
+	var modelRef *asserts.Ref
+	bs := asserts.NewMemoryBackstore()
+	for _, fi := range dc {
+		added, err := readAssertions(filepath.Join(assertSeedDir, fi.Name()), bs)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cannot read assertions from %q: %v", fi.Name(), err))
+		}
+		for _, ref := range added {
+			if ref.Type == asserts.ModelType {
+				if modelRef != nil && modelRef.Unique() != ref.Unique() {
+					errs = append(errs, fmt.Errorf("cannot have more than one model assertion"))
+					continue
+				}
+				modelRef = ref
+			}
+		}
+		refs = append(refs, added...)
+	}
+
+	retrieve := func(ref *asserts.Ref) (asserts.Assertion, error) {
+		a, err := bs.Get(ref.Type, ref.PrimaryKey, ref.Type.MaxSupportedFormat())
+		if asserts.IsNotFound(err) {
+			return nil, fmt.Errorf("cannot find %s", ref)
+		}
+		return a, err
+	}
+	save := func(a asserts.Assertion) error {
+		err := db.Add(a)
+		if revErr, ok := err.(*asserts.RevisionError); ok && revErr.Current >= a.Revision() {
+			// already added while verifying another assertion
+			return nil
+		}
+		return err
+	}
+	for _, ref := range refs {
+		// a fetcher per assertion, so that a failure does not
+		// affect the verification of the others
+		f := asserts.NewFetcher(db, retrieve, save)
+		if err := f.Fetch(ref); err != nil {
+			errs = append(errs, fmt.Errorf("cannot verify %s: %v", ref, err))
+		}
+	}
+
+	if modelRef == nil {
+		errs = append(errs, fmt.Errorf("seed has no model assertion"))
+		return db, nil, errs
+	}
+	a, err := modelRef.Resolve(db.Find)
+	if err != nil {
+		// already reported above
+		return db, nil, errs
+	}
+	return db, a.(*asserts.Model), errs
+}
+
+func readAssertions(fn string, bs asserts.Backstore) ([]*asserts.Ref, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var refs []*asserts.Ref
+	dec := asserts.NewDecoder(f)
+	for {
+		a, err := dec.Decode()
+		if err == io.EOF {
+			return refs, nil
+		}
+		if err != nil {
+			return refs, err
+		}
+		if err := bs.Put(a.Type(), a); err != nil {
+			if revErr, ok := err.(*asserts.RevisionError); ok && revErr.Current >= a.Revision() {
+				continue
+			}
+			return refs, err
+		}
+		refs = append(refs, a.Ref())
+	}
+}
+
+func seedSnapInfo(seedDir string, sn *snap.SeedSnap, db *asserts.Database) (*snap.Info, error) {
+	snapPath := filepath.Join(seedDir, "snaps", sn.File)
+	if !osutil.FileExists(snapPath) {
+		return nil, fmt.Errorf("cannot find file %q of snap %q", sn.File, sn.Name)
+	}
+
+	var si *snap.SideInfo
+	if sn.Unasserted {
+		si = &snap.SideInfo{RealName: sn.Name}
+	} else {
+		var err error
+		si, err = snapasserts.DeriveSideInfo(snapPath, db)
+		if asserts.IsNotFound(err) {
+			return nil, fmt.Errorf("cannot find signatures with metadata for snap %q (%q)", sn.Name, sn.File)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot verify snap %q: %v", sn.Name, err)
+		}
+		if si.RealName != sn.Name {
+			return nil, fmt.Errorf("cannot use snap %q: its signatures are for snap %q", sn.Name, si.RealName)
+		}
+		if sn.SnapID != "" && sn.SnapID != si.SnapID {
+			return nil, fmt.Errorf("cannot use snap %q: snap-id %q does not match %q from its signatures", sn.Name, sn.SnapID, si.SnapID)
+		}
+	}
+
+	info, err := readInfo(snapPath, si)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read snap %q: %v", sn.Name, err)
+	}
+	if info.Name() != sn.Name {
+		return nil, fmt.Errorf("cannot use snap %q: snap.yaml declares name %q", sn.Name, info.Name())
+	}
+	return info, nil
+}
+
+// checkModelSnaps checks that the snaps the model requires are seeded
+// and that the kernel and gadget are published by the brand or canonical.
+func checkModelSnaps(model *asserts.Model, infos map[string]*snap.Info, db *asserts.Database, haveSnaps bool) []error {
+	var errs []error
+	check := func(what, name string) {
+		info, ok := infos[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("model %s snap %q is missing from the seed", what, name))
+			return
+		}
+		if info == nil || info.SnapID == "" || (what != "kernel" && what != "gadget") {
+			return
+		}
+		a, err := db.Find(asserts.SnapDeclarationType, map[string]string{
+			"series":  release.Series,
+			"snap-id": info.SnapID,
+		})
+		if err != nil {
+			// already reported when reading the snap
+			return
+		}
+		publisher := a.(*asserts.SnapDeclaration).PublisherID()
+		if publisher != model.BrandID() && publisher != "canonical" {
+			errs = append(errs, fmt.Errorf("cannot use %s %q published by %q for model by %q", what, name, publisher, model.BrandID()))
+		}
+	}
+
+	// if there are snaps to seed, core needs to be seeded too
+	if haveSnaps {
+		check("core", "core")
+	}
+	if kernel := model.Kernel(); kernel != "" {
+		check("kernel", kernel)
+	}
+	if gadget := model.Gadget(); gadget != "" {
+		check("gadget", gadget)
+	}
+	for _, name := range model.RequiredSnaps() {
+		check("required", name)
+	}
+	return errs
+}
+
+// checkSnapsClosure checks that the bases and default content
+// providers of the seeded snaps are seeded as well.
+func checkSnapsClosure(infos map[string]*snap.Info) []error {
+	names := make([]string, 0, len(infos))
+	for name := range infos {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		info := infos[name]
+		if info == nil {
+			continue
+		}
+		if info.Base != "" {
+			if _, ok := infos[info.Base]; !ok {
+				errs = append(errs, fmt.Errorf("cannot use snap %q: base %q is missing from the seed", name, info.Base))
+			}
+		}
+		for _, provider := range defaultProviders(info) {
+			if _, ok := infos[provider]; !ok {
+				errs = append(errs, fmt.Errorf("cannot use snap %q: default provider %q is missing from the seed", name, provider))
+			}
+		}
+	}
+	return errs
+}
+
+func defaultProviders(info *snap.Info) []string {
+	var providers []string
+	seen := make(map[string]bool)
+	plugNames := make([]string, 0, len(info.Plugs))
+	for plugName := range info.Plugs {
+		plugNames = append(plugNames, plugName)
+	}
+	sort.Strings(plugNames)
+	for _, plugName := range plugNames {
+		plug := info.Plugs[plugName]
+		if plug.Interface != "content" {
+			continue
+		}
+		var dprovider string
+		if err := plug.Attr("default-provider", &dprovider); err != nil || dprovider == "" {
+			continue
+		}
+		// the default provider used to be documented as
+		// "snapname:ifname", only the snap name matters
+		name := strings.Split(dprovider, ":")[0]
+		if !seen[name] {
+			seen[name] = true
+			providers = append(providers, name)
+		}
+	}
+	return providers
+}
@@ -0,0 +1,321 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package seed_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/asserts/sysdb"
+	"github.com/snapcore/snapd/seed"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type validateSuite struct {
+	testutil.BaseTest
+
+	storeSigning *assertstest.StoreStack
+	brandSigning *assertstest.SigningDB
+	brandAcct    *asserts.Account
+	brandAccKey  *asserts.AccountKey
+
+	seedDir string
+}
+
+var _ = Suite(&validateSuite{})
+
+func (s *validateSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.AddCleanup(snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {}))
+
+	s.storeSigning = assertstest.NewStoreStack("can0nical", nil)
+	s.AddCleanup(sysdb.InjectTrusted(s.storeSigning.Trusted))
+
+	brandPrivKey, _ := assertstest.GenerateKey(752)
+	s.brandSigning = assertstest.NewSigningDB("my-brand", brandPrivKey)
+	s.brandAcct = assertstest.NewAccount(s.storeSigning, "my-brand", map[string]interface{}{
+		"account-id":   "my-brand",
+		"verification": "certified",
+	}, "")
+	s.brandAccKey = assertstest.NewAccountKey(s.storeSigning, s.brandAcct, nil, brandPrivKey.PublicKey(), "")
+
+	s.seedDir = c.MkDir()
+	for _, d := range []string{"snaps", "assertions"} {
+		c.Assert(os.MkdirAll(filepath.Join(s.seedDir, d), 0755), IsNil)
+	}
+
+	// the mock snap files contain just their snap.yaml
+	s.AddCleanup(seed.MockReadInfo(func(snapPath string, si *snap.SideInfo) (*snap.Info, error) {
+		snapYaml, err := ioutil.ReadFile(snapPath)
+		if err != nil {
+			return nil, err
+		}
+		info, err := snap.InfoFromSnapYaml(snapYaml)
+		if err != nil {
+			return nil, err
+		}
+		info.SideInfo = *si
+		return info, nil
+	}))
+}
+
+func (s *validateSuite) TearDownTest(c *C) {
+	s.BaseTest.TearDownTest(c)
+}
+
+func (s *validateSuite) writeAssertions(c *C, fn string, as ...asserts.Assertion) {
+	f, err := os.Create(filepath.Join(s.seedDir, "assertions", fn))
+	c.Assert(err, IsNil)
+	defer f.Close()
+	enc := asserts.NewEncoder(f)
+	for _, a := range as {
+		c.Assert(enc.Encode(a), IsNil)
+	}
+}
+
+func (s *validateSuite) writeModel(c *C, extra map[string]interface{}) {
+	headers := map[string]interface{}{
+		"series":       "16",
+		"authority-id": "my-brand",
+		"brand-id":     "my-brand",
+		"model":        "my-model",
+		"architecture": "amd64",
+		"gadget":       "pc",
+		"kernel":       "pc-kernel",
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}
+	for k, v := range extra {
+		headers[k] = v
+	}
+	model, err := s.brandSigning.Sign(asserts.ModelType, headers, nil, "")
+	c.Assert(err, IsNil)
+
+	s.writeAssertions(c, "model", s.brandAcct, s.brandAccKey, model, s.storeSigning.StoreAccountKey(""))
+}
+
+// makeSnap puts a snap into the seed, together with its assertions
+// if publisherID is set.
+func (s *validateSuite) makeSnap(c *C, snapYaml, publisherID string) *snap.SeedSnap {
+	info, err := snap.InfoFromSnapYaml([]byte(snapYaml))
+	c.Assert(err, IsNil)
+	name := info.Name()
+	fn := name + "_1.snap"
+	snapPath := filepath.Join(s.seedDir, "snaps", fn)
+	c.Assert(ioutil.WriteFile(snapPath, []byte(snapYaml), 0644), IsNil)
+
+	if publisherID == "" {
+		return &snap.SeedSnap{Name: name, File: fn, Unasserted: true}
+	}
+
+	var as []asserts.Assertion
+	if publisherID != "my-brand" {
+		as = append(as, assertstest.NewAccount(s.storeSigning, publisherID, map[string]interface{}{
+			"account-id": publisherID,
+		}, ""))
+	}
+	decl, err := s.storeSigning.Sign(asserts.SnapDeclarationType, map[string]interface{}{
+		"series":       "16",
+		"snap-id":      name + "-id",
+		"publisher-id": publisherID,
+		"snap-name":    name,
+		"timestamp":    time.Now().UTC().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	sha3_384, size, err := asserts.SnapFileSHA3_384(snapPath)
+	c.Assert(err, IsNil)
+	rev, err := s.storeSigning.Sign(asserts.SnapRevisionType, map[string]interface{}{
+		"snap-sha3-384": sha3_384,
+		"snap-size":     fmt.Sprintf("%d", size),
+		"snap-id":       name + "-id",
+		"developer-id":  publisherID,
+		"snap-revision": "1",
+		"timestamp":     time.Now().UTC().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	as = append(as, decl, rev)
+	s.writeAssertions(c, name, as...)
+
+	return &snap.SeedSnap{Name: name, SnapID: name + "-id", File: fn}
+}
+
+func (s *validateSuite) writeSeedYaml(c *C, snaps ...*snap.SeedSnap) {
+	seedYaml := &snap.Seed{Snaps: snaps}
+	c.Assert(seedYaml.Write(filepath.Join(s.seedDir, "seed.yaml")), IsNil)
+}
+
+func (s *validateSuite) makeCoreSnaps(c *C) []*snap.SeedSnap {
+	return []*snap.SeedSnap{
+		s.makeSnap(c, "name: core\nversion: 1\ntype: os", "can0nical"),
+		s.makeSnap(c, "name: pc-kernel\nversion: 1\ntype: kernel", "my-brand"),
+		s.makeSnap(c, "name: pc\nversion: 1\ntype: gadget", "my-brand"),
+	}
+}
+
+func (s *validateSuite) TestValidateHappy(c *C) {
+	s.writeModel(c, map[string]interface{}{
+		"required-snaps": []interface{}{"foo"},
+	})
+	snaps := s.makeCoreSnaps(c)
+	snaps = append(snaps,
+		s.makeSnap(c, `name: foo
+version: 1
+base: core18
+plugs:
+  data:
+    interface: content
+    content: data
+    target: $SNAP/data
+    default-provider: bar:data
+`, "developer"),
+		s.makeSnap(c, "name: core18\nversion: 1\ntype: base", "can0nical"),
+		s.makeSnap(c, "name: bar\nversion: 1", ""),
+	)
+	s.writeSeedYaml(c, snaps...)
+
+	c.Check(seed.Validate(s.seedDir), IsNil)
+}
+
+func (s *validateSuite) TestValidateReportsAllProblems(c *C) {
+	s.writeModel(c, map[string]interface{}{
+		"required-snaps": []interface{}{"foo", "baz"},
+	})
+	snaps := s.makeCoreSnaps(c)
+	snaps = append(snaps,
+		s.makeSnap(c, `name: foo
+version: 1
+base: core18
+plugs:
+  data:
+    interface: content
+    content: data
+    target: $SNAP/data
+    default-provider: bar
+`, "developer"),
+		&snap.SeedSnap{Name: "missing", File: "missing_1.snap", Unasserted: true},
+	)
+	// the kernel is not part of the seed
+	s.writeSeedYaml(c, snaps[0], snaps[2], snaps[3], snaps[4])
+
+	err := seed.Validate(s.seedDir)
+	c.Assert(err, FitsTypeOf, &seed.ValidationError{})
+	c.Check(err, ErrorMatches, `cannot validate seed:
+ - cannot find file "missing_1.snap" of snap "missing"
+ - model kernel snap "pc-kernel" is missing from the seed
+ - model required snap "baz" is missing from the seed
+ - cannot use snap "foo": base "core18" is missing from the seed
+ - cannot use snap "foo": default provider "bar" is missing from the seed`)
+}
+
+func (s *validateSuite) TestValidateNoSnaps(c *C) {
+	s.writeModel(c, nil)
+	s.writeSeedYaml(c)
+
+	err := seed.Validate(s.seedDir)
+	c.Check(err, ErrorMatches, `cannot validate seed:
+ - model kernel snap "pc-kernel" is missing from the seed
+ - model gadget snap "pc" is missing from the seed`)
+}
+
+func (s *validateSuite) TestValidateNoSeedYaml(c *C) {
+	err := seed.Validate(s.seedDir)
+	c.Check(err, ErrorMatches, `cannot validate seed:
+ - cannot read seed yaml: .*/seed.yaml`)
+}
+
+func (s *validateSuite) TestValidateNoModel(c *C) {
+	snaps := s.makeCoreSnaps(c)
+	s.writeSeedYaml(c, snaps...)
+	s.writeAssertions(c, "model", s.brandAcct, s.brandAccKey, s.storeSigning.StoreAccountKey(""))
+
+	err := seed.Validate(s.seedDir)
+	c.Check(err, ErrorMatches, `cannot validate seed:
+ - seed has no model assertion`)
+}
+
+func (s *validateSuite) TestValidateUnverifiedModel(c *C) {
+	snaps := s.makeCoreSnaps(c)
+	s.writeSeedYaml(c, snaps...)
+	model, err := s.brandSigning.Sign(asserts.ModelType, map[string]interface{}{
+		"series":       "16",
+		"authority-id": "my-brand",
+		"brand-id":     "my-brand",
+		"model":        "my-model",
+		"architecture": "amd64",
+		"gadget":       "pc",
+		"kernel":       "pc-kernel",
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	// the brand account-key is missing
+	s.writeAssertions(c, "model", s.brandAcct, model, s.storeSigning.StoreAccountKey(""))
+
+	err = seed.Validate(s.seedDir)
+	c.Check(err, ErrorMatches, `cannot validate seed:
+ - cannot verify model \(my-model; series:16 brand-id:my-brand\): cannot find account-key \(.*\)`)
+}
+
+func (s *validateSuite) TestValidateSnapMismatch(c *C) {
+	s.writeModel(c, nil)
+	snaps := s.makeCoreSnaps(c)
+	// the snap file does not match its assertions anymore
+	err := ioutil.WriteFile(filepath.Join(s.seedDir, "snaps", snaps[2].File), []byte("name: pc\nversion: 2\ntype: gadget"), 0644)
+	c.Assert(err, IsNil)
+	snaps[0].SnapID = "other-id"
+	s.writeSeedYaml(c, snaps...)
+
+	err = seed.Validate(s.seedDir)
+	c.Check(err, ErrorMatches, `cannot validate seed:
+ - cannot use snap "core": snap-id "other-id" does not match "core-id" from its signatures
+ - cannot find signatures with metadata for snap "pc" \("pc_1.snap"\)`)
+}
+
+func (s *validateSuite) TestValidateKernelPublisher(c *C) {
+	s.writeModel(c, nil)
+	s.writeSeedYaml(c,
+		s.makeSnap(c, "name: core\nversion: 1\ntype: os", "can0nical"),
+		s.makeSnap(c, "name: pc-kernel\nversion: 1\ntype: kernel", "someone-else"),
+		s.makeSnap(c, "name: pc\nversion: 1\ntype: gadget", "my-brand"),
+	)
+
+	err := seed.Validate(s.seedDir)
+	c.Check(err, ErrorMatches, `cannot validate seed:
+ - cannot use kernel "pc-kernel" published by "someone-else" for model by "my-brand"`)
+}
+
+func (s *validateSuite) TestValidateDuplicatedSnap(c *C) {
+	s.writeModel(c, nil)
+	snaps := s.makeCoreSnaps(c)
+	s.writeSeedYaml(c, append(snaps, snaps[1])...)
+
+	err := seed.Validate(s.seedDir)
+	c.Check(err, ErrorMatches, `cannot validate seed:
+ - cannot seed snap "pc-kernel" more than once`)
+}
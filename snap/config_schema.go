@@ -0,0 +1,389 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigSchema describes the configuration options supported by a
+// snap, as declared in its meta/config-schema.yaml.
+type ConfigSchema struct {
+	Properties map[string]*ConfigProperty `yaml:"properties"`
+	Required   []string                   `yaml:"required,omitempty"`
+}
+
+// ConfigProperty describes a single configuration option.
+type ConfigProperty struct {
+	Type        string        `yaml:"type"`
+	Description string        `yaml:"description,omitempty"`
+	Enum        []interface{} `yaml:"enum,omitempty"`
+	Minimum     *float64      `yaml:"minimum,omitempty"`
+	Maximum     *float64      `yaml:"maximum,omitempty"`
+	Default     interface{}   `yaml:"default,omitempty"`
+
+	// for objects
+	Properties map[string]*ConfigProperty `yaml:"properties,omitempty"`
+	Required   []string                   `yaml:"required,omitempty"`
+
+	// for arrays
+	Items *ConfigProperty `yaml:"items,omitempty"`
+}
+
+// ConfigValidationError is returned when a configuration does not
+// conform to the snap config schema.
+type ConfigValidationError struct {
+	Key string
+	Msg string
+}
+
+func (e *ConfigValidationError) Error() string {
+	return fmt.Sprintf("option %q %s", e.Key, e.Msg)
+}
+
+// same as the config key validation in overlord/configstate/config
+var validConfigKey = regexp.MustCompile("^(?:[a-z0-9]+-?)*[a-z](?:-?[a-z0-9])*$")
+
+const configSchemaFile = "config-schema.yaml"
+
+func addConfigSchema(info *Info) error {
+	data, err := ioutil.ReadFile(filepath.Join(info.MountDir(), "meta", configSchemaFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	info.ConfigSchema, err = ReadConfigSchema(data)
+	return err
+}
+
+func addConfigSchemaFromContainer(info *Info, snapf Container) error {
+	fileNames, err := snapf.ListDir("meta")
+	if err != nil {
+		// same as for hooks, no meta dir listing means no schema
+		return nil
+	}
+	for _, fileName := range fileNames {
+		if fileName != configSchemaFile {
+			continue
+		}
+		data, err := snapf.ReadFile("meta/" + configSchemaFile)
+		if err != nil {
+			return err
+		}
+		info.ConfigSchema, err = ReadConfigSchema(data)
+		return err
+	}
+	return nil
+}
+
+// ReadConfigSchema parses and checks the content of a config-schema.yaml.
+func ReadConfigSchema(data []byte) (*ConfigSchema, error) {
+	var schema ConfigSchema
+	if err := yaml.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("cannot parse config schema: %v", err)
+	}
+	if err := schema.root().check(""); err != nil {
+		return nil, fmt.Errorf("invalid config schema: %v", err)
+	}
+	return &schema, nil
+}
+
+func (s *ConfigSchema) root() *ConfigProperty {
+	return &ConfigProperty{
+		Type:       "object",
+		Properties: s.Properties,
+		Required:   s.Required,
+	}
+}
+
+func joinConfigKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// check verifies the property definition itself and normalizes
+// its enum and default values.
+func (p *ConfigProperty) check(key string) error {
+	what := fmt.Sprintf("option %q", key)
+	if key == "" {
+		what = "top-level"
+	}
+	switch p.Type {
+	case "string", "integer", "number", "boolean", "array", "object":
+	case "":
+		return fmt.Errorf("%s has no type", what)
+	default:
+		return fmt.Errorf("%s has unsupported type %q", what, p.Type)
+	}
+
+	if (p.Minimum != nil || p.Maximum != nil) && p.Type != "integer" && p.Type != "number" {
+		return fmt.Errorf("%s cannot have minimum or maximum with type %q", what, p.Type)
+	}
+	if p.Minimum != nil && p.Maximum != nil && *p.Minimum > *p.Maximum {
+		return fmt.Errorf("%s has minimum greater than maximum", what)
+	}
+	if (len(p.Properties) != 0 || len(p.Required) != 0) && p.Type != "object" {
+		return fmt.Errorf("%s cannot have properties with type %q", what, p.Type)
+	}
+	if p.Items != nil && p.Type != "array" {
+		return fmt.Errorf("%s cannot have items with type %q", what, p.Type)
+	}
+
+	for name, prop := range p.Properties {
+		if !validConfigKey.MatchString(name) {
+			return fmt.Errorf("invalid option name %q", joinConfigKey(key, name))
+		}
+		if prop == nil {
+			return fmt.Errorf("option %q has no definition", joinConfigKey(key, name))
+		}
+		if err := prop.check(joinConfigKey(key, name)); err != nil {
+			return err
+		}
+	}
+	for _, name := range p.Required {
+		if p.Properties[name] == nil {
+			return fmt.Errorf("required option %q is not defined", joinConfigKey(key, name))
+		}
+	}
+	if p.Items != nil {
+		if err := p.Items.check(key + "[]"); err != nil {
+			return err
+		}
+	}
+
+	for i, v := range p.Enum {
+		v, err := normalizeYamlValue(v)
+		if err != nil {
+			return fmt.Errorf("%s has invalid enum value: %v", what, err)
+		}
+		if err := p.checkValue(key, v); err != nil {
+			return fmt.Errorf("%s has invalid enum value: %v", what, err)
+		}
+		p.Enum[i] = v
+	}
+	if p.Default != nil {
+		v, err := normalizeYamlValue(p.Default)
+		if err != nil {
+			return fmt.Errorf("%s has invalid default: %v", what, err)
+		}
+		if err := p.validate(key, v); err != nil {
+			return fmt.Errorf("%s has invalid default: %v", what, err)
+		}
+		p.Default = v
+	}
+	return nil
+}
+
+func numberValue(v interface{}) (f float64, isInt bool, ok bool) {
+	switch x := v.(type) {
+	case json.Number:
+		if _, err := x.Int64(); err == nil {
+			f, _ := x.Float64()
+			return f, true, true
+		}
+		f, err := x.Float64()
+		if err != nil {
+			return 0, false, false
+		}
+		return f, f == math.Trunc(f), true
+	case int:
+		return float64(x), true, true
+	case int64:
+		return float64(x), true, true
+	case float64:
+		return x, x == math.Trunc(x), true
+	}
+	return 0, false, false
+}
+
+// checkValue checks the type and range of v, not considering enum,
+// nested properties or array items.
+func (p *ConfigProperty) checkValue(key string, v interface{}) error {
+	ok := false
+	switch p.Type {
+	case "string":
+		_, ok = v.(string)
+	case "boolean":
+		_, ok = v.(bool)
+	case "array":
+		_, ok = v.([]interface{})
+	case "object":
+		_, ok = v.(map[string]interface{})
+	case "integer", "number":
+		f, isInt, isNumber := numberValue(v)
+		ok = isNumber && (isInt || p.Type == "number")
+		if ok && p.Minimum != nil && f < *p.Minimum {
+			return &ConfigValidationError{Key: key, Msg: fmt.Sprintf("must be at least %v", *p.Minimum)}
+		}
+		if ok && p.Maximum != nil && f > *p.Maximum {
+			return &ConfigValidationError{Key: key, Msg: fmt.Sprintf("must be at most %v", *p.Maximum)}
+		}
+	}
+	if !ok {
+		article := "a"
+		if p.Type == "integer" || p.Type == "array" || p.Type == "object" {
+			article = "an"
+		}
+		return &ConfigValidationError{Key: key, Msg: fmt.Sprintf("must be %s %s", article, p.Type)}
+	}
+	return nil
+}
+
+func (p *ConfigProperty) validate(key string, v interface{}) error {
+	if v == nil {
+		// unset
+		return nil
+	}
+	if err := p.checkValue(key, v); err != nil {
+		return err
+	}
+
+	if len(p.Enum) != 0 {
+		found := false
+		values := make([]string, len(p.Enum))
+		for i, e := range p.Enum {
+			values[i] = fmt.Sprintf("%v", e)
+			if values[i] == fmt.Sprintf("%v", v) {
+				found = true
+			}
+		}
+		if !found {
+			return &ConfigValidationError{Key: key, Msg: fmt.Sprintf("must be one of: %s", strings.Join(values, ", "))}
+		}
+	}
+
+	switch p.Type {
+	case "object":
+		return p.validateObject(key, v.(map[string]interface{}))
+	case "array":
+		if p.Items == nil {
+			return nil
+		}
+		for i, item := range v.([]interface{}) {
+			if err := p.Items.validate(fmt.Sprintf("%s[%d]", key, i), item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *ConfigProperty) validateObject(key string, m map[string]interface{}) error {
+	if len(p.Properties) == 0 {
+		// free-form object
+		return nil
+	}
+
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		prop := p.Properties[name]
+		if prop == nil {
+			return &ConfigValidationError{Key: joinConfigKey(key, name), Msg: "is not defined in the config schema"}
+		}
+		if err := prop.validate(joinConfigKey(key, name), m[name]); err != nil {
+			return err
+		}
+	}
+	for _, name := range p.Required {
+		if m[name] == nil && p.Properties[name].Default == nil {
+			return &ConfigValidationError{Key: joinConfigKey(key, name), Msg: "is required"}
+		}
+	}
+	return nil
+}
+
+// Validate checks the given snap configuration against the schema,
+// required options with a default need not to be set. An empty
+// configuration is always valid, i.e. required options need to be
+// set only once the snap is configured.
+func (s *ConfigSchema) Validate(config map[string]interface{}) error {
+	if len(config) == 0 {
+		return nil
+	}
+	return s.root().validateObject("", config)
+}
+
+func (p *ConfigProperty) applyDefaults(config map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{}, len(config))
+	for k, v := range config {
+		res[k] = v
+	}
+	for name, prop := range p.Properties {
+		v := res[name]
+		switch {
+		case v == nil && prop.Default != nil:
+			res[name] = prop.Default
+		case prop.Type == "object":
+			m, ok := v.(map[string]interface{})
+			if v == nil || ok {
+				m = prop.applyDefaults(m)
+				if len(m) != 0 {
+					res[name] = m
+				}
+			}
+		}
+	}
+	return res
+}
+
+// ApplyDefaults returns a copy of the given configuration with the
+// default values of the options that are not set filled in.
+func (s *ConfigSchema) ApplyDefaults(config map[string]interface{}) map[string]interface{} {
+	return s.root().applyDefaults(config)
+}
+
+// Default returns the default value of the given dotted option key,
+// if it has one.
+func (s *ConfigSchema) Default(key string) (interface{}, bool) {
+	p := s.root()
+	for _, subkey := range strings.Split(key, ".") {
+		p = p.Properties[subkey]
+		if p == nil {
+			return nil, false
+		}
+	}
+	if p.Default != nil {
+		return p.Default, true
+	}
+	if p.Type == "object" {
+		if m := p.applyDefaults(nil); len(m) != 0 {
+			return m, true
+		}
+	}
+	return nil, false
+}
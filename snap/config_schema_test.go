@@ -0,0 +1,224 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2018 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snap_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snapdir"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type configSchemaSuite struct {
+	testutil.BaseTest
+}
+
+var _ = Suite(&configSchemaSuite{})
+
+func (s *configSchemaSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.BaseTest.AddCleanup(snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {}))
+	dirs.SetRootDir(c.MkDir())
+}
+
+func (s *configSchemaSuite) TearDownTest(c *C) {
+	dirs.SetRootDir("/")
+	s.BaseTest.TearDownTest(c)
+}
+
+var mockConfigSchemaYaml = []byte(`
+properties:
+  port:
+    type: integer
+    minimum: 1
+    maximum: 65535
+    default: 8080
+  mode:
+    type: string
+    enum: [fast, slow]
+  ratio:
+    type: number
+    maximum: 1
+  debug:
+    type: boolean
+  hosts:
+    type: array
+    items:
+      type: string
+  tls:
+    type: object
+    properties:
+      cert:
+        type: string
+      verify:
+        type: boolean
+        default: true
+    required: [cert]
+  extra:
+    type: object
+required: [mode]
+`)
+
+func (s *configSchemaSuite) TestReadConfigSchema(c *C) {
+	schema, err := snap.ReadConfigSchema(mockConfigSchemaYaml)
+	c.Assert(err, IsNil)
+	c.Check(schema.Required, DeepEquals, []string{"mode"})
+	c.Check(schema.Properties, HasLen, 7)
+	c.Check(schema.Properties["port"].Type, Equals, "integer")
+	c.Check(schema.Properties["port"].Default, Equals, int64(8080))
+	c.Check(schema.Properties["mode"].Enum, DeepEquals, []interface{}{"fast", "slow"})
+	c.Check(schema.Properties["tls"].Properties["verify"].Default, Equals, true)
+}
+
+func (s *configSchemaSuite) TestReadConfigSchemaErrors(c *C) {
+	for _, t := range []struct {
+		schema string
+		err    string
+	}{
+		{"properties: [", `cannot parse config schema: .*`},
+		{"properties:\n  foo: {}", `invalid config schema: option "foo" has no type`},
+		{"properties:\n  foo: {type: int}", `invalid config schema: option "foo" has unsupported type "int"`},
+		{"properties:\n  Foo: {type: string}", `invalid config schema: invalid option name "Foo"`},
+		{"properties:\n  foo: {type: string, minimum: 1}", `invalid config schema: option "foo" cannot have minimum or maximum with type "string"`},
+		{"properties:\n  foo: {type: integer, minimum: 2, maximum: 1}", `invalid config schema: option "foo" has minimum greater than maximum`},
+		{"properties:\n  foo: {type: string, items: {type: string}}", `invalid config schema: option "foo" cannot have items with type "string"`},
+		{"properties:\n  foo: {type: string, properties: {bar: {type: string}}}", `invalid config schema: option "foo" cannot have properties with type "string"`},
+		{"properties:\n  foo: {type: integer, default: bar}", `invalid config schema: option "foo" has invalid default: option "foo" must be an integer`},
+		{"properties:\n  foo: {type: integer, enum: [1, a]}", `invalid config schema: option "foo" has invalid enum value: option "foo" must be an integer`},
+		{"properties:\n  foo: {type: object, properties: {bar: {type: bool}}}", `invalid config schema: option "foo.bar" has unsupported type "bool"`},
+		{"properties:\n  foo: {type: string}\nrequired: [bar]", `invalid config schema: required option "bar" is not defined`},
+	} {
+		_, err := snap.ReadConfigSchema([]byte(t.schema))
+		c.Check(err, ErrorMatches, t.err, Commentf(t.schema))
+	}
+}
+
+func (s *configSchemaSuite) TestValidate(c *C) {
+	schema, err := snap.ReadConfigSchema(mockConfigSchemaYaml)
+	c.Assert(err, IsNil)
+
+	// nothing configured yet
+	c.Check(schema.Validate(nil), IsNil)
+
+	for _, t := range []struct {
+		config map[string]interface{}
+		err    string
+	}{
+		{map[string]interface{}{"mode": "fast"}, ""},
+		{map[string]interface{}{"mode": "fast", "port": json.Number("80"), "ratio": json.Number("0.5"), "debug": true}, ""},
+		{map[string]interface{}{"mode": "fast", "port": nil}, ""},
+		{map[string]interface{}{"mode": "fast", "hosts": []interface{}{"a", "b"}}, ""},
+		{map[string]interface{}{"mode": "fast", "tls": map[string]interface{}{"cert": "foo"}}, ""},
+		{map[string]interface{}{"mode": "fast", "extra": map[string]interface{}{"any": "thing"}}, ""},
+		{map[string]interface{}{"port": json.Number("80")}, `option "mode" is required`},
+		{map[string]interface{}{"mode": "medium"}, `option "mode" must be one of: fast, slow`},
+		{map[string]interface{}{"mode": "fast", "prot": json.Number("80")}, `option "prot" is not defined in the config schema`},
+		{map[string]interface{}{"mode": "fast", "port": "80"}, `option "port" must be an integer`},
+		{map[string]interface{}{"mode": "fast", "port": json.Number("8.5")}, `option "port" must be an integer`},
+		{map[string]interface{}{"mode": "fast", "port": json.Number("0")}, `option "port" must be at least 1`},
+		{map[string]interface{}{"mode": "fast", "port": json.Number("65536")}, `option "port" must be at most 65535`},
+		{map[string]interface{}{"mode": "fast", "ratio": json.Number("1.5")}, `option "ratio" must be at most 1`},
+		{map[string]interface{}{"mode": "fast", "debug": "yes"}, `option "debug" must be a boolean`},
+		{map[string]interface{}{"mode": "fast", "hosts": []interface{}{"a", json.Number("1")}}, `option "hosts\[1\]" must be a string`},
+		{map[string]interface{}{"mode": "fast", "tls": "on"}, `option "tls" must be an object`},
+		{map[string]interface{}{"mode": "fast", "tls": map[string]interface{}{"verify": false}}, `option "tls.cert" is required`},
+		{map[string]interface{}{"mode": "fast", "tls": map[string]interface{}{"cert": "foo", "key": "bar"}}, `option "tls.key" is not defined in the config schema`},
+	} {
+		err := schema.Validate(t.config)
+		if t.err == "" {
+			c.Check(err, IsNil, Commentf("%v", t.config))
+		} else {
+			c.Check(err, ErrorMatches, t.err, Commentf("%v", t.config))
+			c.Check(err, FitsTypeOf, &snap.ConfigValidationError{})
+		}
+	}
+}
+
+func (s *configSchemaSuite) TestDefaults(c *C) {
+	schema, err := snap.ReadConfigSchema(mockConfigSchemaYaml)
+	c.Assert(err, IsNil)
+
+	c.Check(schema.ApplyDefaults(nil), DeepEquals, map[string]interface{}{
+		"port": int64(8080),
+		"tls":  map[string]interface{}{"verify": true},
+	})
+	c.Check(schema.ApplyDefaults(map[string]interface{}{
+		"port": json.Number("80"),
+		"tls":  map[string]interface{}{"cert": "foo"},
+	}), DeepEquals, map[string]interface{}{
+		"port": json.Number("80"),
+		"tls":  map[string]interface{}{"cert": "foo", "verify": true},
+	})
+
+	v, ok := schema.Default("port")
+	c.Check(ok, Equals, true)
+	c.Check(v, Equals, int64(8080))
+	v, ok = schema.Default("tls.verify")
+	c.Check(ok, Equals, true)
+	c.Check(v, Equals, true)
+	v, ok = schema.Default("tls")
+	c.Check(ok, Equals, true)
+	c.Check(v, DeepEquals, map[string]interface{}{"verify": true})
+	_, ok = schema.Default("mode")
+	c.Check(ok, Equals, false)
+	_, ok = schema.Default("tls.cert.foo")
+	c.Check(ok, Equals, false)
+}
+
+func (s *configSchemaSuite) TestReadInfoWithConfigSchema(c *C) {
+	info := snaptest.MockSnap(c, "name: foo\nversion: 1.0", &snap.SideInfo{Revision: snap.R(1)})
+	err := ioutil.WriteFile(filepath.Join(info.MountDir(), "meta", "config-schema.yaml"), mockConfigSchemaYaml, 0644)
+	c.Assert(err, IsNil)
+
+	info, err = snap.ReadInfo("foo", &snap.SideInfo{Revision: snap.R(1)})
+	c.Assert(err, IsNil)
+	c.Assert(info.ConfigSchema, NotNil)
+	c.Check(info.ConfigSchema.Required, DeepEquals, []string{"mode"})
+
+	// also from the snap container
+	info, err = snap.ReadInfoFromSnapFile(snapdir.New(info.MountDir()), nil)
+	c.Assert(err, IsNil)
+	c.Assert(info.ConfigSchema, NotNil)
+	c.Check(info.ConfigSchema.Properties, HasLen, 7)
+}
+
+func (s *configSchemaSuite) TestReadInfoWithInvalidConfigSchema(c *C) {
+	info := snaptest.MockSnap(c, "name: foo\nversion: 1.0", &snap.SideInfo{Revision: snap.R(1)})
+	err := ioutil.WriteFile(filepath.Join(info.MountDir(), "meta", "config-schema.yaml"), []byte("properties:\n  foo: {}"), 0644)
+	c.Assert(err, IsNil)
+
+	_, err = snap.ReadInfoFromSnapFile(snapdir.New(info.MountDir()), nil)
+	c.Assert(err, ErrorMatches, `invalid config schema: option "foo" has no type`)
+}
+
+func (s *configSchemaSuite) TestReadInfoWithoutConfigSchema(c *C) {
+	info := snaptest.MockSnap(c, "name: foo\nversion: 1.0", &snap.SideInfo{Revision: snap.R(1)})
+
+	info, err := snap.ReadInfoFromSnapFile(snapdir.New(info.MountDir()), nil)
+	c.Assert(err, IsNil)
+	c.Check(info.ConfigSchema, IsNil)
+}
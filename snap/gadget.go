@@ -33,6 +33,15 @@ type GadgetInfo struct {
 
 	// Default configuration for snaps (snap-id => key => value).
 	Defaults map[string]map[string]interface{} `yaml:"defaults,omitempty"`
+
+	KernelCmdline GadgetKernelCmdline `yaml:"kernel-cmdline,omitempty"`
+}
+
+// GadgetKernelCmdline holds the kernel command line parameters the
+// gadget allows to be set via the system.kernel.cmdline-append core
+// option, as glob patterns matched against name=value or name.
+type GadgetKernelCmdline struct {
+	Allow []string `yaml:"allow,omitempty"`
 }
 
 type GadgetVolume struct {
@@ -47,7 +56,9 @@ type GadgetVolume struct {
 // type when we actually handle these.
 
 type VolumeStructure struct {
+	Name        string          `yaml:"name"`
 	Label       string          `yaml:"filesystem-label"`
+	Role        string          `yaml:"role"`
 	Offset      string          `yaml:"offset"`
 	OffsetWrite string          `yaml:"offset-write"`
 	Size        string          `yaml:"size"`
@@ -55,6 +66,15 @@ type VolumeStructure struct {
 	ID          string          `yaml:"id"`
 	Filesystem  string          `yaml:"filesystem"`
 	Content     []VolumeContent `yaml:"content"`
+	Update      VolumeUpdate    `yaml:"update"`
+}
+
+// VolumeUpdate describes how a structure is updated when the gadget snap
+// is refreshed. The content is only written when the edition of the new
+// gadget is higher than the one currently installed.
+type VolumeUpdate struct {
+	Edition  uint32   `yaml:"edition"`
+	Preserve []string `yaml:"preserve"`
 }
 
 type VolumeContent struct {
@@ -79,18 +99,34 @@ func ReadGadgetInfo(info *Info, classic bool) (*GadgetInfo, error) {
 		return nil, fmt.Errorf(errorFormat, "not a gadget snap")
 	}
 
-	var gi GadgetInfo
+	return ReadGadgetInfoFromDir(info.MountDir(), classic)
+}
 
-	gadgetYamlFn := filepath.Join(info.MountDir(), "meta", "gadget.yaml")
+// ReadGadgetInfoFromDir reads the gadget specific metadata from
+// meta/gadget.yaml of the gadget snap unpacked or mounted at gadgetDir.
+func ReadGadgetInfoFromDir(gadgetDir string, classic bool) (*GadgetInfo, error) {
+	const errorFormat = "cannot read gadget snap details: %s"
+
+	gadgetYamlFn := filepath.Join(gadgetDir, "meta", "gadget.yaml")
 	gmeta, err := ioutil.ReadFile(gadgetYamlFn)
 	if classic && os.IsNotExist(err) {
 		// gadget.yaml is optional for classic gadgets
-		return &gi, nil
+		return &GadgetInfo{}, nil
 	}
 	if err != nil {
 		return nil, fmt.Errorf(errorFormat, err)
 	}
 
+	return InfoFromGadgetYaml(gmeta, classic)
+}
+
+// InfoFromGadgetYaml parses the gadget specific metadata from the content
+// of a gadget.yaml.
+func InfoFromGadgetYaml(gmeta []byte, classic bool) (*GadgetInfo, error) {
+	const errorFormat = "cannot read gadget snap details: %s"
+
+	var gi GadgetInfo
+
 	if err := yaml.Unmarshal(gmeta, &gi); err != nil {
 		return nil, fmt.Errorf(errorFormat, err)
 	}
@@ -103,6 +139,12 @@ func ReadGadgetInfo(info *Info, classic bool) (*GadgetInfo, error) {
 		gi.Defaults[k] = dflt.(map[string]interface{})
 	}
 
+	for _, pattern := range gi.KernelCmdline.Allow {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf(errorFormat, fmt.Sprintf("invalid kernel-cmdline allow pattern %q", pattern))
+		}
+	}
+
 	if classic && len(gi.Volumes) == 0 {
 		// volumes can be left out on classic
 		// can still specify defaults though
@@ -21,6 +21,7 @@ package snap_test
 
 import (
 	"io/ioutil"
+	"os"
 	"path/filepath"
 
 	. "gopkg.in/check.v1"
@@ -212,6 +213,31 @@ func (s *gadgetYamlTestSuite) TestReadGadgetYamlValid(c *C) {
 	})
 }
 
+func (s *gadgetYamlTestSuite) TestReadGadgetInfoFromDir(c *C) {
+	gadgetDir := c.MkDir()
+	err := os.MkdirAll(filepath.Join(gadgetDir, "meta"), 0755)
+	c.Assert(err, IsNil)
+	err = ioutil.WriteFile(filepath.Join(gadgetDir, "meta", "gadget.yaml"), mockGadgetYaml, 0644)
+	c.Assert(err, IsNil)
+
+	ginfo, err := snap.ReadGadgetInfoFromDir(gadgetDir, false)
+	c.Assert(err, IsNil)
+	c.Check(ginfo.Volumes, HasLen, 1)
+	c.Check(ginfo.Volumes["volumename"].Bootloader, Equals, "u-boot")
+
+	_, err = snap.ReadGadgetInfoFromDir(c.MkDir(), false)
+	c.Assert(err, ErrorMatches, ".*meta/gadget.yaml: no such file or directory")
+}
+
+func (s *gadgetYamlTestSuite) TestInfoFromGadgetYaml(c *C) {
+	ginfo, err := snap.InfoFromGadgetYaml(mockGadgetYaml, false)
+	c.Assert(err, IsNil)
+	c.Check(ginfo.Volumes["volumename"].Schema, Equals, "mbr")
+
+	_, err = snap.InfoFromGadgetYaml([]byte("volumes: ["), false)
+	c.Check(err, ErrorMatches, "cannot read gadget snap details: .*")
+}
+
 func (s *gadgetYamlTestSuite) TestReadMultiVolumeGadgetYamlValid(c *C) {
 	info := snaptest.MockSnap(c, mockGadgetSnapYaml, &snap.SideInfo{Revision: snap.R(42)})
 	err := ioutil.WriteFile(filepath.Join(info.MountDir(), "meta", "gadget.yaml"), mockMultiVolumeGadgetYaml, 0644)
@@ -227,7 +253,9 @@ func (s *gadgetYamlTestSuite) TestReadMultiVolumeGadgetYamlValid(c *C) {
 				Bootloader: "u-boot",
 				Structure: []snap.VolumeStructure{
 					{
+						Name:       "system-boot",
 						Label:      "system-boot",
+						Role:       "system-boot",
 						Size:       "128M",
 						Filesystem: "vfat",
 						Type:       "0C",
@@ -239,7 +267,9 @@ func (s *gadgetYamlTestSuite) TestReadMultiVolumeGadgetYamlValid(c *C) {
 						},
 					},
 					{
+						Name:       "writable",
 						Label:      "writable",
+						Role:       "system-data",
 						Type:       "83",
 						Filesystem: "ext4",
 						Size:       "380M",
@@ -249,6 +279,7 @@ func (s *gadgetYamlTestSuite) TestReadMultiVolumeGadgetYamlValid(c *C) {
 			"u-boot-frobinator-3000": {
 				Structure: []snap.VolumeStructure{
 					{
+						Name:   "u-boot",
 						Type:   "bare",
 						Size:   "623000",
 						Offset: "0",
@@ -264,6 +295,40 @@ func (s *gadgetYamlTestSuite) TestReadMultiVolumeGadgetYamlValid(c *C) {
 	})
 }
 
+func (s *gadgetYamlTestSuite) TestReadGadgetYamlKernelCmdline(c *C) {
+	info := snaptest.MockSnap(c, mockGadgetSnapYaml, &snap.SideInfo{Revision: snap.R(42)})
+	gadgetYaml := []byte(`
+kernel-cmdline:
+  allow:
+    - console=*
+    - quiet
+`)
+	err := ioutil.WriteFile(filepath.Join(info.MountDir(), "meta", "gadget.yaml"), gadgetYaml, 0644)
+	c.Assert(err, IsNil)
+
+	ginfo, err := snap.ReadGadgetInfo(info, true)
+	c.Assert(err, IsNil)
+	c.Assert(ginfo, DeepEquals, &snap.GadgetInfo{
+		KernelCmdline: snap.GadgetKernelCmdline{
+			Allow: []string{"console=*", "quiet"},
+		},
+	})
+}
+
+func (s *gadgetYamlTestSuite) TestReadGadgetYamlKernelCmdlineInvalidPattern(c *C) {
+	info := snaptest.MockSnap(c, mockGadgetSnapYaml, &snap.SideInfo{Revision: snap.R(42)})
+	gadgetYaml := []byte(`
+kernel-cmdline:
+  allow:
+    - "console=[tty"
+`)
+	err := ioutil.WriteFile(filepath.Join(info.MountDir(), "meta", "gadget.yaml"), gadgetYaml, 0644)
+	c.Assert(err, IsNil)
+
+	_, err = snap.ReadGadgetInfo(info, true)
+	c.Assert(err, ErrorMatches, `cannot read gadget snap details: invalid kernel-cmdline allow pattern "console=\[tty"`)
+}
+
 func (s *gadgetYamlTestSuite) TestReadGadgetYamlInvalidBootloader(c *C) {
 	info := snaptest.MockSnap(c, mockGadgetSnapYaml, &snap.SideInfo{Revision: snap.R(42)})
 	mockGadgetYamlBroken := []byte(`
@@ -167,6 +167,10 @@ type Info struct {
 	Plugs            map[string]*PlugInfo
 	Slots            map[string]*SlotInfo
 
+	// ConfigSchema is the schema of the snap configuration from
+	// meta/config-schema.yaml, if the snap has one.
+	ConfigSchema *ConfigSchema
+
 	// Plugs or slots with issues (they are not included in Plugs or Slots)
 	BadInterfaces map[string]string // slot or plug => message
 
@@ -211,6 +215,15 @@ type Layout struct {
 	Symlink  string      `json:"symlink,omitempty"`
 }
 
+// IsUserScoped returns true if the layout applies to the per-user mount namespace.
+//
+// User-scoped layouts have a mount point relative to $HOME and allow apps to
+// remap parts of the home directory, such as ~/.config, into the per-user data
+// directories of the snap.
+func (l *Layout) IsUserScoped() bool {
+	return strings.HasPrefix(l.Path, "$HOME/")
+}
+
 // String returns a simple textual representation of a layout.
 func (l *Layout) String() string {
 	var buf bytes.Buffer
@@ -361,6 +374,11 @@ func (s *Info) Services() []*AppInfo {
 }
 
 // ExpandSnapVariables resolves $SNAP, $SNAP_DATA and $SNAP_COMMON.
+//
+// The per-user variables $SNAP_USER_DATA and $SNAP_USER_COMMON are resolved
+// relative to $HOME, which is itself left unexpanded. Paths using them are
+// only meaningful in the per-user mount namespace where snap-update-ns
+// resolves $HOME for the user invoking the snap.
 func (s *Info) ExpandSnapVariables(path string) string {
 	return os.Expand(path, func(v string) string {
 		switch v {
@@ -374,6 +392,12 @@ func (s *Info) ExpandSnapVariables(path string) string {
 			return s.DataDir()
 		case "SNAP_COMMON":
 			return s.CommonDataDir()
+		case "SNAP_USER_DATA":
+			return s.UserDataDir("$HOME")
+		case "SNAP_USER_COMMON":
+			return s.UserCommonDataDir("$HOME")
+		case "HOME":
+			return "$HOME"
 		}
 		return ""
 	})
@@ -569,6 +593,7 @@ type AppInfo struct {
 	RestartCond     RestartCondition
 	Completer       string
 	RefreshMode     string
+	ReloadOnConfig  bool
 
 	// TODO: this should go away once we have more plumbing and can change
 	// things vs refactor
@@ -777,6 +802,11 @@ func ReadInfo(name string, si *SideInfo) (*Info, error) {
 		return nil, err
 	}
 
+	err = addConfigSchema(info)
+	if err != nil {
+		return nil, err
+	}
+
 	return info, nil
 }
 
@@ -803,6 +833,11 @@ func ReadInfoFromSnapFile(snapf Container, si *SideInfo) (*Info, error) {
 		return nil, err
 	}
 
+	err = addConfigSchemaFromContainer(info, snapf)
+	if err != nil {
+		return nil, err
+	}
+
 	err = Validate(info)
 	if err != nil {
 		return nil, err
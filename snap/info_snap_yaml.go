@@ -68,6 +68,7 @@ type appYaml struct {
 	StopTimeout     timeout.Timeout `yaml:"stop-timeout,omitempty"`
 	Completer       string          `yaml:"completer,omitempty"`
 	RefreshMode     string          `yaml:"refresh-mode,omitempty"`
+	ReloadOnConfig  bool            `yaml:"reload-on-config,omitempty"`
 
 	RestartCond RestartCondition `yaml:"restart-condition,omitempty"`
 	SlotNames   []string         `yaml:"slots,omitempty"`
@@ -300,6 +301,7 @@ func setAppsFromSnapYaml(y snapYaml, snap *Info) error {
 			Environment:     yApp.Environment,
 			Completer:       yApp.Completer,
 			RefreshMode:     yApp.RefreshMode,
+			ReloadOnConfig:  yApp.ReloadOnConfig,
 			Before:          yApp.Before,
 			After:           yApp.After,
 			Autostart:       yApp.Autostart,
@@ -1423,6 +1423,7 @@ apps:
    post-stop-command: post-stop-cmd
    restart-condition: on-abnormal
    bus-name: busName
+   reload-on-config: true
    sockets:
      sock1:
        listen-stream: $SNAP_DATA/sock1.socket
@@ -1441,6 +1442,7 @@ apps:
 		StopCommand:     "stop-cmd",
 		PostStopCommand: "post-stop-cmd",
 		BusName:         "busName",
+		ReloadOnConfig:  true,
 		Sockets:         map[string]*snap.SocketInfo{},
 	}
 
@@ -910,5 +910,14 @@ func (s *infoSuite) TestExpandSnapVariables(c *C) {
 	c.Assert(info.ExpandSnapVariables("$SNAP/stuff"), Equals, "/snap/foo/42/stuff")
 	c.Assert(info.ExpandSnapVariables("$SNAP_DATA/stuff"), Equals, "/var/snap/foo/42/stuff")
 	c.Assert(info.ExpandSnapVariables("$SNAP_COMMON/stuff"), Equals, "/var/snap/foo/common/stuff")
+	c.Assert(info.ExpandSnapVariables("$SNAP_USER_DATA/stuff"), Equals, "$HOME/snap/foo/42/stuff")
+	c.Assert(info.ExpandSnapVariables("$SNAP_USER_COMMON/stuff"), Equals, "$HOME/snap/foo/common/stuff")
+	c.Assert(info.ExpandSnapVariables("$HOME/stuff"), Equals, "$HOME/stuff")
 	c.Assert(info.ExpandSnapVariables("$GARBAGE/rocks"), Equals, "/rocks")
 }
+
+func (s *infoSuite) TestLayoutIsUserScoped(c *C) {
+	c.Check((&snap.Layout{Path: "$HOME/.config/foo"}).IsUserScoped(), Equals, true)
+	c.Check((&snap.Layout{Path: "/usr/foo"}).IsUserScoped(), Equals, false)
+	c.Check((&snap.Layout{Path: "$SNAP/foo"}).IsUserScoped(), Equals, false)
+}
@@ -29,6 +29,7 @@ import (
 	"strings"
 	"syscall"
 
+	"github.com/snapcore/snapd/gadget"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/osutil/sys"
@@ -236,6 +237,12 @@ func loadAndValidate(sourceDir string) (*snap.Info, error) {
 	if err := snap.ValidateContainer(snapdir.New(sourceDir), info, logger.Noticef); err != nil {
 		return nil, err
 	}
+
+	if info.Type == snap.TypeGadget && osutil.FileExists(filepath.Join(sourceDir, "meta", "gadget.yaml")) {
+		if err := gadget.Validate(sourceDir); err != nil {
+			return nil, err
+		}
+	}
 	return info, nil
 }
 
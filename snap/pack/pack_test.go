@@ -140,6 +140,47 @@ apps:
 	c.Assert(err, Equals, snap.ErrMissingPaths)
 }
 
+func (s *packSuite) TestValidateGadget(c *C) {
+	sourceDir := makeExampleSnapSourceDir(c, `name: hello
+version: 0
+type: gadget
+`)
+	// gadget.yaml is optional for classic gadgets
+	c.Assert(pack.CheckSkeleton(sourceDir), IsNil)
+
+	err := ioutil.WriteFile(filepath.Join(sourceDir, "meta", "gadget.yaml"), []byte(`
+volumes:
+  pc:
+    bootloader: grub
+    structure:
+      - name: mbr
+        type: mbr
+        size: 512
+`), 0644)
+	c.Assert(err, IsNil)
+	err = pack.CheckSkeleton(sourceDir)
+	c.Assert(err, ErrorMatches, `invalid volume "pc": invalid structure #0 \("mbr"\): mbr structures cannot be larger than 446 bytes`)
+
+	err = ioutil.WriteFile(filepath.Join(sourceDir, "meta", "gadget.yaml"), []byte(`
+volumes:
+  pc:
+    bootloader: grub
+    structure:
+      - name: mbr
+        type: mbr
+        size: 440
+        content:
+          - image: pc-boot.img
+`), 0644)
+	c.Assert(err, IsNil)
+	err = pack.CheckSkeleton(sourceDir)
+	c.Assert(err, ErrorMatches, `invalid volume "pc": cannot lay out structure #0 \("mbr"\): content "pc-boot.img": .* no such file or directory`)
+
+	err = ioutil.WriteFile(filepath.Join(sourceDir, "pc-boot.img"), make([]byte, 440), 0644)
+	c.Assert(err, IsNil)
+	c.Assert(pack.CheckSkeleton(sourceDir), IsNil)
+}
+
 func (s *packSuite) TestCopyCopies(c *C) {
 	sourceDir := makeExampleSnapSourceDir(c, "{name: hello, version: 0}")
 	// actually this'll be on /tmp so it'll be a link
@@ -564,12 +564,24 @@ func ValidateApp(app *AppInfo) error {
 	if app.RefreshMode != "" && app.Daemon == "" {
 		return fmt.Errorf(`"refresh-mode" cannot be used for %q, only for services`, app.Name)
 	}
+	if app.ReloadOnConfig && app.Daemon == "" {
+		return fmt.Errorf(`"reload-on-config" cannot be used for %q, only for services`, app.Name)
+	}
 
 	return validateAppTimer(app)
 }
 
 // ValidatePathVariables ensures that given path contains only $SNAP, $SNAP_DATA or $SNAP_COMMON.
 func ValidatePathVariables(path string) error {
+	return validatePathVariables(path, "SNAP", "SNAP_DATA", "SNAP_COMMON")
+}
+
+// ValidateUserPathVariables ensures that given path contains only $HOME, $SNAP, $SNAP_USER_DATA or $SNAP_USER_COMMON.
+func ValidateUserPathVariables(path string) error {
+	return validatePathVariables(path, "HOME", "SNAP", "SNAP_USER_DATA", "SNAP_USER_COMMON")
+}
+
+func validatePathVariables(path string, known ...string) error {
 	for path != "" {
 		start := strings.IndexRune(path, '$')
 		if start < 0 {
@@ -583,7 +595,7 @@ func ValidatePathVariables(path string) error {
 			end = len(path)
 		}
 		v := path[:end]
-		if v != "SNAP" && v != "SNAP_DATA" && v != "SNAP_COMMON" {
+		if !strutil.ListContains(known, v) {
 			return fmt.Errorf("reference to unknown variable %q", "$"+v)
 		}
 		path = path[end:]
@@ -649,6 +661,10 @@ func ValidateLayout(layout *Layout, constraints []LayoutConstraint) error {
 		return fmt.Errorf("layout cannot use an empty path")
 	}
 
+	if layout.IsUserScoped() {
+		return validateUserLayout(layout, constraints)
+	}
+
 	if err := ValidatePathVariables(mountPoint); err != nil {
 		return fmt.Errorf("layout %q uses invalid mount point: %s", layout.Path, err)
 	}
@@ -753,3 +769,96 @@ func ValidateLayout(layout *Layout, constraints []LayoutConstraint) error {
 	}
 	return nil
 }
+
+// validateUserLayout ensures that the given user-scoped layout contains only valid subset of constructs.
+func validateUserLayout(layout *Layout, constraints []LayoutConstraint) error {
+	si := layout.Snap
+	// Rules for validating user-scoped layouts:
+	//
+	// * mount point must be in $HOME but not in $HOME/snap
+	// * source of mount --bind must be in one of $SNAP, $SNAP_USER_DATA or $SNAP_USER_COMMON
+	// * target of symlink must be in one of $SNAP, $SNAP_USER_DATA or $SNAP_USER_COMMON
+	// * may not mount on top of an existing layout mountpoint
+	// * elements are always owned by the user invoking the snap
+
+	if strings.Contains(layout.Path[len("$HOME"):], "$") {
+		return fmt.Errorf("layout %q uses invalid mount point: only $HOME may be used", layout.Path)
+	}
+	mountPoint := si.ExpandSnapVariables(layout.Path)
+	if !isAbsAndClean(mountPoint) {
+		return fmt.Errorf("layout %q uses invalid mount point: must be absolute and clean", layout.Path)
+	}
+	if mountedTree("$HOME/snap").IsOffLimits(mountPoint) {
+		return fmt.Errorf("layout %q in an off-limits area", layout.Path)
+	}
+
+	for _, constraint := range constraints {
+		if constraint.IsOffLimits(mountPoint) {
+			return fmt.Errorf("layout %q underneath prior layout item %q", layout.Path, constraint)
+		}
+	}
+
+	var nused int
+	for _, field := range []string{layout.Bind, layout.BindFile, layout.Type, layout.Symlink} {
+		if field != "" {
+			nused++
+		}
+	}
+	if nused != 1 {
+		return fmt.Errorf("layout %q must define a bind mount, a filesystem mount or a symlink", layout.Path)
+	}
+
+	userSource := func(what, source string) error {
+		if err := ValidateUserPathVariables(source); err != nil {
+			return fmt.Errorf("layout %q uses invalid %s %q: %s", layout.Path, what, source, err)
+		}
+		expanded := si.ExpandSnapVariables(source)
+		if !isAbsAndClean(expanded) {
+			return fmt.Errorf("layout %q uses invalid %s %q: must be absolute and clean", layout.Path, what, expanded)
+		}
+		// User-scoped layouts *must* use $SNAP, $SNAP_USER_DATA or
+		// $SNAP_USER_COMMON as the source. Notably $HOME cannot be used
+		// directly so that snaps cannot shuffle arbitrary parts of the home
+		// directory around.
+		if !mountedTree(si.ExpandSnapVariables("$SNAP")).IsOffLimits(expanded) &&
+			!mountedTree(si.ExpandSnapVariables("$SNAP_USER_DATA")).IsOffLimits(expanded) &&
+			!mountedTree(si.ExpandSnapVariables("$SNAP_USER_COMMON")).IsOffLimits(expanded) {
+			return fmt.Errorf("layout %q uses invalid %s %q: must start with $SNAP, $SNAP_USER_DATA or $SNAP_USER_COMMON", layout.Path, what, expanded)
+		}
+		return nil
+	}
+
+	if layout.Bind != "" || layout.BindFile != "" {
+		if err := userSource("bind mount source", layout.Bind+layout.BindFile); err != nil {
+			return err
+		}
+	}
+
+	switch layout.Type {
+	case "tmpfs":
+	case "":
+		// nothing to do
+	default:
+		return fmt.Errorf("layout %q uses invalid filesystem %q", layout.Path, layout.Type)
+	}
+
+	if layout.Symlink != "" {
+		if err := userSource("symlink old name", layout.Symlink); err != nil {
+			return err
+		}
+	}
+
+	// Elements of user-scoped layouts are owned by the user invoking the
+	// snap, custom users and groups cannot be used.
+	if layout.User != "root" && layout.User != "" {
+		return fmt.Errorf("layout %q cannot use custom user %q in the user-scoped layout", layout.Path, layout.User)
+	}
+	if layout.Group != "root" && layout.Group != "" {
+		return fmt.Errorf("layout %q cannot use custom group %q in the user-scoped layout", layout.Path, layout.Group)
+	}
+
+	if layout.Mode&01777 != layout.Mode {
+		return fmt.Errorf("layout %q uses invalid mode %#o", layout.Path, layout.Mode)
+	}
+	return nil
+}
@@ -458,6 +458,14 @@ func (s *ValidateSuite) TestAppRefreshMode(c *C) {
 	c.Check(err, ErrorMatches, `"refresh-mode" cannot be used for "foo", only for services`)
 }
 
+func (s *ValidateSuite) TestAppReloadOnConfig(c *C) {
+	c.Check(ValidateApp(&AppInfo{Name: "foo", Daemon: "simple", ReloadOnConfig: true}), IsNil)
+
+	// non-services cannot be reloaded on configuration changes
+	err := ValidateApp(&AppInfo{Name: "foo", Daemon: "", ReloadOnConfig: true})
+	c.Check(err, ErrorMatches, `"reload-on-config" cannot be used for "foo", only for services`)
+}
+
 func (s *ValidateSuite) TestAppWhitelistError(c *C) {
 	err := ValidateApp(&AppInfo{Name: "foo", Command: "x\n"})
 	c.Assert(err, NotNil)
@@ -696,6 +704,51 @@ func (s *ValidateSuite) TestValidateLayout(c *C) {
 	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$SNAP/data", Symlink: "$SNAP_DATA"}, nil), IsNil)
 }
 
+func (s *ValidateSuite) TestValidateUserLayout(c *C) {
+	si := &Info{SuggestedName: "foo", SideInfo: SideInfo{Revision: R(42)}}
+	// Several invalid layouts.
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$HOME/foo"}, nil),
+		ErrorMatches, `layout "\$HOME/foo" must define a bind mount, a filesystem mount or a symlink`)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$HOME/foo", Type: "ext4"}, nil),
+		ErrorMatches, `layout "\$HOME/foo" uses invalid filesystem "ext4"`)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$HOME/$SNAP", Type: "tmpfs"}, nil),
+		ErrorMatches, `layout "\$HOME/\$SNAP" uses invalid mount point: only \$HOME may be used`)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$HOME/foo/../bar", Type: "tmpfs"}, nil),
+		ErrorMatches, `layout "\$HOME/foo/../bar" uses invalid mount point: must be absolute and clean`)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$HOME/snap", Type: "tmpfs"}, nil),
+		ErrorMatches, `layout "\$HOME/snap" in an off-limits area`)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$HOME/snap/foo/42", Type: "tmpfs"}, nil),
+		ErrorMatches, `layout "\$HOME/snap/foo/42" in an off-limits area`)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$HOME/foo", Bind: "$HOME/bar"}, nil),
+		ErrorMatches, `layout "\$HOME/foo" uses invalid bind mount source "\$HOME/bar": must start with \$SNAP, \$SNAP_USER_DATA or \$SNAP_USER_COMMON`)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$HOME/foo", Bind: "/snap/foo/420/bar"}, nil),
+		ErrorMatches, `layout "\$HOME/foo" uses invalid bind mount source "/snap/foo/420/bar": must start with \$SNAP, \$SNAP_USER_DATA or \$SNAP_USER_COMMON`)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$HOME/foo", Bind: "$HOME/snap/foo/commonx/bar"}, nil),
+		ErrorMatches, `layout "\$HOME/foo" uses invalid bind mount source "\$HOME/snap/foo/commonx/bar": must start with \$SNAP, \$SNAP_USER_DATA or \$SNAP_USER_COMMON`)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$HOME/foo", Bind: "$SNAP_DATA/bar"}, nil),
+		ErrorMatches, `layout "\$HOME/foo" uses invalid bind mount source "\$SNAP_DATA/bar": reference to unknown variable "\$SNAP_DATA"`)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$HOME/foo", Symlink: "/etc"}, nil),
+		ErrorMatches, `layout "\$HOME/foo" uses invalid symlink old name "/etc": must start with \$SNAP, \$SNAP_USER_DATA or \$SNAP_USER_COMMON`)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$HOME/foo", Type: "tmpfs", User: "joe"}, nil),
+		ErrorMatches, `layout "\$HOME/foo" cannot use custom user "joe" in the user-scoped layout`)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$HOME/foo", Type: "tmpfs", Group: "joe"}, nil),
+		ErrorMatches, `layout "\$HOME/foo" cannot use custom group "joe" in the user-scoped layout`)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$HOME/foo", Type: "tmpfs", Mode: 02755}, nil),
+		ErrorMatches, `layout "\$HOME/foo" uses invalid mode 02755`)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$HOME/foo/bar", Type: "tmpfs"}, []LayoutConstraint{testConstraint("$HOME/foo")}),
+		ErrorMatches, `layout "\$HOME/foo/bar" underneath prior layout item "\$HOME/foo"`)
+	// System layouts cannot use per-user variables.
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "/foo", Bind: "$SNAP_USER_DATA/foo"}, nil),
+		ErrorMatches, `layout "/foo" uses invalid bind mount source "\$SNAP_USER_DATA/foo": reference to unknown variable "\$SNAP_USER_DATA"`)
+
+	// Several valid layouts.
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$HOME/.config/foo", Bind: "$SNAP_USER_DATA/.config/foo"}, nil), IsNil)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$HOME/.config/foo", Bind: "$SNAP_USER_COMMON/.config/foo"}, nil), IsNil)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$HOME/.foorc", BindFile: "$SNAP_USER_DATA/foorc"}, nil), IsNil)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$HOME/.foorc", Symlink: "$SNAP/etc/foorc"}, nil), IsNil)
+	c.Check(ValidateLayout(&Layout{Snap: si, Path: "$HOME/.cache/foo", Type: "tmpfs", User: "root", Group: "root", Mode: 0700}, nil), IsNil)
+}
+
 func (s *ValidateSuite) TestValidateLayoutAll(c *C) {
 	// /usr/foo prevents /usr/foo/bar from being valid (tmpfs)
 	const yaml1 = `
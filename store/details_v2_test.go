@@ -281,6 +281,7 @@ func (s *detailsV2Suite) TestInfoFromStoreSnap(c *C) {
 		"Channels", // TODO: support coming later
 		"Tracks",   // TODO: support coming later
 		"Layout",
+		"ConfigSchema",
 		"SideInfo.Channel",
 		"DownloadInfo.AnonDownloadURL", // TODO: going away at some point
 	}
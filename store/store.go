@@ -2018,6 +2018,33 @@ func (s *Store) ReadyToBuy(user *auth.UserState) error {
 	}
 }
 
+// connectivityCheckTimeout is how long ConnectivityCheck waits for the
+// store to answer.
+var connectivityCheckTimeout = 15 * time.Second
+
+// ConnectivityCheck checks whether the store can be reached, returning
+// an error if it cannot.
+func (s *Store) ConnectivityCheck() error {
+	reqOptions := &requestOptions{
+		Method: "GET",
+		URL:    s.endpointURL(sectionsEndpPath, nil),
+		Accept: halJsonContentType,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), connectivityCheckTimeout)
+	defer cancel()
+	resp, err := s.doRequest(ctx, s.client, reqOptions, nil)
+	if err != nil {
+		return fmt.Errorf("cannot reach the store: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("cannot reach the store: unexpected HTTP code %d", resp.StatusCode)
+	}
+	return nil
+}
+
 func (s *Store) CacheDownloads() int {
 	return s.cfg.CacheDownloads
 }
@@ -2843,6 +2843,51 @@ func (s *storeTestSuite) TestSectionsQueryCustomStore(c *C) {
 	c.Check(sections, DeepEquals, []string{"featured", "database"})
 }
 
+func (s *storeTestSuite) TestConnectivityCheck(c *C) {
+	n := 0
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assertRequest(c, r, "GET", sectionsPath)
+		w.Header().Set("Content-Type", "application/hal+json")
+		w.WriteHeader(200)
+		io.WriteString(w, MockSectionsJSON)
+		n++
+	}))
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	serverURL, _ := url.Parse(mockServer.URL)
+	sto := New(&Config{StoreBaseURL: serverURL}, nil)
+
+	c.Check(sto.ConnectivityCheck(), IsNil)
+	c.Check(n, Equals, 1)
+}
+
+func (s *storeTestSuite) TestConnectivityCheckUnexpectedStatus(c *C) {
+	n := 0
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(500)
+		n++
+	}))
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	serverURL, _ := url.Parse(mockServer.URL)
+	sto := New(&Config{StoreBaseURL: serverURL}, nil)
+
+	c.Check(sto.ConnectivityCheck(), ErrorMatches, "cannot reach the store: unexpected HTTP code 500")
+	// no retries
+	c.Check(n, Equals, 1)
+}
+
+func (s *storeTestSuite) TestConnectivityCheckUnreachable(c *C) {
+	mockServer := httptest.NewServer(nil)
+	serverURL, _ := url.Parse(mockServer.URL)
+	mockServer.Close()
+	sto := New(&Config{StoreBaseURL: serverURL}, nil)
+
+	c.Check(sto.ConnectivityCheck(), ErrorMatches, "cannot reach the store: .*")
+}
+
 const mockNamesJSON = `
 {
   "_embedded": {
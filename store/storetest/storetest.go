@@ -73,6 +73,10 @@ func (Store) ReadyToBuy(*auth.UserState) error {
 	panic("Store.ReadyToBuy not expected")
 }
 
+func (Store) ConnectivityCheck() error {
+	panic("Store.ConnectivityCheck not expected")
+}
+
 func (Store) Sections(context.Context, *auth.UserState) ([]string, error) {
 	panic("Store.Sections not expected")
 }
@@ -109,9 +109,11 @@ type Systemd interface {
 	Stop(service string, timeout time.Duration) error
 	Kill(service, signal, who string) error
 	Restart(service string, timeout time.Duration) error
+	ReloadOrRestart(service ...string) error
 	Status(services ...string) ([]*ServiceStatus, error)
 	LogReader(services []string, n string, follow bool) (io.ReadCloser, error)
 	WriteMountUnitFile(name, what, where, fstype string) (string, error)
+	WriteSwapUnitFile(what string) (string, error)
 	Mask(service string) error
 	Unmask(service string) error
 }
@@ -332,6 +334,14 @@ func (s *systemd) Restart(serviceName string, timeout time.Duration) error {
 	return s.Start(serviceName)
 }
 
+// ReloadOrRestart reloads the given services if they support it, or
+// restarts them otherwise; services that are not running are left
+// alone.
+func (*systemd) ReloadOrRestart(serviceNames ...string) error {
+	_, err := systemctlCmd(append([]string{"try-reload-or-restart"}, serviceNames...)...)
+	return err
+}
+
 // Error is returned if the systemd action failed
 type Error struct {
 	cmd      []string
@@ -471,3 +481,24 @@ WantedBy=multi-user.target
 	mu := MountUnitPath(where)
 	return filepath.Base(mu), osutil.AtomicWriteFile(mu, []byte(c), 0644, 0)
 }
+
+// SwapUnitPath returns the path of the swap unit for the given swap file
+func SwapUnitPath(what string) string {
+	escapedPath := EscapeUnitNamePath(what)
+	return filepath.Join(dirs.SnapServicesDir, escapedPath+".swap")
+}
+
+func (s *systemd) WriteSwapUnitFile(what string) (string, error) {
+	c := fmt.Sprintf(`[Unit]
+Description=Swap file %s
+
+[Swap]
+What=%s
+
+[Install]
+WantedBy=swap.target
+`, what, what)
+
+	su := SwapUnitPath(what)
+	return filepath.Base(su), osutil.AtomicWriteFile(su, []byte(c), 0644, 0)
+}
@@ -386,6 +386,12 @@ func (s *SystemdTestSuite) TestRestart(c *C) {
 	c.Check(s.argses[2], DeepEquals, []string{"start", "foo"})
 }
 
+func (s *SystemdTestSuite) TestReloadOrRestart(c *C) {
+	err := New("", s.rep).ReloadOrRestart("foo", "bar")
+	c.Assert(err, IsNil)
+	c.Check(s.argses, DeepEquals, [][]string{{"try-reload-or-restart", "foo", "bar"}})
+}
+
 func (s *SystemdTestSuite) TestKill(c *C) {
 	c.Assert(New("", s.rep).Kill("foo", "HUP", ""), IsNil)
 	c.Check(s.argses, DeepEquals, [][]string{{"kill", "foo", "-s", "HUP", "--kill-who=all"}})
@@ -483,6 +489,28 @@ WantedBy=multi-user.target
 `[1:], mockSnapPath))
 }
 
+func (s *SystemdTestSuite) TestSwapUnitPath(c *C) {
+	c.Assert(SwapUnitPath("/var/tmp/swapfile.swp"), Equals, filepath.Join(dirs.SnapServicesDir, "var-tmp-swapfile.swp.swap"))
+}
+
+func (s *SystemdTestSuite) TestWriteSwapUnit(c *C) {
+	swapUnitName, err := New("", nil).WriteSwapUnitFile("/var/tmp/swapfile.swp")
+	c.Assert(err, IsNil)
+	defer os.Remove(swapUnitName)
+
+	c.Check(swapUnitName, Equals, "var-tmp-swapfile.swp.swap")
+	c.Assert(filepath.Join(dirs.SnapServicesDir, swapUnitName), testutil.FileEquals, `
+[Unit]
+Description=Swap file /var/tmp/swapfile.swp
+
+[Swap]
+What=/var/tmp/swapfile.swp
+
+[Install]
+WantedBy=swap.target
+`[1:])
+}
+
 func (s *SystemdTestSuite) TestWriteMountUnitForDirs(c *C) {
 	// a directory instead of a file produces a different output
 	snapDir := c.MkDir()
@@ -315,6 +315,26 @@ func StopServices(apps []*snap.AppInfo, reason snap.ServiceStopReason, inter int
 
 }
 
+// ReloadOrRestartServices reloads or, if they do not support it,
+// restarts the service units for the applications from the snap which
+// are services and are running.
+func ReloadOrRestartServices(apps []*snap.AppInfo, inter interacter) error {
+	sysd := systemd.New(dirs.GlobalRootDir, inter)
+
+	services := make([]string, 0, len(apps))
+	for _, app := range apps {
+		if !app.IsService() {
+			continue
+		}
+		services = append(services, app.ServiceName())
+	}
+	if len(services) == 0 {
+		return nil
+	}
+
+	return sysd.ReloadOrRestart(services...)
+}
+
 // RemoveSnapServices disables and removes service units for the applications from the snap which are services.
 func RemoveSnapServices(s *snap.Info, inter interacter) error {
 	sysd := systemd.New(dirs.GlobalRootDir, inter)
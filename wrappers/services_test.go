@@ -231,6 +231,32 @@ func (s *servicesTestSuite) TestStartServices(c *C) {
 	c.Assert(sysdLog, DeepEquals, [][]string{{"start", filepath.Base(svcFile)}})
 }
 
+func (s *servicesTestSuite) TestReloadOrRestartServices(c *C) {
+	var sysdLog [][]string
+	r := systemd.MockSystemctl(func(cmd ...string) ([]byte, error) {
+		sysdLog = append(sysdLog, cmd)
+		return nil, nil
+	})
+	defer r()
+
+	info := snaptest.MockSnap(c, packageHello+`
+ svc2:
+  daemon: simple
+`, &snap.SideInfo{Revision: snap.R(12)})
+
+	apps := []*snap.AppInfo{info.Apps["hello"], info.Apps["svc1"], info.Apps["svc2"]}
+	err := wrappers.ReloadOrRestartServices(apps, nil)
+	c.Assert(err, IsNil)
+	c.Check(sysdLog, DeepEquals, [][]string{
+		{"try-reload-or-restart", "snap.hello-snap.svc1.service", "snap.hello-snap.svc2.service"},
+	})
+
+	sysdLog = nil
+	err = wrappers.ReloadOrRestartServices([]*snap.AppInfo{info.Apps["hello"]}, nil)
+	c.Assert(err, IsNil)
+	c.Check(sysdLog, HasLen, 0)
+}
+
 func (s *servicesTestSuite) TestAddSnapMultiServicesFailCreateCleanup(c *C) {
 	var sysdLog [][]string
 